@@ -0,0 +1,58 @@
+// Package acme provides an ACME HTTP-01 challenge handler usable by external certificate managers
+package acme
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ChallengePath is the path prefix of the HTTP-01 challenges
+const ChallengePath = "/.well-known/acme-challenge/"
+
+// ChallengeHandler serves the HTTP-01 challenges key authorizations.
+// Its Present and CleanUp methods are compatible with the lego challenge.Provider interface.
+type ChallengeHandler struct {
+	mu     sync.RWMutex
+	tokens map[string]string
+}
+
+func NewChallengeHandler() *ChallengeHandler {
+	return &ChallengeHandler{tokens: make(map[string]string)}
+}
+
+// Present makes the key authorization available for the token
+func (h *ChallengeHandler) Present(_, token, keyAuth string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.tokens[token] = keyAuth
+	return nil
+}
+
+// CleanUp removes the token
+func (h *ChallengeHandler) CleanUp(_, token, _ string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.tokens, token)
+	return nil
+}
+
+func (h *ChallengeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, ChallengePath) {
+		http.NotFound(w, r)
+		return
+	}
+	h.mu.RLock()
+	keyAuth, ok := h.tokens[strings.TrimPrefix(r.URL.Path, ChallengePath)]
+	h.mu.RUnlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(keyAuth))
+}
@@ -0,0 +1,29 @@
+package acme
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChallengeHandler(t *testing.T) {
+	h := NewChallengeHandler()
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+	assert.Equal(t, http.StatusNotFound, get(ChallengePath+"token").Code)
+
+	require.NoError(t, h.Present("example.com", "token", "key-auth"))
+	rec := get(ChallengePath + "token")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "key-auth", rec.Body.String())
+	assert.Equal(t, http.StatusNotFound, get("/token").Code)
+
+	require.NoError(t, h.CleanUp("example.com", "token", "key-auth"))
+	assert.Equal(t, http.StatusNotFound, get(ChallengePath+"token").Code)
+}
@@ -0,0 +1,171 @@
+// Package apikey issues, verifies and revokes api keys. Only the SHA-256 hash of the key secret is stored,
+// so the key is returned once, when it is issued.
+//
+// The keys are formatted as <prefix>_<id>_<secret>: the id is used to look up the key record,
+// and the prefix allows secret scanners to detect leaked keys.
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPrefix = "key"
+	idSize        = 8
+	secretSize    = 32
+)
+
+var (
+	// ErrNotFound is returned when the key does not exist
+	ErrNotFound = errors.New("apikey: not found")
+	// ErrInvalid is returned when the key is malformed or its secret does not match
+	ErrInvalid = errors.New("apikey: invalid key")
+	// ErrRevoked is returned when the key was revoked
+	ErrRevoked = errors.New("apikey: revoked")
+	// ErrExpired is returned when the key is expired
+	ErrExpired = errors.New("apikey: expired")
+)
+
+// Key is an api key record
+type Key struct {
+	ID string
+	// Name is a human readable description, e.g. "ci"
+	Name string
+	// Owner is the identity the key authenticates, it is used as the rpcctx identity, hence for the quotas
+	Owner  string
+	Scopes []string
+	// Hash is the SHA-256 hash of the key secret
+	Hash      []byte
+	CreatedAt time.Time
+	// ExpiresAt is zero if the key does not expire
+	ExpiresAt time.Time
+	// RevokedAt is zero if the key is not revoked
+	RevokedAt time.Time
+}
+
+// HasScope returns true if the key was issued with scope
+func (k *Key) HasScope(scope string) bool {
+	for _, v := range k.Scopes {
+		if v == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Store persists the key records
+type Store interface {
+	// Create stores a new key
+	Create(ctx context.Context, k *Key) error
+	// Get returns the key, or ErrNotFound
+	Get(ctx context.Context, id string) (*Key, error)
+	// List returns the keys of owner, all the keys if owner is empty
+	List(ctx context.Context, owner string) ([]*Key, error)
+	// Revoke marks the key as revoked, or returns ErrNotFound
+	Revoke(ctx context.Context, id string, at time.Time) error
+}
+
+type Option func(m *Manager)
+
+// WithPrefix sets the keys prefix, defaults to "key"
+func WithPrefix(prefix string) Option {
+	return func(m *Manager) {
+		m.prefix = prefix
+	}
+}
+
+// Manager issues and verifies the api keys
+type Manager struct {
+	s      Store
+	prefix string
+	now    func() time.Time
+}
+
+// New returns a Manager storing the keys in s
+func New(s Store, opts ...Option) *Manager {
+	m := &Manager{s: s, prefix: defaultPrefix, now: time.Now}
+	for _, v := range opts {
+		v(m)
+	}
+	return m
+}
+
+// Issue creates a key for owner, a zero ttl means no expiration. The returned key is not stored, only its hash is.
+func (m *Manager) Issue(ctx context.Context, owner, name string, ttl time.Duration, scopes ...string) (string, *Key, error) {
+	id := make([]byte, idSize)
+	secret := make([]byte, secretSize)
+	if _, err := rand.Read(id); err != nil {
+		return "", nil, err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, err
+	}
+	s := base64.RawURLEncoding.EncodeToString(secret)
+	k := &Key{
+		ID:        hex.EncodeToString(id),
+		Name:      name,
+		Owner:     owner,
+		Scopes:    scopes,
+		Hash:      hash(s),
+		CreatedAt: m.now().UTC(),
+	}
+	if ttl > 0 {
+		k.ExpiresAt = k.CreatedAt.Add(ttl)
+	}
+	if err := m.s.Create(ctx, k); err != nil {
+		return "", nil, err
+	}
+	return m.prefix + "_" + k.ID + "_" + s, k, nil
+}
+
+// Verify returns the key record if key is valid
+func (m *Manager) Verify(ctx context.Context, key string) (*Key, error) {
+	if !strings.HasPrefix(key, m.prefix+"_") {
+		return nil, ErrInvalid
+	}
+	rest := key[len(m.prefix)+1:]
+	i := strings.Index(rest, "_")
+	if i != idSize*2 {
+		return nil, ErrInvalid
+	}
+	k, err := m.s.Get(ctx, rest[:i])
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(k.Hash, hash(rest[i+1:])) != 1 {
+		return nil, ErrInvalid
+	}
+	if !k.RevokedAt.IsZero() {
+		return nil, ErrRevoked
+	}
+	if !k.ExpiresAt.IsZero() && !m.now().Before(k.ExpiresAt) {
+		return nil, ErrExpired
+	}
+	return k, nil
+}
+
+// List returns the keys of owner, all the keys if owner is empty
+func (m *Manager) List(ctx context.Context, owner string) ([]*Key, error) {
+	return m.s.List(ctx, owner)
+}
+
+// Revoke revokes the key with the given id
+func (m *Manager) Revoke(ctx context.Context, id string) error {
+	return m.s.Revoke(ctx, id, m.now().UTC())
+}
+
+func hash(secret string) []byte {
+	h := sha256.Sum256([]byte(secret))
+	return h[:]
+}
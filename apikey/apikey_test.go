@@ -0,0 +1,83 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager(t *testing.T) {
+	ctx := context.Background()
+	m := New(NewMemoryStore(), WithPrefix("test"))
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	key, k, err := m.Issue(ctx, "alice", "ci", time.Hour, "read")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key, "test_"+k.ID+"_"))
+
+	got, err := m.Verify(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", got.Owner)
+	assert.True(t, got.HasScope("read"))
+	assert.False(t, got.HasScope("write"))
+
+	for _, v := range []string{"", "test_", "other_" + key[5:], key + "x", "test_" + strings.Repeat("0", 16) + "_secret"} {
+		_, err = m.Verify(ctx, v)
+		assert.True(t, errors.Is(err, ErrInvalid), v)
+	}
+
+	_, _, err = m.Issue(ctx, "bob", "", 0)
+	require.NoError(t, err)
+	ks, err := m.List(ctx, "alice")
+	require.NoError(t, err)
+	assert.Len(t, ks, 1)
+	ks, err = m.List(ctx, "")
+	require.NoError(t, err)
+	assert.Len(t, ks, 2)
+
+	now = now.Add(2 * time.Hour)
+	_, err = m.Verify(ctx, key)
+	assert.True(t, errors.Is(err, ErrExpired))
+
+	require.NoError(t, m.Revoke(ctx, k.ID))
+	_, err = m.Verify(ctx, key)
+	assert.True(t, errors.Is(err, ErrRevoked))
+	assert.True(t, errors.Is(m.Revoke(ctx, "missing"), ErrNotFound))
+}
+
+func TestSchema(t *testing.T) {
+	stmts := Schema("keys")
+	require.Len(t, stmts, 2)
+	for _, v := range stmts {
+		assert.NotContains(t, v, ";")
+	}
+	// there is no sqlite driver in the module dependencies, the statements are run with the sqlite3 shell
+	sqlite, err := exec.LookPath("sqlite3")
+	if err != nil {
+		t.Skip("sqlite3 not found")
+	}
+	dir, err := ioutil.TempDir("", "apikey")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	db := filepath.Join(dir, "keys.db")
+	// the migration can run again
+	for i := 0; i < 2; i++ {
+		for _, v := range stmts {
+			out, err := exec.Command(sqlite, db, v).CombinedOutput()
+			require.NoError(t, err, string(out))
+		}
+	}
+	out, err := exec.Command(sqlite, db, "SELECT name FROM sqlite_master WHERE name NOT LIKE 'sqlite_%' ORDER BY name").CombinedOutput()
+	require.NoError(t, err, string(out))
+	assert.Equal(t, "keys\nkeys_owner\n", string(out))
+}
@@ -0,0 +1,70 @@
+package apikey
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+type memory struct {
+	mu   sync.RWMutex
+	keys map[string]Key
+}
+
+// NewMemoryStore returns an in-process Store, e.g. for tests
+func NewMemoryStore() Store {
+	return &memory{keys: make(map[string]Key)}
+}
+
+func (m *memory) Create(_ context.Context, k *Key) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.keys[k.ID]; ok {
+		return fmt.Errorf("apikey: %s already exists", k.ID)
+	}
+	m.keys[k.ID] = *k
+	return nil
+}
+
+func (m *memory) Get(_ context.Context, id string) (*Key, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	k, ok := m.keys[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &k, nil
+}
+
+func (m *memory) List(_ context.Context, owner string) ([]*Key, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var out []*Key
+	for _, v := range m.keys {
+		if owner != "" && v.Owner != owner {
+			continue
+		}
+		k := v
+		out = append(out, &k)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].CreatedAt.Before(out[j].CreatedAt)
+	})
+	return out, nil
+}
+
+func (m *memory) Revoke(_ context.Context, id string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k, ok := m.keys[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if k.RevokedAt.IsZero() {
+		k.RevokedAt = at
+		m.keys[id] = k
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package apikey
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const defaultTable = "api_keys"
+
+// Schema returns the DDL statements of the keys table and its owner index for postgres and sqlite.
+// They are returned separately as most drivers do not execute several statements at once.
+// MySQL does not support CREATE INDEX IF NOT EXISTS: the table statement is portable, but the index
+// must be created once without the IF NOT EXISTS clause.
+func Schema(table string) []string {
+	return []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id VARCHAR(32) PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	owner VARCHAR(255) NOT NULL,
+	scopes TEXT NOT NULL,
+	hash VARCHAR(64) NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	expires_at TIMESTAMP NULL,
+	revoked_at TIMESTAMP NULL
+)`, table),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s_owner ON %s (owner)`, table, table),
+	}
+}
+
+type SQLOption func(s *sqlStore)
+
+// WithTable sets the table name, defaults to api_keys
+func WithTable(name string) SQLOption {
+	return func(s *sqlStore) {
+		s.table = name
+	}
+}
+
+// WithDollarPlaceholders uses the $1 placeholders, e.g. for postgres, instead of ?
+func WithDollarPlaceholders() SQLOption {
+	return func(s *sqlStore) {
+		s.dollar = true
+	}
+}
+
+type sqlStore struct {
+	db     *sql.DB
+	table  string
+	dollar bool
+}
+
+// NewSQLStore returns a Store using db, the table must exist, see Schema and Migrate
+func NewSQLStore(db *sql.DB, opts ...SQLOption) Store {
+	s := &sqlStore{db: db, table: defaultTable}
+	for _, v := range opts {
+		v(s)
+	}
+	return s
+}
+
+// Migrate creates the keys table if it does not exist, for postgres and sqlite, see Schema
+func Migrate(ctx context.Context, db *sql.DB, table string) error {
+	if table == "" {
+		table = defaultTable
+	}
+	for _, v := range Schema(table) {
+		if _, err := db.ExecContext(ctx, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// query replaces the ? placeholders if needed
+func (s *sqlStore) query(q string) string {
+	q = strings.Replace(q, "{table}", s.table, -1)
+	if !s.dollar {
+		return q
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range q {
+		if r == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+func (s *sqlStore) Create(ctx context.Context, k *Key) error {
+	_, err := s.db.ExecContext(ctx, s.query(`INSERT INTO {table} (id, name, owner, scopes, hash, created_at, expires_at, revoked_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`),
+		k.ID, k.Name, k.Owner, strings.Join(k.Scopes, ","), fmt.Sprintf("%x", k.Hash), k.CreatedAt, nullTime(k.ExpiresAt), nullTime(k.RevokedAt))
+	return err
+}
+
+const columns = `id, name, owner, scopes, hash, created_at, expires_at, revoked_at`
+
+func scan(row interface {
+	Scan(dest ...interface{}) error
+}) (*Key, error) {
+	var (
+		k                Key
+		scopes, hash     string
+		expires, revoked sql.NullTime
+	)
+	if err := row.Scan(&k.ID, &k.Name, &k.Owner, &scopes, &hash, &k.CreatedAt, &expires, &revoked); err != nil {
+		return nil, err
+	}
+	if scopes != "" {
+		k.Scopes = strings.Split(scopes, ",")
+	}
+	if _, err := fmt.Sscanf(hash, "%x", &k.Hash); err != nil {
+		return nil, fmt.Errorf("apikey: %s: invalid hash: %w", k.ID, err)
+	}
+	k.ExpiresAt = expires.Time
+	k.RevokedAt = revoked.Time
+	return &k, nil
+}
+
+func (s *sqlStore) Get(ctx context.Context, id string) (*Key, error) {
+	k, err := scan(s.db.QueryRowContext(ctx, s.query(`SELECT `+columns+` FROM {table} WHERE id = ?`), id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return k, err
+}
+
+func (s *sqlStore) List(ctx context.Context, owner string) ([]*Key, error) {
+	q := `SELECT ` + columns + ` FROM {table}`
+	var args []interface{}
+	if owner != "" {
+		q += ` WHERE owner = ?`
+		args = append(args, owner)
+	}
+	rows, err := s.db.QueryContext(ctx, s.query(q+` ORDER BY created_at`), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*Key
+	for rows.Next() {
+		k, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, k)
+	}
+	return out, rows.Err()
+}
+
+func (s *sqlStore) Revoke(ctx context.Context, id string, at time.Time) error {
+	res, err := s.db.ExecContext(ctx, s.query(`UPDATE {table} SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`), at, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n != 0 {
+		return nil
+	}
+	// already revoked or not found
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	return nil
+}
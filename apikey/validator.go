@@ -0,0 +1,48 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+
+	errors2 "go.linka.cloud/grpc/errors"
+	"go.linka.cloud/grpc/rpcctx"
+)
+
+type keyCtx struct{}
+
+// FromContext returns the key which authenticated the request
+func FromContext(ctx context.Context) (*Key, bool) {
+	k, ok := ctx.Value(keyCtx{}).(*Key)
+	return k, ok
+}
+
+// Validator returns an auth.APIKeyValidator, for auth.WithAPIKeyValidators, or an auth.TokenValidator,
+// for auth.WithTokenValidators when the keys are sent as bearer tokens.
+// The key owner is set as the rpcctx identity, so that the quota interceptors apply per owner.
+// If scopes are given, the key must have at least one of them.
+func (m *Manager) Validator(scopes ...string) func(ctx context.Context, key string) (context.Context, error) {
+	return func(ctx context.Context, key string) (context.Context, error) {
+		k, err := m.Verify(ctx, key)
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrInvalid), errors.Is(err, ErrRevoked), errors.Is(err, ErrExpired):
+			return ctx, errors2.Unauthenticatedf("%v", err)
+		default:
+			return ctx, errors2.Unavailabled(err)
+		}
+		if len(scopes) != 0 {
+			ok := false
+			for _, v := range scopes {
+				if k.HasScope(v) {
+					ok = true
+					break
+				}
+			}
+			if !ok {
+				return ctx, errors2.PermissionDeniedf("api key missing scope")
+			}
+		}
+		ctx = context.WithValue(ctx, keyCtx{}, k)
+		return rpcctx.WithIdentity(ctx, k.Owner), nil
+	}
+}
@@ -0,0 +1,58 @@
+// Package blob provides an object storage abstraction with S3, filesystem and in-memory backends,
+// and helpers to stream objects over grpc streams and http.
+// The S3 compatible object stores, e.g. AWS S3, GCS or MinIO, are supported with NewS3 and NewGCS,
+// other backends can be used by implementing the Bucket interface.
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when the object does not exist
+	ErrNotFound = errors.New("blob: not found")
+	// ErrInvalidKey is returned when the key is empty or escapes the bucket, e.g. ../file
+	ErrInvalidKey = errors.New("blob: invalid key")
+)
+
+// Attributes describes a stored object
+type Attributes struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// WriterOptions are the options of a new object
+type WriterOptions struct {
+	ContentType string
+}
+
+// Bucket stores objects by key, keys use / as separator
+type Bucket interface {
+	// Reader opens the object, the caller must close the reader
+	Reader(ctx context.Context, key string) (io.ReadCloser, *Attributes, error)
+	// Writer creates or replaces the object, it is only visible once the writer is closed successfully
+	Writer(ctx context.Context, key string, opts *WriterOptions) (io.WriteCloser, error)
+	Attributes(ctx context.Context, key string) (*Attributes, error)
+	Delete(ctx context.Context, key string) error
+	// List returns the objects which keys start with prefix, sorted by key
+	List(ctx context.Context, prefix string) ([]*Attributes, error)
+}
+
+// validKey returns the cleaned key
+func validKey(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") {
+		return "", ErrInvalidKey
+	}
+	k := path.Clean(key)
+	if k == "." || k == ".." || strings.HasPrefix(k, "../") {
+		return "", ErrInvalidKey
+	}
+	return k, nil
+}
@@ -0,0 +1,162 @@
+package blob
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// NewFS returns a bucket storing the objects as files under root.
+// The content type is derived from the key extension.
+func NewFS(root string) (Bucket, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+	return &fs{root: root}, nil
+}
+
+type fs struct {
+	root string
+}
+
+func (f *fs) path(key string) (string, string, error) {
+	key, err := validKey(key)
+	if err != nil {
+		return "", "", err
+	}
+	return key, filepath.Join(f.root, filepath.FromSlash(key)), nil
+}
+
+func (f *fs) attributes(key string, fi os.FileInfo) *Attributes {
+	return &Attributes{
+		Key:         key,
+		Size:        fi.Size(),
+		ContentType: mime.TypeByExtension(path.Ext(key)),
+		ModTime:     fi.ModTime(),
+	}
+}
+
+func (f *fs) Reader(_ context.Context, key string) (io.ReadCloser, *Attributes, error) {
+	key, p, err := f.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	file, err := os.Open(p)
+	if os.IsNotExist(err) {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	if fi.IsDir() {
+		file.Close()
+		return nil, nil, ErrNotFound
+	}
+	return file, f.attributes(key, fi), nil
+}
+
+func (f *fs) Writer(_ context.Context, key string, _ *WriterOptions) (io.WriteCloser, error) {
+	_, p, err := f.path(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return nil, err
+	}
+	// write to a temporary file so that readers never see a partial object
+	tmp, err := ioutil.TempFile(filepath.Dir(p), ".blob-")
+	if err != nil {
+		return nil, err
+	}
+	return &fsWriter{File: tmp, path: p}, nil
+}
+
+func (f *fs) Attributes(_ context.Context, key string) (*Attributes, error) {
+	key, p, err := f.path(key)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(p)
+	if os.IsNotExist(err) || (err == nil && fi.IsDir()) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f.attributes(key, fi), nil
+}
+
+func (f *fs) Delete(_ context.Context, key string) error {
+	_, p, err := f.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (f *fs) List(_ context.Context, prefix string) ([]*Attributes, error) {
+	var out []*Attributes
+	err := filepath.Walk(f.root, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() || strings.HasPrefix(fi.Name(), ".blob-") {
+			return nil
+		}
+		rel, err := filepath.Rel(f.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if strings.HasPrefix(key, prefix) {
+			out = append(out, f.attributes(key, fi))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Key < out[j].Key
+	})
+	return out, nil
+}
+
+type fsWriter struct {
+	*os.File
+	path string
+}
+
+func (w *fsWriter) Close() error {
+	if err := w.File.Close(); err != nil {
+		os.Remove(w.File.Name())
+		return err
+	}
+	if err := os.Rename(w.File.Name(), w.path); err != nil {
+		os.Remove(w.File.Name())
+		return err
+	}
+	return nil
+}
+
+func (w *fsWriter) abort() {
+	w.File.Close()
+	os.Remove(w.File.Name())
+}
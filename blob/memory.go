@@ -0,0 +1,130 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type object struct {
+	data  []byte
+	attrs Attributes
+}
+
+// NewMemory returns an in-memory bucket, e.g. for tests
+func NewMemory() Bucket {
+	return &memory{objects: make(map[string]*object)}
+}
+
+type memory struct {
+	mu      sync.RWMutex
+	objects map[string]*object
+}
+
+func (m *memory) Reader(_ context.Context, key string) (io.ReadCloser, *Attributes, error) {
+	key, err := validKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	m.mu.RLock()
+	o, ok := m.objects[key]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, nil, ErrNotFound
+	}
+	attrs := o.attrs
+	return ioutil.NopCloser(bytes.NewReader(o.data)), &attrs, nil
+}
+
+func (m *memory) Writer(_ context.Context, key string, opts *WriterOptions) (io.WriteCloser, error) {
+	key, err := validKey(key)
+	if err != nil {
+		return nil, err
+	}
+	w := &memoryWriter{m: m, key: key}
+	if opts != nil {
+		w.contentType = opts.ContentType
+	}
+	return w, nil
+}
+
+func (m *memory) Attributes(_ context.Context, key string) (*Attributes, error) {
+	key, err := validKey(key)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	o, ok := m.objects[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	attrs := o.attrs
+	return &attrs, nil
+}
+
+func (m *memory) Delete(_ context.Context, key string) error {
+	key, err := validKey(key)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.objects[key]; !ok {
+		return ErrNotFound
+	}
+	delete(m.objects, key)
+	return nil
+}
+
+func (m *memory) List(_ context.Context, prefix string) ([]*Attributes, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var out []*Attributes
+	for k, v := range m.objects {
+		if strings.HasPrefix(k, prefix) {
+			attrs := v.attrs
+			out = append(out, &attrs)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Key < out[j].Key
+	})
+	return out, nil
+}
+
+type memoryWriter struct {
+	m           *memory
+	key         string
+	contentType string
+	buf         bytes.Buffer
+}
+
+func (w *memoryWriter) Write(b []byte) (int, error) {
+	return w.buf.Write(b)
+}
+
+func (w *memoryWriter) Close() error {
+	data := w.buf.Bytes()
+	w.m.mu.Lock()
+	defer w.m.mu.Unlock()
+	w.m.objects[w.key] = &object{
+		data: data,
+		attrs: Attributes{
+			Key:         w.key,
+			Size:        int64(len(data)),
+			ContentType: w.contentType,
+			ModTime:     time.Now(),
+		},
+	}
+	return nil
+}
+
+func (w *memoryWriter) abort() {
+	w.buf.Reset()
+}
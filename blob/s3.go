@@ -0,0 +1,391 @@
+package blob
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// GCSEndpoint is the endpoint of the google cloud storage XML api
+	GCSEndpoint = "https://storage.googleapis.com"
+
+	defaultS3Region = "us-east-1"
+	// emptySHA256 is the hex encoded sha256 of an empty payload
+	emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+type S3Option func(s *s3)
+
+// WithS3Credentials sets the access key pair used to sign the requests, the requests are anonymous without them
+func WithS3Credentials(accessKey, secretKey string) S3Option {
+	return func(s *s3) {
+		s.accessKey = accessKey
+		s.secretKey = secretKey
+	}
+}
+
+// WithS3SessionToken sets the session token of temporary credentials, e.g. from an assumed role
+func WithS3SessionToken(token string) S3Option {
+	return func(s *s3) {
+		s.token = token
+	}
+}
+
+// WithS3Region sets the bucket region, defaults to us-east-1
+func WithS3Region(region string) S3Option {
+	return func(s *s3) {
+		s.region = region
+	}
+}
+
+// WithS3HTTPClient sets the client used to call the api
+func WithS3HTTPClient(c *http.Client) S3Option {
+	return func(s *s3) {
+		s.client = c
+	}
+}
+
+// NewS3 returns a bucket using the S3 api at endpoint, e.g. https://s3.eu-west-3.amazonaws.com
+// or a MinIO server. The bucket is addressed in the path, and the requests are signed with AWS Signature Version 4.
+// The objects are buffered to a temporary file until the writer is closed, as the api requires their length.
+func NewS3(endpoint, bucket string, opts ...S3Option) (Bucket, error) {
+	u, err := url.Parse(strings.TrimSuffix(endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("blob: invalid s3 endpoint %q", endpoint)
+	}
+	if bucket == "" {
+		return nil, fmt.Errorf("blob: s3 bucket name is required")
+	}
+	s := &s3{endpoint: u, bucket: bucket, region: defaultS3Region, client: http.DefaultClient}
+	for _, v := range opts {
+		v(s)
+	}
+	return s, nil
+}
+
+// NewGCS returns a bucket using the google cloud storage XML api, which is compatible with the S3 one.
+// The credentials are the HMAC keys of a service account, see WithS3Credentials.
+func NewGCS(bucket string, opts ...S3Option) (Bucket, error) {
+	return NewS3(GCSEndpoint, bucket, append([]S3Option{WithS3Region("auto")}, opts...)...)
+}
+
+type s3 struct {
+	endpoint  *url.URL
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+	token     string
+	client    *http.Client
+	// now is the signing time, for tests
+	now func() time.Time
+}
+
+func (s *s3) Reader(ctx context.Context, key string) (io.ReadCloser, *Attributes, error) {
+	key, err := validKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	res, err := s.do(ctx, http.MethodGet, key, nil, nil, emptySHA256, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return res.Body, s3Attributes(key, res), nil
+}
+
+func (s *s3) Writer(ctx context.Context, key string, opts *WriterOptions) (io.WriteCloser, error) {
+	key, err := validKey(key)
+	if err != nil {
+		return nil, err
+	}
+	tmp, err := ioutil.TempFile("", ".blob-")
+	if err != nil {
+		return nil, err
+	}
+	w := &s3Writer{s: s, ctx: ctx, key: key, file: tmp, hash: sha256.New()}
+	if opts != nil {
+		w.contentType = opts.ContentType
+	}
+	return w, nil
+}
+
+func (s *s3) Attributes(ctx context.Context, key string) (*Attributes, error) {
+	key, err := validKey(key)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.do(ctx, http.MethodHead, key, nil, nil, emptySHA256, nil)
+	if err != nil {
+		return nil, err
+	}
+	res.Body.Close()
+	return s3Attributes(key, res), nil
+}
+
+func (s *s3) Delete(ctx context.Context, key string) error {
+	key, err := validKey(key)
+	if err != nil {
+		return err
+	}
+	// the api does not report the missing objects on deletion
+	if _, err := s.Attributes(ctx, key); err != nil {
+		return err
+	}
+	res, err := s.do(ctx, http.MethodDelete, key, nil, nil, emptySHA256, nil)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+type s3ListResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+}
+
+func (s *s3) List(ctx context.Context, prefix string) ([]*Attributes, error) {
+	var (
+		out   []*Attributes
+		token string
+	)
+	for {
+		q := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		res, err := s.do(ctx, http.MethodGet, "", q, nil, emptySHA256, nil)
+		if err != nil {
+			return nil, err
+		}
+		var r s3ListResult
+		err = xml.NewDecoder(res.Body).Decode(&r)
+		res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range r.Contents {
+			out = append(out, &Attributes{Key: v.Key, Size: v.Size, ModTime: v.LastModified})
+		}
+		if !r.IsTruncated || r.NextContinuationToken == "" {
+			break
+		}
+		token = r.NextContinuationToken
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Key < out[j].Key
+	})
+	return out, nil
+}
+
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// do sends the signed request for the object key, or the bucket if key is empty, it returns ErrNotFound
+// for the missing objects and an error with the api error code for the other failures
+func (s *s3) do(ctx context.Context, method, key string, q url.Values, body io.Reader, payload string, h http.Header) (*http.Response, error) {
+	u := *s.endpoint
+	u.Path = u.Path + "/" + s.bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+	u.RawPath = s3Escape(u.Path, true)
+	u.RawQuery = s3Query(q)
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	for k, v := range h {
+		req.Header[k] = v
+	}
+	if f, ok := body.(*os.File); ok {
+		fi, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		req.ContentLength = fi.Size()
+	}
+	s.sign(req, payload)
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode/100 == 2 {
+		return res, nil
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound && key != "" {
+		return nil, ErrNotFound
+	}
+	var e s3Error
+	b, _ := ioutil.ReadAll(io.LimitReader(res.Body, 4096))
+	if err := xml.Unmarshal(b, &e); err != nil || e.Code == "" {
+		return nil, fmt.Errorf("blob: s3: unexpected status %s", res.Status)
+	}
+	return nil, fmt.Errorf("blob: s3: unexpected status %s: %s: %s", res.Status, e.Code, e.Message)
+}
+
+// sign adds the AWS Signature Version 4 authorization header to the request
+func (s *s3) sign(req *http.Request, payload string) {
+	req.Header.Set("X-Amz-Content-Sha256", payload)
+	if s.accessKey == "" {
+		return
+	}
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	t := now().UTC()
+	date, ts := t.Format("20060102"), t.Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", ts)
+	if s.token != "" {
+		req.Header.Set("X-Amz-Security-Token", s.token)
+	}
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		if k := strings.ToLower(k); strings.HasPrefix(k, "x-amz-") || k == "range" {
+			headers[k] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	var names []string
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonical strings.Builder
+	for _, k := range names {
+		canonical.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signed := strings.Join(names, ";")
+	creq := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonical.String(),
+		signed,
+		payload,
+	}, "\n")
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	sts := "AWS4-HMAC-SHA256\n" + ts + "\n" + scope + "\n" + hexSHA256([]byte(creq))
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	for _, v := range []string{s.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, v)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signed, hex.EncodeToString(hmacSHA256(key, sts))))
+}
+
+func s3Attributes(key string, res *http.Response) *Attributes {
+	a := &Attributes{Key: key, ContentType: res.Header.Get("Content-Type")}
+	if res.ContentLength > 0 {
+		a.Size = res.ContentLength
+	}
+	a.ModTime, _ = http.ParseTime(res.Header.Get("Last-Modified"))
+	return a
+}
+
+// s3Escape percent-encodes everything but the unreserved characters, and the slashes if slash is true
+func s3Escape(s string, slash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && slash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// s3Query returns the canonical query string: sorted by key with the values percent-encoded
+func s3Query(q url.Values) string {
+	var keys []string
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range q[k] {
+			parts = append(parts, s3Escape(k, false)+"="+s3Escape(v, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func hexSHA256(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+type s3Writer struct {
+	s           *s3
+	ctx         context.Context
+	key         string
+	contentType string
+	file        *os.File
+	hash        hash.Hash
+}
+
+func (w *s3Writer) Write(b []byte) (int, error) {
+	n, err := w.file.Write(b)
+	w.hash.Write(b[:n])
+	return n, err
+}
+
+func (w *s3Writer) Close() error {
+	defer w.abort()
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	h := http.Header{}
+	if w.contentType != "" {
+		h.Set("Content-Type", w.contentType)
+	}
+	res, err := w.s.do(w.ctx, http.MethodPut, w.key, nil, w.file, hex.EncodeToString(w.hash.Sum(nil)), h)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+func (w *s3Writer) abort() {
+	w.file.Close()
+	os.Remove(w.file.Name())
+}
@@ -0,0 +1,114 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// DefaultChunkSize is the chunk size used by Send when size is not positive, it stays under the grpc default message size
+const DefaultChunkSize = 64 * 1024
+
+// Send reads r and calls send with chunks of at most size bytes, e.g. to reply on a server stream:
+//
+//	blob.Send(r, 0, func(b []byte) error { return stream.Send(&pb.Chunk{Data: b}) })
+func Send(r io.Reader, size int, send func([]byte) error) error {
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	buf := make([]byte, size)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			// the buffer is reused, send a copy as the message may be retained
+			if err := send(append([]byte(nil), buf[:n]...)); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Receive writes the chunks returned by recv to w until it returns io.EOF, e.g. from a client stream:
+//
+//	blob.Receive(w, func() ([]byte, error) { c, err := stream.Recv(); return c.GetData(), err })
+func Receive(w io.Writer, recv func() ([]byte, error)) error {
+	for {
+		b, err := recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+}
+
+// aborter is implemented by the writers which can discard a partially written object
+type aborter interface {
+	abort()
+}
+
+// Upload stores the chunks returned by recv in the bucket, the object is only created if all the chunks are received
+func Upload(ctx context.Context, b Bucket, key string, opts *WriterOptions, recv func() ([]byte, error)) error {
+	w, err := b.Writer(ctx, key, opts)
+	if err != nil {
+		return err
+	}
+	if err := Receive(w, recv); err != nil {
+		if a, ok := w.(aborter); ok {
+			a.abort()
+		}
+		return err
+	}
+	return w.Close()
+}
+
+// Handler serves the bucket objects over http, the key is the request path without prefix.
+// It can be mounted on the service with Service.Handle.
+func Handler(b Bucket, prefix string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		key := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, prefix), "/")
+		rc, attrs, err := b.Reader(r.Context(), key)
+		if errors.Is(err, ErrNotFound) || errors.Is(err, ErrInvalidKey) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		defer rc.Close()
+		if attrs.ContentType != "" {
+			w.Header().Set("Content-Type", attrs.ContentType)
+		}
+		if rs, ok := rc.(io.ReadSeeker); ok {
+			// supports range and conditional requests
+			http.ServeContent(w, r, key, attrs.ModTime, rs)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.FormatInt(attrs.Size, 10))
+		if !attrs.ModTime.IsZero() {
+			w.Header().Set("Last-Modified", attrs.ModTime.UTC().Format(http.TimeFormat))
+		}
+		if r.Method == http.MethodHead {
+			return
+		}
+		io.Copy(w, rc)
+	})
+}
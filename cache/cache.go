@@ -0,0 +1,43 @@
+// Package cache provides a common key value cache interface with in-memory and redis implementations.
+// The implementations are service modules: their connections and janitors are managed by the service lifecycle.
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrNotFound is returned when the key does not exist or is expired
+var ErrNotFound = errors.New("cache: not found")
+
+type Cache interface {
+	// Get returns the value of key, or ErrNotFound
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set stores the value, a zero ttl means no expiration
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes the key, it does not fail if the key does not exist
+	Delete(ctx context.Context, key string) error
+	// Ping checks the cache availability, it can be used as a service readiness check
+	Ping(ctx context.Context) error
+}
+
+// GetProto reads the key into m
+func GetProto(ctx context.Context, c Cache, key string, m proto.Message) error {
+	b, err := c.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(b, m)
+}
+
+// SetProto stores m under key
+func SetProto(ctx context.Context, c Cache, key string, m proto.Message, ttl time.Duration) error {
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return c.Set(ctx, key, b, ttl)
+}
@@ -0,0 +1,135 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testCache(t *testing.T, c Cache) {
+	ctx := context.Background()
+	require.NoError(t, c.Ping(ctx))
+	_, err := c.Get(ctx, "key")
+	assert.Equal(t, ErrNotFound, err)
+
+	require.NoError(t, c.Set(ctx, "key", []byte("value"), 0))
+	v, err := c.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), v)
+
+	require.NoError(t, c.Delete(ctx, "key"))
+	_, err = c.Get(ctx, "key")
+	assert.Equal(t, ErrNotFound, err)
+	require.NoError(t, c.Delete(ctx, "key"))
+}
+
+func TestMemory(t *testing.T) {
+	m := NewMemory(WithJanitorInterval(time.Millisecond)).(*memory)
+	testCache(t, m)
+
+	now := time.Now()
+	m.now = func() time.Time { return now }
+	ctx := context.Background()
+	require.NoError(t, m.Set(ctx, "ttl", []byte("value"), time.Second))
+	_, err := m.Get(ctx, "ttl")
+	require.NoError(t, err)
+	now = now.Add(time.Second)
+	_, err = m.Get(ctx, "ttl")
+	assert.Equal(t, ErrNotFound, err)
+	assert.Equal(t, 1, m.Len())
+	m.evict()
+	assert.Equal(t, 0, m.Len())
+}
+
+// fakeRedis implements the few commands used by the redis cache
+type fakeRedis struct {
+	mu   sync.Mutex
+	data map[string]string
+	cmds []string
+}
+
+func (f *fakeRedis) serve(t *testing.T, l net.Listener) {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer c.Close()
+			r := bufio.NewReader(c)
+			w := bufio.NewWriter(c)
+			for {
+				v, err := readReply(r)
+				if err != nil {
+					return
+				}
+				var args []string
+				for _, a := range v.([]interface{}) {
+					args = append(args, string(a.([]byte)))
+				}
+				f.mu.Lock()
+				f.cmds = append(f.cmds, strings.Join(args, " "))
+				switch args[0] {
+				case "PING":
+					w.WriteString("+PONG\r\n")
+				case "AUTH":
+					if args[len(args)-1] != "secret" {
+						w.WriteString("-WRONGPASS invalid password\r\n")
+					} else {
+						w.WriteString("+OK\r\n")
+					}
+				case "SET":
+					f.data[args[1]] = args[2]
+					w.WriteString("+OK\r\n")
+				case "GET":
+					if v, ok := f.data[args[1]]; ok {
+						w.WriteString("$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n")
+					} else {
+						w.WriteString("$-1\r\n")
+					}
+				case "DEL":
+					delete(f.data, args[1])
+					w.WriteString(":1\r\n")
+				default:
+					w.WriteString("-ERR unknown command\r\n")
+				}
+				f.mu.Unlock()
+				w.Flush()
+			}
+		}()
+	}
+}
+
+func TestRedis(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	f := &fakeRedis{data: make(map[string]string)}
+	go f.serve(t, l)
+
+	ctx := context.Background()
+	c := NewRedis(l.Addr().String(), WithRedisAuth("", "secret"), WithRedisPrefix("svc:"))
+	require.NoError(t, c.Start(ctx))
+	testCache(t, c)
+	require.NoError(t, c.Set(ctx, "ttl", []byte("v"), time.Second))
+	require.NoError(t, c.Stop(ctx))
+
+	f.mu.Lock()
+	assert.Contains(t, f.cmds, "AUTH secret")
+	assert.Contains(t, f.cmds, "SET svc:key value")
+	assert.Contains(t, f.cmds, "SET svc:ttl v PX 1000")
+	f.mu.Unlock()
+
+	bad := NewRedis(l.Addr().String(), WithRedisAuth("", "wrong"))
+	err = bad.Ping(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "WRONGPASS")
+}
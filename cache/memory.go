@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const defaultJanitorInterval = time.Minute
+
+type MemoryOption func(m *memory)
+
+// WithJanitorInterval sets the interval at which the expired entries are evicted
+func WithJanitorInterval(d time.Duration) MemoryOption {
+	return func(m *memory) {
+		m.interval = d
+	}
+}
+
+// Memory is an in-process Cache
+type Memory interface {
+	Cache
+	Name() string
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+	// Len returns the number of entries, including the expired ones not yet evicted
+	Len() int
+}
+
+// NewMemory returns an in-process cache, the expired entries are evicted by a janitor running while the module is started
+func NewMemory(opts ...MemoryOption) Memory {
+	m := &memory{entries: make(map[string]entry), interval: defaultJanitorInterval, now: time.Now}
+	for _, v := range opts {
+		v(m)
+	}
+	return m
+}
+
+type entry struct {
+	value   []byte
+	expires time.Time
+}
+
+func (e entry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+type memory struct {
+	mu       sync.RWMutex
+	entries  map[string]entry
+	interval time.Duration
+	now      func() time.Time
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+func (m *memory) Name() string {
+	return "cache-memory"
+}
+
+func (m *memory) Get(_ context.Context, key string) ([]byte, error) {
+	m.mu.RLock()
+	e, ok := m.entries[key]
+	m.mu.RUnlock()
+	if !ok || e.expired(m.now()) {
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), e.value...), nil
+}
+
+func (m *memory) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	e := entry{value: append([]byte(nil), value...)}
+	if ttl > 0 {
+		e.expires = m.now().Add(ttl)
+	}
+	m.mu.Lock()
+	m.entries[key] = e
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *memory) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	delete(m.entries, key)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *memory) Ping(_ context.Context) error {
+	return nil
+}
+
+func (m *memory) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.entries)
+}
+
+func (m *memory) Start(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cancel != nil {
+		return nil
+	}
+	ctx, m.cancel = context.WithCancel(ctx)
+	m.done = make(chan struct{})
+	go m.janitor(ctx)
+	return nil
+}
+
+func (m *memory) Stop(_ context.Context) error {
+	m.mu.Lock()
+	if m.cancel == nil {
+		m.mu.Unlock()
+		return nil
+	}
+	m.cancel()
+	m.cancel = nil
+	done := m.done
+	m.mu.Unlock()
+	<-done
+	return nil
+}
+
+func (m *memory) janitor(ctx context.Context) {
+	defer close(m.done)
+	t := time.NewTicker(m.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			m.evict()
+		}
+	}
+}
+
+func (m *memory) evict() {
+	now := m.now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k, v := range m.entries {
+		if v.expired(now) {
+			delete(m.entries, k)
+		}
+	}
+}
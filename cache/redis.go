@@ -0,0 +1,253 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRedisPoolSize    = 10
+	defaultRedisDialTimeout = 5 * time.Second
+)
+
+type RedisOption func(o *redisOptions)
+
+// WithRedisAuth authenticates the connections, username may be empty for redis < 6
+func WithRedisAuth(username, password string) RedisOption {
+	return func(o *redisOptions) {
+		o.username = username
+		o.password = password
+	}
+}
+
+// WithRedisDB selects the database
+func WithRedisDB(db int) RedisOption {
+	return func(o *redisOptions) {
+		o.db = db
+	}
+}
+
+// WithRedisPoolSize sets the maximum number of idle connections
+func WithRedisPoolSize(n int) RedisOption {
+	return func(o *redisOptions) {
+		o.poolSize = n
+	}
+}
+
+// WithRedisDialTimeout sets the connection timeout
+func WithRedisDialTimeout(d time.Duration) RedisOption {
+	return func(o *redisOptions) {
+		o.dialTimeout = d
+	}
+}
+
+// WithRedisTLS enables tls
+func WithRedisTLS(c *tls.Config) RedisOption {
+	return func(o *redisOptions) {
+		o.tls = c
+	}
+}
+
+// WithRedisPrefix prefixes all the keys, e.g. with the service name
+func WithRedisPrefix(prefix string) RedisOption {
+	return func(o *redisOptions) {
+		o.prefix = prefix
+	}
+}
+
+type redisOptions struct {
+	username    string
+	password    string
+	db          int
+	poolSize    int
+	dialTimeout time.Duration
+	tls         *tls.Config
+	prefix      string
+}
+
+// Redis is a Cache backed by a redis server
+type Redis interface {
+	Cache
+	Name() string
+	// Start checks the server availability
+	Start(ctx context.Context) error
+	// Stop closes the connections
+	Stop(ctx context.Context) error
+}
+
+// NewRedis returns a redis cache connecting to addr
+func NewRedis(addr string, opts ...RedisOption) Redis {
+	o := redisOptions{poolSize: defaultRedisPoolSize, dialTimeout: defaultRedisDialTimeout}
+	for _, v := range opts {
+		v(&o)
+	}
+	return &redis{addr: addr, o: o, idle: make(chan *redisConn, o.poolSize)}
+}
+
+type redis struct {
+	addr   string
+	o      redisOptions
+	idle   chan *redisConn
+	mu     sync.Mutex
+	closed bool
+}
+
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+func (c *redis) Name() string {
+	return "cache-redis"
+}
+
+func (c *redis) Start(ctx context.Context) error {
+	c.mu.Lock()
+	c.closed = false
+	c.mu.Unlock()
+	return c.Ping(ctx)
+}
+
+func (c *redis) Stop(_ context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	for {
+		select {
+		case v := <-c.idle:
+			v.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+func (c *redis) Get(ctx context.Context, key string) ([]byte, error) {
+	v, err := c.do(ctx, []byte("GET"), c.key(key))
+	if err == errNil {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, errors.New("redis: unexpected GET reply")
+	}
+	return b, nil
+}
+
+func (c *redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	args := [][]byte{[]byte("SET"), c.key(key), value}
+	if ttl > 0 {
+		ms := ttl.Milliseconds()
+		if ms == 0 {
+			ms = 1
+		}
+		args = append(args, []byte("PX"), []byte(strconv.FormatInt(ms, 10)))
+	}
+	_, err := c.do(ctx, args...)
+	return err
+}
+
+func (c *redis) Delete(ctx context.Context, key string) error {
+	_, err := c.do(ctx, []byte("DEL"), c.key(key))
+	return err
+}
+
+func (c *redis) Ping(ctx context.Context) error {
+	_, err := c.do(ctx, []byte("PING"))
+	return err
+}
+
+func (c *redis) key(k string) []byte {
+	return []byte(c.o.prefix + k)
+}
+
+func (c *redis) do(ctx context.Context, args ...[]byte) (interface{}, error) {
+	conn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	v, err := c.roundTrip(ctx, conn, args...)
+	var re respError
+	// error replies and nil values do not break the connection
+	if err != nil && err != errNil && !errors.As(err, &re) {
+		conn.Close()
+		return nil, err
+	}
+	c.put(conn)
+	return v, err
+}
+
+func (c *redis) roundTrip(ctx context.Context, conn *redisConn, args ...[]byte) (interface{}, error) {
+	if d, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(d)
+	} else {
+		conn.SetDeadline(time.Time{})
+	}
+	if err := writeCommand(conn.w, args...); err != nil {
+		return nil, err
+	}
+	return readReply(conn.r)
+}
+
+func (c *redis) get(ctx context.Context) (*redisConn, error) {
+	select {
+	case v := <-c.idle:
+		return v, nil
+	default:
+	}
+	return c.dial(ctx)
+}
+
+func (c *redis) put(conn *redisConn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		conn.Close()
+		return
+	}
+	select {
+	case c.idle <- conn:
+	default:
+		conn.Close()
+	}
+}
+
+func (c *redis) dial(ctx context.Context) (*redisConn, error) {
+	d := &net.Dialer{Timeout: c.o.dialTimeout}
+	nc, err := d.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, err
+	}
+	if c.o.tls != nil {
+		nc = tls.Client(nc, c.o.tls)
+	}
+	conn := &redisConn{Conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+	if c.o.password != "" {
+		args := [][]byte{[]byte("AUTH")}
+		if c.o.username != "" {
+			args = append(args, []byte(c.o.username))
+		}
+		args = append(args, []byte(c.o.password))
+		if _, err := c.roundTrip(ctx, conn, args...); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if c.o.db != 0 {
+		if _, err := c.roundTrip(ctx, conn, []byte("SELECT"), []byte(strconv.Itoa(c.o.db))); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// respError is an error reply returned by the redis server
+type respError string
+
+func (e respError) Error() string {
+	return "redis: " + string(e)
+}
+
+var errNil = errors.New("redis: nil")
+
+// writeCommand encodes the command as a RESP array of bulk strings
+func writeCommand(w *bufio.Writer, args ...[]byte) error {
+	if _, err := fmt.Fprintf(w, "*%d\r\n", len(args)); err != nil {
+		return err
+	}
+	for _, v := range args {
+		if _, err := fmt.Fprintf(w, "$%d\r\n", len(v)); err != nil {
+			return err
+		}
+		if _, err := w.Write(v); err != nil {
+			return err
+		}
+		if _, err := w.WriteString("\r\n"); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// readReply decodes a RESP reply: simple strings and bulk strings are returned as []byte,
+// integers as int64, arrays as []interface{}. Nil replies return errNil and error replies a respError.
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("redis: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, respError(line[1:])
+	case ':':
+		return strconv.ParseInt(string(line[1:]), 10, 64)
+	case '$':
+		n, err := strconv.Atoi(string(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, errNil
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	case '*':
+		n, err := strconv.Atoi(string(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, errNil
+		}
+		out := make([]interface{}, n)
+		for i := range out {
+			v, err := readReply(r)
+			if err != nil && err != errNil {
+				return nil, err
+			}
+			out[i] = v
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply type %q", line[0])
+	}
+}
+
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadSlice('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, errors.New("redis: malformed reply")
+	}
+	return append([]byte(nil), line[:len(line)-2]...), nil
+}
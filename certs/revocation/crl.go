@@ -0,0 +1,107 @@
+package revocation
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NewCRL returns a Checker using the CRL files and the certificates CRL distribution points.
+// The lists are cached until their next update.
+func NewCRL(opts ...Option) Checker {
+	return &crlChecker{opts: newOptions(opts...), lists: make(map[string]*crl)}
+}
+
+type crl struct {
+	list    *pkix.CertificateList
+	revoked map[string]struct{}
+	until   time.Time
+}
+
+type crlChecker struct {
+	opts  options
+	mu    sync.Mutex
+	lists map[string]*crl
+}
+
+func (c *crlChecker) Check(ctx context.Context, cert, issuer *x509.Certificate) error {
+	sources := append(append([]string(nil), c.opts.crlFiles...), cert.CRLDistributionPoints...)
+	checked := false
+	var lastErr error
+	for _, src := range sources {
+		l, err := c.load(ctx, src)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		// the files may hold the lists of other authorities
+		if issuer.CheckCRLSignature(l.list) != nil {
+			continue
+		}
+		checked = true
+		if _, ok := l.revoked[cert.SerialNumber.String()]; ok {
+			return ErrRevoked
+		}
+	}
+	if checked {
+		return nil
+	}
+	if lastErr != nil {
+		return fmt.Errorf("%w: %v", ErrUnknown, lastErr)
+	}
+	return ErrUnknown
+}
+
+func (c *crlChecker) load(ctx context.Context, src string) (*crl, error) {
+	now := time.Now()
+	c.mu.Lock()
+	l, ok := c.lists[src]
+	c.mu.Unlock()
+	if ok && now.Before(l.until) {
+		return l, nil
+	}
+	b, err := c.read(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	list, err := x509.ParseCRL(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", src, err)
+	}
+	l = &crl{list: list, revoked: make(map[string]struct{}), until: list.TBSCertList.NextUpdate}
+	for _, v := range list.TBSCertList.RevokedCertificates {
+		l.revoked[v.SerialNumber.String()] = struct{}{}
+	}
+	if l.until.IsZero() || l.until.Before(now) {
+		l.until = now.Add(c.opts.cacheDuration)
+	}
+	c.mu.Lock()
+	c.lists[src] = l
+	c.mu.Unlock()
+	return l, nil
+}
+
+func (c *crlChecker) read(ctx context.Context, src string) ([]byte, error) {
+	if !strings.HasPrefix(src, "http://") && !strings.HasPrefix(src, "https://") {
+		return ioutil.ReadFile(src)
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, src, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.opts.client.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", src, res.Status)
+	}
+	return ioutil.ReadAll(res.Body)
+}
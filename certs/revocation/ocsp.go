@@ -0,0 +1,305 @@
+package revocation
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	// register the hash used by the OCSP certificate ids
+	_ "crypto/sha1"
+)
+
+// the OCSP structures, see RFC 6960
+
+var (
+	oidSHA1              = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidOCSPBasicResponse = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+)
+
+var signatureAlgorithms = []struct {
+	oid  asn1.ObjectIdentifier
+	algo x509.SignatureAlgorithm
+}{
+	{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 5}, x509.SHA1WithRSA},
+	{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}, x509.SHA256WithRSA},
+	{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 12}, x509.SHA384WithRSA},
+	{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 13}, x509.SHA512WithRSA},
+	{asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 1}, x509.ECDSAWithSHA1},
+	{asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}, x509.ECDSAWithSHA256},
+	{asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 3}, x509.ECDSAWithSHA384},
+	{asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 4}, x509.ECDSAWithSHA512},
+	{asn1.ObjectIdentifier{1, 3, 101, 112}, x509.PureEd25519},
+}
+
+type certID struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	NameHash      []byte
+	IssuerKeyHash []byte
+	SerialNumber  *big.Int
+}
+
+type ocspRequest struct {
+	TBSRequest tbsRequest
+}
+
+type tbsRequest struct {
+	Version     int `asn1:"explicit,tag:0,default:0,optional"`
+	RequestList []request
+}
+
+type request struct {
+	Cert certID
+}
+
+type responseASN1 struct {
+	Status   asn1.Enumerated
+	Response responseBytes `asn1:"explicit,tag:0,optional"`
+}
+
+type responseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type basicResponse struct {
+	TBSResponseData    responseData
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+	Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type responseData struct {
+	Raw            asn1.RawContent
+	Version        int `asn1:"optional,default:0,explicit,tag:0"`
+	RawResponderID asn1.RawValue
+	ProducedAt     time.Time `asn1:"generalized"`
+	Responses      []singleResponse
+}
+
+type singleResponse struct {
+	CertID           certID
+	Good             asn1.Flag        `asn1:"tag:0,optional"`
+	Revoked          revokedInfo      `asn1:"tag:1,optional"`
+	Unknown          asn1.Flag        `asn1:"tag:2,optional"`
+	ThisUpdate       time.Time        `asn1:"generalized"`
+	NextUpdate       time.Time        `asn1:"generalized,explicit,tag:0,optional"`
+	SingleExtensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type revokedInfo struct {
+	RevocationTime time.Time       `asn1:"generalized"`
+	Reason         asn1.Enumerated `asn1:"explicit,tag:0,optional"`
+}
+
+// Status is the parsed status of an OCSP response
+type Status struct {
+	// Revoked is true if the certificate is revoked, Good is true if it is valid, both are false if it is unknown
+	Revoked    bool
+	Good       bool
+	ThisUpdate time.Time
+	NextUpdate time.Time
+	// Raw is the DER encoded response, it can be stapled
+	Raw []byte
+}
+
+func newCertID(cert, issuer *x509.Certificate) (certID, error) {
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(issuer.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return certID{}, err
+	}
+	h := crypto.SHA1.New()
+	h.Write(issuer.RawSubject)
+	nameHash := h.Sum(nil)
+	h.Reset()
+	h.Write(spki.PublicKey.RightAlign())
+	return certID{
+		HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA1, Parameters: asn1.RawValue{Tag: 5}},
+		NameHash:      nameHash,
+		IssuerKeyHash: h.Sum(nil),
+		SerialNumber:  cert.SerialNumber,
+	}, nil
+}
+
+// CreateRequest returns the DER encoded OCSP request of cert
+func CreateRequest(cert, issuer *x509.Certificate) ([]byte, error) {
+	id, err := newCertID(cert, issuer)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(ocspRequest{TBSRequest: tbsRequest{RequestList: []request{{Cert: id}}}})
+}
+
+// ParseResponse parses and verifies the OCSP response of cert, which must be signed by the issuer
+// or by a responder certificate issued by the issuer for OCSP signing
+func ParseResponse(b []byte, cert, issuer *x509.Certificate) (*Status, error) {
+	var res responseASN1
+	if rest, err := asn1.Unmarshal(b, &res); err != nil {
+		return nil, err
+	} else if len(rest) != 0 {
+		return nil, errors.New("revocation: trailing data in OCSP response")
+	}
+	if res.Status != 0 {
+		return nil, fmt.Errorf("revocation: OCSP responder error status %d", res.Status)
+	}
+	if !res.Response.ResponseType.Equal(oidOCSPBasicResponse) {
+		return nil, errors.New("revocation: unsupported OCSP response type")
+	}
+	var basic basicResponse
+	if _, err := asn1.Unmarshal(res.Response.Response, &basic); err != nil {
+		return nil, err
+	}
+	signer := issuer
+	if len(basic.Certificates) != 0 {
+		c, err := x509.ParseCertificate(basic.Certificates[0].FullBytes)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(c.Raw, issuer.Raw) {
+			if err := c.CheckSignatureFrom(issuer); err != nil {
+				return nil, fmt.Errorf("revocation: OCSP responder certificate: %w", err)
+			}
+			if !hasOCSPSigning(c) {
+				return nil, errors.New("revocation: OCSP responder certificate is not authorized")
+			}
+			signer = c
+		}
+	}
+	algo := x509.UnknownSignatureAlgorithm
+	for _, v := range signatureAlgorithms {
+		if v.oid.Equal(basic.SignatureAlgorithm.Algorithm) {
+			algo = v.algo
+		}
+	}
+	if err := signer.CheckSignature(algo, basic.TBSResponseData.Raw, basic.Signature.RightAlign()); err != nil {
+		return nil, fmt.Errorf("revocation: OCSP response signature: %w", err)
+	}
+	id, err := newCertID(cert, issuer)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range basic.TBSResponseData.Responses {
+		if r.CertID.SerialNumber.Cmp(id.SerialNumber) != 0 || !bytes.Equal(r.CertID.NameHash, id.NameHash) ||
+			!bytes.Equal(r.CertID.IssuerKeyHash, id.IssuerKeyHash) {
+			continue
+		}
+		s := &Status{ThisUpdate: r.ThisUpdate, NextUpdate: r.NextUpdate, Raw: b}
+		switch {
+		case bool(r.Good):
+			s.Good = true
+		case !r.Revoked.RevocationTime.IsZero():
+			s.Revoked = true
+		}
+		return s, nil
+	}
+	return nil, errors.New("revocation: certificate not found in OCSP response")
+}
+
+func hasOCSPSigning(c *x509.Certificate) bool {
+	for _, v := range c.ExtKeyUsage {
+		if v == x509.ExtKeyUsageOCSPSigning {
+			return true
+		}
+	}
+	return false
+}
+
+// Query sends the OCSP request of cert to its first OCSP responder
+func Query(ctx context.Context, client *http.Client, cert, issuer *x509.Certificate) (*Status, error) {
+	if len(cert.OCSPServer) == 0 {
+		return nil, fmt.Errorf("%w: no OCSP responder", ErrUnknown)
+	}
+	req, err := CreateRequest(cert, issuer)
+	if err != nil {
+		return nil, err
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, cert.OCSPServer[0], bytes.NewReader(req))
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Content-Type", "application/ocsp-request")
+	r.Header.Set("Accept", "application/ocsp-response")
+	res, err := client.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("revocation: OCSP responder returned %s", res.Status)
+	}
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	return ParseResponse(b, cert, issuer)
+}
+
+// NewOCSP returns a Checker querying the certificates OCSP responders, the responses are cached until their next update
+func NewOCSP(opts ...Option) Checker {
+	return &ocspChecker{opts: newOptions(opts...), cache: make(map[string]cached)}
+}
+
+type cached struct {
+	err   error
+	until time.Time
+}
+
+type ocspChecker struct {
+	opts  options
+	mu    sync.Mutex
+	cache map[string]cached
+}
+
+func (c *ocspChecker) Check(ctx context.Context, cert, issuer *x509.Certificate) error {
+	key := string(issuer.RawSubject) + cert.SerialNumber.String()
+	now := time.Now()
+	c.mu.Lock()
+	v, ok := c.cache[key]
+	c.mu.Unlock()
+	if ok && now.Before(v.until) {
+		return v.err
+	}
+	s, err := Query(ctx, c.opts.client, cert, issuer)
+	if err != nil {
+		return err
+	}
+	switch {
+	case s.Revoked:
+		err = ErrRevoked
+	case !s.Good:
+		err = ErrUnknown
+	}
+	until := s.NextUpdate
+	if until.IsZero() {
+		until = now.Add(c.opts.cacheDuration)
+	}
+	c.mu.Lock()
+	prune(c.cache, now)
+	c.cache[key] = cached{err: err, until: until}
+	c.mu.Unlock()
+	return err
+}
+
+// prune removes the expired entries when the cache grows
+func prune(m map[string]cached, now time.Time) {
+	if len(m) < maxCacheEntries {
+		return
+	}
+	for k, v := range m {
+		if now.After(v.until) {
+			delete(m, k)
+		}
+	}
+}
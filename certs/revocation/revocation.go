@@ -0,0 +1,114 @@
+// Package revocation checks the certificates revocation status using OCSP and CRLs,
+// and staples the OCSP responses of the server certificates.
+package revocation
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var (
+	// ErrRevoked is returned when the certificate is revoked
+	ErrRevoked = errors.New("revocation: certificate revoked")
+	// ErrUnknown is returned when the revocation status could not be determined
+	ErrUnknown = errors.New("revocation: unknown certificate status")
+)
+
+const (
+	defaultTimeout  = 5 * time.Second
+	maxCacheEntries = 1024
+)
+
+// Checker checks the revocation status of a certificate issued by issuer.
+// It returns ErrRevoked if the certificate is revoked, ErrUnknown or another error if the status is not available.
+type Checker interface {
+	Check(ctx context.Context, cert, issuer *x509.Certificate) error
+}
+
+// CheckerFunc is a Checker function
+type CheckerFunc func(ctx context.Context, cert, issuer *x509.Certificate) error
+
+func (fn CheckerFunc) Check(ctx context.Context, cert, issuer *x509.Certificate) error {
+	return fn(ctx, cert, issuer)
+}
+
+// First returns a Checker using the first checker giving a definitive answer, e.g. OCSP then CRL
+func First(checkers ...Checker) Checker {
+	return CheckerFunc(func(ctx context.Context, cert, issuer *x509.Certificate) error {
+		err := ErrUnknown
+		for _, c := range checkers {
+			err = c.Check(ctx, cert, issuer)
+			if err == nil || errors.Is(err, ErrRevoked) {
+				return err
+			}
+		}
+		return err
+	})
+}
+
+// VerifyPeerCertificate returns a tls.Config VerifyPeerCertificate function checking the revocation
+// status of the verified peer certificates, e.g. the client certificates in mTLS mode.
+// When softFail is true, the certificates which status is not available are accepted.
+func VerifyPeerCertificate(c Checker, softFail bool) func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	return func(_ [][]byte, verifiedChains [][]*x509.Certificate) error {
+		ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+		defer cancel()
+		for _, chain := range verifiedChains {
+			// the root is trusted, check the leaf and the intermediates
+			for i := 0; i+1 < len(chain); i++ {
+				err := c.Check(ctx, chain[i], chain[i+1])
+				if err == nil {
+					continue
+				}
+				if errors.Is(err, ErrRevoked) {
+					return fmt.Errorf("%s: %w", chain[i].Subject, err)
+				}
+				if !softFail {
+					return fmt.Errorf("%s: %w", chain[i].Subject, err)
+				}
+			}
+		}
+		return nil
+	}
+}
+
+type Option func(o *options)
+
+// WithHTTPClient sets the client used to query the OCSP responders and download the CRLs
+func WithHTTPClient(c *http.Client) Option {
+	return func(o *options) {
+		o.client = c
+	}
+}
+
+// WithCacheDuration sets how long a status is cached when the response has no next update time, defaults to 1 hour
+func WithCacheDuration(d time.Duration) Option {
+	return func(o *options) {
+		o.cacheDuration = d
+	}
+}
+
+// WithCRLFiles adds local CRL files, PEM or DER encoded, checked in addition to the certificates distribution points
+func WithCRLFiles(paths ...string) Option {
+	return func(o *options) {
+		o.crlFiles = append(o.crlFiles, paths...)
+	}
+}
+
+type options struct {
+	client        *http.Client
+	cacheDuration time.Duration
+	crlFiles      []string
+}
+
+func newOptions(opts ...Option) options {
+	o := options{client: &http.Client{Timeout: defaultTimeout}, cacheDuration: time.Hour}
+	for _, v := range opts {
+		v(&o)
+	}
+	return o
+}
@@ -0,0 +1,165 @@
+package revocation
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type ca struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newCA(t *testing.T) *ca {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &ca{cert: cert, key: key}
+}
+
+func (c *ca) issue(t *testing.T, serial int64, ocsp, crl string) (*x509.Certificate, tls.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "leaf"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	if ocsp != "" {
+		tmpl.OCSPServer = []string{ocsp}
+	}
+	if crl != "" {
+		tmpl.CRLDistributionPoints = []string{crl}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, c.cert, &key.PublicKey, c.key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, tls.Certificate{Certificate: [][]byte{der, c.cert.Raw}, PrivateKey: key}
+}
+
+// respond creates the OCSP response of the requested certificate, revoked if its serial is in revoked
+func (c *ca) respond(t *testing.T, req []byte, revoked map[int64]bool) []byte {
+	var r ocspRequest
+	_, err := asn1.Unmarshal(req, &r)
+	require.NoError(t, err)
+	id := r.TBSRequest.RequestList[0].Cert
+	single := singleResponse{CertID: id, ThisUpdate: time.Now().UTC().Truncate(time.Second), NextUpdate: time.Now().Add(time.Hour).UTC().Truncate(time.Second)}
+	if revoked[id.SerialNumber.Int64()] {
+		single.Revoked = revokedInfo{RevocationTime: time.Now().Add(-time.Minute).UTC().Truncate(time.Second)}
+	} else {
+		single.Good = asn1.Flag(true)
+	}
+	keyHash, err := asn1.Marshal(id.IssuerKeyHash)
+	require.NoError(t, err)
+	tbs, err := asn1.Marshal(responseData{
+		RawResponderID: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 2, IsCompound: true, Bytes: keyHash},
+		ProducedAt:     time.Now().UTC().Truncate(time.Second),
+		Responses:      []singleResponse{single},
+	})
+	require.NoError(t, err)
+	h := sha256.Sum256(tbs)
+	sig, err := c.key.Sign(rand.Reader, h[:], crypto.SHA256)
+	require.NoError(t, err)
+	basic, err := asn1.Marshal(basicResponse{
+		TBSResponseData:    responseData{Raw: tbs},
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}},
+		Signature:          asn1.BitString{Bytes: sig, BitLength: 8 * len(sig)},
+	})
+	require.NoError(t, err)
+	b, err := asn1.Marshal(responseASN1{Response: responseBytes{ResponseType: oidOCSPBasicResponse, Response: basic}})
+	require.NoError(t, err)
+	return b
+}
+
+func TestOCSP(t *testing.T) {
+	c := newCA(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Write(c.respond(t, b, map[int64]bool{3: true}))
+	}))
+	defer srv.Close()
+
+	good, goodTLS := c.issue(t, 2, srv.URL, "")
+	revoked, _ := c.issue(t, 3, srv.URL, "")
+	none, _ := c.issue(t, 4, "", "")
+
+	checker := NewOCSP()
+	ctx := context.Background()
+	assert.NoError(t, checker.Check(ctx, good, c.cert))
+	assert.True(t, errors.Is(checker.Check(ctx, revoked, c.cert), ErrRevoked))
+	assert.True(t, errors.Is(checker.Check(ctx, none, c.cert), ErrUnknown))
+
+	verify := VerifyPeerCertificate(checker, true)
+	assert.NoError(t, verify(nil, [][]*x509.Certificate{{good, c.cert}}))
+	assert.NoError(t, verify(nil, [][]*x509.Certificate{{none, c.cert}}))
+	assert.Error(t, verify(nil, [][]*x509.Certificate{{revoked, c.cert}}))
+	assert.Error(t, VerifyPeerCertificate(checker, false)(nil, [][]*x509.Certificate{{none, c.cert}}))
+
+	s, err := NewStapler(goodTLS)
+	require.NoError(t, err)
+	require.NoError(t, s.Refresh(ctx))
+	stapled, err := s.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.NotEmpty(t, stapled.OCSPStaple)
+}
+
+func TestCRL(t *testing.T) {
+	c := newCA(t)
+	good, _ := c.issue(t, 2, "", "")
+	revoked, _ := c.issue(t, 3, "", "")
+
+	b, err := c.cert.CreateCRL(rand.Reader, c.key, []pkix.RevokedCertificate{{SerialNumber: big.NewInt(3), RevocationTime: time.Now()}}, time.Now(), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	dir, err := ioutil.TempDir("", "crl")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "ca.crl")
+	require.NoError(t, ioutil.WriteFile(path, b, 0600))
+
+	checker := NewCRL(WithCRLFiles(path))
+	ctx := context.Background()
+	assert.NoError(t, checker.Check(ctx, good, c.cert))
+	assert.True(t, errors.Is(checker.Check(ctx, revoked, c.cert), ErrRevoked))
+
+	// not issued by the list authority
+	other := newCA(t)
+	foreign, _ := other.issue(t, 3, "", "")
+	assert.True(t, errors.Is(checker.Check(ctx, foreign, other.cert), ErrUnknown))
+
+	assert.NoError(t, First(NewOCSP(), checker).Check(ctx, good, c.cert))
+}
@@ -0,0 +1,97 @@
+package revocation
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"sync"
+	"time"
+)
+
+const stapleRetryInterval = 5 * time.Minute
+
+// Stapler keeps a fresh OCSP response stapled to a server certificate
+type Stapler struct {
+	opts   options
+	cert   tls.Certificate
+	leaf   *x509.Certificate
+	issuer *x509.Certificate
+
+	mu     sync.RWMutex
+	staple *tls.Certificate
+	next   time.Time
+}
+
+// NewStapler returns a Stapler for cert, which chain must contain the issuer certificate
+func NewStapler(cert tls.Certificate, opts ...Option) (*Stapler, error) {
+	if len(cert.Certificate) < 2 {
+		return nil, errors.New("revocation: the certificate chain does not contain the issuer")
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	issuer, err := x509.ParseCertificate(cert.Certificate[1])
+	if err != nil {
+		return nil, err
+	}
+	c := cert
+	return &Stapler{opts: newOptions(opts...), cert: cert, leaf: leaf, issuer: issuer, staple: &c}, nil
+}
+
+// Refresh fetches a new OCSP response. A revoked certificate is not stapled.
+func (s *Stapler) Refresh(ctx context.Context) error {
+	st, err := Query(ctx, s.opts.client, s.leaf, s.issuer)
+	if err != nil {
+		return err
+	}
+	if st.Revoked {
+		return ErrRevoked
+	}
+	if !st.Good {
+		return ErrUnknown
+	}
+	c := s.cert
+	c.OCSPStaple = st.Raw
+	next := st.NextUpdate
+	if next.IsZero() {
+		next = time.Now().Add(s.opts.cacheDuration)
+	}
+	s.mu.Lock()
+	s.staple = &c
+	s.next = next
+	s.mu.Unlock()
+	return nil
+}
+
+// GetCertificate returns the certificate with its stapled response, it can be used as tls.Config.GetCertificate
+func (s *Stapler) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.staple, nil
+}
+
+// Run refreshes the response halfway to its next update until the context is done, onError is called on failures
+func (s *Stapler) Run(ctx context.Context, onError func(err error)) {
+	for {
+		wait := stapleRetryInterval
+		if err := s.Refresh(ctx); err != nil {
+			if onError != nil {
+				onError(err)
+			}
+		} else {
+			s.mu.RLock()
+			wait = time.Until(s.next) / 2
+			s.mu.RUnlock()
+			if wait < time.Minute {
+				wait = time.Minute
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
@@ -14,10 +14,20 @@ import (
 	"go.linka.cloud/grpc/registry/noop"
 )
 
+// DefaultLoadBalancingPolicy is the load balancing policy of the clients resolving their target through the registry
+const DefaultLoadBalancingPolicy = "round_robin"
+
 type Client interface {
 	grpc.ClientConnInterface
 }
 
+// Dial returns a client of the named service, resolved through the configured registry, e.g.
+//
+//	c, err := client.Dial("my-service", client.WithService(svc.Options()))
+func Dial(name string, opts ...Option) (Client, error) {
+	return New(append([]Option{WithName(name)}, opts...)...)
+}
+
 func New(opts ...Option) (Client, error) {
 	c := &client{opts: &options{}}
 	for _, o := range opts {
@@ -50,6 +60,13 @@ func New(opts ...Option) (Client, error) {
 	} else {
 		c.addr = c.opts.addr
 	}
+	policy := c.opts.lbPolicy
+	if policy == "" && c.opts.addr == "" {
+		policy = DefaultLoadBalancingPolicy
+	}
+	if policy != "" {
+		c.opts.dialOptions = append(c.opts.dialOptions, grpc.WithDefaultServiceConfig(fmt.Sprintf(`{"loadBalancingConfig":[{%q:{}}]}`, policy)))
+	}
 	if c.opts.version != "" && c.opts.addr == "" {
 		c.addr = c.addr + ":" + strings.TrimSpace(c.opts.version)
 	}
@@ -19,6 +19,7 @@ type Options interface {
 	DialOptions() []grpc.DialOption
 	UnaryInterceptors() []grpc.UnaryClientInterceptor
 	StreamInterceptors() []grpc.StreamClientInterceptor
+	LoadBalancingPolicy() string
 }
 
 type Option func(*options)
@@ -86,6 +87,39 @@ func WithStreamInterceptors(i ...grpc.StreamClientInterceptor) Option {
 	}
 }
 
+// WithLoadBalancingPolicy sets the load balancing policy used across the resolved nodes, e.g. round_robin
+// or pick_first. It defaults to round_robin when the target is resolved through the registry.
+func WithLoadBalancingPolicy(policy string) Option {
+	return func(o *options) {
+		o.lbPolicy = policy
+	}
+}
+
+// ServiceOptions are the service options shared with the client, it is implemented by service.Options
+type ServiceOptions interface {
+	Registry() registry.Registry
+	Secure() bool
+	TLSConfig() *tls.Config
+	SelfSigned() bool
+	ClientInterceptors() []grpc.UnaryClientInterceptor
+	StreamClientInterceptors() []grpc.StreamClientInterceptor
+}
+
+// WithService configures the client like the service the options belong to: it uses the same registry,
+// the service certificates and certificate authorities and the service client interceptors, e.g. the
+// metadata ones. It should be used before the other options, which may then override the service ones.
+func WithService(so ServiceOptions) Option {
+	return func(o *options) {
+		if r := so.Registry(); r != nil {
+			o.registry = r
+		}
+		o.secure = so.Secure()
+		o.tlsConfig = clientTLSConfig(so.TLSConfig(), so.SelfSigned())
+		o.unaryInterceptors = append(o.unaryInterceptors, so.ClientInterceptors()...)
+		o.streamInterceptors = append(o.streamInterceptors, so.StreamClientInterceptors()...)
+	}
+}
+
 type options struct {
 	registry    registry.Registry
 	name        string
@@ -94,6 +128,7 @@ type options struct {
 	tlsConfig   *tls.Config
 	secure      bool
 	dialOptions []grpc.DialOption
+	lbPolicy    string
 
 	unaryInterceptors  []grpc.UnaryClientInterceptor
 	streamInterceptors []grpc.StreamClientInterceptor
@@ -134,3 +169,27 @@ func (o *options) UnaryInterceptors() []grpc.UnaryClientInterceptor {
 func (o *options) StreamInterceptors() []grpc.StreamClientInterceptor {
 	return o.streamInterceptors
 }
+
+func (o *options) LoadBalancingPolicy() string {
+	return o.lbPolicy
+}
+
+// clientTLSConfig returns the client configuration of the server TLS configuration: the server certificates
+// are presented to the peers requiring mutual TLS and the client certificate authorities are trusted
+// when no root certificate authority is configured, the system ones otherwise.
+// The server certificates are only not verified if the service generated its own self signed certificate.
+func clientTLSConfig(c *tls.Config, selfSigned bool) *tls.Config {
+	if c == nil {
+		return nil
+	}
+	conf := &tls.Config{
+		Certificates:       c.Certificates,
+		RootCAs:            c.RootCAs,
+		MinVersion:         c.MinVersion,
+		InsecureSkipVerify: selfSigned,
+	}
+	if conf.RootCAs == nil {
+		conf.RootCAs = c.ClientCAs
+	}
+	return conf
+}
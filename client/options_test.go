@@ -0,0 +1,75 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+
+	"go.linka.cloud/grpc/registry"
+	"go.linka.cloud/grpc/registry/noop"
+)
+
+type serviceOptions struct {
+	reg        registry.Registry
+	conf       *tls.Config
+	selfSigned bool
+}
+
+func (o serviceOptions) Registry() registry.Registry {
+	return o.reg
+}
+
+func (o serviceOptions) Secure() bool {
+	return true
+}
+
+func (o serviceOptions) TLSConfig() *tls.Config {
+	return o.conf
+}
+
+func (o serviceOptions) SelfSigned() bool {
+	return o.selfSigned
+}
+
+func (o serviceOptions) ClientInterceptors() []grpc.UnaryClientInterceptor {
+	return []grpc.UnaryClientInterceptor{nil}
+}
+
+func (o serviceOptions) StreamClientInterceptors() []grpc.StreamClientInterceptor {
+	return []grpc.StreamClientInterceptor{nil}
+}
+
+func TestWithService(t *testing.T) {
+	pool := x509.NewCertPool()
+	reg := noop.New()
+	o := &options{}
+	WithService(serviceOptions{reg: reg, conf: &tls.Config{ClientCAs: pool, MinVersion: tls.VersionTLS12}})(o)
+	assert.Equal(t, reg, o.registry)
+	assert.True(t, o.secure)
+	assert.Len(t, o.unaryInterceptors, 1)
+	assert.Len(t, o.streamInterceptors, 1)
+	if assert.NotNil(t, o.tlsConfig) {
+		assert.Equal(t, pool, o.tlsConfig.RootCAs)
+		assert.False(t, o.tlsConfig.InsecureSkipVerify)
+		assert.Equal(t, uint16(tls.VersionTLS12), o.tlsConfig.MinVersion)
+	}
+
+	// e.g. an acme or public certificate, verified with the system certificate authorities
+	o = &options{}
+	WithService(serviceOptions{conf: &tls.Config{}})(o)
+	assert.Nil(t, o.registry)
+	assert.Nil(t, o.tlsConfig.RootCAs)
+	assert.False(t, o.tlsConfig.InsecureSkipVerify)
+
+	// self signed service certificate
+	o = &options{}
+	WithService(serviceOptions{conf: &tls.Config{}, selfSigned: true})(o)
+	assert.True(t, o.tlsConfig.InsecureSkipVerify)
+
+	o = &options{}
+	WithService(serviceOptions{})(o)
+	assert.Nil(t, o.tlsConfig)
+}
@@ -0,0 +1,53 @@
+// grpc is the command line tool of the framework
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"go.linka.cloud/grpc/compat"
+)
+
+func main() {
+	root := &cobra.Command{
+		Use:          "grpc",
+		Short:        "The go.linka.cloud/grpc framework tool",
+		SilenceUsage: true,
+	}
+	root.AddCommand(checkCmd())
+	if err := root.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+func checkCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "check BASELINE CURRENT",
+		Short: "Check that the CURRENT descriptors are backward compatible with the BASELINE ones",
+		Long: `Check that the CURRENT FileDescriptorSet is backward compatible with the BASELINE one, e.g. in CI:
+
+    protoc --include_imports --descriptor_set_out=current.binpb greeter.proto
+    grpc check baseline.binpb current.binpb
+
+The descriptor sets are encoded in binary, or as protojson when the file has the .json extension.
+It exits with a non-zero status when breaking changes are found.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			baseline, err := compat.Load(args[0])
+			if err != nil {
+				return err
+			}
+			current, err := compat.Load(args[1])
+			if err != nil {
+				return err
+			}
+			if err := compat.Check(baseline, current); err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), "no breaking changes")
+			return nil
+		},
+	}
+}
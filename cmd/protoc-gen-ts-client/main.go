@@ -0,0 +1,104 @@
+// protoc-gen-ts-client generates the TypeScript clients of the services built with the framework.
+// The clients use the grpc-web transport of the Connect runtime, configured with the service grpc-web
+// prefix and the framework authentication headers: the bearer token and the api key.
+// It relies on the protoc-gen-es messages and the protoc-gen-connect-es services definitions:
+//
+//	protoc --es_out=target=ts:ts --connect-es_out=target=ts:ts --ts-client_out=grpc_web_prefix=/grpc:ts greeter.proto
+//
+// Parameters:
+//
+//	grpc_web_prefix: the prefix set with service.WithGRPCWebPrefix
+//	import_extension: the extension of the generated imports, e.g. ".js" for ECMAScript modules, none by default
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+const (
+	authorizationHeader = "authorization"
+	// apiKeyHeader is auth.APIKeyHeader
+	apiKeyHeader = "x-api-key"
+)
+
+func main() {
+	var flags flag.FlagSet
+	prefix := flags.String("grpc_web_prefix", "", "the service grpc-web prefix")
+	ext := flags.String("import_extension", "", "the extension of the generated imports")
+	protogen.Options{ParamFunc: flags.Set}.Run(func(gen *protogen.Plugin) error {
+		for _, f := range gen.Files {
+			if f.Generate && len(f.Services) != 0 {
+				generate(gen, f, *prefix, *ext)
+			}
+		}
+		return nil
+	})
+}
+
+func generate(gen *protogen.Plugin, f *protogen.File, prefix, ext string) {
+	g := gen.NewGeneratedFile(f.GeneratedFilenamePrefix+"_client.ts", "")
+	g.P("// Code generated by protoc-gen-ts-client. DO NOT EDIT.")
+	g.P("// source: ", f.Desc.Path())
+	g.P()
+	g.P("/* eslint-disable */")
+	g.P("// @ts-nocheck")
+	g.P()
+	g.P(`import { createPromiseClient } from "@connectrpc/connect";`)
+	g.P(`import type { Interceptor, PromiseClient, Transport } from "@connectrpc/connect";`)
+	g.P(`import { createGrpcWebTransport } from "@connectrpc/connect-web";`)
+	names := make([]string, 0, len(f.Services))
+	for _, s := range f.Services {
+		names = append(names, s.GoName)
+	}
+	g.P(fmt.Sprintf(`import { %s } from "./%s_connect%s";`, strings.Join(names, ", "), path.Base(f.GeneratedFilenamePrefix), ext))
+	g.P()
+	g.P("/** grpcWebPrefix is the prefix the service serves grpc-web on */")
+	g.P(fmt.Sprintf("export const grpcWebPrefix = %q;", prefix))
+	g.P()
+	g.P("export interface ClientOptions {")
+	g.P("  /** baseUrl is the service url, it defaults to the page origin */")
+	g.P("  baseUrl?: string;")
+	g.P("  /** token returns the bearer token sent in the authorization header */")
+	g.P("  token?: () => string | undefined | Promise<string | undefined>;")
+	g.P("  /** apiKey returns the api key sent in the " + apiKeyHeader + " header */")
+	g.P("  apiKey?: () => string | undefined | Promise<string | undefined>;")
+	g.P("  /** interceptors run after the authentication one */")
+	g.P("  interceptors?: Interceptor[];")
+	g.P("}")
+	g.P()
+	g.P("/** createTransport returns the grpc-web transport of the service, sending the authentication headers */")
+	g.P("export function createTransport(opts: ClientOptions = {}): Transport {")
+	g.P("  const auth: Interceptor = (next) => async (req) => {")
+	g.P("    const token = opts.token ? await opts.token() : undefined;")
+	g.P("    if (token) {")
+	g.P(fmt.Sprintf("      req.header.set(%q, `Bearer ${token}`);", authorizationHeader))
+	g.P("    }")
+	g.P("    const key = opts.apiKey ? await opts.apiKey() : undefined;")
+	g.P("    if (key) {")
+	g.P(fmt.Sprintf("      req.header.set(%q, key);", apiKeyHeader))
+	g.P("    }")
+	g.P("    return next(req);")
+	g.P("  };")
+	g.P("  const baseUrl = opts.baseUrl ?? globalThis.location?.origin ?? \"\";")
+	g.P("  return createGrpcWebTransport({")
+	g.P("    baseUrl: baseUrl.replace(/\\/$/, \"\") + grpcWebPrefix,")
+	g.P("    interceptors: [auth, ...(opts.interceptors ?? [])],")
+	g.P("  });")
+	g.P("}")
+	for _, s := range f.Services {
+		g.P()
+		g.P(fmt.Sprintf("/** create%sClient returns a %s client, using transport or a transport created from the options */", s.GoName, s.Desc.FullName()))
+		g.P(fmt.Sprintf("export function create%sClient(transport: Transport | ClientOptions = {}): PromiseClient<typeof %s> {", s.GoName, s.GoName))
+		g.P(fmt.Sprintf("  return createPromiseClient(%s, isTransport(transport) ? transport : createTransport(transport));", s.GoName))
+		g.P("}")
+	}
+	g.P()
+	g.P("function isTransport(v: Transport | ClientOptions): v is Transport {")
+	g.P("  return typeof (v as Transport).unary === \"function\";")
+	g.P("}")
+}
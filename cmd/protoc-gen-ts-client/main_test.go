@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func TestGenerate(t *testing.T) {
+	req := &pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{"greeter/greeter.proto"},
+		Parameter:      proto.String("paths=source_relative"),
+		ProtoFile: []*descriptorpb.FileDescriptorProto{{
+			Name:    proto.String("greeter/greeter.proto"),
+			Package: proto.String("greeter"),
+			Syntax:  proto.String("proto3"),
+			Options: &descriptorpb.FileOptions{GoPackage: proto.String("example.org/greeter")},
+			MessageType: []*descriptorpb.DescriptorProto{
+				{Name: proto.String("HelloRequest")},
+				{Name: proto.String("HelloReply")},
+			},
+			Service: []*descriptorpb.ServiceDescriptorProto{{
+				Name: proto.String("Greeter"),
+				Method: []*descriptorpb.MethodDescriptorProto{{
+					Name:       proto.String("SayHello"),
+					InputType:  proto.String(".greeter.HelloRequest"),
+					OutputType: proto.String(".greeter.HelloReply"),
+				}},
+			}},
+		}},
+	}
+	gen, err := protogen.Options{}.New(req)
+	require.NoError(t, err)
+	generate(gen, gen.Files[0], "/grpc", ".js")
+	res := gen.Response()
+	require.Nil(t, res.Error)
+	require.Len(t, res.File, 1)
+	assert.Equal(t, "greeter/greeter_client.ts", res.File[0].GetName())
+	out := res.File[0].GetContent()
+	assert.Contains(t, out, `import { Greeter } from "./greeter_connect.js";`)
+	assert.Contains(t, out, `export const grpcWebPrefix = "/grpc";`)
+	assert.Contains(t, out, "export function createGreeterClient(")
+	assert.Contains(t, out, `req.header.set("x-api-key", key);`)
+}
@@ -0,0 +1,54 @@
+package codec
+
+import (
+	"fmt"
+
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// JSONName is the name registered for the proto JSON codec: the clients select it with the
+// application/grpc+json content-type, e.g. with grpc.CallContentSubtype(JSONName)
+const JSONName = "json"
+
+// the grpc codecs registry is global and not safe for concurrent use, so the codec is registered
+// once when the package is imported, e.g. import _ "go.linka.cloud/grpc/codec"
+func init() {
+	encoding.RegisterCodec(NewJSON())
+}
+
+// JSON is a grpc codec using the protobuf JSON mapping
+type JSON struct {
+	MarshalOptions   protojson.MarshalOptions
+	UnmarshalOptions protojson.UnmarshalOptions
+}
+
+// NewJSON returns a JSON codec with the gateway default semantics:
+// the unpopulated fields are emitted and the unknown fields are discarded
+func NewJSON() JSON {
+	return JSON{
+		MarshalOptions:   protojson.MarshalOptions{EmitUnpopulated: true},
+		UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+	}
+}
+
+func (c JSON) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("failed to marshal, message is %T, want proto.Message", v)
+	}
+	return c.MarshalOptions.Marshal(m)
+}
+
+func (c JSON) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("failed to unmarshal, message is %T, want proto.Message", v)
+	}
+	return c.UnmarshalOptions.Unmarshal(data, m)
+}
+
+func (JSON) Name() string {
+	return JSONName
+}
@@ -0,0 +1,29 @@
+package codec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestJSON(t *testing.T) {
+	c := NewJSON()
+	b, err := c.Marshal(wrapperspb.String("value"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `"value"`, string(b))
+
+	var s structpb.Struct
+	require.NoError(t, c.Unmarshal([]byte(`{"key": 1}`), &s))
+	assert.Equal(t, 1.0, s.Fields["key"].GetNumberValue())
+
+	_, err = c.Marshal("not a message")
+	assert.Error(t, err)
+	assert.Equal(t, "json", c.Name())
+
+	// the codec is registered by the package import
+	assert.IsType(t, JSON{}, encoding.GetCodec(JSONName))
+}
@@ -0,0 +1,312 @@
+// Package compat detects the backward incompatible changes of the services contracts, as buf breaking does
+// with its WIRE_JSON rules: the removed services, methods, messages, fields and enum values, and the type,
+// cardinality, name or streaming changes, which break the existing clients on the wire or over the gateway.
+package compat
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// The kinds of breaking changes
+const (
+	ServiceRemoved          = "SERVICE_REMOVED"
+	MethodRemoved           = "METHOD_REMOVED"
+	MethodTypeChanged       = "METHOD_TYPE_CHANGED"
+	MethodStreamingChanged  = "METHOD_STREAMING_CHANGED"
+	MessageRemoved          = "MESSAGE_REMOVED"
+	FieldRemoved            = "FIELD_REMOVED"
+	FieldTypeChanged        = "FIELD_TYPE_CHANGED"
+	FieldCardinalityChanged = "FIELD_CARDINALITY_CHANGED"
+	FieldNameChanged        = "FIELD_NAME_CHANGED"
+	FieldOneofChanged       = "FIELD_ONEOF_CHANGED"
+	EnumRemoved             = "ENUM_REMOVED"
+	EnumValueRemoved        = "ENUM_VALUE_REMOVED"
+	EnumValueNameChanged    = "ENUM_VALUE_NAME_CHANGED"
+)
+
+// Change is a backward incompatible change
+type Change struct {
+	Kind string
+	// Element is the full name of the changed element in the baseline, e.g. "greeter.HelloRequest.name"
+	Element string
+	Message string
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %s: %s", c.Kind, c.Element, c.Message)
+}
+
+// Error is returned when breaking changes are found
+type Error struct {
+	Changes []Change
+}
+
+func (e *Error) Error() string {
+	s := make([]string, len(e.Changes))
+	for i, v := range e.Changes {
+		s[i] = "\t" + v.String()
+	}
+	return fmt.Sprintf("compat: %d breaking changes:\n%s", len(e.Changes), strings.Join(s, "\n"))
+}
+
+// Check returns the breaking changes of current against baseline as an *Error, or nil if they are compatible
+func Check(baseline, current *descriptorpb.FileDescriptorSet) error {
+	b, err := protodesc.NewFiles(baseline)
+	if err != nil {
+		return fmt.Errorf("compat: baseline: %w", err)
+	}
+	c, err := protodesc.NewFiles(current)
+	if err != nil {
+		return fmt.Errorf("compat: current: %w", err)
+	}
+	if changes := Compare(b, c); len(changes) != 0 {
+		return &Error{Changes: changes}
+	}
+	return nil
+}
+
+// Compare returns the breaking changes of the current files against the baseline ones, sorted by element
+func Compare(baseline, current *protoregistry.Files) []Change {
+	c := &comparer{current: current}
+	baseline.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		c.file(fd)
+		return true
+	})
+	sort.SliceStable(c.changes, func(i, j int) bool {
+		return c.changes[i].Element < c.changes[j].Element
+	})
+	return c.changes
+}
+
+// Registered returns the descriptors of the services, e.g. "greeter.Greeter", and of their dependencies
+// from the registered files
+func Registered(services ...string) (*descriptorpb.FileDescriptorSet, error) {
+	set := &descriptorpb.FileDescriptorSet{}
+	seen := make(map[string]bool)
+	var add func(fd protoreflect.FileDescriptor)
+	add = func(fd protoreflect.FileDescriptor) {
+		if seen[fd.Path()] {
+			return
+		}
+		seen[fd.Path()] = true
+		imports := fd.Imports()
+		for i := 0; i < imports.Len(); i++ {
+			add(imports.Get(i).FileDescriptor)
+		}
+		set.File = append(set.File, protodesc.ToFileDescriptorProto(fd))
+	}
+	for _, v := range services {
+		d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(v))
+		if err != nil {
+			return nil, fmt.Errorf("compat: %s: %w", v, err)
+		}
+		if _, ok := d.(protoreflect.ServiceDescriptor); !ok {
+			return nil, fmt.Errorf("compat: %s is not a service", v)
+		}
+		add(d.ParentFile())
+	}
+	return set, nil
+}
+
+// Load reads a FileDescriptorSet, e.g. produced by protoc --descriptor_set_out or buf build,
+// encoded as protojson if the file has the .json extension, in binary otherwise
+func Load(path string) (*descriptorpb.FileDescriptorSet, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	set := &descriptorpb.FileDescriptorSet{}
+	if filepath.Ext(path) == ".json" {
+		err = protojson.Unmarshal(b, set)
+	} else {
+		err = proto.Unmarshal(b, set)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("compat: %s: %w", path, err)
+	}
+	return set, nil
+}
+
+// Save writes the FileDescriptorSet to path, see Load
+func Save(path string, set *descriptorpb.FileDescriptorSet) error {
+	var (
+		b   []byte
+		err error
+	)
+	if filepath.Ext(path) == ".json" {
+		b, err = protojson.MarshalOptions{Multiline: true}.Marshal(set)
+	} else {
+		b, err = proto.MarshalOptions{Deterministic: true}.Marshal(set)
+	}
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+type comparer struct {
+	current *protoregistry.Files
+	changes []Change
+}
+
+func (c *comparer) add(kind string, d protoreflect.Descriptor, format string, args ...interface{}) {
+	c.changes = append(c.changes, Change{Kind: kind, Element: string(d.FullName()), Message: fmt.Sprintf(format, args...)})
+}
+
+func (c *comparer) find(d protoreflect.Descriptor) protoreflect.Descriptor {
+	v, err := c.current.FindDescriptorByName(d.FullName())
+	if err != nil {
+		return nil
+	}
+	return v
+}
+
+func (c *comparer) file(fd protoreflect.FileDescriptor) {
+	services := fd.Services()
+	for i := 0; i < services.Len(); i++ {
+		c.service(services.Get(i))
+	}
+	c.messages(fd.Messages())
+	c.enums(fd.Enums())
+}
+
+func (c *comparer) messages(messages protoreflect.MessageDescriptors) {
+	for i := 0; i < messages.Len(); i++ {
+		m := messages.Get(i)
+		if m.IsMapEntry() {
+			continue
+		}
+		c.message(m)
+	}
+}
+
+func (c *comparer) enums(enums protoreflect.EnumDescriptors) {
+	for i := 0; i < enums.Len(); i++ {
+		c.enum(enums.Get(i))
+	}
+}
+
+func (c *comparer) service(sd protoreflect.ServiceDescriptor) {
+	cur, ok := c.find(sd).(protoreflect.ServiceDescriptor)
+	if !ok {
+		c.add(ServiceRemoved, sd, "service was removed")
+		return
+	}
+	methods := sd.Methods()
+	for i := 0; i < methods.Len(); i++ {
+		m := methods.Get(i)
+		cm := cur.Methods().ByName(m.Name())
+		if cm == nil {
+			c.add(MethodRemoved, m, "method was removed")
+			continue
+		}
+		if m.Input().FullName() != cm.Input().FullName() {
+			c.add(MethodTypeChanged, m, "request type changed from %s to %s", m.Input().FullName(), cm.Input().FullName())
+		}
+		if m.Output().FullName() != cm.Output().FullName() {
+			c.add(MethodTypeChanged, m, "response type changed from %s to %s", m.Output().FullName(), cm.Output().FullName())
+		}
+		if m.IsStreamingClient() != cm.IsStreamingClient() || m.IsStreamingServer() != cm.IsStreamingServer() {
+			c.add(MethodStreamingChanged, m, "streaming changed from %s to %s", streaming(m), streaming(cm))
+		}
+	}
+}
+
+func (c *comparer) message(md protoreflect.MessageDescriptor) {
+	cur, ok := c.find(md).(protoreflect.MessageDescriptor)
+	if !ok {
+		c.add(MessageRemoved, md, "message was removed")
+		return
+	}
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		f := fields.Get(i)
+		cf := cur.Fields().ByNumber(f.Number())
+		if cf == nil {
+			if !cur.ReservedRanges().Has(f.Number()) {
+				c.add(FieldRemoved, f, "field %d was removed without being reserved", f.Number())
+			}
+			continue
+		}
+		if kind(f) != kind(cf) {
+			c.add(FieldTypeChanged, f, "type changed from %s to %s", kind(f), kind(cf))
+		}
+		if (f.Cardinality() == protoreflect.Repeated) != (cf.Cardinality() == protoreflect.Repeated) {
+			c.add(FieldCardinalityChanged, f, "cardinality changed from %s to %s", f.Cardinality(), cf.Cardinality())
+		}
+		if f.Name() != cf.Name() || f.JSONName() != cf.JSONName() {
+			c.add(FieldNameChanged, f, "name changed from %s to %s", f.Name(), cf.Name())
+		}
+		if oneof(f) != oneof(cf) {
+			c.add(FieldOneofChanged, f, "oneof changed from %q to %q", oneof(f), oneof(cf))
+		}
+	}
+	c.messages(md.Messages())
+	c.enums(md.Enums())
+}
+
+func (c *comparer) enum(ed protoreflect.EnumDescriptor) {
+	cur, ok := c.find(ed).(protoreflect.EnumDescriptor)
+	if !ok {
+		c.add(EnumRemoved, ed, "enum was removed")
+		return
+	}
+	values := ed.Values()
+	for i := 0; i < values.Len(); i++ {
+		v := values.Get(i)
+		cv := cur.Values().ByNumber(v.Number())
+		if cv == nil {
+			if !cur.ReservedRanges().Has(v.Number()) {
+				c.add(EnumValueRemoved, v, "value %d was removed without being reserved", v.Number())
+			}
+			continue
+		}
+		// the json encoding uses the values names
+		if cv.Name() != v.Name() && cur.Values().ByName(v.Name()) == nil {
+			c.add(EnumValueNameChanged, v, "value %d was renamed to %s", v.Number(), cv.Name())
+		}
+	}
+}
+
+func streaming(m protoreflect.MethodDescriptor) string {
+	switch {
+	case m.IsStreamingClient() && m.IsStreamingServer():
+		return "bidirectional"
+	case m.IsStreamingClient():
+		return "client streaming"
+	case m.IsStreamingServer():
+		return "server streaming"
+	}
+	return "unary"
+}
+
+// kind returns the field type including the message or enum name, and the map key and value types
+func kind(f protoreflect.FieldDescriptor) string {
+	switch {
+	case f.IsMap():
+		return fmt.Sprintf("map<%s, %s>", kind(f.MapKey()), kind(f.MapValue()))
+	case f.Message() != nil:
+		return string(f.Message().FullName())
+	case f.Enum() != nil:
+		return string(f.Enum().FullName())
+	}
+	return f.Kind().String()
+}
+
+func oneof(f protoreflect.FieldDescriptor) string {
+	if o := f.ContainingOneof(); o != nil && !o.IsSynthetic() {
+		return string(o.Name())
+	}
+	return ""
+}
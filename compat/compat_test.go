@@ -0,0 +1,103 @@
+package compat
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func field(name string, n int32, typ descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+	return &descriptorpb.FieldDescriptorProto{
+		Name:   proto.String(name),
+		Number: proto.Int32(n),
+		Type:   typ.Enum(),
+		Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+	}
+}
+
+func baseline() *descriptorpb.FileDescriptorSet {
+	return &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{{
+		Name:    proto.String("greeter.proto"),
+		Package: proto.String("greeter"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("HelloRequest"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("name", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+				field("count", 2, descriptorpb.FieldDescriptorProto_TYPE_INT32),
+				field("lang", 3, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+			}},
+			{Name: proto.String("HelloReply"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("message", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+			}},
+		},
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name: proto.String("Mood"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{
+				{Name: proto.String("MOOD_UNSPECIFIED"), Number: proto.Int32(0)},
+				{Name: proto.String("MOOD_HAPPY"), Number: proto.Int32(1)},
+			},
+		}},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Greeter"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				{Name: proto.String("SayHello"), InputType: proto.String(".greeter.HelloRequest"), OutputType: proto.String(".greeter.HelloReply")},
+				{Name: proto.String("SayGoodbye"), InputType: proto.String(".greeter.HelloRequest"), OutputType: proto.String(".greeter.HelloReply")},
+			},
+		}},
+	}}}
+}
+
+func TestCheckCompatible(t *testing.T) {
+	current := baseline()
+	f := current.File[0]
+	// new elements and reserved removed fields are compatible
+	f.MessageType[0].Field = f.MessageType[0].Field[:2]
+	f.MessageType[0].ReservedRange = []*descriptorpb.DescriptorProto_ReservedRange{{Start: proto.Int32(3), End: proto.Int32(4)}}
+	f.MessageType[1].Field = append(f.MessageType[1].Field, field("lang", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING))
+	f.Service[0].Method = append(f.Service[0].Method, &descriptorpb.MethodDescriptorProto{
+		Name: proto.String("SayHi"), InputType: proto.String(".greeter.HelloRequest"), OutputType: proto.String(".greeter.HelloReply"),
+	})
+	assert.NoError(t, Check(baseline(), current))
+}
+
+func TestCheckBreaking(t *testing.T) {
+	current := baseline()
+	f := current.File[0]
+	f.MessageType[0].Field = []*descriptorpb.FieldDescriptorProto{
+		field("username", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+		field("count", 2, descriptorpb.FieldDescriptorProto_TYPE_INT64),
+	}
+	f.EnumType[0].Value = f.EnumType[0].Value[:1]
+	f.Service[0].Method = f.Service[0].Method[:1]
+	f.Service[0].Method[0].ServerStreaming = proto.Bool(true)
+
+	err := Check(baseline(), current)
+	require.Error(t, err)
+	e, ok := err.(*Error)
+	require.True(t, ok)
+	var kinds []string
+	for _, v := range e.Changes {
+		kinds = append(kinds, v.Kind)
+	}
+	assert.ElementsMatch(t, []string{FieldTypeChanged, FieldRemoved, FieldNameChanged, EnumValueRemoved, MethodRemoved, MethodStreamingChanged}, kinds)
+	assert.Contains(t, err.Error(), "greeter.HelloRequest.lang")
+}
+
+func TestLoadSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "compat")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"baseline.binpb", "baseline.json"} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, Save(path, baseline()))
+		set, err := Load(path)
+		require.NoError(t, err)
+		assert.True(t, proto.Equal(baseline(), set))
+	}
+}
@@ -0,0 +1,41 @@
+// Package consumer provides the delivery guarantees the broker consumers need on top of the at-least-once
+// delivery, as handler middlewares, so that the event handlers do not each implement them:
+// the deduplication of the redelivered messages, the ordered processing of the messages sharing a key
+// and the retry and dead-lettering of the messages which processing keeps failing.
+//
+//	r := consumer.NewRetrier(consumer.WithDeadLetterQueue(consumer.NewMemoryQueue()))
+//	h := consumer.Chain(handle,
+//		consumer.Dedup(consumer.NewMemoryStore(100000), time.Hour),
+//		consumer.Ordered(ctx, 16),
+//		r.Middleware,
+//	)
+package consumer
+
+import (
+	"context"
+)
+
+// Message is a message delivered by the broker
+type Message struct {
+	// ID identifies the message across its redeliveries
+	ID string
+	// Key is the ordering key, e.g. the aggregate id, the messages without key are not ordered
+	Key     string
+	Topic   string
+	Payload []byte
+	Headers map[string]string
+}
+
+// Handler processes a message, the message should be redelivered when it returns an error
+type Handler func(ctx context.Context, m *Message) error
+
+// Middleware wraps a Handler
+type Middleware func(next Handler) Handler
+
+// Chain returns h wrapped with the middlewares, the first one being the outermost
+func Chain(h Handler, mws ...Middleware) Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
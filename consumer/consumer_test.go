@@ -0,0 +1,157 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDedup(t *testing.T) {
+	ctx := context.Background()
+	var calls int
+	fail := true
+	h := Chain(func(ctx context.Context, m *Message) error {
+		calls++
+		if fail {
+			return errors.New("failed")
+		}
+		return nil
+	}, Dedup(NewMemoryStore(10), time.Hour))
+
+	m := &Message{ID: "1"}
+	// a failed message is processed again on redelivery
+	require.Error(t, h(ctx, m))
+	fail = false
+	require.NoError(t, h(ctx, m))
+	assert.Equal(t, 2, calls)
+	// duplicates are skipped
+	require.NoError(t, h(ctx, m))
+	assert.Equal(t, 2, calls)
+	// messages without id are always processed
+	require.NoError(t, h(ctx, &Message{}))
+	require.NoError(t, h(ctx, &Message{}))
+	assert.Equal(t, 4, calls)
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore(2)
+	for _, id := range []string{"1", "2", "3"} {
+		ok, err := s.Add(ctx, id, time.Hour)
+		require.NoError(t, err)
+		assert.True(t, ok)
+	}
+	// the oldest id is evicted
+	ok, _ := s.Add(ctx, "1", time.Hour)
+	assert.True(t, ok)
+	ok, _ = s.Add(ctx, "3", time.Hour)
+	assert.False(t, ok)
+
+	// expired ids are evicted
+	ok, _ = s.Add(ctx, "4", time.Millisecond)
+	assert.True(t, ok)
+	time.Sleep(5 * time.Millisecond)
+	ok, _ = s.Add(ctx, "4", time.Hour)
+	assert.True(t, ok)
+}
+
+func TestOrdered(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var (
+		mu  sync.Mutex
+		got = make(map[string][]int)
+	)
+	h := Chain(func(ctx context.Context, m *Message) error {
+		var i int
+		fmt.Sscan(string(m.Payload), &i)
+		mu.Lock()
+		got[m.Key] = append(got[m.Key], i)
+		mu.Unlock()
+		return nil
+	}, Ordered(ctx, 4))
+
+	keys := []string{"a", "b", "c", "d", "e"}
+	var wg sync.WaitGroup
+	for _, k := range keys {
+		wg.Add(1)
+		// each key is delivered in order, the keys concurrently
+		go func(k string) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				require.NoError(t, h(ctx, &Message{Key: k, Payload: []byte(fmt.Sprint(i))}))
+			}
+		}(k)
+	}
+	wg.Wait()
+	for _, k := range keys {
+		require.Len(t, got[k], 100)
+		for i, v := range got[k] {
+			assert.Equal(t, i, v)
+		}
+	}
+
+	// the handler returns when the workers are stopped
+	cancel()
+	assert.Error(t, h(context.Background(), &Message{Key: "a"}))
+}
+
+func TestRetrier(t *testing.T) {
+	ctx := context.Background()
+	q := NewMemoryQueue()
+	r := NewRetrier(WithMaxAttempts(3), WithBackoff(func(int) time.Duration { return time.Millisecond }), WithDeadLetterQueue(q))
+
+	calls := make(map[string]int)
+	failing := true
+	fn := func(ctx context.Context, m *Message) error {
+		calls[m.ID]++
+		switch {
+		case m.ID == "poison":
+			return Permanent(errors.New("invalid payload"))
+		case m.ID == "flaky" && calls[m.ID] < 2:
+			return errors.New("temporary")
+		case m.ID == "failing" && failing:
+			return errors.New("broken")
+		}
+		return nil
+	}
+	h := Chain(fn, r.Middleware)
+
+	require.NoError(t, h(ctx, &Message{ID: "flaky", Topic: "a"}))
+	assert.Equal(t, 2, calls["flaky"])
+	// the messages are acknowledged once dead-lettered
+	require.NoError(t, h(ctx, &Message{ID: "failing", Topic: "a"}))
+	assert.Equal(t, 3, calls["failing"])
+	require.NoError(t, h(ctx, &Message{ID: "poison", Topic: "b"}))
+	assert.Equal(t, 1, calls["poison"])
+
+	ls, err := r.DeadLetters(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, ls, 2)
+	assert.Equal(t, "failing", ls[0].Message.ID)
+	assert.Equal(t, 3, ls[0].Attempts)
+	assert.Equal(t, "broken", ls[0].Error)
+	ls, err = r.DeadLetters(ctx, "b")
+	require.NoError(t, err)
+	require.Len(t, ls, 1)
+	assert.Equal(t, "poison", ls[0].Message.ID)
+
+	// a failed requeue keeps the message
+	require.Error(t, r.Requeue(ctx, "failing", fn))
+	l, err := q.Get(ctx, "failing")
+	require.NoError(t, err)
+	assert.Equal(t, 4, l.Attempts)
+	failing = false
+	require.NoError(t, r.Requeue(ctx, "failing", fn))
+	_, err = q.Get(ctx, "failing")
+	assert.Equal(t, ErrNotFound, err)
+
+	require.NoError(t, r.Discard(ctx, "poison"))
+	assert.Equal(t, ErrNotFound, r.Requeue(ctx, "poison", fn))
+}
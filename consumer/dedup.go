@@ -0,0 +1,96 @@
+package consumer
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"go.linka.cloud/grpc/logger"
+)
+
+// Store records the ids of the processed messages
+type Store interface {
+	// Add records id for ttl and reports whether it was not already recorded
+	Add(ctx context.Context, id string, ttl time.Duration) (bool, error)
+	// Remove forgets id, e.g. when its processing failed
+	Remove(ctx context.Context, id string) error
+}
+
+// Dedup skips the messages which id was already processed within window.
+// The id is recorded before the message is processed, so that the concurrent redeliveries are skipped too,
+// and forgotten if the processing fails, so that the next redelivery is processed.
+// The messages without id are always processed.
+func Dedup(s Store, window time.Duration) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, m *Message) error {
+			if m.ID == "" {
+				return next(ctx, m)
+			}
+			ok, err := s.Add(ctx, m.ID, window)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				logger.C(ctx).WithFields("message", m.ID, "topic", m.Topic).Debug("skipping duplicate message")
+				return nil
+			}
+			if err := next(ctx, m); err != nil {
+				if rerr := s.Remove(ctx, m.ID); rerr != nil {
+					logger.C(ctx).Warnf("failed to forget message %s: %v", m.ID, rerr)
+				}
+				return err
+			}
+			return nil
+		}
+	}
+}
+
+// NewMemoryStore returns an in-memory Store recording at most size ids, the oldest ones being evicted first
+func NewMemoryStore(size int) Store {
+	return &memoryStore{size: size, ids: make(map[string]*list.Element), order: list.New()}
+}
+
+type entry struct {
+	id      string
+	expires time.Time
+}
+
+type memoryStore struct {
+	mu    sync.Mutex
+	size  int
+	ids   map[string]*list.Element
+	order *list.List
+}
+
+func (s *memoryStore) Add(_ context.Context, id string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.ids[id]; ok {
+		if now.Before(e.Value.(*entry).expires) {
+			return false, nil
+		}
+		s.remove(e)
+	}
+	// evict the expired and the oldest entries
+	for e := s.order.Front(); e != nil && (now.After(e.Value.(*entry).expires) || s.order.Len() >= s.size); e = s.order.Front() {
+		s.remove(e)
+	}
+	s.ids[id] = s.order.PushBack(&entry{id: id, expires: now.Add(ttl)})
+	return true, nil
+}
+
+func (s *memoryStore) Remove(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.ids[id]; ok {
+		s.remove(e)
+	}
+	return nil
+}
+
+func (s *memoryStore) remove(e *list.Element) {
+	delete(s.ids, e.Value.(*entry).id)
+	s.order.Remove(e)
+}
@@ -0,0 +1,82 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned when a dead-lettered message does not exist
+var ErrNotFound = errors.New("consumer: dead letter not found")
+
+// Letter is a dead-lettered message
+type Letter struct {
+	Message *Message `json:"message"`
+	// Error is the last processing error
+	Error    string    `json:"error"`
+	Attempts int       `json:"attempts"`
+	Created  time.Time `json:"created"`
+}
+
+// DeadLetterQueue stores the messages which processing keeps failing, keyed by their id
+type DeadLetterQueue interface {
+	Put(ctx context.Context, l *Letter) error
+	Get(ctx context.Context, id string) (*Letter, error)
+	// List returns the topic dead-lettered messages, oldest first, or all of them if topic is empty
+	List(ctx context.Context, topic string) ([]*Letter, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// NewMemoryQueue returns an in-memory DeadLetterQueue
+func NewMemoryQueue() DeadLetterQueue {
+	return &memoryQueue{letters: make(map[string]*Letter)}
+}
+
+type memoryQueue struct {
+	mu      sync.RWMutex
+	letters map[string]*Letter
+}
+
+func (q *memoryQueue) Put(_ context.Context, l *Letter) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.letters[l.Message.ID] = l
+	return nil
+}
+
+func (q *memoryQueue) Get(_ context.Context, id string) (*Letter, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	l, ok := q.letters[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return l, nil
+}
+
+func (q *memoryQueue) List(_ context.Context, topic string) ([]*Letter, error) {
+	q.mu.RLock()
+	var out []*Letter
+	for _, v := range q.letters {
+		if topic == "" || v.Message.Topic == topic {
+			out = append(out, v)
+		}
+	}
+	q.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Created.Before(out[j].Created)
+	})
+	return out, nil
+}
+
+func (q *memoryQueue) Delete(_ context.Context, id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.letters[id]; !ok {
+		return ErrNotFound
+	}
+	delete(q.letters, id)
+	return nil
+}
@@ -0,0 +1,53 @@
+package consumer
+
+import (
+	"time"
+
+	"go.linka.cloud/grpc/utils/backoff"
+)
+
+const defaultMaxAttempts = 5
+
+type Option func(o *options)
+
+// WithMaxAttempts sets the number of attempts before a message is dead-lettered, defaults to 5
+func WithMaxAttempts(n int) Option {
+	return func(o *options) {
+		o.maxAttempts = n
+	}
+}
+
+// WithBackoff sets the delay before the given retry attempt, starting at 1, defaults to backoff.Do
+func WithBackoff(fn func(attempt int) time.Duration) Option {
+	return func(o *options) {
+		o.backoff = fn
+	}
+}
+
+// WithDeadLetterQueue sets the queue storing the dead-lettered messages,
+// the messages are only logged and dropped if none is configured
+func WithDeadLetterQueue(q DeadLetterQueue) Option {
+	return func(o *options) {
+		o.queue = q
+	}
+}
+
+type options struct {
+	maxAttempts int
+	backoff     func(attempt int) time.Duration
+	queue       DeadLetterQueue
+}
+
+func newOptions(opts ...Option) options {
+	o := options{
+		maxAttempts: defaultMaxAttempts,
+		backoff:     backoff.Do,
+	}
+	for _, v := range opts {
+		v(&o)
+	}
+	if o.maxAttempts < 1 {
+		o.maxAttempts = 1
+	}
+	return o
+}
@@ -0,0 +1,55 @@
+package consumer
+
+import (
+	"context"
+	"hash/fnv"
+)
+
+type task struct {
+	ctx  context.Context
+	m    *Message
+	next Handler
+	done chan error
+}
+
+// Ordered processes the messages sharing a key sequentially, in their delivery order, while the messages
+// with different keys are processed concurrently on workers partitions. The handler waits for the processing
+// of its message, so that its acknowledgement is unchanged, the broker must deliver the messages concurrently
+// to benefit from the partitions. The workers stop when ctx is done.
+func Ordered(ctx context.Context, workers int) Middleware {
+	if workers < 1 {
+		workers = 1
+	}
+	queues := make([]chan task, workers)
+	for i := range queues {
+		queues[i] = make(chan task)
+		go func(q chan task) {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case t := <-q:
+					t.done <- t.next(t.ctx, t.m)
+				}
+			}
+		}(queues[i])
+	}
+	return func(next Handler) Handler {
+		return func(hctx context.Context, m *Message) error {
+			if m.Key == "" {
+				return next(hctx, m)
+			}
+			h := fnv.New32a()
+			h.Write([]byte(m.Key))
+			t := task{ctx: hctx, m: m, next: next, done: make(chan error, 1)}
+			select {
+			case queues[h.Sum32()%uint32(len(queues))] <- t:
+			case <-hctx.Done():
+				return hctx.Err()
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			return <-t.done
+		}
+	}
+}
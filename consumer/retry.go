@@ -0,0 +1,137 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"go.linka.cloud/grpc/logger"
+)
+
+// permanentError is a failure which is not worth retrying, e.g. a message that cannot be decoded
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks err as not worth retrying: the message is dead-lettered on the first attempt
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Retrier retries the messages which processing fails, with backoff, and dead-letters the ones
+// still failing after the max attempts, so that a poison message does not block its subscription.
+// The dead-lettered messages are acknowledged, they can be inspected and requeued.
+// It is a prometheus.Collector exposing the processing metrics.
+type Retrier struct {
+	opts     options
+	attempts *prometheus.CounterVec
+}
+
+// NewRetrier returns a Retrier
+func NewRetrier(opts ...Option) *Retrier {
+	return &Retrier{
+		opts: newOptions(opts...),
+		attempts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "consumer_messages_total",
+			Help: "Total number of message processing attempts by result: success, retry, dead_letter or requeued.",
+		}, []string{"topic", "result"}),
+	}
+}
+
+// Middleware is the retry Middleware
+func (r *Retrier) Middleware(next Handler) Handler {
+	return func(ctx context.Context, m *Message) error {
+		var err error
+		for i := 1; ; i++ {
+			if err = next(ctx, m); err == nil {
+				r.attempts.WithLabelValues(m.Topic, "success").Inc()
+				return nil
+			}
+			var perm *permanentError
+			if errors.As(err, &perm) || i >= r.opts.maxAttempts {
+				return r.deadLetter(ctx, m, i, err)
+			}
+			r.attempts.WithLabelValues(m.Topic, "retry").Inc()
+			select {
+			case <-time.After(r.opts.backoff(i)):
+			case <-ctx.Done():
+				// the broker redelivers the message
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+func (r *Retrier) deadLetter(ctx context.Context, m *Message, attempts int, err error) error {
+	r.attempts.WithLabelValues(m.Topic, "dead_letter").Inc()
+	log := logger.C(ctx).WithFields("message", m.ID, "topic", m.Topic)
+	if r.opts.queue == nil {
+		log.Errorf("consumer: dropping message after %d attempts: %v", attempts, err)
+		return nil
+	}
+	log.Warnf("consumer: dead-lettering message after %d attempts: %v", attempts, err)
+	if err := r.opts.queue.Put(ctx, &Letter{Message: m, Error: err.Error(), Attempts: attempts, Created: time.Now()}); err != nil {
+		// let the broker redeliver the message rather than losing it
+		return err
+	}
+	return nil
+}
+
+// DeadLetters returns the topic dead-lettered messages, or all of them if topic is empty
+func (r *Retrier) DeadLetters(ctx context.Context, topic string) ([]*Letter, error) {
+	if r.opts.queue == nil {
+		return nil, nil
+	}
+	return r.opts.queue.List(ctx, topic)
+}
+
+// Requeue processes a dead-lettered message again with h, e.g. once the handler has been fixed,
+// and removes it from the queue on success. h is called once, it should not be wrapped by the Retrier.
+func (r *Retrier) Requeue(ctx context.Context, id string, h Handler) error {
+	if r.opts.queue == nil {
+		return ErrNotFound
+	}
+	l, err := r.opts.queue.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := h(ctx, l.Message); err != nil {
+		l.Attempts++
+		l.Error = err.Error()
+		if perr := r.opts.queue.Put(ctx, l); perr != nil {
+			logger.C(ctx).Errorf("consumer: failed to update dead letter %s: %v", id, perr)
+		}
+		return err
+	}
+	r.attempts.WithLabelValues(l.Message.Topic, "requeued").Inc()
+	return r.opts.queue.Delete(ctx, id)
+}
+
+// Discard removes a dead-lettered message
+func (r *Retrier) Discard(ctx context.Context, id string) error {
+	if r.opts.queue == nil {
+		return ErrNotFound
+	}
+	return r.opts.queue.Delete(ctx, id)
+}
+
+func (r *Retrier) Describe(c chan<- *prometheus.Desc) {
+	r.attempts.Describe(c)
+}
+
+func (r *Retrier) Collect(c chan<- prometheus.Metric) {
+	r.attempts.Collect(c)
+}
@@ -0,0 +1,149 @@
+package crud
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// The resources fields conventions, see https://google.aip.dev/154 and https://google.aip.dev/164
+const (
+	// ETagField is a string field holding a checksum of the resource, computed by the crud layer
+	ETagField = "etag"
+	// VersionField is an integer field incremented on each update
+	VersionField = "version"
+	// DeleteTimeField is a google.protobuf.Timestamp field set when the resource is soft-deleted
+	DeleteTimeField = "delete_time"
+)
+
+// ETag returns the etag of m: a checksum of its deterministic encoding, its etag field excluded
+func ETag(m proto.Message) (string, error) {
+	r := m.ProtoReflect()
+	if fd := r.Descriptor().Fields().ByName(ETagField); fd != nil {
+		m = proto.Clone(m)
+		m.ProtoReflect().Clear(fd)
+	}
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(sum[:16]), nil
+}
+
+// CheckETag returns ErrConflict if etag is not empty and does not match the etag of m
+func CheckETag(m proto.Message, etag string) error {
+	if etag == "" {
+		return nil
+	}
+	cur, err := ETag(m)
+	if err != nil {
+		return err
+	}
+	if cur != etag {
+		return ErrConflict
+	}
+	return nil
+}
+
+// Deleted reports whether m is soft-deleted, i.e. has its delete_time field set
+func Deleted(m proto.Message) bool {
+	r := m.ProtoReflect()
+	fd := r.Descriptor().Fields().ByName(DeleteTimeField)
+	return fd != nil && r.Has(fd)
+}
+
+// SoftDeletable reports whether the resources of type md can be soft-deleted, i.e. have a delete_time field
+func SoftDeletable(md protoreflect.MessageDescriptor) bool {
+	fd := md.Fields().ByName(DeleteTimeField)
+	return fd != nil && fd.Message() != nil && fd.Message().FullName() == "google.protobuf.Timestamp"
+}
+
+// SoftDelete sets the delete_time field of m to t, it returns false if m can not be soft-deleted
+func SoftDelete(m proto.Message, t time.Time) bool {
+	r := m.ProtoReflect()
+	if !SoftDeletable(r.Descriptor()) {
+		return false
+	}
+	ts := r.Mutable(r.Descriptor().Fields().ByName(DeleteTimeField)).Message()
+	fields := ts.Descriptor().Fields()
+	ts.Set(fields.ByName("seconds"), protoreflect.ValueOfInt64(t.Unix()))
+	ts.Set(fields.ByName("nanos"), protoreflect.ValueOfInt32(int32(t.Nanosecond())))
+	return true
+}
+
+// Undelete clears the delete_time field of m
+func Undelete(m proto.Message) {
+	r := m.ProtoReflect()
+	if fd := r.Descriptor().Fields().ByName(DeleteTimeField); fd != nil {
+		r.Clear(fd)
+	}
+}
+
+// version returns the version field of md if it is an integer field
+func version(md protoreflect.MessageDescriptor) protoreflect.FieldDescriptor {
+	fd := md.Fields().ByName(VersionField)
+	if fd == nil || fd.IsList() {
+		return nil
+	}
+	switch fd.Kind() {
+	case protoreflect.Int64Kind, protoreflect.Uint64Kind, protoreflect.Int32Kind, protoreflect.Uint32Kind:
+		return fd
+	}
+	return nil
+}
+
+// checkVersion returns ErrConflict if the version of req is set and differs from the one of cur
+func checkVersion(fd protoreflect.FieldDescriptor, cur, req protoreflect.Message) error {
+	if fd == nil || !req.Has(fd) {
+		return nil
+	}
+	if cur.Get(fd).Interface() != req.Get(fd).Interface() {
+		return ErrConflict
+	}
+	return nil
+}
+
+// incVersion increments the version of m
+func incVersion(fd protoreflect.FieldDescriptor, m protoreflect.Message) {
+	if fd == nil {
+		return
+	}
+	v := m.Get(fd)
+	switch fd.Kind() {
+	case protoreflect.Int64Kind:
+		m.Set(fd, protoreflect.ValueOfInt64(v.Int()+1))
+	case protoreflect.Int32Kind:
+		m.Set(fd, protoreflect.ValueOfInt32(int32(v.Int()+1)))
+	case protoreflect.Uint64Kind:
+		m.Set(fd, protoreflect.ValueOfUint64(v.Uint()+1))
+	case protoreflect.Uint32Kind:
+		m.Set(fd, protoreflect.ValueOfUint32(uint32(v.Uint()+1)))
+	}
+}
+
+// setETag computes and sets the etag of m if it has an etag field
+func setETag(m protoreflect.Message) error {
+	fd := m.Descriptor().Fields().ByName(ETagField)
+	if fd == nil || fd.Kind() != protoreflect.StringKind {
+		return nil
+	}
+	etag, err := ETag(m.Interface())
+	if err != nil {
+		return err
+	}
+	m.Set(fd, protoreflect.ValueOfString(etag))
+	return nil
+}
+
+// requestETag returns the etag of the request or of its resource if any
+func requestETag(m protoreflect.Message) string {
+	fd := m.Descriptor().Fields().ByName(ETagField)
+	if fd == nil || fd.Kind() != protoreflect.StringKind {
+		return ""
+	}
+	return m.Get(fd).String()
+}
@@ -0,0 +1,506 @@
+// Package crud implements the standard Create, Get, List, Update and Delete methods of a service
+// over a Store at runtime, following https://google.aip.dev/121 naming conventions, so that plain
+// resource services do not need hand written implementations.
+//
+// The methods are recognized by their names: Create<Resource>, Get<Resource>, List<Resources>,
+// Update<Resource>, Delete<Resource> and Undelete<Resource>, e.g.
+//
+//	rpc CreateBook(CreateBookRequest) returns (Book);          // book, optional book_id
+//	rpc GetBook(GetBookRequest) returns (Book);                // id
+//	rpc ListBooks(ListBooksRequest) returns (ListBooksResponse); // page_size, page_token, filter, order_by
+//	rpc UpdateBook(UpdateBookRequest) returns (Book);          // book, update_mask
+//	rpc DeleteBook(DeleteBookRequest) returns (google.protobuf.Empty);
+//
+// The resources following the etag, version and delete_time fields conventions get optimistic locking and
+// soft-delete support: a stale etag or version is rejected with Aborted, and modifying a soft-deleted resource
+// with FailedPrecondition. The soft-deleted resources are excluded from List unless show_deleted is set.
+//
+// The other methods return Unimplemented. The handlers go through the service interceptors,
+// so validation, authorization or metrics apply as for generated services.
+package crud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	errors2 "go.linka.cloud/grpc/errors"
+)
+
+const fieldMaskName = "google.protobuf.FieldMask"
+
+// Register registers the service described by sd on s, its standard methods being implemented over store
+func Register(s grpc.ServiceRegistrar, sd protoreflect.ServiceDescriptor, store Store, opts ...Option) error {
+	desc, impl, err := newServiceDesc(sd, store, newOptions(opts...))
+	if err != nil {
+		return err
+	}
+	s.RegisterService(desc, impl)
+	return nil
+}
+
+type server struct {
+	sd    protoreflect.ServiceDescriptor
+	store Store
+	opts  options
+}
+
+type handler func(ctx context.Context, req protoreflect.Message) (proto.Message, error)
+
+func newServiceDesc(sd protoreflect.ServiceDescriptor, store Store, o options) (*grpc.ServiceDesc, *server, error) {
+	s := &server{sd: sd, store: store, opts: o}
+	desc := &grpc.ServiceDesc{
+		ServiceName: string(sd.FullName()),
+		HandlerType: (*interface{})(nil),
+		Metadata:    sd.ParentFile().Path(),
+	}
+	var found bool
+	methods := sd.Methods()
+	for i := 0; i < methods.Len(); i++ {
+		m := methods.Get(i)
+		if m.IsStreamingClient() || m.IsStreamingServer() {
+			desc.Streams = append(desc.Streams, grpc.StreamDesc{
+				StreamName:    string(m.Name()),
+				ClientStreams: m.IsStreamingClient(),
+				ServerStreams: m.IsStreamingServer(),
+				Handler: func(interface{}, grpc.ServerStream) error {
+					return errors2.Unimplementedf("method %s not implemented", m.Name())
+				},
+			})
+			continue
+		}
+		h, err := s.handler(m)
+		if err != nil {
+			return nil, nil, fmt.Errorf("crud: %s: %w", m.FullName(), err)
+		}
+		if h == nil {
+			name := m.Name()
+			h = func(context.Context, protoreflect.Message) (proto.Message, error) {
+				return nil, errors2.Unimplementedf("method %s not implemented", name)
+			}
+		} else {
+			found = true
+		}
+		desc.Methods = append(desc.Methods, s.methodDesc(m, h))
+	}
+	if !found {
+		return nil, nil, fmt.Errorf("crud: %s has no standard method", sd.FullName())
+	}
+	return desc, s, nil
+}
+
+func (s *server) methodDesc(m protoreflect.MethodDescriptor, h handler) grpc.MethodDesc {
+	in := messageType(m.Input())
+	method := fmt.Sprintf("/%s/%s", s.sd.FullName(), m.Name())
+	return grpc.MethodDesc{
+		MethodName: string(m.Name()),
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := in.New().Interface()
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			call := func(ctx context.Context, req interface{}) (interface{}, error) {
+				return h(ctx, req.(proto.Message).ProtoReflect())
+			}
+			if interceptor == nil {
+				return call(ctx, req)
+			}
+			return interceptor(ctx, req, &grpc.UnaryServerInfo{Server: srv, FullMethod: method}, call)
+		},
+	}
+}
+
+// handler returns the handler of the standard method m, or nil if m is not a standard method
+func (s *server) handler(m protoreflect.MethodDescriptor) (handler, error) {
+	name := string(m.Name())
+	switch {
+	case hasVerb(name, "Create"):
+		return s.create(m)
+	case hasVerb(name, "Get"):
+		return s.get(m)
+	case hasVerb(name, "List"):
+		return s.list(m)
+	case hasVerb(name, "Update"):
+		return s.update(m)
+	case hasVerb(name, "Delete"):
+		return s.delete(m)
+	case hasVerb(name, "Undelete"):
+		return s.undelete(m)
+	}
+	return nil, nil
+}
+
+// hasVerb reports whether the method name starts with the verb followed by the resource name, e.g. GetBook but not Getaway
+func hasVerb(name, verb string) bool {
+	return len(name) > len(verb) && strings.HasPrefix(name, verb) && unicode.IsUpper(rune(name[len(verb)]))
+}
+
+func (s *server) idField(md protoreflect.MessageDescriptor) (protoreflect.FieldDescriptor, error) {
+	names := []string{"id", "name"}
+	if s.opts.idField != "" {
+		names = []string{s.opts.idField}
+	}
+	for _, v := range names {
+		fd := md.Fields().ByName(protoreflect.Name(v))
+		if fd == nil {
+			continue
+		}
+		if fd.Kind() != protoreflect.StringKind || fd.IsList() {
+			return nil, fmt.Errorf("%s.%s is not a string field", md.FullName(), v)
+		}
+		return fd, nil
+	}
+	return nil, fmt.Errorf("%s has no %s field", md.FullName(), strings.Join(names, " or "))
+}
+
+// resourceField returns the field of md holding a resource of type res
+func resourceField(md, res protoreflect.MessageDescriptor) (protoreflect.FieldDescriptor, error) {
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if fd.Message() != nil && fd.Message().FullName() == res.FullName() && !fd.IsList() && !fd.IsMap() {
+			return fd, nil
+		}
+	}
+	return nil, fmt.Errorf("%s has no %s field", md.FullName(), res.FullName())
+}
+
+func (s *server) create(m protoreflect.MethodDescriptor) (handler, error) {
+	res := m.Output()
+	rt := messageType(res)
+	idFd, err := s.idField(res)
+	if err != nil {
+		return nil, err
+	}
+	resFd, err := resourceField(m.Input(), res)
+	if err != nil {
+		return nil, err
+	}
+	// the client assigned id field, e.g. book_id, see https://google.aip.dev/133#user-specified-ids
+	reqIDFd := m.Input().Fields().ByName(resFd.Name() + "_id")
+	versionFd := version(res)
+	return func(ctx context.Context, req protoreflect.Message) (proto.Message, error) {
+		r := rt.New()
+		proto.Merge(r.Interface(), req.Get(resFd).Message().Interface())
+		id := r.Get(idFd).String()
+		if reqIDFd != nil && req.Get(reqIDFd).String() != "" {
+			id = req.Get(reqIDFd).String()
+		}
+		if id == "" {
+			id = s.opts.newID()
+		}
+		r.Set(idFd, protoreflect.ValueOfString(id))
+		Undelete(r.Interface())
+		if versionFd != nil {
+			r.Clear(versionFd)
+			incVersion(versionFd, r)
+		}
+		if err := setETag(r); err != nil {
+			return nil, err
+		}
+		if err := s.store.Create(ctx, id, r.Interface()); err != nil {
+			return nil, storeError(err)
+		}
+		return r.Interface(), nil
+	}, nil
+}
+
+func (s *server) get(m protoreflect.MethodDescriptor) (handler, error) {
+	res := m.Output()
+	rt := messageType(res)
+	if _, err := s.idField(res); err != nil {
+		return nil, err
+	}
+	reqIDFd, err := s.idField(m.Input())
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, req protoreflect.Message) (proto.Message, error) {
+		id := req.Get(reqIDFd).String()
+		if id == "" {
+			return nil, errors2.InvalidArgumentf("%s is required", reqIDFd.Name())
+		}
+		r := rt.New().Interface()
+		if err := s.store.Get(ctx, id, r); err != nil {
+			return nil, storeError(err)
+		}
+		return r, nil
+	}, nil
+}
+
+func (s *server) list(m protoreflect.MethodDescriptor) (handler, error) {
+	var itemsFd protoreflect.FieldDescriptor
+	fields := m.Output().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		if fd := fields.Get(i); fd.IsList() && fd.Message() != nil {
+			itemsFd = fd
+			break
+		}
+	}
+	if itemsFd == nil {
+		return nil, fmt.Errorf("%s has no repeated resource field", m.Output().FullName())
+	}
+	rt := messageType(itemsFd.Message())
+	out := messageType(m.Output())
+	in := m.Input().Fields()
+	sizeFd, tokenFd, filterFd, orderFd := in.ByName("page_size"), in.ByName("page_token"), in.ByName("filter"), in.ByName("order_by")
+	showDeletedFd := in.ByName("show_deleted")
+	nextFd := fields.ByName("next_page_token")
+	return func(ctx context.Context, req protoreflect.Message) (proto.Message, error) {
+		o := ListOptions{PageSize: s.opts.pageSize}
+		if sizeFd != nil {
+			switch n := int(req.Get(sizeFd).Int()); {
+			case n < 0:
+				return nil, errors2.InvalidArgumentf("page_size must be positive")
+			case n > s.opts.maxPageSize:
+				o.PageSize = s.opts.maxPageSize
+			case n > 0:
+				o.PageSize = n
+			}
+		}
+		if tokenFd != nil {
+			o.PageToken = req.Get(tokenFd).String()
+		}
+		if filterFd != nil {
+			o.Filter = req.Get(filterFd).String()
+		}
+		if orderFd != nil {
+			o.OrderBy = req.Get(orderFd).String()
+		}
+		if showDeletedFd != nil {
+			o.ShowDeleted = req.Get(showDeletedFd).Bool()
+		}
+		items, next, err := s.store.List(ctx, rt, o)
+		if err != nil {
+			return nil, storeError(err)
+		}
+		res := out.New()
+		l := res.Mutable(itemsFd).List()
+		for _, v := range items {
+			l.Append(protoreflect.ValueOfMessage(v.ProtoReflect()))
+		}
+		if nextFd != nil {
+			res.Set(nextFd, protoreflect.ValueOfString(next))
+		}
+		return res.Interface(), nil
+	}, nil
+}
+
+func (s *server) update(m protoreflect.MethodDescriptor) (handler, error) {
+	res := m.Output()
+	rt := messageType(res)
+	idFd, err := s.idField(res)
+	if err != nil {
+		return nil, err
+	}
+	resFd, err := resourceField(m.Input(), res)
+	if err != nil {
+		return nil, err
+	}
+	var maskFd protoreflect.FieldDescriptor
+	in := m.Input().Fields()
+	for i := 0; i < in.Len(); i++ {
+		if fd := in.Get(i); fd.Message() != nil && fd.Message().FullName() == fieldMaskName {
+			maskFd = fd
+			break
+		}
+	}
+	versionFd := version(res)
+	return func(ctx context.Context, req protoreflect.Message) (proto.Message, error) {
+		src := req.Get(resFd).Message()
+		id := src.Get(idFd).String()
+		if id == "" {
+			return nil, errors2.InvalidArgumentf("%s.%s is required", resFd.Name(), idFd.Name())
+		}
+		cur := rt.New()
+		if err := s.store.Get(ctx, id, cur.Interface()); err != nil {
+			return nil, storeError(err)
+		}
+		if err := s.checkCurrent(cur, src, versionFd); err != nil {
+			return nil, storeError(err)
+		}
+		r := rt.New()
+		paths := maskPaths(req, maskFd)
+		if len(paths) == 0 || (len(paths) == 1 && paths[0] == "*") {
+			// full replacement
+			proto.Merge(r.Interface(), src.Interface())
+		} else {
+			proto.Merge(r.Interface(), cur.Interface())
+			if err := applyMask(r, src, paths); err != nil {
+				return nil, errors2.InvalidArgument(err)
+			}
+		}
+		r.Set(idFd, protoreflect.ValueOfString(id))
+		// the delete time is output only
+		Undelete(r.Interface())
+		if versionFd != nil {
+			r.Set(versionFd, cur.Get(versionFd))
+		}
+		if err := s.save(ctx, id, cur, r, versionFd); err != nil {
+			return nil, storeError(err)
+		}
+		return r.Interface(), nil
+	}, nil
+}
+
+// checkCurrent verifies that the stored resource cur is not deleted and matches the etag and version of req
+func (s *server) checkCurrent(cur, req protoreflect.Message, versionFd protoreflect.FieldDescriptor) error {
+	if Deleted(cur.Interface()) {
+		return ErrDeleted
+	}
+	if err := CheckETag(cur.Interface(), requestETag(req)); err != nil {
+		return err
+	}
+	return checkVersion(versionFd, cur, req)
+}
+
+// save increments the version of r, computes its etag and replaces cur by r in the store
+func (s *server) save(ctx context.Context, id string, cur, r protoreflect.Message, versionFd protoreflect.FieldDescriptor) error {
+	incVersion(versionFd, r)
+	if err := setETag(r); err != nil {
+		return err
+	}
+	if c, ok := s.store.(CompareAndSwapper); ok {
+		return c.CompareAndSwap(ctx, id, cur.Interface(), r.Interface())
+	}
+	return s.store.Update(ctx, id, r.Interface())
+}
+
+func (s *server) delete(m protoreflect.MethodDescriptor) (handler, error) {
+	name := protoreflect.Name(strings.TrimPrefix(string(m.Name()), "Delete"))
+	res := m.ParentFile().Messages().ByName(name)
+	if res == nil {
+		return nil, fmt.Errorf("resource %s not found in %s", name, m.ParentFile().Path())
+	}
+	rt := messageType(res)
+	reqIDFd, err := s.idField(m.Input())
+	if err != nil {
+		return nil, err
+	}
+	out := messageType(m.Output())
+	// the deleted resource is returned if the method returns it, see https://google.aip.dev/164
+	returnsRes := m.Output().FullName() == res.FullName()
+	soft := SoftDeletable(res)
+	versionFd := version(res)
+	return func(ctx context.Context, req protoreflect.Message) (proto.Message, error) {
+		id := req.Get(reqIDFd).String()
+		if id == "" {
+			return nil, errors2.InvalidArgumentf("%s is required", reqIDFd.Name())
+		}
+		etag := requestETag(req)
+		if !soft && !returnsRes && etag == "" {
+			if err := s.store.Delete(ctx, rt, id); err != nil {
+				return nil, storeError(err)
+			}
+			return out.New().Interface(), nil
+		}
+		cur := rt.New()
+		if err := s.store.Get(ctx, id, cur.Interface()); err != nil {
+			return nil, storeError(err)
+		}
+		if err := s.checkCurrent(cur, req, nil); err != nil {
+			return nil, storeError(err)
+		}
+		if !soft {
+			if err := s.store.Delete(ctx, rt, id); err != nil {
+				return nil, storeError(err)
+			}
+			if returnsRes {
+				return cur.Interface(), nil
+			}
+			return out.New().Interface(), nil
+		}
+		r := proto.Clone(cur.Interface())
+		SoftDelete(r, time.Now())
+		if err := s.save(ctx, id, cur, r.ProtoReflect(), versionFd); err != nil {
+			return nil, storeError(err)
+		}
+		if returnsRes {
+			return r, nil
+		}
+		return out.New().Interface(), nil
+	}, nil
+}
+
+func (s *server) undelete(m protoreflect.MethodDescriptor) (handler, error) {
+	res := m.Output()
+	if !SoftDeletable(res) {
+		return nil, fmt.Errorf("%s has no %s timestamp field", res.FullName(), DeleteTimeField)
+	}
+	rt := messageType(res)
+	if _, err := s.idField(res); err != nil {
+		return nil, err
+	}
+	reqIDFd, err := s.idField(m.Input())
+	if err != nil {
+		return nil, err
+	}
+	versionFd := version(res)
+	return func(ctx context.Context, req protoreflect.Message) (proto.Message, error) {
+		id := req.Get(reqIDFd).String()
+		if id == "" {
+			return nil, errors2.InvalidArgumentf("%s is required", reqIDFd.Name())
+		}
+		cur := rt.New()
+		if err := s.store.Get(ctx, id, cur.Interface()); err != nil {
+			return nil, storeError(err)
+		}
+		if !Deleted(cur.Interface()) {
+			return nil, storeError(ErrNotDeleted)
+		}
+		if err := CheckETag(cur.Interface(), requestETag(req)); err != nil {
+			return nil, storeError(err)
+		}
+		r := proto.Clone(cur.Interface())
+		Undelete(r)
+		if err := s.save(ctx, id, cur, r.ProtoReflect(), versionFd); err != nil {
+			return nil, storeError(err)
+		}
+		return r, nil
+	}, nil
+}
+
+func maskPaths(req protoreflect.Message, fd protoreflect.FieldDescriptor) []string {
+	if fd == nil || !req.Has(fd) {
+		return nil
+	}
+	fm := req.Get(fd).Message()
+	l := fm.Get(fm.Descriptor().Fields().ByName("paths")).List()
+	paths := make([]string, 0, l.Len())
+	for i := 0; i < l.Len(); i++ {
+		paths = append(paths, l.Get(i).String())
+	}
+	return paths
+}
+
+// messageType returns the generated type of md if it is registered, a dynamic one otherwise
+func messageType(md protoreflect.MessageDescriptor) protoreflect.MessageType {
+	if mt, err := protoregistry.GlobalTypes.FindMessageByName(md.FullName()); err == nil {
+		return mt
+	}
+	return dynamicpb.NewMessageType(md)
+}
+
+// storeError maps the Store errors to their status, the other errors are returned as is
+func storeError(err error) error {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return errors2.NotFound(err)
+	case errors.Is(err, ErrAlreadyExists):
+		return errors2.AlreadyExists(err)
+	case errors.Is(err, ErrConflict):
+		return errors2.Aborted(err)
+	case errors.Is(err, ErrDeleted), errors.Is(err, ErrNotDeleted):
+		return errors2.FailedPrecondition(err)
+	}
+	return err
+}
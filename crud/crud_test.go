@@ -0,0 +1,300 @@
+package crud
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	_ "google.golang.org/protobuf/types/known/emptypb"
+	_ "google.golang.org/protobuf/types/known/fieldmaskpb"
+	_ "google.golang.org/protobuf/types/known/timestamppb"
+
+	"go.linka.cloud/grpc/errors"
+)
+
+func field(name string, n int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string, repeated bool) *descriptorpb.FieldDescriptorProto {
+	label := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	if repeated {
+		label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+	}
+	f := &descriptorpb.FieldDescriptorProto{Name: proto.String(name), Number: proto.Int32(n), Type: typ.Enum(), Label: label.Enum()}
+	if typeName != "" {
+		f.TypeName = proto.String(typeName)
+	}
+	return f
+}
+
+func str(name string, n int32) *descriptorpb.FieldDescriptorProto {
+	return field(name, n, descriptorpb.FieldDescriptorProto_TYPE_STRING, "", false)
+}
+
+func message(name string, fields ...*descriptorpb.FieldDescriptorProto) *descriptorpb.DescriptorProto {
+	return &descriptorpb.DescriptorProto{Name: proto.String(name), Field: fields}
+}
+
+func method(name, in, out string) *descriptorpb.MethodDescriptorProto {
+	return &descriptorpb.MethodDescriptorProto{Name: proto.String(name), InputType: proto.String(in), OutputType: proto.String(out)}
+}
+
+func testService(t *testing.T) protoreflect.ServiceDescriptor {
+	const book = ".crud.test.Book"
+	msg := descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       proto.String("crud/test.proto"),
+		Package:    proto.String("crud.test"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/field_mask.proto", "google/protobuf/empty.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{
+			message("Book", str("id", 1), str("title", 2), str("author", 3)),
+			message("CreateBookRequest", field("book", 1, msg, book, false), str("book_id", 2)),
+			message("GetBookRequest", str("id", 1)),
+			message("ListBooksRequest", field("page_size", 1, descriptorpb.FieldDescriptorProto_TYPE_INT32, "", false), str("page_token", 2)),
+			message("ListBooksResponse", field("books", 1, msg, book, true), str("next_page_token", 2)),
+			message("UpdateBookRequest", field("book", 1, msg, book, false), field("update_mask", 2, msg, ".google.protobuf.FieldMask", false)),
+			message("DeleteBookRequest", str("id", 1)),
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Books"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				method("CreateBook", ".crud.test.CreateBookRequest", book),
+				method("GetBook", ".crud.test.GetBookRequest", book),
+				method("ListBooks", ".crud.test.ListBooksRequest", ".crud.test.ListBooksResponse"),
+				method("UpdateBook", ".crud.test.UpdateBookRequest", book),
+				method("DeleteBook", ".crud.test.DeleteBookRequest", ".google.protobuf.Empty"),
+				method("ArchiveBook", ".crud.test.GetBookRequest", book),
+			},
+		}},
+	}, protoregistry.GlobalFiles)
+	require.NoError(t, err)
+	return fd.Services().Get(0)
+}
+
+func notesService(t *testing.T) protoreflect.ServiceDescriptor {
+	const note = ".crud.notes.Note"
+	msg := descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       proto.String("crud/notes.proto"),
+		Package:    proto.String("crud.notes"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/field_mask.proto", "google/protobuf/timestamp.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{
+			message("Note",
+				str("id", 1),
+				str("text", 2),
+				str("etag", 3),
+				field("version", 4, descriptorpb.FieldDescriptorProto_TYPE_INT64, "", false),
+				field("delete_time", 5, msg, ".google.protobuf.Timestamp", false),
+			),
+			message("CreateNoteRequest", field("note", 1, msg, note, false)),
+			message("ListNotesRequest", field("show_deleted", 1, descriptorpb.FieldDescriptorProto_TYPE_BOOL, "", false)),
+			message("ListNotesResponse", field("notes", 1, msg, note, true)),
+			message("UpdateNoteRequest", field("note", 1, msg, note, false), field("update_mask", 2, msg, ".google.protobuf.FieldMask", false)),
+			message("DeleteNoteRequest", str("id", 1), str("etag", 2)),
+			message("UndeleteNoteRequest", str("id", 1)),
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Notes"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				method("CreateNote", ".crud.notes.CreateNoteRequest", note),
+				method("ListNotes", ".crud.notes.ListNotesRequest", ".crud.notes.ListNotesResponse"),
+				method("UpdateNote", ".crud.notes.UpdateNoteRequest", note),
+				method("DeleteNote", ".crud.notes.DeleteNoteRequest", note),
+				method("UndeleteNote", ".crud.notes.UndeleteNoteRequest", note),
+			},
+		}},
+	}, protoregistry.GlobalFiles)
+	require.NoError(t, err)
+	return fd.Services().Get(0)
+}
+
+type caller struct {
+	t    *testing.T
+	sd   protoreflect.ServiceDescriptor
+	desc *grpc.ServiceDesc
+	impl interface{}
+}
+
+// call invokes the method with the json encoded request and returns the json decoded response
+func (c *caller) call(name, req string) (map[string]interface{}, error) {
+	m := c.sd.Methods().ByName(protoreflect.Name(name))
+	require.NotNil(c.t, m)
+	for _, v := range c.desc.Methods {
+		if v.MethodName != name {
+			continue
+		}
+		res, err := v.Handler(c.impl, context.Background(), func(in interface{}) error {
+			return protojson.Unmarshal([]byte(req), in.(proto.Message))
+		}, nil)
+		if err != nil {
+			return nil, err
+		}
+		b, err := protojson.Marshal(res.(proto.Message))
+		require.NoError(c.t, err)
+		out := make(map[string]interface{})
+		require.NoError(c.t, json.Unmarshal(b, &out))
+		return out, nil
+	}
+	c.t.Fatalf("method %s not found", name)
+	return nil, nil
+}
+
+func TestCRUD(t *testing.T) {
+	sd := testService(t)
+	n := 0
+	desc, impl, err := newServiceDesc(sd, NewMemory(), newOptions(WithIDGenerator(func() string {
+		n++
+		return string(rune('0' + n))
+	})))
+	require.NoError(t, err)
+	assert.Equal(t, "crud.test.Books", desc.ServiceName)
+	c := &caller{t: t, sd: sd, desc: desc, impl: impl}
+
+	res, err := c.call("CreateBook", `{"book": {"title": "Dune", "author": "Herbert"}}`)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"id": "1", "title": "Dune", "author": "Herbert"}, res)
+	res, err = c.call("CreateBook", `{"book": {"title": "Foundation"}, "book_id": "asimov-1"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "asimov-1", res["id"])
+	_, err = c.call("CreateBook", `{"book": {"id": "asimov-1"}}`)
+	assert.True(t, errors.IsAlreadyExists(err))
+
+	res, err = c.call("GetBook", `{"id": "1"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "Dune", res["title"])
+	_, err = c.call("GetBook", `{"id": "2"}`)
+	assert.True(t, errors.IsNotFound(err))
+	_, err = c.call("GetBook", `{}`)
+	assert.True(t, errors.IsInvalidArgument(err))
+
+	res, err = c.call("UpdateBook", `{"book": {"id": "1", "title": "Dune Messiah", "author": "ignored"}, "update_mask": "title"}`)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"id": "1", "title": "Dune Messiah", "author": "Herbert"}, res)
+	_, err = c.call("UpdateBook", `{"book": {"id": "1"}, "update_mask": "unknown"}`)
+	assert.True(t, errors.IsInvalidArgument(err))
+	res, err = c.call("UpdateBook", `{"book": {"id": "1", "title": "Dune"}}`)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"id": "1", "title": "Dune"}, res)
+
+	res, err = c.call("ListBooks", `{"page_size": 1}`)
+	require.NoError(t, err)
+	require.Len(t, res["books"], 1)
+	assert.Equal(t, "1", res["books"].([]interface{})[0].(map[string]interface{})["id"])
+	require.NotEmpty(t, res["nextPageToken"])
+	res, err = c.call("ListBooks", `{"page_size": 1, "page_token": "`+res["nextPageToken"].(string)+`"}`)
+	require.NoError(t, err)
+	require.Len(t, res["books"], 1)
+	assert.Equal(t, "asimov-1", res["books"].([]interface{})[0].(map[string]interface{})["id"])
+	assert.Nil(t, res["nextPageToken"])
+
+	_, err = c.call("DeleteBook", `{"id": "1"}`)
+	require.NoError(t, err)
+	_, err = c.call("DeleteBook", `{"id": "1"}`)
+	assert.True(t, errors.IsNotFound(err))
+
+	_, err = c.call("ArchiveBook", `{"id": "asimov-1"}`)
+	assert.True(t, errors.IsUnimplemented(err))
+}
+
+func TestApplyMask(t *testing.T) {
+	sd := testService(t)
+	md := sd.ParentFile().Messages().ByName("UpdateBookRequest")
+	dst, src := messageType(md).New(), messageType(md).New()
+	require.NoError(t, protojson.Unmarshal([]byte(`{"book": {"id": "1", "title": "a"}}`), dst.Interface()))
+	require.NoError(t, protojson.Unmarshal([]byte(`{"book": {"title": "b", "author": "c"}}`), src.Interface()))
+	require.NoError(t, applyMask(dst, src, []string{"book.title", "book.author"}))
+	b, err := protojson.Marshal(dst.Interface())
+	require.NoError(t, err)
+	out := make(map[string]interface{})
+	require.NoError(t, json.Unmarshal(b, &out))
+	assert.Equal(t, map[string]interface{}{"book": map[string]interface{}{"id": "1", "title": "b", "author": "c"}}, out)
+	assert.Error(t, applyMask(dst, src, []string{"book.title.length"}))
+}
+
+func TestOptimisticLockingAndSoftDelete(t *testing.T) {
+	sd := notesService(t)
+	desc, impl, err := newServiceDesc(sd, NewMemory(), newOptions(WithIDGenerator(func() string {
+		return "n"
+	})))
+	require.NoError(t, err)
+	c := &caller{t: t, sd: sd, desc: desc, impl: impl}
+
+	res, err := c.call("CreateNote", `{"note": {"text": "a", "version": 42}}`)
+	require.NoError(t, err)
+	assert.Equal(t, "1", res["version"])
+	etag := res["etag"].(string)
+	require.NotEmpty(t, etag)
+
+	// stale etag and version
+	_, err = c.call("UpdateNote", `{"note": {"id": "n", "text": "b", "etag": "stale"}}`)
+	assert.True(t, errors.IsAborted(err))
+	_, err = c.call("UpdateNote", `{"note": {"id": "n", "text": "b", "version": 2}}`)
+	assert.True(t, errors.IsAborted(err))
+
+	res, err = c.call("UpdateNote", `{"note": {"id": "n", "text": "b", "etag": "`+etag+`", "version": 1}}`)
+	require.NoError(t, err)
+	assert.Equal(t, "2", res["version"])
+	assert.NotEqual(t, etag, res["etag"])
+	_, err = c.call("DeleteNote", `{"id": "n", "etag": "`+etag+`"}`)
+	assert.True(t, errors.IsAborted(err))
+	etag = res["etag"].(string)
+
+	res, err = c.call("DeleteNote", `{"id": "n", "etag": "`+etag+`"}`)
+	require.NoError(t, err)
+	assert.NotEmpty(t, res["deleteTime"])
+	assert.Equal(t, "3", res["version"])
+
+	_, err = c.call("UpdateNote", `{"note": {"id": "n", "text": "c"}}`)
+	assert.True(t, errors.IsFailedPrecondition(err))
+	_, err = c.call("DeleteNote", `{"id": "n"}`)
+	assert.True(t, errors.IsFailedPrecondition(err))
+
+	res, err = c.call("ListNotes", `{}`)
+	require.NoError(t, err)
+	assert.Nil(t, res["notes"])
+	res, err = c.call("ListNotes", `{"show_deleted": true}`)
+	require.NoError(t, err)
+	assert.Len(t, res["notes"], 1)
+
+	res, err = c.call("UndeleteNote", `{"id": "n"}`)
+	require.NoError(t, err)
+	assert.Nil(t, res["deleteTime"])
+	_, err = c.call("UndeleteNote", `{"id": "n"}`)
+	assert.True(t, errors.IsFailedPrecondition(err))
+}
+
+func TestConventions(t *testing.T) {
+	sd := notesService(t)
+	m := messageType(sd.ParentFile().Messages().ByName("Note")).New().Interface()
+	require.NoError(t, protojson.Unmarshal([]byte(`{"id": "1", "text": "a"}`), m))
+	etag, err := ETag(m)
+	require.NoError(t, err)
+	assert.NoError(t, CheckETag(m, ""))
+	assert.NoError(t, CheckETag(m, etag))
+	require.NoError(t, setETag(m.ProtoReflect()))
+	// the etag field is not part of the checksum
+	assert.NoError(t, CheckETag(m, etag))
+
+	assert.False(t, Deleted(m))
+	assert.True(t, SoftDelete(m, time.Now()))
+	assert.True(t, Deleted(m))
+	assert.Equal(t, ErrConflict, CheckETag(m, etag))
+	Undelete(m)
+	assert.False(t, Deleted(m))
+
+	res, err := NewServerInterceptors().UnaryServerInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{}, func(context.Context, interface{}) (interface{}, error) {
+		return nil, fmt.Errorf("save: %w", ErrConflict)
+	})
+	assert.Nil(t, res)
+	assert.True(t, errors.IsAborted(err))
+}
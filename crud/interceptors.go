@@ -0,0 +1,37 @@
+package crud
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+
+	"go.linka.cloud/grpc/interceptors"
+)
+
+// NewServerInterceptors returns interceptors mapping the crud errors returned by hand written handlers,
+// e.g. using a gorm store with CheckETag and SoftDelete, to the same status codes as the crud layer:
+// NotFound, AlreadyExists, Aborted for ErrConflict and FailedPrecondition for ErrDeleted and ErrNotDeleted
+func NewServerInterceptors() interceptors.ServerInterceptors {
+	return errorsInterceptors{}
+}
+
+type errorsInterceptors struct{}
+
+func (errorsInterceptors) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		res, err := handler(ctx, req)
+		if err != nil {
+			return nil, storeError(err)
+		}
+		return res, nil
+	}
+}
+
+func (errorsInterceptors) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := handler(srv, ss); err != nil {
+			return storeError(err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,38 @@
+package crud
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// applyMask copies the fields listed in paths from src to dst.
+// The paths are dot separated field names, a field unset in src is cleared in dst.
+func applyMask(dst, src protoreflect.Message, paths []string) error {
+	for _, p := range paths {
+		if err := copyPath(dst, src, strings.Split(p, ".")); err != nil {
+			return fmt.Errorf("invalid field mask path %q: %w", p, err)
+		}
+	}
+	return nil
+}
+
+func copyPath(dst, src protoreflect.Message, path []string) error {
+	fd := src.Descriptor().Fields().ByName(protoreflect.Name(path[0]))
+	if fd == nil {
+		return fmt.Errorf("unknown field %s", path[0])
+	}
+	if len(path) == 1 {
+		if src.Has(fd) {
+			dst.Set(fd, src.Get(fd))
+		} else {
+			dst.Clear(fd)
+		}
+		return nil
+	}
+	if fd.Message() == nil || fd.IsList() || fd.IsMap() {
+		return fmt.Errorf("%s is not a message field", path[0])
+	}
+	return copyPath(dst.Mutable(fd).Message(), src.Get(fd).Message(), path[1:])
+}
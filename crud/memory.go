@@ -0,0 +1,137 @@
+package crud
+
+import (
+	"context"
+	"encoding/base64"
+	"sort"
+	"strconv"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"go.linka.cloud/grpc/errors"
+)
+
+// NewMemory returns a Store keeping the resources in memory, for tests and prototypes.
+// The resources are listed in id order, the filter and order by options are not supported.
+// It implements CompareAndSwapper.
+func NewMemory() Store {
+	return &memory{m: make(map[protoreflect.FullName]map[string]proto.Message)}
+}
+
+type memory struct {
+	mu sync.RWMutex
+	m  map[protoreflect.FullName]map[string]proto.Message
+}
+
+func (s *memory) table(name protoreflect.FullName) map[string]proto.Message {
+	t, ok := s.m[name]
+	if !ok {
+		t = make(map[string]proto.Message)
+		s.m[name] = t
+	}
+	return t
+}
+
+func (s *memory) Create(_ context.Context, id string, m proto.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.table(m.ProtoReflect().Descriptor().FullName())
+	if _, ok := t[id]; ok {
+		return ErrAlreadyExists
+	}
+	t[id] = proto.Clone(m)
+	return nil
+}
+
+func (s *memory) Get(_ context.Context, id string, m proto.Message) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.m[m.ProtoReflect().Descriptor().FullName()][id]
+	if !ok {
+		return ErrNotFound
+	}
+	proto.Reset(m)
+	proto.Merge(m, v)
+	return nil
+}
+
+func (s *memory) List(_ context.Context, t protoreflect.MessageType, o ListOptions) ([]proto.Message, string, error) {
+	if o.Filter != "" || o.OrderBy != "" {
+		return nil, "", errors.InvalidArgumentf("filter and order_by are not supported")
+	}
+	offset := 0
+	if o.PageToken != "" {
+		b, err := base64.RawURLEncoding.DecodeString(o.PageToken)
+		if err == nil {
+			offset, err = strconv.Atoi(string(b))
+		}
+		if err != nil || offset < 0 {
+			return nil, "", errors.InvalidArgumentf("invalid page token")
+		}
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	table := s.m[t.Descriptor().FullName()]
+	ids := make([]string, 0, len(table))
+	for k, v := range table {
+		if o.ShowDeleted || !Deleted(v) {
+			ids = append(ids, k)
+		}
+	}
+	sort.Strings(ids)
+	if offset > len(ids) {
+		offset = len(ids)
+	}
+	end := len(ids)
+	if o.PageSize > 0 && offset+o.PageSize < end {
+		end = offset + o.PageSize
+	}
+	out := make([]proto.Message, 0, end-offset)
+	for _, id := range ids[offset:end] {
+		out = append(out, proto.Clone(table[id]))
+	}
+	var next string
+	if end < len(ids) {
+		next = base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(end)))
+	}
+	return out, next, nil
+}
+
+func (s *memory) Update(_ context.Context, id string, m proto.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.table(m.ProtoReflect().Descriptor().FullName())
+	if _, ok := t[id]; !ok {
+		return ErrNotFound
+	}
+	t[id] = proto.Clone(m)
+	return nil
+}
+
+func (s *memory) CompareAndSwap(_ context.Context, id string, old, m proto.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.table(m.ProtoReflect().Descriptor().FullName())
+	v, ok := t[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if !proto.Equal(v, old) {
+		return ErrConflict
+	}
+	t[id] = proto.Clone(m)
+	return nil
+}
+
+func (s *memory) Delete(_ context.Context, t protoreflect.MessageType, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	table := s.m[t.Descriptor().FullName()]
+	if _, ok := table[id]; !ok {
+		return ErrNotFound
+	}
+	delete(table, id)
+	return nil
+}
@@ -0,0 +1,55 @@
+package crud
+
+import (
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPageSize = 50
+	defaultMaxSize  = 1000
+)
+
+type Option func(o *options)
+
+// WithIDField sets the name of the resources id field, defaults to "id" or "name", whichever exists.
+// The same field name is used in the Get and Delete requests.
+func WithIDField(name string) Option {
+	return func(o *options) {
+		o.idField = name
+	}
+}
+
+// WithIDGenerator sets the function generating the ids of the created resources
+// when the request does not provide one, defaults to random uuids
+func WithIDGenerator(fn func() string) Option {
+	return func(o *options) {
+		o.newID = fn
+	}
+}
+
+// WithPageSize sets the default and maximum List page sizes, defaults to 50 and 1000
+func WithPageSize(def, max int) Option {
+	return func(o *options) {
+		o.pageSize = def
+		o.maxPageSize = max
+	}
+}
+
+type options struct {
+	idField     string
+	newID       func() string
+	pageSize    int
+	maxPageSize int
+}
+
+func newOptions(opts ...Option) options {
+	o := options{
+		newID:       func() string { return uuid.New().String() },
+		pageSize:    defaultPageSize,
+		maxPageSize: defaultMaxSize,
+	}
+	for _, v := range opts {
+		v(&o)
+	}
+	return o
+}
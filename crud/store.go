@@ -0,0 +1,56 @@
+package crud
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+var (
+	ErrNotFound      = errors.New("crud: resource not found")
+	ErrAlreadyExists = errors.New("crud: resource already exists")
+	// ErrConflict is returned when the resource was modified concurrently, e.g. its etag or version does not match
+	ErrConflict = errors.New("crud: resource was modified concurrently")
+	// ErrDeleted is returned when modifying a soft-deleted resource
+	ErrDeleted = errors.New("crud: resource is deleted")
+	// ErrNotDeleted is returned when undeleting a resource which is not deleted
+	ErrNotDeleted = errors.New("crud: resource is not deleted")
+)
+
+// ListOptions are the List request parameters, see https://google.aip.dev/132
+type ListOptions struct {
+	PageSize  int
+	PageToken string
+	// Filter is an https://google.aip.dev/160 filter, it can be compiled to a SQL condition with filter.Compile
+	Filter  string
+	OrderBy string
+	// ShowDeleted includes the soft-deleted resources, see Deleted
+	ShowDeleted bool
+}
+
+// Store persists the resources, it is implemented on top of a database, e.g. with gorm.
+// The resources are identified by their type and their id.
+type Store interface {
+	// Create stores m, it returns ErrAlreadyExists if a resource with the same id exists
+	Create(ctx context.Context, id string, m proto.Message) error
+	// Get loads the resource into m, it returns ErrNotFound if it does not exist
+	Get(ctx context.Context, id string, m proto.Message) error
+	// List returns a page of resources of type t and the token of the next page, empty if it is the last one
+	List(ctx context.Context, t protoreflect.MessageType, o ListOptions) ([]proto.Message, string, error)
+	// Update replaces the stored resource by m, it returns ErrNotFound if it does not exist
+	Update(ctx context.Context, id string, m proto.Message) error
+	// Delete removes the resource, it returns ErrNotFound if it does not exist
+	Delete(ctx context.Context, t protoreflect.MessageType, id string) error
+}
+
+// CompareAndSwapper is implemented by the stores able to update a resource only if it was not modified
+// since it was read, e.g. with a gorm Where("etag = ?", old.Etag) clause.
+// When the store implements it, the crud layer uses it instead of Update so that optimistic locking
+// holds across instances.
+type CompareAndSwapper interface {
+	// CompareAndSwap replaces the stored resource by m if it is still equal to old,
+	// it returns ErrConflict otherwise, or ErrNotFound if it does not exist
+	CompareAndSwap(ctx context.Context, id string, old, m proto.Message) error
+}
@@ -0,0 +1,155 @@
+// Package crypto provides envelope encryption helpers: each value is encrypted with its own
+// data key (AES-256-GCM), which is in turn encrypted (wrapped) by a key provider such as
+// a KMS or a local key ring. Only the wrapped data key is stored alongside the ciphertext.
+package crypto
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	version = 1
+	keySize = 32
+)
+
+var (
+	// ErrInvalidCiphertext is returned when the ciphertext is malformed or was tampered with
+	ErrInvalidCiphertext = errors.New("crypto: invalid ciphertext")
+	// ErrUnknownKey is returned when the key provider does not know the key encryption key
+	ErrUnknownKey = errors.New("crypto: unknown key")
+)
+
+// KeyProvider wraps and unwraps the data keys
+type KeyProvider interface {
+	// WrapKey encrypts the data key with the current key encryption key and returns its id
+	WrapKey(ctx context.Context, key []byte) (keyID string, wrapped []byte, err error)
+	// UnwrapKey decrypts the data key wrapped with the key encryption key identified by keyID
+	UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// Envelope encrypts and decrypts values using a KeyProvider
+type Envelope struct {
+	p KeyProvider
+}
+
+// New returns an Envelope using p to protect the data keys
+func New(p KeyProvider) *Envelope {
+	return &Envelope{p: p}
+}
+
+// Encrypt encrypts plaintext. The additional data, e.g. the record id, is authenticated but not
+// encrypted: the same one must be given to Decrypt, which prevents copying a value to another record.
+//
+// The format is: version (1 byte) | key id length (2 bytes) | key id | wrapped key length (2 bytes) | wrapped key | nonce | ciphertext
+func (e *Envelope) Encrypt(ctx context.Context, plaintext, additionalData []byte) ([]byte, error) {
+	key := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	id, wrapped, err := e.p.WrapKey(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("crypto: wrap key: %w", err)
+	}
+	if len(id) > 0xffff || len(wrapped) > 0xffff {
+		return nil, errors.New("crypto: wrapped key too long")
+	}
+	b := make([]byte, 0, 5+len(id)+len(wrapped)+len(plaintext)+64)
+	b = append(b, version)
+	b = appendBytes(b, []byte(id))
+	b = appendBytes(b, wrapped)
+	return seal(b, key, plaintext, additionalData)
+}
+
+// Decrypt decrypts a value returned by Encrypt
+func (e *Envelope) Decrypt(ctx context.Context, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) == 0 || ciphertext[0] != version {
+		return nil, ErrInvalidCiphertext
+	}
+	id, rest, ok := readBytes(ciphertext[1:])
+	if !ok {
+		return nil, ErrInvalidCiphertext
+	}
+	wrapped, rest, ok := readBytes(rest)
+	if !ok {
+		return nil, ErrInvalidCiphertext
+	}
+	key, err := e.p.UnwrapKey(ctx, string(id), wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("crypto: unwrap key: %w", err)
+	}
+	return open(key, rest, additionalData)
+}
+
+// KeyID returns the id of the key encryption key which protects ciphertext, e.g. to find the values to re-encrypt after a rotation
+func KeyID(ciphertext []byte) (string, error) {
+	if len(ciphertext) == 0 || ciphertext[0] != version {
+		return "", ErrInvalidCiphertext
+	}
+	id, _, ok := readBytes(ciphertext[1:])
+	if !ok {
+		return "", ErrInvalidCiphertext
+	}
+	return string(id), nil
+}
+
+func appendBytes(b, v []byte) []byte {
+	var l [2]byte
+	binary.BigEndian.PutUint16(l[:], uint16(len(v)))
+	return append(append(b, l[:]...), v...)
+}
+
+func readBytes(b []byte) ([]byte, []byte, bool) {
+	if len(b) < 2 {
+		return nil, nil, false
+	}
+	l := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+l {
+		return nil, nil, false
+	}
+	return b[2 : 2+l], b[2+l:], true
+}
+
+// seal appends the nonce and the AES-GCM encrypted plaintext to dst
+func seal(dst, key, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	dst = append(dst, nonce...)
+	return aead.Seal(dst, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts the nonce prefixed ciphertext returned by seal
+func open(key, b, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < aead.NonceSize() {
+		return nil, ErrInvalidCiphertext
+	}
+	out, err := aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], additionalData)
+	if err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+	return out, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	c, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(c)
+}
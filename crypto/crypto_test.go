@@ -0,0 +1,115 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvelope(t *testing.T) {
+	ctx := context.Background()
+	k1, k2 := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)
+	p, err := NewLocal("1", map[string][]byte{"1": k1})
+	require.NoError(t, err)
+	e := New(p)
+
+	b, err := e.Encrypt(ctx, []byte("secret"), []byte("id-1"))
+	require.NoError(t, err)
+	assert.False(t, bytes.Contains(b, []byte("secret")))
+	id, err := KeyID(b)
+	require.NoError(t, err)
+	assert.Equal(t, "1", id)
+
+	out, err := e.Decrypt(ctx, b, []byte("id-1"))
+	require.NoError(t, err)
+	assert.Equal(t, "secret", string(out))
+
+	_, err = e.Decrypt(ctx, b, []byte("id-2"))
+	assert.True(t, errors.Is(err, ErrInvalidCiphertext))
+	b[len(b)-1] ^= 1
+	_, err = e.Decrypt(ctx, b, []byte("id-1"))
+	assert.True(t, errors.Is(err, ErrInvalidCiphertext))
+	b[len(b)-1] ^= 1
+	_, err = e.Decrypt(ctx, b[:10], []byte("id-1"))
+	assert.Error(t, err)
+
+	// rotation: the old values are still readable
+	p, err = NewLocal("2", map[string][]byte{"1": k1, "2": k2})
+	require.NoError(t, err)
+	e = New(p)
+	out, err = e.Decrypt(ctx, b, []byte("id-1"))
+	require.NoError(t, err)
+	assert.Equal(t, "secret", string(out))
+	b, err = e.Encrypt(ctx, []byte("secret"), nil)
+	require.NoError(t, err)
+	id, _ = KeyID(b)
+	assert.Equal(t, "2", id)
+
+	p, err = NewLocal("1", map[string][]byte{"1": k1})
+	require.NoError(t, err)
+	_, err = New(p).Decrypt(ctx, b, nil)
+	assert.True(t, errors.Is(err, ErrUnknownKey))
+
+	_, err = NewLocal("1", map[string][]byte{"1": []byte("short")})
+	assert.Error(t, err)
+	_, err = NewLocal("2", map[string][]byte{"1": k1})
+	assert.True(t, errors.Is(err, ErrUnknownKey))
+}
+
+func TestLocalFromEnv(t *testing.T) {
+	os.Setenv("TEST_CRYPTO_KEYS", "b:"+base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32))+",a:"+base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32)))
+	defer os.Unsetenv("TEST_CRYPTO_KEYS")
+	p, err := NewLocalFromEnv("TEST_CRYPTO_KEYS")
+	require.NoError(t, err)
+	id, _, err := p.WrapKey(context.Background(), make([]byte, 32))
+	require.NoError(t, err)
+	assert.Equal(t, "b", id)
+	_, err = NewLocalFromEnv("TEST_CRYPTO_MISSING")
+	assert.Error(t, err)
+}
+
+func TestKMS(t *testing.T) {
+	xor := func(_ context.Context, keyID string, b []byte) ([]byte, error) {
+		if keyID != "kms-key" {
+			return nil, ErrUnknownKey
+		}
+		out := make([]byte, len(b))
+		for i := range b {
+			out[i] = b[i] ^ 0x42
+		}
+		return out, nil
+	}
+	e := New(NewKMS(KMSFuncs{EncryptFunc: xor, DecryptFunc: xor}, "kms-key"))
+	b, err := e.Encrypt(context.Background(), []byte("secret"), nil)
+	require.NoError(t, err)
+	out, err := e.Decrypt(context.Background(), b, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "secret", string(out))
+}
+
+func TestSQL(t *testing.T) {
+	var s String
+	_, err := String("secret").Value()
+	assert.Error(t, err)
+
+	p, err := NewLocal("1", map[string][]byte{"1": bytes.Repeat([]byte{1}, 32)})
+	require.NoError(t, err)
+	SetDefault(New(p))
+	defer SetDefault(nil)
+
+	v, err := String("secret").Value()
+	require.NoError(t, err)
+	require.NoError(t, s.Scan(v))
+	assert.Equal(t, String("secret"), s)
+
+	var b Bytes
+	require.NoError(t, b.Scan(nil))
+	assert.Nil(t, b)
+	assert.Error(t, b.Scan(42))
+}
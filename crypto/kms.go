@@ -0,0 +1,53 @@
+package crypto
+
+import (
+	"context"
+)
+
+// KMS is the subset of a key management service client used to wrap the data keys,
+// e.g. AWS KMS, Google Cloud KMS or Vault transit, or an age recipient / identity pair.
+// The clients are adapted to this interface so that the package does not depend on their sdk.
+type KMS interface {
+	// Encrypt encrypts plaintext with the key keyID
+	Encrypt(ctx context.Context, keyID string, plaintext []byte) ([]byte, error)
+	// Decrypt decrypts ciphertext encrypted with the key keyID
+	Decrypt(ctx context.Context, keyID string, ciphertext []byte) ([]byte, error)
+}
+
+// KMSFuncs is a KMS implemented by functions
+type KMSFuncs struct {
+	EncryptFunc func(ctx context.Context, keyID string, plaintext []byte) ([]byte, error)
+	DecryptFunc func(ctx context.Context, keyID string, ciphertext []byte) ([]byte, error)
+}
+
+func (f KMSFuncs) Encrypt(ctx context.Context, keyID string, plaintext []byte) ([]byte, error) {
+	return f.EncryptFunc(ctx, keyID, plaintext)
+}
+
+func (f KMSFuncs) Decrypt(ctx context.Context, keyID string, ciphertext []byte) ([]byte, error) {
+	return f.DecryptFunc(ctx, keyID, ciphertext)
+}
+
+type kms struct {
+	c     KMS
+	keyID string
+}
+
+// NewKMS returns a KeyProvider wrapping the data keys with the KMS key keyID.
+// The values encrypted with a previous key are decrypted with the key they were encrypted with,
+// so rotating the key only requires changing keyID.
+func NewKMS(c KMS, keyID string) KeyProvider {
+	return &kms{c: c, keyID: keyID}
+}
+
+func (k *kms) WrapKey(ctx context.Context, key []byte) (string, []byte, error) {
+	b, err := k.c.Encrypt(ctx, k.keyID, key)
+	if err != nil {
+		return "", nil, err
+	}
+	return k.keyID, b, nil
+}
+
+func (k *kms) UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	return k.c.Decrypt(ctx, keyID, wrapped)
+}
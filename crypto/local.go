@@ -0,0 +1,74 @@
+package crypto
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+)
+
+type local struct {
+	current string
+	keys    map[string][]byte
+}
+
+// NewLocal returns a KeyProvider wrapping the data keys with the 32 bytes keys, new data keys are wrapped with current.
+// The previous keys are kept to decrypt the existing values after a rotation.
+// It is meant for development and for environments without KMS, the keys must be provisioned as secrets.
+func NewLocal(current string, keys map[string][]byte) (KeyProvider, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, current)
+	}
+	l := &local{current: current, keys: make(map[string][]byte, len(keys))}
+	for k, v := range keys {
+		if len(v) != keySize {
+			return nil, fmt.Errorf("crypto: key %q: invalid size %d, expected %d", k, len(v), keySize)
+		}
+		l.keys[k] = append([]byte(nil), v...)
+	}
+	return l, nil
+}
+
+// NewLocalFromEnv returns a local KeyProvider reading the keys from the env variable, formatted as a comma separated
+// list of id:base64-key, e.g. "2024:...,2023:...". The first key is the current one.
+func NewLocalFromEnv(env string) (KeyProvider, error) {
+	v := os.Getenv(env)
+	if v == "" {
+		return nil, fmt.Errorf("crypto: %s is not set", env)
+	}
+	var current string
+	keys := make(map[string][]byte)
+	for _, p := range strings.Split(v, ",") {
+		i := strings.Index(p, ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("crypto: %s: invalid key format", env)
+		}
+		k, err := base64.StdEncoding.DecodeString(strings.TrimSpace(p[i+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("crypto: %s: %w", env, err)
+		}
+		id := strings.TrimSpace(p[:i])
+		if current == "" {
+			current = id
+		}
+		keys[id] = k
+	}
+	return NewLocal(current, keys)
+}
+
+func (l *local) WrapKey(_ context.Context, key []byte) (string, []byte, error) {
+	b, err := seal(nil, l.keys[l.current], key, []byte(l.current))
+	if err != nil {
+		return "", nil, err
+	}
+	return l.current, b, nil
+}
+
+func (l *local) UnwrapKey(_ context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	k, ok := l.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, keyID)
+	}
+	return open(k, wrapped, []byte(keyID))
+}
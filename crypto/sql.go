@@ -0,0 +1,88 @@
+package crypto
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	defaultMu sync.RWMutex
+	defaultE  *Envelope
+)
+
+// SetDefault sets the Envelope used by the encrypted column types
+func SetDefault(e *Envelope) {
+	defaultMu.Lock()
+	defaultE = e
+	defaultMu.Unlock()
+}
+
+func getDefault() (*Envelope, error) {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	if defaultE == nil {
+		return nil, errors.New("crypto: no default envelope, see SetDefault")
+	}
+	return defaultE, nil
+}
+
+// String is a string stored encrypted with the default Envelope: it implements sql.Scanner and driver.Valuer,
+// so that it can be used as a gorm or database/sql field, the column must be a binary one (bytea, blob...).
+// The value is not bound to its record, use Envelope.Encrypt with the record id as additional data for that.
+type String string
+
+// Value implements driver.Valuer
+func (s String) Value() (driver.Value, error) {
+	return Bytes(s).Value()
+}
+
+// Scan implements sql.Scanner
+func (s *String) Scan(src interface{}) error {
+	var b Bytes
+	if err := b.Scan(src); err != nil {
+		return err
+	}
+	*s = String(b)
+	return nil
+}
+
+// Bytes is a byte slice stored encrypted with the default Envelope, see String
+type Bytes []byte
+
+// Value implements driver.Valuer
+func (b Bytes) Value() (driver.Value, error) {
+	e, err := getDefault()
+	if err != nil {
+		return nil, err
+	}
+	return e.Encrypt(context.Background(), b, nil)
+}
+
+// Scan implements sql.Scanner
+func (b *Bytes) Scan(src interface{}) error {
+	var v []byte
+	switch s := src.(type) {
+	case nil:
+		*b = nil
+		return nil
+	case []byte:
+		v = s
+	case string:
+		v = []byte(s)
+	default:
+		return fmt.Errorf("crypto: cannot scan %T", src)
+	}
+	e, err := getDefault()
+	if err != nil {
+		return err
+	}
+	out, err := e.Decrypt(context.Background(), v, nil)
+	if err != nil {
+		return err
+	}
+	*b = out
+	return nil
+}
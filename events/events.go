@@ -0,0 +1,237 @@
+// Package events is the bus of the service lifecycle, connection, registry and health events
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Type is the kind of Event
+type Type int
+
+const (
+	Starting Type = iota
+	Started
+	Stopping
+	Stopped
+	ConnOpened
+	ConnClosed
+	Registered
+	Deregistered
+	RegistrationFailed
+	HealthChanged
+	TaskPanicked
+	LeaderChanged
+	AddressChanged
+)
+
+func (t Type) String() string {
+	switch t {
+	case Starting:
+		return "Starting"
+	case Started:
+		return "Started"
+	case Stopping:
+		return "Stopping"
+	case Stopped:
+		return "Stopped"
+	case ConnOpened:
+		return "ConnOpened"
+	case ConnClosed:
+		return "ConnClosed"
+	case Registered:
+		return "Registered"
+	case Deregistered:
+		return "Deregistered"
+	case RegistrationFailed:
+		return "RegistrationFailed"
+	case HealthChanged:
+		return "HealthChanged"
+	case TaskPanicked:
+		return "TaskPanicked"
+	case LeaderChanged:
+		return "LeaderChanged"
+	case AddressChanged:
+		return "AddressChanged"
+	default:
+		return "Unknown"
+	}
+}
+
+// Event is a framework event, only the fields relevant to its Type are set
+type Event struct {
+	Type Type
+	Time time.Time
+	// Address is the service address for Started events, the registered address for registry events,
+	// the remote address for connection events, the leader identity for LeaderChanged events
+	// and the new advertised address for AddressChanged events
+	Address string
+	// Status is the new health status for HealthChanged events
+	Status string
+	// Previous is the old health status for HealthChanged events and the old address for AddressChanged events
+	Previous string
+	// Task is the name of the task for TaskPanicked events
+	Task string
+	// Panic is the recovered value for TaskPanicked events
+	Panic interface{}
+	// Error is set for RegistrationFailed events
+	Error error
+}
+
+// Listener receives the events, it is called from a goroutine dedicated to the subscription
+// so it may block without delaying the publisher, the events are delivered in order
+type Listener func(e Event)
+
+// Bus dispatches the published events to its subscribers
+type Bus interface {
+	// Publish sends e to the subscribers, the time is set if missing
+	Publish(e Event)
+	// Subscribe registers l for the given types, all the events if none, and returns a function removing it
+	Subscribe(l Listener, types ...Type) (unsubscribe func())
+	// Flush waits for the published events to be delivered
+	Flush(ctx context.Context) error
+}
+
+func NewBus() Bus {
+	return &bus{}
+}
+
+type bus struct {
+	mu   sync.RWMutex
+	subs []*subscription
+}
+
+func (b *bus) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, v := range b.subs {
+		if v.matches(e.Type) {
+			v.push(item{e: e})
+		}
+	}
+}
+
+func (b *bus) Subscribe(l Listener, types ...Type) func() {
+	s := &subscription{fn: l, signal: make(chan struct{}, 1), done: make(chan struct{})}
+	if len(types) != 0 {
+		s.types = make(map[Type]struct{}, len(types))
+		for _, v := range types {
+			s.types[v] = struct{}{}
+		}
+	}
+	go s.run()
+	b.mu.Lock()
+	b.subs = append(b.subs, s)
+	b.mu.Unlock()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			out := make([]*subscription, 0, len(b.subs))
+			for _, v := range b.subs {
+				if v != s {
+					out = append(out, v)
+				}
+			}
+			b.subs = out
+			b.mu.Unlock()
+			close(s.done)
+		})
+	}
+}
+
+func (b *bus) Flush(ctx context.Context) error {
+	b.mu.RLock()
+	subs := b.subs
+	b.mu.RUnlock()
+	for _, v := range subs {
+		if err := v.flush(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type subscription struct {
+	fn    Listener
+	types map[Type]struct{}
+
+	mu     sync.Mutex
+	queue  []item
+	signal chan struct{}
+	done   chan struct{}
+}
+
+// item is a queued event, or a flush marker closed once the previous events are delivered
+type item struct {
+	e       Event
+	flushed chan struct{}
+}
+
+func (s *subscription) matches(t Type) bool {
+	if s.types == nil {
+		return true
+	}
+	_, ok := s.types[t]
+	return ok
+}
+
+func (s *subscription) push(i item) {
+	s.mu.Lock()
+	s.queue = append(s.queue, i)
+	s.mu.Unlock()
+	select {
+	case s.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (s *subscription) run() {
+	for {
+		select {
+		case <-s.done:
+			s.mu.Lock()
+			for _, v := range s.queue {
+				if v.flushed != nil {
+					close(v.flushed)
+				}
+			}
+			s.queue = nil
+			s.mu.Unlock()
+			return
+		case <-s.signal:
+		}
+		for {
+			s.mu.Lock()
+			if len(s.queue) == 0 {
+				s.mu.Unlock()
+				break
+			}
+			i := s.queue[0]
+			s.queue = s.queue[1:]
+			s.mu.Unlock()
+			if i.flushed != nil {
+				close(i.flushed)
+				continue
+			}
+			s.fn(i.e)
+		}
+	}
+}
+
+func (s *subscription) flush(ctx context.Context) error {
+	flushed := make(chan struct{})
+	s.push(item{flushed: flushed})
+	select {
+	case <-flushed:
+		return nil
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
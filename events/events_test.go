@@ -0,0 +1,64 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBus(t *testing.T) {
+	b := NewBus()
+	var mu sync.Mutex
+	var all, health []Type
+	unsub := b.Subscribe(func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		all = append(all, e.Type)
+	})
+	b.Subscribe(func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		health = append(health, e.Type)
+		assert.False(t, e.Time.IsZero())
+	}, HealthChanged)
+
+	b.Publish(Event{Type: Started})
+	b.Publish(Event{Type: HealthChanged, Status: "NOT_SERVING", Previous: "SERVING"})
+	b.Publish(Event{Type: Stopped})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, b.Flush(ctx))
+	mu.Lock()
+	assert.Equal(t, []Type{Started, HealthChanged, Stopped}, all)
+	assert.Equal(t, []Type{HealthChanged}, health)
+	mu.Unlock()
+
+	unsub()
+	b.Publish(Event{Type: Started})
+	require.NoError(t, b.Flush(ctx))
+	mu.Lock()
+	assert.Len(t, all, 3)
+	mu.Unlock()
+}
+
+func TestBusSlowListener(t *testing.T) {
+	b := NewBus()
+	release := make(chan struct{})
+	b.Subscribe(func(e Event) {
+		<-release
+	})
+	// publishing does not block on the listener
+	for i := 0; i < 100; i++ {
+		b.Publish(Event{Type: ConnOpened})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Error(t, b.Flush(ctx))
+	close(release)
+	require.NoError(t, b.Flush(context.Background()))
+}
@@ -0,0 +1,237 @@
+// Package filter implements the https://google.aip.dev/160 filtering language for the List methods,
+// compiling the filters to SQL conditions that can be passed to gorm's Where or database/sql queries.
+//
+// Only the allowed fields can be filtered on, and the values are always passed as query arguments,
+// so the filters are safe to receive from clients. The supported syntax is:
+//
+//	author = "Herbert" AND (year >= 1965 OR NOT rating < 4)
+//	title = "Dune*"          // prefix match on string fields
+//	title:"dune"             // substring match on string fields
+//	isbn:*                   // the field is set
+//	-archived = true         // negation
+//
+// Functions and global restrictions (a bare value without a field) are not supported.
+// The filters longer than MaxLength or nested deeper than MaxDepth are rejected.
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// MaxLength is the maximum length of a filter in bytes
+	MaxLength = 4096
+	// MaxDepth is the maximum nesting depth of the parenthesized expressions
+	MaxDepth = 64
+)
+
+// Type is the type of a filterable field, it is used to validate and convert the values
+type Type int
+
+const (
+	String Type = iota
+	Int
+	Float
+	Bool
+	// Timestamp values are RFC 3339 strings
+	Timestamp
+)
+
+// Field is a filterable field
+type Field struct {
+	// Column is the SQL column expression, e.g. "books.title"
+	Column string
+	Type   Type
+}
+
+// Fields is the allowlist of the filterable fields by name, e.g. "author.name"
+type Fields map[string]Field
+
+// Error is a filter syntax or validation error, it should be returned to the client as an InvalidArgument
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("invalid filter at position %d: %s", e.Pos, e.Msg)
+}
+
+// GRPCStatus returns the InvalidArgument status of the error
+func (e *Error) GRPCStatus() *status.Status {
+	return status.New(codes.InvalidArgument, e.Error())
+}
+
+func errorf(pos int, format string, args ...interface{}) error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Condition is a compiled filter
+type Condition struct {
+	// SQL is the condition with ? placeholders, it is empty if the filter is empty
+	SQL  string
+	Args []interface{}
+}
+
+// Compile parses the filter and compiles it to a SQL condition, the fields must be in the allowlist
+func Compile(filter string, fields Fields) (Condition, error) {
+	if strings.TrimSpace(filter) == "" {
+		return Condition{}, nil
+	}
+	if len(filter) > MaxLength {
+		return Condition{}, errorf(MaxLength, "filter longer than %d bytes", MaxLength)
+	}
+	tokens, err := lex(filter)
+	if err != nil {
+		return Condition{}, err
+	}
+	p := &parser{tokens: tokens}
+	n, err := p.expression()
+	if err != nil {
+		return Condition{}, err
+	}
+	if t := p.peek(); t.kind != eof {
+		return Condition{}, errorf(t.pos, "unexpected %q", t.value)
+	}
+	c := &compiler{fields: fields}
+	if err := c.node(n); err != nil {
+		return Condition{}, err
+	}
+	return Condition{SQL: c.b.String(), Args: c.args}, nil
+}
+
+type compiler struct {
+	fields Fields
+	b      strings.Builder
+	args   []interface{}
+}
+
+func (c *compiler) node(n node) error {
+	switch n := n.(type) {
+	case *andNode:
+		return c.join(n.nodes, " AND ")
+	case *orNode:
+		return c.join(n.nodes, " OR ")
+	case *notNode:
+		c.b.WriteString("NOT ")
+		return c.group(n.node)
+	case *restriction:
+		return c.restriction(n)
+	}
+	return fmt.Errorf("filter: unexpected node %T", n)
+}
+
+func (c *compiler) join(nodes []node, op string) error {
+	for i, v := range nodes {
+		if i > 0 {
+			c.b.WriteString(op)
+		}
+		if err := c.group(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// group writes the node in parentheses unless it is a restriction or a negation
+func (c *compiler) group(n node) error {
+	switch n.(type) {
+	case *restriction, *notNode:
+		return c.node(n)
+	}
+	c.b.WriteString("(")
+	if err := c.node(n); err != nil {
+		return err
+	}
+	c.b.WriteString(")")
+	return nil
+}
+
+func (c *compiler) restriction(r *restriction) error {
+	f, ok := c.fields[r.field.value]
+	if !ok {
+		return errorf(r.field.pos, "unknown field %q", r.field.value)
+	}
+	op, v := r.op.value, r.value.value
+	if op == ":" && v == "*" && r.value.kind == text {
+		c.b.WriteString(f.Column + " IS NOT NULL")
+		return nil
+	}
+	if f.Type == String {
+		return c.stringRestriction(f, r)
+	}
+	if op == ":" {
+		op = "="
+	}
+	var arg interface{}
+	var err error
+	switch f.Type {
+	case Int:
+		arg, err = strconv.ParseInt(v, 10, 64)
+	case Float:
+		arg, err = strconv.ParseFloat(v, 64)
+	case Bool:
+		arg, err = strconv.ParseBool(v)
+		if err == nil && op != "=" && op != "!=" {
+			return errorf(r.op.pos, "%s is not supported on boolean fields", op)
+		}
+	case Timestamp:
+		arg, err = time.Parse(time.RFC3339Nano, v)
+	default:
+		return errorf(r.field.pos, "unsupported field type %d", f.Type)
+	}
+	if err != nil {
+		return errorf(r.value.pos, "invalid value %q for field %s", v, r.field.value)
+	}
+	c.b.WriteString(f.Column + " " + sqlOp(op) + " ?")
+	c.args = append(c.args, arg)
+	return nil
+}
+
+func (c *compiler) stringRestriction(f Field, r *restriction) error {
+	op, v := r.op.value, r.value.value
+	switch {
+	case op == ":":
+		// has: substring match
+		c.b.WriteString(f.Column + ` LIKE ? ESCAPE '\'`)
+		c.args = append(c.args, "%"+escapeLike(v)+"%")
+	case (op == "=" || op == "!=") && (strings.HasPrefix(v, "*") || strings.HasSuffix(v, "*")):
+		// wildcards
+		like := escapeLike(strings.Trim(v, "*"))
+		if strings.HasPrefix(v, "*") {
+			like = "%" + like
+		}
+		if strings.HasSuffix(v, "*") {
+			like += "%"
+		}
+		if op == "!=" {
+			c.b.WriteString(f.Column + ` NOT LIKE ? ESCAPE '\'`)
+		} else {
+			c.b.WriteString(f.Column + ` LIKE ? ESCAPE '\'`)
+		}
+		c.args = append(c.args, like)
+	default:
+		c.b.WriteString(f.Column + " " + sqlOp(op) + " ?")
+		c.args = append(c.args, v)
+	}
+	return nil
+}
+
+func sqlOp(op string) string {
+	if op == "!=" {
+		return "<>"
+	}
+	return op
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
@@ -0,0 +1,110 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var fields = Fields{
+	"title":       {Column: "title", Type: String},
+	"author.name": {Column: "authors.name", Type: String},
+	"year":        {Column: "year", Type: Int},
+	"rating":      {Column: "rating", Type: Float},
+	"archived":    {Column: "archived", Type: Bool},
+	"create_time": {Column: "created_at", Type: Timestamp},
+}
+
+func TestCompile(t *testing.T) {
+	ts := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		filter string
+		sql    string
+		args   []interface{}
+	}{
+		{filter: "", sql: ""},
+		{filter: `title = "Dune"`, sql: "title = ?", args: []interface{}{"Dune"}},
+		{filter: `author.name=Herbert`, sql: "authors.name = ?", args: []interface{}{"Herbert"}},
+		{filter: `year >= 1965 AND year < 2000`, sql: "year >= ? AND year < ?", args: []interface{}{int64(1965), int64(2000)}},
+		{filter: `year > 1965 rating <= 4.5`, sql: "year > ? AND rating <= ?", args: []interface{}{int64(1965), 4.5}},
+		{
+			// OR binds tighter than AND
+			filter: `archived = false AND year = 1965 OR year = -1`,
+			sql:    "archived = ? AND (year = ? OR year = ?)",
+			args:   []interface{}{false, int64(1965), int64(-1)},
+		},
+		{
+			filter: `(archived = true AND year = 1965) OR NOT title != 'x'`,
+			sql:    "(archived = ? AND year = ?) OR NOT title <> ?",
+			args:   []interface{}{true, int64(1965), "x"},
+		},
+		{filter: `-archived:true`, sql: "NOT archived = ?", args: []interface{}{true}},
+		{filter: `title:"50%_off"`, sql: `title LIKE ? ESCAPE '\'`, args: []interface{}{`%50\%\_off%`}},
+		{filter: `title = "Dune*"`, sql: `title LIKE ? ESCAPE '\'`, args: []interface{}{`Dune%`}},
+		{filter: `title != *Messiah`, sql: `title NOT LIKE ? ESCAPE '\'`, args: []interface{}{`%Messiah`}},
+		{filter: `title:*`, sql: "title IS NOT NULL"},
+		{filter: `create_time > "2021-01-02T03:04:05Z"`, sql: "created_at > ?", args: []interface{}{ts}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			c, err := Compile(tt.filter, fields)
+			require.NoError(t, err)
+			assert.Equal(t, tt.sql, c.SQL)
+			assert.Equal(t, tt.args, c.Args)
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		filter string
+		pos    int
+	}{
+		{filter: `password = "x"`, pos: 0},
+		{filter: `title = "Dune`, pos: 8},
+		{filter: `year = abc`, pos: 7},
+		{filter: `archived > true`, pos: 9},
+		{filter: `create_time > yesterday`, pos: 14},
+		{filter: `Dune`, pos: 0},
+		{filter: `(title = x`, pos: 10},
+		{filter: `title = x)`, pos: 9},
+		{filter: `title =`, pos: 7},
+		{filter: `title = x; DROP TABLE books`, pos: 9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			_, err := Compile(tt.filter, fields)
+			require.Error(t, err)
+			e, ok := err.(*Error)
+			require.True(t, ok, err)
+			assert.Equal(t, tt.pos, e.Pos, e.Error())
+		})
+	}
+}
+
+func TestCompileLimits(t *testing.T) {
+	nested := func(depth int) string {
+		return strings.Repeat("(", depth) + "title = x" + strings.Repeat(")", depth)
+	}
+	_, err := Compile(nested(MaxDepth), fields)
+	require.NoError(t, err)
+
+	_, err = Compile(nested(MaxDepth+1), fields)
+	require.Error(t, err)
+	assert.Equal(t, MaxDepth, err.(*Error).Pos)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// the deeply nested filters are rejected without parsing them
+	_, err = Compile(nested(1e6), fields)
+	require.Error(t, err)
+	assert.Equal(t, MaxLength, err.(*Error).Pos)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = Compile(`title = "`+strings.Repeat("x", MaxLength)+`"`, fields)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
@@ -0,0 +1,101 @@
+package filter
+
+import (
+	"strings"
+	"unicode"
+)
+
+type kind int
+
+const (
+	eof kind = iota
+	lparen
+	rparen
+	text
+	str
+	comparator
+	and
+	or
+	not
+)
+
+type token struct {
+	kind  kind
+	value string
+	pos   int
+}
+
+// lex splits the filter into tokens, see https://google.aip.dev/assets/misc/ebnf-filtering.txt
+func lex(s string) ([]token, error) {
+	var out []token
+	r := []rune(s)
+	for i := 0; i < len(r); {
+		c := r[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(':
+			out = append(out, token{kind: lparen, value: "(", pos: i})
+			i++
+		case c == ')':
+			out = append(out, token{kind: rparen, value: ")", pos: i})
+			i++
+		case c == '"' || c == '\'':
+			start := i
+			var b strings.Builder
+			i++
+			for ; i < len(r) && r[i] != c; i++ {
+				if r[i] == '\\' && i+1 < len(r) {
+					i++
+				}
+				b.WriteRune(r[i])
+			}
+			if i == len(r) {
+				return nil, errorf(start, "unterminated string")
+			}
+			i++
+			out = append(out, token{kind: str, value: b.String(), pos: start})
+		case c == '=' || c == ':':
+			out = append(out, token{kind: comparator, value: string(c), pos: i})
+			i++
+		case c == '!' || c == '<' || c == '>':
+			if i+1 < len(r) && r[i+1] == '=' {
+				out = append(out, token{kind: comparator, value: string(r[i : i+2]), pos: i})
+				i += 2
+				continue
+			}
+			if c == '!' {
+				return nil, errorf(i, "unexpected character %q", c)
+			}
+			out = append(out, token{kind: comparator, value: string(c), pos: i})
+			i++
+		case c == '-' && (i+1 == len(r) || !unicode.IsDigit(r[i+1])):
+			// negation, e.g. -deleted
+			out = append(out, token{kind: not, value: "-", pos: i})
+			i++
+		case isText(c):
+			start := i
+			for i < len(r) && isText(r[i]) {
+				i++
+			}
+			v := string(r[start:i])
+			switch v {
+			case "AND":
+				out = append(out, token{kind: and, value: v, pos: start})
+			case "OR":
+				out = append(out, token{kind: or, value: v, pos: start})
+			case "NOT":
+				out = append(out, token{kind: not, value: v, pos: start})
+			default:
+				out = append(out, token{kind: text, value: v, pos: start})
+			}
+		default:
+			return nil, errorf(i, "unexpected character %q", c)
+		}
+	}
+	return append(out, token{kind: eof, pos: len(r)}), nil
+}
+
+func isText(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || strings.ContainsRune("_.-*+", c)
+}
@@ -0,0 +1,149 @@
+package filter
+
+type node interface{}
+
+type andNode struct {
+	nodes []node
+}
+
+type orNode struct {
+	nodes []node
+}
+
+type notNode struct {
+	node node
+}
+
+type restriction struct {
+	field token
+	op    token
+	value token
+}
+
+type parser struct {
+	tokens []token
+	i      int
+	// depth is the current parentheses nesting depth
+	depth int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.i]
+	if t.kind != eof {
+		p.i++
+	}
+	return t
+}
+
+// expression: sequence {AND sequence}
+func (p *parser) expression() (node, error) {
+	n, err := p.sequence()
+	if err != nil {
+		return nil, err
+	}
+	nodes := []node{n}
+	for p.peek().kind == and {
+		p.next()
+		n, err := p.sequence()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return &andNode{nodes: nodes}, nil
+}
+
+// sequence: factor {factor}, the factors are implicitly joined with AND
+func (p *parser) sequence() (node, error) {
+	n, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
+	nodes := []node{n}
+	for k := p.peek().kind; k == text || k == str || k == lparen || k == not; k = p.peek().kind {
+		n, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return &andNode{nodes: nodes}, nil
+}
+
+// factor: term {OR term}, OR has a higher precedence than AND
+func (p *parser) factor() (node, error) {
+	n, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	nodes := []node{n}
+	for p.peek().kind == or {
+		p.next()
+		n, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return &orNode{nodes: nodes}, nil
+}
+
+// term: [NOT | -] simple
+func (p *parser) term() (node, error) {
+	if p.peek().kind == not {
+		p.next()
+		n, err := p.simple()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{node: n}, nil
+	}
+	return p.simple()
+}
+
+// simple: restriction | "(" expression ")"
+func (p *parser) simple() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case lparen:
+		if p.depth == MaxDepth {
+			return nil, errorf(t.pos, "expressions nested deeper than %d", MaxDepth)
+		}
+		p.depth++
+		n, err := p.expression()
+		p.depth--
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != rparen {
+			return nil, errorf(t.pos, "expected )")
+		}
+		return n, nil
+	case text:
+		op := p.next()
+		if op.kind != comparator {
+			return nil, errorf(t.pos, "%s: expected a comparison, global restrictions are not supported", t.value)
+		}
+		v := p.next()
+		if v.kind != text && v.kind != str {
+			return nil, errorf(v.pos, "expected a value")
+		}
+		return &restriction{field: t, op: op, value: v}, nil
+	case eof:
+		return nil, errorf(t.pos, "unexpected end of filter")
+	}
+	return nil, errorf(t.pos, "unexpected %q", t.value)
+}
@@ -0,0 +1,42 @@
+// Package httpclient provides an *http.Client for third-party REST APIs with the same
+// observability as the gRPC clients: tracing, metrics, retries and token injection.
+package httpclient
+
+import (
+	"net/http"
+)
+
+// New returns an instrumented *http.Client.
+// The round trippers are applied in this order: headers and token injection, tracing, retries, metrics.
+// A single span covers the retries while the metrics record every attempt.
+func New(opts ...Option) *http.Client {
+	o := newOptions(opts...)
+	var rt http.RoundTripper
+	if o.transport != nil {
+		rt = o.transport
+		if t, ok := rt.(*http.Transport); ok {
+			rt = configure(t.Clone(), o)
+		}
+	} else {
+		rt = configure(http.DefaultTransport.(*http.Transport).Clone(), o)
+	}
+	if o.metrics != nil {
+		rt = &metricsTransport{next: rt, m: o.metrics}
+	}
+	if o.retries > 1 {
+		rt = &retryTransport{next: rt, max: o.retries, backoff: o.backoff, maxPushback: defaultMaxPushback}
+	}
+	rt = &tracingTransport{next: rt, tracer: o.tracer}
+	if o.token != nil || len(o.headers) != 0 {
+		rt = &authTransport{next: rt, token: o.token, headers: o.headers}
+	}
+	return &http.Client{Transport: rt, Timeout: o.timeout}
+}
+
+func configure(t *http.Transport, o options) *http.Transport {
+	t.Proxy = o.proxy
+	if o.tls != nil {
+		t.TLSClientConfig = o.tls.Clone()
+	}
+	return t
+}
@@ -0,0 +1,105 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func noBackoff(int) time.Duration {
+	return 0
+}
+
+func TestRetry(t *testing.T) {
+	var calls int
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		b, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+	m := NewMetrics()
+	c := New(WithRetry(3, noBackoff), WithMetrics(m))
+
+	req, err := http.NewRequest(http.MethodPut, srv.URL, bytes.NewReader([]byte("body")))
+	require.NoError(t, err)
+	res, err := c.Do(req)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, []string{"body", "body", "body"}, bodies)
+	host := req.URL.Host
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.requests.WithLabelValues(host, "PUT", "503")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.requests.WithLabelValues(host, "PUT", "200")))
+
+	// non idempotent requests are not retried
+	calls = 0
+	res, err = c.Post(srv.URL, "text/plain", bytes.NewReader([]byte("body")))
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetryAfter(t *testing.T) {
+	res := &http.Response{Header: http.Header{}}
+	_, ok := retryAfter(res)
+	assert.False(t, ok)
+	res.Header.Set("Retry-After", "2")
+	d, ok := retryAfter(res)
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Second, d)
+	res.Header.Set("Retry-After", time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat))
+	d, ok = retryAfter(res)
+	assert.True(t, ok)
+	assert.Zero(t, d)
+}
+
+func TestTokenAndTracing(t *testing.T) {
+	var auth, ua, trace string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth, ua, trace = r.Header.Get("Authorization"), r.Header.Get("User-Agent"), r.Header.Get("Mockpfx-Ids-Traceid")
+	}))
+	defer srv.Close()
+	tracer := mocktracer.New()
+	c := New(
+		WithTracer(tracer),
+		WithHeaders(http.Header{"User-Agent": []string{"test"}}),
+		WithTokenSource(func(ctx context.Context) (string, error) {
+			return "token", nil
+		}),
+	)
+	res, err := c.Get(srv.URL)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, "Bearer token", auth)
+	assert.Equal(t, "test", ua)
+	assert.NotEmpty(t, trace)
+	spans := tracer.FinishedSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "HTTP GET", spans[0].OperationName)
+	assert.Equal(t, uint16(http.StatusOK), spans[0].Tag("http.status_code"))
+
+	c = New(WithTokenSource(func(ctx context.Context) (string, error) {
+		return "", errors.New("no token")
+	}))
+	_, err = c.Get(srv.URL)
+	assert.Error(t, err)
+}
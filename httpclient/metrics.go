@@ -0,0 +1,61 @@
+package httpclient
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics records the outgoing requests, it must be registered to be exposed
+type Metrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// NewMetrics returns the client metrics, labelled by host, method and status code
+func NewMetrics() *Metrics {
+	return &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_client_requests_total",
+			Help: "Total number of HTTP requests sent by the client, retries included.",
+		}, []string{"host", "method", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_client_request_duration_seconds",
+			Help:    "Duration of the HTTP requests until the response headers are received.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"host", "method"}),
+	}
+}
+
+func (m *Metrics) Describe(c chan<- *prometheus.Desc) {
+	m.requests.Describe(c)
+	m.duration.Describe(c)
+}
+
+func (m *Metrics) Collect(c chan<- prometheus.Metric) {
+	m.requests.Collect(c)
+	m.duration.Collect(c)
+}
+
+type metricsTransport struct {
+	next http.RoundTripper
+	m    *Metrics
+}
+
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	res, err := t.next.RoundTrip(req)
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	t.m.duration.WithLabelValues(req.URL.Host, method).Observe(time.Since(start).Seconds())
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(res.StatusCode)
+	}
+	t.m.requests.WithLabelValues(req.URL.Host, method, code).Inc()
+	return res, err
+}
@@ -0,0 +1,126 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+
+	"go.linka.cloud/grpc/utils/backoff"
+)
+
+const (
+	defaultTimeout     = 30 * time.Second
+	defaultMaxPushback = 30 * time.Second
+)
+
+type Option func(o *options)
+
+// WithTimeout sets the overall timeout of the requests, retries included, defaults to 30 seconds.
+// A zero duration disables the timeout.
+func WithTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.timeout = d
+	}
+}
+
+// WithTransport sets the base transport, it defaults to a clone of http.DefaultTransport.
+// The proxy and tls options are ignored when the transport is not an *http.Transport.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(o *options) {
+		o.transport = rt
+	}
+}
+
+// WithProxy sets the proxy used by the transport, defaults to http.ProxyFromEnvironment
+func WithProxy(fn func(*http.Request) (*url.URL, error)) Option {
+	return func(o *options) {
+		o.proxy = fn
+	}
+}
+
+// WithProxyURL routes all the requests through the proxy at u
+func WithProxyURL(u *url.URL) Option {
+	return WithProxy(http.ProxyURL(u))
+}
+
+// WithTLSConfig sets the tls configuration of the transport
+func WithTLSConfig(c *tls.Config) Option {
+	return func(o *options) {
+		o.tls = c
+	}
+}
+
+// WithTracer sets the tracer used to create the client spans, defaults to opentracing.GlobalTracer
+func WithTracer(t opentracing.Tracer) Option {
+	return func(o *options) {
+		o.tracer = t
+	}
+}
+
+// WithMetrics records the requests in m, see NewMetrics
+func WithMetrics(m *Metrics) Option {
+	return func(o *options) {
+		o.metrics = m
+	}
+}
+
+// WithRetry retries the idempotent requests up to max attempts, including the first one, when the transport fails
+// or the server answers with 429, 502, 503 or 504. The Retry-After header is honored.
+// The backoff defaults to backoff.Do when fn is nil.
+func WithRetry(max int, fn func(attempt int) time.Duration) Option {
+	return func(o *options) {
+		o.retries = max
+		if fn != nil {
+			o.backoff = fn
+		}
+	}
+}
+
+// WithTokenSource sets the Authorization header of the requests to the bearer token returned by fn
+func WithTokenSource(fn func(ctx context.Context) (string, error)) Option {
+	return func(o *options) {
+		o.token = fn
+	}
+}
+
+// WithHeaders adds static headers to the requests, e.g. a User-Agent
+func WithHeaders(h http.Header) Option {
+	return func(o *options) {
+		for k, v := range h {
+			o.headers[k] = append(o.headers[k], v...)
+		}
+	}
+}
+
+type options struct {
+	timeout   time.Duration
+	transport http.RoundTripper
+	proxy     func(*http.Request) (*url.URL, error)
+	tls       *tls.Config
+	tracer    opentracing.Tracer
+	metrics   *Metrics
+	retries   int
+	backoff   func(attempt int) time.Duration
+	token     func(ctx context.Context) (string, error)
+	headers   http.Header
+}
+
+func newOptions(opts ...Option) options {
+	o := options{
+		timeout: defaultTimeout,
+		proxy:   http.ProxyFromEnvironment,
+		backoff: backoff.Do,
+		headers: make(http.Header),
+	}
+	for _, v := range opts {
+		v(&o)
+	}
+	if o.tracer == nil {
+		o.tracer = opentracing.GlobalTracer()
+	}
+	return o
+}
@@ -0,0 +1,177 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+)
+
+type authTransport struct {
+	next    http.RoundTripper
+	token   func(ctx context.Context) (string, error)
+	headers http.Header
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// a RoundTripper must not modify the request
+	req = req.Clone(req.Context())
+	for k, v := range t.headers {
+		if req.Header.Get(k) == "" {
+			req.Header[k] = v
+		}
+	}
+	if t.token != nil && req.Header.Get("Authorization") == "" {
+		tk, err := t.token(req.Context())
+		if err != nil {
+			closeBody(req)
+			return nil, fmt.Errorf("httpclient: get token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+tk)
+	}
+	return t.next.RoundTrip(req)
+}
+
+type tracingTransport struct {
+	next   http.RoundTripper
+	tracer opentracing.Tracer
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var parent opentracing.SpanContext
+	if s := opentracing.SpanFromContext(req.Context()); s != nil {
+		parent = s.Context()
+	}
+	span := t.tracer.StartSpan("HTTP "+req.Method, opentracing.ChildOf(parent), ext.SpanKindRPCClient)
+	defer span.Finish()
+	ext.HTTPMethod.Set(span, req.Method)
+	ext.HTTPUrl.Set(span, redact(req.URL))
+	ext.PeerHostname.Set(span, req.URL.Hostname())
+	req = req.Clone(opentracing.ContextWithSpan(req.Context(), span))
+	if err := t.tracer.Inject(span.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(req.Header)); err != nil {
+		span.LogKV("event", "inject failed", "error", err.Error())
+	}
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogKV("event", "error", "message", err.Error())
+		return nil, err
+	}
+	ext.HTTPStatusCode.Set(span, uint16(res.StatusCode))
+	if res.StatusCode >= http.StatusInternalServerError {
+		ext.Error.Set(span, true)
+	}
+	return res, nil
+}
+
+type retryTransport struct {
+	next        http.RoundTripper
+	max         int
+	backoff     func(attempt int) time.Duration
+	maxPushback time.Duration
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !retryable(req) {
+		return t.next.RoundTrip(req)
+	}
+	ctx := req.Context()
+	for attempt := 1; ; attempt++ {
+		r := req
+		if attempt > 1 && req.Body != nil && req.Body != http.NoBody {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r = req.Clone(ctx)
+			r.Body = body
+		}
+		res, err := t.next.RoundTrip(r)
+		if attempt >= t.max || ctx.Err() != nil || !shouldRetry(res, err) {
+			return res, err
+		}
+		d := t.backoff(attempt)
+		if res != nil {
+			if p, ok := retryAfter(res); ok {
+				d = p
+				if d > t.maxPushback {
+					d = t.maxPushback
+				}
+			}
+			io.Copy(ioutil.Discard, res.Body)
+			res.Body.Close()
+		}
+		timer := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// retryable reports whether the request can be sent again: its method must be idempotent or it must carry
+// an Idempotency-Key header, and its body must be replayable
+func retryable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+func shouldRetry(res *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch res.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryAfter parses the Retry-After header, either in seconds or as an http date
+func retryAfter(res *http.Response) (time.Duration, bool) {
+	v := res.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if s, err := strconv.Atoi(v); err == nil && s >= 0 {
+		return time.Duration(s) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// redact removes the credentials from the url
+func redact(u *url.URL) string {
+	if u.User == nil {
+		return u.String()
+	}
+	c := *u
+	c.User = nil
+	return c.String()
+}
+
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
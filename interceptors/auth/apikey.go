@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"context"
+
+	grpc_auth "github.com/grpc-ecosystem/go-grpc-middleware/auth"
+	"google.golang.org/grpc/metadata"
+
+	"go.linka.cloud/grpc/errors"
+	"go.linka.cloud/grpc/interceptors"
+	metadata2 "go.linka.cloud/grpc/interceptors/metadata"
+)
+
+// APIKeyHeader is the metadata key carrying the api key
+const APIKeyHeader = "x-api-key"
+
+type APIKeyValidator func(ctx context.Context, key string) (context.Context, error)
+
+func makeAPIKeyAuthFunc(v APIKeyValidator) grpc_auth.AuthFunc {
+	return func(ctx context.Context) (context.Context, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		keys := md.Get(APIKeyHeader)
+		if len(keys) == 0 || keys[0] == "" {
+			return ctx, errors.Unauthenticatedf("missing api key")
+		}
+		return v(ctx, keys[0])
+	}
+}
+
+func NewAPIKeyClientInterceptors(key string) interceptors.ClientInterceptors {
+	return metadata2.NewInterceptors(APIKeyHeader, key)
+}
@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"go.linka.cloud/grpc/interceptors"
+)
+
+// WithIgnoredPaths bypass the http middleware auth for the request paths under one of the prefixes, e.g. the
+// public gateway routes. The prefixes match whole path segments: /public matches /public and /public/health
+// but not /publications.
+func WithIgnoredPaths(prefixes ...string) Option {
+	return func(o *options) {
+		o.ignoredPaths = append(o.ignoredPaths, prefixes...)
+	}
+}
+
+// WithHTTPMethod sets the function returning the grpc full method of the http requests, e.g. from the gateway
+// routes, so that the http middleware applies the methods options: WithMethods, WithIgnoredMethods and WithIgnored.
+// It defaults to the request path, i.e. the full method of the grpc-web requests.
+func WithHTTPMethod(fn func(r *http.Request) string) Option {
+	return func(o *options) {
+		o.httpMethod = fn
+	}
+}
+
+// WithPresenceOnly makes the http middleware only check that the request carries credentials: an authorization
+// header, an api key or a client certificate, leaving their validation to the interceptors
+func WithPresenceOnly() Option {
+	return func(o *options) {
+		o.presenceOnly = true
+	}
+}
+
+// authenticatedKey is the context key of the requests authenticated by a middleware, it is created for each
+// middleware so that only the interceptors sharing its options trust its authentication.
+// It is not zero sized as the pointers to distinct zero sized values may be equal.
+type authenticatedKey struct {
+	_ byte
+}
+
+// authenticated reports whether the rpc was authenticated by the http middleware owning the key,
+// i.e. a gateway call dispatched in-process with the http request context
+func authenticated(ctx context.Context, key *authenticatedKey) bool {
+	if key == nil {
+		return false
+	}
+	ok, _ := ctx.Value(key).(bool)
+	return ok
+}
+
+// authHeaders are the http headers read by the validators, the only ones passed to them as metadata
+var authHeaders = []string{"authorization", APIKeyHeader}
+
+// NewHTTPMiddleware returns a middleware running the validators at the http layer, e.g. before the gateway
+// transcoding and in-process dispatch, so that the unauthenticated requests are rejected with 401, or 403 when
+// denied, without consuming the unmarshalling.
+// The calls dispatched in-process are authenticated again by the interceptors, see NewServerInterceptorsWithHTTPMiddleware
+// to authenticate them only once.
+func NewHTTPMiddleware(opts ...Option) func(http.Handler) http.Handler {
+	o := options{}
+	for _, v := range opts {
+		v(&o)
+	}
+	return newHTTPMiddleware(o, &authenticatedKey{})
+}
+
+// NewServerInterceptorsWithHTTPMiddleware returns the server interceptors and the http middleware sharing the options.
+// The authenticated context is passed to the requests by the middleware so that the interceptors do not authenticate
+// again the calls it dispatches in-process, the other calls, and the ones authenticated by other middlewares,
+// are still authenticated.
+func NewServerInterceptorsWithHTTPMiddleware(opts ...Option) (interceptors.ServerInterceptors, func(http.Handler) http.Handler) {
+	o := options{}
+	for _, v := range opts {
+		v(&o)
+	}
+	key := &authenticatedKey{}
+	return &interceptor{o: o, authFn: ChainedAuthFuncs(o.authFns...), key: key}, newHTTPMiddleware(o, key)
+}
+
+func newHTTPMiddleware(o options, key *authenticatedKey) func(http.Handler) http.Handler {
+	authFn := ChainedAuthFuncs(o.authFns...)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, v := range o.ignoredPaths {
+				if underPath(r.URL.Path, v) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			md := make(metadata.MD, len(authHeaders))
+			for _, k := range authHeaders {
+				if v := r.Header[http.CanonicalHeaderKey(k)]; len(v) != 0 {
+					md.Append(k, v...)
+				}
+			}
+			ctx := metadata.NewIncomingContext(r.Context(), md)
+			p := &peer.Peer{}
+			if host, port, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+				if ip := net.ParseIP(host); ip != nil {
+					n, _ := strconv.Atoi(port)
+					p.Addr = &net.TCPAddr{IP: ip, Port: n}
+				}
+			}
+			if r.TLS != nil {
+				p.AuthInfo = credentials.TLSInfo{State: *r.TLS}
+			}
+			if p.Addr != nil || p.AuthInfo != nil {
+				ctx = peer.NewContext(ctx, p)
+			}
+			method := r.URL.Path
+			if o.httpMethod != nil {
+				method = o.httpMethod(r)
+			}
+			if o.isNotProtected(ctx, method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if o.presenceOnly {
+				if r.Header.Get("Authorization") == "" && r.Header.Get(APIKeyHeader) == "" && (r.TLS == nil || len(r.TLS.PeerCertificates) == 0) {
+					http.Error(w, codes.Unauthenticated.String(), http.StatusUnauthorized)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx, err := authFn(ctx)
+			if err != nil {
+				if status.Code(err) == codes.PermissionDenied {
+					http.Error(w, codes.PermissionDenied.String(), http.StatusForbidden)
+					return
+				}
+				http.Error(w, codes.Unauthenticated.String(), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, key, true)))
+		})
+	}
+}
+
+// underPath reports whether the path is the prefix or one of its sub paths
+func underPath(path, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || strings.HasSuffix(prefix, "/") || path[len(prefix)] == '/'
+}
@@ -10,6 +10,7 @@ import (
 	"google.golang.org/grpc/status"
 
 	"go.linka.cloud/grpc/interceptors"
+	"go.linka.cloud/grpc/match"
 )
 
 func ChainedAuthFuncs(fn ...grpc_auth.AuthFunc) grpc_auth.AuthFunc {
@@ -43,12 +44,14 @@ func NewServerInterceptors(opts ...Option) interceptors.ServerInterceptors {
 type interceptor struct {
 	o      options
 	authFn grpc_auth.AuthFunc
+	// key is the authenticated context key of the paired http middleware, nil if none
+	key *authenticatedKey
 }
 
 func (i *interceptor) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
 	a := grpc_auth.UnaryServerInterceptor(i.authFn)
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
-		if i.isNotProtected(info.FullMethod) {
+		if i.isNotProtected(ctx, info.FullMethod) {
 			return handler(ctx, req)
 		}
 		return a(ctx, req, info, handler)
@@ -58,32 +61,26 @@ func (i *interceptor) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
 func (i *interceptor) StreamServerInterceptor() grpc.StreamServerInterceptor {
 	a := grpc_auth.StreamServerInterceptor(i.authFn)
 	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
-		if i.isNotProtected(info.FullMethod) {
+		if i.isNotProtected(ss.Context(), info.FullMethod) {
 			return handler(srv, ss)
 		}
 		return a(srv, ss, info, handler)
 	}
 }
 
-func (i *interceptor) isNotProtected(endpoint string) bool {
-	// default to not ignored
-	if len(i.o.ignoredMethods) == 0 && len(i.o.methods) == 0 {
-		return false
-	}
-	for _, v := range i.o.ignoredMethods {
-		if v == endpoint {
-			return true
-		}
+func (i *interceptor) isNotProtected(ctx context.Context, endpoint string) bool {
+	return authenticated(ctx, i.key) || i.o.isNotProtected(ctx, endpoint)
+}
+
+func (o options) isNotProtected(ctx context.Context, endpoint string) bool {
+	if match.AnyMethod(o.ignoredMethods, endpoint) || match.Any(o.ignored...).Match(ctx, endpoint) {
+		return true
 	}
-	if len(i.o.methods) == 0 {
+	// default to protected
+	if len(o.methods) == 0 {
 		return false
 	}
-	for _, v := range i.o.methods {
-		if v == endpoint {
-			return false
-		}
-	}
-	return true
+	return !match.AnyMethod(o.methods, endpoint)
 }
 
 func Equals(s1, s2 string) bool {
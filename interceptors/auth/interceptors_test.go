@@ -2,44 +2,49 @@ package auth
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	grpc_auth "github.com/grpc-ecosystem/go-grpc-middleware/auth"
 	assert2 "github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 
 	"go.linka.cloud/grpc/errors"
+	"go.linka.cloud/grpc/interceptors"
 )
 
 func TestNotProtectededOnly(t *testing.T) {
 	assert := assert2.New(t)
 	i := &interceptor{o: options{ignoredMethods: []string{"/test.Service/ignored"}}}
-	assert.False(i.isNotProtected("/test.Service/protected"))
-	assert.True(i.isNotProtected("/test.Service/ignored"))
+	assert.False(i.isNotProtected(context.Background(), "/test.Service/protected"))
+	assert.True(i.isNotProtected(context.Background(), "/test.Service/ignored"))
 }
 
 func TestProtectedOnly(t *testing.T) {
 	assert := assert2.New(t)
 	i := &interceptor{o: options{methods: []string{"/test.Service/protected"}}}
-	assert.False(i.isNotProtected("/test.Service/protected"))
-	assert.True(i.isNotProtected("/test.Service/ignored"))
+	assert.False(i.isNotProtected(context.Background(), "/test.Service/protected"))
+	assert.True(i.isNotProtected(context.Background(), "/test.Service/ignored"))
 }
 
 func TestProtectedAndIgnored(t *testing.T) {
 	assert := assert2.New(t)
 	i := &interceptor{o: options{methods: []string{"/test.Service/protected"}, ignoredMethods: []string{"/test.Service/ignored"}}}
-	assert.True(i.isNotProtected("/test.Service/ignored"))
-	assert.False(i.isNotProtected("/test.Service/protected"))
-	assert.True(i.isNotProtected("/test.Service/other"))
+	assert.True(i.isNotProtected(context.Background(), "/test.Service/ignored"))
+	assert.False(i.isNotProtected(context.Background(), "/test.Service/protected"))
+	assert.True(i.isNotProtected(context.Background(), "/test.Service/other"))
 }
 
 func TestProtectedByDefault(t *testing.T) {
 	i := &interceptor{}
-	assert2.False(t, i.isNotProtected("/test.Service/noop"))
-	assert2.False(t, i.isNotProtected("/test.Service/method/cannotExists"))
-	assert2.False(t, i.isNotProtected("/test.Service/validMethod"))
+	assert2.False(t, i.isNotProtected(context.Background(), "/test.Service/noop"))
+	assert2.False(t, i.isNotProtected(context.Background(), "/test.Service/method/cannotExists"))
+	assert2.False(t, i.isNotProtected(context.Background(), "/test.Service/validMethod"))
 }
 
 var (
@@ -144,3 +149,70 @@ func TestChainedAuthFuncs(t *testing.T) {
 		})
 	}
 }
+
+func TestHTTPMiddleware(t *testing.T) {
+	do := func(h func(http.Handler) http.Handler, path, auth string) int {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		h(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(w, r)
+		return w.Code
+	}
+	m := NewHTTPMiddleware(WithTokenValidators(tokenAuth), WithIgnoredPaths("/public"), WithIgnoredMethods("/test.Service/Public"))
+	assert2.Equal(t, http.StatusUnauthorized, do(m, "/api", ""))
+	assert2.Equal(t, http.StatusForbidden, do(m, "/api", "bearer noop"))
+	assert2.Equal(t, http.StatusOK, do(m, "/api", "bearer token"))
+	assert2.Equal(t, http.StatusOK, do(m, "/public", ""))
+	assert2.Equal(t, http.StatusOK, do(m, "/public/health", ""))
+	assert2.Equal(t, http.StatusUnauthorized, do(m, "/publications", ""))
+	assert2.Equal(t, http.StatusOK, do(m, "/test.Service/Public", ""))
+	assert2.Equal(t, http.StatusUnauthorized, do(m, "/test.Service/Private", ""))
+
+	m = NewHTTPMiddleware(WithTokenValidators(tokenAuth), WithMethods("/test.Service/"), WithHTTPMethod(func(r *http.Request) string {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			return ""
+		}
+		return "/test.Service" + strings.TrimPrefix(r.URL.Path, "/api")
+	}))
+	assert2.Equal(t, http.StatusUnauthorized, do(m, "/api/Method", ""))
+	assert2.Equal(t, http.StatusOK, do(m, "/other", ""))
+
+	// the calls authenticated by the middleware are not authenticated again by its interceptors only
+	i, m := NewServerInterceptorsWithHTTPMiddleware(WithTokenValidators(tokenAuth))
+	strict := NewServerInterceptors(WithTokenValidators(func(ctx context.Context, token string) (context.Context, error) {
+		return ctx, errors.PermissionDeniedf("")
+	}))
+	call := func(i interceptors.ServerInterceptors, ctx context.Context) error {
+		_, err := i.UnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Method"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+		return err
+	}
+	var called bool
+	r := httptest.NewRequest(http.MethodGet, "/api", nil)
+	r.Header.Set("Authorization", "bearer token")
+	r.Header.Set("Cookie", "session=secret")
+	m(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		assert2.NoError(t, call(i, r.Context()))
+		assert2.Equal(t, codes.PermissionDenied, status.Code(call(strict, r.Context())))
+		// only the credentials are passed to the validators
+		md, _ := metadata.FromIncomingContext(r.Context())
+		assert2.Equal(t, metadata.Pairs("authorization", "bearer token"), md)
+	})).ServeHTTP(httptest.NewRecorder(), r)
+	assert2.True(t, called)
+	assert2.Equal(t, codes.Unauthenticated, status.Code(call(i, context.Background())))
+	// nor by the interceptors of another middleware
+	called = false
+	NewHTTPMiddleware(WithTokenValidators(tokenAuth))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		assert2.Equal(t, codes.Unauthenticated, status.Code(call(i, metadata.NewIncomingContext(r.Context(), nil))))
+	})).ServeHTTP(httptest.NewRecorder(), r)
+	assert2.True(t, called)
+
+	m = NewHTTPMiddleware(WithPresenceOnly())
+	assert2.Equal(t, http.StatusUnauthorized, do(m, "/api", ""))
+	assert2.Equal(t, http.StatusOK, do(m, "/api", "bearer noop"))
+}
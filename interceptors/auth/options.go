@@ -1,25 +1,38 @@
 package auth
 
 import (
+	"net/http"
+
 	grpc_auth "github.com/grpc-ecosystem/go-grpc-middleware/auth"
+
+	"go.linka.cloud/grpc/match"
 )
 
 type Option func(o *options)
 
-// WithMethods change the behaviour to not protect by default, it takes a list of fully qualified method names to protect, e.g. /helloworld.Greeter/SayHello
+// WithMethods change the behaviour to not protect by default, it takes a list of method patterns to protect, e.g. /helloworld.Greeter/SayHello,
+// see the match package for the patterns syntax
 func WithMethods(methods ...string) Option {
 	return func(o *options) {
 		o.methods = append(o.methods, methods...)
 	}
 }
 
-// WithIgnoredMethods bypass auth for the given methods, it takes a list of fully qualified method name, e.g. /helloworld.Greeter/SayHello
+// WithIgnoredMethods bypass auth for the given methods, it takes a list of method patterns, e.g. /helloworld.Greeter/SayHello
+// or /grpc.health.v1.Health/, see the match package for the patterns syntax
 func WithIgnoredMethods(methods ...string) Option {
 	return func(o *options) {
 		o.ignoredMethods = append(o.ignoredMethods, methods...)
 	}
 }
 
+// WithIgnored bypass auth for the rpcs selected by the matchers, e.g. match.PeerNetworks for the internal networks
+func WithIgnored(m ...match.Matcher) Option {
+	return func(o *options) {
+		o.ignored = append(o.ignored, m...)
+	}
+}
+
 func WithBasicValidators(validators ...BasicValidator) Option {
 	var authFns []grpc_auth.AuthFunc
 	for _, v := range validators {
@@ -50,9 +63,25 @@ func WithX509Validators(validators ...X509Validator) Option {
 	}
 }
 
+// WithAPIKeyValidators validates the api key sent in the x-api-key metadata, see the apikey package
+func WithAPIKeyValidators(validators ...APIKeyValidator) Option {
+	var authFns []grpc_auth.AuthFunc
+	for _, v := range validators {
+		authFns = append(authFns, makeAPIKeyAuthFunc(v))
+	}
+	return func(o *options) {
+		o.authFns = append(o.authFns, authFns...)
+	}
+}
+
 type options struct {
 	methods        []string
 	ignoredMethods []string
+	ignored        []match.Matcher
 
 	authFns []grpc_auth.AuthFunc
+
+	ignoredPaths []string
+	httpMethod   func(r *http.Request) string
+	presenceOnly bool
 }
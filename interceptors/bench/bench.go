@@ -0,0 +1,194 @@
+// Package bench measures the server interceptors overhead on the hot path, i.e. the cost they add to each rpc
+// without the network, the serialization nor the handler work.
+//
+// The interceptors benchmarks are defined in the package tests, make bench runs them along with the duration
+// budgets and writes the results to the benchmarks.txt file of the package, so that runs on the same machine
+// can be compared, e.g. with benchstat. The results depend on the machine, they are not committed.
+//
+//	go test -run '^$' -bench . -benchmem ./interceptors/bench
+//
+// The debug-only interceptors are compiled out with the nodebug build tag, see interceptors.Debug.
+//
+// The overhead budgets guard the default chain against regressions, e.g.:
+//
+//	func TestBudget(t *testing.T) {
+//		bench.Budget{Allocs: bench.Allocs(chain[0]) + bench.Allocs(chain[1]), Duration: 5 * time.Microsecond}.Check(t, chain...)
+//	}
+//
+// The duration budget is only checked when the BENCH_BUDGET environment variable is set, as make bench does,
+// since it depends on the machine and the load of the one running the tests.
+package bench
+
+import (
+	"context"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	grpcmiddleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// Method is the full method name of the benchmarked rpcs
+const Method = "/bench.Service/Method"
+
+// Context returns the context of the benchmarked rpcs: it carries incoming metadata, a peer
+// and a server transport stream, so that the interceptors can set the headers
+func Context() context.Context {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("user-agent", "bench", "x-request-id", "bench"))
+	ctx = grpc.NewContextWithServerTransportStream(ctx, transportStream{})
+	return peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 4242}})
+}
+
+// Request returns the request of the benchmarked rpcs
+func Request() interface{} {
+	return wrapperspb.String("bench")
+}
+
+// UnaryCall returns a function calling the unary interceptors chain with a handler returning the request
+func UnaryCall(i ...grpc.UnaryServerInterceptor) func() error {
+	ctx, req := Context(), Request()
+	info := &grpc.UnaryServerInfo{FullMethod: Method}
+	chain := grpcmiddleware.ChainUnaryServer(i...)
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return req, nil
+	}
+	return func() error {
+		_, err := chain(ctx, req, info, handler)
+		return err
+	}
+}
+
+// StreamCall returns a function calling the stream interceptors chain with a handler receiving and sending a message
+func StreamCall(i ...grpc.StreamServerInterceptor) func() error {
+	ss := &stream{ctx: Context()}
+	info := &grpc.StreamServerInfo{FullMethod: Method, IsClientStream: true, IsServerStream: true}
+	chain := grpcmiddleware.ChainStreamServer(i...)
+	handler := func(srv interface{}, ss grpc.ServerStream) error {
+		m := Request()
+		if err := ss.RecvMsg(m); err != nil {
+			return err
+		}
+		return ss.SendMsg(m)
+	}
+	return func() error {
+		return chain(nil, ss, info, handler)
+	}
+}
+
+// Unary benchmarks the unary interceptors chain
+func Unary(b *testing.B, i ...grpc.UnaryServerInterceptor) {
+	run(b, UnaryCall(i...))
+}
+
+// Stream benchmarks the stream interceptors chain
+func Stream(b *testing.B, i ...grpc.StreamServerInterceptor) {
+	run(b, StreamCall(i...))
+}
+
+func run(b *testing.B, call func() error) {
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if err := call(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BudgetEnv is the environment variable enabling the duration budgets
+const BudgetEnv = "BENCH_BUDGET"
+
+// Budget is the maximum overhead of a unary interceptors chain per rpc
+type Budget struct {
+	// Allocs is the maximum number of allocations, it is not checked if zero
+	Allocs float64
+	// Duration is the maximum duration, it is not checked if zero, in short mode or without the BudgetEnv
+	// environment variable as it depends on the machine running the tests
+	Duration time.Duration
+}
+
+// Allocs returns the allocations per rpc of the unary interceptors chain, without the handler and chain ones
+func Allocs(i ...grpc.UnaryServerInterceptor) float64 {
+	call, baseline := UnaryCall(i...), UnaryCall()
+	base := testing.AllocsPerRun(100, func() {
+		_ = baseline()
+	})
+	return testing.AllocsPerRun(100, func() {
+		_ = call()
+	}) - base
+}
+
+// Check fails the test if the unary interceptors chain exceeds the budget
+func (bd Budget) Check(t testing.TB, i ...grpc.UnaryServerInterceptor) {
+	t.Helper()
+	call := UnaryCall(i...)
+	if err := call(); err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	if bd.Allocs > 0 {
+		if n := Allocs(i...); n > bd.Allocs {
+			t.Errorf("interceptors allocations: %.1f per rpc, budget %.1f", n, bd.Allocs)
+		}
+	}
+	if bd.Duration <= 0 || testing.Short() || os.Getenv(BudgetEnv) == "" {
+		return
+	}
+	r := testing.Benchmark(func(b *testing.B) {
+		run(b, call)
+	})
+	if d := time.Duration(r.NsPerOp()); d > bd.Duration {
+		t.Errorf("interceptors overhead: %v per rpc, budget %v", d, bd.Duration)
+	}
+}
+
+// stream is a grpc.ServerStream discarding the sent messages and receiving empty ones
+type stream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *stream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *stream) SetHeader(metadata.MD) error {
+	return nil
+}
+
+func (s *stream) SendHeader(metadata.MD) error {
+	return nil
+}
+
+func (s *stream) SetTrailer(metadata.MD) {}
+
+func (s *stream) SendMsg(interface{}) error {
+	return nil
+}
+
+func (s *stream) RecvMsg(interface{}) error {
+	return nil
+}
+
+// transportStream is a grpc.ServerTransportStream discarding the headers and trailers
+type transportStream struct{}
+
+func (transportStream) Method() string {
+	return Method
+}
+
+func (transportStream) SetHeader(metadata.MD) error {
+	return nil
+}
+
+func (transportStream) SendHeader(metadata.MD) error {
+	return nil
+}
+
+func (transportStream) SetTrailer(metadata.MD) error {
+	return nil
+}
@@ -0,0 +1,111 @@
+package bench
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"go.linka.cloud/grpc/interceptors"
+	"go.linka.cloud/grpc/interceptors/concurrency"
+	"go.linka.cloud/grpc/interceptors/defaulter"
+	"go.linka.cloud/grpc/interceptors/killswitch"
+	"go.linka.cloud/grpc/interceptors/limits"
+	"go.linka.cloud/grpc/interceptors/metadata"
+	"go.linka.cloud/grpc/interceptors/metrics"
+	"go.linka.cloud/grpc/interceptors/priority"
+	"go.linka.cloud/grpc/interceptors/quota"
+	"go.linka.cloud/grpc/interceptors/recovery"
+	"go.linka.cloud/grpc/interceptors/slowlog"
+	"go.linka.cloud/grpc/interceptors/timeout"
+	"go.linka.cloud/grpc/interceptors/tracing"
+	"go.linka.cloud/grpc/interceptors/validation"
+	"go.linka.cloud/grpc/rpcctx"
+)
+
+// benchmarks are the server interceptors, the chain one is the sum of all of them
+var benchmarks = []struct {
+	name string
+	new  func() interceptors.ServerInterceptors
+}{
+	{name: "recovery", new: func() interceptors.ServerInterceptors { return recovery.NewInterceptors() }},
+	{name: "rpcctx", new: func() interceptors.ServerInterceptors {
+		return rpcctx.NewServerInterceptors(rpcctx.WithServiceInfo("bench", "v0.0.0"))
+	}},
+	{name: "metadata", new: func() interceptors.ServerInterceptors { return metadata.NewInterceptors("x-bench", "bench") }},
+	{name: "validation", new: func() interceptors.ServerInterceptors { return validation.NewInterceptors(false) }},
+	{name: "defaulter", new: func() interceptors.ServerInterceptors { return defaulter.NewInterceptors() }},
+	{name: "limits", new: func() interceptors.ServerInterceptors { return limits.NewServerInterceptors() }},
+	{name: "killswitch", new: func() interceptors.ServerInterceptors { return killswitch.NewServerInterceptors() }},
+	{name: "timeout", new: func() interceptors.ServerInterceptors { return timeout.NewServerInterceptors() }},
+	{name: "priority", new: func() interceptors.ServerInterceptors { return priority.NewServerInterceptors() }},
+	{name: "concurrency", new: func() interceptors.ServerInterceptors { return concurrency.NewServerInterceptors() }},
+	{name: "quota", new: func() interceptors.ServerInterceptors { return quota.NewServerInterceptors() }},
+	{name: "metrics", new: func() interceptors.ServerInterceptors { return metrics.NewServerInterceptors() }},
+	{name: "tracing", new: func() interceptors.ServerInterceptors { return tracing.NewServerInterceptors() }},
+	{name: "slowlog", new: func() interceptors.ServerInterceptors { return slowlog.NewServerInterceptors() }},
+}
+
+func chain() ([]grpc.UnaryServerInterceptor, []grpc.StreamServerInterceptor) {
+	var (
+		unary  []grpc.UnaryServerInterceptor
+		stream []grpc.StreamServerInterceptor
+	)
+	for _, v := range benchmarks {
+		i := v.new()
+		unary = append(unary, i.UnaryServerInterceptor())
+		stream = append(stream, i.StreamServerInterceptor())
+	}
+	return unary, stream
+}
+
+func BenchmarkUnary(b *testing.B) {
+	b.Run("baseline", func(b *testing.B) {
+		Unary(b)
+	})
+	for _, v := range benchmarks {
+		i := v.new()
+		b.Run(v.name, func(b *testing.B) {
+			Unary(b, i.UnaryServerInterceptor())
+		})
+	}
+	unary, _ := chain()
+	b.Run("chain", func(b *testing.B) {
+		Unary(b, unary...)
+	})
+}
+
+func BenchmarkStream(b *testing.B) {
+	b.Run("baseline", func(b *testing.B) {
+		Stream(b)
+	})
+	for _, v := range benchmarks {
+		i := v.new()
+		b.Run(v.name, func(b *testing.B) {
+			Stream(b, i.StreamServerInterceptor())
+		})
+	}
+	_, stream := chain()
+	b.Run("chain", func(b *testing.B) {
+		Stream(b, stream...)
+	})
+}
+
+// TestBudget guards the overhead of the interceptors added by the service to every rpc:
+// the chain does not allocate more than its interceptors measured alone
+func TestBudget(t *testing.T) {
+	i := []grpc.UnaryServerInterceptor{
+		recovery.NewInterceptors().UnaryServerInterceptor(),
+		rpcctx.NewServerInterceptors(rpcctx.WithServiceInfo("bench", "v0.0.0")).UnaryServerInterceptor(),
+		metadata.NewInterceptors("x-bench", "bench").UnaryServerInterceptor(),
+	}
+	var allocs float64
+	for _, v := range i {
+		allocs += Allocs(v)
+	}
+	// a zero budget is not checked
+	if allocs < 1 {
+		allocs = 1
+	}
+	Budget{Allocs: allocs, Duration: 50 * time.Microsecond}.Check(t, i...)
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// ControlKey is the response header metadata key carrying the cache policy,
+// using the http Cache-Control syntax, e.g. max-age=60, stale-while-revalidate=30
+const ControlKey = "cache-control"
+
+// control is a parsed cache-control header
+type control struct {
+	Policy
+	noStore bool
+}
+
+func parseControl(values []string) (control, bool) {
+	var c control
+	found := false
+	for _, v := range values {
+		for _, d := range strings.Split(v, ",") {
+			d = strings.ToLower(strings.TrimSpace(d))
+			k, val := d, ""
+			if i := strings.Index(d, "="); i >= 0 {
+				k, val = d[:i], d[i+1:]
+			}
+			switch k {
+			case "no-store", "no-cache", "private":
+				c.noStore = true
+				found = true
+			case "max-age":
+				if n, err := strconv.Atoi(val); err == nil {
+					c.MaxAge = time.Duration(n) * time.Second
+					found = true
+				}
+			case "stale-while-revalidate":
+				if n, err := strconv.Atoi(val); err == nil {
+					c.StaleWhileRevalidate = time.Duration(n) * time.Second
+					found = true
+				}
+			}
+		}
+	}
+	return c, found
+}
+
+// Format returns the cache-control header value of the policy
+func (p Policy) Format() string {
+	v := fmt.Sprintf("max-age=%d", int(p.MaxAge.Seconds()))
+	if p.StaleWhileRevalidate > 0 {
+		v += fmt.Sprintf(", stale-while-revalidate=%d", int(p.StaleWhileRevalidate.Seconds()))
+	}
+	return v
+}
+
+// SetControl sends the cache policy of the response from a server handler
+func SetControl(ctx context.Context, p Policy) error {
+	return grpc.SetHeader(ctx, metadata.Pairs(ControlKey, p.Format()))
+}
+
+// NoStore tells the clients not to cache the response from a server handler
+func NoStore(ctx context.Context) error {
+	return grpc.SetHeader(ctx, metadata.Pairs(ControlKey, "no-store"))
+}
+
+// noCache is the call option bypassing the cache
+type noCache struct {
+	grpc.EmptyCallOption
+}
+
+// NoCache is a call option bypassing the cache lookup, the response is still stored
+func NoCache() grpc.CallOption {
+	return noCache{}
+}
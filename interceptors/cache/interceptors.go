@@ -0,0 +1,208 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+
+	cache2 "go.linka.cloud/grpc/cache"
+	"go.linka.cloud/grpc/interceptors"
+	"go.linka.cloud/grpc/logger"
+	"go.linka.cloud/grpc/match"
+)
+
+const defaultRevalidateTimeout = 10 * time.Second
+
+// NewClientInterceptors returns interceptors caching the unary responses of the cacheable methods.
+// Stale responses are returned while they are refreshed in the background (stale-while-revalidate).
+// Streams are not cached.
+func NewClientInterceptors(opts ...Option) interceptors.ClientInterceptors {
+	o := options{
+		methods:           make(map[string]Policy),
+		revalidateTimeout: defaultRevalidateTimeout,
+	}
+	for _, v := range opts {
+		v(&o)
+	}
+	if o.cache == nil {
+		o.cache = cache2.NewMemory()
+	}
+	return &interceptor{opts: o, revalidating: make(map[string]struct{})}
+}
+
+type interceptor struct {
+	opts options
+
+	mu           sync.Mutex
+	revalidating map[string]struct{}
+}
+
+// entry is the cached response: fresh until, stale until, then the response
+type entry struct {
+	fresh time.Time
+	stale time.Time
+	data  []byte
+}
+
+func (e entry) marshal() []byte {
+	b := make([]byte, 16+len(e.data))
+	binary.BigEndian.PutUint64(b, uint64(e.fresh.UnixNano()))
+	binary.BigEndian.PutUint64(b[8:], uint64(e.stale.UnixNano()))
+	copy(b[16:], e.data)
+	return b
+}
+
+func unmarshalEntry(b []byte) (entry, error) {
+	if len(b) < 16 {
+		return entry{}, errors.New("cache: invalid entry")
+	}
+	return entry{
+		fresh: time.Unix(0, int64(binary.BigEndian.Uint64(b))),
+		stale: time.Unix(0, int64(binary.BigEndian.Uint64(b[8:]))),
+		data:  b[16:],
+	}, nil
+}
+
+func (i *interceptor) cacheable(method string) bool {
+	_, ok := i.policy(method)
+	return ok || i.opts.serverControl
+}
+
+// policy returns the policy of the most specific pattern matching the method
+func (i *interceptor) policy(method string) (Policy, bool) {
+	if p, ok := i.opts.methods[method]; ok {
+		return p, true
+	}
+	var (
+		p       Policy
+		pattern string
+		found   bool
+	)
+	for k, v := range i.opts.methods {
+		if match.Method(k, method) && (!found || match.Specific(k, pattern)) {
+			p, pattern, found = v, k, true
+		}
+	}
+	return p, found
+}
+
+func (i *interceptor) key(ctx context.Context, method string, req proto.Message) (string, error) {
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write(b)
+	md, _ := metadata.FromOutgoingContext(ctx)
+	for _, k := range i.opts.keyMetadata {
+		for _, v := range md.Get(k) {
+			h.Write([]byte{0})
+			h.Write([]byte(k + "=" + v))
+		}
+	}
+	return "grpc:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (i *interceptor) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		rq, ok1 := req.(proto.Message)
+		rp, ok2 := reply.(proto.Message)
+		if !ok1 || !ok2 || !i.cacheable(method) {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		key, err := i.key(ctx, method, rq)
+		if err != nil {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		if !bypass(opts) {
+			if b, err := i.opts.cache.Get(ctx, key); err == nil {
+				if e, err := unmarshalEntry(b); err == nil && time.Now().Before(e.stale) && proto.Unmarshal(e.data, rp) == nil {
+					if time.Now().After(e.fresh) {
+						i.revalidate(ctx, key, method, rq, rp, cc, invoker, opts...)
+					}
+					return nil
+				}
+			}
+		}
+		return i.invoke(ctx, key, method, rq, rp, cc, invoker, opts...)
+	}
+}
+
+// invoke calls the method and stores the response according to the policy
+func (i *interceptor) invoke(ctx context.Context, key, method string, req, reply proto.Message, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	var header metadata.MD
+	if err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Header(&header))...); err != nil {
+		return err
+	}
+	p, ok := i.policy(method)
+	if c, found := parseControl(header.Get(ControlKey)); found {
+		if c.noStore {
+			return nil
+		}
+		p, ok = c.Policy, true
+	}
+	if !ok || p.MaxAge <= 0 {
+		return nil
+	}
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(reply)
+	if err != nil {
+		return nil
+	}
+	now := time.Now()
+	e := entry{fresh: now.Add(p.MaxAge), stale: now.Add(p.MaxAge + p.StaleWhileRevalidate), data: b}
+	if err := i.opts.cache.Set(ctx, key, e.marshal(), p.MaxAge+p.StaleWhileRevalidate); err != nil {
+		logger.C(ctx).Warnf("failed to cache %s response: %v", method, err)
+	}
+	return nil
+}
+
+// revalidate refreshes the entry in the background, only one revalidation per key runs at a time
+func (i *interceptor) revalidate(ctx context.Context, key, method string, req, reply proto.Message, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) {
+	i.mu.Lock()
+	if _, ok := i.revalidating[key]; ok {
+		i.mu.Unlock()
+		return
+	}
+	i.revalidating[key] = struct{}{}
+	i.mu.Unlock()
+	// the call must outlive the caller context, keep its outgoing metadata only
+	md, _ := metadata.FromOutgoingContext(ctx)
+	rctx, cancel := context.WithTimeout(metadata.NewOutgoingContext(context.Background(), md.Copy()), i.opts.revalidateTimeout)
+	log := logger.C(ctx)
+	go func() {
+		defer func() {
+			cancel()
+			i.mu.Lock()
+			delete(i.revalidating, key)
+			i.mu.Unlock()
+		}()
+		if err := i.invoke(rctx, key, method, req, reply.ProtoReflect().New().Interface(), cc, invoker, opts...); err != nil {
+			log.Debugf("failed to revalidate %s response: %v", method, err)
+		}
+	}()
+}
+
+func (i *interceptor) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
+
+func bypass(opts []grpc.CallOption) bool {
+	for _, v := range opts {
+		if _, ok := v.(noCache); ok {
+			return true
+		}
+	}
+	return false
+}
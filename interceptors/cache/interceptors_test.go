@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestParseControl(t *testing.T) {
+	c, ok := parseControl([]string{"max-age=60, stale-while-revalidate=30"})
+	require.True(t, ok)
+	assert.Equal(t, time.Minute, c.MaxAge)
+	assert.Equal(t, 30*time.Second, c.StaleWhileRevalidate)
+	assert.False(t, c.noStore)
+
+	c, ok = parseControl([]string{"no-store"})
+	require.True(t, ok)
+	assert.True(t, c.noStore)
+
+	_, ok = parseControl(nil)
+	assert.False(t, ok)
+
+	assert.Equal(t, "max-age=60, stale-while-revalidate=30", Policy{MaxAge: time.Minute, StaleWhileRevalidate: 30 * time.Second}.Format())
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	const method = "/test.Service/Get"
+	calls := 0
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		calls++
+		reply.(*wrapperspb.StringValue).Value = req.(*wrapperspb.StringValue).Value
+		for _, v := range opts {
+			if h, ok := v.(grpc.HeaderCallOption); ok {
+				*h.HeaderAddr = metadata.Pairs(ControlKey, "max-age=60")
+			}
+		}
+		return nil
+	}
+	i := NewClientInterceptors(WithServerControl()).UnaryClientInterceptor()
+	ctx := context.Background()
+
+	for n := 0; n < 2; n++ {
+		reply := &wrapperspb.StringValue{}
+		require.NoError(t, i(ctx, method, wrapperspb.String("a"), reply, nil, invoker))
+		assert.Equal(t, "a", reply.Value)
+	}
+	assert.Equal(t, 1, calls)
+
+	reply := &wrapperspb.StringValue{}
+	require.NoError(t, i(ctx, method, wrapperspb.String("b"), reply, nil, invoker))
+	assert.Equal(t, "b", reply.Value)
+	assert.Equal(t, 2, calls)
+
+	require.NoError(t, i(ctx, method, wrapperspb.String("a"), reply, nil, invoker, NoCache()))
+	assert.Equal(t, 3, calls)
+}
@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"time"
+
+	cache2 "go.linka.cloud/grpc/cache"
+)
+
+type Option func(o *options)
+
+// Policy is the caching policy of a method
+type Policy struct {
+	// MaxAge is the duration the response is fresh
+	MaxAge time.Duration
+	// StaleWhileRevalidate is the duration after MaxAge the stale response is still returned
+	// while it is refreshed in the background
+	StaleWhileRevalidate time.Duration
+}
+
+// WithCache sets the cache the responses are stored in, defaults to an in-memory cache
+func WithCache(c cache2.Cache) Option {
+	return func(o *options) {
+		o.cache = c
+	}
+}
+
+// WithMethod marks the method as cacheable, e.g. /helloworld.Greeter/SayHello, with the default policy p.
+// The method may be a pattern, e.g. /helloworld.Greeter/Get*, see the match package for the syntax, the policy of the
+// longest matching pattern is used.
+// The policy is overridden by the server cache-control response header if any.
+func WithMethod(method string, p Policy) Option {
+	return func(o *options) {
+		o.methods[method] = p
+	}
+}
+
+// WithServerControl caches the responses of all the methods which responses carry a cache-control header
+func WithServerControl() Option {
+	return func(o *options) {
+		o.serverControl = true
+	}
+}
+
+// WithKeyMetadata adds the values of the outgoing metadata keys to the cache key, e.g. authorization,
+// so that responses are not shared between callers
+func WithKeyMetadata(keys ...string) Option {
+	return func(o *options) {
+		o.keyMetadata = append(o.keyMetadata, keys...)
+	}
+}
+
+// WithRevalidateTimeout sets the timeout of the background revalidation calls, defaults to 10 seconds
+func WithRevalidateTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.revalidateTimeout = d
+	}
+}
+
+type options struct {
+	cache             cache2.Cache
+	methods           map[string]Policy
+	serverControl     bool
+	keyMetadata       []string
+	revalidateTimeout time.Duration
+}
@@ -0,0 +1,151 @@
+// Package compression selects the calls compression per method or by request size, rather than for all the calls,
+// and exposes the negotiated encodings as metrics.
+//
+// The server compresses its responses with the compressor used by the request, so the client decision applies
+// to both directions.
+package compression
+
+import (
+	"context"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+
+	"go.linka.cloud/grpc/interceptors"
+	"go.linka.cloud/grpc/match"
+)
+
+const identity = "identity"
+
+type ClientInterceptors interface {
+	interceptors.ClientInterceptors
+	prometheus.Collector
+}
+
+type ServerInterceptors interface {
+	interceptors.ServerInterceptors
+	prometheus.Collector
+}
+
+// NewClientInterceptors returns interceptors compressing the calls matching the options
+func NewClientInterceptors(opts ...Option) ClientInterceptors {
+	return &client{
+		o: newOptions(opts...),
+		calls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_client_compression_total",
+			Help: "Total number of RPCs started on the client by request encoding.",
+		}, []string{"grpc_service", "grpc_method", "encoding"}),
+	}
+}
+
+type client struct {
+	o     options
+	calls *prometheus.CounterVec
+}
+
+func (c *client) matches(method string) bool {
+	return match.AnyMethod(c.o.methods, method)
+}
+
+// compress returns the call options to append, the call compressor option, if any, is left untouched
+func (c *client) compress(method string, req interface{}, opts []grpc.CallOption) []grpc.CallOption {
+	enc := identity
+	for _, v := range opts {
+		if v, ok := v.(grpc.CompressorCallOption); ok {
+			enc = v.CompressorType
+		}
+	}
+	var out []grpc.CallOption
+	if enc == identity && encoding.GetCompressor(c.o.compressor) != nil {
+		compress := c.matches(method)
+		if !compress && c.o.minSize > 0 && req != nil {
+			if m, ok := req.(proto.Message); ok && proto.Size(m) >= c.o.minSize {
+				compress = true
+			}
+		}
+		if compress {
+			enc = c.o.compressor
+			out = append(out, grpc.UseCompressor(enc))
+		}
+	}
+	s, m := match.Split(method)
+	c.calls.WithLabelValues(s, m, enc).Inc()
+	return out
+}
+
+func (c *client) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(ctx, method, req, reply, cc, append(opts, c.compress(method, req, opts)...)...)
+	}
+}
+
+func (c *client) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		// the stream messages are not known yet, only the methods apply
+		return streamer(ctx, desc, cc, method, append(opts, c.compress(method, nil, opts)...)...)
+	}
+}
+
+func (c *client) Describe(descs chan<- *prometheus.Desc) {
+	c.calls.Describe(descs)
+}
+
+func (c *client) Collect(ch chan<- prometheus.Metric) {
+	c.calls.Collect(ch)
+}
+
+// NewServerInterceptors returns interceptors counting the rpcs by request encoding and by the encodings
+// the clients accept, e.g. to check that the clients compress the large calls
+func NewServerInterceptors() ServerInterceptors {
+	return &server{
+		calls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_server_compression_total",
+			Help: "Total number of RPCs received on the server by request encoding and accepted encodings.",
+		}, []string{"grpc_service", "grpc_method", "encoding", "accept_encoding"}),
+	}
+}
+
+type server struct {
+	calls *prometheus.CounterVec
+}
+
+func (s *server) observe(ctx context.Context, method string) {
+	enc, accept := identity, identity
+	// the request encoding is a reserved header, not exposed in the metadata but by the transport stream
+	if st, ok := grpc.ServerTransportStreamFromContext(ctx).(interface{ RecvCompress() string }); ok && st.RecvCompress() != "" {
+		enc = st.RecvCompress()
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get("grpc-accept-encoding"); len(v) != 0 && v[0] != "" {
+			accept = strings.Join(v, ",")
+		}
+	}
+	svc, m := match.Split(method)
+	s.calls.WithLabelValues(svc, m, enc, accept).Inc()
+}
+
+func (s *server) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		s.observe(ctx, info.FullMethod)
+		return handler(ctx, req)
+	}
+}
+
+func (s *server) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		s.observe(ss.Context(), info.FullMethod)
+		return handler(srv, ss)
+	}
+}
+
+func (s *server) Describe(descs chan<- *prometheus.Desc) {
+	s.calls.Describe(descs)
+}
+
+func (s *server) Collect(ch chan<- prometheus.Metric) {
+	s.calls.Collect(ch)
+}
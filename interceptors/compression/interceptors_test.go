@@ -0,0 +1,41 @@
+package compression
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestClientInterceptors(t *testing.T) {
+	c := NewClientInterceptors(WithMethods("/svc.Service/"), WithMinSize(100))
+	i := c.UnaryClientInterceptor()
+	call := func(method string, req interface{}, opts ...grpc.CallOption) string {
+		enc := "identity"
+		_ = i(context.Background(), method, req, nil, nil, func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			for _, v := range opts {
+				if v, ok := v.(grpc.CompressorCallOption); ok {
+					enc = v.CompressorType
+				}
+			}
+			return nil
+		}, opts...)
+		return enc
+	}
+	small, large := wrapperspb.String("small"), wrapperspb.String(strings.Repeat("a", 100))
+	assert.Equal(t, "gzip", call("/svc.Service/Get", small))
+	assert.Equal(t, "identity", call("/other.Service/Get", small))
+	assert.Equal(t, "gzip", call("/other.Service/Get", large))
+	// the call compressor takes precedence
+	assert.Equal(t, "identity", call("/svc.Service/Get", small, grpc.UseCompressor("identity")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(c.(*client).calls.WithLabelValues("other.Service", "Get", "gzip"))+testutil.ToFloat64(c.(*client).calls.WithLabelValues("other.Service", "Get", "identity")))
+
+	// unknown compressor
+	c = NewClientInterceptors(WithCompressor("unknown"), WithMethods("/svc.Service/"))
+	i = c.UnaryClientInterceptor()
+	assert.Equal(t, "identity", call("/svc.Service/Get", small))
+}
@@ -0,0 +1,44 @@
+package compression
+
+import (
+	"google.golang.org/grpc/encoding/gzip"
+)
+
+type Option func(o *options)
+
+// WithCompressor sets the compressor name, it must be registered with encoding.RegisterCompressor,
+// defaults to gzip
+func WithCompressor(name string) Option {
+	return func(o *options) {
+		o.compressor = name
+	}
+}
+
+// WithMethods always compresses the calls to the methods, e.g. /pkg.Service/Method,
+// or to all the methods of a service using the service prefix, e.g. /pkg.Service/, see the match package for the patterns syntax
+func WithMethods(methods ...string) Option {
+	return func(o *options) {
+		o.methods = append(o.methods, methods...)
+	}
+}
+
+// WithMinSize compresses the unary requests which encoded size is at least n bytes, zero disables the threshold
+func WithMinSize(n int) Option {
+	return func(o *options) {
+		o.minSize = n
+	}
+}
+
+type options struct {
+	compressor string
+	methods    []string
+	minSize    int
+}
+
+func newOptions(opts ...Option) options {
+	o := options{compressor: gzip.Name}
+	for _, v := range opts {
+		v(&o)
+	}
+	return o
+}
@@ -0,0 +1,153 @@
+package concurrency
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go.linka.cloud/grpc/errors"
+	"go.linka.cloud/grpc/interceptors"
+	"go.linka.cloud/grpc/match"
+)
+
+type ServerInterceptors interface {
+	interceptors.ServerInterceptors
+	prometheus.Collector
+}
+
+// NewServerInterceptors returns interceptors adjusting the allowed concurrency of each method
+// based on the observed latencies, the requests above the limit are rejected with Unavailable.
+// It defaults to Vegas limiters.
+func NewServerInterceptors(opts ...Option) ServerInterceptors {
+	o := options{}
+	for _, v := range opts {
+		v(&o)
+	}
+	if o.limiter == nil {
+		o.limiter = func() Limiter {
+			return NewVegas(Bounds{Initial: 20, Min: 1, Max: 1000})
+		}
+	}
+	return &interceptor{
+		o:        o,
+		limiters: make(map[string]Limiter),
+		limit: prometheus.NewDesc(
+			"grpc_server_concurrency_limit",
+			"Current concurrency limit of the method.",
+			[]string{"grpc_service", "grpc_method"}, nil,
+		),
+		inflight: prometheus.NewDesc(
+			"grpc_server_concurrency_in_flight",
+			"Current number of in-flight requests of the method.",
+			[]string{"grpc_service", "grpc_method"}, nil,
+		),
+		shed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_server_concurrency_shed_total",
+			Help: "Total number of RPCs rejected by the concurrency limiter.",
+		}, []string{"grpc_service", "grpc_method"}),
+	}
+}
+
+type interceptor struct {
+	o options
+
+	mu       sync.RWMutex
+	limiters map[string]Limiter
+
+	limit    *prometheus.Desc
+	inflight *prometheus.Desc
+	shed     *prometheus.CounterVec
+}
+
+func (i *interceptor) limiter(method string) Limiter {
+	i.mu.RLock()
+	l, ok := i.limiters[method]
+	i.mu.RUnlock()
+	if ok {
+		return l
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if l, ok := i.limiters[method]; ok {
+		return l
+	}
+	l = i.o.limiter()
+	i.limiters[method] = l
+	return l
+}
+
+func (i *interceptor) isIgnored(ctx context.Context, method string) bool {
+	return match.AnyMethod(i.o.ignoredMethods, method) || match.Any(i.o.ignored...).Match(ctx, method)
+}
+
+func (i *interceptor) do(ctx context.Context, method string, fn func() error) (err error) {
+	if i.isIgnored(ctx, method) {
+		return fn()
+	}
+	l := i.limiter(method)
+	if !l.Acquire() {
+		s, m := match.Split(method)
+		i.shed.WithLabelValues(s, m).Inc()
+		return errors.Unavailablef("%s: concurrency limit reached", method)
+	}
+	start := time.Now()
+	// the slot of a panicking handler is released too, the panic counting as a drop
+	panicked := true
+	defer func() {
+		l.Release(time.Since(start), panicked || dropped(err))
+	}()
+	err = fn()
+	panicked = false
+	return err
+}
+
+func (i *interceptor) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		err = i.do(ctx, info.FullMethod, func() error {
+			resp, err = handler(ctx, req)
+			return err
+		})
+		return resp, err
+	}
+}
+
+func (i *interceptor) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return i.do(ss.Context(), info.FullMethod, func() error {
+			return handler(srv, ss)
+		})
+	}
+}
+
+func (i *interceptor) Describe(descs chan<- *prometheus.Desc) {
+	descs <- i.limit
+	descs <- i.inflight
+	i.shed.Describe(descs)
+}
+
+func (i *interceptor) Collect(c chan<- prometheus.Metric) {
+	i.mu.RLock()
+	for k, v := range i.limiters {
+		s, m := match.Split(k)
+		c <- prometheus.MustNewConstMetric(i.limit, prometheus.GaugeValue, float64(v.Limit()), s, m)
+		c <- prometheus.MustNewConstMetric(i.inflight, prometheus.GaugeValue, float64(v.InFlight()), s, m)
+	}
+	i.mu.RUnlock()
+	i.shed.Collect(c)
+}
+
+func dropped(err error) bool {
+	if err == nil {
+		return false
+	}
+	switch status.Code(err) {
+	case codes.DeadlineExceeded, codes.ResourceExhausted, codes.Unavailable:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,33 @@
+package concurrency
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPanicRelease(t *testing.T) {
+	i := NewServerInterceptors(WithLimiter(func() Limiter {
+		return NewAIMD(Bounds{Initial: 2, Min: 1, Max: 2}, 0.5, time.Second)
+	})).(*interceptor)
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected the handler panic")
+			}
+		}()
+		i.do(context.Background(), "/svc/Method", func() error {
+			panic("boom")
+		})
+	}()
+	l := i.limiter("/svc/Method")
+	if n := l.InFlight(); n != 0 {
+		t.Fatalf("expected the slot to be released, got %d in-flight", n)
+	}
+	if l.Limit() != 1 {
+		t.Fatalf("expected the panic to decrease the limit, got %d", l.Limit())
+	}
+	if err := i.do(context.Background(), "/svc/Method", func() error { return nil }); err != nil {
+		t.Fatalf("expected the request to be admitted, got %v", err)
+	}
+}
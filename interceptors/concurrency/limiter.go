@@ -0,0 +1,159 @@
+package concurrency
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter tracks the in-flight requests and adjusts the concurrency limit based on the observed latencies.
+type Limiter interface {
+	// Acquire reserves a slot, it returns false if the concurrency limit is reached
+	Acquire() bool
+	// Release frees the slot acquired by a request that took rtt to complete.
+	// dropped reports that the request failed because of an overload, e.g. timeout or resources exhaustion.
+	Release(rtt time.Duration, dropped bool)
+	// Limit returns the current concurrency limit
+	Limit() int
+	// InFlight returns the number of in-flight requests
+	InFlight() int
+}
+
+// Bounds define the concurrency limit boundaries
+type Bounds struct {
+	Initial int
+	Min     int
+	Max     int
+}
+
+func (b Bounds) defaults() Bounds {
+	if b.Min <= 0 {
+		b.Min = 1
+	}
+	if b.Max <= 0 {
+		b.Max = 1000
+	}
+	if b.Initial < b.Min {
+		b.Initial = b.Min
+	}
+	if b.Initial > b.Max {
+		b.Initial = b.Max
+	}
+	return b
+}
+
+func (b Bounds) clamp(v float64) float64 {
+	return math.Max(float64(b.Min), math.Min(float64(b.Max), v))
+}
+
+// NewAIMD returns an additive-increase / multiplicative-decrease limiter:
+// the limit grows by one when the limiter is used at more than half its capacity,
+// and is multiplied by backoff (e.g. 0.9) each time a request is dropped or exceeds timeout.
+func NewAIMD(b Bounds, backoff float64, timeout time.Duration) Limiter {
+	b = b.defaults()
+	if backoff <= 0 || backoff >= 1 {
+		backoff = 0.9
+	}
+	return &aimd{limiter: limiter{bounds: b, limit: float64(b.Initial)}, backoff: backoff, timeout: timeout}
+}
+
+// vegasMinRTTWindow is the period after which the Vegas limiter minimum latency is measured again
+const vegasMinRTTWindow = 30 * time.Second
+
+// NewVegas returns a delay based limiter inspired by TCP Vegas:
+// the queue size is estimated from the ratio between the minimum and the observed latencies,
+// the limit is increased while the estimated queue is small and decreased when it grows.
+// The minimum latency is the one observed during the last 30 seconds, so that the limit follows the latency shifts.
+func NewVegas(b Bounds) Limiter {
+	b = b.defaults()
+	return &vegas{limiter: limiter{bounds: b, limit: float64(b.Initial)}, now: time.Now}
+}
+
+type limiter struct {
+	mu       sync.Mutex
+	bounds   Bounds
+	limit    float64
+	inflight int
+}
+
+func (l *limiter) Acquire() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inflight >= int(l.limit) {
+		return false
+	}
+	l.inflight++
+	return true
+}
+
+func (l *limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+func (l *limiter) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inflight
+}
+
+type aimd struct {
+	limiter
+	backoff float64
+	timeout time.Duration
+}
+
+func (l *aimd) Release(rtt time.Duration, dropped bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	inflight := l.inflight
+	l.inflight--
+	switch {
+	case dropped || (l.timeout > 0 && rtt > l.timeout):
+		l.limit = l.bounds.clamp(l.limit * l.backoff)
+	case inflight*2 >= int(l.limit):
+		l.limit = l.bounds.clamp(l.limit + 1)
+	}
+}
+
+type vegas struct {
+	limiter
+	now    func() time.Time
+	minRTT time.Duration
+	// probe is the minimum latency observed since probeStart, it replaces minRTT once the window elapsed
+	probe      time.Duration
+	probeStart time.Time
+}
+
+func (l *vegas) Release(rtt time.Duration, dropped bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inflight--
+	if dropped {
+		l.limit = l.bounds.clamp(l.limit / 2)
+		return
+	}
+	if rtt <= 0 {
+		return
+	}
+	if l.minRTT == 0 || rtt < l.minRTT {
+		l.minRTT = rtt
+	}
+	if l.probe == 0 || rtt < l.probe {
+		l.probe = rtt
+	}
+	if now := l.now(); l.probeStart.IsZero() {
+		l.probeStart = now
+	} else if now.Sub(l.probeStart) >= vegasMinRTTWindow {
+		l.minRTT, l.probe, l.probeStart = l.probe, 0, now
+	}
+	step := math.Max(1, math.Log10(l.limit))
+	queue := l.limit * (1 - float64(l.minRTT)/float64(rtt))
+	switch {
+	case queue < 3*step:
+		l.limit = l.bounds.clamp(l.limit + step)
+	case queue > 6*step:
+		l.limit = l.bounds.clamp(l.limit - step)
+	}
+}
@@ -0,0 +1,79 @@
+package concurrency
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAIMD(t *testing.T) {
+	l := NewAIMD(Bounds{Initial: 2, Min: 1, Max: 4}, 0.5, time.Second)
+	if !l.Acquire() || !l.Acquire() {
+		t.Fatal("expected slots to be available")
+	}
+	if l.Acquire() {
+		t.Fatal("expected limit to be reached")
+	}
+	l.Release(time.Millisecond, false)
+	if l.Limit() != 3 {
+		t.Fatalf("expected limit to increase, got %d", l.Limit())
+	}
+	l.Release(2*time.Second, false)
+	if l.Limit() != 1 {
+		t.Fatalf("expected limit to decrease, got %d", l.Limit())
+	}
+	if l.InFlight() != 0 {
+		t.Fatalf("expected no in-flight requests, got %d", l.InFlight())
+	}
+}
+
+func TestVegas(t *testing.T) {
+	l := NewVegas(Bounds{Initial: 10, Min: 1, Max: 100})
+	for i := 0; i < 10; i++ {
+		l.Acquire()
+		l.Release(10*time.Millisecond, false)
+	}
+	if l.Limit() <= 10 {
+		t.Fatalf("expected limit to increase without queuing, got %d", l.Limit())
+	}
+	before := l.Limit()
+	for i := 0; i < 10; i++ {
+		l.Acquire()
+		l.Release(100*time.Millisecond, false)
+	}
+	if l.Limit() >= before {
+		t.Fatalf("expected limit to decrease with latency, got %d", l.Limit())
+	}
+}
+
+func TestVegasLatencyShift(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewVegas(Bounds{Initial: 10, Min: 1, Max: 100}).(*vegas)
+	l.now = func() time.Time {
+		return now
+	}
+	sample := func(rtt time.Duration) {
+		l.Acquire()
+		l.Release(rtt, false)
+	}
+	for i := 0; i < 5; i++ {
+		sample(time.Millisecond)
+	}
+	for i := 0; i < 10; i++ {
+		sample(100 * time.Millisecond)
+	}
+	shifted := l.Limit()
+	// the early fast samples are forgotten after a window without them
+	for i := 0; i < 2; i++ {
+		now = now.Add(vegasMinRTTWindow)
+		sample(100 * time.Millisecond)
+	}
+	if l.minRTT != 100*time.Millisecond {
+		t.Fatalf("expected the minimum latency to be measured again, got %v", l.minRTT)
+	}
+	for i := 0; i < 10; i++ {
+		sample(100 * time.Millisecond)
+	}
+	if l.Limit() <= shifted {
+		t.Fatalf("expected limit to increase after the latency shift, got %d", l.Limit())
+	}
+}
@@ -0,0 +1,51 @@
+package concurrency
+
+import (
+	"time"
+
+	"go.linka.cloud/grpc/match"
+)
+
+type Option func(o *options)
+
+// WithLimiter sets the factory used to create the limiter of each method
+func WithLimiter(fn func() Limiter) Option {
+	return func(o *options) {
+		o.limiter = fn
+	}
+}
+
+// WithAIMD uses additive-increase / multiplicative-decrease limiters, see NewAIMD
+func WithAIMD(b Bounds, backoff float64, timeout time.Duration) Option {
+	return WithLimiter(func() Limiter {
+		return NewAIMD(b, backoff, timeout)
+	})
+}
+
+// WithVegas uses delay based limiters, see NewVegas
+func WithVegas(b Bounds) Option {
+	return WithLimiter(func() Limiter {
+		return NewVegas(b)
+	})
+}
+
+// WithIgnoredMethods bypass the concurrency control for the given methods, it takes a list of method patterns, e.g. /helloworld.Greeter/SayHello,
+// see the match package for the patterns syntax
+func WithIgnoredMethods(methods ...string) Option {
+	return func(o *options) {
+		o.ignoredMethods = append(o.ignoredMethods, methods...)
+	}
+}
+
+// WithIgnored bypass the concurrency control for the rpcs selected by the matchers
+func WithIgnored(m ...match.Matcher) Option {
+	return func(o *options) {
+		o.ignored = append(o.ignored, m...)
+	}
+}
+
+type options struct {
+	limiter        func() Limiter
+	ignoredMethods []string
+	ignored        []match.Matcher
+}
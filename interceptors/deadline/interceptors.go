@@ -0,0 +1,99 @@
+package deadline
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+
+	"go.linka.cloud/grpc/errors"
+	"go.linka.cloud/grpc/interceptors"
+	"go.linka.cloud/grpc/match"
+)
+
+type ClientInterceptors interface {
+	interceptors.ClientInterceptors
+	prometheus.Collector
+}
+
+// NewClientInterceptors returns interceptors reducing the outgoing deadline by a per-hop margin.
+// The calls which remaining budget is below the floor fail immediately with DeadlineExceeded,
+// as the downstream work could not complete in time.
+func NewClientInterceptors(opts ...Option) ClientInterceptors {
+	o := options{}
+	for _, v := range opts {
+		v(&o)
+	}
+	return &interceptor{
+		opts: o,
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_client_deadline_rejected_total",
+			Help: "Total number of RPCs not sent because their remaining deadline was too short.",
+		}, []string{"grpc_service", "grpc_method"}),
+	}
+}
+
+type interceptor struct {
+	opts     options
+	rejected *prometheus.CounterVec
+}
+
+func (i *interceptor) Describe(descs chan<- *prometheus.Desc) {
+	i.rejected.Describe(descs)
+}
+
+func (i *interceptor) Collect(c chan<- prometheus.Metric) {
+	i.rejected.Collect(c)
+}
+
+// context returns the context with the reduced deadline
+func (i *interceptor) context(ctx context.Context, method string) (context.Context, context.CancelFunc, error) {
+	dl, ok := ctx.Deadline()
+	if !ok {
+		if i.opts.def <= 0 {
+			return ctx, func() {}, nil
+		}
+		ctx, cancel := context.WithTimeout(ctx, i.opts.def)
+		return ctx, cancel, nil
+	}
+	remaining := time.Until(dl) - i.opts.margin
+	if remaining <= 0 || remaining < i.opts.floor {
+		s, m := match.Split(method)
+		i.rejected.WithLabelValues(s, m).Inc()
+		return nil, nil, errors.DeadlineExceededf("%s: remaining deadline too short: %v", method, remaining)
+	}
+	ctx, cancel := context.WithDeadline(ctx, dl.Add(-i.opts.margin))
+	return ctx, cancel, nil
+}
+
+func (i *interceptor) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, cancel, err := i.context(ctx, method)
+		if err != nil {
+			return err
+		}
+		defer cancel()
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+func (i *interceptor) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx, cancel, err := i.context(ctx, method)
+		if err != nil {
+			return nil, err
+		}
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		// the stream outlives the call, its context is released when the deadline expires
+		go func() {
+			<-cs.Context().Done()
+			cancel()
+		}()
+		return cs, nil
+	}
+}
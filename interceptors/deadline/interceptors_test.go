@@ -0,0 +1,45 @@
+package deadline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	i := NewClientInterceptors(WithMargin(100*time.Millisecond), WithFloor(50*time.Millisecond)).UnaryClientInterceptor()
+	var got time.Duration
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		dl, ok := ctx.Deadline()
+		require.True(t, ok)
+		got = time.Until(dl)
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, i(ctx, "/test.Service/Get", nil, nil, nil, invoker))
+	assert.True(t, got <= 900*time.Millisecond)
+	assert.True(t, got > 800*time.Millisecond)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 120*time.Millisecond)
+	defer cancel()
+	err := i(ctx, "/test.Service/Get", nil, nil, nil, invoker)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+}
+
+func TestDefaultDeadline(t *testing.T) {
+	i := NewClientInterceptors(WithDefault(time.Second)).UnaryClientInterceptor()
+	err := i(context.Background(), "/test.Service/Get", nil, nil, nil, func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		_, ok := ctx.Deadline()
+		assert.True(t, ok)
+		return nil
+	})
+	require.NoError(t, err)
+}
@@ -0,0 +1,35 @@
+package deadline
+
+import (
+	"time"
+)
+
+type Option func(o *options)
+
+// WithMargin sets the duration subtracted from the incoming deadline for each hop,
+// leaving the caller time to handle the response or the error
+func WithMargin(d time.Duration) Option {
+	return func(o *options) {
+		o.margin = d
+	}
+}
+
+// WithFloor rejects the calls which remaining time, after the margin, is below d
+func WithFloor(d time.Duration) Option {
+	return func(o *options) {
+		o.floor = d
+	}
+}
+
+// WithDefault sets the deadline of the calls made without one, a zero value leaves them unbounded
+func WithDefault(d time.Duration) Option {
+	return func(o *options) {
+		o.def = d
+	}
+}
+
+type options struct {
+	margin time.Duration
+	floor  time.Duration
+	def    time.Duration
+}
@@ -0,0 +1,8 @@
+//go:build !nodebug
+// +build !nodebug
+
+package interceptors
+
+// Debug reports whether the debug-only interceptors are compiled in, e.g. the slowlog diagnostics.
+// They are compiled out with the nodebug build tag, their constructors then return no-op interceptors.
+const Debug = true
@@ -4,17 +4,34 @@ import (
 	"context"
 
 	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
 
 	"go.linka.cloud/grpc/interceptors"
 )
 
-type interceptor struct{}
+type interceptor struct {
+	opts options
+}
 
-func NewInterceptors() interceptors.Interceptors {
-	return &interceptor{}
+// NewInterceptors returns interceptors setting the messages defaults: the rules declared with WithRules,
+// then the generated Default method. They must run before the validation interceptors.
+func NewInterceptors(opts ...Option) interceptors.Interceptors {
+	o := options{rules: make(map[protoreflect.FullName][]rule)}
+	for _, v := range opts {
+		v(&o)
+	}
+	return &interceptor{opts: o}
 }
 
-func defaults(v interface{}) {
+func (i interceptor) defaults(v interface{}) {
+	if m, ok := v.(proto.Message); ok && m != nil && len(i.opts.rules) != 0 {
+		if r := m.ProtoReflect(); r.IsValid() {
+			for _, v := range i.opts.rules[r.Descriptor().FullName()] {
+				v.apply(r)
+			}
+		}
+	}
 	if d, ok := v.(interface{ Default() }); v != nil && ok {
 		d.Default()
 	}
@@ -22,47 +39,49 @@ func defaults(v interface{}) {
 
 func (i interceptor) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
-		defaults(req)
+		i.defaults(req)
 		return handler(ctx, req)
 	}
 }
 
 func (i interceptor) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
 	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
-		defaults(req)
+		i.defaults(req)
 		return invoker(ctx, method, req, reply, cc, opts...)
 	}
 }
 
 func (i interceptor) StreamServerInterceptor() grpc.StreamServerInterceptor {
 	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
-		wrapper := &recvWrapper{ServerStream: stream}
+		wrapper := &recvWrapper{ServerStream: stream, defaults: i.defaults}
 		return handler(srv, wrapper)
 	}
 }
 
 func (i interceptor) StreamClientInterceptor() grpc.StreamClientInterceptor {
 	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
-		desc.Handler = (&sendWrapper{handler: desc.Handler}).Handler()
+		desc.Handler = (&sendWrapper{handler: desc.Handler, defaults: i.defaults}).Handler()
 		return streamer(ctx, desc, cc, method)
 	}
 }
 
 type recvWrapper struct {
 	grpc.ServerStream
+	defaults func(v interface{})
 }
 
 func (s *recvWrapper) RecvMsg(m interface{}) error {
 	if err := s.ServerStream.RecvMsg(m); err != nil {
 		return err
 	}
-	defaults(m)
+	s.defaults(m)
 	return nil
 }
 
 type sendWrapper struct {
 	grpc.ServerStream
-	handler grpc.StreamHandler
+	handler  grpc.StreamHandler
+	defaults func(v interface{})
 }
 
 func (s *sendWrapper) Handler() grpc.StreamHandler {
@@ -72,6 +91,6 @@ func (s *sendWrapper) Handler() grpc.StreamHandler {
 }
 
 func (s *sendWrapper) SendMsg(m interface{}) error {
-	defaults(m)
+	s.defaults(m)
 	return s.ServerStream.SendMsg(m)
 }
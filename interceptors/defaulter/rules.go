@@ -0,0 +1,167 @@
+package defaulter
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Normalizer transforms a string field value
+type Normalizer func(string) string
+
+var (
+	Trim  Normalizer = strings.TrimSpace
+	Lower Normalizer = strings.ToLower
+	Upper Normalizer = strings.ToUpper
+)
+
+// Rule declares the default value and the normalization of a message field
+type Rule struct {
+	// Field is the dot separated path of the field in the message, e.g. "user.email"
+	Field string
+	// Default is set when the field is not set, it must be convertible to the field type,
+	// e.g. an int for an int32 field or a protoreflect.EnumNumber for an enum field
+	Default interface{}
+	// Normalize are applied in order to the string fields, including the repeated ones
+	Normalize []Normalizer
+}
+
+type Option func(o *options)
+
+// WithRules declares the rules applied to the messages of the same type as m, before their generated Default method.
+// It panics if a rule does not match the message fields.
+func WithRules(m proto.Message, rules ...Rule) Option {
+	md := m.ProtoReflect().Descriptor()
+	var compiled []rule
+	for _, v := range rules {
+		r, err := compile(md, v)
+		if err != nil {
+			panic(fmt.Sprintf("defaulter: %s: %v", md.FullName(), err))
+		}
+		compiled = append(compiled, r)
+	}
+	return func(o *options) {
+		o.rules[md.FullName()] = append(o.rules[md.FullName()], compiled...)
+	}
+}
+
+type options struct {
+	rules map[protoreflect.FullName][]rule
+}
+
+type rule struct {
+	path      []protoreflect.FieldDescriptor
+	def       protoreflect.Value
+	hasDef    bool
+	normalize []Normalizer
+}
+
+func compile(md protoreflect.MessageDescriptor, r Rule) (rule, error) {
+	var out rule
+	for i, name := range strings.Split(r.Field, ".") {
+		if md == nil {
+			return out, fmt.Errorf("%s: %s is not a message field", r.Field, out.path[i-1].Name())
+		}
+		fd := md.Fields().ByName(protoreflect.Name(name))
+		if fd == nil {
+			return out, fmt.Errorf("%s: unknown field %s", r.Field, name)
+		}
+		out.path = append(out.path, fd)
+		md = nil
+		if fd.Message() != nil && !fd.IsList() && !fd.IsMap() {
+			md = fd.Message()
+		}
+	}
+	fd := out.path[len(out.path)-1]
+	if len(r.Normalize) != 0 && (fd.Kind() != protoreflect.StringKind || fd.IsMap()) {
+		return out, fmt.Errorf("%s: normalizers only apply to string fields", r.Field)
+	}
+	out.normalize = r.Normalize
+	if r.Default != nil {
+		if fd.IsList() || fd.IsMap() || fd.Message() != nil {
+			return out, fmt.Errorf("%s: defaults only apply to scalar fields", r.Field)
+		}
+		v, err := toValue(fd, r.Default)
+		if err != nil {
+			return out, fmt.Errorf("%s: %w", r.Field, err)
+		}
+		out.def, out.hasDef = v, true
+	}
+	return out, nil
+}
+
+// toValue converts v to the type of the field
+func toValue(fd protoreflect.FieldDescriptor, v interface{}) (protoreflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	var target reflect.Type
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		target = reflect.TypeOf("")
+	case protoreflect.BytesKind:
+		target = reflect.TypeOf([]byte(nil))
+	case protoreflect.BoolKind:
+		target = reflect.TypeOf(false)
+	case protoreflect.EnumKind:
+		target = reflect.TypeOf(protoreflect.EnumNumber(0))
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		target = reflect.TypeOf(int32(0))
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		target = reflect.TypeOf(int64(0))
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		target = reflect.TypeOf(uint32(0))
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		target = reflect.TypeOf(uint64(0))
+	case protoreflect.FloatKind:
+		target = reflect.TypeOf(float32(0))
+	case protoreflect.DoubleKind:
+		target = reflect.TypeOf(float64(0))
+	default:
+		return protoreflect.Value{}, fmt.Errorf("unsupported field kind %s", fd.Kind())
+	}
+	if !rv.Type().ConvertibleTo(target) || (rv.Kind() == reflect.String) != (target.Kind() == reflect.String) {
+		return protoreflect.Value{}, fmt.Errorf("%T is not convertible to %s", v, fd.Kind())
+	}
+	return protoreflect.ValueOf(rv.Convert(target).Interface()), nil
+}
+
+func (r rule) apply(m protoreflect.Message) {
+	r.applyPath(m, r.path)
+}
+
+func (r rule) applyPath(m protoreflect.Message, path []protoreflect.FieldDescriptor) {
+	fd := path[0]
+	if len(path) > 1 {
+		if !m.Has(fd) {
+			// only create the parent messages when a default has to be set
+			if !r.hasDef {
+				return
+			}
+		}
+		r.applyPath(m.Mutable(fd).Message(), path[1:])
+		return
+	}
+	if r.hasDef && !m.Has(fd) {
+		m.Set(fd, r.def)
+	}
+	if len(r.normalize) == 0 || !m.Has(fd) {
+		return
+	}
+	if fd.IsList() {
+		l := m.Mutable(fd).List()
+		for i := 0; i < l.Len(); i++ {
+			l.Set(i, protoreflect.ValueOfString(r.normalizeString(l.Get(i).String())))
+		}
+		return
+	}
+	m.Set(fd, protoreflect.ValueOfString(r.normalizeString(m.Get(fd).String())))
+}
+
+func (r rule) normalizeString(s string) string {
+	for _, fn := range r.normalize {
+		s = fn(s)
+	}
+	return s
+}
@@ -0,0 +1,59 @@
+package defaulter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestRules(t *testing.T) {
+	i := NewInterceptors(
+		WithRules(&descriptorpb.FieldDescriptorProto{},
+			Rule{Field: "name", Normalize: []Normalizer{Trim, Lower}},
+			Rule{Field: "number", Default: 1},
+			Rule{Field: "label", Default: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL},
+			Rule{Field: "options.deprecated", Default: true},
+		),
+		WithRules(&descriptorpb.DescriptorProto{}, Rule{Field: "reserved_name", Normalize: []Normalizer{Upper}}),
+	)
+	var got interface{}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		got = req
+		return nil, nil
+	}
+	_, err := i.UnaryServerInterceptor()(context.Background(), &descriptorpb.FieldDescriptorProto{Name: proto.String("  Name ")}, &grpc.UnaryServerInfo{}, handler)
+	assert.NoError(t, err)
+	assert.True(t, proto.Equal(&descriptorpb.FieldDescriptorProto{
+		Name:    proto.String("name"),
+		Number:  proto.Int32(1),
+		Label:   descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Options: &descriptorpb.FieldOptions{Deprecated: proto.Bool(true)},
+	}, got.(proto.Message)), got)
+
+	// set values are kept
+	req := &descriptorpb.FieldDescriptorProto{Number: proto.Int32(2), Options: &descriptorpb.FieldOptions{Deprecated: proto.Bool(false)}}
+	_, err = i.UnaryServerInterceptor()(context.Background(), req, &grpc.UnaryServerInfo{}, handler)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), req.GetNumber())
+	assert.False(t, req.GetOptions().GetDeprecated())
+	assert.Nil(t, req.Name)
+
+	req2 := &descriptorpb.DescriptorProto{ReservedName: []string{"a", "b"}}
+	_, err = i.UnaryServerInterceptor()(context.Background(), req2, &grpc.UnaryServerInfo{}, handler)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"A", "B"}, req2.ReservedName)
+}
+
+func TestInvalidRules(t *testing.T) {
+	m := &descriptorpb.FieldDescriptorProto{}
+	assert.Panics(t, func() { WithRules(m, Rule{Field: "unknown"}) })
+	assert.Panics(t, func() { WithRules(m, Rule{Field: "name.value"}) })
+	assert.Panics(t, func() { WithRules(m, Rule{Field: "number", Normalize: []Normalizer{Trim}}) })
+	assert.Panics(t, func() { WithRules(m, Rule{Field: "number", Default: "1"}) })
+	assert.Panics(t, func() { WithRules(m, Rule{Field: "name", Default: 1}) })
+	assert.Panics(t, func() { WithRules(m, Rule{Field: "options", Default: true}) })
+}
@@ -0,0 +1,235 @@
+// Package egress restricts the methods a service may call to an allowlist, e.g. to enforce the compliance
+// boundaries between the services of a monorepo. The policies are loaded from a watched configuration shared
+// by the services, the violations are either logged or rejected.
+package egress
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go.linka.cloud/grpc/config"
+	"go.linka.cloud/grpc/interceptors"
+	"go.linka.cloud/grpc/logger"
+	"go.linka.cloud/grpc/match"
+)
+
+// Mode is the action taken on the calls which are not allowed
+type Mode string
+
+const (
+	// Enforce rejects the calls with a PermissionDenied error
+	Enforce Mode = "enforce"
+	// Audit only logs the calls, e.g. while rolling out a policy
+	Audit Mode = "audit"
+)
+
+// Policy is the egress allowlist of a service
+type Policy struct {
+	// Mode defaults to Enforce
+	Mode Mode `json:"mode,omitempty"`
+	// Allow lists the methods the service may call, e.g. /pkg.Service/Method, all the methods of a service
+	// using its prefix, e.g. /pkg.Service/, or a prefix ending with *, e.g. /pkg.v1.*
+	Allow []string `json:"allow"`
+}
+
+func (p Policy) validate() error {
+	switch p.Mode {
+	case Enforce, Audit, "":
+	default:
+		return fmt.Errorf("egress: invalid mode %q", p.Mode)
+	}
+	for _, v := range p.Allow {
+		if v != "*" && !strings.HasPrefix(v, "/") {
+			return fmt.Errorf("egress: invalid method %q", v)
+		}
+	}
+	return nil
+}
+
+// Allowed reports whether the policy allows the full method
+func (p Policy) Allowed(method string) bool {
+	for _, v := range p.Allow {
+		switch {
+		case v == method:
+			return true
+		case strings.HasSuffix(v, "*") && strings.HasPrefix(method, strings.TrimSuffix(v, "*")):
+			return true
+		case strings.HasSuffix(v, "/") && strings.HasPrefix(method, v):
+			return true
+		}
+	}
+	return false
+}
+
+// Config is the egress configuration shared by the services, keyed by the calling service name,
+// the "*" entry applying to the services without one. The services without policy may call any method.
+type Config map[string]Policy
+
+// ParseConfig parses a json Config, e.g. {"billing": {"allow": ["/payments.v1.Payments/"]}}
+func ParseConfig(b []byte) (Config, error) {
+	var c Config
+	if len(strings.TrimSpace(string(b))) == 0 {
+		return nil, nil
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	for k, v := range c {
+		if err := v.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+	}
+	return c, nil
+}
+
+type ClientInterceptors interface {
+	interceptors.ClientInterceptors
+	prometheus.Collector
+	// SetPolicy replaces the policy, a nil policy allows all the calls
+	SetPolicy(p *Policy) error
+	// Policy returns the current policy, it is nil if the calls are not restricted
+	Policy() *Policy
+	// Watch loads the service policy from the config and keeps it up to date until the context is done.
+	// The configuration is a json Config.
+	Watch(ctx context.Context, c config.Config) error
+}
+
+// NewClientInterceptors returns interceptors enforcing the egress policy of the service calling
+func NewClientInterceptors(service string, p *Policy) (ClientInterceptors, error) {
+	i := &interceptor{
+		service: service,
+		violations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_client_egress_violations_total",
+			Help: "Total number of outgoing RPCs not allowed by the egress policy, by action: rejected or logged.",
+		}, []string{"grpc_service", "grpc_method", "action"}),
+	}
+	if err := i.SetPolicy(p); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+type interceptor struct {
+	service    string
+	mu         sync.RWMutex
+	policy     *Policy
+	violations *prometheus.CounterVec
+}
+
+func (i *interceptor) SetPolicy(p *Policy) error {
+	if p != nil {
+		if err := p.validate(); err != nil {
+			return err
+		}
+	}
+	i.mu.Lock()
+	i.policy = p
+	i.mu.Unlock()
+	return nil
+}
+
+func (i *interceptor) Policy() *Policy {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.policy
+}
+
+// lookup returns the service policy from the config
+func (i *interceptor) lookup(c Config) *Policy {
+	if p, ok := c[i.service]; ok {
+		return &p
+	}
+	if p, ok := c["*"]; ok {
+		return &p
+	}
+	return nil
+}
+
+func (i *interceptor) load(b []byte) error {
+	c, err := ParseConfig(b)
+	if err != nil {
+		return err
+	}
+	return i.SetPolicy(i.lookup(c))
+}
+
+func (i *interceptor) Watch(ctx context.Context, c config.Config) error {
+	b, err := c.Read()
+	if err != nil {
+		return err
+	}
+	if err := i.load(b); err != nil {
+		return err
+	}
+	updates := make(chan []byte)
+	if err := c.Watch(ctx, updates); err != nil {
+		return err
+	}
+	go func() {
+		log := logger.C(ctx)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case b := <-updates:
+				if err := i.load(b); err != nil {
+					log.WithError(err).Error("failed to load egress policy")
+					continue
+				}
+				log.Info("egress policy updated")
+			}
+		}
+	}()
+	return nil
+}
+
+func (i *interceptor) Describe(descs chan<- *prometheus.Desc) {
+	i.violations.Describe(descs)
+}
+
+func (i *interceptor) Collect(c chan<- prometheus.Metric) {
+	i.violations.Collect(c)
+}
+
+func (i *interceptor) check(ctx context.Context, method string) error {
+	p := i.Policy()
+	if p == nil || p.Allowed(method) {
+		return nil
+	}
+	s, m := match.Split(method)
+	log := logger.C(ctx).WithFields("service", i.service, "method", method)
+	if p.Mode == Audit {
+		i.violations.WithLabelValues(s, m, "logged").Inc()
+		log.Warn("egress policy violation")
+		return nil
+	}
+	i.violations.WithLabelValues(s, m, "rejected").Inc()
+	log.Error("egress policy violation: call rejected")
+	return status.Errorf(codes.PermissionDenied, "%s: %s is not allowed to call this method", method, i.service)
+}
+
+func (i *interceptor) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if err := i.check(ctx, method); err != nil {
+			return err
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+func (i *interceptor) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if err := i.check(ctx, method); err != nil {
+			return nil, err
+		}
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
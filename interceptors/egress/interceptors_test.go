@@ -0,0 +1,55 @@
+package egress
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestEgress(t *testing.T) {
+	e, err := NewClientInterceptors("billing", nil)
+	require.NoError(t, err)
+	i := e.UnaryClientInterceptor()
+	call := func(method string) error {
+		return i(context.Background(), method, nil, nil, nil, func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			return nil
+		})
+	}
+	// no policy allows all the calls
+	assert.NoError(t, call("/payments.v1.Payments/Charge"))
+
+	assert.Error(t, e.SetPolicy(&Policy{Allow: []string{"payments.v1.Payments/"}}))
+	assert.Error(t, e.SetPolicy(&Policy{Mode: "block"}))
+
+	require.NoError(t, e.SetPolicy(&Policy{Allow: []string{"/payments.v1.Payments/", "/users.v1.*", "/audit.Audit/Log"}}))
+	assert.NoError(t, call("/payments.v1.Payments/Charge"))
+	assert.NoError(t, call("/users.v1.Users/Get"))
+	assert.NoError(t, call("/audit.Audit/Log"))
+	assert.Equal(t, codes.PermissionDenied, status.Code(call("/audit.Audit/Delete")))
+	assert.Equal(t, codes.PermissionDenied, status.Code(call("/orders.v1.Orders/List")))
+
+	// audit mode only logs the violations
+	require.NoError(t, e.SetPolicy(&Policy{Mode: Audit}))
+	assert.NoError(t, call("/orders.v1.Orders/List"))
+}
+
+func TestConfig(t *testing.T) {
+	c, err := ParseConfig([]byte(`{"billing": {"allow": ["/payments.v1.Payments/"]}, "*": {"mode": "audit", "allow": ["*"]}}`))
+	require.NoError(t, err)
+	assert.True(t, c["billing"].Allowed("/payments.v1.Payments/Charge"))
+	assert.False(t, c["billing"].Allowed("/users.v1.Users/Get"))
+	assert.True(t, c["*"].Allowed("/users.v1.Users/Get"))
+	_, err = ParseConfig([]byte(`{"billing": {"allow": ["invalid"]}}`))
+	assert.Error(t, err)
+
+	i := &interceptor{service: "billing"}
+	assert.Equal(t, []string{"/payments.v1.Payments/"}, i.lookup(c).Allow)
+	i.service = "orders"
+	assert.Equal(t, Audit, i.lookup(c).Mode)
+	assert.Nil(t, i.lookup(Config{}))
+}
@@ -0,0 +1,66 @@
+package killswitch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestKillSwitch(t *testing.T) {
+	ks := NewServerInterceptors()
+	i := ks.UnaryServerInterceptor()
+	call := func(method string) error {
+		_, err := i(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+		return err
+	}
+	assert.NoError(t, call("/svc.Service/Get"))
+
+	assert.Error(t, ks.Disable(Rule{Method: "svc.Service/Get"}))
+	assert.Error(t, ks.Disable(Rule{Method: "/svc.Service/Get", Code: "INTERNAL"}))
+	require.NoError(t, ks.Disable(Rule{Method: "/svc.Service/", Code: Unavailable, Message: "maintenance"}))
+	require.NoError(t, ks.Disable(Rule{Method: "/svc.Service/Get"}))
+
+	assert.Equal(t, codes.Unimplemented, status.Code(call("/svc.Service/Get")))
+	err := call("/svc.Service/List")
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Contains(t, err.Error(), "maintenance")
+	assert.NoError(t, call("/other.Service/Get"))
+
+	assert.True(t, ks.Enable("/svc.Service/"))
+	assert.False(t, ks.Enable("/svc.Service/"))
+	assert.NoError(t, call("/svc.Service/List"))
+
+	rules, err := ParseRules([]byte(`[{"method": "/a.A/", "code": "UNAVAILABLE"}]`))
+	require.NoError(t, err)
+	require.NoError(t, ks.SetRules(rules...))
+	assert.Equal(t, []Rule{{Method: "/a.A/", Code: Unavailable}}, ks.Rules())
+}
+
+func TestHandler(t *testing.T) {
+	ks := NewServerInterceptors()
+	h := ks.Handler()
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPut, "/", `{"method": "invalid"}`).Code)
+	w := do(http.MethodPut, "/", `{"method": "/svc.Service/Get", "message": "bug"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[{"method": "/svc.Service/Get", "message": "bug"}]`, w.Body.String())
+	_, ok := ks.Disabled("/svc.Service/Get")
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, "/?method=/svc.Service/Get", "").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodDelete, "/?method=/svc.Service/Get", "").Code)
+	assert.JSONEq(t, `[]`, do(http.MethodGet, "/", "").Body.String())
+}
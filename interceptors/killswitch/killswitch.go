@@ -0,0 +1,255 @@
+// Package killswitch disables methods at runtime, e.g. a buggy endpoint, without redeploying the service.
+// The methods are disabled through the interceptors api, their admin http handler or a watched configuration.
+package killswitch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go.linka.cloud/grpc/config"
+	"go.linka.cloud/grpc/interceptors"
+	"go.linka.cloud/grpc/logger"
+	"go.linka.cloud/grpc/match"
+)
+
+// Code is the status code returned by a disabled method
+type Code string
+
+const (
+	// Unimplemented reports the method as not implemented, the clients should not retry
+	Unimplemented Code = "UNIMPLEMENTED"
+	// Unavailable reports the method as temporarily unavailable, the clients may retry later
+	Unavailable Code = "UNAVAILABLE"
+)
+
+func (c Code) grpc() (codes.Code, error) {
+	switch c {
+	case Unimplemented, "":
+		return codes.Unimplemented, nil
+	case Unavailable:
+		return codes.Unavailable, nil
+	}
+	return 0, fmt.Errorf("killswitch: invalid code %q", c)
+}
+
+// Rule disables the methods matching its pattern, e.g. /pkg.Service/Method, /pkg.Service/Get* or /pkg.Service/
+// for all the methods of a service. The most specific rule matching a method wins.
+type Rule struct {
+	Method  string `json:"method"`
+	Code    Code   `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+func (r Rule) validate() error {
+	if !strings.HasPrefix(r.Method, "/") && r.Method != "*" {
+		return fmt.Errorf("killswitch: invalid method %q", r.Method)
+	}
+	_, err := r.Code.grpc()
+	return err
+}
+
+type ServerInterceptors interface {
+	interceptors.ServerInterceptors
+	prometheus.Collector
+	// Disable disables the rule method, replacing its previous rule
+	Disable(r Rule) error
+	// Enable enables back the method, it returns false if it was not disabled
+	Enable(method string) bool
+	// SetRules replaces all the rules, e.g. on configuration reload
+	SetRules(rules ...Rule) error
+	// Rules returns the rules sorted by method
+	Rules() []Rule
+	// Disabled returns the rule disabling the full method, the method rule takes precedence over its service one
+	Disabled(method string) (Rule, bool)
+	// Watch loads the rules from the config and keeps them up to date until the context is done.
+	// The configuration is a json list of rules, e.g. [{"method": "/pkg.Service/Method", "code": "UNAVAILABLE"}].
+	Watch(ctx context.Context, c config.Config) error
+	// Handler returns the admin http handler: GET lists the rules, PUT disables the method of the json rule
+	// and DELETE enables back the method query parameter, e.g. service.WithAdminHandler("/killswitch", ks.Handler())
+	Handler() http.Handler
+}
+
+// NewServerInterceptors returns interceptors rejecting the calls to the disabled methods
+func NewServerInterceptors() ServerInterceptors {
+	return &interceptor{
+		rules: make(map[string]Rule),
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_server_killswitch_rejected_total",
+			Help: "Total number of RPCs rejected because their method is disabled.",
+		}, []string{"grpc_service", "grpc_method"}),
+	}
+}
+
+type interceptor struct {
+	mu       sync.RWMutex
+	rules    map[string]Rule
+	rejected *prometheus.CounterVec
+}
+
+func (i *interceptor) Disable(r Rule) error {
+	if err := r.validate(); err != nil {
+		return err
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.rules[r.Method] = r
+	return nil
+}
+
+func (i *interceptor) Enable(method string) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	_, ok := i.rules[method]
+	delete(i.rules, method)
+	return ok
+}
+
+func (i *interceptor) SetRules(rules ...Rule) error {
+	m := make(map[string]Rule, len(rules))
+	for _, v := range rules {
+		if err := v.validate(); err != nil {
+			return err
+		}
+		m[v.Method] = v
+	}
+	i.mu.Lock()
+	i.rules = m
+	i.mu.Unlock()
+	return nil
+}
+
+func (i *interceptor) Rules() []Rule {
+	i.mu.RLock()
+	out := make([]Rule, 0, len(i.rules))
+	for _, v := range i.rules {
+		out = append(out, v)
+	}
+	i.mu.RUnlock()
+	sort.Slice(out, func(a, b int) bool {
+		return out[a].Method < out[b].Method
+	})
+	return out
+}
+
+func (i *interceptor) Disabled(method string) (Rule, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	if len(i.rules) == 0 {
+		return Rule{}, false
+	}
+	if r, ok := i.rules[method]; ok {
+		return r, true
+	}
+	var (
+		r       Rule
+		pattern string
+		found   bool
+	)
+	for k, v := range i.rules {
+		if match.Method(k, method) && (!found || match.Specific(k, pattern)) {
+			r, pattern, found = v, k, true
+		}
+	}
+	return r, found
+}
+
+// ParseRules parses a json list of rules
+func ParseRules(b []byte) ([]Rule, error) {
+	var rules []Rule
+	if len(strings.TrimSpace(string(b))) == 0 {
+		return nil, nil
+	}
+	if err := json.Unmarshal(b, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (i *interceptor) Watch(ctx context.Context, c config.Config) error {
+	b, err := c.Read()
+	if err != nil {
+		return err
+	}
+	rules, err := ParseRules(b)
+	if err != nil {
+		return err
+	}
+	if err := i.SetRules(rules...); err != nil {
+		return err
+	}
+	updates := make(chan []byte)
+	if err := c.Watch(ctx, updates); err != nil {
+		return err
+	}
+	go func() {
+		log := logger.C(ctx)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case b := <-updates:
+				rules, err := ParseRules(b)
+				if err == nil {
+					err = i.SetRules(rules...)
+				}
+				if err != nil {
+					log.WithError(err).Error("failed to load killswitch rules")
+					continue
+				}
+				log.Info("killswitch rules updated")
+			}
+		}
+	}()
+	return nil
+}
+
+func (i *interceptor) Describe(descs chan<- *prometheus.Desc) {
+	i.rejected.Describe(descs)
+}
+
+func (i *interceptor) Collect(c chan<- prometheus.Metric) {
+	i.rejected.Collect(c)
+}
+
+func (i *interceptor) check(method string) error {
+	r, ok := i.Disabled(method)
+	if !ok {
+		return nil
+	}
+	s, m := match.Split(method)
+	i.rejected.WithLabelValues(s, m).Inc()
+	c, _ := r.Code.grpc()
+	msg := r.Message
+	if msg == "" {
+		msg = "method disabled"
+	}
+	return status.Errorf(c, "%s: %s", method, msg)
+}
+
+func (i *interceptor) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := i.check(info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (i *interceptor) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := i.check(info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
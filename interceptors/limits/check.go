@@ -0,0 +1,87 @@
+package limits
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	limitDepth    = "depth"
+	limitRepeated = "repeated"
+	limitString   = "string"
+	limitBytes    = "bytes"
+)
+
+// Error reports the first limit exceeded by a message
+type Error struct {
+	// Limit is one of depth, repeated, string or bytes
+	Limit string
+	// Field is the full name of the field exceeding the limit
+	Field string
+	Max   int
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s exceeds the %s limit (%d)", e.Field, e.Limit, e.Max)
+}
+
+// Check returns an *Error if the message exceeds the limits
+func Check(m protoreflect.Message, l Limits) error {
+	if l == (Limits{}) {
+		return nil
+	}
+	return check(m, l, 1)
+}
+
+func check(m protoreflect.Message, l Limits, depth int) (err error) {
+	if l.MaxDepth > 0 && depth > l.MaxDepth {
+		return &Error{Limit: limitDepth, Field: string(m.Descriptor().FullName()), Max: l.MaxDepth}
+	}
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsList():
+			list := v.List()
+			if l.MaxRepeated > 0 && list.Len() > l.MaxRepeated {
+				err = &Error{Limit: limitRepeated, Field: string(fd.FullName()), Max: l.MaxRepeated}
+				return false
+			}
+			for i := 0; i < list.Len() && err == nil; i++ {
+				err = checkValue(fd, list.Get(i), l, depth)
+			}
+		case fd.IsMap():
+			mp := v.Map()
+			if l.MaxRepeated > 0 && mp.Len() > l.MaxRepeated {
+				err = &Error{Limit: limitRepeated, Field: string(fd.FullName()), Max: l.MaxRepeated}
+				return false
+			}
+			mp.Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+				if err = checkValue(fd.MapKey(), k.Value(), l, depth); err != nil {
+					return false
+				}
+				err = checkValue(fd.MapValue(), v, l, depth)
+				return err == nil
+			})
+		default:
+			err = checkValue(fd, v, l, depth)
+		}
+		return err == nil
+	})
+	return err
+}
+
+func checkValue(fd protoreflect.FieldDescriptor, v protoreflect.Value, l Limits, depth int) error {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		if l.MaxStringLength > 0 && len(v.String()) > l.MaxStringLength {
+			return &Error{Limit: limitString, Field: string(fd.FullName()), Max: l.MaxStringLength}
+		}
+	case protoreflect.BytesKind:
+		if l.MaxBytesLength > 0 && len(v.Bytes()) > l.MaxBytesLength {
+			return &Error{Limit: limitBytes, Field: string(fd.FullName()), Max: l.MaxBytesLength}
+		}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return check(v.Message(), l, depth+1)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package limits
+
+import (
+	"context"
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+
+	errors2 "go.linka.cloud/grpc/errors"
+	"go.linka.cloud/grpc/interceptors"
+	"go.linka.cloud/grpc/match"
+)
+
+type ServerInterceptors interface {
+	interceptors.ServerInterceptors
+	prometheus.Collector
+}
+
+// NewServerInterceptors returns interceptors enforcing limits on the messages structure before the handler
+// is invoked: depth, repeated fields elements, strings and bytes lengths. The raw size is limited by the server
+// max receive size, these limits protect the handlers from the complex or decompressed messages.
+// The requests exceeding the limits are rejected with InvalidArgument.
+func NewServerInterceptors(opts ...Option) ServerInterceptors {
+	o := options{limits: DefaultLimits, methods: make(map[string]Limits)}
+	for _, v := range opts {
+		v(&o)
+	}
+	return &interceptor{
+		o: o,
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_server_limits_rejected_total",
+			Help: "Total number of messages rejected by the limits by direction and limit.",
+		}, []string{"grpc_service", "grpc_method", "direction", "limit"}),
+	}
+}
+
+type interceptor struct {
+	o        options
+	rejected *prometheus.CounterVec
+}
+
+// limits returns the limits of the most specific pattern matching the method
+func (i *interceptor) limits(method string) Limits {
+	if l, ok := i.o.methods[method]; ok {
+		return l
+	}
+	var (
+		l       = i.o.limits
+		pattern string
+		found   bool
+	)
+	for k, v := range i.o.methods {
+		if match.Method(k, method) && (!found || match.Specific(k, pattern)) {
+			l, pattern, found = v, k, true
+		}
+	}
+	return l
+}
+
+func (i *interceptor) check(method string, l Limits, msg interface{}, request bool) error {
+	m, ok := msg.(proto.Message)
+	if !ok {
+		return nil
+	}
+	err := Check(m.ProtoReflect(), l)
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if !errors.As(err, &e) {
+		return err
+	}
+	dir := "response"
+	if request {
+		dir = "request"
+	}
+	s, n := match.Split(method)
+	i.rejected.WithLabelValues(s, n, dir, e.Limit).Inc()
+	if request {
+		return errors2.InvalidArgumentf("%s: %v", method, e)
+	}
+	return errors2.Internalf("%s: response %v", method, e)
+}
+
+func (i *interceptor) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		l := i.limits(info.FullMethod)
+		if err := i.check(info.FullMethod, l, req, true); err != nil {
+			return nil, err
+		}
+		res, err := handler(ctx, req)
+		if err != nil || !i.o.responses {
+			return res, err
+		}
+		if err := i.check(info.FullMethod, l, res, false); err != nil {
+			return nil, err
+		}
+		return res, nil
+	}
+}
+
+func (i *interceptor) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &stream{ServerStream: ss, i: i, method: info.FullMethod, l: i.limits(info.FullMethod)})
+	}
+}
+
+type stream struct {
+	grpc.ServerStream
+	i      *interceptor
+	method string
+	l      Limits
+}
+
+func (s *stream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return s.i.check(s.method, s.l, m, true)
+}
+
+func (s *stream) SendMsg(m interface{}) error {
+	if s.i.o.responses {
+		if err := s.i.check(s.method, s.l, m, false); err != nil {
+			return err
+		}
+	}
+	return s.ServerStream.SendMsg(m)
+}
+
+func (i *interceptor) Describe(descs chan<- *prometheus.Desc) {
+	i.rejected.Describe(descs)
+}
+
+func (i *interceptor) Collect(c chan<- prometheus.Metric) {
+	i.rejected.Collect(c)
+}
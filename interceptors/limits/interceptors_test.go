@@ -0,0 +1,70 @@
+package limits
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func nested(depth int) *structpb.Struct {
+	s := &structpb.Struct{Fields: map[string]*structpb.Value{}}
+	if depth > 0 {
+		s.Fields["child"] = structpb.NewStructValue(nested(depth - 1))
+	}
+	return s
+}
+
+func TestCheck(t *testing.T) {
+	// each struct level is a Struct and a Value message
+	assert.NoError(t, Check(nested(3).ProtoReflect(), Limits{MaxDepth: 8}))
+	var e *Error
+	err := Check(nested(10).ProtoReflect(), Limits{MaxDepth: 8})
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, limitDepth, e.Limit)
+
+	l, err := structpb.NewList([]interface{}{1, 2, 3})
+	require.NoError(t, err)
+	err = Check(l.ProtoReflect(), Limits{MaxRepeated: 2})
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, limitRepeated, e.Limit)
+	assert.Equal(t, "google.protobuf.ListValue.values", e.Field)
+
+	s, err := structpb.NewStruct(map[string]interface{}{"key": strings.Repeat("a", 10)})
+	require.NoError(t, err)
+	assert.NoError(t, Check(s.ProtoReflect(), Limits{MaxStringLength: 10}))
+	err = Check(s.ProtoReflect(), Limits{MaxStringLength: 5})
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, limitString, e.Limit)
+	// map keys are checked too
+	s, err = structpb.NewStruct(map[string]interface{}{strings.Repeat("k", 10): 1})
+	require.NoError(t, err)
+	assert.Error(t, Check(s.ProtoReflect(), Limits{MaxStringLength: 5}))
+}
+
+func TestInterceptors(t *testing.T) {
+	i := NewServerInterceptors(
+		WithLimits(Limits{MaxDepth: 8}),
+		WithMethodLimits("/svc.Service/", Limits{MaxDepth: 100}),
+		WithMethodLimits("/svc.Service/Strict", Limits{MaxDepth: 2}),
+		WithResponses(),
+	).UnaryServerInterceptor()
+	call := func(method string, req, res interface{}) error {
+		_, err := i(context.Background(), req, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return res, nil
+		})
+		return err
+	}
+	deep := nested(10)
+	assert.Equal(t, codes.InvalidArgument, status.Code(call("/other.Service/Get", deep, nil)))
+	assert.NoError(t, call("/svc.Service/Get", deep, nil))
+	assert.Equal(t, codes.InvalidArgument, status.Code(call("/svc.Service/Strict", nested(1), nil)))
+	assert.Equal(t, codes.Internal, status.Code(call("/other.Service/Get", nested(0), deep)))
+}
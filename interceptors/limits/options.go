@@ -0,0 +1,46 @@
+package limits
+
+// Limits are the message limits, zero means unlimited
+type Limits struct {
+	// MaxDepth is the maximum nesting depth of the messages, the top level message depth is 1
+	MaxDepth int
+	// MaxRepeated is the maximum number of elements of a repeated or map field
+	MaxRepeated int
+	// MaxStringLength is the maximum length in bytes of a string field
+	MaxStringLength int
+	// MaxBytesLength is the maximum length of a bytes field
+	MaxBytesLength int
+}
+
+// DefaultLimits only limits the messages depth, the raw size being limited by the server max receive size
+var DefaultLimits = Limits{MaxDepth: 32}
+
+type Option func(o *options)
+
+// WithLimits sets the limits of the methods without specific limits, defaults to DefaultLimits
+func WithLimits(l Limits) Option {
+	return func(o *options) {
+		o.limits = l
+	}
+}
+
+// WithMethodLimits sets the limits of the methods matching the pattern, e.g. /pkg.Service/Method, /pkg.Service/Get*
+// or /pkg.Service/ for all the methods of a service. The most specific pattern matching a method wins.
+func WithMethodLimits(method string, l Limits) Option {
+	return func(o *options) {
+		o.methods[method] = l
+	}
+}
+
+// WithResponses also checks the responses, a response exceeding the limits fails with Internal
+func WithResponses() Option {
+	return func(o *options) {
+		o.responses = true
+	}
+}
+
+type options struct {
+	limits    Limits
+	methods   map[string]Limits
+	responses bool
+}
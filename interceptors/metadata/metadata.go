@@ -2,6 +2,7 @@ package metadata
 
 import (
 	"context"
+	"strings"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
@@ -9,17 +10,124 @@ import (
 	"go.linka.cloud/grpc/interceptors"
 )
 
+// NewInterceptors returns interceptors sending the static key value pairs as server headers and client metadata
 func NewInterceptors(pairs ...string) interceptors.Interceptors {
-	return mdInterceptors{pairs: pairs}
+	return New(WithPairs(pairs...))
+}
+
+// New returns interceptors sending the configured values as server headers and client metadata.
+// The static values are merged once, the calls only compute the dynamic ones. The empty values are dropped.
+func New(opts ...Option) interceptors.Interceptors {
+	o := options{}
+	for _, v := range opts {
+		v(&o)
+	}
+	i := mdInterceptors{o: o, static: metadata.MD{}}
+	for _, v := range o.values {
+		if v.fn != nil {
+			i.dynamic = append(i.dynamic, v)
+			continue
+		}
+		if v.v == "" {
+			continue
+		}
+		i.static.Append(v.key, v.v)
+		i.pairs = append(i.pairs, strings.ToLower(v.key), v.v)
+	}
+	// the static values are shared by the calls, appending to them must not write to their backing arrays
+	for k, v := range i.static {
+		i.static[k] = v[:len(v):len(v)]
+	}
+	return i
 }
 
 type mdInterceptors struct {
-	pairs []string
+	o options
+	// static are the static values, as metadata and as key value pairs
+	static  metadata.MD
+	pairs   []string
+	dynamic []value
+}
+
+// md returns the call values, it must not be modified
+func (i mdInterceptors) md(ctx context.Context) metadata.MD {
+	if len(i.dynamic) == 0 {
+		return i.static
+	}
+	md := i.static.Copy()
+	for _, v := range i.dynamic {
+		if s := v.fn(ctx); s != "" {
+			md.Append(v.key, s)
+		}
+	}
+	return md
+}
+
+// owned returns the call values, owned by the call so that they can be handed over
+func (i mdInterceptors) owned(ctx context.Context) metadata.MD {
+	if len(i.dynamic) == 0 {
+		return i.static.Copy()
+	}
+	return i.md(ctx)
+}
+
+// kv returns the call values as key value pairs, it must not be modified
+func (i mdInterceptors) kv(ctx context.Context) []string {
+	if len(i.dynamic) == 0 {
+		return i.pairs
+	}
+	kv := append(make([]string, 0, len(i.pairs)+2*len(i.dynamic)), i.pairs...)
+	for _, v := range i.dynamic {
+		if s := v.fn(ctx); s != "" {
+			kv = append(kv, v.key, s)
+		}
+	}
+	return kv
+}
+
+func (i mdInterceptors) outgoing(ctx context.Context) context.Context {
+	if i.o.mode == Append {
+		kv := i.kv(ctx)
+		if len(kv) == 0 {
+			return ctx
+		}
+		return metadata.AppendToOutgoingContext(ctx, kv...)
+	}
+	if len(i.static) == 0 && len(i.dynamic) == 0 {
+		return ctx
+	}
+	out, ok := metadata.FromOutgoingContext(ctx)
+	if !ok {
+		if md := i.owned(ctx); len(md) != 0 {
+			return metadata.NewOutgoingContext(ctx, md)
+		}
+		return ctx
+	}
+	md := i.md(ctx)
+	if len(md) == 0 {
+		return ctx
+	}
+	out = out.Copy()
+	for k, v := range md {
+		out[k] = append([]string(nil), v...)
+	}
+	return metadata.NewOutgoingContext(ctx, out)
+}
+
+func (i mdInterceptors) header(ctx context.Context) error {
+	if len(i.static) == 0 && len(i.dynamic) == 0 {
+		return nil
+	}
+	md := i.owned(ctx)
+	if len(md) == 0 {
+		return nil
+	}
+	return grpc.SetHeader(ctx, md)
 }
 
 func (i mdInterceptors) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
-		if err := grpc.SetHeader(ctx, metadata.Pairs(i.pairs...)); err != nil {
+		if err := i.header(ctx); err != nil {
 			return nil, err
 		}
 		return handler(ctx, req)
@@ -28,7 +136,7 @@ func (i mdInterceptors) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
 
 func (i mdInterceptors) StreamServerInterceptor() grpc.StreamServerInterceptor {
 	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
-		if err := grpc.SetHeader(ss.Context(), metadata.Pairs(i.pairs...)); err != nil {
+		if err := i.header(ss.Context()); err != nil {
 			return err
 		}
 		return handler(srv, ss)
@@ -37,14 +145,12 @@ func (i mdInterceptors) StreamServerInterceptor() grpc.StreamServerInterceptor {
 
 func (i mdInterceptors) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
 	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
-		ctx = metadata.AppendToOutgoingContext(ctx, i.pairs...)
-		return invoker(ctx, method, req, reply, cc, opts...)
+		return invoker(i.outgoing(ctx), method, req, reply, cc, opts...)
 	}
 }
 
 func (i mdInterceptors) StreamClientInterceptor() grpc.StreamClientInterceptor {
 	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
-		ctx = metadata.AppendToOutgoingContext(ctx, i.pairs...)
-		return streamer(ctx, desc, cc, method, opts...)
+		return streamer(i.outgoing(ctx), desc, cc, method, opts...)
 	}
 }
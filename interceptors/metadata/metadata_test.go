@@ -0,0 +1,188 @@
+package metadata
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func outgoing(t *testing.T, ctx context.Context, opts ...Option) metadata.MD {
+	var md metadata.MD
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	}
+	require.NoError(t, New(opts...).UnaryClientInterceptor()(ctx, "/svc/Method", nil, nil, nil, invoker))
+	return md
+}
+
+func TestClientInterceptor(t *testing.T) {
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "key", "existing")
+	dyn := WithDynamic("dynamic", func(ctx context.Context) string { return "value" })
+	empty := WithDynamic("empty", func(ctx context.Context) string { return "" })
+
+	md := outgoing(t, ctx, WithPairs("key", "a", "other", "b"), dyn, empty)
+	assert.Equal(t, []string{"existing", "a"}, md.Get("key"))
+	assert.Equal(t, []string{"b"}, md.Get("other"))
+	assert.Equal(t, []string{"value"}, md.Get("dynamic"))
+	assert.Empty(t, md.Get("empty"))
+
+	md = outgoing(t, ctx, WithPairs("key", "a"), WithMode(Override))
+	assert.Equal(t, []string{"a"}, md.Get("key"))
+
+	md = outgoing(t, context.Background(), WithPairs("key", "a"), WithMode(Override))
+	assert.Equal(t, []string{"a"}, md.Get("key"))
+}
+
+func TestNewInterceptorsCompat(t *testing.T) {
+	var md metadata.MD
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	}
+	require.NoError(t, NewInterceptors("k", "v").UnaryClientInterceptor()(context.Background(), "/svc/Method", nil, nil, nil, invoker))
+	assert.Equal(t, []string{"v"}, md.Get("k"))
+}
+
+func TestPropagation(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		"x-request-id", "id",
+		"x-b3-traceid", "trace",
+		"x-tenant-id", "incoming",
+		"secret", "value",
+	))
+	ctx = metadata.AppendToOutgoingContext(ctx, "x-tenant-id", "outgoing")
+	var md metadata.MD
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	}
+	i := NewPropagationInterceptors("x-request-id", "X-Tenant-Id", "x-b3-*")
+	require.NoError(t, i.UnaryClientInterceptor()(ctx, "/svc/Method", nil, nil, nil, invoker))
+	assert.Equal(t, []string{"id"}, md.Get("x-request-id"))
+	assert.Equal(t, []string{"trace"}, md.Get("x-b3-traceid"))
+	assert.Equal(t, []string{"outgoing"}, md.Get("x-tenant-id"))
+	assert.Empty(t, md.Get("secret"))
+}
+
+func TestStaticValues(t *testing.T) {
+	i := New(WithPairs("Key", "a", "key", "b", "empty", "")).(mdInterceptors)
+	ctx := context.Background()
+	// the empty static values are dropped, unlike with metadata.Pairs
+	assert.Equal(t, metadata.Pairs("key", "a", "key", "b"), i.md(ctx))
+	assert.Equal(t, []string{"key", "a", "key", "b"}, i.kv(ctx))
+	assert.Zero(t, testing.AllocsPerRun(100, func() {
+		i.md(ctx)
+		i.kv(ctx)
+	}))
+}
+
+func TestStaticValuesNotShared(t *testing.T) {
+	var md metadata.MD
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	}
+	ctx := context.Background()
+	for _, mode := range []Mode{Append, Override} {
+		// the calls metadata do not share the static values
+		i := New(WithPairs("key", "a", "key", "b"), WithMode(mode)).UnaryClientInterceptor()
+		require.NoError(t, i(ctx, "/svc/Method", nil, nil, nil, invoker))
+		md["key"][0] = "c"
+		md["key"] = append(md["key"], "d")
+		md["other"] = []string{"e"}
+		require.NoError(t, i(ctx, "/svc/Method", nil, nil, nil, invoker))
+		assert.Equal(t, metadata.Pairs("key", "a", "key", "b"), md)
+	}
+
+	s := New(WithPairs("key", "a", "key", "b")).UnaryServerInterceptor()
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	}
+	st := &transportStream{}
+	ctx = grpc.NewContextWithServerTransportStream(ctx, st)
+	_, err := s(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/svc/Method"}, handler)
+	require.NoError(t, err)
+	st.header["key"][0] = "c"
+	st.header["other"] = []string{"e"}
+	st.header = nil
+	_, err = s(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/svc/Method"}, handler)
+	require.NoError(t, err)
+	assert.Equal(t, metadata.Pairs("key", "a", "key", "b"), st.header)
+}
+
+func TestAllocations(t *testing.T) {
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "key", "existing")
+	kv := []string{"key", "a", "other", "b"}
+	// the static values do not cost more than the grpc metadata calls sending them
+	i := New(WithPairs(kv...)).UnaryClientInterceptor()
+	assert.LessOrEqual(t, testing.AllocsPerRun(100, func() {
+		_ = i(ctx, "/svc/Method", nil, nil, nil, invoker)
+	}), testing.AllocsPerRun(100, func() {
+		_ = metadata.AppendToOutgoingContext(ctx, kv...)
+	}))
+
+	md := metadata.Pairs(kv...)
+	st := &transportStream{}
+	sctx := grpc.NewContextWithServerTransportStream(context.Background(), st)
+	s := New(WithPairs(kv...)).UnaryServerInterceptor()
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/svc/Method"}
+	assert.LessOrEqual(t, testing.AllocsPerRun(100, func() {
+		st.header = nil
+		_, _ = s(sctx, nil, info, handler)
+	}), testing.AllocsPerRun(100, func() {
+		st.header = nil
+		_ = grpc.SetHeader(sctx, md.Copy())
+	}))
+}
+
+// transportStream is a grpc.ServerTransportStream keeping the headers
+type transportStream struct {
+	grpc.ServerTransportStream
+	header metadata.MD
+}
+
+func (s *transportStream) SetHeader(md metadata.MD) error {
+	if s.header == nil {
+		s.header = md
+		return nil
+	}
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func BenchmarkClientInterceptor(b *testing.B) {
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "key", "existing")
+	benchmarks := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "static", opts: []Option{WithPairs("key", "a", "other", "b")}},
+		{name: "dynamic", opts: []Option{WithPairs("key", "a"), WithDynamic("other", func(ctx context.Context) string { return "b" })}},
+		{name: "override", opts: []Option{WithPairs("key", "a", "other", "b"), WithMode(Override)}},
+	}
+	for _, v := range benchmarks {
+		i := New(v.opts...).UnaryClientInterceptor()
+		b.Run(v.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for n := 0; n < b.N; n++ {
+				if err := i(ctx, "/svc/Method", nil, nil, nil, invoker); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package metadata
+
+import (
+	"context"
+)
+
+// Mode defines how the interceptor values are merged with the existing metadata
+type Mode int
+
+const (
+	// Append adds the values to the existing ones
+	Append Mode = iota
+	// Override replaces the existing values of the same keys
+	Override
+)
+
+// ValueFunc lazily computes a metadata value, an empty value is not sent
+type ValueFunc func(ctx context.Context) string
+
+type Option func(o *options)
+
+// WithPairs adds static key value pairs, it panics if the number of arguments is odd.
+// As the dynamic ones, the empty values are not sent, unlike metadata.Pairs which keeps them.
+func WithPairs(kv ...string) Option {
+	if len(kv)%2 == 1 {
+		panic("metadata: WithPairs got an odd number of arguments")
+	}
+	return func(o *options) {
+		for i := 0; i < len(kv); i += 2 {
+			k, v := kv[i], kv[i+1]
+			o.values = append(o.values, value{key: k, v: v})
+		}
+	}
+}
+
+// WithDynamic adds a value computed for each call, e.g. a hostname or an instance id
+func WithDynamic(key string, fn ValueFunc) Option {
+	return func(o *options) {
+		o.values = append(o.values, value{key: key, fn: fn})
+	}
+}
+
+// WithMode sets how the values are merged with the existing outgoing metadata, defaults to Append
+func WithMode(m Mode) Option {
+	return func(o *options) {
+		o.mode = m
+	}
+}
+
+// value is a static value if fn is nil
+type value struct {
+	key string
+	v   string
+	fn  ValueFunc
+}
+
+type options struct {
+	values []value
+	mode   Mode
+}
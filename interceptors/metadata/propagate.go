@@ -0,0 +1,106 @@
+package metadata
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"go.linka.cloud/grpc/interceptors"
+)
+
+// DefaultPropagatedKeys are the metadata keys propagated when no keys are given to NewPropagationInterceptors
+var DefaultPropagatedKeys = []string{
+	"x-request-id",
+	"x-tenant-id",
+	"authorization",
+	"traceparent",
+	"tracestate",
+	"baggage",
+}
+
+// NewPropagationInterceptors returns interceptors copying the allowed incoming metadata to the outgoing calls,
+// including the in-process ones.
+// A key ending with * matches all the keys with the given prefix, e.g. x-b3-*.
+// The values already present in the outgoing metadata are kept.
+func NewPropagationInterceptors(keys ...string) interceptors.Interceptors {
+	if len(keys) == 0 {
+		keys = DefaultPropagatedKeys
+	}
+	p := &propagate{exact: make(map[string]struct{})}
+	for _, v := range keys {
+		v = strings.ToLower(v)
+		if strings.HasSuffix(v, "*") {
+			p.prefixes = append(p.prefixes, strings.TrimSuffix(v, "*"))
+			continue
+		}
+		p.exact[v] = struct{}{}
+	}
+	return p
+}
+
+type propagate struct {
+	exact    map[string]struct{}
+	prefixes []string
+}
+
+func (p *propagate) allowed(key string) bool {
+	if _, ok := p.exact[key]; ok {
+		return true
+	}
+	for _, v := range p.prefixes {
+		if strings.HasPrefix(key, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *propagate) propagate(ctx context.Context) context.Context {
+	in, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+	out, _ := metadata.FromOutgoingContext(ctx)
+	out = out.Copy()
+	var changed bool
+	for k, v := range in {
+		if !p.allowed(k) {
+			continue
+		}
+		if _, ok := out[k]; ok {
+			continue
+		}
+		out[k] = append([]string(nil), v...)
+		changed = true
+	}
+	if !changed {
+		return ctx
+	}
+	return metadata.NewOutgoingContext(ctx, out)
+}
+
+func (p *propagate) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(p.propagate(ctx), req)
+	}
+}
+
+func (p *propagate) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, NewContextServerStream(p.propagate(ss.Context()), ss))
+	}
+}
+
+func (p *propagate) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(p.propagate(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+func (p *propagate) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(p.propagate(ctx), desc, cc, method, opts...)
+	}
+}
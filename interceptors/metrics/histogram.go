@@ -0,0 +1,163 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+
+	"go.linka.cloud/grpc/interceptors/tracing"
+	"go.linka.cloud/grpc/match"
+)
+
+// ExemplarFunc returns the exemplar labels of an observation, e.g. {"trace_id": "..."}, or nil
+type ExemplarFunc func(ctx context.Context) prometheus.Labels
+
+type HistogramOption func(o *histogramOptions)
+
+type histogramOptions struct {
+	name        string
+	help        string
+	constLabels prometheus.Labels
+	buckets     []float64
+	methods     map[string][]float64
+	exemplars   ExemplarFunc
+}
+
+// WithHistogramName overrides the histogram name, defaults to grpc_server_handling_seconds
+func WithHistogramName(name string) HistogramOption {
+	return func(o *histogramOptions) {
+		o.name = name
+	}
+}
+
+// WithHistogramConstLabels adds constant labels to the histogram
+func WithHistogramConstLabels(labels prometheus.Labels) HistogramOption {
+	return func(o *histogramOptions) {
+		o.constLabels = labels
+	}
+}
+
+// WithBuckets sets the buckets used by the methods without specific buckets, defaults to prometheus.DefBuckets
+func WithBuckets(buckets ...float64) HistogramOption {
+	return func(o *histogramOptions) {
+		o.buckets = buckets
+	}
+}
+
+// WithMethodBuckets sets the buckets of the methods matching the pattern, e.g. /pkg.Service/Method, /pkg.Service/Get*
+// or /pkg.Service/ for all the methods of a service. The most specific pattern matching a method wins.
+func WithMethodBuckets(method string, buckets ...float64) HistogramOption {
+	return func(o *histogramOptions) {
+		o.methods[method] = buckets
+	}
+}
+
+// WithExemplars attaches the labels returned by fn as exemplars of the observations,
+// linking the latency to the traces. Exemplars are only exposed with the OpenMetrics format.
+// It defaults to TraceExemplars, nil disables the exemplars.
+func WithExemplars(fn ExemplarFunc) HistogramOption {
+	return func(o *histogramOptions) {
+		o.exemplars = fn
+	}
+}
+
+// TraceExemplars returns the trace id of the rpc span as the trace_id exemplar label.
+// The tracing interceptors must run before the metrics ones so that the span is in the context.
+func TraceExemplars(ctx context.Context) prometheus.Labels {
+	id, ok := tracing.TraceID(ctx)
+	if !ok {
+		return nil
+	}
+	return prometheus.Labels{"trace_id": id}
+}
+
+// histogram is a handling time histogram which buckets are configurable per method
+type histogram struct {
+	opts    histogramOptions
+	def     *prometheus.HistogramVec
+	methods map[string]*prometheus.HistogramVec
+}
+
+func newHistogram(opts ...HistogramOption) *histogram {
+	o := histogramOptions{
+		name:      "grpc_server_handling_seconds",
+		help:      "Histogram of response latency (seconds) of gRPC that had been application-level handled by the server.",
+		buckets:   prometheus.DefBuckets,
+		methods:   make(map[string][]float64),
+		exemplars: TraceExemplars,
+	}
+	for _, v := range opts {
+		v(&o)
+	}
+	vec := func(buckets []float64) *prometheus.HistogramVec {
+		// all the vectors share the same description so that they are exposed as a single metric
+		return prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:        o.name,
+			Help:        o.help,
+			ConstLabels: o.constLabels,
+			Buckets:     buckets,
+		}, []string{"grpc_type", "grpc_service", "grpc_method"})
+	}
+	h := &histogram{opts: o, def: vec(o.buckets), methods: make(map[string]*prometheus.HistogramVec, len(o.methods))}
+	for k, v := range o.methods {
+		h.methods[k] = vec(v)
+	}
+	return h
+}
+
+// vec returns the histogram vector of the most specific pattern matching the full method name
+func (h *histogram) vec(method string) *prometheus.HistogramVec {
+	if v, ok := h.methods[method]; ok {
+		return v
+	}
+	var (
+		vec     = h.def
+		pattern string
+		found   bool
+	)
+	for k, v := range h.methods {
+		if match.Method(k, method) && (!found || match.Specific(k, pattern)) {
+			vec, pattern, found = v, k, true
+		}
+	}
+	return vec
+}
+
+func (h *histogram) observe(ctx context.Context, typ, method string, d time.Duration) {
+	service, name := match.Split(method)
+	o := h.vec(method).WithLabelValues(typ, service, name)
+	if h.opts.exemplars != nil {
+		if l := h.opts.exemplars(ctx); len(l) != 0 {
+			if e, ok := o.(prometheus.ExemplarObserver); ok {
+				e.ObserveWithExemplar(d.Seconds(), l)
+				return
+			}
+		}
+	}
+	o.Observe(d.Seconds())
+}
+
+func (h *histogram) Describe(descs chan<- *prometheus.Desc) {
+	h.def.Describe(descs)
+}
+
+func (h *histogram) Collect(c chan<- prometheus.Metric) {
+	h.def.Collect(c)
+	for _, v := range h.methods {
+		v.Collect(c)
+	}
+}
+
+func streamType(info *grpc.StreamServerInfo) string {
+	switch {
+	case info.IsClientStream && info.IsServerStream:
+		return "bidi_stream"
+	case info.IsClientStream:
+		return "client_stream"
+	case info.IsServerStream:
+		return "server_stream"
+	}
+	return "unary"
+}
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistogramBuckets(t *testing.T) {
+	h := newHistogram(
+		WithBuckets(1, 10),
+		WithMethodBuckets("/cache.Cache/", 0.0001, 0.001),
+		WithMethodBuckets("/cache.Cache/Warm", 60, 600),
+	)
+	assert.Equal(t, h.def, h.vec("/batch.Batch/Run"))
+	assert.Equal(t, h.methods["/cache.Cache/"], h.vec("/cache.Cache/Get"))
+	assert.Equal(t, h.methods["/cache.Cache/Warm"], h.vec("/cache.Cache/Warm"))
+
+	h.observe(context.Background(), "unary", "/cache.Cache/Get", time.Millisecond)
+	m := collect(t, h)
+	require.Len(t, m, 1)
+	assert.Len(t, m[0].GetHistogram().GetBucket(), 2)
+	assert.Equal(t, 0.0001, m[0].GetHistogram().GetBucket()[0].GetUpperBound())
+}
+
+func TestHistogramExemplars(t *testing.T) {
+	h := newHistogram(WithExemplars(func(ctx context.Context) prometheus.Labels {
+		return prometheus.Labels{"trace_id": "0af7651916cd43dd8448eb211c80319c"}
+	}))
+	h.observe(context.Background(), "unary", "/test.Test/Get", time.Millisecond)
+	m := collect(t, h)
+	require.Len(t, m, 1)
+	var found bool
+	for _, b := range m[0].GetHistogram().GetBucket() {
+		if e := b.GetExemplar(); e != nil {
+			found = true
+			assert.Equal(t, "trace_id", e.GetLabel()[0].GetName())
+		}
+	}
+	assert.True(t, found)
+}
+
+func TestTraceExemplars(t *testing.T) {
+	assert.Nil(t, TraceExemplars(context.Background()))
+	span := mocktracer.New().StartSpan("test")
+	l := TraceExemplars(opentracing.ContextWithSpan(context.Background(), span))
+	assert.Equal(t, prometheus.Labels{"trace_id": strconv.Itoa(span.Context().(mocktracer.MockSpanContext).TraceID)}, l)
+}
+
+func collect(t *testing.T, c prometheus.Collector) []*dto.Metric {
+	ch := make(chan prometheus.Metric, 10)
+	c.Collect(ch)
+	close(ch)
+	var out []*dto.Metric
+	for v := range ch {
+		m := &dto.Metric{}
+		require.NoError(t, v.Write(m))
+		out = append(out, m)
+	}
+	return out
+}
@@ -1,12 +1,16 @@
 package metrics
 
 import (
+	"context"
+	"time"
+
 	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
 	"github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/grpc"
 
 	"go.linka.cloud/grpc/interceptors"
 	"go.linka.cloud/grpc/service"
+	"go.linka.cloud/grpc/slo"
 )
 
 type Registerer interface {
@@ -23,6 +27,17 @@ type ServerInterceptors interface {
 	interceptors.ServerInterceptors
 	prometheus.Collector
 	EnableHandlingTimeHistogram(opts ...grpc_prometheus.HistogramOption)
+	// EnableMethodHandlingTimeHistogram enables a handling time histogram which buckets can be configured per method
+	// and which observations can carry exemplars. It replaces EnableHandlingTimeHistogram, which uses the same
+	// metric name by default, and must be called before the service starts serving.
+	EnableMethodHandlingTimeHistogram(opts ...HistogramOption)
+	// EnableSLO feeds the tracker with the completed rpcs and exposes its metrics with the interceptors ones.
+	// It must be called before the service starts serving.
+	EnableSLO(t *slo.Tracker)
+	// EnableTenantMetrics counts the completed rpcs per tenant, as returned by rpcctx.Tenant, the exported tenant
+	// label values being controlled by the options to bound the cardinality. It must be called before the service
+	// starts serving.
+	EnableTenantMetrics(opts ...TenantOption)
 }
 
 type ClientInterceptors interface {
@@ -30,8 +45,11 @@ type ClientInterceptors interface {
 }
 
 type metrics struct {
-	s *grpc_prometheus.ServerMetrics
-	c *grpc_prometheus.ClientMetrics
+	s  *grpc_prometheus.ServerMetrics
+	c  *grpc_prometheus.ClientMetrics
+	h  *histogram
+	t  *slo.Tracker
+	tn *tenants
 }
 
 func (m *metrics) EnableHandlingTimeHistogram(opts ...grpc_prometheus.HistogramOption) {
@@ -40,16 +58,52 @@ func (m *metrics) EnableHandlingTimeHistogram(opts ...grpc_prometheus.HistogramO
 	}
 }
 
+func (m *metrics) EnableMethodHandlingTimeHistogram(opts ...HistogramOption) {
+	if m.s != nil {
+		m.h = newHistogram(opts...)
+	}
+}
+
+func (m *metrics) EnableSLO(t *slo.Tracker) {
+	if m.s != nil {
+		m.t = t
+	}
+}
+
+func (m *metrics) EnableTenantMetrics(opts ...TenantOption) {
+	if m.s != nil {
+		m.tn = newTenants(opts...)
+	}
+}
+
 func (m *metrics) Describe(descs chan<- *prometheus.Desc) {
 	if m.s != nil {
 		m.s.Describe(descs)
 	}
+	if m.h != nil {
+		m.h.Describe(descs)
+	}
+	if m.t != nil {
+		m.t.Describe(descs)
+	}
+	if m.tn != nil {
+		m.tn.Describe(descs)
+	}
 }
 
 func (m *metrics) Collect(c chan<- prometheus.Metric) {
 	if m.s != nil {
 		m.s.Collect(c)
 	}
+	if m.h != nil {
+		m.h.Collect(c)
+	}
+	if m.t != nil {
+		m.t.Collect(c)
+	}
+	if m.tn != nil {
+		m.tn.Collect(c)
+	}
 }
 
 func (m *metrics) Register(svc service.Service) {
@@ -87,11 +141,41 @@ func DefaultClientInterceptors() ClientInterceptors {
 }
 
 func (m *metrics) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
-	return m.s.UnaryServerInterceptor()
+	i := m.s.UnaryServerInterceptor()
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if m.h == nil && m.t == nil && m.tn == nil {
+			return i(ctx, req, info, handler)
+		}
+		start := time.Now()
+		res, err := i(ctx, req, info, handler)
+		m.observe(ctx, "unary", info.FullMethod, time.Since(start), err)
+		return res, err
+	}
 }
 
 func (m *metrics) StreamServerInterceptor() grpc.StreamServerInterceptor {
-	return m.s.StreamServerInterceptor()
+	i := m.s.StreamServerInterceptor()
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if m.h == nil && m.t == nil && m.tn == nil {
+			return i(srv, ss, info, handler)
+		}
+		start := time.Now()
+		err := i(srv, ss, info, handler)
+		m.observe(ss.Context(), streamType(info), info.FullMethod, time.Since(start), err)
+		return err
+	}
+}
+
+func (m *metrics) observe(ctx context.Context, typ, method string, d time.Duration, err error) {
+	if m.h != nil {
+		m.h.observe(ctx, typ, method, d)
+	}
+	if m.t != nil {
+		m.t.Observe(method, d, err)
+	}
+	if m.tn != nil {
+		m.tn.observe(ctx, err)
+	}
 }
 
 func (m *metrics) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
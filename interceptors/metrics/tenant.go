@@ -0,0 +1,189 @@
+package metrics
+
+import (
+	"context"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/status"
+
+	"go.linka.cloud/grpc/rpcctx"
+)
+
+// OtherTenant is the label value of the tenants not exported by the top tenants policy
+const OtherTenant = "other"
+
+type TenantOption func(o *tenantOptions)
+
+// WithTopTenants exports the n tenants with the most requests, the other ones are aggregated in the OtherTenant label.
+// A tenant leaving the top moves its requests to OtherTenant, which may look like a counter reset. It defaults to 10.
+func WithTopTenants(n int) TenantOption {
+	return func(o *tenantOptions) {
+		o.mode = tenantTop
+		o.n = n
+	}
+}
+
+// WithHashedTenants exports the tenants hashed in the given number of buckets, see TenantBucket
+func WithHashedTenants(buckets int) TenantOption {
+	return func(o *tenantOptions) {
+		o.mode = tenantHashed
+		o.n = buckets
+	}
+}
+
+// WithoutTenants disables the tenant label values, the requests are exported with an empty tenant label
+func WithoutTenants() TenantOption {
+	return func(o *tenantOptions) {
+		o.mode = tenantDisabled
+	}
+}
+
+// TenantBucket returns the label value of the tenant with the hashed tenants policy
+func TenantBucket(tenant string, buckets int) string {
+	h := fnv.New32a()
+	h.Write([]byte(tenant))
+	return strconv.Itoa(int(h.Sum32() % uint32(buckets)))
+}
+
+type tenantMode int
+
+const (
+	tenantTop tenantMode = iota
+	tenantHashed
+	tenantDisabled
+)
+
+type tenantOptions struct {
+	mode tenantMode
+	n    int
+}
+
+// tenants counts the handled rpcs per tenant, the tenant being read with rpcctx.Tenant.
+// The label values are chosen at collection time according to the policy.
+type tenants struct {
+	opts tenantOptions
+	desc *prometheus.Desc
+
+	mu sync.Mutex
+	// counts are the requests per label value, or per tracked tenant with the top policy
+	counts map[string]*tenantCounts
+	// evicted are the requests of the tenants which were not tracked anymore
+	evicted *tenantCounts
+}
+
+type tenantCounts struct {
+	total float64
+	codes map[string]float64
+}
+
+func (c *tenantCounts) add(code string, v float64) {
+	c.total += v
+	c.codes[code] += v
+}
+
+func newTenantCounts() *tenantCounts {
+	return &tenantCounts{codes: make(map[string]float64)}
+}
+
+func newTenants(opts ...TenantOption) *tenants {
+	o := tenantOptions{mode: tenantTop, n: 10}
+	for _, v := range opts {
+		v(&o)
+	}
+	if o.n <= 0 {
+		o.n = 1
+	}
+	return &tenants{
+		opts:    o,
+		desc:    prometheus.NewDesc("grpc_server_tenant_handled_total", "Total number of RPCs completed on the server by tenant and code.", []string{"tenant", "grpc_code"}, nil),
+		counts:  make(map[string]*tenantCounts),
+		evicted: newTenantCounts(),
+	}
+}
+
+func (t *tenants) observe(ctx context.Context, err error) {
+	tenant, _ := rpcctx.Tenant(ctx)
+	code := status.Code(err).String()
+	switch t.opts.mode {
+	case tenantHashed:
+		tenant = TenantBucket(tenant, t.opts.n)
+	case tenantDisabled:
+		tenant = ""
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c, ok := t.counts[tenant]
+	if !ok {
+		if t.opts.mode == tenantTop {
+			t.evict()
+		}
+		c = newTenantCounts()
+		t.counts[tenant] = c
+	}
+	c.add(code, 1)
+}
+
+// evict bounds the tracked tenants with the top policy: the least active one is aggregated in the evicted counts
+func (t *tenants) evict() {
+	if len(t.counts) < t.opts.n*10 {
+		return
+	}
+	var (
+		min  string
+		minC *tenantCounts
+	)
+	for k, v := range t.counts {
+		if minC == nil || v.total < minC.total {
+			min, minC = k, v
+		}
+	}
+	for k, v := range minC.codes {
+		t.evicted.add(k, v)
+	}
+	delete(t.counts, min)
+}
+
+func (t *tenants) Describe(descs chan<- *prometheus.Desc) {
+	descs <- t.desc
+}
+
+func (t *tenants) Collect(ch chan<- prometheus.Metric) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	send := func(tenant string, c *tenantCounts) {
+		for code, v := range c.codes {
+			ch <- prometheus.MustNewConstMetric(t.desc, prometheus.CounterValue, v, tenant, code)
+		}
+	}
+	if t.opts.mode != tenantTop {
+		for k, v := range t.counts {
+			send(k, v)
+		}
+		return
+	}
+	keys := make([]string, 0, len(t.counts))
+	for k := range t.counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return t.counts[keys[i]].total > t.counts[keys[j]].total
+	})
+	other := newTenantCounts()
+	for k, v := range t.evicted.codes {
+		other.add(k, v)
+	}
+	for i, k := range keys {
+		if i < t.opts.n {
+			send(k, t.counts[k])
+			continue
+		}
+		for code, v := range t.counts[k].codes {
+			other.add(code, v)
+		}
+	}
+	send(OtherTenant, other)
+}
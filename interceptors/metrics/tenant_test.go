@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go.linka.cloud/grpc/rpcctx"
+)
+
+func TestTenants(t *testing.T) {
+	requests := map[string]int{"a": 3, "b": 2, "c": 1}
+	run := func(tn *tenants) map[string]float64 {
+		for k, n := range requests {
+			for i := 0; i < n; i++ {
+				tn.observe(rpcctx.WithTenant(context.Background(), k), nil)
+			}
+		}
+		tn.observe(rpcctx.WithTenant(context.Background(), "a"), status.Error(codes.NotFound, ""))
+		out := make(map[string]float64)
+		for _, m := range collect(t, tn) {
+			var tenant, code string
+			for _, l := range m.GetLabel() {
+				switch l.GetName() {
+				case "tenant":
+					tenant = l.GetValue()
+				case "grpc_code":
+					code = l.GetValue()
+				}
+			}
+			out[tenant+"/"+code] += m.GetCounter().GetValue()
+		}
+		return out
+	}
+	assert.Equal(t, map[string]float64{"a/OK": 3, "a/NotFound": 1, "b/OK": 2, OtherTenant + "/OK": 1}, run(newTenants(WithTopTenants(2))))
+	assert.Equal(t, map[string]float64{"/OK": 6, "/NotFound": 1}, run(newTenants(WithoutTenants())))
+	hashed := run(newTenants(WithHashedTenants(1)))
+	assert.Equal(t, map[string]float64{"0/OK": 6, "0/NotFound": 1}, hashed)
+}
@@ -0,0 +1,7 @@
+//go:build nodebug
+// +build nodebug
+
+package interceptors
+
+// Debug reports whether the debug-only interceptors are compiled in, they are compiled out with the nodebug build tag
+const Debug = false
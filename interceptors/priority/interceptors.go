@@ -0,0 +1,168 @@
+package priority
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"go.linka.cloud/grpc/errors"
+	"go.linka.cloud/grpc/interceptors"
+	metadata2 "go.linka.cloud/grpc/interceptors/metadata"
+	"go.linka.cloud/grpc/match"
+)
+
+type ServerInterceptors interface {
+	interceptors.ServerInterceptors
+	prometheus.Collector
+}
+
+// NewServerInterceptors returns interceptors classifying the requests into priority tiers
+// and shedding the lowest priority requests first when the capacity is exhausted.
+// Shed requests are rejected with Unavailable.
+func NewServerInterceptors(opts ...Option) ServerInterceptors {
+	o := defaultOptions()
+	for _, v := range opts {
+		v(&o)
+	}
+	return &interceptor{
+		o: o,
+		s: newScheduler(o.capacity, o.tiers),
+		admitted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_server_priority_admitted_total",
+			Help: "Total number of RPCs admitted by priority tier.",
+		}, []string{"priority"}),
+		shed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_server_priority_shed_total",
+			Help: "Total number of RPCs shed by priority tier.",
+		}, []string{"priority"}),
+		inflight: prometheus.NewDesc("grpc_server_priority_in_flight", "Current number of in-flight RPCs.", nil, nil),
+		queued:   prometheus.NewDesc("grpc_server_priority_queued", "Current number of RPCs waiting for admission by priority tier.", []string{"priority"}, nil),
+	}
+}
+
+type interceptor struct {
+	o options
+	s *scheduler
+
+	admitted *prometheus.CounterVec
+	shed     *prometheus.CounterVec
+	inflight *prometheus.Desc
+	queued   *prometheus.Desc
+}
+
+// method returns the priority of the most specific pattern matching the method
+func (i *interceptor) method(method string) (Priority, bool) {
+	if p, ok := i.o.methods[method]; ok {
+		return p, true
+	}
+	var (
+		p       Priority
+		pattern string
+		found   bool
+	)
+	for k, v := range i.o.methods {
+		if match.Method(k, method) && (!found || match.Specific(k, pattern)) {
+			p, pattern, found = v, k, true
+		}
+	}
+	return p, found
+}
+
+func (i *interceptor) classify(ctx context.Context, method string) Priority {
+	if p, ok := i.method(method); ok {
+		return p
+	}
+	if i.o.key == "" {
+		return Normal
+	}
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return Normal
+	}
+	v := md.Get(i.o.key)
+	if len(v) == 0 {
+		return Normal
+	}
+	p, _ := Parse(v[0])
+	if p < Normal && !i.trusted(ctx, method, p) {
+		return Normal
+	}
+	return p
+}
+
+// trusted reports whether the rpc may claim the priority from its metadata
+func (i *interceptor) trusted(ctx context.Context, method string, p Priority) bool {
+	for _, v := range i.o.trusted {
+		if p >= v.max && v.m.Match(ctx, method) {
+			return true
+		}
+	}
+	return false
+}
+
+func (i *interceptor) admit(ctx context.Context, method string) (context.Context, error) {
+	p := i.classify(ctx, method)
+	qctx := ctx
+	if i.o.queueTimeout > 0 {
+		var cancel context.CancelFunc
+		qctx, cancel = context.WithTimeout(ctx, i.o.queueTimeout)
+		defer cancel()
+	}
+	if err := i.s.acquire(qctx, p); err != nil {
+		i.shed.WithLabelValues(p.String()).Inc()
+		if err := ctx.Err(); err != nil {
+			return ctx, status.FromContextError(err).Err()
+		}
+		return ctx, errors.Unavailablef("%s: server overloaded", method)
+	}
+	i.admitted.WithLabelValues(p.String()).Inc()
+	return newContext(ctx, p), nil
+}
+
+func (i *interceptor) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		if match.AnyMethod(i.o.exempt, info.FullMethod) {
+			return handler(ctx, req)
+		}
+		ctx, err = i.admit(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		defer i.s.release()
+		return handler(ctx, req)
+	}
+}
+
+func (i *interceptor) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if match.AnyMethod(i.o.exempt, info.FullMethod) {
+			return handler(srv, ss)
+		}
+		ctx, err := i.admit(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		defer i.s.release()
+		return handler(srv, metadata2.NewContextServerStream(ctx, ss))
+	}
+}
+
+func (i *interceptor) Describe(descs chan<- *prometheus.Desc) {
+	i.admitted.Describe(descs)
+	i.shed.Describe(descs)
+	descs <- i.inflight
+	descs <- i.queued
+}
+
+func (i *interceptor) Collect(c chan<- prometheus.Metric) {
+	i.admitted.Collect(c)
+	i.shed.Collect(c)
+	inflight, queued := i.s.stats()
+	c <- prometheus.MustNewConstMetric(i.inflight, prometheus.GaugeValue, float64(inflight))
+	for p, v := range queued {
+		c <- prometheus.MustNewConstMetric(i.queued, prometheus.GaugeValue, float64(v), p.String())
+	}
+}
@@ -0,0 +1,120 @@
+package priority
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"go.linka.cloud/grpc/match"
+)
+
+func TestClassify(t *testing.T) {
+	internal, err := match.PeerNetworks("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := func(ip string, p string) context.Context {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(DefaultMetadataKey, p))
+		return peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 4242}})
+	}
+	tests := []struct {
+		name   string
+		opts   []Option
+		method string
+		ctx    context.Context
+		want   Priority
+	}{
+		{name: "metadata disabled by default", ctx: ctx("10.0.0.1", "low"), want: Normal},
+		{name: "lower priority", opts: []Option{WithMetadataKey(DefaultMetadataKey)}, ctx: ctx("192.168.0.1", "low"), want: Low},
+		{name: "untrusted critical", opts: []Option{WithMetadataKey(DefaultMetadataKey)}, ctx: ctx("192.168.0.1", "critical"), want: Normal},
+		{
+			name: "untrusted peer",
+			opts: []Option{WithMetadataKey(DefaultMetadataKey), WithTrustedMetadata(Critical, internal)},
+			ctx:  ctx("192.168.0.1", "high"),
+			want: Normal,
+		},
+		{
+			name: "trusted high",
+			opts: []Option{WithMetadataKey(DefaultMetadataKey), WithTrustedMetadata(High, internal)},
+			ctx:  ctx("10.0.0.1", "high"),
+			want: High,
+		},
+		{
+			name: "trusted up to high",
+			opts: []Option{WithMetadataKey(DefaultMetadataKey), WithTrustedMetadata(High, internal)},
+			ctx:  ctx("10.0.0.1", "critical"),
+			want: Normal,
+		},
+		{
+			name:   "method priority",
+			opts:   []Option{WithMetadataKey(DefaultMetadataKey)},
+			method: "/grpc.health.v1.Health/Check",
+			ctx:    ctx("192.168.0.1", "low"),
+			want:   Critical,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := NewServerInterceptors(tt.opts...).(*interceptor)
+			method := tt.method
+			if method == "" {
+				method = "/svc/Method"
+			}
+			if got := i.classify(tt.ctx, method); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestAdmission(t *testing.T) {
+	i := NewServerInterceptors(WithCapacity(1), WithQueueTimeout(10*time.Millisecond)).(*interceptor)
+	// the only slot is taken
+	if err := i.s.acquire(context.Background(), Critical); err != nil {
+		t.Fatal(err)
+	}
+	defer i.s.release()
+	stream := func(ctx context.Context, method string) (bool, error) {
+		var called bool
+		err := i.StreamServerInterceptor()(nil, &serverStream{ctx: ctx}, &grpc.StreamServerInfo{FullMethod: method}, func(srv interface{}, ss grpc.ServerStream) error {
+			called = true
+			return nil
+		})
+		return called, err
+	}
+
+	// the health watchers do not wait for a slot
+	called, err := stream(context.Background(), "/grpc.health.v1.Health/Watch")
+	if err != nil || !called {
+		t.Fatalf("expected the watch to be served, got %v", err)
+	}
+	called, err = stream(context.Background(), "/svc/Stream")
+	if called || status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected Unavailable, got %v", err)
+	}
+	// the clients cancelled while queued get the context status
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = i.UnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/svc/Method"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	if status.Code(err) != codes.Canceled {
+		t.Fatalf("expected Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package priority
+
+import (
+	"time"
+
+	"go.linka.cloud/grpc/match"
+)
+
+const (
+	// DefaultMetadataKey is the conventional metadata key of the request priority, see WithMetadataKey
+	DefaultMetadataKey = "x-priority"
+)
+
+type Option func(o *options)
+
+// WithCapacity sets the maximum number of concurrent requests
+func WithCapacity(n int) Option {
+	return func(o *options) {
+		o.capacity = n
+	}
+}
+
+// WithTier overrides the admission limits of the given priority tier
+func WithTier(p Priority, t Tier) Option {
+	return func(o *options) {
+		o.tiers[p] = t
+	}
+}
+
+// WithMethodPriority assigns a priority to the methods matching the pattern, e.g. /helloworld.Greeter/SayHello
+// or /helloworld.Greeter/, the most specific pattern matching a method wins
+func WithMethodPriority(method string, p Priority) Option {
+	return func(o *options) {
+		o.methods[method] = p
+	}
+}
+
+// WithMetadataKey enables the metadata based classification: the request priority is read from the key,
+// e.g. DefaultMetadataKey. It is disabled by default as the clients could otherwise claim any priority.
+// The priorities above Normal are only accepted from the rpcs allowed with WithTrustedMetadata.
+func WithMetadataKey(key string) Option {
+	return func(o *options) {
+		o.key = key
+	}
+}
+
+// WithTrustedMetadata accepts the priorities up to max read from the metadata of the rpcs matching m,
+// e.g. match.PeerNetworks of the internal networks. The other rpcs priorities are capped to Normal.
+func WithTrustedMetadata(max Priority, m match.Matcher) Option {
+	return func(o *options) {
+		o.trusted = append(o.trusted, trusted{max: max, m: m})
+	}
+}
+
+// WithExemptMethods bypasses the admission of the methods matching the patterns, e.g. the long-lived streams
+// which would otherwise hold a slot for their whole life. /grpc.health.v1.Health/Watch is exempted by default.
+func WithExemptMethods(methods ...string) Option {
+	return func(o *options) {
+		o.exempt = append(o.exempt, methods...)
+	}
+}
+
+// WithQueueTimeout sets the maximum time a request waits for admission
+func WithQueueTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.queueTimeout = d
+	}
+}
+
+// trusted are the rpcs allowed to claim the priorities up to max from their metadata
+type trusted struct {
+	max Priority
+	m   match.Matcher
+}
+
+type options struct {
+	capacity     int
+	tiers        map[Priority]Tier
+	methods      map[string]Priority
+	exempt       []string
+	key          string
+	trusted      []trusted
+	queueTimeout time.Duration
+}
+
+func defaultOptions() options {
+	return options{
+		capacity: 100,
+		tiers:    make(map[Priority]Tier),
+		methods: map[string]Priority{
+			"/grpc.health.v1.Health/Check": Critical,
+		},
+		// the health watchers, e.g. the load balancers, would hold their slots forever
+		exempt:       []string{"/grpc.health.v1.Health/Watch"},
+		queueTimeout: time.Second,
+	}
+}
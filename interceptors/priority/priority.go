@@ -0,0 +1,56 @@
+package priority
+
+import (
+	"context"
+	"strings"
+)
+
+// Priority is the request priority tier, lower values have a higher priority
+type Priority int
+
+const (
+	// Critical requests, e.g. health checks and control-plane calls, are never queued behind other tiers
+	Critical Priority = iota
+	High
+	Normal
+	Low
+)
+
+var priorities = []Priority{Critical, High, Normal, Low}
+
+func (p Priority) String() string {
+	switch p {
+	case Critical:
+		return "critical"
+	case High:
+		return "high"
+	case Normal:
+		return "normal"
+	case Low:
+		return "low"
+	default:
+		return "unknown"
+	}
+}
+
+// Parse returns the priority matching the given name
+func Parse(s string) (Priority, bool) {
+	for _, v := range priorities {
+		if strings.EqualFold(v.String(), strings.TrimSpace(s)) {
+			return v, true
+		}
+	}
+	return Normal, false
+}
+
+type key struct{}
+
+// FromContext returns the priority assigned to the request
+func FromContext(ctx context.Context) (Priority, bool) {
+	p, ok := ctx.Value(key{}).(Priority)
+	return p, ok
+}
+
+func newContext(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, key{}, p)
+}
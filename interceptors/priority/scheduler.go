@@ -0,0 +1,128 @@
+package priority
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrQueueFull is returned when the tier queue is full
+	ErrQueueFull = errors.New("priority queue is full")
+)
+
+// Tier defines the admission limits of a priority tier
+type Tier struct {
+	// Share is the fraction of the capacity the tier may use, e.g. 0.5 means that the tier's requests
+	// are only admitted while less than half of the capacity is in use
+	Share float64
+	// MaxQueue is the maximum number of requests waiting for admission
+	MaxQueue int
+}
+
+var defaultTiers = map[Priority]Tier{
+	Critical: {Share: 1, MaxQueue: 100},
+	High:     {Share: 0.9, MaxQueue: 50},
+	Normal:   {Share: 0.75, MaxQueue: 20},
+	Low:      {Share: 0.5, MaxQueue: 0},
+}
+
+type scheduler struct {
+	mu       sync.Mutex
+	capacity int
+	inflight int
+	tiers    map[Priority]Tier
+	queues   map[Priority][]chan struct{}
+}
+
+func newScheduler(capacity int, tiers map[Priority]Tier) *scheduler {
+	s := &scheduler{capacity: capacity, tiers: make(map[Priority]Tier), queues: make(map[Priority][]chan struct{})}
+	for _, p := range priorities {
+		t, ok := tiers[p]
+		if !ok {
+			t = defaultTiers[p]
+		}
+		s.tiers[p] = t
+	}
+	return s
+}
+
+func (s *scheduler) admissible(p Priority) bool {
+	return float64(s.inflight) < float64(s.capacity)*s.tiers[p].Share
+}
+
+// acquire waits until the request is admitted, the queue is full or the context is done
+func (s *scheduler) acquire(ctx context.Context, p Priority) error {
+	s.mu.Lock()
+	if s.admissible(p) && !s.waiting(p) {
+		s.inflight++
+		s.mu.Unlock()
+		return nil
+	}
+	if len(s.queues[p]) >= s.tiers[p].MaxQueue {
+		s.mu.Unlock()
+		return ErrQueueFull
+	}
+	ch := make(chan struct{})
+	s.queues[p] = append(s.queues[p], ch)
+	s.mu.Unlock()
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for i, v := range s.queues[p] {
+			if v == ch {
+				s.queues[p] = append(s.queues[p][:i], s.queues[p][i+1:]...)
+				return ctx.Err()
+			}
+		}
+		// we have been admitted concurrently
+		s.inflight--
+		s.dispatch()
+		return ctx.Err()
+	}
+}
+
+// waiting reports whether requests with the same or a higher priority are queued
+func (s *scheduler) waiting(p Priority) bool {
+	for _, v := range priorities {
+		if v > p {
+			break
+		}
+		if len(s.queues[v]) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *scheduler) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inflight--
+	s.dispatch()
+}
+
+// dispatch admits the queued requests by priority order, it must be called with the lock held
+func (s *scheduler) dispatch() {
+	for _, p := range priorities {
+		for len(s.queues[p]) != 0 && s.admissible(p) {
+			ch := s.queues[p][0]
+			s.queues[p] = s.queues[p][1:]
+			s.inflight++
+			close(ch)
+		}
+	}
+}
+
+func (s *scheduler) stats() (inflight int, queued map[Priority]int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	queued = make(map[Priority]int, len(s.queues))
+	for _, p := range priorities {
+		queued[p] = len(s.queues[p])
+	}
+	return s.inflight, queued
+}
@@ -0,0 +1,46 @@
+package priority
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestScheduler(t *testing.T) {
+	s := newScheduler(4, map[Priority]Tier{
+		Low:    {Share: 0.5, MaxQueue: 0},
+		Normal: {Share: 0.75, MaxQueue: 1},
+	})
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err := s.acquire(ctx, Low); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.acquire(ctx, Low); err != ErrQueueFull {
+		t.Fatalf("expected low priority request to be shed, got %v", err)
+	}
+	if err := s.acquire(ctx, Normal); err != nil {
+		t.Fatal(err)
+	}
+	tctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := s.acquire(tctx, Normal); err != context.DeadlineExceeded {
+		t.Fatalf("expected queued request to time out, got %v", err)
+	}
+	if err := s.acquire(ctx, Critical); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() {
+		done <- s.acquire(ctx, High)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	s.release()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if inflight, _ := s.stats(); inflight != 4 {
+		t.Fatalf("expected 4 in-flight requests, got %d", inflight)
+	}
+}
@@ -0,0 +1,140 @@
+package quota
+
+import (
+	"context"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+
+	"go.linka.cloud/grpc/errors"
+	"go.linka.cloud/grpc/interceptors"
+	"go.linka.cloud/grpc/match"
+)
+
+const (
+	scopeConnection = "connection"
+	scopeIdentity   = "identity"
+)
+
+type ServerInterceptors interface {
+	interceptors.ServerInterceptors
+	prometheus.Collector
+}
+
+// NewServerInterceptors returns interceptors limiting the concurrent rpcs, streams included,
+// per peer connection and per authenticated identity. The rpcs above the quota are rejected with ResourceExhausted,
+// so that a single noisy client cannot starve the others.
+func NewServerInterceptors(opts ...Option) ServerInterceptors {
+	o := options{identities: make(map[string]int), identityFunc: defaultIdentity}
+	for _, v := range opts {
+		v(&o)
+	}
+	return &interceptor{
+		o:          o,
+		connection: newCounter(),
+		identity:   newCounter(),
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_server_quota_rejected_total",
+			Help: "Total number of RPCs rejected by the concurrency quotas by scope.",
+		}, []string{"scope"}),
+	}
+}
+
+type interceptor struct {
+	o          options
+	connection *counter
+	identity   *counter
+	rejected   *prometheus.CounterVec
+}
+
+func (i *interceptor) isIgnored(ctx context.Context, method string) bool {
+	return match.AnyMethod(i.o.ignoredMethods, method) || match.Any(i.o.ignored...).Match(ctx, method)
+}
+
+func (i *interceptor) do(ctx context.Context, method string, fn func() error) error {
+	if i.isIgnored(ctx, method) {
+		return fn()
+	}
+	if i.o.connection > 0 {
+		if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+			key := p.Addr.String()
+			if !i.connection.acquire(key, i.o.connection) {
+				i.rejected.WithLabelValues(scopeConnection).Inc()
+				return errors.ResourceExhaustedf("%s: too many concurrent requests on the connection", method)
+			}
+			defer i.connection.release(key)
+		}
+	}
+	if id, ok := i.o.identityFunc(ctx); ok {
+		limit := i.o.identity
+		if l, ok := i.o.identities[id]; ok {
+			limit = l
+		}
+		if limit > 0 {
+			if !i.identity.acquire(id, limit) {
+				i.rejected.WithLabelValues(scopeIdentity).Inc()
+				return errors.ResourceExhaustedf("%s: too many concurrent requests for the identity", method)
+			}
+			defer i.identity.release(id)
+		}
+	}
+	return fn()
+}
+
+func (i *interceptor) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		err = i.do(ctx, info.FullMethod, func() error {
+			resp, err = handler(ctx, req)
+			return err
+		})
+		return resp, err
+	}
+}
+
+func (i *interceptor) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return i.do(ss.Context(), info.FullMethod, func() error {
+			return handler(srv, ss)
+		})
+	}
+}
+
+func (i *interceptor) Describe(descs chan<- *prometheus.Desc) {
+	i.rejected.Describe(descs)
+}
+
+func (i *interceptor) Collect(c chan<- prometheus.Metric) {
+	i.rejected.Collect(c)
+}
+
+// counter counts the in-flight rpcs per key
+type counter struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func newCounter() *counter {
+	return &counter{counts: make(map[string]int)}
+}
+
+func (c *counter) acquire(key string, limit int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts[key] >= limit {
+		return false
+	}
+	c.counts[key]++
+	return true
+}
+
+func (c *counter) release(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts[key] <= 1 {
+		delete(c.counts, key)
+		return
+	}
+	c.counts[key]--
+}
@@ -0,0 +1,49 @@
+package quota
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"go.linka.cloud/grpc/rpcctx"
+)
+
+func TestQuota(t *testing.T) {
+	i := NewServerInterceptors(
+		WithConnectionLimit(1),
+		WithIdentityLimit(2),
+		WithIdentityLimits(map[string]int{"admin": 10}),
+	).UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"}
+	conn := func(port int, id string) context.Context {
+		ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}})
+		return rpcctx.WithIdentity(ctx, id)
+	}
+
+	// nested calls keep the outer one in flight
+	var call func(ctxs ...context.Context) error
+	call = func(ctxs ...context.Context) error {
+		if len(ctxs) == 0 {
+			return nil
+		}
+		_, err := i(ctxs[0], nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, call(ctxs[1:]...)
+		})
+		return err
+	}
+
+	require.NoError(t, call(conn(1, "user"), conn(2, "user")))
+	// same connection
+	assert.Equal(t, codes.ResourceExhausted, status.Code(call(conn(1, "user"), conn(1, "other"))))
+	// same identity over 3 connections
+	assert.Equal(t, codes.ResourceExhausted, status.Code(call(conn(1, "user"), conn(2, "user"), conn(3, "user"))))
+	// overridden identity limit
+	require.NoError(t, call(conn(1, "admin"), conn(2, "admin"), conn(3, "admin")))
+}
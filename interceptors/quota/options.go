@@ -0,0 +1,81 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+
+	"go.linka.cloud/grpc/match"
+	"go.linka.cloud/grpc/rpcctx"
+)
+
+// IdentityFunc returns the identity of the caller the quota is applied to, false if it is anonymous
+type IdentityFunc func(ctx context.Context) (string, bool)
+
+type Option func(o *options)
+
+// WithConnectionLimit sets the maximum number of concurrent rpcs per peer connection, zero means unlimited
+func WithConnectionLimit(n int) Option {
+	return func(o *options) {
+		o.connection = n
+	}
+}
+
+// WithIdentityLimit sets the maximum number of concurrent rpcs per identity, across its connections,
+// zero means unlimited
+func WithIdentityLimit(n int) Option {
+	return func(o *options) {
+		o.identity = n
+	}
+}
+
+// WithIdentityLimits overrides the limit of specific identities, e.g. a privileged tenant
+func WithIdentityLimits(limits map[string]int) Option {
+	return func(o *options) {
+		for k, v := range limits {
+			o.identities[k] = v
+		}
+	}
+}
+
+// WithIdentityFunc sets how the identity is resolved, defaults to the rpcctx identity formatted with fmt.Sprint.
+// The interceptors must run after the authentication ones.
+func WithIdentityFunc(fn IdentityFunc) Option {
+	return func(o *options) {
+		o.identityFunc = fn
+	}
+}
+
+// WithIgnoredMethods bypass the quotas for the given methods, it takes a list of method patterns,
+// e.g. /grpc.health.v1.Health/Check or /grpc.health.v1.Health/, see the match package for the patterns syntax
+func WithIgnoredMethods(methods ...string) Option {
+	return func(o *options) {
+		o.ignoredMethods = append(o.ignoredMethods, methods...)
+	}
+}
+
+// WithIgnored bypass the quotas for the rpcs selected by the matchers, e.g. match.PeerIdentity for the trusted callers
+func WithIgnored(m ...match.Matcher) Option {
+	return func(o *options) {
+		o.ignored = append(o.ignored, m...)
+	}
+}
+
+type options struct {
+	connection     int
+	identity       int
+	identities     map[string]int
+	identityFunc   IdentityFunc
+	ignoredMethods []string
+	ignored        []match.Matcher
+}
+
+func defaultIdentity(ctx context.Context) (string, bool) {
+	v, ok := rpcctx.Identity(ctx)
+	if !ok {
+		return "", false
+	}
+	if s, ok := v.(string); ok {
+		return s, s != ""
+	}
+	return fmt.Sprint(v), true
+}
@@ -0,0 +1,78 @@
+package retry
+
+import (
+	"sync"
+	"time"
+)
+
+// Budget limits the retries to a ratio of the calls over a sliding window,
+// so that retries can not amplify an outage
+type Budget interface {
+	// Call records a call, retries excluded
+	Call()
+	// Retry reports whether a retry is allowed and records it if it is
+	Retry() bool
+}
+
+// NewBudget returns a Budget allowing ratio retries per call, e.g. 0.1 for 10%, over the window.
+// minPerSecond retries per second are always allowed so that low traffic clients can still retry.
+func NewBudget(ratio, minPerSecond float64, window time.Duration) Budget {
+	if window < time.Second {
+		window = time.Second
+	}
+	n := int(window / time.Second)
+	return &budget{
+		ratio:   ratio,
+		reserve: minPerSecond * float64(n),
+		calls:   make([]int, n),
+		retries: make([]int, n),
+		now:     time.Now,
+	}
+}
+
+type budget struct {
+	mu      sync.Mutex
+	ratio   float64
+	reserve float64
+	// calls and retries are ring buffers of per second counters
+	calls   []int
+	retries []int
+	last    int64
+	now     func() time.Time
+}
+
+// advance resets the counters of the seconds elapsed since the last update and returns the current slot
+func (b *budget) advance() int {
+	sec := b.now().Unix()
+	n := int64(len(b.calls))
+	if b.last != 0 {
+		for s := b.last + 1; s <= sec && s <= b.last+n; s++ {
+			b.calls[s%n] = 0
+			b.retries[s%n] = 0
+		}
+	}
+	b.last = sec
+	return int(sec % n)
+}
+
+func (b *budget) Call() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.calls[b.advance()]++
+}
+
+func (b *budget) Retry() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	i := b.advance()
+	var calls, retries int
+	for j := range b.calls {
+		calls += b.calls[j]
+		retries += b.retries[j]
+	}
+	if float64(retries+1) > float64(calls)*b.ratio+b.reserve {
+		return false
+	}
+	b.retries[i]++
+	return true
+}
@@ -0,0 +1,38 @@
+package retry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBudget(t *testing.T) {
+	now := time.Unix(1000, 0)
+	b := NewBudget(0.1, 0, 10*time.Second).(*budget)
+	b.now = func() time.Time { return now }
+
+	assert.False(t, b.Retry())
+	for i := 0; i < 20; i++ {
+		b.Call()
+	}
+	assert.True(t, b.Retry())
+	assert.True(t, b.Retry())
+	assert.False(t, b.Retry())
+
+	// the calls leave the window
+	now = now.Add(11 * time.Second)
+	b.Call()
+	assert.False(t, b.Retry())
+}
+
+func TestBudgetReserve(t *testing.T) {
+	now := time.Unix(1000, 0)
+	b := NewBudget(0, 1, 2*time.Second).(*budget)
+	b.now = func() time.Time { return now }
+	assert.True(t, b.Retry())
+	assert.True(t, b.Retry())
+	assert.False(t, b.Retry())
+	now = now.Add(2 * time.Second)
+	assert.True(t, b.Retry())
+}
@@ -0,0 +1,132 @@
+package retry
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"go.linka.cloud/grpc/interceptors"
+	"go.linka.cloud/grpc/match"
+)
+
+type ClientInterceptors interface {
+	interceptors.ClientInterceptors
+	prometheus.Collector
+}
+
+// NewClientInterceptors returns interceptors retrying the failed unary calls.
+// Retries are limited by a budget and honour the server pushback: the RetryInfo error details
+// and the grpc-retry-pushback-ms trailer, a negative pushback disables the retry.
+// Streams are not retried.
+func NewClientInterceptors(opts ...Option) ClientInterceptors {
+	return &interceptor{
+		opts: newOptions(opts...),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_client_retries_total",
+			Help: "Total number of RPCs retried by the client.",
+		}, []string{"grpc_service", "grpc_method"}),
+		throttled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_client_retries_throttled_total",
+			Help: "Total number of RPCs retries denied by the retry budget or the server pushback.",
+		}, []string{"grpc_service", "grpc_method"}),
+	}
+}
+
+// Pushback returns the error details asking the clients to retry after d, e.g.
+//
+//	errors.Unavailabled(err, retry.Pushback(time.Second))
+func Pushback(d time.Duration) proto.Message {
+	return &errdetails.RetryInfo{RetryDelay: durationpb.New(d)}
+}
+
+type interceptor struct {
+	opts      options
+	retries   *prometheus.CounterVec
+	throttled *prometheus.CounterVec
+}
+
+func (i *interceptor) Describe(descs chan<- *prometheus.Desc) {
+	i.retries.Describe(descs)
+	i.throttled.Describe(descs)
+}
+
+func (i *interceptor) Collect(c chan<- prometheus.Metric) {
+	i.retries.Collect(c)
+	i.throttled.Collect(c)
+}
+
+func (i *interceptor) retryable(err error) bool {
+	c := status.Code(err)
+	for _, v := range i.opts.codes {
+		if v == c {
+			return true
+		}
+	}
+	return false
+}
+
+// pushback returns the delay requested by the server, ok is false if the server asked not to retry
+func (i *interceptor) pushback(err error, trailer metadata.MD) (d time.Duration, set bool, ok bool) {
+	if v := trailer.Get(pushbackMetadataKey); len(v) != 0 {
+		ms, err := strconv.Atoi(v[0])
+		if err != nil || ms < 0 {
+			return 0, true, false
+		}
+		d, set = time.Duration(ms)*time.Millisecond, true
+	}
+	for _, v := range status.Convert(err).Details() {
+		if ri, ok := v.(*errdetails.RetryInfo); ok && ri.GetRetryDelay() != nil {
+			d, set = ri.GetRetryDelay().AsDuration(), true
+		}
+	}
+	if d > i.opts.maxPushback {
+		d = i.opts.maxPushback
+	}
+	return d, set, true
+}
+
+func (i *interceptor) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		i.opts.budget.Call()
+		s, m := match.Split(method)
+		for attempt := 1; ; attempt++ {
+			var trailer metadata.MD
+			err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Trailer(&trailer))...)
+			if err == nil || attempt >= i.opts.max || !i.retryable(err) || ctx.Err() != nil {
+				return err
+			}
+			wait, set, ok := i.pushback(err, trailer)
+			if !ok || !i.opts.budget.Retry() {
+				i.throttled.WithLabelValues(s, m).Inc()
+				return err
+			}
+			if !set {
+				wait = i.opts.backoff(attempt)
+			}
+			if dl, ok := ctx.Deadline(); ok && time.Until(dl) < wait {
+				// the retry could not complete in time
+				return err
+			}
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(wait):
+			}
+			i.retries.WithLabelValues(s, m).Inc()
+		}
+	}
+}
+
+func (i *interceptor) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
@@ -0,0 +1,80 @@
+package retry
+
+import (
+	"time"
+
+	"google.golang.org/grpc/codes"
+
+	"go.linka.cloud/grpc/utils/backoff"
+)
+
+const (
+	defaultMax          = 3
+	defaultBudgetRatio  = 0.1
+	defaultBudgetMin    = 10
+	defaultBudgetWindow = 10 * time.Second
+	defaultMaxPushback  = 30 * time.Second
+	pushbackMetadataKey = "grpc-retry-pushback-ms"
+)
+
+type Option func(o *options)
+
+// WithMax sets the maximum number of attempts, including the first call
+func WithMax(n int) Option {
+	return func(o *options) {
+		o.max = n
+	}
+}
+
+// WithCodes sets the retryable status codes, defaults to Unavailable
+func WithCodes(codes ...codes.Code) Option {
+	return func(o *options) {
+		o.codes = codes
+	}
+}
+
+// WithBackoff sets the delay before the given retry attempt, starting at 1, defaults to backoff.Do
+func WithBackoff(fn func(attempt int) time.Duration) Option {
+	return func(o *options) {
+		o.backoff = fn
+	}
+}
+
+// WithBudget sets the retry budget shared by all the methods, see NewBudget.
+// Defaults to 10% of the calls over 10 seconds with 10 retries per second reserved.
+func WithBudget(b Budget) Option {
+	return func(o *options) {
+		o.budget = b
+	}
+}
+
+// WithMaxPushback caps the delay requested by the servers, defaults to 30 seconds
+func WithMaxPushback(d time.Duration) Option {
+	return func(o *options) {
+		o.maxPushback = d
+	}
+}
+
+type options struct {
+	max         int
+	codes       []codes.Code
+	backoff     func(attempt int) time.Duration
+	budget      Budget
+	maxPushback time.Duration
+}
+
+func newOptions(opts ...Option) options {
+	o := options{
+		max:         defaultMax,
+		codes:       []codes.Code{codes.Unavailable},
+		backoff:     backoff.Do,
+		maxPushback: defaultMaxPushback,
+	}
+	for _, v := range opts {
+		v(&o)
+	}
+	if o.budget == nil {
+		o.budget = NewBudget(defaultBudgetRatio, defaultBudgetMin, defaultBudgetWindow)
+	}
+	return o
+}
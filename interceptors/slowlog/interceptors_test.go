@@ -0,0 +1,64 @@
+package slowlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"go.linka.cloud/grpc/logger"
+	"go.linka.cloud/grpc/rpcctx"
+)
+
+func TestSlowLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "slowlog")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var buf bytes.Buffer
+	ctx := logger.Set(context.Background(), logger.New().SetOutput(&buf).SetFormatter(logger.FormatJSON.Formatter()))
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(rpcctx.RequestIDKey, "id"))
+	i := NewServerInterceptors(WithThreshold(20*time.Millisecond), WithMaxEvents(1), WithGoroutineDumps(dir, time.Minute)).UnaryServerInterceptor()
+	call := func(d time.Duration) {
+		_, err := i(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/svc.Service/Get"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			Record(ctx, "query", "SELECT 1", d)
+			Record(ctx, "query", "SELECT 2", d)
+			time.Sleep(d)
+			return nil, nil
+		})
+		require.NoError(t, err)
+	}
+	call(0)
+	assert.Empty(t, buf.String())
+
+	call(30 * time.Millisecond)
+	var m map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &m))
+	assert.Equal(t, "slow request", m["msg"])
+	assert.Equal(t, "/svc.Service/Get", m["method"])
+	assert.Equal(t, "id", m["request_id"])
+	assert.Equal(t, []interface{}{"query 30ms: SELECT 1"}, m["events"])
+	assert.Equal(t, float64(1), m["events_dropped"])
+	require.NotEmpty(t, m["goroutine_dump"])
+	b, err := ioutil.ReadFile(m["goroutine_dump"].(string))
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "goroutine")
+
+	// the dumps are rate limited
+	dump := m["goroutine_dump"]
+	buf.Reset()
+	call(30 * time.Millisecond)
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &m))
+	assert.Equal(t, dump, m["goroutine_dump"])
+
+	// the events are not recorded outside of a request
+	Record(context.Background(), "query", "SELECT 1", time.Second)
+}
@@ -0,0 +1,58 @@
+package slowlog
+
+import (
+	"time"
+)
+
+const (
+	defaultThreshold    = time.Second
+	defaultMaxEvents    = 100
+	defaultDumpInterval = time.Minute
+)
+
+type Option func(o *options)
+
+// WithThreshold sets the latency above which a request is diagnosed, defaults to 1 second
+func WithThreshold(d time.Duration) Option {
+	return func(o *options) {
+		o.threshold = d
+	}
+}
+
+// WithMaxEvents sets the number of events recorded per request, the following ones are only counted, defaults to 100
+func WithMaxEvents(n int) Option {
+	return func(o *options) {
+		o.maxEvents = n
+	}
+}
+
+// WithGoroutineDumps writes a goroutine dump in dir when a slow request is diagnosed, its path is logged
+// with the request diagnostics. At most one dump is written per interval, defaults to 1 minute,
+// the diagnostics of the following slow requests reference the last one.
+func WithGoroutineDumps(dir string, interval time.Duration) Option {
+	return func(o *options) {
+		o.dumpDir = dir
+		if interval > 0 {
+			o.dumpInterval = interval
+		}
+	}
+}
+
+type options struct {
+	threshold    time.Duration
+	maxEvents    int
+	dumpDir      string
+	dumpInterval time.Duration
+}
+
+func newOptions(opts ...Option) options {
+	o := options{
+		threshold:    defaultThreshold,
+		maxEvents:    defaultMaxEvents,
+		dumpInterval: defaultDumpInterval,
+	}
+	for _, v := range opts {
+		v(&o)
+	}
+	return o
+}
@@ -0,0 +1,155 @@
+// Package slowlog logs the requests exceeding a latency threshold as a single structured entry correlating
+// their diagnostics: the request and trace ids, the events recorded while handling them, e.g. the database
+// queries, and a reference to a goroutine dump taken when they completed.
+//
+// The events are recorded with Record, e.g. from a gorm logger Trace method:
+//
+//	func (l *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+//		sql, _ := fc()
+//		slowlog.Record(ctx, "query", sql, time.Since(begin))
+//	}
+package slowlog
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+
+	"go.linka.cloud/grpc/interceptors"
+	"go.linka.cloud/grpc/interceptors/noop"
+	"go.linka.cloud/grpc/interceptors/tracing"
+	"go.linka.cloud/grpc/logger"
+	"go.linka.cloud/grpc/rpcctx"
+)
+
+// Event is a diagnostic event recorded while handling a request
+type Event struct {
+	Kind     string
+	Text     string
+	Duration time.Duration
+}
+
+func (e Event) String() string {
+	return fmt.Sprintf("%s %v: %s", e.Kind, e.Duration, e.Text)
+}
+
+type recorderKey struct{}
+
+type recorder struct {
+	mu      sync.Mutex
+	max     int
+	events  []Event
+	dropped int
+}
+
+// Record adds an event to the diagnostics of the request, it is a noop outside of the requests handled
+// by the interceptors
+func Record(ctx context.Context, kind, text string, d time.Duration) {
+	r, ok := ctx.Value(recorderKey{}).(*recorder)
+	if !ok {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.events) >= r.max {
+		r.dropped++
+		return
+	}
+	r.events = append(r.events, Event{Kind: kind, Text: text, Duration: d})
+}
+
+// NewServerInterceptors returns the interceptors logging the diagnostics of the slow unary requests.
+// The streams are not diagnosed as their duration is not a latency.
+// It is a debug-only interceptor, compiled out with the nodebug build tag, see interceptors.Debug.
+func NewServerInterceptors(opts ...Option) interceptors.ServerInterceptors {
+	if !interceptors.Debug {
+		return noop.New()
+	}
+	return &interceptor{opts: newOptions(opts...)}
+}
+
+type interceptor struct {
+	opts options
+
+	mu       sync.Mutex
+	lastDump time.Time
+	dump     string
+}
+
+func (i *interceptor) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		r := &recorder{max: i.opts.maxEvents}
+		start := time.Now()
+		res, err := handler(context.WithValue(ctx, recorderKey{}, r), req)
+		if d := time.Since(start); d > i.opts.threshold {
+			i.log(ctx, info.FullMethod, d, err, r)
+		}
+		return res, err
+	}
+}
+
+func (i *interceptor) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, ss)
+	}
+}
+
+func (i *interceptor) log(ctx context.Context, method string, d time.Duration, err error, r *recorder) {
+	log := logger.C(ctx)
+	fields := []interface{}{"method", method, "duration", d.String(), "threshold", i.opts.threshold.String(), "code", status.Code(err).String()}
+	if id, ok := rpcctx.RequestID(ctx); ok {
+		fields = append(fields, logger.FieldRequestID, id)
+	}
+	if id, ok := tracing.TraceID(ctx); ok {
+		fields = append(fields, "trace_id", id)
+	}
+	r.mu.Lock()
+	events := make([]string, len(r.events))
+	for k, v := range r.events {
+		events[k] = v.String()
+	}
+	fields = append(fields, "events", events)
+	if r.dropped != 0 {
+		fields = append(fields, "events_dropped", r.dropped)
+	}
+	r.mu.Unlock()
+	if i.opts.dumpDir != "" {
+		if p, err := i.goroutineDump(); err != nil {
+			log.Errorf("slowlog: failed to dump goroutines: %v", err)
+		} else {
+			fields = append(fields, "goroutine_dump", p)
+		}
+	}
+	log.WithFields(fields...).Warn("slow request")
+}
+
+// goroutineDump writes a goroutine dump if none was written during the interval, it returns the last dump path
+func (i *interceptor) goroutineDump() (string, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	now := time.Now()
+	if i.dump != "" && now.Sub(i.lastDump) < i.opts.dumpInterval {
+		return i.dump, nil
+	}
+	if err := os.MkdirAll(i.opts.dumpDir, 0755); err != nil {
+		return "", err
+	}
+	p := filepath.Join(i.opts.dumpDir, fmt.Sprintf("goroutines-%s.txt", now.UTC().Format("20060102T150405.000000000")))
+	f, err := os.Create(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if err := pprof.Lookup("goroutine").WriteTo(f, 2); err != nil {
+		return "", err
+	}
+	i.lastDump, i.dump = now, p
+	return p, nil
+}
@@ -0,0 +1,152 @@
+// Package swap provides interceptors which inner implementation can be replaced at runtime, e.g. when the
+// configuration of the authorization policies, the rate limits or the chaos settings is reloaded,
+// without recreating the grpc.Server:
+//
+//	limits := swap.NewServer(newRateLimit(conf))
+//	svc, _ := service.New(service.WithServerInterceptors(limits))
+//	...
+//	limits.Swap(newRateLimit(newConf))
+//
+// The calls in flight complete with the interceptors they started with.
+package swap
+
+import (
+	"context"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+
+	"go.linka.cloud/grpc/interceptors"
+)
+
+type ServerInterceptors interface {
+	interceptors.ServerInterceptors
+	// Swap replaces the inner interceptors, nil disables them
+	Swap(i interceptors.ServerInterceptors)
+}
+
+type ClientInterceptors interface {
+	interceptors.ClientInterceptors
+	// Swap replaces the inner interceptors, nil disables them
+	Swap(i interceptors.ClientInterceptors)
+}
+
+type Interceptors interface {
+	interceptors.Interceptors
+	// Swap replaces the inner interceptors, nil disables them
+	Swap(i interceptors.Interceptors)
+}
+
+// NewServer returns server interceptors delegating to i until they are swapped
+func NewServer(i interceptors.ServerInterceptors) ServerInterceptors {
+	s := &server{}
+	s.Swap(i)
+	return s
+}
+
+// NewClient returns client interceptors delegating to i until they are swapped
+func NewClient(i interceptors.ClientInterceptors) ClientInterceptors {
+	c := &client{}
+	c.Swap(i)
+	return c
+}
+
+// New returns interceptors delegating to i until they are swapped
+func New(i interceptors.Interceptors) Interceptors {
+	b := &both{}
+	b.Swap(i)
+	return b
+}
+
+type serverChain struct {
+	unary  grpc.UnaryServerInterceptor
+	stream grpc.StreamServerInterceptor
+}
+
+type server struct {
+	v atomic.Value
+}
+
+func (s *server) Swap(i interceptors.ServerInterceptors) {
+	var c serverChain
+	if i != nil {
+		c = serverChain{unary: i.UnaryServerInterceptor(), stream: i.StreamServerInterceptor()}
+	}
+	s.v.Store(c)
+}
+
+func (s *server) load() serverChain {
+	return s.v.Load().(serverChain)
+}
+
+func (s *server) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if c := s.load(); c.unary != nil {
+			return c.unary(ctx, req, info, handler)
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (s *server) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if c := s.load(); c.stream != nil {
+			return c.stream(srv, ss, info, handler)
+		}
+		return handler(srv, ss)
+	}
+}
+
+type clientChain struct {
+	unary  grpc.UnaryClientInterceptor
+	stream grpc.StreamClientInterceptor
+}
+
+type client struct {
+	v atomic.Value
+}
+
+func (c *client) Swap(i interceptors.ClientInterceptors) {
+	var cc clientChain
+	if i != nil {
+		cc = clientChain{unary: i.UnaryClientInterceptor(), stream: i.StreamClientInterceptor()}
+	}
+	c.v.Store(cc)
+}
+
+func (c *client) load() clientChain {
+	return c.v.Load().(clientChain)
+}
+
+func (c *client) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if i := c.load(); i.unary != nil {
+			return i.unary(ctx, method, req, reply, cc, invoker, opts...)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+func (c *client) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if i := c.load(); i.stream != nil {
+			return i.stream(ctx, desc, cc, method, streamer, opts...)
+		}
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
+
+type both struct {
+	server
+	client
+}
+
+func (b *both) Swap(i interceptors.Interceptors) {
+	if i == nil {
+		b.server.Swap(nil)
+		b.client.Swap(nil)
+		return
+	}
+	b.server.Swap(i)
+	b.client.Swap(i)
+}
@@ -0,0 +1,57 @@
+package swap
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+
+	"go.linka.cloud/grpc/interceptors"
+)
+
+type tag string
+
+func (t tag) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return string(t), nil
+	}
+}
+
+func (t tag) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(string(t), ss)
+	}
+}
+
+func TestSwap(t *testing.T) {
+	s := NewServer(tag("a"))
+	unary := s.UnaryServerInterceptor()
+	stream := s.StreamServerInterceptor()
+	call := func() interface{} {
+		res, _ := unary(context.Background(), nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return "handler", nil
+		})
+		return res
+	}
+	callStream := func() interface{} {
+		var got interface{}
+		_ = stream("handler", nil, &grpc.StreamServerInfo{}, func(srv interface{}, ss grpc.ServerStream) error {
+			got = srv
+			return nil
+		})
+		return got
+	}
+	assert.Equal(t, "a", call())
+	assert.Equal(t, "a", callStream())
+
+	s.Swap(tag("b"))
+	assert.Equal(t, "b", call())
+	assert.Equal(t, "b", callStream())
+
+	s.Swap(nil)
+	assert.Equal(t, "handler", call())
+	assert.Equal(t, "handler", callStream())
+
+	var _ interceptors.Interceptors = New(nil)
+}
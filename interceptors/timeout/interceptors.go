@@ -0,0 +1,142 @@
+package timeout
+
+import (
+	"context"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+
+	"go.linka.cloud/grpc/config"
+	"go.linka.cloud/grpc/errors"
+	"go.linka.cloud/grpc/interceptors"
+	"go.linka.cloud/grpc/interceptors/metadata"
+	"go.linka.cloud/grpc/logger"
+	"go.linka.cloud/grpc/match"
+)
+
+type ServerInterceptors interface {
+	interceptors.ServerInterceptors
+	prometheus.Collector
+	// Policies returns the current policies
+	Policies() Policies
+	// SetPolicies replaces the current policies
+	SetPolicies(p Policies)
+	// Watch loads the policies from the config and keeps them up to date until the context is done.
+	Watch(ctx context.Context, c config.Config) error
+}
+
+// NewServerInterceptors returns interceptors enforcing a maximum execution time on the server side.
+// The handler context is cancelled when the timeout expires, regardless of the deadline sent by the client.
+func NewServerInterceptors(opts ...Option) ServerInterceptors {
+	o := options{}
+	for _, v := range opts {
+		v(&o)
+	}
+	return &interceptor{
+		policies: o.policies,
+		timeouts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_server_handling_timeouts_total",
+			Help: "Total number of RPCs cancelled by the server side timeout policies.",
+		}, []string{"grpc_service", "grpc_method"}),
+	}
+}
+
+type interceptor struct {
+	mu       sync.RWMutex
+	policies Policies
+	timeouts *prometheus.CounterVec
+}
+
+func (i *interceptor) Policies() Policies {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.policies
+}
+
+func (i *interceptor) SetPolicies(p Policies) {
+	i.mu.Lock()
+	i.policies = p
+	i.mu.Unlock()
+}
+
+func (i *interceptor) Watch(ctx context.Context, c config.Config) error {
+	b, err := c.Read()
+	if err != nil {
+		return err
+	}
+	p, err := ParsePolicies(b)
+	if err != nil {
+		return err
+	}
+	i.SetPolicies(p)
+	updates := make(chan []byte)
+	if err := c.Watch(ctx, updates); err != nil {
+		return err
+	}
+	go func() {
+		log := logger.C(ctx)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case b := <-updates:
+				p, err := ParsePolicies(b)
+				if err != nil {
+					log.WithError(err).Error("failed to parse timeout policies")
+					continue
+				}
+				i.SetPolicies(p)
+				log.Info("timeout policies updated")
+			}
+		}
+	}()
+	return nil
+}
+
+func (i *interceptor) Describe(descs chan<- *prometheus.Desc) {
+	i.timeouts.Describe(descs)
+}
+
+func (i *interceptor) Collect(c chan<- prometheus.Metric) {
+	i.timeouts.Collect(c)
+}
+
+// check returns a DeadlineExceeded error if the server side timeout expired while the parent context is still valid
+func (i *interceptor) check(parent, ctx context.Context, method string, err error) error {
+	if parent.Err() != nil || ctx.Err() != context.DeadlineExceeded {
+		return err
+	}
+	s, m := match.Split(method)
+	i.timeouts.WithLabelValues(s, m).Inc()
+	return errors.DeadlineExceededf("%s: server timeout exceeded", method)
+}
+
+func (i *interceptor) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		d, ok := i.Policies().timeout(info.FullMethod)
+		if !ok {
+			return handler(ctx, req)
+		}
+		tctx, cancel := context.WithTimeout(ctx, d)
+		defer cancel()
+		res, err := handler(tctx, req)
+		if err := i.check(ctx, tctx, info.FullMethod, err); err != nil {
+			return nil, err
+		}
+		return res, nil
+	}
+}
+
+func (i *interceptor) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		d, ok := i.Policies().timeout(info.FullMethod)
+		if !ok {
+			return handler(srv, ss)
+		}
+		ctx := ss.Context()
+		tctx, cancel := context.WithTimeout(ctx, d)
+		defer cancel()
+		return i.check(ctx, tctx, info.FullMethod, handler(srv, metadata.NewContextServerStream(tctx, ss)))
+	}
+}
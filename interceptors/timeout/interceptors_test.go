@@ -0,0 +1,90 @@
+package timeout
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	i := NewServerInterceptors(
+		WithDefault(time.Second),
+		WithMethod("/test.Service/Slow", 50*time.Millisecond),
+		WithMethod("/test.Service/Unbounded", 0),
+	).(*interceptor)
+	unary := func(ctx context.Context, method string, handler grpc.UnaryHandler) error {
+		_, err := i.UnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		return err
+	}
+	deadline := func(ctx context.Context, method string) (d time.Duration, ok bool) {
+		require.NoError(t, unary(ctx, method, func(ctx context.Context, req interface{}) (interface{}, error) {
+			var dl time.Time
+			dl, ok = ctx.Deadline()
+			d = time.Until(dl)
+			return nil, nil
+		}))
+		return d, ok
+	}
+
+	// the policy deadline is applied
+	d, ok := deadline(context.Background(), "/test.Service/Get")
+	require.True(t, ok)
+	assert.True(t, d <= time.Second)
+	assert.True(t, d > 900*time.Millisecond)
+
+	// the shorter client deadline is kept
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	d, ok = deadline(ctx, "/test.Service/Get")
+	require.True(t, ok)
+	assert.True(t, d <= 100*time.Millisecond)
+
+	_, ok = deadline(context.Background(), "/test.Service/Unbounded")
+	assert.False(t, ok)
+
+	// the server timeout is reported as DeadlineExceeded and counted
+	wait := func(ctx context.Context, req interface{}) (interface{}, error) {
+		<-ctx.Done()
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	err := unary(context.Background(), "/test.Service/Slow", wait)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	assert.Contains(t, status.Convert(err).Message(), "server timeout exceeded")
+	assert.Equal(t, float64(1), testutil.ToFloat64(i.timeouts.WithLabelValues("test.Service", "Slow")))
+
+	// but not the expired client deadlines
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = unary(ctx, "/test.Service/Get", wait)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	assert.NotContains(t, status.Convert(err).Message(), "server timeout exceeded")
+	assert.Equal(t, float64(0), testutil.ToFloat64(i.timeouts.WithLabelValues("test.Service", "Get")))
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	i := NewServerInterceptors(WithMethod("/test.Service/Watch", 50*time.Millisecond)).(*interceptor)
+	err := i.StreamServerInterceptor()(nil, &serverStream{ctx: context.Background()}, &grpc.StreamServerInfo{FullMethod: "/test.Service/Watch"}, func(srv interface{}, ss grpc.ServerStream) error {
+		_, ok := ss.Context().Deadline()
+		assert.True(t, ok)
+		<-ss.Context().Done()
+		return ss.Context().Err()
+	})
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	assert.Equal(t, float64(1), testutil.ToFloat64(i.timeouts.WithLabelValues("test.Service", "Watch")))
+}
@@ -0,0 +1,36 @@
+package timeout
+
+import (
+	"time"
+)
+
+type Option func(o *options)
+
+// WithDefault sets the maximum execution time applied to the methods without a specific policy.
+// A zero value disables the default timeout.
+func WithDefault(d time.Duration) Option {
+	return func(o *options) {
+		o.policies.Default = d
+	}
+}
+
+// WithMethod sets the maximum execution time for the given fully qualified method name, e.g. /helloworld.Greeter/SayHello
+func WithMethod(method string, d time.Duration) Option {
+	return func(o *options) {
+		if o.policies.Methods == nil {
+			o.policies.Methods = make(map[string]time.Duration)
+		}
+		o.policies.Methods[method] = d
+	}
+}
+
+// WithPolicies replaces the whole policies set
+func WithPolicies(p Policies) Option {
+	return func(o *options) {
+		o.policies = p
+	}
+}
+
+type options struct {
+	policies Policies
+}
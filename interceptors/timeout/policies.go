@@ -0,0 +1,70 @@
+package timeout
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.linka.cloud/grpc/match"
+)
+
+// Policies defines the server side maximum execution time of the methods.
+type Policies struct {
+	// Default is applied to all the methods without a specific policy
+	Default time.Duration
+	// Methods maps the method patterns, e.g. /helloworld.Greeter/SayHello or /helloworld.Greeter/,
+	// to their maximum execution time, the most specific pattern matching a method wins
+	Methods map[string]time.Duration
+}
+
+// timeout returns the timeout of the most specific pattern matching the method
+func (p Policies) timeout(method string) (time.Duration, bool) {
+	if d, ok := p.Methods[method]; ok {
+		return d, d > 0
+	}
+	var (
+		d       = p.Default
+		pattern string
+		found   bool
+	)
+	for k, v := range p.Methods {
+		if match.Method(k, method) && (!found || match.Specific(k, pattern)) {
+			d, pattern, found = v, k, true
+		}
+	}
+	return d, d > 0
+}
+
+type policiesJSON struct {
+	Default string            `json:"default"`
+	Methods map[string]string `json:"methods"`
+}
+
+// ParsePolicies parses policies from their json representation, e.g.
+//
+//	{"default": "10s", "methods": {"/helloworld.Greeter/SayHello": "500ms"}}
+func ParsePolicies(b []byte) (Policies, error) {
+	var j policiesJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return Policies{}, err
+	}
+	var p Policies
+	if j.Default != "" {
+		d, err := time.ParseDuration(j.Default)
+		if err != nil {
+			return Policies{}, fmt.Errorf("default: %w", err)
+		}
+		p.Default = d
+	}
+	if len(j.Methods) != 0 {
+		p.Methods = make(map[string]time.Duration, len(j.Methods))
+	}
+	for k, v := range j.Methods {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return Policies{}, fmt.Errorf("%s: %w", k, err)
+		}
+		p.Methods[k] = d
+	}
+	return p, nil
+}
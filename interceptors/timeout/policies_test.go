@@ -0,0 +1,25 @@
+package timeout
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePolicies(t *testing.T) {
+	p, err := ParsePolicies([]byte(`{"default": "10s", "methods": {"/test.Service/fast": "500ms", "/test.Service/unbounded": "0s"}}`))
+	require.NoError(t, err)
+	d, ok := p.timeout("/test.Service/other")
+	assert.True(t, ok)
+	assert.Equal(t, 10*time.Second, d)
+	d, ok = p.timeout("/test.Service/fast")
+	assert.True(t, ok)
+	assert.Equal(t, 500*time.Millisecond, d)
+	_, ok = p.timeout("/test.Service/unbounded")
+	assert.False(t, ok)
+
+	_, err = ParsePolicies([]byte(`{"default": "ten seconds"}`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,106 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-opentracing/go/otgrpc"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go.linka.cloud/grpc/interceptors"
+)
+
+// TailSampler decides, once the rpc completed, whether its trace is kept
+type TailSampler func(method string, duration time.Duration, err error) bool
+
+// KeepErrors keeps the traces of the rpcs failed with one of the codes, any error if none is given
+func KeepErrors(cs ...codes.Code) TailSampler {
+	return func(_ string, _ time.Duration, err error) bool {
+		if err == nil {
+			return false
+		}
+		if len(cs) == 0 {
+			return true
+		}
+		c := status.Code(err)
+		for _, v := range cs {
+			if v == c {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// KeepSlow keeps the traces of the rpcs which took longer than threshold
+func KeepSlow(threshold time.Duration) TailSampler {
+	return func(_ string, d time.Duration, _ error) bool {
+		return d > threshold
+	}
+}
+
+// KeepAny keeps the trace if one of the samplers keeps it, e.g. KeepAny(KeepErrors(), KeepSlow(time.Second))
+func KeepAny(samplers ...TailSampler) TailSampler {
+	return func(method string, d time.Duration, err error) bool {
+		for _, v := range samplers {
+			if v(method, d, err) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// NewSampledServerInterceptors returns the server tracing interceptors with a tail based sampling decision:
+// once the rpc completed, the span sampling priority is set according to the sampler.
+// The tracer must sample all the traces, e.g. with a constant sampler, and honour the sampling.priority tag
+// so that the traces not kept are dropped.
+func NewSampledServerInterceptors(s TailSampler, opts ...otgrpc.Option) interceptors.ServerInterceptors {
+	return sampled{tracing: tracing{opts: opts}, s: s}
+}
+
+type sampled struct {
+	tracing
+	s TailSampler
+}
+
+func (t sampled) decide(ctx context.Context, method string, start time.Time, err error) {
+	span := opentracing.SpanFromContext(ctx)
+	if span == nil {
+		return
+	}
+	if t.s(method, time.Since(start), err) {
+		ext.SamplingPriority.Set(span, 1)
+	} else {
+		ext.SamplingPriority.Set(span, 0)
+	}
+}
+
+func (t sampled) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	i := t.tracing.UnaryServerInterceptor()
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		// the decision is made in the handler so that it happens before the span is finished
+		return i(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			start := time.Now()
+			res, err := handler(ctx, req)
+			t.decide(ctx, info.FullMethod, start, err)
+			return res, err
+		})
+	}
+}
+
+func (t sampled) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	i := t.tracing.StreamServerInterceptor()
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return i(srv, ss, info, func(srv interface{}, ss grpc.ServerStream) error {
+			start := time.Now()
+			err := handler(srv, ss)
+			t.decide(ss.Context(), info.FullMethod, start, err)
+			return err
+		})
+	}
+}
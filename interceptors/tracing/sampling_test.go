@@ -0,0 +1,43 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSamplers(t *testing.T) {
+	s := KeepAny(KeepErrors(codes.Internal), KeepSlow(time.Second))
+	assert.False(t, s("", time.Millisecond, nil))
+	assert.False(t, s("", time.Millisecond, status.Error(codes.NotFound, "")))
+	assert.True(t, s("", time.Millisecond, status.Error(codes.Internal, "")))
+	assert.True(t, s("", 2*time.Second, nil))
+	assert.True(t, KeepErrors()("", 0, status.Error(codes.NotFound, "")))
+}
+
+func TestSampledServerInterceptors(t *testing.T) {
+	tracer := mocktracer.New()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+
+	i := NewSampledServerInterceptors(KeepErrors()).UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Method"}
+	for _, err := range []error{nil, status.Error(codes.Internal, "")} {
+		_, _ = i(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, err
+		})
+	}
+	spans := tracer.FinishedSpans()
+	require.Len(t, spans, 2)
+	// the mock tracer applies the sampling priority to the span context
+	assert.False(t, spans[0].SpanContext.Sampled)
+	assert.True(t, spans[1].SpanContext.Sampled)
+}
@@ -0,0 +1,45 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+)
+
+// TraceID returns the trace id of the span in the context. As opentracing does not expose the ids,
+// it is read from the span context propagation headers: W3C traceparent, Jaeger uber-trace-id,
+// and the *-traceid ones used by B3 and the basic tracers.
+func TraceID(ctx context.Context) (string, bool) {
+	span := opentracing.SpanFromContext(ctx)
+	if span == nil {
+		return "", false
+	}
+	c := opentracing.HTTPHeadersCarrier{}
+	if err := span.Tracer().Inject(span.Context(), opentracing.HTTPHeaders, c); err != nil {
+		return "", false
+	}
+	return traceID(c)
+}
+
+func traceID(c opentracing.HTTPHeadersCarrier) (string, bool) {
+	var id string
+	_ = c.ForeachKey(func(k, v string) error {
+		switch k = strings.ToLower(k); {
+		case k == "traceparent":
+			// version-traceid-spanid-flags
+			if p := strings.Split(v, "-"); len(p) == 4 {
+				id = p[1]
+			}
+		case k == "uber-trace-id":
+			// traceid:spanid:parentid:flags
+			if i := strings.Index(v, ":"); i > 0 {
+				id = v[:i]
+			}
+		case strings.HasSuffix(k, "traceid") && id == "":
+			id = v
+		}
+		return nil
+	})
+	return id, id != ""
+}
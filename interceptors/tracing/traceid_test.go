@@ -0,0 +1,32 @@
+package tracing
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTraceID(t *testing.T) {
+	_, ok := TraceID(context.Background())
+	assert.False(t, ok)
+
+	tracer := mocktracer.New()
+	span := tracer.StartSpan("test")
+	id, ok := TraceID(opentracing.ContextWithSpan(context.Background(), span))
+	assert.True(t, ok)
+	assert.Equal(t, strconv.Itoa(span.Context().(mocktracer.MockSpanContext).TraceID), id)
+
+	for k, v := range map[string]string{
+		"Traceparent":   "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		"Uber-Trace-Id": "0af7651916cd43dd8448eb211c80319c:b7ad6b7169203331:0:1",
+		"X-B3-Traceid":  "0af7651916cd43dd8448eb211c80319c",
+	} {
+		id, ok := traceID(opentracing.HTTPHeadersCarrier{k: []string{v}})
+		assert.True(t, ok, k)
+		assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", id, k)
+	}
+}
@@ -0,0 +1,310 @@
+package leader
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.linka.cloud/grpc/logger"
+)
+
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	// microTime is the format of the kubernetes MicroTime
+	microTime = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+var (
+	errNotFound = errors.New("leader: lease not found")
+	errConflict = errors.New("leader: lease modified concurrently")
+)
+
+type lease struct {
+	APIVersion string        `json:"apiVersion"`
+	Kind       string        `json:"kind"`
+	Metadata   leaseMetadata `json:"metadata"`
+	Spec       leaseSpec     `json:"spec"`
+}
+
+type leaseMetadata struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace,omitempty"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+type leaseSpec struct {
+	HolderIdentity       string `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds int32  `json:"leaseDurationSeconds,omitempty"`
+	AcquireTime          string `json:"acquireTime,omitempty"`
+	RenewTime            string `json:"renewTime,omitempty"`
+	LeaseTransitions     int32  `json:"leaseTransitions,omitempty"`
+}
+
+// NewKubernetes returns an Elector using the coordination.k8s.io Lease name, as the kubernetes
+// controllers do. The service account needs the get, create and update permissions on the lease.
+func NewKubernetes(name string, opts ...Option) (Elector, error) {
+	o := options{
+		leaseDuration: defaultLeaseDuration,
+		renewDeadline: defaultRenewDeadline,
+		retryPeriod:   defaultRetryPeriod,
+	}
+	for _, v := range opts {
+		v(&o)
+	}
+	if o.renewDeadline >= o.leaseDuration {
+		return nil, fmt.Errorf("leader: renew deadline %v must be lower than the lease duration %v", o.renewDeadline, o.leaseDuration)
+	}
+	k := &kubernetes{name: name, opts: o}
+	if o.host == "" {
+		if err := k.inCluster(); err != nil {
+			return nil, err
+		}
+	}
+	if k.opts.client == nil {
+		k.opts.client = http.DefaultClient
+	}
+	if k.opts.namespace == "" {
+		k.opts.namespace = "default"
+		if b, err := ioutil.ReadFile(filepath.Join(serviceAccountDir, "namespace")); err == nil {
+			k.opts.namespace = strings.TrimSpace(string(b))
+		}
+	}
+	return k, nil
+}
+
+type kubernetes struct {
+	name string
+	opts options
+	// tokenFile is read on each request as the service account tokens are rotated
+	tokenFile string
+
+	// observed is the last lease record seen and when, the expiration is computed from the local
+	// observation time so that it does not depend on the clocks of the other replicas
+	observed     leaseSpec
+	observedTime time.Time
+}
+
+func (k *kubernetes) inCluster() error {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return errors.New("leader: not running in a kubernetes cluster, use WithAPIServer")
+	}
+	ca, err := ioutil.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return errors.New("leader: invalid service account CA certificate")
+	}
+	k.opts.host = "https://" + net.JoinHostPort(host, port)
+	k.tokenFile = filepath.Join(serviceAccountDir, "token")
+	if k.opts.client == nil {
+		k.opts.client = &http.Client{
+			Timeout:   k.opts.retryPeriod,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+		}
+	}
+	return nil
+}
+
+func (k *kubernetes) Run(ctx context.Context, id string, onChange func(leader string)) error {
+	log := logger.C(ctx).WithFields("lease", k.name, "identity", id)
+	var (
+		leader  string
+		renewed time.Time
+	)
+	set := func(l string) {
+		if l == leader {
+			return
+		}
+		leader = l
+		onChange(l)
+	}
+	defer func() {
+		if leader != id {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), k.opts.retryPeriod)
+		defer cancel()
+		if err := k.release(ctx, id); err != nil {
+			log.Warnf("failed to release leadership: %v", err)
+		}
+	}()
+	t := time.NewTicker(k.opts.retryPeriod)
+	defer t.Stop()
+	for {
+		holder, err := k.tryAcquireOrRenew(ctx, id)
+		switch {
+		case err == nil:
+			if holder == id {
+				renewed = time.Now()
+			}
+			set(holder)
+		case ctx.Err() != nil:
+		case leader == id && time.Since(renewed) > k.opts.renewDeadline:
+			log.Errorf("failed to renew leadership: %v", err)
+			set("")
+		default:
+			log.Debugf("failed to acquire or renew lease: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+// tryAcquireOrRenew acquires the lease if it is free or expired, renews it if id holds it,
+// and returns its holder
+func (k *kubernetes) tryAcquireOrRenew(ctx context.Context, id string) (string, error) {
+	now := time.Now()
+	l, err := k.get(ctx)
+	if err == errNotFound {
+		l = &lease{
+			APIVersion: "coordination.k8s.io/v1",
+			Kind:       "Lease",
+			Metadata:   leaseMetadata{Name: k.name, Namespace: k.opts.namespace},
+			Spec: leaseSpec{
+				HolderIdentity:       id,
+				LeaseDurationSeconds: k.leaseSeconds(),
+				AcquireTime:          now.Format(microTime),
+				RenewTime:            now.Format(microTime),
+			},
+		}
+		if err := k.do(ctx, http.MethodPost, k.path(""), l, nil); err != nil {
+			return "", err
+		}
+		k.observe(l.Spec, now)
+		return id, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if l.Spec != k.observed {
+		k.observe(l.Spec, now)
+	}
+	s := &l.Spec
+	if s.HolderIdentity != "" && s.HolderIdentity != id && now.Before(k.observedTime.Add(k.opts.leaseDuration)) {
+		return s.HolderIdentity, nil
+	}
+	if s.HolderIdentity != id {
+		s.HolderIdentity = id
+		s.AcquireTime = now.Format(microTime)
+		s.LeaseTransitions++
+	}
+	s.LeaseDurationSeconds = k.leaseSeconds()
+	s.RenewTime = now.Format(microTime)
+	if err := k.do(ctx, http.MethodPut, k.path(k.name), l, nil); err != nil {
+		return "", err
+	}
+	k.observe(l.Spec, now)
+	return id, nil
+}
+
+// release frees the lease so that a standby replica takes over without waiting for its expiration
+func (k *kubernetes) release(ctx context.Context, id string) error {
+	l, err := k.get(ctx)
+	if err != nil {
+		return err
+	}
+	if l.Spec.HolderIdentity != id {
+		return nil
+	}
+	l.Spec.HolderIdentity = ""
+	l.Spec.LeaseDurationSeconds = 1
+	l.Spec.RenewTime = time.Now().Format(microTime)
+	return k.do(ctx, http.MethodPut, k.path(k.name), l, nil)
+}
+
+func (k *kubernetes) observe(s leaseSpec, t time.Time) {
+	k.observed = s
+	k.observedTime = t
+}
+
+func (k *kubernetes) leaseSeconds() int32 {
+	s := int32((k.opts.leaseDuration + time.Second - 1) / time.Second)
+	if s < 1 {
+		return 1
+	}
+	return s
+}
+
+func (k *kubernetes) get(ctx context.Context) (*lease, error) {
+	var l lease
+	if err := k.do(ctx, http.MethodGet, k.path(k.name), nil, &l); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+func (k *kubernetes) path(name string) string {
+	p := "/apis/coordination.k8s.io/v1/namespaces/" + k.opts.namespace + "/leases"
+	if name != "" {
+		p += "/" + name
+	}
+	return p
+}
+
+func (k *kubernetes) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body []byte
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = b
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(k.opts.host, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	token := k.opts.token
+	if k.tokenFile != "" {
+		b, err := ioutil.ReadFile(k.tokenFile)
+		if err != nil {
+			return err
+		}
+		token = strings.TrimSpace(string(b))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res, err := k.opts.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return errNotFound
+	case res.StatusCode == http.StatusConflict:
+		return errConflict
+	case res.StatusCode < 200 || res.StatusCode > 299:
+		return fmt.Errorf("leader: %s %s: %s: %s", method, path, res.Status, strings.TrimSpace(string(b)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(b, out)
+}
@@ -0,0 +1,143 @@
+package leader
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// leases is a minimal kubernetes leases api
+type leases struct {
+	mu      sync.Mutex
+	version int
+	lease   *lease
+}
+
+func (s *leases) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !strings.HasPrefix(r.URL.Path, "/apis/coordination.k8s.io/v1/namespaces/test/leases") {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		if s.lease == nil {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(s.lease)
+	case http.MethodPost, http.MethodPut:
+		var l lease
+		if err := json.NewDecoder(r.Body).Decode(&l); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if (r.Method == http.MethodPost && s.lease != nil) ||
+			(r.Method == http.MethodPut && (s.lease == nil || l.Metadata.ResourceVersion != s.lease.Metadata.ResourceVersion)) {
+			http.Error(w, "conflict", http.StatusConflict)
+			return
+		}
+		s.version++
+		l.Metadata.ResourceVersion = strconv.Itoa(s.version)
+		s.lease = &l
+		json.NewEncoder(w).Encode(s.lease)
+	}
+}
+
+func (s *leases) holder() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lease == nil {
+		return ""
+	}
+	return s.lease.Spec.HolderIdentity
+}
+
+type candidate struct {
+	mu     sync.Mutex
+	leader string
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (c *candidate) get() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.leader
+}
+
+func run(t *testing.T, url, id string) *candidate {
+	e, err := NewKubernetes("test",
+		WithNamespace("test"),
+		WithAPIServer(url, "token", nil),
+		WithLeaseDuration(300*time.Millisecond),
+		WithRenewDeadline(200*time.Millisecond),
+		WithRetryPeriod(20*time.Millisecond),
+	)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &candidate{cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(c.done)
+		assert.NoError(t, e.Run(ctx, id, func(leader string) {
+			c.mu.Lock()
+			c.leader = leader
+			c.mu.Unlock()
+		}))
+	}()
+	return c
+}
+
+func TestKubernetes(t *testing.T) {
+	api := &leases{}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	a := run(t, srv.URL, "a")
+	require.Eventually(t, func() bool { return a.get() == "a" }, time.Second, 10*time.Millisecond)
+	b := run(t, srv.URL, "b")
+	require.Eventually(t, func() bool { return b.get() == "a" }, time.Second, 10*time.Millisecond)
+
+	// the leadership is released on return
+	a.cancel()
+	<-a.done
+	require.Eventually(t, func() bool { return b.get() == "b" }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "b", api.holder())
+	api.mu.Lock()
+	assert.Equal(t, int32(1), api.lease.Spec.LeaseTransitions)
+	api.mu.Unlock()
+
+	b.cancel()
+	<-b.done
+	assert.Empty(t, api.holder())
+}
+
+func TestKubernetesExpiration(t *testing.T) {
+	api := &leases{lease: &lease{
+		Metadata: leaseMetadata{Name: "test", Namespace: "test", ResourceVersion: "0"},
+		Spec:     leaseSpec{HolderIdentity: "gone", LeaseDurationSeconds: 1},
+	}}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	b := run(t, srv.URL, "b")
+	defer b.cancel()
+	require.Eventually(t, func() bool { return b.get() == "gone" }, time.Second, 10*time.Millisecond)
+	// the lease is taken over once it was not renewed for the lease duration
+	require.Eventually(t, func() bool { return b.get() == "b" }, time.Second, 10*time.Millisecond)
+}
+
+func TestKubernetesOptions(t *testing.T) {
+	_, err := NewKubernetes("test", WithAPIServer("http://localhost", "", nil), WithRenewDeadline(time.Minute))
+	assert.Error(t, err)
+}
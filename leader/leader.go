@@ -0,0 +1,14 @@
+// Package leader provides the leader election of the active-passive services, see service.WithLeaderElection
+package leader
+
+import (
+	"context"
+)
+
+// Elector elects a leader among the replicas of a service
+type Elector interface {
+	// Run campaigns for the leadership as id until ctx is done, the leadership is released on return.
+	// onChange is called with the identity of the current leader each time it changes, it is empty
+	// when the leader is unknown, e.g. when the leadership could not be renewed.
+	Run(ctx context.Context, id string, onChange func(leader string)) error
+}
@@ -0,0 +1,64 @@
+package leader
+
+import (
+	"net/http"
+	"time"
+)
+
+const (
+	defaultLeaseDuration = 15 * time.Second
+	defaultRenewDeadline = 10 * time.Second
+	defaultRetryPeriod   = 2 * time.Second
+)
+
+type Option func(o *options)
+
+// WithNamespace sets the namespace of the lease, it defaults to the pod namespace
+func WithNamespace(ns string) Option {
+	return func(o *options) {
+		o.namespace = ns
+	}
+}
+
+// WithLeaseDuration sets the duration the standby replicas wait before taking over a lease which was not renewed
+func WithLeaseDuration(d time.Duration) Option {
+	return func(o *options) {
+		o.leaseDuration = d
+	}
+}
+
+// WithRenewDeadline sets the duration the leader retries to renew the lease before giving up the leadership,
+// it must be lower than the lease duration
+func WithRenewDeadline(d time.Duration) Option {
+	return func(o *options) {
+		o.renewDeadline = d
+	}
+}
+
+// WithRetryPeriod sets the interval between the lease acquisition or renewal attempts
+func WithRetryPeriod(d time.Duration) Option {
+	return func(o *options) {
+		o.retryPeriod = d
+	}
+}
+
+// WithAPIServer sets the kubernetes api server url, the bearer token and the http client,
+// e.g. to run outside the cluster. It defaults to the in-cluster configuration.
+func WithAPIServer(url, token string, client *http.Client) Option {
+	return func(o *options) {
+		o.host = url
+		o.token = token
+		o.client = client
+	}
+}
+
+type options struct {
+	namespace     string
+	leaseDuration time.Duration
+	renewDeadline time.Duration
+	retryPeriod   time.Duration
+
+	host   string
+	token  string
+	client *http.Client
+}
@@ -0,0 +1,81 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// The field names of the framework logs, they are stable across the formats so that the log pipelines
+// can rely on them
+const (
+	FieldTimestamp = "ts"
+	FieldLevel     = "level"
+	FieldMessage   = "msg"
+	FieldService   = "svc"
+	FieldVersion   = "ver"
+	FieldRequestID = "request_id"
+)
+
+// Format is the logs output format
+type Format string
+
+const (
+	// FormatText is the human readable logrus text format
+	FormatText Format = "text"
+	// FormatJSON is the machine parseable format, one json object per line
+	FormatJSON Format = "json"
+)
+
+// ParseFormat parses a format name, e.g. from a flag or an environment variable
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case FormatText, FormatJSON:
+		return f, nil
+	}
+	return "", fmt.Errorf("logger: invalid format %q", s)
+}
+
+// Formatter returns the logrus formatter of the format, the unknown formats default to FormatText
+func (f Format) Formatter() logrus.Formatter {
+	fm := logrus.FieldMap{
+		logrus.FieldKeyTime:  FieldTimestamp,
+		logrus.FieldKeyLevel: FieldLevel,
+		logrus.FieldKeyMsg:   FieldMessage,
+	}
+	if f == FormatJSON {
+		return &logrus.JSONFormatter{FieldMap: fm, TimestampFormat: time.RFC3339Nano}
+	}
+	return &logrus.TextFormatter{FieldMap: fm, FullTimestamp: true}
+}
+
+// WithFormatter returns a copy of the logger using the formatter, with the same output, level, hooks and fields.
+// Unlike SetFormatter, the logger is not modified, e.g. the default one shared by all the services of the process.
+func WithFormatter(l Logger, f logrus.Formatter) Logger {
+	var (
+		base *logrus.Logger
+		data logrus.Fields
+	)
+	switch t := l.FieldLogger().(type) {
+	case *logrus.Logger:
+		base = t
+	case *logrus.Entry:
+		base, data = t.Logger, t.Data
+	default:
+		panic(fmt.Sprintf("unexpected logger type %T", t))
+	}
+	c := &logrus.Logger{
+		Out:          base.Out,
+		Hooks:        base.Hooks,
+		Formatter:    f,
+		ReportCaller: base.ReportCaller,
+		Level:        base.GetLevel(),
+		ExitFunc:     base.ExitFunc,
+	}
+	if len(data) == 0 {
+		return FromLogrus(c)
+	}
+	return FromLogrus(c.WithFields(data))
+}
@@ -0,0 +1,27 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithFormatter(t *testing.T) {
+	var buf bytes.Buffer
+	base := New().SetOutput(&buf).SetFormatter(&logrus.TextFormatter{DisableTimestamp: true})
+	log := WithFormatter(base.WithField("svc", "test"), FormatJSON.Formatter())
+	log.Info("json")
+	var m map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &m))
+	assert.Equal(t, "json", m[FieldMessage])
+	assert.Equal(t, "test", m["svc"])
+
+	// the original logger keeps its formatter
+	buf.Reset()
+	base.Info("text")
+	assert.Equal(t, "level=info msg=text\n", buf.String())
+}
@@ -34,6 +34,7 @@ type Logger interface {
 	WriterLevel(level logrus.Level) *io.PipeWriter
 
 	SetOutput(w io.Writer) Logger
+	SetFormatter(f logrus.Formatter) Logger
 
 	Debugf(format string, args ...interface{})
 	Infof(format string, args ...interface{})
@@ -223,3 +224,15 @@ func (l *logger) SetOutput(w io.Writer) Logger {
 	}
 	panic(fmt.Sprintf("unexpected logger type %T", l.fl))
 }
+
+func (l *logger) SetFormatter(f logrus.Formatter) Logger {
+	switch t := l.fl.(type) {
+	case *logrus.Logger:
+		t.SetFormatter(f)
+		return l
+	case *logrus.Entry:
+		t.Logger.SetFormatter(f)
+		return l
+	}
+	panic(fmt.Sprintf("unexpected logger type %T", l.fl))
+}
@@ -0,0 +1,220 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// FieldSuppressed is the field counting the identical entries dropped by a sampled logger since the last one logged
+const FieldSuppressed = "suppressed"
+
+// maxSampledKeys bounds the number of distinct entries tracked by a sampled logger
+const maxSampledKeys = 4096
+
+// Sampled returns a logger logging each identical entry, i.e. the same level and message, or format for the
+// formatted methods, at most burst times per period, e.g. to protect the log pipelines when a downstream outage
+// makes every request log the same error. The next entry logged after some were dropped carries their count in the
+// FieldSuppressed field. The loggers derived with the With methods share the sampling, the Fatal and Panic methods,
+// Logr and FieldLogger are not sampled.
+func Sampled(l Logger, burst int, period time.Duration) Logger {
+	return &sampled{Logger: l, s: &sampler{burst: burst, period: period, entries: make(map[string]*sample)}}
+}
+
+type sample struct {
+	start      time.Time
+	count      int
+	suppressed int
+}
+
+type sampler struct {
+	burst   int
+	period  time.Duration
+	mu      sync.Mutex
+	entries map[string]*sample
+}
+
+// allow reports whether the entry should be logged and the number of entries suppressed before it
+func (s *sampler) allow(level logrus.Level, key string) (bool, int) {
+	now := time.Now()
+	k := level.String() + ":" + key
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[k]
+	if !ok {
+		if len(s.entries) >= maxSampledKeys {
+			s.prune(now)
+		}
+		e = &sample{start: now}
+		s.entries[k] = e
+	}
+	if now.Sub(e.start) >= s.period {
+		e.start, e.count = now, 0
+	}
+	if e.count >= s.burst {
+		e.suppressed++
+		return false, 0
+	}
+	e.count++
+	n := e.suppressed
+	e.suppressed = 0
+	return true, n
+}
+
+// prune removes the entries of the elapsed periods
+func (s *sampler) prune(now time.Time) {
+	for k, v := range s.entries {
+		if now.Sub(v.start) >= s.period {
+			delete(s.entries, k)
+		}
+	}
+}
+
+type sampled struct {
+	Logger
+	s *sampler
+}
+
+// log returns the logger to use for the entry, or nil if it is dropped
+func (l *sampled) log(level logrus.Level, key string) Logger {
+	ok, n := l.s.allow(level, key)
+	if !ok {
+		return nil
+	}
+	if n != 0 {
+		return l.Logger.WithField(FieldSuppressed, n)
+	}
+	return l.Logger
+}
+
+func (l *sampled) WithField(key string, value interface{}) Logger {
+	return &sampled{Logger: l.Logger.WithField(key, value), s: l.s}
+}
+
+func (l *sampled) WithFields(kv ...interface{}) Logger {
+	return &sampled{Logger: l.Logger.WithFields(kv...), s: l.s}
+}
+
+func (l *sampled) WithError(err error) Logger {
+	return &sampled{Logger: l.Logger.WithError(err), s: l.s}
+}
+
+func (l *sampled) SetLevel(level logrus.Level) Logger {
+	l.Logger.SetLevel(level)
+	return l
+}
+
+func (l *sampled) SetOutput(w io.Writer) Logger {
+	l.Logger.SetOutput(w)
+	return l
+}
+
+func (l *sampled) SetFormatter(f logrus.Formatter) Logger {
+	l.Logger.SetFormatter(f)
+	return l
+}
+
+func (l *sampled) Debugf(format string, args ...interface{}) {
+	if log := l.log(logrus.DebugLevel, format); log != nil {
+		log.Debugf(format, args...)
+	}
+}
+
+func (l *sampled) Infof(format string, args ...interface{}) {
+	if log := l.log(logrus.InfoLevel, format); log != nil {
+		log.Infof(format, args...)
+	}
+}
+
+func (l *sampled) Printf(format string, args ...interface{}) {
+	if log := l.log(logrus.InfoLevel, format); log != nil {
+		log.Printf(format, args...)
+	}
+}
+
+func (l *sampled) Warnf(format string, args ...interface{}) {
+	if log := l.log(logrus.WarnLevel, format); log != nil {
+		log.Warnf(format, args...)
+	}
+}
+
+func (l *sampled) Warningf(format string, args ...interface{}) {
+	l.Warnf(format, args...)
+}
+
+func (l *sampled) Errorf(format string, args ...interface{}) {
+	if log := l.log(logrus.ErrorLevel, format); log != nil {
+		log.Errorf(format, args...)
+	}
+}
+
+func (l *sampled) Debug(args ...interface{}) {
+	if log := l.log(logrus.DebugLevel, fmt.Sprint(args...)); log != nil {
+		log.Debug(args...)
+	}
+}
+
+func (l *sampled) Info(args ...interface{}) {
+	if log := l.log(logrus.InfoLevel, fmt.Sprint(args...)); log != nil {
+		log.Info(args...)
+	}
+}
+
+func (l *sampled) Print(args ...interface{}) {
+	if log := l.log(logrus.InfoLevel, fmt.Sprint(args...)); log != nil {
+		log.Print(args...)
+	}
+}
+
+func (l *sampled) Warn(args ...interface{}) {
+	if log := l.log(logrus.WarnLevel, fmt.Sprint(args...)); log != nil {
+		log.Warn(args...)
+	}
+}
+
+func (l *sampled) Warning(args ...interface{}) {
+	l.Warn(args...)
+}
+
+func (l *sampled) Error(args ...interface{}) {
+	if log := l.log(logrus.ErrorLevel, fmt.Sprint(args...)); log != nil {
+		log.Error(args...)
+	}
+}
+
+func (l *sampled) Debugln(args ...interface{}) {
+	if log := l.log(logrus.DebugLevel, fmt.Sprint(args...)); log != nil {
+		log.Debugln(args...)
+	}
+}
+
+func (l *sampled) Infoln(args ...interface{}) {
+	if log := l.log(logrus.InfoLevel, fmt.Sprint(args...)); log != nil {
+		log.Infoln(args...)
+	}
+}
+
+func (l *sampled) Println(args ...interface{}) {
+	if log := l.log(logrus.InfoLevel, fmt.Sprint(args...)); log != nil {
+		log.Println(args...)
+	}
+}
+
+func (l *sampled) Warnln(args ...interface{}) {
+	if log := l.log(logrus.WarnLevel, fmt.Sprint(args...)); log != nil {
+		log.Warnln(args...)
+	}
+}
+
+func (l *sampled) Warningln(args ...interface{}) {
+	l.Warnln(args...)
+}
+
+func (l *sampled) Errorln(args ...interface{}) {
+	if log := l.log(logrus.ErrorLevel, fmt.Sprint(args...)); log != nil {
+		log.Errorln(args...)
+	}
+}
@@ -0,0 +1,47 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampled(t *testing.T) {
+	var buf bytes.Buffer
+	log := Sampled(New().SetOutput(&buf).SetFormatter(FormatJSON.Formatter()), 2, 50*time.Millisecond)
+	lines := func() []map[string]interface{} {
+		var out []map[string]interface{}
+		for _, v := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			if v == "" {
+				continue
+			}
+			var m map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(v), &m))
+			out = append(out, m)
+		}
+		buf.Reset()
+		return out
+	}
+
+	for i := 0; i < 5; i++ {
+		// the derived loggers share the sampling
+		log.WithField("request", i).Errorf("downstream unavailable: %d", i)
+		log.Warn("other")
+	}
+	ls := lines()
+	require.Len(t, ls, 4)
+	assert.Equal(t, "downstream unavailable: 1", ls[2]["msg"])
+
+	time.Sleep(60 * time.Millisecond)
+	log.Errorf("downstream unavailable: %d", 5)
+	log.Warn("other")
+	ls = lines()
+	require.Len(t, ls, 2)
+	assert.Equal(t, float64(3), ls[0][FieldSuppressed])
+	assert.Equal(t, float64(3), ls[1][FieldSuppressed])
+}
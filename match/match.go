@@ -0,0 +1,205 @@
+// Package match selects the rpcs by method, metadata or peer, it is the selector syntax shared by the interceptors
+// options, e.g. the authentication exemptions, the quotas or the cacheable methods.
+//
+// The method patterns match the full method names:
+//
+//	/pkg.Service/Method     the method
+//	/pkg.Service/           all the methods of the service
+//	/pkg.Service/Get*       the methods of the service starting with Get
+//	/pkg.v1.*/*             all the methods of the services of the pkg.v1 package
+//	*                       all the methods
+//
+// The * wildcard matches any sequence of characters but /, the pattern syntax is the one of path.Match.
+package match
+
+import (
+	"context"
+	"net"
+	"path"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"go.linka.cloud/grpc/rpcctx"
+)
+
+// Matcher reports whether an rpc is selected
+type Matcher interface {
+	Match(ctx context.Context, method string) bool
+}
+
+// Func is a Matcher function
+type Func func(ctx context.Context, method string) bool
+
+func (f Func) Match(ctx context.Context, method string) bool {
+	return f(ctx, method)
+}
+
+// Method reports whether the full method name matches the pattern
+func Method(pattern, method string) bool {
+	switch {
+	case pattern == "*" || pattern == method:
+		return true
+	case strings.HasSuffix(pattern, "/"):
+		return strings.HasPrefix(method, pattern)
+	case !strings.ContainsAny(pattern, `*?[\`):
+		return false
+	}
+	ok, _ := path.Match(pattern, method)
+	return ok
+}
+
+// AnyMethod reports whether the full method name matches one of the patterns
+func AnyMethod(patterns []string, method string) bool {
+	for _, v := range patterns {
+		if Method(v, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// Specific reports whether the pattern is more specific than the current one, it selects the most specific
+// of the patterns matching a method, e.g. /pkg.Service/Get* over /pkg.Service/: the longest one, the patterns
+// of the same length are ordered so that the selection does not depend on the map iteration order.
+// The exact method name must be looked up first as a longer pattern may match it.
+func Specific(pattern, current string) bool {
+	return len(pattern) > len(current) || len(pattern) == len(current) && pattern < current
+}
+
+// Split returns the service and method names of the full method name, e.g. pkg.Service and Method,
+// they are unknown if the name is malformed
+func Split(method string) (string, string) {
+	method = strings.TrimPrefix(method, "/")
+	if i := strings.Index(method, "/"); i >= 0 {
+		return method[:i], method[i+1:]
+	}
+	return "unknown", "unknown"
+}
+
+// Validate returns an error if the method pattern is malformed
+func Validate(pattern string) error {
+	_, err := path.Match(pattern, "")
+	return err
+}
+
+// Methods matches the rpcs which full method name matches one of the patterns
+func Methods(patterns ...string) Matcher {
+	return Func(func(_ context.Context, method string) bool {
+		return AnyMethod(patterns, method)
+	})
+}
+
+// Metadata matches the rpcs which incoming metadata key has a value matching the pattern,
+// any value if the pattern is empty
+func Metadata(key, pattern string) Matcher {
+	return Func(func(ctx context.Context, _ string) bool {
+		md, _ := metadata.FromIncomingContext(ctx)
+		return values(md.Get(key), pattern)
+	})
+}
+
+// OutgoingMetadata is the Metadata matcher of the client interceptors, it checks the outgoing metadata
+func OutgoingMetadata(key, pattern string) Matcher {
+	return Func(func(ctx context.Context, _ string) bool {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		return values(md.Get(key), pattern)
+	})
+}
+
+func values(vs []string, pattern string) bool {
+	for _, v := range vs {
+		if pattern == "" || pattern == v {
+			return true
+		}
+		if ok, _ := path.Match(pattern, v); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// PeerNetworks matches the rpcs which peer address is in one of the networks, e.g. 10.0.0.0/8
+func PeerNetworks(cidrs ...string) (Matcher, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, v := range cidrs {
+		_, n, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return Func(func(ctx context.Context, _ string) bool {
+		p, ok := peer.FromContext(ctx)
+		if !ok || p.Addr == nil {
+			return false
+		}
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			host = p.Addr.String()
+		}
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return false
+		}
+		for _, n := range nets {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+// PeerIdentity matches the rpcs which peer mTLS identity, see rpcctx.AuthInfo.Identity, matches one of the patterns,
+// e.g. spiffe://example.org/ns/*/sa/api
+func PeerIdentity(patterns ...string) Matcher {
+	return Func(func(ctx context.Context, _ string) bool {
+		a, ok := rpcctx.PeerAuth(ctx)
+		if !ok {
+			return false
+		}
+		id := a.Identity()
+		if id == "" {
+			return false
+		}
+		for _, v := range patterns {
+			if values([]string{id}, v) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// All matches the rpcs matched by all the matchers
+func All(m ...Matcher) Matcher {
+	return Func(func(ctx context.Context, method string) bool {
+		for _, v := range m {
+			if !v.Match(ctx, method) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// Any matches the rpcs matched by one of the matchers
+func Any(m ...Matcher) Matcher {
+	return Func(func(ctx context.Context, method string) bool {
+		for _, v := range m {
+			if v.Match(ctx, method) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Not matches the rpcs not matched by m
+func Not(m Matcher) Matcher {
+	return Func(func(ctx context.Context, method string) bool {
+		return !m.Match(ctx, method)
+	})
+}
@@ -0,0 +1,88 @@
+package match
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"go.linka.cloud/grpc/rpcctx"
+)
+
+func TestMethod(t *testing.T) {
+	const method = "/pkg.v1.Service/GetItem"
+	tests := []struct {
+		pattern string
+		match   bool
+	}{
+		{pattern: method, match: true},
+		{pattern: "/pkg.v1.Service/", match: true},
+		{pattern: "/pkg.v1.Service/Get*", match: true},
+		{pattern: "/pkg.v1.*/*", match: true},
+		{pattern: "*", match: true},
+		{pattern: "/pkg.v1.Service/List*", match: false},
+		{pattern: "/pkg.v1.Service", match: false},
+		{pattern: "/pkg.*", match: false},
+		{pattern: "/pkg.v1.Other/", match: false},
+		{pattern: "/pkg.v1.Service/[", match: false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.match, Method(tt.pattern, method), tt.pattern)
+	}
+	assert.True(t, AnyMethod([]string{"/other.Service/", "/pkg.v1.*/*"}, method))
+	assert.False(t, AnyMethod(nil, method))
+	assert.NoError(t, Validate("/pkg.v1.*/Get*"))
+	assert.Error(t, Validate("/pkg.v1.Service/["))
+}
+
+func TestSpecific(t *testing.T) {
+	assert.True(t, Specific("/pkg.v1.Service/Get*", "/pkg.v1.Service/"))
+	assert.False(t, Specific("*", "/pkg.v1.Service/"))
+	assert.True(t, Specific("/pkg.v1.A/", "/pkg.v1.B/"))
+	assert.False(t, Specific("/pkg.v1.B/", "/pkg.v1.A/"))
+
+	s, m := Split("/pkg.v1.Service/GetItem")
+	assert.Equal(t, "pkg.v1.Service", s)
+	assert.Equal(t, "GetItem", m)
+	s, m = Split("malformed")
+	assert.Equal(t, "unknown", s)
+	assert.Equal(t, "unknown", m)
+}
+
+func TestMatchers(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-tenant", "acme-prod"))
+	ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.1.2.3"), Port: 4242}})
+	ctx = rpcctx.WithAuthInfo(ctx, &rpcctx.AuthInfo{Certificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "api"}}}})
+
+	assert.True(t, Metadata("x-tenant", "").Match(ctx, ""))
+	assert.True(t, Metadata("x-tenant", "acme-*").Match(ctx, ""))
+	assert.False(t, Metadata("x-tenant", "other").Match(ctx, ""))
+	assert.False(t, Metadata("x-other", "").Match(ctx, ""))
+	assert.False(t, OutgoingMetadata("x-tenant", "").Match(ctx, ""))
+
+	internal, err := PeerNetworks("10.0.0.0/8", "192.168.0.0/16")
+	require.NoError(t, err)
+	assert.True(t, internal.Match(ctx, ""))
+	external, err := PeerNetworks("172.16.0.0/12")
+	require.NoError(t, err)
+	assert.False(t, external.Match(ctx, ""))
+	assert.False(t, internal.Match(context.Background(), ""))
+	_, err = PeerNetworks("10.0.0.0")
+	assert.Error(t, err)
+
+	assert.True(t, PeerIdentity("ap*").Match(ctx, ""))
+	assert.False(t, PeerIdentity("web").Match(ctx, ""))
+
+	m := All(Methods("/pkg.v1.Service/"), internal)
+	assert.True(t, m.Match(ctx, "/pkg.v1.Service/GetItem"))
+	assert.False(t, m.Match(ctx, "/pkg.v1.Other/GetItem"))
+	assert.True(t, Any(external, Methods("*")).Match(ctx, "/pkg.v1.Other/GetItem"))
+	assert.False(t, Any().Match(ctx, "/pkg.v1.Other/GetItem"))
+	assert.True(t, Not(external).Match(ctx, ""))
+}
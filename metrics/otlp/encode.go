@@ -0,0 +1,253 @@
+package otlp
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	scopeName = "go.linka.cloud/grpc/metrics/otlp"
+	// aggregationCumulative is the AGGREGATION_TEMPORALITY_CUMULATIVE enum value
+	aggregationCumulative = 2
+)
+
+type payload struct {
+	ResourceMetrics []resourceMetrics `json:"resourceMetrics"`
+}
+
+type resourceMetrics struct {
+	Resource     resource       `json:"resource"`
+	ScopeMetrics []scopeMetrics `json:"scopeMetrics"`
+}
+
+type resource struct {
+	Attributes []attribute `json:"attributes"`
+}
+
+type scopeMetrics struct {
+	Scope   scope    `json:"scope"`
+	Metrics []metric `json:"metrics"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+type attribute struct {
+	Key   string         `json:"key"`
+	Value attributeValue `json:"value"`
+}
+
+type attributeValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type metric struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Gauge       *gauge     `json:"gauge,omitempty"`
+	Sum         *sum       `json:"sum,omitempty"`
+	Histogram   *histogram `json:"histogram,omitempty"`
+	Summary     *summary   `json:"summary,omitempty"`
+}
+
+type gauge struct {
+	DataPoints []numberDataPoint `json:"dataPoints"`
+}
+
+type sum struct {
+	DataPoints             []numberDataPoint `json:"dataPoints"`
+	AggregationTemporality int               `json:"aggregationTemporality"`
+	IsMonotonic            bool              `json:"isMonotonic"`
+}
+
+type histogram struct {
+	DataPoints             []histogramDataPoint `json:"dataPoints"`
+	AggregationTemporality int                  `json:"aggregationTemporality"`
+}
+
+type summary struct {
+	DataPoints []summaryDataPoint `json:"dataPoints"`
+}
+
+type numberDataPoint struct {
+	Attributes        []attribute `json:"attributes,omitempty"`
+	StartTimeUnixNano string      `json:"startTimeUnixNano"`
+	TimeUnixNano      string      `json:"timeUnixNano"`
+	AsDouble          double      `json:"asDouble"`
+}
+
+type histogramDataPoint struct {
+	Attributes        []attribute `json:"attributes,omitempty"`
+	StartTimeUnixNano string      `json:"startTimeUnixNano"`
+	TimeUnixNano      string      `json:"timeUnixNano"`
+	Count             string      `json:"count"`
+	Sum               double      `json:"sum"`
+	BucketCounts      []string    `json:"bucketCounts"`
+	ExplicitBounds    []double    `json:"explicitBounds"`
+}
+
+type summaryDataPoint struct {
+	Attributes        []attribute     `json:"attributes,omitempty"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	Count             string          `json:"count"`
+	Sum               double          `json:"sum"`
+	QuantileValues    []quantileValue `json:"quantileValues"`
+}
+
+type quantileValue struct {
+	Quantile float64 `json:"quantile"`
+	Value    double  `json:"value"`
+}
+
+// double is a float64 using the protobuf json mapping, which encodes the non finite values as strings:
+// encoding/json cannot marshal them and would fail the whole export
+type double float64
+
+func (d double) MarshalJSON() ([]byte, error) {
+	switch f := float64(d); {
+	case math.IsNaN(f):
+		return []byte(`"NaN"`), nil
+	case math.IsInf(f, 1):
+		return []byte(`"Infinity"`), nil
+	case math.IsInf(f, -1):
+		return []byte(`"-Infinity"`), nil
+	default:
+		return json.Marshal(f)
+	}
+}
+
+func (d *double) UnmarshalJSON(b []byte) error {
+	switch string(b) {
+	case `"NaN"`:
+		*d = double(math.NaN())
+	case `"Infinity"`:
+		*d = double(math.Inf(1))
+	case `"-Infinity"`:
+		*d = double(math.Inf(-1))
+	default:
+		var f float64
+		if err := json.Unmarshal(b, &f); err != nil {
+			return err
+		}
+		*d = double(f)
+	}
+	return nil
+}
+
+// encode converts the prometheus metric families to an OTLP json export request
+func encode(res map[string]string, mfs []*dto.MetricFamily, start, now time.Time) ([]byte, error) {
+	st, ts := nanos(start), nanos(now)
+	var ms []metric
+	for _, mf := range mfs {
+		m := metric{Name: mf.GetName(), Description: mf.GetHelp()}
+		switch mf.GetType() {
+		case dto.MetricType_COUNTER:
+			m.Sum = &sum{AggregationTemporality: aggregationCumulative, IsMonotonic: true}
+			for _, v := range mf.GetMetric() {
+				m.Sum.DataPoints = append(m.Sum.DataPoints, numberDataPoint{
+					Attributes:        labels(v.GetLabel()),
+					StartTimeUnixNano: st,
+					TimeUnixNano:      ts,
+					AsDouble:          double(v.GetCounter().GetValue()),
+				})
+			}
+		case dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+			m.Gauge = &gauge{}
+			for _, v := range mf.GetMetric() {
+				value := v.GetGauge().GetValue()
+				if mf.GetType() == dto.MetricType_UNTYPED {
+					value = v.GetUntyped().GetValue()
+				}
+				m.Gauge.DataPoints = append(m.Gauge.DataPoints, numberDataPoint{
+					Attributes:        labels(v.GetLabel()),
+					StartTimeUnixNano: st,
+					TimeUnixNano:      ts,
+					AsDouble:          double(value),
+				})
+			}
+		case dto.MetricType_HISTOGRAM:
+			m.Histogram = &histogram{AggregationTemporality: aggregationCumulative}
+			for _, v := range mf.GetMetric() {
+				h := v.GetHistogram()
+				dp := histogramDataPoint{
+					Attributes:        labels(v.GetLabel()),
+					StartTimeUnixNano: st,
+					TimeUnixNano:      ts,
+					Count:             strconv.FormatUint(h.GetSampleCount(), 10),
+					Sum:               double(h.GetSampleSum()),
+				}
+				// prometheus buckets are cumulative, otlp ones are not
+				var prev uint64
+				for _, b := range h.GetBucket() {
+					if math.IsInf(b.GetUpperBound(), 1) {
+						continue
+					}
+					dp.ExplicitBounds = append(dp.ExplicitBounds, double(b.GetUpperBound()))
+					dp.BucketCounts = append(dp.BucketCounts, strconv.FormatUint(b.GetCumulativeCount()-prev, 10))
+					prev = b.GetCumulativeCount()
+				}
+				dp.BucketCounts = append(dp.BucketCounts, strconv.FormatUint(h.GetSampleCount()-prev, 10))
+				m.Histogram.DataPoints = append(m.Histogram.DataPoints, dp)
+			}
+		case dto.MetricType_SUMMARY:
+			m.Summary = &summary{}
+			for _, v := range mf.GetMetric() {
+				s := v.GetSummary()
+				dp := summaryDataPoint{
+					Attributes:        labels(v.GetLabel()),
+					StartTimeUnixNano: st,
+					TimeUnixNano:      ts,
+					Count:             strconv.FormatUint(s.GetSampleCount(), 10),
+					Sum:               double(s.GetSampleSum()),
+				}
+				for _, q := range s.GetQuantile() {
+					if math.IsNaN(q.GetValue()) {
+						continue
+					}
+					dp.QuantileValues = append(dp.QuantileValues, quantileValue{Quantile: q.GetQuantile(), Value: double(q.GetValue())})
+				}
+				m.Summary.DataPoints = append(m.Summary.DataPoints, dp)
+			}
+		default:
+			continue
+		}
+		ms = append(ms, m)
+	}
+	p := payload{ResourceMetrics: []resourceMetrics{{
+		Resource:     resource{Attributes: attributes(res)},
+		ScopeMetrics: []scopeMetrics{{Scope: scope{Name: scopeName}, Metrics: ms}},
+	}}}
+	return json.Marshal(p)
+}
+
+func labels(lps []*dto.LabelPair) []attribute {
+	var out []attribute
+	for _, v := range lps {
+		out = append(out, attribute{Key: v.GetName(), Value: attributeValue{StringValue: v.GetValue()}})
+	}
+	return out
+}
+
+func attributes(m map[string]string) []attribute {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]attribute, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, attribute{Key: k, Value: attributeValue{StringValue: m[k]}})
+	}
+	return out
+}
+
+func nanos(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
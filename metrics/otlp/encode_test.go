@@ -0,0 +1,82 @@
+package otlp
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncode(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	c := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "requests_total", Help: "requests"}, []string{"code"})
+	h := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "latency_seconds", Buckets: []float64{1, 2}})
+	reg.MustRegister(c, h)
+	c.WithLabelValues("OK").Add(3)
+	h.Observe(0.5)
+	h.Observe(1.5)
+	h.Observe(5)
+
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	b, err := encode(map[string]string{AttributeServiceName: "test"}, mfs, time.Unix(0, 0), time.Unix(1, 0))
+	require.NoError(t, err)
+
+	var p payload
+	require.NoError(t, json.Unmarshal(b, &p))
+	require.Len(t, p.ResourceMetrics, 1)
+	rm := p.ResourceMetrics[0]
+	assert.Equal(t, []attribute{{Key: AttributeServiceName, Value: attributeValue{StringValue: "test"}}}, rm.Resource.Attributes)
+	require.Len(t, rm.ScopeMetrics, 1)
+	ms := rm.ScopeMetrics[0].Metrics
+	require.Len(t, ms, 2)
+
+	// metric families are sorted by name
+	assert.Equal(t, "latency_seconds", ms[0].Name)
+	require.NotNil(t, ms[0].Histogram)
+	dp := ms[0].Histogram.DataPoints[0]
+	assert.Equal(t, "3", dp.Count)
+	assert.Equal(t, []double{1, 2}, dp.ExplicitBounds)
+	assert.Equal(t, []string{"1", "1", "1"}, dp.BucketCounts)
+
+	assert.Equal(t, "requests_total", ms[1].Name)
+	require.NotNil(t, ms[1].Sum)
+	assert.True(t, ms[1].Sum.IsMonotonic)
+	assert.Equal(t, double(3), ms[1].Sum.DataPoints[0].AsDouble)
+	assert.Equal(t, "1000000000", ms[1].Sum.DataPoints[0].TimeUnixNano)
+	assert.Equal(t, []attribute{{Key: "code", Value: attributeValue{StringValue: "OK"}}}, ms[1].Sum.DataPoints[0].Attributes)
+}
+
+func TestEncodeNonFinite(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	g := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "ratio"}, []string{"kind"})
+	reg.MustRegister(g)
+	g.WithLabelValues("nan").Set(math.NaN())
+	g.WithLabelValues("inf").Set(math.Inf(1))
+	g.WithLabelValues("-inf").Set(math.Inf(-1))
+	g.WithLabelValues("finite").Set(0.5)
+
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	// the non finite values do not fail the whole batch
+	b, err := encode(nil, mfs, time.Unix(0, 0), time.Unix(1, 0))
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"asDouble":"NaN"`)
+	assert.Contains(t, string(b), `"asDouble":"Infinity"`)
+	assert.Contains(t, string(b), `"asDouble":"-Infinity"`)
+
+	var p payload
+	require.NoError(t, json.Unmarshal(b, &p))
+	values := make(map[string]double)
+	for _, v := range p.ResourceMetrics[0].ScopeMetrics[0].Metrics[0].Gauge.DataPoints {
+		values[v.Attributes[0].Value.StringValue] = v.AsDouble
+	}
+	assert.True(t, math.IsNaN(float64(values["nan"])))
+	assert.True(t, math.IsInf(float64(values["inf"]), 1))
+	assert.True(t, math.IsInf(float64(values["-inf"]), -1))
+	assert.Equal(t, double(0.5), values["finite"])
+}
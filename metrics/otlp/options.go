@@ -0,0 +1,133 @@
+package otlp
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	defaultEndpoint = "http://localhost:4318/v1/metrics"
+	defaultInterval = 30 * time.Second
+	defaultTimeout  = 10 * time.Second
+)
+
+type Option func(o *options)
+
+// WithEndpoint sets the OTLP/HTTP metrics endpoint, e.g. http://collector:4318/v1/metrics.
+// It defaults to the OTEL_EXPORTER_OTLP_METRICS_ENDPOINT or OTEL_EXPORTER_OTLP_ENDPOINT environment variables
+func WithEndpoint(url string) Option {
+	return func(o *options) {
+		o.endpoint = url
+	}
+}
+
+// WithInterval sets the push interval
+func WithInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.interval = d
+	}
+}
+
+// WithTimeout sets the timeout of a single push
+func WithTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.timeout = d
+	}
+}
+
+// WithHeaders adds headers to the push requests, e.g. for authentication
+func WithHeaders(headers map[string]string) Option {
+	return func(o *options) {
+		for k, v := range headers {
+			o.headers[k] = v
+		}
+	}
+}
+
+// WithGatherer sets the gatherer the metrics are read from, defaults to prometheus.DefaultGatherer
+func WithGatherer(g prometheus.Gatherer) Option {
+	return func(o *options) {
+		o.gatherer = g
+	}
+}
+
+// WithHTTPClient sets the client used to push the metrics
+func WithHTTPClient(c *http.Client) Option {
+	return func(o *options) {
+		o.client = c
+	}
+}
+
+// WithResource adds resource attributes
+func WithResource(attrs map[string]string) Option {
+	return func(o *options) {
+		for k, v := range attrs {
+			o.resource[k] = v
+		}
+	}
+}
+
+// WithService sets the service.name, service.version and service.instance.id resource attributes
+func WithService(name, version, instanceID string) Option {
+	return func(o *options) {
+		o.resource[AttributeServiceName] = name
+		o.resource[AttributeServiceVersion] = version
+		o.resource[AttributeServiceInstanceID] = instanceID
+	}
+}
+
+// Config is the resolved configuration of the exporter
+type Config struct {
+	Endpoint string
+	Interval time.Duration
+	Timeout  time.Duration
+	Headers  map[string]string
+	Resource map[string]string
+}
+
+// Resolve returns the configuration of an exporter created with the options
+func Resolve(opts ...Option) Config {
+	o := newOptions()
+	for _, v := range opts {
+		v(o)
+	}
+	return Config{
+		Endpoint: o.endpoint,
+		Interval: o.interval,
+		Timeout:  o.timeout,
+		Headers:  o.headers,
+		Resource: o.resource,
+	}
+}
+
+type options struct {
+	endpoint string
+	interval time.Duration
+	timeout  time.Duration
+	headers  map[string]string
+	gatherer prometheus.Gatherer
+	client   *http.Client
+	resource map[string]string
+}
+
+func newOptions() *options {
+	o := &options{
+		endpoint: defaultEndpoint,
+		interval: defaultInterval,
+		timeout:  defaultTimeout,
+		headers:  make(map[string]string),
+		gatherer: prometheus.DefaultGatherer,
+		client:   http.DefaultClient,
+		resource: make(map[string]string),
+	}
+	if v := os.Getenv("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT"); v != "" {
+		o.endpoint = v
+	} else if v := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); v != "" {
+		o.endpoint = strings.TrimSuffix(v, "/") + "/v1/metrics"
+	}
+	return o
+}
@@ -0,0 +1,133 @@
+// Package otlp pushes prometheus metrics to an OpenTelemetry collector using the OTLP/HTTP json protocol
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.linka.cloud/grpc/logger"
+)
+
+const (
+	AttributeServiceName       = "service.name"
+	AttributeServiceVersion    = "service.version"
+	AttributeServiceInstanceID = "service.instance.id"
+)
+
+// Exporter periodically pushes the gathered metrics.
+// It implements the service.Module interface so that it can be started and stopped with the service.
+type Exporter interface {
+	Name() string
+	Start(ctx context.Context) error
+	// Stop stops the exporter after pushing the metrics a last time
+	Stop(ctx context.Context) error
+	// Push pushes the metrics immediately
+	Push(ctx context.Context) error
+}
+
+func NewExporter(opts ...Option) Exporter {
+	o := newOptions()
+	for _, v := range opts {
+		v(o)
+	}
+	return &exporter{o: o}
+}
+
+type exporter struct {
+	o      *options
+	start  time.Time
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (e *exporter) Name() string {
+	return "otlp-metrics"
+}
+
+func (e *exporter) Start(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.cancel != nil {
+		return nil
+	}
+	e.start = time.Now()
+	ctx, e.cancel = context.WithCancel(ctx)
+	e.done = make(chan struct{})
+	go e.run(ctx)
+	return nil
+}
+
+func (e *exporter) run(ctx context.Context) {
+	defer close(e.done)
+	t := time.NewTicker(e.o.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := e.Push(ctx); err != nil {
+				logger.C(ctx).Warnf("otlp: failed to push metrics: %v", err)
+			}
+		}
+	}
+}
+
+func (e *exporter) Stop(ctx context.Context) error {
+	e.mu.Lock()
+	if e.cancel == nil {
+		e.mu.Unlock()
+		return nil
+	}
+	e.cancel()
+	e.cancel = nil
+	done := e.done
+	e.mu.Unlock()
+	<-done
+	// flush so that short-lived processes do not lose their last metrics
+	return e.Push(ctx)
+}
+
+func (e *exporter) Push(ctx context.Context) error {
+	mfs, err := e.o.gatherer.Gather()
+	if err != nil {
+		return err
+	}
+	start := e.start
+	if start.IsZero() {
+		start = time.Now()
+	}
+	b, err := encode(e.o.resource, mfs, start, time.Now())
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, e.o.timeout)
+	defer cancel()
+	req, err := http.NewRequest(http.MethodPost, e.o.endpoint, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.o.headers {
+		req.Header.Set(k, v)
+	}
+	res, err := e.o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("otlp: unexpected status %s: %s", res.Status, string(msg))
+	}
+	io.Copy(ioutil.Discard, res.Body)
+	return nil
+}
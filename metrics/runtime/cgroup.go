@@ -0,0 +1,134 @@
+package runtime
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// unlimited is the threshold above which a cgroup v1 memory limit means no limit
+const unlimited = 1 << 62
+
+// cgroup reads the container resources from the cgroup v1 or v2 filesystem,
+// the values which cannot be read, e.g. outside of a container, are not exported
+type cgroup struct {
+	root string
+	v2   bool
+
+	cpuLimit    *prometheus.Desc
+	cpuUsage    *prometheus.Desc
+	memoryLimit *prometheus.Desc
+	memoryUsage *prometheus.Desc
+}
+
+func newCgroup(root string, desc func(name, help string) *prometheus.Desc) *cgroup {
+	_, err := os.Stat(filepath.Join(root, "cgroup.controllers"))
+	return &cgroup{
+		root:        root,
+		v2:          err == nil,
+		cpuLimit:    desc("cgroup_cpu_limit_cores", "CPU limit of the container in cores."),
+		cpuUsage:    desc("cgroup_cpu_usage_seconds_total", "Total CPU time consumed by the container."),
+		memoryLimit: desc("cgroup_memory_limit_bytes", "Memory limit of the container."),
+		memoryUsage: desc("cgroup_memory_usage_bytes", "Memory usage of the container."),
+	}
+}
+
+func (c *cgroup) describe(descs chan<- *prometheus.Desc) {
+	descs <- c.cpuLimit
+	descs <- c.cpuUsage
+	descs <- c.memoryLimit
+	descs <- c.memoryUsage
+}
+
+func (c *cgroup) collect(ch chan<- prometheus.Metric) {
+	send := func(d *prometheus.Desc, t prometheus.ValueType, v float64, ok bool) {
+		if ok {
+			ch <- prometheus.MustNewConstMetric(d, t, v)
+		}
+	}
+	v, ok := c.cpuLimitCores()
+	send(c.cpuLimit, prometheus.GaugeValue, v, ok)
+	v, ok = c.cpuUsageSeconds()
+	send(c.cpuUsage, prometheus.CounterValue, v, ok)
+	v, ok = c.memoryLimitBytes()
+	send(c.memoryLimit, prometheus.GaugeValue, v, ok)
+	v, ok = c.memoryUsageBytes()
+	send(c.memoryUsage, prometheus.GaugeValue, v, ok)
+}
+
+func (c *cgroup) cpuLimitCores() (float64, bool) {
+	if c.v2 {
+		// cpu.max: "$MAX $PERIOD" or "max $PERIOD"
+		f := strings.Fields(c.read("cpu.max"))
+		if len(f) != 2 || f[0] == "max" {
+			return 0, false
+		}
+		return ratio(f[0], f[1])
+	}
+	quota := c.read("cpu", "cpu.cfs_quota_us")
+	if quota == "" || strings.HasPrefix(quota, "-") {
+		return 0, false
+	}
+	return ratio(quota, c.read("cpu", "cpu.cfs_period_us"))
+}
+
+func (c *cgroup) cpuUsageSeconds() (float64, bool) {
+	if c.v2 {
+		for _, l := range strings.Split(c.read("cpu.stat"), "\n") {
+			if f := strings.Fields(l); len(f) == 2 && f[0] == "usage_usec" {
+				v, ok := parse(f[1])
+				return v / 1e6, ok
+			}
+		}
+		return 0, false
+	}
+	v, ok := parse(c.read("cpuacct", "cpuacct.usage"))
+	return v / 1e9, ok
+}
+
+func (c *cgroup) memoryLimitBytes() (float64, bool) {
+	var v float64
+	var ok bool
+	if c.v2 {
+		v, ok = parse(c.read("memory.max"))
+	} else {
+		v, ok = parse(c.read("memory", "memory.limit_in_bytes"))
+	}
+	return v, ok && v < unlimited
+}
+
+func (c *cgroup) memoryUsageBytes() (float64, bool) {
+	if c.v2 {
+		return parse(c.read("memory.current"))
+	}
+	return parse(c.read("memory", "memory.usage_in_bytes"))
+}
+
+func (c *cgroup) read(path ...string) string {
+	b, err := ioutil.ReadFile(filepath.Join(append([]string{c.root}, path...)...))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func parse(s string) (float64, bool) {
+	v, err := strconv.ParseFloat(s, 64)
+	return v, err == nil
+}
+
+func ratio(a, b string) (float64, bool) {
+	x, ok := parse(a)
+	if !ok {
+		return 0, false
+	}
+	y, ok := parse(b)
+	if !ok || y == 0 {
+		return 0, false
+	}
+	return x / y, true
+}
@@ -0,0 +1,52 @@
+package runtime
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const defaultCgroupRoot = "/sys/fs/cgroup"
+
+type Option func(o *options)
+
+// WithNamespace sets the metrics namespace, e.g. the service one, the metrics are named {namespace}_runtime_*
+func WithNamespace(ns string) Option {
+	return func(o *options) {
+		o.namespace = ns
+	}
+}
+
+// WithConstLabels adds constant labels to the metrics, e.g. the service name and instance
+func WithConstLabels(labels prometheus.Labels) Option {
+	return func(o *options) {
+		o.labels = labels
+	}
+}
+
+// WithCgroup exports the cgroup (v1 or v2) CPU and memory limits and usage of the container
+func WithCgroup() Option {
+	return func(o *options) {
+		o.cgroup = true
+	}
+}
+
+// WithCgroupRoot sets the path the cgroup filesystem of the container is mounted on, defaults to /sys/fs/cgroup
+func WithCgroupRoot(path string) Option {
+	return func(o *options) {
+		o.cgroupRoot = path
+	}
+}
+
+type options struct {
+	namespace  string
+	labels     prometheus.Labels
+	cgroup     bool
+	cgroupRoot string
+}
+
+func newOptions(opts ...Option) options {
+	o := options{cgroupRoot: defaultCgroupRoot}
+	for _, v := range opts {
+		v(&o)
+	}
+	return o
+}
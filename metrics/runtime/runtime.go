@@ -0,0 +1,107 @@
+// Package runtime exports the Go runtime metrics and the container cgroup resources usage
+// under a custom namespace, so that they can be exposed per service without node exporters.
+package runtime
+
+import (
+	"runtime"
+	"runtime/debug"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const subsystem = "runtime"
+
+// New returns a collector of the Go runtime metrics, and of the cgroup ones if enabled
+func New(opts ...Option) prometheus.Collector {
+	o := newOptions(opts...)
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(o.namespace, subsystem, name), help, nil, o.labels)
+	}
+	c := &collector{
+		opts:         o,
+		goroutines:   desc("goroutines", "Number of goroutines that currently exist."),
+		gomaxprocs:   desc("gomaxprocs", "Number of operating system threads that can execute Go code simultaneously."),
+		gcCycles:     desc("gc_cycles_total", "Number of completed GC cycles."),
+		gcPause:      desc("gc_pause_seconds_total", "Total GC stop-the-world pause duration."),
+		gcLastPause:  desc("gc_last_pause_seconds", "Duration of the last GC stop-the-world pause."),
+		heapAlloc:    desc("heap_alloc_bytes", "Number of heap bytes allocated and still in use."),
+		heapInuse:    desc("heap_inuse_bytes", "Number of heap bytes in in-use spans."),
+		heapObjects:  desc("heap_objects", "Number of allocated heap objects."),
+		heapReleased: desc("heap_released_bytes", "Number of heap bytes released to the operating system."),
+		stackInuse:   desc("stack_inuse_bytes", "Number of bytes in stack spans."),
+		sys:          desc("sys_bytes", "Number of bytes obtained from the operating system."),
+		allocs:       desc("alloc_bytes_total", "Total number of bytes allocated, even if freed."),
+		nextGC:       desc("next_gc_bytes", "Heap size target of the next GC cycle."),
+	}
+	if o.cgroup {
+		c.cgroup = newCgroup(o.cgroupRoot, desc)
+	}
+	return c
+}
+
+type collector struct {
+	opts   options
+	cgroup *cgroup
+
+	goroutines   *prometheus.Desc
+	gomaxprocs   *prometheus.Desc
+	gcCycles     *prometheus.Desc
+	gcPause      *prometheus.Desc
+	gcLastPause  *prometheus.Desc
+	heapAlloc    *prometheus.Desc
+	heapInuse    *prometheus.Desc
+	heapObjects  *prometheus.Desc
+	heapReleased *prometheus.Desc
+	stackInuse   *prometheus.Desc
+	sys          *prometheus.Desc
+	allocs       *prometheus.Desc
+	nextGC       *prometheus.Desc
+}
+
+func (c *collector) Describe(descs chan<- *prometheus.Desc) {
+	for _, v := range []*prometheus.Desc{
+		c.goroutines, c.gomaxprocs, c.gcCycles, c.gcPause, c.gcLastPause, c.heapAlloc, c.heapInuse,
+		c.heapObjects, c.heapReleased, c.stackInuse, c.sys, c.allocs, c.nextGC,
+	} {
+		descs <- v
+	}
+	if c.cgroup != nil {
+		c.cgroup.describe(descs)
+	}
+}
+
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	gauge := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, v)
+	}
+	counter := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, v)
+	}
+	gauge(c.goroutines, float64(runtime.NumGoroutine()))
+	gauge(c.gomaxprocs, float64(runtime.GOMAXPROCS(0)))
+
+	var gc debug.GCStats
+	debug.ReadGCStats(&gc)
+	counter(c.gcCycles, float64(gc.NumGC))
+	counter(c.gcPause, gc.PauseTotal.Seconds())
+	var last float64
+	if len(gc.Pause) != 0 {
+		last = gc.Pause[0].Seconds()
+	}
+	gauge(c.gcLastPause, last)
+
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	gauge(c.heapAlloc, float64(ms.HeapAlloc))
+	gauge(c.heapInuse, float64(ms.HeapInuse))
+	gauge(c.heapObjects, float64(ms.HeapObjects))
+	gauge(c.heapReleased, float64(ms.HeapReleased))
+	gauge(c.stackInuse, float64(ms.StackInuse))
+	gauge(c.sys, float64(ms.Sys))
+	counter(c.allocs, float64(ms.TotalAlloc))
+	gauge(c.nextGC, float64(ms.NextGC))
+
+	if c.cgroup != nil {
+		c.cgroup.collect(ch)
+	}
+}
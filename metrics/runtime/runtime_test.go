@@ -0,0 +1,93 @@
+package runtime
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	for k, v := range files {
+		p := filepath.Join(root, k)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, ioutil.WriteFile(p, []byte(v+"\n"), 0644))
+	}
+}
+
+func TestCgroup(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+	}{
+		{
+			name: "v2",
+			files: map[string]string{
+				"cgroup.controllers": "cpu memory",
+				"cpu.max":            "150000 100000",
+				"cpu.stat":           "usage_usec 2500000\nuser_usec 2000000",
+				"memory.max":         "536870912",
+				"memory.current":     "104857600",
+			},
+		},
+		{
+			name: "v1",
+			files: map[string]string{
+				"cpu/cpu.cfs_quota_us":         "150000",
+				"cpu/cpu.cfs_period_us":        "100000",
+				"cpuacct/cpuacct.usage":        "2500000000",
+				"memory/memory.limit_in_bytes": "536870912",
+				"memory/memory.usage_in_bytes": "104857600",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, err := ioutil.TempDir("", "cgroup")
+			require.NoError(t, err)
+			defer os.RemoveAll(root)
+			writeFiles(t, root, tt.files)
+			c := newCgroup(root, func(name, help string) *prometheus.Desc {
+				return prometheus.NewDesc(name, help, nil, nil)
+			})
+			v, ok := c.cpuLimitCores()
+			assert.True(t, ok)
+			assert.Equal(t, 1.5, v)
+			v, ok = c.cpuUsageSeconds()
+			assert.True(t, ok)
+			assert.Equal(t, 2.5, v)
+			v, ok = c.memoryLimitBytes()
+			assert.True(t, ok)
+			assert.Equal(t, float64(512<<20), v)
+			v, ok = c.memoryUsageBytes()
+			assert.True(t, ok)
+			assert.Equal(t, float64(100<<20), v)
+		})
+	}
+}
+
+func TestCollector(t *testing.T) {
+	root, err := ioutil.TempDir("", "cgroup")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+	// no limits
+	writeFiles(t, root, map[string]string{"cgroup.controllers": "cpu memory", "cpu.max": "max 100000", "memory.max": "max"})
+
+	c := New(WithNamespace("grpc_service"), WithConstLabels(prometheus.Labels{"service": "test"}), WithCgroup(), WithCgroupRoot(root))
+	r := prometheus.NewPedanticRegistry()
+	require.NoError(t, r.Register(c))
+	mfs, err := r.Gather()
+	require.NoError(t, err)
+	names := make(map[string]bool)
+	for _, v := range mfs {
+		names[v.GetName()] = true
+	}
+	assert.True(t, names["grpc_service_runtime_goroutines"])
+	assert.True(t, names["grpc_service_runtime_heap_alloc_bytes"])
+	assert.False(t, names["grpc_service_runtime_cgroup_cpu_limit_cores"])
+	assert.False(t, names["grpc_service_runtime_cgroup_memory_limit_bytes"])
+}
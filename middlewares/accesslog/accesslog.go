@@ -0,0 +1,144 @@
+// Package accesslog provides an http access log middleware for the gateway, grpc-web and static routes
+package accesslog
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"go.linka.cloud/grpc/logger"
+	"go.linka.cloud/grpc/utils/forwarded"
+)
+
+// New returns the access log middleware, it can be used with service.WithMiddlewares
+func New(opts ...Option) func(http.Handler) http.Handler {
+	o := newOptions()
+	for _, v := range opts {
+		v(o)
+	}
+	var mu sync.Mutex
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := o.skip[r.URL.Path]; ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			id := r.Header.Get(o.requestIDHeader)
+			if id == "" {
+				id = uuid.New().String()
+				r.Header.Set(o.requestIDHeader, id)
+			}
+			w.Header().Set(o.requestIDHeader, id)
+			rw := &responseWriter{ResponseWriter: w}
+			start := time.Now()
+			next.ServeHTTP(rw, r)
+			d := time.Since(start)
+			status := rw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if status < http.StatusInternalServerError && o.rate < 1 && rand.Float64() >= o.rate {
+				return
+			}
+			if o.clf != nil {
+				mu.Lock()
+				fmt.Fprintln(o.clf, commonLogFormat(r, status, rw.bytes, start))
+				mu.Unlock()
+				return
+			}
+			log := o.logger
+			if log == nil {
+				log = logger.C(r.Context())
+			}
+			log = log.WithFields(
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", status,
+				"duration", d,
+				"bytes", rw.bytes,
+				"request_id", id,
+				"remote_ip", RemoteIP(r),
+			)
+			if status >= http.StatusInternalServerError {
+				log.Warn("http request")
+				return
+			}
+			log.Info("http request")
+		})
+	}
+}
+
+// RemoteIP returns the client ip, the forwarded headers are only honored for the trusted proxies
+// configured with the forwarded middleware, e.g. with service.WithTrustedProxies
+func RemoteIP(r *http.Request) string {
+	return forwarded.ClientIP(r)
+}
+
+// commonLogFormat formats the request as: host ident authuser [date] "request" status bytes
+func commonLogFormat(r *http.Request, status int, bytes int64, t time.Time) string {
+	user := "-"
+	if r.URL.User != nil {
+		if v := r.URL.User.Username(); v != "" {
+			user = v
+		}
+	} else if v, _, ok := r.BasicAuth(); ok && v != "" {
+		user = v
+	}
+	return fmt.Sprintf(`%s - %s [%s] "%s %s %s" %d %d`,
+		RemoteIP(r),
+		user,
+		t.Format("02/Jan/2006:15:04:05 -0700"),
+		r.Method,
+		r.RequestURI,
+		r.Proto,
+		status,
+		bytes,
+	)
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack is required by the websocket proxies
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("accesslog: response writer does not implement http.Hijacker")
+	}
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
@@ -0,0 +1,76 @@
+package accesslog
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.linka.cloud/grpc/utils/forwarded"
+)
+
+func TestCommonLogFormat(t *testing.T) {
+	var buf bytes.Buffer
+	h := New(WithCommonLogFormat(&buf))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("hello"))
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/a?b=c", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.NotEmpty(t, rec.Header().Get(DefaultRequestIDHeader))
+	line := buf.String()
+	require.NotEmpty(t, line)
+	assert.Contains(t, line, "10.0.0.1 - - [")
+	assert.Contains(t, line, `"GET /a?b=c HTTP/1.1" 418 5`)
+}
+
+func TestSampling(t *testing.T) {
+	var buf bytes.Buffer
+	h := New(WithCommonLogFormat(&buf), WithSampleRate(0))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/error" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Empty(t, buf.String())
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/error", nil))
+	assert.Contains(t, buf.String(), " 500 0")
+}
+
+func TestRemoteIP(t *testing.T) {
+	trust, err := forwarded.New("192.0.2.0/24", "10.0.0.0/8")
+	require.NoError(t, err)
+	tests := []struct {
+		name    string
+		header  http.Header
+		trusted bool
+		want    string
+	}{
+		{name: "remote addr", want: "192.0.2.1"},
+		{name: "untrusted", header: http.Header{"X-Forwarded-For": {"10.0.0.3"}}, want: "192.0.2.1"},
+		{name: "forwarded", header: http.Header{"Forwarded": {`for="[2001:db8::1]:4711";proto=https, for=10.0.0.2`}}, trusted: true, want: "2001:db8::1"},
+		{name: "x-forwarded-for", header: http.Header{"X-Forwarded-For": {"10.0.0.3, 10.0.0.4"}}, trusted: true, want: "10.0.0.3"},
+		{name: "x-real-ip", header: http.Header{"X-Real-Ip": {"10.0.0.5"}}, trusted: true, want: "10.0.0.5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range tt.header {
+				r.Header[k] = v
+			}
+			if !tt.trusted {
+				assert.Equal(t, tt.want, RemoteIP(r))
+				return
+			}
+			trust.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, tt.want, RemoteIP(r))
+			})).ServeHTTP(httptest.NewRecorder(), r)
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package accesslog
+
+import (
+	"io"
+
+	"go.linka.cloud/grpc/logger"
+)
+
+const DefaultRequestIDHeader = "X-Request-Id"
+
+type Option func(o *options)
+
+// WithLogger sets the logger used for the structured access logs, defaults to the context logger
+func WithLogger(l logger.Logger) Option {
+	return func(o *options) {
+		o.logger = l
+	}
+}
+
+// WithSampleRate logs only the given ratio (between 0 and 1) of the successful requests.
+// Server errors are always logged.
+func WithSampleRate(rate float64) Option {
+	return func(o *options) {
+		o.rate = rate
+	}
+}
+
+// WithCommonLogFormat writes the access logs to w using the Common Log Format instead of the structured logger
+func WithCommonLogFormat(w io.Writer) Option {
+	return func(o *options) {
+		o.clf = w
+	}
+}
+
+// WithRequestIDHeader sets the header used to read or generate the request id, defaults to X-Request-Id
+func WithRequestIDHeader(name string) Option {
+	return func(o *options) {
+		o.requestIDHeader = name
+	}
+}
+
+// WithSkipPaths disables the logging of the given paths, e.g. health checks
+func WithSkipPaths(paths ...string) Option {
+	return func(o *options) {
+		for _, v := range paths {
+			o.skip[v] = struct{}{}
+		}
+	}
+}
+
+type options struct {
+	logger          logger.Logger
+	rate            float64
+	clf             io.Writer
+	requestIDHeader string
+	skip            map[string]struct{}
+}
+
+func newOptions() *options {
+	return &options{
+		rate:            1,
+		requestIDHeader: DefaultRequestIDHeader,
+		skip:            make(map[string]struct{}),
+	}
+}
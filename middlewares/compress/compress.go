@@ -0,0 +1,227 @@
+// Package compress provides an http response compression middleware for the gateway and the static routes
+package compress
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// New returns the compression middleware, it can be used with service.WithMiddlewares.
+// The responses are compressed with the preferred encoding accepted by the client when their content type
+// is compressible and their size reaches the minimum size.
+func New(opts ...Option) func(http.Handler) http.Handler {
+	o := newOptions(opts...)
+	pool := &sync.Pool{}
+	encoders := append(o.encoders, encoder{name: "gzip", fn: func(w io.Writer) (io.WriteCloser, error) {
+		if v, ok := pool.Get().(*gzip.Writer); ok {
+			v.Reset(w)
+			return &pooled{Writer: v, pool: pool}, nil
+		}
+		v, err := gzip.NewWriterLevel(w, o.level)
+		if err != nil {
+			return nil, err
+		}
+		return &pooled{Writer: v, pool: pool}, nil
+	}})
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			// websockets, range and head requests are left untouched
+			if r.Method == http.MethodHead || r.Header.Get("Range") != "" || r.Header.Get("Upgrade") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			enc, ok := negotiate(r.Header.Get("Accept-Encoding"), encoders)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			cw := &responseWriter{ResponseWriter: w, o: o, enc: enc}
+			defer cw.close()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// pooled returns the gzip writer to the pool once closed
+type pooled struct {
+	*gzip.Writer
+	pool *sync.Pool
+}
+
+func (p *pooled) Close() error {
+	err := p.Writer.Close()
+	p.pool.Put(p.Writer)
+	return err
+}
+
+// negotiate returns the first encoder accepted by the client
+func negotiate(header string, encoders []encoder) (encoder, bool) {
+	if header == "" {
+		return encoder{}, false
+	}
+	accepted := make(map[string]bool)
+	wildcard := false
+	for _, v := range strings.Split(header, ",") {
+		parts := strings.Split(v, ";")
+		name := strings.ToLower(strings.TrimSpace(parts[0]))
+		q := 1.0
+		for _, p := range parts[1:] {
+			p = strings.TrimSpace(p)
+			if strings.HasPrefix(p, "q=") {
+				if f, err := strconv.ParseFloat(p[2:], 64); err == nil {
+					q = f
+				}
+			}
+		}
+		if name == "*" {
+			wildcard = q > 0
+			continue
+		}
+		accepted[name] = q > 0
+	}
+	for _, v := range encoders {
+		if ok, found := accepted[v.name]; ok || (!found && wildcard) {
+			return v, true
+		}
+	}
+	return encoder{}, false
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+	o      *options
+	enc    encoder
+	status int
+	// buf holds the response until its size or a flush decides whether it is compressed
+	buf     []byte
+	decided bool
+	w       io.WriteCloser
+	err     error
+}
+
+func (w *responseWriter) compressible() bool {
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	switch w.status {
+	case http.StatusNoContent, http.StatusNotModified, http.StatusPartialContent:
+		return false
+	}
+	ct, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		ct = http.DetectContentType(w.buf)
+		ct, _, _ = mime.ParseMediaType(ct)
+	}
+	if strings.HasPrefix(ct, "text/") {
+		return true
+	}
+	for _, v := range w.o.contentTypes {
+		if v == ct {
+			return true
+		}
+	}
+	return false
+}
+
+// decide starts the compressed or the plain response, compress is false when the response is too small
+func (w *responseWriter) decide(compress bool) {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if compress && w.compressible() {
+		if enc, err := w.enc.fn(w.ResponseWriter); err == nil {
+			h := w.Header()
+			h.Set("Content-Encoding", w.enc.name)
+			h.Del("Content-Length")
+			h.Del("Accept-Ranges")
+			if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+				h.Set("ETag", "W/"+etag)
+			}
+			w.w = enc
+		}
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	if len(w.buf) == 0 {
+		return
+	}
+	b := w.buf
+	w.buf = nil
+	_, w.err = w.write(b)
+}
+
+func (w *responseWriter) write(b []byte) (int, error) {
+	if w.w != nil {
+		return w.w.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.decided {
+		return w.write(b)
+	}
+	w.buf = append(w.buf, b...)
+	if len(w.buf) >= w.o.minSize {
+		w.decide(true)
+		if w.err != nil {
+			return 0, w.err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush sends the buffered response, compressed as the streamed responses are usually compressible
+func (w *responseWriter) Flush() {
+	w.decide(true)
+	if f, ok := w.w.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijack not supported")
+	}
+	w.decided = true
+	return h.Hijack()
+}
+
+func (w *responseWriter) close() {
+	if !w.decided {
+		// nothing written or too small to be worth it
+		if w.status == 0 && len(w.buf) == 0 {
+			return
+		}
+		w.decide(false)
+	}
+	if w.w != nil {
+		w.w.Close()
+	}
+}
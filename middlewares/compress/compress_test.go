@@ -0,0 +1,94 @@
+package compress
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiate(t *testing.T) {
+	encs := []encoder{{name: "zstd"}, {name: "gzip"}}
+	for h, want := range map[string]string{
+		"":                    "",
+		"gzip":                "gzip",
+		"gzip, zstd":          "zstd",
+		"zstd;q=0, gzip;q=.5": "gzip",
+		"*":                   "zstd",
+		"*, zstd;q=0":         "gzip",
+		"br":                  "",
+	} {
+		e, _ := negotiate(h, encs)
+		assert.Equal(t, want, e.name, h)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	body := strings.Repeat(`{"key": "value"}`, 100)
+	h := New(WithMinSize(100))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", r.URL.Query().Get("type"))
+		n := len(body)
+		if r.URL.Query().Get("small") != "" {
+			n = 10
+		}
+		io.WriteString(w, body[:n])
+	}))
+	do := func(target, accept string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		if accept != "" {
+			r.Header.Set("Accept-Encoding", accept)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := do("/?type=application/json", "gzip")
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	gr, err := gzip.NewReader(w.Body)
+	require.NoError(t, err)
+	b, err := ioutil.ReadAll(gr)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(b))
+
+	for _, v := range []struct{ target, accept string }{
+		{"/?type=application/json", ""},
+		{"/?type=application/json&small=1", "gzip"},
+		{"/?type=image/png", "gzip"},
+	} {
+		w = do(v.target, v.accept)
+		assert.Empty(t, w.Header().Get("Content-Encoding"), v.target)
+		assert.True(t, strings.HasPrefix(body, w.Body.String()), v.target)
+	}
+
+	w = do("/?type=text/plain;charset=utf-8", "deflate, gzip")
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+}
+
+func TestFlush(t *testing.T) {
+	h := New()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		io.WriteString(w, `{}`)
+		w.(http.Flusher).Flush()
+		io.WriteString(w, `{}`)
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	gr, err := gzip.NewReader(w.Body)
+	require.NoError(t, err)
+	b, err := ioutil.ReadAll(gr)
+	require.NoError(t, err)
+	assert.Equal(t, `{}{}`, string(b))
+}
@@ -0,0 +1,71 @@
+package compress
+
+import (
+	"compress/gzip"
+	"io"
+)
+
+const defaultMinSize = 1024
+
+// DefaultContentTypes are the compressed content types, the text/ types are always compressed
+var DefaultContentTypes = []string{
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"application/x-ndjson",
+	"image/svg+xml",
+}
+
+// EncoderFunc returns a compressing writer, e.g. a zstd encoder
+type EncoderFunc func(w io.Writer) (io.WriteCloser, error)
+
+type Option func(o *options)
+
+// WithMinSize sets the minimum response size to compress, defaults to 1024 bytes
+func WithMinSize(n int) Option {
+	return func(o *options) {
+		o.minSize = n
+	}
+}
+
+// WithContentTypes sets the compressed content types, in addition to the text/ ones, defaults to DefaultContentTypes
+func WithContentTypes(types ...string) Option {
+	return func(o *options) {
+		o.contentTypes = types
+	}
+}
+
+// WithGzipLevel sets the gzip compression level, defaults to gzip.DefaultCompression
+func WithGzipLevel(level int) Option {
+	return func(o *options) {
+		o.level = level
+	}
+}
+
+// WithEncoder registers an encoding, e.g. zstd, preferred over the previously registered ones and gzip
+// when the client accepts it
+func WithEncoder(name string, fn EncoderFunc) Option {
+	return func(o *options) {
+		o.encoders = append([]encoder{{name: name, fn: fn}}, o.encoders...)
+	}
+}
+
+type encoder struct {
+	name string
+	fn   EncoderFunc
+}
+
+type options struct {
+	minSize      int
+	contentTypes []string
+	level        int
+	encoders     []encoder
+}
+
+func newOptions(opts ...Option) *options {
+	o := &options{minSize: defaultMinSize, contentTypes: DefaultContentTypes, level: gzip.DefaultCompression}
+	for _, v := range opts {
+		v(o)
+	}
+	return o
+}
@@ -0,0 +1,246 @@
+// Package mock serves mock implementations of services from their descriptors, returning canned or scripted
+// responses, so that the consumers of a service can be tested, or developed locally, without it:
+//
+//	sd, _ := mock.Lookup("greeter.Greeter")
+//	m, _ := mock.Register(svc, sd,
+//		mock.WithJSONResponses("SayHello", `{"message": "hello"}`),
+//		mock.WithError("SayGoodbye", errors.Unavailablef("down")),
+//	)
+//	...
+//	assert.Len(t, m.Calls("SayHello"), 1)
+//
+// The methods without responses return an empty response, or Unimplemented in strict mode. The handlers go
+// through the service interceptors.
+package mock
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"go.linka.cloud/grpc/errors"
+)
+
+// Lookup returns the descriptor of the service name, e.g. "greeter.Greeter", from the registered files
+func Lookup(name string) (protoreflect.ServiceDescriptor, error) {
+	d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, fmt.Errorf("mock: %s: %w", name, err)
+	}
+	sd, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("mock: %s is not a service", name)
+	}
+	return sd, nil
+}
+
+// Mock is a mocked service, it records the requests it receives
+type Mock struct {
+	sd    protoreflect.ServiceDescriptor
+	opts  options
+	mu    sync.Mutex
+	calls map[string][]proto.Message
+	next  map[string]int
+}
+
+// Register registers the mock of the service described by sd on s
+func Register(s grpc.ServiceRegistrar, sd protoreflect.ServiceDescriptor, opts ...Option) (*Mock, error) {
+	m, err := New(sd, opts...)
+	if err != nil {
+		return nil, err
+	}
+	s.RegisterService(m.ServiceDesc(), m)
+	return m, nil
+}
+
+// New returns the mock of the service described by sd, see Register
+func New(sd protoreflect.ServiceDescriptor, opts ...Option) (*Mock, error) {
+	m := &Mock{sd: sd, opts: newOptions(opts...), calls: make(map[string][]proto.Message), next: make(map[string]int)}
+	for name, v := range m.opts.methods {
+		md := sd.Methods().ByName(protoreflect.Name(name))
+		if md == nil {
+			return nil, fmt.Errorf("mock: %s has no method %s", sd.FullName(), name)
+		}
+		out := messageType(md.Output())
+		for _, r := range v.responses {
+			if r.ProtoReflect().Descriptor().FullName() != md.Output().FullName() {
+				return nil, fmt.Errorf("mock: %s: invalid response type %s", md.FullName(), r.ProtoReflect().Descriptor().FullName())
+			}
+		}
+		for _, s := range v.json {
+			r := out.New().Interface()
+			if err := protojson.Unmarshal([]byte(s), r); err != nil {
+				return nil, fmt.Errorf("mock: %s: %w", md.FullName(), err)
+			}
+			v.responses = append(v.responses, r)
+		}
+	}
+	return m, nil
+}
+
+// Calls returns the requests received by method, e.g. "SayHello"
+func (m *Mock) Calls(method string) []proto.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]proto.Message(nil), m.calls[method]...)
+}
+
+// Reset clears the recorded calls and restarts the responses sequences
+func (m *Mock) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = make(map[string][]proto.Message)
+	m.next = make(map[string]int)
+}
+
+// ServiceDesc returns the grpc service description of the mock
+func (m *Mock) ServiceDesc() *grpc.ServiceDesc {
+	desc := &grpc.ServiceDesc{
+		ServiceName: string(m.sd.FullName()),
+		HandlerType: (*interface{})(nil),
+		Metadata:    m.sd.ParentFile().Path(),
+	}
+	methods := m.sd.Methods()
+	for i := 0; i < methods.Len(); i++ {
+		md := methods.Get(i)
+		if md.IsStreamingClient() || md.IsStreamingServer() {
+			desc.Streams = append(desc.Streams, m.streamDesc(md))
+			continue
+		}
+		desc.Methods = append(desc.Methods, m.methodDesc(md))
+	}
+	return desc
+}
+
+func (m *Mock) methodDesc(md protoreflect.MethodDescriptor) grpc.MethodDesc {
+	in := messageType(md.Input())
+	method := fmt.Sprintf("/%s/%s", m.sd.FullName(), md.Name())
+	return grpc.MethodDesc{
+		MethodName: string(md.Name()),
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := in.New().Interface()
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			call := func(ctx context.Context, req interface{}) (interface{}, error) {
+				m.record(md, req.(proto.Message))
+				return m.respond(ctx, md, req.(proto.Message))
+			}
+			if interceptor == nil {
+				return call(ctx, req)
+			}
+			return interceptor(ctx, req, &grpc.UnaryServerInfo{Server: srv, FullMethod: method}, call)
+		},
+	}
+}
+
+func (m *Mock) streamDesc(md protoreflect.MethodDescriptor) grpc.StreamDesc {
+	in := messageType(md.Input())
+	return grpc.StreamDesc{
+		StreamName:    string(md.Name()),
+		ClientStreams: md.IsStreamingClient(),
+		ServerStreams: md.IsStreamingServer(),
+		Handler: func(srv interface{}, ss grpc.ServerStream) error {
+			ctx := ss.Context()
+			var req proto.Message
+			for {
+				r := in.New().Interface()
+				if err := ss.RecvMsg(r); err == io.EOF {
+					break
+				} else if err != nil {
+					return err
+				}
+				req = r
+				m.record(md, req)
+				if !md.IsStreamingClient() {
+					break
+				}
+				// bidirectional streams reply to each message
+				if md.IsStreamingServer() {
+					res, err := m.respond(ctx, md, req)
+					if err != nil {
+						return err
+					}
+					if err := ss.SendMsg(res); err != nil {
+						return err
+					}
+				}
+			}
+			switch {
+			case md.IsStreamingClient() && md.IsStreamingServer():
+				return nil
+			case md.IsStreamingClient():
+				res, err := m.respond(ctx, md, req)
+				if err != nil {
+					return err
+				}
+				return ss.SendMsg(res)
+			}
+			return m.sendAll(ctx, md, req, ss)
+		},
+	}
+}
+
+// sendAll sends all the responses of the server stream
+func (m *Mock) sendAll(ctx context.Context, md protoreflect.MethodDescriptor, req proto.Message, ss grpc.ServerStream) error {
+	v := m.opts.methods[string(md.Name())]
+	if v == nil || v.responder != nil || v.err != nil {
+		res, err := m.respond(ctx, md, req)
+		if err != nil {
+			return err
+		}
+		return ss.SendMsg(res)
+	}
+	for _, res := range v.responses {
+		if err := ss.SendMsg(res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Mock) record(md protoreflect.MethodDescriptor, req proto.Message) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls[string(md.Name())] = append(m.calls[string(md.Name())], proto.Clone(req))
+}
+
+// respond returns the next response of the method
+func (m *Mock) respond(ctx context.Context, md protoreflect.MethodDescriptor, req proto.Message) (proto.Message, error) {
+	v := m.opts.methods[string(md.Name())]
+	switch {
+	case v == nil && m.opts.strict:
+		return nil, errors.Unimplementedf("method %s not mocked", md.Name())
+	case v == nil:
+		return messageType(md.Output()).New().Interface(), nil
+	case v.responder != nil:
+		return v.responder(ctx, req)
+	case v.err != nil:
+		return nil, v.err
+	case len(v.responses) == 0:
+		return messageType(md.Output()).New().Interface(), nil
+	}
+	m.mu.Lock()
+	i := m.next[string(md.Name())]
+	if i < len(v.responses)-1 {
+		m.next[string(md.Name())]++
+	}
+	m.mu.Unlock()
+	return proto.Clone(v.responses[i]), nil
+}
+
+// messageType returns the registered message type of md, or a dynamic one
+func messageType(md protoreflect.MessageDescriptor) protoreflect.MessageType {
+	if mt, err := protoregistry.GlobalTypes.FindMessageByName(md.FullName()); err == nil {
+		return mt
+	}
+	return dynamicpb.NewMessageType(md)
+}
@@ -0,0 +1,129 @@
+package mock
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"go.linka.cloud/grpc/errors"
+)
+
+func testService(t *testing.T) protoreflect.ServiceDescriptor {
+	str := func(name string, n int32) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(n),
+			Type:   descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		}
+	}
+	method := func(name string) *descriptorpb.MethodDescriptorProto {
+		return &descriptorpb.MethodDescriptorProto{
+			Name:       proto.String(name),
+			InputType:  proto.String(".mock.test.HelloRequest"),
+			OutputType: proto.String(".mock.test.HelloReply"),
+		}
+	}
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("mock/test.proto"),
+		Package: proto.String("mock.test"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("HelloRequest"), Field: []*descriptorpb.FieldDescriptorProto{str("name", 1)}},
+			{Name: proto.String("HelloReply"), Field: []*descriptorpb.FieldDescriptorProto{str("message", 1)}},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name:   proto.String("Greeter"),
+			Method: []*descriptorpb.MethodDescriptorProto{method("SayHello"), method("SayGoodbye"), method("SayNothing")},
+		}},
+	}, protoregistry.GlobalFiles)
+	require.NoError(t, err)
+	return fd.Services().Get(0)
+}
+
+func call(t *testing.T, m *Mock, name, req string) (string, error) {
+	for _, v := range m.ServiceDesc().Methods {
+		if v.MethodName != name {
+			continue
+		}
+		res, err := v.Handler(m, context.Background(), func(in interface{}) error {
+			return protojson.Unmarshal([]byte(req), in.(proto.Message))
+		}, nil)
+		if err != nil {
+			return "", err
+		}
+		b, err := protojson.Marshal(res.(proto.Message))
+		require.NoError(t, err)
+		return string(b), nil
+	}
+	t.Fatalf("method %s not found", name)
+	return "", nil
+}
+
+func TestMock(t *testing.T) {
+	sd := testService(t)
+	m, err := New(sd,
+		WithJSONResponses("SayHello", `{"message": "hello"}`, `{"message": "hello again"}`),
+		WithError("SayGoodbye", errors.Unavailablef("down")),
+	)
+	require.NoError(t, err)
+
+	res, err := call(t, m, "SayHello", `{"name": "a"}`)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"message": "hello"}`, res)
+	for i := 0; i < 2; i++ {
+		res, err = call(t, m, "SayHello", `{"name": "b"}`)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"message": "hello again"}`, res)
+	}
+	calls := m.Calls("SayHello")
+	require.Len(t, calls, 3)
+	b, err := protojson.Marshal(calls[0])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name": "a"}`, string(b))
+
+	_, err = call(t, m, "SayGoodbye", `{}`)
+	assert.True(t, errors.IsUnavailable(err))
+
+	res, err = call(t, m, "SayNothing", `{}`)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{}`, res)
+
+	m.Reset()
+	assert.Empty(t, m.Calls("SayHello"))
+	res, err = call(t, m, "SayHello", `{}`)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"message": "hello"}`, res)
+}
+
+func TestMockResponder(t *testing.T) {
+	sd := testService(t)
+	m, err := New(sd, WithStrict(), WithResponder("SayHello", func(ctx context.Context, req proto.Message) (proto.Message, error) {
+		name := req.ProtoReflect().Get(req.ProtoReflect().Descriptor().Fields().ByName("name")).String()
+		res := messageType(sd.Methods().ByName("SayHello").Output()).New()
+		res.Set(res.Descriptor().Fields().ByName("message"), protoreflect.ValueOfString("hello "+name))
+		return res.Interface(), nil
+	}))
+	require.NoError(t, err)
+	res, err := call(t, m, "SayHello", `{"name": "world"}`)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"message": "hello world"}`, res)
+	_, err = call(t, m, "SayNothing", `{}`)
+	assert.True(t, errors.IsUnimplemented(err))
+}
+
+func TestMockInvalid(t *testing.T) {
+	sd := testService(t)
+	_, err := New(sd, WithJSONResponses("Unknown", `{}`))
+	assert.Error(t, err)
+	_, err = New(sd, WithJSONResponses("SayHello", `{"unknown": 1}`))
+	assert.Error(t, err)
+}
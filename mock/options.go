@@ -0,0 +1,82 @@
+package mock
+
+import (
+	"context"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Responder returns the response of a mocked method call, req is the request message, or the last received
+// one for the client streams. It may return a status error.
+type Responder func(ctx context.Context, req proto.Message) (proto.Message, error)
+
+type Option func(o *options)
+
+// WithResponses sets the canned responses of method, e.g. "SayHello": they are returned in order,
+// the last one being repeated. The server streams send them all.
+func WithResponses(method string, res ...proto.Message) Option {
+	return func(o *options) {
+		o.method(method).responses = append(o.method(method).responses, res...)
+	}
+}
+
+// WithJSONResponses sets the canned responses of method as protojson, e.g. loaded from a fixtures file,
+// see WithResponses
+func WithJSONResponses(method string, res ...string) Option {
+	return func(o *options) {
+		o.method(method).json = append(o.method(method).json, res...)
+	}
+}
+
+// WithError makes method return err, e.g. errors.Unavailablef("down")
+func WithError(method string, err error) Option {
+	return func(o *options) {
+		o.method(method).err = err
+	}
+}
+
+// WithResponder scripts the responses of method, it takes precedence over the canned responses
+func WithResponder(method string, r Responder) Option {
+	return func(o *options) {
+		o.method(method).responder = r
+	}
+}
+
+// WithStrict makes the methods without responses return Unimplemented instead of an empty response
+func WithStrict() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+type method struct {
+	responses []proto.Message
+	json      []string
+	err       error
+	responder Responder
+}
+
+type options struct {
+	methods map[string]*method
+	strict  bool
+}
+
+func (o *options) method(name string) *method {
+	if o.methods == nil {
+		o.methods = make(map[string]*method)
+	}
+	m, ok := o.methods[name]
+	if !ok {
+		m = &method{}
+		o.methods[name] = m
+	}
+	return m
+}
+
+func newOptions(opts ...Option) options {
+	var o options
+	for _, v := range opts {
+		v(&o)
+	}
+	return o
+}
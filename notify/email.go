@@ -0,0 +1,40 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/smtp"
+	"strings"
+)
+
+// NewEmail returns a Notifier sending the events by email through the smtp server at addr (host:port)
+func NewEmail(addr string, auth smtp.Auth, from string, to ...string) Notifier {
+	return &email{addr: addr, auth: auth, from: from, to: to}
+}
+
+type email struct {
+	addr string
+	auth smtp.Auth
+	from string
+	to   []string
+}
+
+func (m *email) Notify(ctx context.Context, e Event) error {
+	if len(m.to) == 0 {
+		return nil
+	}
+	subject := fmt.Sprintf("[%s] %s", e.Type, e.Service)
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n",
+		m.from, strings.Join(m.to, ", "), subject, e.String())
+	// smtp.SendMail does not support contexts
+	errs := make(chan error, 1)
+	go func() {
+		errs <- smtp.SendMail(m.addr, m.auth, m.from, m.to, []byte(msg))
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,89 @@
+// Package notify sends the service lifecycle and error events to webhooks, Slack or email,
+// for deployments without a full alerting stack.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/multierr"
+)
+
+type EventType string
+
+const (
+	Started            EventType = "started"
+	Stopped            EventType = "stopped"
+	PanicRecovered     EventType = "panic_recovered"
+	HealthChanged      EventType = "health_changed"
+	RegistrationFailed EventType = "registration_failed"
+)
+
+// Event is a notification
+type Event struct {
+	Type     EventType         `json:"type"`
+	Service  string            `json:"service,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	Message  string            `json:"message"`
+	Time     time.Time         `json:"time"`
+	Fields   map[string]string `json:"fields,omitempty"`
+}
+
+// String formats the event as a human readable line
+func (e Event) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s]", e.Type)
+	if e.Service != "" {
+		fmt.Fprintf(&b, " %s", e.Service)
+		if e.Instance != "" {
+			fmt.Fprintf(&b, " (%s)", e.Instance)
+		}
+	}
+	fmt.Fprintf(&b, ": %s", e.Message)
+	keys := make([]string, 0, len(e.Fields))
+	for k := range e.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%s", k, e.Fields[k])
+	}
+	return b.String()
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, e Event) error
+}
+
+// Func is a Notifier function
+type Func func(ctx context.Context, e Event) error
+
+func (fn Func) Notify(ctx context.Context, e Event) error {
+	return fn(ctx, e)
+}
+
+// Filter returns a Notifier only forwarding the events of the given types to n
+func Filter(n Notifier, types ...EventType) Notifier {
+	return Func(func(ctx context.Context, e Event) error {
+		for _, v := range types {
+			if v == e.Type {
+				return n.Notify(ctx, e)
+			}
+		}
+		return nil
+	})
+}
+
+// Multi returns a Notifier forwarding the events to all the notifiers
+func Multi(ns ...Notifier) Notifier {
+	return Func(func(ctx context.Context, e Event) error {
+		var err error
+		for _, n := range ns {
+			err = multierr.Append(err, n.Notify(ctx, e))
+		}
+		return err
+	})
+}
@@ -0,0 +1,61 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhook(t *testing.T) {
+	var got Event
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	e := Event{Type: Started, Service: "test", Message: "service started", Time: time.Now().UTC()}
+	n := NewWebhook(srv.URL, WithHeaders(map[string]string{"Authorization": "Bearer token"}))
+	require.NoError(t, n.Notify(context.Background(), e))
+	assert.Equal(t, Started, got.Type)
+	assert.Equal(t, "service started", got.Message)
+	assert.Equal(t, "Bearer token", auth)
+}
+
+func TestSlack(t *testing.T) {
+	var got map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	e := Event{Type: HealthChanged, Service: "test", Message: "not serving", Fields: map[string]string{"status": "NOT_SERVING"}}
+	require.NoError(t, NewSlack(srv.URL).Notify(context.Background(), e))
+	assert.Equal(t, "[health_changed] test: not serving status=NOT_SERVING", got["text"])
+}
+
+func TestWebhookError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	assert.Error(t, NewWebhook(srv.URL).Notify(context.Background(), Event{Type: Stopped}))
+}
+
+func TestFilter(t *testing.T) {
+	var got []EventType
+	n := Filter(Func(func(ctx context.Context, e Event) error {
+		got = append(got, e.Type)
+		return nil
+	}), PanicRecovered)
+	require.NoError(t, n.Notify(context.Background(), Event{Type: Started}))
+	require.NoError(t, n.Notify(context.Background(), Event{Type: PanicRecovered}))
+	assert.Equal(t, []EventType{PanicRecovered}, got)
+}
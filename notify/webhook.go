@@ -0,0 +1,85 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+type Option func(o *options)
+
+type options struct {
+	client  *http.Client
+	headers map[string]string
+}
+
+// WithHTTPClient sets the client used to post the events
+func WithHTTPClient(c *http.Client) Option {
+	return func(o *options) {
+		o.client = c
+	}
+}
+
+// WithHeaders adds headers to the requests, e.g. for authentication
+func WithHeaders(headers map[string]string) Option {
+	return func(o *options) {
+		for k, v := range headers {
+			o.headers[k] = v
+		}
+	}
+}
+
+func newOptions(opts ...Option) *options {
+	o := &options{client: http.DefaultClient, headers: make(map[string]string)}
+	for _, v := range opts {
+		v(o)
+	}
+	return o
+}
+
+// NewWebhook returns a Notifier posting the events as json to url
+func NewWebhook(url string, opts ...Option) Notifier {
+	return &webhook{url: url, opts: newOptions(opts...), body: func(e Event) interface{} { return e }}
+}
+
+// NewSlack returns a Notifier posting the events to a Slack incoming webhook url
+func NewSlack(url string, opts ...Option) Notifier {
+	return &webhook{url: url, opts: newOptions(opts...), body: func(e Event) interface{} {
+		return map[string]string{"text": e.String()}
+	}}
+}
+
+type webhook struct {
+	url  string
+	opts *options
+	body func(e Event) interface{}
+}
+
+func (w *webhook) Notify(ctx context.Context, e Event) error {
+	b, err := json.Marshal(w.body(e))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.opts.headers {
+		req.Header.Set(k, v)
+	}
+	res, err := w.opts.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, res.Body)
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("notify: %s returned %s", w.url, res.Status)
+	}
+	return nil
+}
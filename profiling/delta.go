@@ -0,0 +1,271 @@
+package profiling
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+)
+
+// the pprof protobuf field numbers, see https://github.com/google/pprof/blob/main/proto/profile.proto
+const (
+	profileSampleType  = 1
+	profileSample      = 2
+	profileLocation    = 4
+	profileStringTable = 6
+
+	valueTypeType = 1
+
+	sampleLocationID = 1
+	sampleValue      = 2
+
+	locationID      = 1
+	locationAddress = 3
+)
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// cumulative are the sample types the runtime accumulates since the process start:
+// the allocations of the heap and allocs profiles and the contentions of the mutex and block profiles.
+// The inuse_* values of the heap profile are a snapshot and are kept as is.
+var cumulative = map[string]bool{
+	"alloc_objects": true,
+	"alloc_space":   true,
+	"contentions":   true,
+	"delay":         true,
+}
+
+var errMalformed = errors.New("profiling: malformed pprof profile")
+
+// delta returns the profile of the allocations or contentions since the previous call for the same type,
+// the first one covers the time since the process start.
+// The samples are matched by their stack addresses, as the location ids are not stable between writes.
+func (p *profiler) delta(t Type, data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	fs, err := fields(b)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		strs    []string
+		types   []int64
+		samples []field
+		addrs   = make(map[uint64]uint64)
+	)
+	for _, f := range fs {
+		switch f.num {
+		case profileStringTable:
+			strs = append(strs, string(f.data))
+		case profileSampleType:
+			vt, err := fields(f.data)
+			if err != nil {
+				return nil, err
+			}
+			var s int64
+			for _, v := range vt {
+				if v.num == valueTypeType {
+					s = int64(v.val)
+				}
+			}
+			types = append(types, s)
+		case profileLocation:
+			lf, err := fields(f.data)
+			if err != nil {
+				return nil, err
+			}
+			var id, addr uint64
+			for _, v := range lf {
+				switch v.num {
+				case locationID:
+					id = v.val
+				case locationAddress:
+					addr = v.val
+				}
+			}
+			addrs[id] = addr
+		case profileSample:
+			samples = append(samples, f)
+		}
+	}
+	deltas := make([]bool, len(types))
+	for i, v := range types {
+		if v < 0 || int(v) >= len(strs) {
+			return nil, errMalformed
+		}
+		deltas[i] = cumulative[strs[v]]
+	}
+	if p.prev == nil {
+		p.prev = make(map[Type]map[string][]int64)
+	}
+	prev, cur := p.prev[t], make(map[string][]int64, len(samples))
+	var out []byte
+	for _, f := range fs {
+		if f.num != profileSample {
+			out = append(out, f.raw...)
+			continue
+		}
+		sf, err := fields(f.data)
+		if err != nil {
+			return nil, err
+		}
+		var (
+			key    []byte
+			values []int64
+			rest   []byte
+		)
+		for _, v := range sf {
+			switch v.num {
+			case sampleLocationID:
+				ids, err := varints(v)
+				if err != nil {
+					return nil, err
+				}
+				key = appendVarint(key, uint64(len(ids)))
+				for _, id := range ids {
+					key = appendVarint(key, addrs[id])
+				}
+				rest = append(rest, v.raw...)
+			case sampleValue:
+				vs, err := varints(v)
+				if err != nil {
+					return nil, err
+				}
+				for _, v := range vs {
+					values = append(values, int64(v))
+				}
+			default:
+				// the labels are part of the sample identity
+				key = append(key, v.raw...)
+				rest = append(rest, v.raw...)
+			}
+		}
+		if len(values) != len(types) {
+			return nil, errMalformed
+		}
+		cur[string(key)] = append([]int64(nil), values...)
+		zero := true
+		for i, v := range values {
+			if last, ok := prev[string(key)]; ok && deltas[i] && v >= last[i] {
+				values[i] = v - last[i]
+			}
+			if values[i] != 0 {
+				zero = false
+			}
+		}
+		if zero {
+			continue
+		}
+		var packed []byte
+		for _, v := range values {
+			packed = appendVarint(packed, uint64(v))
+		}
+		rest = appendBytes(rest, sampleValue, packed)
+		out = appendBytes(out, profileSample, rest)
+	}
+	p.prev[t] = cur
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(out); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// field is a protobuf wire format field
+type field struct {
+	num  int
+	typ  int
+	val  uint64
+	data []byte
+	// raw is the whole encoded field, tag included
+	raw []byte
+}
+
+func fields(b []byte) ([]field, error) {
+	var out []field
+	for len(b) > 0 {
+		start := b
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errMalformed
+		}
+		b = b[n:]
+		f := field{num: int(tag >> 3), typ: int(tag & 7)}
+		switch f.typ {
+		case wireVarint:
+			f.val, n = binary.Uvarint(b)
+			if n <= 0 {
+				return nil, errMalformed
+			}
+		case wireFixed64:
+			if len(b) < 8 {
+				return nil, errMalformed
+			}
+			f.val, n = binary.LittleEndian.Uint64(b), 8
+		case wireFixed32:
+			if len(b) < 4 {
+				return nil, errMalformed
+			}
+			f.val, n = uint64(binary.LittleEndian.Uint32(b)), 4
+		case wireBytes:
+			l, m := binary.Uvarint(b)
+			if m <= 0 || uint64(len(b)-m) < l {
+				return nil, errMalformed
+			}
+			f.data, n = b[m:m+int(l)], m+int(l)
+		default:
+			return nil, errMalformed
+		}
+		b = b[n:]
+		f.raw = start[:len(start)-len(b)]
+		out = append(out, f)
+	}
+	return out, nil
+}
+
+// varints returns the values of a repeated varint field, packed or not
+func varints(f field) ([]uint64, error) {
+	if f.typ == wireVarint {
+		return []uint64{f.val}, nil
+	}
+	if f.typ != wireBytes {
+		return nil, errMalformed
+	}
+	var out []uint64
+	for b := f.data; len(b) > 0; {
+		v, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errMalformed
+		}
+		out = append(out, v)
+		b = b[n:]
+	}
+	return out, nil
+}
+
+func appendVarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+func appendBytes(b []byte, num int, data []byte) []byte {
+	b = appendVarint(b, uint64(num)<<3|wireBytes)
+	b = appendVarint(b, uint64(len(data)))
+	return append(b, data...)
+}
@@ -0,0 +1,186 @@
+package profiling
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"runtime"
+	"runtime/pprof"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testSample struct {
+	locations []uint64
+	values    []int64
+}
+
+// encodeProfile writes a gzipped heap profile, the location ids are offset to check that the samples are matched
+// by address
+func encodeProfile(t *testing.T, offset uint64, samples ...testSample) []byte {
+	strs := []string{"", "alloc_objects", "alloc_space", "inuse_objects", "inuse_space", "count", "bytes"}
+	var b []byte
+	for _, v := range [][2]uint64{{1, 5}, {2, 6}, {3, 5}, {4, 6}} {
+		var vt []byte
+		vt = appendVarint(vt, valueTypeType<<3|wireVarint)
+		vt = appendVarint(vt, v[0])
+		vt = appendVarint(vt, 2<<3|wireVarint)
+		vt = appendVarint(vt, v[1])
+		b = appendBytes(b, profileSampleType, vt)
+	}
+	addrs := make(map[uint64]bool)
+	for _, s := range samples {
+		var ids, values, sb []byte
+		for _, v := range s.locations {
+			ids = appendVarint(ids, v+offset)
+			addrs[v] = true
+		}
+		for _, v := range s.values {
+			values = appendVarint(values, uint64(v))
+		}
+		sb = appendBytes(sb, sampleLocationID, ids)
+		sb = appendBytes(sb, sampleValue, values)
+		b = appendBytes(b, profileSample, sb)
+	}
+	for v := range addrs {
+		var l []byte
+		l = appendVarint(l, locationID<<3|wireVarint)
+		l = appendVarint(l, v+offset)
+		l = appendVarint(l, locationAddress<<3|wireVarint)
+		l = appendVarint(l, 0x1000+v)
+		b = appendBytes(b, profileLocation, l)
+	}
+	for _, v := range strs {
+		b = appendBytes(b, profileStringTable, []byte(v))
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write(b)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+// decodeSamples returns the sample values by their first location address
+func decodeSamples(t *testing.T, data []byte) map[uint64][]int64 {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	b, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	fs, err := fields(b)
+	require.NoError(t, err)
+	addrs := make(map[uint64]uint64)
+	for _, f := range fs {
+		if f.num != profileLocation {
+			continue
+		}
+		lf, err := fields(f.data)
+		require.NoError(t, err)
+		var id, addr uint64
+		for _, v := range lf {
+			switch v.num {
+			case locationID:
+				id = v.val
+			case locationAddress:
+				addr = v.val
+			}
+		}
+		addrs[id] = addr
+	}
+	out := make(map[uint64][]int64)
+	for _, f := range fs {
+		if f.num != profileSample {
+			continue
+		}
+		sf, err := fields(f.data)
+		require.NoError(t, err)
+		var (
+			addr   uint64
+			values []int64
+		)
+		for _, v := range sf {
+			vs, err := varints(v)
+			require.NoError(t, err)
+			switch v.num {
+			case sampleLocationID:
+				addr = addrs[vs[0]]
+			case sampleValue:
+				for _, v := range vs {
+					values = append(values, int64(v))
+				}
+			}
+		}
+		out[addr] = values
+	}
+	return out
+}
+
+func TestDelta(t *testing.T) {
+	p := &profiler{o: newOptions()}
+	// the first profile covers the time since the process start
+	d, err := p.delta(Heap, encodeProfile(t, 0,
+		testSample{locations: []uint64{1, 2}, values: []int64{10, 100, 2, 20}},
+		testSample{locations: []uint64{3}, values: []int64{5, 50, 0, 0}},
+	))
+	require.NoError(t, err)
+	assert.Equal(t, map[uint64][]int64{
+		0x1001: {10, 100, 2, 20},
+		0x1003: {5, 50, 0, 0},
+	}, decodeSamples(t, d))
+
+	d, err = p.delta(Heap, encodeProfile(t, 10,
+		testSample{locations: []uint64{1, 2}, values: []int64{15, 150, 1, 10}},
+		testSample{locations: []uint64{3}, values: []int64{5, 50, 0, 0}},
+		testSample{locations: []uint64{4}, values: []int64{1, 8, 1, 8}},
+	))
+	require.NoError(t, err)
+	// the allocations are the ones of the period, the in use values are kept
+	// and the samples without activity are dropped
+	assert.Equal(t, map[uint64][]int64{
+		0x1001: {5, 50, 1, 10},
+		0x1004: {1, 8, 1, 8},
+	}, decodeSamples(t, d))
+
+	// the types do not share their previous values
+	d, err = p.delta(Allocs, encodeProfile(t, 0,
+		testSample{locations: []uint64{3}, values: []int64{5, 50, 0, 0}},
+	))
+	require.NoError(t, err)
+	assert.Equal(t, map[uint64][]int64{0x1003: {5, 50, 0, 0}}, decodeSamples(t, d))
+
+	_, err = p.delta(Heap, []byte("not a profile"))
+	assert.Error(t, err)
+}
+
+var sink []byte
+
+func TestDeltaRuntime(t *testing.T) {
+	p := &profiler{o: newOptions()}
+	write := func() []byte {
+		runtime.GC()
+		var b bytes.Buffer
+		require.NoError(t, pprof.Lookup(string(Allocs)).WriteTo(&b, 0))
+		d, err := p.delta(Allocs, b.Bytes())
+		require.NoError(t, err)
+		return d
+	}
+	sum := func(data []byte) (n int64) {
+		for _, v := range decodeSamples(t, data) {
+			n += v[1]
+		}
+		return n
+	}
+	old := runtime.MemProfileRate
+	runtime.MemProfileRate = 1
+	defer func() {
+		runtime.MemProfileRate = old
+	}()
+	for i := 0; i < 1<<10; i++ {
+		sink = make([]byte, 1<<10)
+	}
+	total := sum(write())
+	// the runtime output is rewritten to a valid profile with only the allocations since the last one
+	assert.Less(t, sum(write()), total)
+}
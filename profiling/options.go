@@ -0,0 +1,71 @@
+package profiling
+
+import (
+	"time"
+)
+
+const defaultPeriod = 10 * time.Second
+
+type Option func(o *options)
+
+// WithUploader sets the backend the profiles are sent to
+func WithUploader(u Uploader) Option {
+	return func(o *options) {
+		o.uploader = u
+	}
+}
+
+// WithPyroscope sends the profiles to the pyroscope server at addr
+func WithPyroscope(addr string, opts ...PyroscopeOption) Option {
+	return func(o *options) {
+		o.uploader = NewPyroscopeUploader(addr, opts...)
+	}
+}
+
+// WithTypes sets the collected profile types, defaults to cpu, heap and goroutine
+func WithTypes(types ...Type) Option {
+	return func(o *options) {
+		o.types = types
+	}
+}
+
+// WithPeriod sets the collection period, i.e. the cpu profile duration
+func WithPeriod(d time.Duration) Option {
+	return func(o *options) {
+		o.period = d
+	}
+}
+
+// WithTags adds tags to all the profiles
+func WithTags(tags map[string]string) Option {
+	return func(o *options) {
+		for k, v := range tags {
+			o.tags[k] = v
+		}
+	}
+}
+
+// WithService sets the application name and tags the profiles with the service version and instance id
+func WithService(name, version, instanceID string) Option {
+	return func(o *options) {
+		o.name = name
+		o.tags[TagVersion] = version
+		o.tags[TagInstance] = instanceID
+	}
+}
+
+type options struct {
+	name     string
+	uploader Uploader
+	types    []Type
+	period   time.Duration
+	tags     map[string]string
+}
+
+func newOptions() *options {
+	return &options{
+		types:  []Type{CPU, Heap, Goroutine},
+		period: defaultPeriod,
+		tags:   make(map[string]string),
+	}
+}
@@ -0,0 +1,203 @@
+// Package profiling continuously collects pprof profiles and pushes them to a profiling backend
+package profiling
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	"go.linka.cloud/grpc/logger"
+)
+
+const (
+	TagVersion  = "version"
+	TagInstance = "instance"
+)
+
+// Type is a pprof profile type
+type Type string
+
+const (
+	CPU       Type = "cpu"
+	Heap      Type = "heap"
+	Allocs    Type = "allocs"
+	Goroutine Type = "goroutine"
+	Mutex     Type = "mutex"
+	Block     Type = "block"
+)
+
+// Profile is a collected pprof profile
+type Profile struct {
+	Name  string
+	Type  Type
+	From  time.Time
+	Until time.Time
+	Tags  map[string]string
+	// Data is the gzipped pprof protobuf
+	Data []byte
+}
+
+// Uploader sends the profiles to the profiling backend
+type Uploader interface {
+	Upload(ctx context.Context, p *Profile) error
+}
+
+// Profiler periodically collects and uploads profiles.
+// It implements the service.Module interface so that it can be started and stopped with the service.
+type Profiler interface {
+	Name() string
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+}
+
+func NewProfiler(opts ...Option) (Profiler, error) {
+	o := newOptions()
+	for _, v := range opts {
+		v(o)
+	}
+	if o.uploader == nil {
+		return nil, errors.New("profiling: no uploader configured")
+	}
+	if o.name == "" {
+		return nil, errors.New("profiling: application name is required")
+	}
+	return &profiler{o: o}, nil
+}
+
+type profiler struct {
+	o      *options
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+	// prev holds the last cumulative values by sample stack, it is only used by the run goroutine
+	prev map[Type]map[string][]int64
+}
+
+func (p *profiler) Name() string {
+	return "profiling"
+}
+
+func (p *profiler) Start(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cancel != nil {
+		return nil
+	}
+	ctx, p.cancel = context.WithCancel(ctx)
+	p.done = make(chan struct{})
+	go p.run(ctx)
+	return nil
+}
+
+func (p *profiler) Stop(_ context.Context) error {
+	p.mu.Lock()
+	if p.cancel == nil {
+		p.mu.Unlock()
+		return nil
+	}
+	p.cancel()
+	p.cancel = nil
+	done := p.done
+	p.mu.Unlock()
+	<-done
+	return nil
+}
+
+func (p *profiler) run(ctx context.Context) {
+	defer close(p.done)
+	for {
+		ps := p.collect(ctx)
+		for _, v := range ps {
+			// use a fresh context so that the last profiles are sent on shutdown
+			uctx, cancel := context.WithTimeout(context.Background(), p.o.period)
+			if err := p.o.uploader.Upload(uctx, v); err != nil {
+				logger.C(ctx).Warnf("profiling: failed to upload %s profile: %v", v.Type, err)
+			}
+			cancel()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+	}
+}
+
+// collect records the profiles for one period, it returns early when ctx is done
+func (p *profiler) collect(ctx context.Context) []*Profile {
+	log := logger.C(ctx)
+	from := time.Now()
+	var cpu *bytes.Buffer
+	if p.has(CPU) {
+		cpu = &bytes.Buffer{}
+		if err := pprof.StartCPUProfile(cpu); err != nil {
+			// another cpu profile is probably running, e.g. from the pprof http handler
+			log.Warnf("profiling: failed to start cpu profile: %v", err)
+			cpu = nil
+		}
+	}
+	t := time.NewTimer(p.o.period)
+	select {
+	case <-ctx.Done():
+		t.Stop()
+	case <-t.C:
+	}
+	if cpu != nil {
+		pprof.StopCPUProfile()
+	}
+	until := time.Now()
+	var out []*Profile
+	for _, v := range p.o.types {
+		var data []byte
+		if v == CPU {
+			if cpu == nil {
+				continue
+			}
+			data = cpu.Bytes()
+		} else {
+			pp := pprof.Lookup(string(v))
+			if pp == nil {
+				log.Warnf("profiling: unknown profile type %s", v)
+				continue
+			}
+			var b bytes.Buffer
+			if err := pp.WriteTo(&b, 0); err != nil {
+				log.Warnf("profiling: failed to write %s profile: %v", v, err)
+				continue
+			}
+			data = b.Bytes()
+			switch v {
+			case Heap, Allocs, Mutex, Block:
+				// the runtime accumulates the allocations and contentions since the process start:
+				// only upload what happened during the period
+				d, err := p.delta(v, data)
+				if err != nil {
+					log.Warnf("profiling: failed to compute %s profile delta: %v", v, err)
+					continue
+				}
+				data = d
+			}
+		}
+		out = append(out, &Profile{
+			Name:  p.o.name,
+			Type:  v,
+			From:  from,
+			Until: until,
+			Tags:  p.o.tags,
+			Data:  data,
+		})
+	}
+	return out
+}
+
+func (p *profiler) has(t Type) bool {
+	for _, v := range p.o.types {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
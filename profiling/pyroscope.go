@@ -0,0 +1,93 @@
+package profiling
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type PyroscopeOption func(u *pyroscope)
+
+// WithPyroscopeAuthToken sets the bearer token used to authenticate the uploads
+func WithPyroscopeAuthToken(token string) PyroscopeOption {
+	return func(u *pyroscope) {
+		u.token = token
+	}
+}
+
+// WithPyroscopeHTTPClient sets the client used to upload the profiles
+func WithPyroscopeHTTPClient(c *http.Client) PyroscopeOption {
+	return func(u *pyroscope) {
+		u.client = c
+	}
+}
+
+// NewPyroscopeUploader returns an Uploader using the pyroscope ingest api
+func NewPyroscopeUploader(addr string, opts ...PyroscopeOption) Uploader {
+	u := &pyroscope{addr: strings.TrimSuffix(addr, "/"), client: http.DefaultClient}
+	for _, v := range opts {
+		v(u)
+	}
+	return u
+}
+
+type pyroscope struct {
+	addr   string
+	token  string
+	client *http.Client
+}
+
+func (u *pyroscope) Upload(ctx context.Context, p *Profile) error {
+	q := url.Values{}
+	q.Set("name", pyroscopeName(p))
+	q.Set("from", strconv.FormatInt(p.From.Unix(), 10))
+	q.Set("until", strconv.FormatInt(p.Until.Unix(), 10))
+	q.Set("format", "pprof")
+	q.Set("spyName", "gospy")
+	if p.Type == CPU {
+		q.Set("sampleRate", "100")
+	}
+	req, err := http.NewRequest(http.MethodPost, u.addr+"/ingest?"+q.Encode(), bytes.NewReader(p.Data))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if u.token != "" {
+		req.Header.Set("Authorization", "Bearer "+u.token)
+	}
+	res, err := u.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("pyroscope: unexpected status %s: %s", res.Status, string(msg))
+	}
+	io.Copy(ioutil.Discard, res.Body)
+	return nil
+}
+
+// pyroscopeName formats the application name as app.type{key=value,...}
+func pyroscopeName(p *Profile) string {
+	var keys []string
+	for k, v := range p.Tags {
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	tags := make([]string, 0, len(keys))
+	for _, k := range keys {
+		tags = append(tags, k+"="+p.Tags[k])
+	}
+	return fmt.Sprintf("%s.%s{%s}", p.Name, p.Type, strings.Join(tags, ","))
+}
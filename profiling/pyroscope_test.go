@@ -0,0 +1,56 @@
+package profiling
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPyroscopeUpload(t *testing.T) {
+	var (
+		query url.Values
+		auth  string
+		body  []byte
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/ingest", r.URL.Path)
+		query = r.URL.Query()
+		auth = r.Header.Get("Authorization")
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	u := NewPyroscopeUploader(srv.URL+"/", WithPyroscopeAuthToken("token"))
+	p := &Profile{
+		Name:  "svc",
+		Type:  CPU,
+		From:  time.Unix(10, 0),
+		Until: time.Unix(20, 0),
+		Tags:  map[string]string{TagVersion: "v1", TagInstance: "id", "empty": ""},
+		Data:  []byte("data"),
+	}
+	require.NoError(t, u.Upload(context.Background(), p))
+	assert.Equal(t, "svc.cpu{instance=id,version=v1}", query.Get("name"))
+	assert.Equal(t, "10", query.Get("from"))
+	assert.Equal(t, "20", query.Get("until"))
+	assert.Equal(t, "pprof", query.Get("format"))
+	assert.Equal(t, "Bearer token", auth)
+	assert.Equal(t, []byte("data"), body)
+}
+
+func TestPyroscopeUploadError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	err := NewPyroscopeUploader(srv.URL).Upload(context.Background(), &Profile{Name: "svc", Type: Heap})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "boom")
+}
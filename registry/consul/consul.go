@@ -0,0 +1,480 @@
+// Package consul is a registry.Registry backed by the Consul agent HTTP API
+package consul
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/resolver"
+
+	"go.linka.cloud/grpc/registry"
+	resolver2 "go.linka.cloud/grpc/resolver"
+)
+
+const (
+	// DefaultAddress is the address of the local Consul agent
+	DefaultAddress = "127.0.0.1:8500"
+	// DefaultProtocol is the protocol tag of the registered services
+	DefaultProtocol = "grpc"
+
+	// TagVersion is the prefix of the tag holding the service version
+	TagVersion = "version="
+	// TagProtocol is the prefix of the tag holding the service protocol
+	TagProtocol = "protocol="
+)
+
+// NewRegistry returns a registry backed by the Consul agents at the registry addresses, defaults to the local one.
+// The services registered with a TTL are passing as long as they are registered again before it expires,
+// Consul removes them once their check has been critical for DeregisterCriticalAfter.
+// The nodes metadata are registered as the Consul service metadata, their keys must be valid Consul metadata keys.
+func NewRegistry(opts ...registry.Option) registry.Registry {
+	options := registry.Options{
+		Context: context.Background(),
+		Timeout: 5 * time.Second,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	return &consulRegistry{opts: options, http: newHTTPClient(options), registered: make(map[string]uint64)}
+}
+
+type consulRegistry struct {
+	opts registry.Options
+	http *http.Client
+
+	mu sync.Mutex
+	// registered is the hash of the registered services definitions by id
+	registered map[string]uint64
+}
+
+type agentService struct {
+	ID      string
+	Name    string
+	Tags    []string          `json:",omitempty"`
+	Address string            `json:",omitempty"`
+	Port    int               `json:",omitempty"`
+	Meta    map[string]string `json:",omitempty"`
+	Check   *agentCheck       `json:",omitempty"`
+}
+
+type agentCheck struct {
+	CheckID                        string
+	Name                           string
+	Status                         string `json:",omitempty"`
+	TTL                            string `json:",omitempty"`
+	TCP                            string `json:",omitempty"`
+	Interval                       string `json:",omitempty"`
+	DeregisterCriticalServiceAfter string `json:",omitempty"`
+}
+
+type healthEntry struct {
+	Node struct {
+		Address string
+	}
+	Service struct {
+		ID      string
+		Service string
+		Tags    []string
+		Address string
+		Port    int
+		Meta    map[string]string
+	}
+}
+
+func (c *consulRegistry) ResolverBuilder() resolver.Builder {
+	return resolver2.New(c)
+}
+
+func (c *consulRegistry) Init(opts ...registry.Option) error {
+	for _, o := range opts {
+		o(&c.opts)
+	}
+	c.http = newHTTPClient(c.opts)
+	return nil
+}
+
+func (c *consulRegistry) Options() registry.Options {
+	return c.opts
+}
+
+func (c *consulRegistry) Register(s *registry.Service, opts ...registry.RegisterOption) error {
+	if len(s.Nodes) == 0 {
+		return errors.New("consul: service has no nodes")
+	}
+	var o registry.RegisterOptions
+	for _, v := range opts {
+		v(&o)
+	}
+	ctx, cancel := c.context(o.Context)
+	defer cancel()
+	for _, n := range s.Nodes {
+		if err := c.register(ctx, s, n, o.TTL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// register registers the node, or only passes its TTL check if its definition did not change
+func (c *consulRegistry) register(ctx context.Context, s *registry.Service, n *registry.Node, ttl time.Duration) error {
+	host, port, err := splitAddress(n.Address)
+	if err != nil {
+		return err
+	}
+	meta := make(map[string]string, len(s.Metadata)+len(n.Metadata))
+	for k, v := range s.Metadata {
+		meta[k] = v
+	}
+	for k, v := range n.Metadata {
+		meta[k] = v
+	}
+	svc := agentService{ID: n.Id, Name: s.Name, Tags: c.tags(s.Version), Address: host, Port: port, Meta: meta}
+	check := &agentCheck{CheckID: checkID(n.Id), Name: "service " + s.Name, DeregisterCriticalServiceAfter: c.deregisterAfter().String()}
+	switch {
+	case ttl > 0:
+		check.TTL = ttl.String()
+		check.Status = "passing"
+		svc.Check = check
+	case c.tcpCheck() > 0:
+		check.TCP = n.Address
+		check.Interval = c.tcpCheck().String()
+		svc.Check = check
+	}
+	b, err := json.Marshal(svc)
+	if err != nil {
+		return err
+	}
+	h := fnv.New64a()
+	h.Write(b)
+	sum := h.Sum64()
+	c.mu.Lock()
+	last, ok := c.registered[n.Id]
+	c.mu.Unlock()
+	if ok && last == sum && ttl > 0 {
+		// the agent may have lost the registration, e.g. if it restarted, the service is then registered again
+		if _, err := c.do(ctx, http.MethodPut, "agent/check/pass/"+check.CheckID, nil, nil, nil); err == nil {
+			return nil
+		}
+	}
+	if _, err := c.do(ctx, http.MethodPut, "agent/service/register", nil, svc, nil); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.registered[n.Id] = sum
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *consulRegistry) Deregister(s *registry.Service, opts ...registry.DeregisterOption) error {
+	var o registry.DeregisterOptions
+	for _, v := range opts {
+		v(&o)
+	}
+	ctx, cancel := c.context(o.Context)
+	defer cancel()
+	for _, n := range s.Nodes {
+		if _, err := c.do(ctx, http.MethodPut, "agent/service/deregister/"+n.Id, nil, nil, nil); err != nil {
+			return err
+		}
+		c.mu.Lock()
+		delete(c.registered, n.Id)
+		c.mu.Unlock()
+	}
+	return nil
+}
+
+type catalogEntry struct {
+	Node      string
+	ServiceID string
+}
+
+type catalogDeregistration struct {
+	Datacenter string `json:",omitempty"`
+	Node       string
+	ServiceID  string
+}
+
+// Purge removes the nodes from the catalog, whichever agent registered them, unlike Deregister which only
+// removes the services of the agent it sends the requests to. The agent running a purged node registers it
+// again with its anti-entropy sync: Purge is meant for the nodes left by the crashed agents or hosts, the ones
+// of the crashed instances registered with a TTL are removed by Consul after DeregisterCriticalAfter.
+func (c *consulRegistry) Purge(s *registry.Service) error {
+	ctx, cancel := c.context(nil)
+	defer cancel()
+	var entries []catalogEntry
+	if _, err := c.do(ctx, http.MethodGet, "catalog/service/"+s.Name, nil, nil, &entries); err != nil {
+		return err
+	}
+	nodes := make(map[string]string, len(entries))
+	for _, v := range entries {
+		nodes[v.ServiceID] = v.Node
+	}
+	for _, n := range s.Nodes {
+		node, ok := nodes[n.Id]
+		if !ok {
+			continue
+		}
+		d := catalogDeregistration{Datacenter: c.datacenter(), Node: node, ServiceID: n.Id}
+		if _, err := c.do(ctx, http.MethodPut, "catalog/deregister", nil, d, nil); err != nil {
+			return err
+		}
+		c.mu.Lock()
+		delete(c.registered, n.Id)
+		c.mu.Unlock()
+	}
+	return nil
+}
+
+// GetService returns the passing nodes of the service, grouped by version
+func (c *consulRegistry) GetService(name string, opts ...registry.GetOption) ([]*registry.Service, error) {
+	var o registry.GetOptions
+	for _, v := range opts {
+		v(&o)
+	}
+	ctx, cancel := c.context(o.Context)
+	defer cancel()
+	svcs, _, err := c.service(ctx, name, 0, 0)
+	return svcs, err
+}
+
+// service returns the passing nodes of the service, using a blocking query if index is not zero
+func (c *consulRegistry) service(ctx context.Context, name string, index uint64, wait time.Duration) ([]*registry.Service, uint64, error) {
+	q := url.Values{"passing": {"1"}}
+	if index != 0 {
+		q.Set("index", strconv.FormatUint(index, 10))
+		q.Set("wait", wait.String())
+	}
+	var entries []healthEntry
+	idx, err := c.do(ctx, http.MethodGet, "health/service/"+name, q, nil, &entries)
+	if err != nil {
+		return nil, 0, err
+	}
+	return toServices(entries), idx, nil
+}
+
+// ListServices returns the catalog services, once by version, without their nodes
+func (c *consulRegistry) ListServices(opts ...registry.ListOption) ([]*registry.Service, error) {
+	var o registry.ListOptions
+	for _, v := range opts {
+		v(&o)
+	}
+	ctx, cancel := c.context(o.Context)
+	defer cancel()
+	names, err := c.names(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var svcs []*registry.Service
+	for _, v := range names {
+		versions := make(map[string]bool)
+		for _, t := range v.tags {
+			if strings.HasPrefix(t, TagVersion) {
+				versions[strings.TrimPrefix(t, TagVersion)] = true
+			}
+		}
+		if len(versions) == 0 {
+			svcs = append(svcs, &registry.Service{Name: v.name})
+			continue
+		}
+		for _, vv := range sortedKeys(versions) {
+			svcs = append(svcs, &registry.Service{Name: v.name, Version: vv})
+		}
+	}
+	return svcs, nil
+}
+
+type catalogService struct {
+	name string
+	tags []string
+}
+
+// names returns the catalog services but the consul one
+func (c *consulRegistry) names(ctx context.Context) ([]catalogService, error) {
+	var m map[string][]string
+	if _, err := c.do(ctx, http.MethodGet, "catalog/services", nil, nil, &m); err != nil {
+		return nil, err
+	}
+	out := make([]catalogService, 0, len(m))
+	for k, v := range m {
+		if k == "consul" {
+			continue
+		}
+		out = append(out, catalogService{name: k, tags: v})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].name < out[j].name
+	})
+	return out, nil
+}
+
+func (c *consulRegistry) Watch(opts ...registry.WatchOption) (registry.Watcher, error) {
+	var o registry.WatchOptions
+	for _, v := range opts {
+		v(&o)
+	}
+	return newWatcher(c, o), nil
+}
+
+func (c *consulRegistry) String() string {
+	return "consul"
+}
+
+// context returns the request context, bound by the registry timeout
+func (c *consulRegistry) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if c.opts.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.opts.Timeout)
+}
+
+func newHTTPClient(o registry.Options) *http.Client {
+	if o.TLSConfig == nil {
+		return http.DefaultClient
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = o.TLSConfig
+	return &http.Client{Transport: t}
+}
+
+// do sends the request to the first reachable agent and decodes the response into out if not nil,
+// it returns the Consul index of the response
+func (c *consulRegistry) do(ctx context.Context, method, path string, q url.Values, in, out interface{}) (uint64, error) {
+	if q == nil {
+		q = url.Values{}
+	}
+	if dc := c.datacenter(); dc != "" {
+		q.Set("dc", dc)
+	}
+	var body []byte
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return 0, err
+		}
+		body = b
+	}
+	scheme := "http"
+	if c.opts.Secure || c.opts.TLSConfig != nil {
+		scheme = "https"
+	}
+	addrs := c.opts.Addrs
+	if len(addrs) == 0 {
+		addrs = []string{DefaultAddress}
+	}
+	var err error
+	for _, a := range addrs {
+		u := url.URL{Scheme: scheme, Host: a, Path: "/v1/" + path, RawQuery: q.Encode()}
+		var req *http.Request
+		req, err = http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+		if err != nil {
+			return 0, err
+		}
+		if in != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if t := c.token(); t != "" {
+			req.Header.Set("X-Consul-Token", t)
+		}
+		var res *http.Response
+		res, err = c.http.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return 0, err
+			}
+			// try the next agent
+			continue
+		}
+		return decode(res, method, path, out)
+	}
+	return 0, fmt.Errorf("consul: %w", err)
+}
+
+func decode(res *http.Response, method, path string, out interface{}) (uint64, error) {
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		b, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+		return 0, fmt.Errorf("consul: %s %s: %s: %s", method, path, res.Status, strings.TrimSpace(string(b)))
+	}
+	index, _ := strconv.ParseUint(res.Header.Get("X-Consul-Index"), 10, 64)
+	if out == nil {
+		return index, nil
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return 0, fmt.Errorf("consul: %s %s: %w", method, path, err)
+	}
+	return index, nil
+}
+
+// toServices groups the health entries nodes by service name and version
+func toServices(entries []healthEntry) []*registry.Service {
+	var svcs []*registry.Service
+	idx := make(map[string]*registry.Service)
+	for _, e := range entries {
+		var version string
+		for _, t := range e.Service.Tags {
+			if strings.HasPrefix(t, TagVersion) {
+				version = strings.TrimPrefix(t, TagVersion)
+				break
+			}
+		}
+		key := e.Service.Service + ":" + version
+		s, ok := idx[key]
+		if !ok {
+			s = &registry.Service{Name: e.Service.Service, Version: version}
+			idx[key] = s
+			svcs = append(svcs, s)
+		}
+		host := e.Service.Address
+		if host == "" {
+			host = e.Node.Address
+		}
+		s.Nodes = append(s.Nodes, &registry.Node{
+			Id:       e.Service.ID,
+			Address:  net.JoinHostPort(host, strconv.Itoa(e.Service.Port)),
+			Metadata: e.Service.Meta,
+		})
+	}
+	return svcs
+}
+
+func splitAddress(addr string) (string, int, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", 0, fmt.Errorf("consul: invalid node address %q: %w", addr, err)
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		return "", 0, fmt.Errorf("consul: invalid node address %q: %w", addr, err)
+	}
+	return host, p, nil
+}
+
+func checkID(id string) string {
+	return "service:" + id
+}
+
+func sortedKeys(m map[string]bool) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
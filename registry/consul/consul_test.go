@@ -0,0 +1,233 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.linka.cloud/grpc/registry"
+)
+
+// agent is a minimal in-memory Consul agent
+type agent struct {
+	mu       sync.Mutex
+	index    uint64
+	changed  chan struct{}
+	services map[string]agentService
+	passes   int
+	purges   int
+}
+
+func newAgent() *agent {
+	return &agent{index: 1, changed: make(chan struct{}), services: make(map[string]agentService)}
+}
+
+func (a *agent) bump() {
+	a.index++
+	close(a.changed)
+	a.changed = make(chan struct{})
+}
+
+func (a *agent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p := r.URL.Path
+	switch {
+	case r.Method == http.MethodPut && p == "/v1/agent/service/register":
+		var s agentService
+		if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		a.mu.Lock()
+		a.services[s.ID] = s
+		a.bump()
+		a.mu.Unlock()
+	case r.Method == http.MethodPut && strings.HasPrefix(p, "/v1/agent/check/pass/service:"):
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if _, ok := a.services[strings.TrimPrefix(p, "/v1/agent/check/pass/service:")]; !ok {
+			http.Error(w, "unknown check", http.StatusNotFound)
+			return
+		}
+		a.passes++
+	case r.Method == http.MethodPut && strings.HasPrefix(p, "/v1/agent/service/deregister/"):
+		a.mu.Lock()
+		delete(a.services, strings.TrimPrefix(p, "/v1/agent/service/deregister/"))
+		a.bump()
+		a.mu.Unlock()
+	case r.Method == http.MethodGet && strings.HasPrefix(p, "/v1/health/service/"):
+		index, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64)
+		a.mu.Lock()
+		if index >= a.index {
+			ch := a.changed
+			a.mu.Unlock()
+			select {
+			case <-ch:
+			case <-r.Context().Done():
+				return
+			}
+			a.mu.Lock()
+		}
+		defer a.mu.Unlock()
+		var entries []healthEntry
+		for _, s := range a.services {
+			if s.Name != strings.TrimPrefix(p, "/v1/health/service/") {
+				continue
+			}
+			var e healthEntry
+			e.Service.ID, e.Service.Service, e.Service.Tags = s.ID, s.Name, s.Tags
+			e.Service.Address, e.Service.Port, e.Service.Meta = s.Address, s.Port, s.Meta
+			entries = append(entries, e)
+		}
+		w.Header().Set("X-Consul-Index", strconv.FormatUint(a.index, 10))
+		json.NewEncoder(w).Encode(entries)
+	case r.Method == http.MethodGet && strings.HasPrefix(p, "/v1/catalog/service/"):
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		var entries []catalogEntry
+		for _, s := range a.services {
+			if s.Name == strings.TrimPrefix(p, "/v1/catalog/service/") {
+				entries = append(entries, catalogEntry{Node: "node-1", ServiceID: s.ID})
+			}
+		}
+		json.NewEncoder(w).Encode(entries)
+	case r.Method == http.MethodPut && p == "/v1/catalog/deregister":
+		var d catalogDeregistration
+		if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if d.Node != "node-1" {
+			http.Error(w, "unknown node", http.StatusNotFound)
+			return
+		}
+		delete(a.services, d.ServiceID)
+		a.purges++
+		a.bump()
+	case r.Method == http.MethodGet && p == "/v1/catalog/services":
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		m := map[string][]string{"consul": nil}
+		for _, s := range a.services {
+			m[s.Name] = append(m[s.Name], s.Tags...)
+		}
+		json.NewEncoder(w).Encode(m)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func next(t *testing.T, w registry.Watcher) *registry.Result {
+	ch := make(chan *registry.Result, 1)
+	go func() {
+		r, err := w.Next()
+		assert.NoError(t, err)
+		ch <- r
+	}()
+	select {
+	case r := <-ch:
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("no watch result")
+		return nil
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	a := newAgent()
+	srv := httptest.NewServer(a)
+	defer srv.Close()
+	reg := NewRegistry(registry.Addrs(strings.TrimPrefix(srv.URL, "http://")), Tags("team=a"))
+
+	svc := &registry.Service{Name: "test", Version: "v1", Nodes: []*registry.Node{
+		{Id: "test-1", Address: "127.0.0.1:8888", Metadata: map[string]string{"instance_id": "1"}},
+	}}
+	require.NoError(t, reg.Register(svc, registry.RegisterTTL(time.Minute)))
+	a.mu.Lock()
+	s := a.services["test-1"]
+	a.mu.Unlock()
+	assert.Equal(t, []string{"version=v1", "protocol=grpc", "team=a"}, s.Tags)
+	assert.Equal(t, "127.0.0.1", s.Address)
+	assert.Equal(t, 8888, s.Port)
+	if assert.NotNil(t, s.Check) {
+		assert.Equal(t, "service:test-1", s.Check.CheckID)
+		assert.Equal(t, "1m0s", s.Check.TTL)
+		assert.Equal(t, "1m0s", s.Check.DeregisterCriticalServiceAfter)
+	}
+
+	// the unchanged registration only passes the TTL check
+	require.NoError(t, reg.Register(svc, registry.RegisterTTL(time.Minute)))
+	a.mu.Lock()
+	assert.Equal(t, 1, a.passes)
+	a.mu.Unlock()
+
+	svcs, err := reg.GetService("test")
+	require.NoError(t, err)
+	assert.Equal(t, []*registry.Service{svc}, svcs)
+
+	svcs, err = reg.ListServices()
+	require.NoError(t, err)
+	assert.Equal(t, []*registry.Service{{Name: "test", Version: "v1"}}, svcs)
+
+	w, err := reg.Watch(registry.WatchService("test"))
+	require.NoError(t, err)
+	r := next(t, w)
+	assert.Equal(t, registry.Create.String(), r.Action)
+	assert.Equal(t, "test-1", r.Service.Nodes[0].Id)
+
+	svc2 := &registry.Service{Name: "test", Version: "v1", Nodes: []*registry.Node{{Id: "test-2", Address: "127.0.0.1:8889"}}}
+	require.NoError(t, reg.Register(svc2))
+	r = next(t, w)
+	assert.Equal(t, registry.Create.String(), r.Action)
+	assert.Equal(t, "test-2", r.Service.Nodes[0].Id)
+
+	require.NoError(t, reg.Deregister(svc))
+	r = next(t, w)
+	assert.Equal(t, registry.Delete.String(), r.Action)
+	assert.Equal(t, "test-1", r.Service.Nodes[0].Id)
+
+	w.Stop()
+	_, err = w.Next()
+	assert.Equal(t, registry.ErrWatcherStopped, err)
+}
+
+func TestPurge(t *testing.T) {
+	a := newAgent()
+	srv := httptest.NewServer(a)
+	defer srv.Close()
+	reg := NewRegistry(registry.Addrs(strings.TrimPrefix(srv.URL, "http://")))
+	require.Implements(t, (*registry.Purger)(nil), reg)
+
+	svc := &registry.Service{Name: "test", Version: "v1", Nodes: []*registry.Node{
+		{Id: "test-1", Address: "127.0.0.1:8888"},
+		{Id: "test-2", Address: "127.0.0.1:8889"},
+	}}
+	require.NoError(t, reg.Register(svc))
+	j := registry.NewJanitor(reg, registry.WithJanitorFailures(1), registry.WithProbe(func(_ context.Context, _ *registry.Service, n *registry.Node) bool {
+		return n.Id == "test-1"
+	}))
+	purged, err := j.Purge(context.Background())
+	require.NoError(t, err)
+	require.Len(t, purged, 1)
+	require.Len(t, purged[0].Nodes, 1)
+	assert.Equal(t, "test-2", purged[0].Nodes[0].Id)
+
+	// the stale node is removed from the catalog rather than with the agent api
+	a.mu.Lock()
+	assert.Equal(t, 1, a.purges)
+	_, ok := a.services["test-2"]
+	assert.False(t, ok)
+	_, ok = a.services["test-1"]
+	assert.True(t, ok)
+	a.mu.Unlock()
+}
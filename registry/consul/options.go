@@ -0,0 +1,93 @@
+package consul
+
+import (
+	"context"
+	"time"
+
+	"go.linka.cloud/grpc/registry"
+)
+
+type tokenKey struct{}
+
+type datacenterKey struct{}
+
+type tagsKey struct{}
+
+type protocolKey struct{}
+
+type deregisterAfterKey struct{}
+
+type tcpCheckKey struct{}
+
+// Token sets the ACL token sent to the Consul agent
+func Token(token string) registry.Option {
+	return setOption(tokenKey{}, token)
+}
+
+// Datacenter sets the datacenter the services are looked up in, defaults to the agent one
+func Datacenter(dc string) registry.Option {
+	return setOption(datacenterKey{}, dc)
+}
+
+// Tags adds tags to the registered services, in addition to the version and protocol ones
+func Tags(tags ...string) registry.Option {
+	return setOption(tagsKey{}, tags)
+}
+
+// Protocol sets the protocol tag of the registered services, defaults to grpc
+func Protocol(protocol string) registry.Option {
+	return setOption(protocolKey{}, protocol)
+}
+
+// DeregisterCriticalAfter sets the duration after which Consul deregisters the services whose health check
+// is critical, e.g. the instances which crashed without deregistering, defaults to one minute
+func DeregisterCriticalAfter(d time.Duration) registry.Option {
+	return setOption(deregisterAfterKey{}, d)
+}
+
+// TCPCheck makes the Consul agent check that the registered address accepts TCP connections at the given
+// interval when the services are registered without TTL
+func TCPCheck(interval time.Duration) registry.Option {
+	return setOption(tcpCheckKey{}, interval)
+}
+
+func setOption(k, v interface{}) registry.Option {
+	return func(o *registry.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, k, v)
+	}
+}
+
+func (c *consulRegistry) token() string {
+	v, _ := c.opts.Context.Value(tokenKey{}).(string)
+	return v
+}
+
+func (c *consulRegistry) datacenter() string {
+	v, _ := c.opts.Context.Value(datacenterKey{}).(string)
+	return v
+}
+
+func (c *consulRegistry) tags(version string) []string {
+	p, _ := c.opts.Context.Value(protocolKey{}).(string)
+	if p == "" {
+		p = DefaultProtocol
+	}
+	tags := []string{TagVersion + version, TagProtocol + p}
+	v, _ := c.opts.Context.Value(tagsKey{}).([]string)
+	return append(tags, v...)
+}
+
+func (c *consulRegistry) deregisterAfter() time.Duration {
+	if v, ok := c.opts.Context.Value(deregisterAfterKey{}).(time.Duration); ok && v > 0 {
+		return v
+	}
+	return time.Minute
+}
+
+func (c *consulRegistry) tcpCheck() time.Duration {
+	v, _ := c.opts.Context.Value(tcpCheckKey{}).(time.Duration)
+	return v
+}
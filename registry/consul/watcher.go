@@ -0,0 +1,124 @@
+package consul
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"time"
+
+	"go.linka.cloud/grpc/registry"
+)
+
+// watchWait is the maximum duration of the blocking queries
+const watchWait = 5 * time.Minute
+
+// watcher uses blocking queries to diff the passing nodes and returns a result by changed node
+type watcher struct {
+	c       *consulRegistry
+	service string
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	index   uint64
+	nodes   map[string]*registry.Service
+	pending []*registry.Result
+}
+
+func newWatcher(c *consulRegistry, o registry.WatchOptions) *watcher {
+	ctx := o.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	return &watcher{c: c, service: o.Service, ctx: ctx, cancel: cancel, nodes: make(map[string]*registry.Service)}
+}
+
+func (w *watcher) Next() (*registry.Result, error) {
+	for {
+		if len(w.pending) != 0 {
+			r := w.pending[0]
+			w.pending = w.pending[1:]
+			return r, nil
+		}
+		svcs, index, err := w.fetch()
+		if w.ctx.Err() != nil {
+			return nil, registry.ErrWatcherStopped
+		}
+		if err != nil {
+			// the agent may be restarting
+			select {
+			case <-w.ctx.Done():
+				return nil, registry.ErrWatcherStopped
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		// the index may go backward, e.g. on a consul server restore, the blocking queries must then start again,
+		// it must not be zero to not busy loop
+		if index < w.index || index == 0 {
+			index = 1
+		}
+		w.index = index
+		w.diff(svcs)
+	}
+}
+
+// fetch returns the passing nodes of the watched service, or of all the services, once they changed
+func (w *watcher) fetch() ([]*registry.Service, uint64, error) {
+	if w.service != "" {
+		return w.c.service(w.ctx, w.service, w.index, watchWait)
+	}
+	// the health checks change whenever a service is registered, deregistered or its health changed
+	q := url.Values{}
+	if w.index != 0 {
+		q.Set("index", strconv.FormatUint(w.index, 10))
+		q.Set("wait", watchWait.String())
+	}
+	index, err := w.c.do(w.ctx, http.MethodGet, "health/state/any", q, nil, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	names, err := w.c.names(w.ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	var svcs []*registry.Service
+	for _, v := range names {
+		s, _, err := w.c.service(w.ctx, v.name, 0, 0)
+		if err != nil {
+			return nil, 0, err
+		}
+		svcs = append(svcs, s...)
+	}
+	return svcs, index, nil
+}
+
+// diff queues the results of the nodes which changed since the last fetch
+func (w *watcher) diff(svcs []*registry.Service) {
+	nodes := make(map[string]*registry.Service)
+	for _, s := range svcs {
+		for _, n := range s.Nodes {
+			v := &registry.Service{Name: s.Name, Version: s.Version, Metadata: s.Metadata, Nodes: []*registry.Node{n}}
+			nodes[n.Id] = v
+			old, ok := w.nodes[n.Id]
+			switch {
+			case !ok:
+				w.pending = append(w.pending, &registry.Result{Action: registry.Create.String(), Service: v})
+			case !reflect.DeepEqual(old, v):
+				w.pending = append(w.pending, &registry.Result{Action: registry.Update.String(), Service: v})
+			}
+		}
+	}
+	for id, v := range w.nodes {
+		if _, ok := nodes[id]; !ok {
+			w.pending = append(w.pending, &registry.Result{Action: registry.Delete.String(), Service: v})
+		}
+	}
+	w.nodes = nodes
+}
+
+func (w *watcher) Stop() {
+	w.cancel()
+}
@@ -0,0 +1,565 @@
+// Package etcd is a registry.Registry backed by etcd v3 through its JSON gateway.
+//
+// The nodes are registered under a lease kept alive in background, so that their keys are removed when
+// the lease expires, e.g. if the service crashed, and on Deregister.
+package etcd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/resolver"
+
+	"go.linka.cloud/grpc/logger"
+	"go.linka.cloud/grpc/registry"
+	resolver2 "go.linka.cloud/grpc/resolver"
+)
+
+const (
+	// DefaultAddress is the address of the local etcd member
+	DefaultAddress = "127.0.0.1:2379"
+	// DefaultPrefix is the prefix of the registry keys, the nodes are stored under prefix/service/node-id
+	DefaultPrefix = "/linka/registry/"
+	// DefaultLeaseTTL is the TTL of the leases of the services registered without TTL
+	DefaultLeaseTTL = 30 * time.Second
+)
+
+var errUnauthenticated = errors.New("etcd: unauthenticated")
+
+// NewRegistry returns a registry backed by the etcd members at the registry addresses, defaults to the local one
+func NewRegistry(opts ...registry.Option) registry.Registry {
+	options := registry.Options{
+		Context: context.Background(),
+		Timeout: 5 * time.Second,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	return &etcdRegistry{opts: options, http: newHTTPClient(options), leases: make(map[string]*lease)}
+}
+
+type etcdRegistry struct {
+	opts registry.Options
+	http *http.Client
+
+	mu    sync.Mutex
+	token string
+	// leases are the registered nodes leases by key
+	leases map[string]*lease
+}
+
+// lease is the lease of a registered node, kept alive until the node is deregistered
+type lease struct {
+	id     int64
+	hash   uint64
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// stop stops the keepalive and waits for it to return, so that the lease id does not change anymore
+func (l *lease) stop() {
+	l.cancel()
+	<-l.done
+}
+
+// int64s is an int64 encoded as a string, as the gateway does
+type int64s int64
+
+func (i int64s) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strconv.FormatInt(int64(i), 10))
+}
+
+func (i *int64s) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "" || s == "null" {
+		*i = 0
+		return nil
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	*i = int64s(v)
+	return nil
+}
+
+type keyValue struct {
+	Key            []byte `json:"key"`
+	Value          []byte `json:"value,omitempty"`
+	CreateRevision int64s `json:"create_revision,omitempty"`
+	ModRevision    int64s `json:"mod_revision,omitempty"`
+	Lease          int64s `json:"lease,omitempty"`
+}
+
+type header struct {
+	Revision int64s `json:"revision,omitempty"`
+}
+
+type rangeRequest struct {
+	Key      []byte `json:"key"`
+	RangeEnd []byte `json:"range_end,omitempty"`
+}
+
+type rangeResponse struct {
+	Header header     `json:"header"`
+	Kvs    []keyValue `json:"kvs"`
+}
+
+type leaseResponse struct {
+	ID  int64s `json:"ID"`
+	TTL int64s `json:"TTL"`
+}
+
+type gatewayError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *etcdRegistry) ResolverBuilder() resolver.Builder {
+	return resolver2.New(e)
+}
+
+func (e *etcdRegistry) Init(opts ...registry.Option) error {
+	for _, o := range opts {
+		o(&e.opts)
+	}
+	e.http = newHTTPClient(e.opts)
+	return nil
+}
+
+func (e *etcdRegistry) Options() registry.Options {
+	return e.opts
+}
+
+func (e *etcdRegistry) Register(s *registry.Service, opts ...registry.RegisterOption) error {
+	if len(s.Nodes) == 0 {
+		return errors.New("etcd: service has no nodes")
+	}
+	var o registry.RegisterOptions
+	for _, v := range opts {
+		v(&o)
+	}
+	ctx, cancel := e.context(o.Context)
+	defer cancel()
+	for _, n := range s.Nodes {
+		if err := e.register(ctx, s, n, e.leaseTTL(o.TTL)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// register puts the node under a new lease kept alive in background, or only keeps its lease alive
+// if the node did not change
+func (e *etcdRegistry) register(ctx context.Context, s *registry.Service, n *registry.Node, ttl time.Duration) error {
+	key := e.key(s.Name, n.Id)
+	value, err := json.Marshal(&registry.Service{Name: s.Name, Version: s.Version, Metadata: s.Metadata, Nodes: []*registry.Node{n}})
+	if err != nil {
+		return err
+	}
+	h := fnv.New64a()
+	h.Write(value)
+	sum := h.Sum64()
+	e.mu.Lock()
+	l, ok := e.leases[key]
+	e.mu.Unlock()
+	if ok && l.hash == sum {
+		if id := e.leaseID(l); e.keepAlive(ctx, id) == nil {
+			return nil
+		}
+	}
+	if ok {
+		// the keepalive must not put the previous value again
+		l.stop()
+	}
+	id, err := e.put(ctx, key, value, ttl)
+	if err != nil {
+		if ok {
+			e.mu.Lock()
+			delete(e.leases, key)
+			e.mu.Unlock()
+		}
+		return err
+	}
+	lctx, cancel := context.WithCancel(context.Background())
+	nl := &lease{id: id, hash: sum, cancel: cancel, done: make(chan struct{})}
+	e.mu.Lock()
+	e.leases[key] = nl
+	e.mu.Unlock()
+	// the key is attached to the new lease, revoking the previous one does not remove it
+	if ok {
+		e.revoke(ctx, e.leaseID(l))
+	}
+	go e.keepAliveLoop(lctx, nl, key, value, ttl)
+	return nil
+}
+
+// put grants a lease and puts the key under it, it returns the lease id
+func (e *etcdRegistry) put(ctx context.Context, key string, value []byte, ttl time.Duration) (int64, error) {
+	var l leaseResponse
+	if err := e.do(ctx, "lease/grant", map[string]interface{}{"TTL": int64s(seconds(ttl))}, &l); err != nil {
+		return 0, err
+	}
+	if err := e.do(ctx, "kv/put", keyValue{Key: []byte(key), Value: value, Lease: l.ID}, nil); err != nil {
+		e.revoke(ctx, int64(l.ID))
+		return 0, err
+	}
+	return int64(l.ID), nil
+}
+
+// keepAliveLoop refreshes the lease until ctx is done, the key is put again under a new lease if it expired,
+// e.g. after a network partition longer than the TTL
+func (e *etcdRegistry) keepAliveLoop(ctx context.Context, l *lease, key string, value []byte, ttl time.Duration) {
+	defer close(l.done)
+	t := time.NewTicker(ttl / 3)
+	defer t.Stop()
+	log := logger.C(e.opts.Context)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		// the requests are not canceled by stop, so that the lease id is always the one of the key
+		rctx, cancel := e.context(context.Background())
+		err := e.keepAlive(rctx, e.leaseID(l))
+		if err == errLeaseExpired {
+			var id int64
+			if id, err = e.put(rctx, key, value, ttl); err == nil {
+				e.mu.Lock()
+				l.id = id
+				e.mu.Unlock()
+			}
+		}
+		cancel()
+		if err != nil {
+			log.Warnf("etcd: failed to keep %s lease alive: %v", key, err)
+		}
+	}
+}
+
+var errLeaseExpired = errors.New("etcd: lease expired")
+
+// keepAlive refreshes the lease once
+func (e *etcdRegistry) keepAlive(ctx context.Context, id int64) error {
+	res, err := e.stream(ctx, "lease/keepalive", map[string]interface{}{"ID": int64s(id)})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	var msg struct {
+		Result *leaseResponse `json:"result"`
+		Error  *gatewayError  `json:"error"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&msg); err != nil {
+		return fmt.Errorf("etcd: lease keepalive: %w", err)
+	}
+	switch {
+	case msg.Error != nil:
+		return fmt.Errorf("etcd: lease keepalive: %s", msg.Error.Message)
+	case msg.Result == nil || msg.Result.TTL <= 0:
+		return errLeaseExpired
+	}
+	return nil
+}
+
+func (e *etcdRegistry) revoke(ctx context.Context, id int64) {
+	if err := e.do(ctx, "lease/revoke", map[string]interface{}{"ID": int64s(id)}, nil); err != nil {
+		logger.C(e.opts.Context).Debugf("etcd: failed to revoke lease %d: %v", id, err)
+	}
+}
+
+func (e *etcdRegistry) leaseID(l *lease) int64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return l.id
+}
+
+func (e *etcdRegistry) Deregister(s *registry.Service, opts ...registry.DeregisterOption) error {
+	var o registry.DeregisterOptions
+	for _, v := range opts {
+		v(&o)
+	}
+	ctx, cancel := e.context(o.Context)
+	defer cancel()
+	for _, n := range s.Nodes {
+		key := e.key(s.Name, n.Id)
+		e.mu.Lock()
+		l, ok := e.leases[key]
+		delete(e.leases, key)
+		e.mu.Unlock()
+		if ok {
+			l.stop()
+		}
+		if err := e.do(ctx, "kv/deleterange", rangeRequest{Key: []byte(key)}, nil); err != nil {
+			return err
+		}
+		if ok {
+			e.revoke(ctx, e.leaseID(l))
+		}
+	}
+	return nil
+}
+
+func (e *etcdRegistry) GetService(name string, opts ...registry.GetOption) ([]*registry.Service, error) {
+	var o registry.GetOptions
+	for _, v := range opts {
+		v(&o)
+	}
+	ctx, cancel := e.context(o.Context)
+	defer cancel()
+	svcs, err := e.list(ctx, e.key(name, ""))
+	if err != nil {
+		return nil, err
+	}
+	var out []*registry.Service
+	versions := make(map[string]*registry.Service)
+	for _, v := range svcs {
+		s, ok := versions[v.Version]
+		if !ok {
+			s = &registry.Service{Name: v.Name, Version: v.Version, Metadata: v.Metadata}
+			versions[v.Version] = s
+			out = append(out, s)
+		}
+		s.Nodes = append(s.Nodes, v.Nodes...)
+	}
+	return out, nil
+}
+
+// ListServices returns the registered services, once by version, without their nodes
+func (e *etcdRegistry) ListServices(opts ...registry.ListOption) ([]*registry.Service, error) {
+	var o registry.ListOptions
+	for _, v := range opts {
+		v(&o)
+	}
+	ctx, cancel := e.context(o.Context)
+	defer cancel()
+	svcs, err := e.list(ctx, e.prefix())
+	if err != nil {
+		return nil, err
+	}
+	var out []*registry.Service
+	seen := make(map[string]bool)
+	for _, v := range svcs {
+		if k := v.Name + ":" + v.Version; !seen[k] {
+			seen[k] = true
+			out = append(out, &registry.Service{Name: v.Name, Version: v.Version})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Name != out[j].Name {
+			return out[i].Name < out[j].Name
+		}
+		return out[i].Version < out[j].Version
+	})
+	return out, nil
+}
+
+// list returns the nodes registered under the prefix, sorted by key
+func (e *etcdRegistry) list(ctx context.Context, prefix string) ([]*registry.Service, error) {
+	var res rangeResponse
+	if err := e.do(ctx, "kv/range", rangeRequest{Key: []byte(prefix), RangeEnd: prefixEnd(prefix)}, &res); err != nil {
+		return nil, err
+	}
+	out := make([]*registry.Service, 0, len(res.Kvs))
+	for _, v := range res.Kvs {
+		var s registry.Service
+		if err := json.Unmarshal(v.Value, &s); err != nil {
+			continue
+		}
+		out = append(out, &s)
+	}
+	return out, nil
+}
+
+func (e *etcdRegistry) Watch(opts ...registry.WatchOption) (registry.Watcher, error) {
+	var o registry.WatchOptions
+	for _, v := range opts {
+		v(&o)
+	}
+	prefix := e.prefix()
+	if o.Service != "" {
+		prefix = e.key(o.Service, "")
+	}
+	return newWatcher(e, o.Context, prefix), nil
+}
+
+func (e *etcdRegistry) String() string {
+	return "etcd"
+}
+
+func (e *etcdRegistry) key(service, id string) string {
+	return strings.TrimSuffix(e.prefix(), "/") + "/" + service + "/" + id
+}
+
+// context returns the request context, bound by the registry timeout
+func (e *etcdRegistry) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if e.opts.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, e.opts.Timeout)
+}
+
+func newHTTPClient(o registry.Options) *http.Client {
+	if o.TLSConfig == nil {
+		return http.DefaultClient
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = o.TLSConfig
+	return &http.Client{Transport: t}
+}
+
+// do sends the request and decodes the response into out if not nil
+func (e *etcdRegistry) do(ctx context.Context, path string, in, out interface{}) error {
+	res, err := e.stream(ctx, path, in)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("etcd: %s: %w", path, err)
+	}
+	return nil
+}
+
+// stream sends the request, authenticating first if needed, and returns the response, which body must be closed
+func (e *etcdRegistry) stream(ctx context.Context, path string, in interface{}) (*http.Response, error) {
+	res, err := e.send(ctx, path, in)
+	if err != errUnauthenticated {
+		return res, err
+	}
+	// the token may have expired
+	e.mu.Lock()
+	e.token = ""
+	e.mu.Unlock()
+	return e.send(ctx, path, in)
+}
+
+func (e *etcdRegistry) send(ctx context.Context, path string, in interface{}) (*http.Response, error) {
+	token, err := e.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return e.post(ctx, path, in, token)
+}
+
+// authenticate returns the authentication token, if the credentials are set
+func (e *etcdRegistry) authenticate(ctx context.Context) (string, error) {
+	c, ok := e.credentials()
+	if !ok {
+		return "", nil
+	}
+	e.mu.Lock()
+	token := e.token
+	e.mu.Unlock()
+	if token != "" {
+		return token, nil
+	}
+	res, err := e.post(ctx, "auth/authenticate", map[string]string{"name": c.username, "password": c.password}, "")
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	var auth struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&auth); err != nil {
+		return "", fmt.Errorf("etcd: authenticate: %w", err)
+	}
+	e.mu.Lock()
+	e.token = auth.Token
+	e.mu.Unlock()
+	return auth.Token, nil
+}
+
+// post sends the request to the first reachable member
+func (e *etcdRegistry) post(ctx context.Context, path string, in interface{}, token string) (*http.Response, error) {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	scheme := "http"
+	if e.opts.Secure || e.opts.TLSConfig != nil {
+		scheme = "https"
+	}
+	addrs := e.opts.Addrs
+	if len(addrs) == 0 {
+		addrs = []string{DefaultAddress}
+	}
+	for _, a := range addrs {
+		u := url.URL{Scheme: scheme, Host: a, Path: "/v3/" + path}
+		var req *http.Request
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", token)
+		}
+		var res *http.Response
+		res, err = e.http.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			// try the next member
+			continue
+		}
+		if res.StatusCode == http.StatusOK {
+			return res, nil
+		}
+		defer res.Body.Close()
+		body, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+		var gerr gatewayError
+		if json.Unmarshal(body, &gerr) != nil || gerr.Message == "" {
+			gerr.Message = strings.TrimSpace(string(body))
+		}
+		// the grpc Unauthenticated code
+		if res.StatusCode == http.StatusUnauthorized || gerr.Code == 16 {
+			return nil, errUnauthenticated
+		}
+		return nil, fmt.Errorf("etcd: %s: %s: %s", path, res.Status, gerr.Message)
+	}
+	return nil, fmt.Errorf("etcd: %w", err)
+}
+
+// prefixEnd returns the range end matching all the keys with the prefix
+func prefixEnd(prefix string) []byte {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	// all the keys
+	return []byte{0}
+}
+
+func seconds(d time.Duration) int64 {
+	if s := int64(math.Ceil(d.Seconds())); s > 0 {
+		return s
+	}
+	return 1
+}
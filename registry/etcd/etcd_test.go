@@ -0,0 +1,235 @@
+package etcd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.linka.cloud/grpc/registry"
+)
+
+// gateway is a minimal in-memory etcd v3 JSON gateway
+type gateway struct {
+	mu         sync.Mutex
+	rev        int64
+	lease      int64
+	kvs        map[string]keyValue
+	leases     map[int64]bool
+	keepAlives int
+	watchers   []chan event
+}
+
+func newGateway() *gateway {
+	return &gateway{kvs: make(map[string]keyValue), leases: make(map[int64]bool)}
+}
+
+func (g *gateway) notify(e event) {
+	for _, w := range g.watchers {
+		w <- e
+	}
+}
+
+func (g *gateway) delete(key string) {
+	kv, ok := g.kvs[key]
+	if !ok {
+		return
+	}
+	g.rev++
+	delete(g.kvs, key)
+	g.notify(event{Type: "DELETE", Kv: keyValue{Key: kv.Key, ModRevision: int64s(g.rev)}, PrevKv: &kv})
+}
+
+// expire removes the lease and its keys, as etcd does once its TTL elapsed
+func (g *gateway) expire(id int64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.leases, id)
+	for k, v := range g.kvs {
+		if int64(v.Lease) == id {
+			g.delete(k)
+		}
+	}
+}
+
+func (g *gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		keyValue
+		RangeEnd      []byte `json:"range_end"`
+		ID            int64s `json:"ID"`
+		TTL           int64s `json:"TTL"`
+		CreateRequest *struct {
+			Key []byte `json:"key"`
+		} `json:"create_request"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	g.mu.Lock()
+	switch r.URL.Path {
+	case "/v3/lease/grant":
+		g.lease++
+		g.leases[g.lease] = true
+		json.NewEncoder(w).Encode(leaseResponse{ID: int64s(g.lease), TTL: req.TTL})
+	case "/v3/lease/keepalive":
+		g.keepAlives++
+		var ttl int64s
+		if g.leases[int64(req.ID)] {
+			ttl = 1
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"result": leaseResponse{ID: req.ID, TTL: ttl}})
+	case "/v3/lease/revoke":
+		g.mu.Unlock()
+		g.expire(int64(req.ID))
+		g.mu.Lock()
+		json.NewEncoder(w).Encode(struct{}{})
+	case "/v3/kv/put":
+		g.rev++
+		kv := req.keyValue
+		kv.ModRevision, kv.CreateRevision = int64s(g.rev), int64s(g.rev)
+		if old, ok := g.kvs[string(kv.Key)]; ok {
+			kv.CreateRevision = old.CreateRevision
+		}
+		g.kvs[string(kv.Key)] = kv
+		g.notify(event{Kv: kv})
+		json.NewEncoder(w).Encode(struct{}{})
+	case "/v3/kv/range":
+		res := rangeResponse{Header: header{Revision: int64s(g.rev)}}
+		for k, v := range g.kvs {
+			if k >= string(req.Key) && k < string(req.RangeEnd) {
+				res.Kvs = append(res.Kvs, v)
+			}
+		}
+		json.NewEncoder(w).Encode(res)
+	case "/v3/kv/deleterange":
+		g.delete(string(req.Key))
+		json.NewEncoder(w).Encode(struct{}{})
+	case "/v3/watch":
+		ch := make(chan event, 16)
+		g.watchers = append(g.watchers, ch)
+		json.NewEncoder(w).Encode(map[string]interface{}{"result": map[string]interface{}{"created": true}})
+		w.(http.Flusher).Flush()
+		g.mu.Unlock()
+		for {
+			select {
+			case e := <-ch:
+				if !bytes.HasPrefix(e.Kv.Key, req.CreateRequest.Key) {
+					continue
+				}
+				g.mu.Lock()
+				rev := g.rev
+				g.mu.Unlock()
+				json.NewEncoder(w).Encode(map[string]interface{}{"result": map[string]interface{}{
+					"header": header{Revision: int64s(rev)},
+					"events": []event{e},
+				}})
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	default:
+		http.NotFound(w, r)
+	}
+	g.mu.Unlock()
+}
+
+func next(t *testing.T, w registry.Watcher) *registry.Result {
+	ch := make(chan *registry.Result, 1)
+	go func() {
+		r, err := w.Next()
+		assert.NoError(t, err)
+		ch <- r
+	}()
+	select {
+	case r := <-ch:
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("no watch result")
+		return nil
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	g := newGateway()
+	srv := httptest.NewServer(g)
+	defer srv.Close()
+	reg := NewRegistry(registry.Addrs(strings.TrimPrefix(srv.URL, "http://")), Prefix("/test/"))
+
+	svc := &registry.Service{Name: "test", Version: "v1", Nodes: []*registry.Node{
+		{Id: "test-1", Address: "127.0.0.1:8888", Metadata: map[string]string{"instance_id": "1"}},
+	}}
+	require.NoError(t, reg.Register(svc, registry.RegisterTTL(300*time.Millisecond)))
+	g.mu.Lock()
+	kv, ok := g.kvs["/test/test/test-1"]
+	g.mu.Unlock()
+	require.True(t, ok)
+	assert.Equal(t, int64s(1), kv.Lease)
+
+	// the unchanged registration only keeps the lease alive
+	require.NoError(t, reg.Register(svc, registry.RegisterTTL(300*time.Millisecond)))
+	g.mu.Lock()
+	assert.Equal(t, int64(1), g.lease)
+	assert.Greater(t, g.keepAlives, 0)
+	g.mu.Unlock()
+
+	svcs, err := reg.GetService("test")
+	require.NoError(t, err)
+	assert.Equal(t, []*registry.Service{svc}, svcs)
+
+	svcs, err = reg.ListServices()
+	require.NoError(t, err)
+	assert.Equal(t, []*registry.Service{{Name: "test", Version: "v1"}}, svcs)
+
+	w, err := reg.Watch(registry.WatchService("test"))
+	require.NoError(t, err)
+	r := next(t, w)
+	assert.Equal(t, registry.Create.String(), r.Action)
+	assert.Equal(t, "test-1", r.Service.Nodes[0].Id)
+
+	svc2 := &registry.Service{Name: "test", Version: "v1", Nodes: []*registry.Node{{Id: "test-2", Address: "127.0.0.1:8889"}}}
+	require.NoError(t, reg.Register(svc2))
+	r = next(t, w)
+	assert.Equal(t, registry.Create.String(), r.Action)
+	assert.Equal(t, "test-2", r.Service.Nodes[0].Id)
+
+	// the lease is kept alive in background and the node registered again once its lease expired
+	g.expire(1)
+	r = next(t, w)
+	assert.Equal(t, registry.Delete.String(), r.Action)
+	assert.Equal(t, "test-1", r.Service.Nodes[0].Id)
+	r = next(t, w)
+	assert.Equal(t, registry.Create.String(), r.Action)
+	assert.Equal(t, "test-1", r.Service.Nodes[0].Id)
+	g.mu.Lock()
+	assert.Greater(t, g.keepAlives, 1)
+	g.mu.Unlock()
+
+	require.NoError(t, reg.Deregister(svc))
+	r = next(t, w)
+	assert.Equal(t, registry.Delete.String(), r.Action)
+	assert.Equal(t, "test-1", r.Service.Nodes[0].Id)
+	g.mu.Lock()
+	_, ok = g.kvs["/test/test/test-1"]
+	assert.False(t, ok)
+	assert.Len(t, g.leases, 1)
+	g.mu.Unlock()
+
+	w.Stop()
+	_, err = w.Next()
+	assert.Equal(t, registry.ErrWatcherStopped, err)
+}
+
+func TestPrefixEnd(t *testing.T) {
+	assert.Equal(t, []byte("/test0"), prefixEnd("/test/"))
+	assert.Equal(t, []byte("b"), prefixEnd("a\xff"))
+	assert.Equal(t, []byte{0}, prefixEnd("\xff"))
+}
@@ -0,0 +1,65 @@
+package etcd
+
+import (
+	"context"
+	"time"
+
+	"go.linka.cloud/grpc/registry"
+)
+
+type prefixKey struct{}
+
+type authKey struct{}
+
+type leaseTTLKey struct{}
+
+type credentials struct {
+	username string
+	password string
+}
+
+// Prefix sets the prefix of the registry keys, defaults to DefaultPrefix
+func Prefix(prefix string) registry.Option {
+	return setOption(prefixKey{}, prefix)
+}
+
+// Auth sets the credentials used to authenticate against etcd when its authentication is enabled
+func Auth(username, password string) registry.Option {
+	return setOption(authKey{}, credentials{username: username, password: password})
+}
+
+// LeaseTTL sets the TTL of the leases of the services registered without TTL, defaults to DefaultLeaseTTL
+func LeaseTTL(ttl time.Duration) registry.Option {
+	return setOption(leaseTTLKey{}, ttl)
+}
+
+func setOption(k, v interface{}) registry.Option {
+	return func(o *registry.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, k, v)
+	}
+}
+
+func (e *etcdRegistry) prefix() string {
+	if v, ok := e.opts.Context.Value(prefixKey{}).(string); ok && v != "" {
+		return v
+	}
+	return DefaultPrefix
+}
+
+func (e *etcdRegistry) credentials() (credentials, bool) {
+	v, ok := e.opts.Context.Value(authKey{}).(credentials)
+	return v, ok
+}
+
+func (e *etcdRegistry) leaseTTL(ttl time.Duration) time.Duration {
+	if ttl > 0 {
+		return ttl
+	}
+	if v, ok := e.opts.Context.Value(leaseTTLKey{}).(time.Duration); ok && v > 0 {
+		return v
+	}
+	return DefaultLeaseTTL
+}
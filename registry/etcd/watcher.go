@@ -0,0 +1,211 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"go.linka.cloud/grpc/registry"
+)
+
+// watcher lists the nodes under the prefix, then follows the etcd watch events from the listed revision
+// and returns a result by changed node
+type watcher struct {
+	e      *etcdRegistry
+	prefix string
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// rev is the last seen revision, zero if the nodes must be listed again
+	rev     int64
+	res     *http.Response
+	dec     *json.Decoder
+	nodes   map[string]*registry.Service
+	pending []*registry.Result
+}
+
+type event struct {
+	Type   string    `json:"type"`
+	Kv     keyValue  `json:"kv"`
+	PrevKv *keyValue `json:"prev_kv"`
+}
+
+type watchResponse struct {
+	Result *struct {
+		Header          header  `json:"header"`
+		Canceled        bool    `json:"canceled"`
+		CompactRevision int64s  `json:"compact_revision"`
+		CancelReason    string  `json:"cancel_reason"`
+		Events          []event `json:"events"`
+	} `json:"result"`
+	Error *gatewayError `json:"error"`
+}
+
+func newWatcher(e *etcdRegistry, ctx context.Context, prefix string) *watcher {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	return &watcher{e: e, prefix: prefix, ctx: ctx, cancel: cancel, nodes: make(map[string]*registry.Service)}
+}
+
+func (w *watcher) Next() (*registry.Result, error) {
+	for {
+		if len(w.pending) != 0 {
+			r := w.pending[0]
+			w.pending = w.pending[1:]
+			return r, nil
+		}
+		err := w.next()
+		if w.ctx.Err() != nil {
+			w.close()
+			return nil, registry.ErrWatcherStopped
+		}
+		if err != nil {
+			w.close()
+			// the member may be restarting
+			select {
+			case <-w.ctx.Done():
+				return nil, registry.ErrWatcherStopped
+			case <-time.After(time.Second):
+			}
+		}
+	}
+}
+
+// next lists the nodes or opens the watch stream if needed, then queues the results of the next watch response
+func (w *watcher) next() error {
+	if w.rev == 0 {
+		if err := w.list(); err != nil {
+			return err
+		}
+	}
+	if w.dec == nil {
+		req := map[string]interface{}{
+			"create_request": map[string]interface{}{
+				"key":            []byte(w.prefix),
+				"range_end":      prefixEnd(w.prefix),
+				"start_revision": int64s(w.rev + 1),
+				"prev_kv":        true,
+			},
+		}
+		res, err := w.e.stream(w.ctx, "watch", req)
+		if err != nil {
+			return err
+		}
+		w.res, w.dec = res, json.NewDecoder(res.Body)
+	}
+	var msg watchResponse
+	if err := w.dec.Decode(&msg); err != nil {
+		return err
+	}
+	switch {
+	case msg.Error != nil:
+		return fmt.Errorf("etcd: watch: %s", msg.Error.Message)
+	case msg.Result == nil:
+		return nil
+	case msg.Result.Canceled:
+		// the revision was compacted, the nodes must be listed again
+		w.close()
+		w.rev = 0
+		if msg.Result.CompactRevision == 0 {
+			return fmt.Errorf("etcd: watch canceled: %s", msg.Result.CancelReason)
+		}
+		return nil
+	}
+	for _, v := range msg.Result.Events {
+		w.event(v)
+	}
+	if rev := int64(msg.Result.Header.Revision); rev > w.rev {
+		w.rev = rev
+	}
+	return nil
+}
+
+// list diffs the nodes under the prefix with the known ones
+func (w *watcher) list() error {
+	var res rangeResponse
+	if err := w.e.do(w.ctx, "kv/range", rangeRequest{Key: []byte(w.prefix), RangeEnd: prefixEnd(w.prefix)}, &res); err != nil {
+		return err
+	}
+	nodes := make(map[string]*registry.Service)
+	for _, v := range res.Kvs {
+		s, ok := decode(v)
+		if !ok {
+			continue
+		}
+		k := string(v.Key)
+		nodes[k] = s
+		old, ok := w.nodes[k]
+		switch {
+		case !ok:
+			w.queue(registry.Create, s)
+		case !reflect.DeepEqual(old, s):
+			w.queue(registry.Update, s)
+		}
+	}
+	for k, v := range w.nodes {
+		if _, ok := nodes[k]; !ok {
+			w.queue(registry.Delete, v)
+		}
+	}
+	w.nodes = nodes
+	w.rev = int64(res.Header.Revision)
+	return nil
+}
+
+func (w *watcher) event(e event) {
+	k := string(e.Kv.Key)
+	if e.Type == "DELETE" {
+		s, ok := w.nodes[k]
+		if !ok && e.PrevKv != nil {
+			s, ok = decode(*e.PrevKv)
+		}
+		if !ok {
+			return
+		}
+		delete(w.nodes, k)
+		w.queue(registry.Delete, s)
+		return
+	}
+	// the put events type is omitted as it is the zero value
+	s, ok := decode(e.Kv)
+	if !ok {
+		return
+	}
+	_, known := w.nodes[k]
+	w.nodes[k] = s
+	if known || e.Kv.CreateRevision != e.Kv.ModRevision {
+		w.queue(registry.Update, s)
+		return
+	}
+	w.queue(registry.Create, s)
+}
+
+func (w *watcher) queue(a registry.EventType, s *registry.Service) {
+	w.pending = append(w.pending, &registry.Result{Action: a.String(), Service: s})
+}
+
+func (w *watcher) close() {
+	if w.res != nil {
+		w.res.Body.Close()
+	}
+	w.res, w.dec = nil, nil
+}
+
+func (w *watcher) Stop() {
+	w.cancel()
+}
+
+// decode returns the node stored in the key value
+func decode(kv keyValue) (*registry.Service, bool) {
+	var s registry.Service
+	if err := json.Unmarshal(kv.Value, &s); err != nil || len(s.Nodes) == 0 || strings.TrimSpace(s.Name) == "" {
+		return nil, false
+	}
+	return &s, true
+}
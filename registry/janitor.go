@@ -0,0 +1,202 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"go.linka.cloud/grpc/logger"
+)
+
+const (
+	defaultJanitorInterval = time.Minute
+	defaultJanitorFailures = 3
+	defaultProbeTimeout    = 5 * time.Second
+)
+
+// Probe reports whether a registered node is alive
+type Probe func(ctx context.Context, s *Service, n *Node) bool
+
+// DialProbe returns a Probe dialing the node address over tcp
+func DialProbe(timeout time.Duration) Probe {
+	return func(ctx context.Context, s *Service, n *Node) bool {
+		d := net.Dialer{Timeout: timeout}
+		c, err := d.DialContext(ctx, "tcp", n.Address)
+		if err != nil {
+			return false
+		}
+		c.Close()
+		return true
+	}
+}
+
+type JanitorOption func(o *janitorOptions)
+
+// WithProbe sets the liveness probe of the nodes, defaults to a tcp dial with a 5 seconds timeout
+func WithProbe(p Probe) JanitorOption {
+	return func(o *janitorOptions) {
+		o.probe = p
+	}
+}
+
+// WithJanitorInterval sets the interval between two purges, defaults to 1 minute
+func WithJanitorInterval(d time.Duration) JanitorOption {
+	return func(o *janitorOptions) {
+		o.interval = d
+	}
+}
+
+// WithJanitorFailures sets the number of consecutive failed probes before a node is purged, defaults to 3
+func WithJanitorFailures(n int) JanitorOption {
+	return func(o *janitorOptions) {
+		o.failures = n
+	}
+}
+
+// WithJanitorServices restricts the purges to the named services, all the services are purged by default
+func WithJanitorServices(names ...string) JanitorOption {
+	return func(o *janitorOptions) {
+		o.services = names
+	}
+}
+
+type janitorOptions struct {
+	probe    Probe
+	interval time.Duration
+	failures int
+	services []string
+}
+
+// Purger is implemented by the registries which cannot remove the records of the other instances with Deregister,
+// e.g. the Consul agents only deregister the services they registered
+type Purger interface {
+	// Purge removes the nodes of the service whichever instance registered them,
+	// it returns ErrPurgeNotSupported if the registry cannot remove them
+	Purge(s *Service) error
+}
+
+// Janitor purges the stale nodes, i.e. the ones of the crashed instances, from the registries which
+// do not expire the records with their TTL. It should run on a single instance, e.g. the leader.
+//
+// The nodes are removed with Purge if the registry is a Purger, with Deregister otherwise:
+//   - etcd: Deregister deletes the nodes keys, whichever client registered them
+//   - consul: Purge deregisters the nodes from the catalog, see its documentation
+//   - mdns: the nodes advertised by the other instances cannot be removed, Purge fails with ErrPurgeNotSupported,
+//     but the crashed instances do not answer the queries anymore and their records are not listed
+type Janitor struct {
+	r    Registry
+	opts janitorOptions
+
+	mu       sync.Mutex
+	failures map[string]int
+}
+
+// NewJanitor returns a Janitor purging the stale nodes of r
+func NewJanitor(r Registry, opts ...JanitorOption) *Janitor {
+	o := janitorOptions{
+		probe:    DialProbe(defaultProbeTimeout),
+		interval: defaultJanitorInterval,
+		failures: defaultJanitorFailures,
+	}
+	for _, v := range opts {
+		v(&o)
+	}
+	if o.failures < 1 {
+		o.failures = 1
+	}
+	return &Janitor{r: r, opts: o, failures: make(map[string]int)}
+}
+
+// Purge probes the registered nodes and deregisters the ones which failed the consecutive probes threshold,
+// it returns the purged services, each one with its purged nodes
+func (j *Janitor) Purge(ctx context.Context) ([]*Service, error) {
+	names := j.opts.services
+	if len(names) == 0 {
+		ss, err := j.r.ListServices()
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range ss {
+			names = append(names, v.Name)
+		}
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	seen := make(map[string]bool)
+	var purged []*Service
+	for _, name := range names {
+		ss, err := j.r.GetService(name)
+		// most registries return no service rather than ErrNotFound
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return purged, err
+		}
+		for _, s := range ss {
+			p := &Service{Name: s.Name, Version: s.Version, Metadata: s.Metadata}
+			for _, n := range s.Nodes {
+				key := s.Name + "/" + n.Id
+				seen[key] = true
+				if j.opts.probe(ctx, s, n) {
+					delete(j.failures, key)
+					continue
+				}
+				j.failures[key]++
+				if j.failures[key] < j.opts.failures {
+					continue
+				}
+				p.Nodes = append(p.Nodes, n)
+			}
+			if len(p.Nodes) == 0 {
+				continue
+			}
+			if err := j.purge(p); err != nil {
+				return purged, err
+			}
+			for _, n := range p.Nodes {
+				delete(j.failures, s.Name+"/"+n.Id)
+			}
+			purged = append(purged, p)
+		}
+	}
+	// forget the nodes which are gone
+	for k := range j.failures {
+		if !seen[k] {
+			delete(j.failures, k)
+		}
+	}
+	return purged, nil
+}
+
+func (j *Janitor) purge(s *Service) error {
+	if p, ok := j.r.(Purger); ok {
+		return p.Purge(s)
+	}
+	return j.r.Deregister(s)
+}
+
+// Run purges the stale nodes periodically until ctx is done, e.g. as a service background task:
+// svc.Go("janitor", janitor.Run). The purge errors are logged and retried at the next interval.
+func (j *Janitor) Run(ctx context.Context) error {
+	t := time.NewTicker(j.opts.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+			ss, err := j.Purge(ctx)
+			if err != nil {
+				logger.C(ctx).Warnf("registry janitor: purge failed: %v", err)
+			}
+			for _, v := range ss {
+				for _, n := range v.Nodes {
+					logger.C(ctx).Infof("registry janitor: purged %s node %s (%s)", v.Name, n.Id, n.Address)
+				}
+			}
+		}
+	}
+}
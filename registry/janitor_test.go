@@ -0,0 +1,99 @@
+package registry
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memory is a minimal in-memory Registry
+type memory struct {
+	Registry
+	mu       sync.Mutex
+	services map[string]*Service
+}
+
+func (m *memory) Deregister(s *Service, _ ...DeregisterOption) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.services[s.Name]
+	if !ok {
+		return ErrNotFound
+	}
+	var nodes []*Node
+	for _, n := range e.Nodes {
+		keep := true
+		for _, v := range s.Nodes {
+			if v.Id == n.Id {
+				keep = false
+			}
+		}
+		if keep {
+			nodes = append(nodes, n)
+		}
+	}
+	e.Nodes = nodes
+	return nil
+}
+
+func (m *memory) GetService(name string, _ ...GetOption) ([]*Service, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.services[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return []*Service{{Name: s.Name, Version: s.Version, Nodes: append([]*Node(nil), s.Nodes...)}}, nil
+}
+
+func (m *memory) ListServices(_ ...ListOption) ([]*Service, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*Service
+	for _, v := range m.services {
+		out = append(out, &Service{Name: v.Name})
+	}
+	return out, nil
+}
+
+func TestJanitor(t *testing.T) {
+	r := &memory{services: map[string]*Service{
+		"a": {Name: "a", Nodes: []*Node{{Id: "a-1", Address: "alive"}, {Id: "a-2", Address: "dead"}}},
+		"b": {Name: "b", Nodes: []*Node{{Id: "b-1", Address: "flaky"}}},
+	}}
+	flaky := true
+	j := NewJanitor(r, WithJanitorFailures(2), WithProbe(func(ctx context.Context, s *Service, n *Node) bool {
+		switch n.Address {
+		case "alive":
+			return true
+		case "flaky":
+			flaky = !flaky
+			return flaky
+		}
+		return false
+	}))
+	ctx := context.Background()
+
+	purged, err := j.Purge(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, purged)
+
+	// the nodes are purged after consecutive failures only
+	purged, err = j.Purge(ctx)
+	require.NoError(t, err)
+	require.Len(t, purged, 1)
+	assert.Equal(t, "a", purged[0].Name)
+	require.Len(t, purged[0].Nodes, 1)
+	assert.Equal(t, "a-2", purged[0].Nodes[0].Id)
+
+	ss, err := r.GetService("a")
+	require.NoError(t, err)
+	require.Len(t, ss[0].Nodes, 1)
+	assert.Equal(t, "a-1", ss[0].Nodes[0].Id)
+	ss, err = r.GetService("b")
+	require.NoError(t, err)
+	assert.Len(t, ss[0].Nodes, 1)
+}
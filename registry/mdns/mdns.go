@@ -33,7 +33,9 @@ type mdnsTxt struct {
 }
 
 type mdnsEntry struct {
-	id   string
+	id string
+	// key is the advertised address and txt record, the node is advertised again if it changed
+	key  string
 	node *Server
 }
 
@@ -58,10 +60,6 @@ func (m *mdnsRegistry) ResolverBuilder() resolver.Builder {
 	return resolver2.New(m)
 }
 
-func (m *mdnsRegistry) Scheme() string {
-	panic("implement me")
-}
-
 type mdnsWatcher struct {
 	id   string
 	wo   registry.WatchOptions
@@ -148,8 +146,7 @@ func newRegistry(opts ...registry.Option) registry.Registry {
 	// set the domain
 	domain := mdnsDomain
 
-	d, ok := options.Context.Value("domain").(string)
-	if ok {
+	if d, ok := options.Context.Value(domainKey{}).(string); ok && d != "" {
 		domain = d
 	}
 
@@ -205,35 +202,35 @@ func (m *mdnsRegistry) Register(service *registry.Service, opts ...registry.Regi
 	var gerr error
 
 	for _, node := range service.Nodes {
-		var seen bool
-		var e *mdnsEntry
-
-		for _, entry := range entries {
-			if node.Id == entry.id {
-				seen = true
-				e = entry
-				break
-			}
-		}
-
-		// already registered, continue
-		if seen {
-			continue
-			// doesn't exist
-		} else {
-			e = &mdnsEntry{}
-		}
-
 		txt, err := encode(&mdnsTxt{
 			Service:  service.Name,
 			Version:  service.Version,
 			Metadata: node.Metadata,
 		})
-
 		if err != nil {
 			gerr = err
 			continue
 		}
+		key := node.Address + ";" + strings.Join(txt, "")
+
+		var e *mdnsEntry
+		for i, entry := range entries {
+			if node.Id != entry.id {
+				continue
+			}
+			e = entry
+			// changed, e.g. a new version or address, stop advertising the previous record
+			if entry.key != key {
+				entry.node.Shutdown()
+				entries = append(entries[:i], entries[i+1:]...)
+			}
+			break
+		}
+
+		// already registered, continue
+		if e != nil && e.key == key {
+			continue
+		}
 
 		host, pt, err := net.SplitHostPort(node.Address)
 		if err != nil {
@@ -242,6 +239,12 @@ func (m *mdnsRegistry) Register(service *registry.Service, opts ...registry.Regi
 		}
 		port, _ := strconv.Atoi(pt)
 
+		// advertise the host addresses if the node listens on all the interfaces
+		var ips []net.IP
+		if ip := net.ParseIP(host); ip != nil && !ip.IsUnspecified() {
+			ips = []net.IP{ip}
+		}
+
 		// we got here, new node
 		s, err := NewMDNSService(
 			node.Id,
@@ -249,7 +252,7 @@ func (m *mdnsRegistry) Register(service *registry.Service, opts ...registry.Regi
 			m.domain+".",
 			"",
 			port,
-			[]net.IP{net.ParseIP(host)},
+			ips,
 			txt,
 		)
 		if err != nil {
@@ -263,9 +266,7 @@ func (m *mdnsRegistry) Register(service *registry.Service, opts ...registry.Regi
 			continue
 		}
 
-		e.id = node.Id
-		e.node = srv
-		entries = append(entries, e)
+		entries = append(entries, &mdnsEntry{id: node.Id, key: key, node: srv})
 	}
 
 	// save
@@ -309,6 +310,36 @@ func (m *mdnsRegistry) Deregister(service *registry.Service, opts ...registry.De
 	return nil
 }
 
+// Purge stops advertising the nodes advertised by this registry, the nodes advertised by the other instances
+// cannot be removed: it fails with registry.ErrPurgeNotSupported if some of the nodes are not local ones.
+// The crashed instances do not answer the queries anymore, their nodes are not returned by GetService.
+func (m *mdnsRegistry) Purge(service *registry.Service) error {
+	local := make(map[string]bool)
+	m.Lock()
+	for _, v := range m.services[service.Name] {
+		local[v.id] = true
+	}
+	m.Unlock()
+	p := &registry.Service{Name: service.Name, Version: service.Version, Metadata: service.Metadata}
+	var remote []string
+	for _, v := range service.Nodes {
+		if local[v.Id] {
+			p.Nodes = append(p.Nodes, v)
+		} else {
+			remote = append(remote, v.Id)
+		}
+	}
+	if len(p.Nodes) != 0 {
+		if err := m.Deregister(p); err != nil {
+			return err
+		}
+	}
+	if len(remote) != 0 {
+		return fmt.Errorf("%w: %s nodes advertised by other instances: %s", registry.ErrPurgeNotSupported, service.Name, strings.Join(remote, ", "))
+	}
+	return nil
+}
+
 func (m *mdnsRegistry) GetService(service string, opts ...registry.GetOption) ([]*registry.Service, error) {
 	serviceMap := make(map[string]*registry.Service)
 	entries := make(chan *ServiceEntry, 10)
@@ -555,7 +586,7 @@ func (m *mdnsWatcher) Next() (*registry.Result, error) {
 				continue
 			}
 
-			if len(txt.Service) == 0 || len(txt.Version) == 0 {
+			if len(txt.Service) == 0 {
 				continue
 			}
 
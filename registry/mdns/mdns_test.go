@@ -1,9 +1,13 @@
 package mdns
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"go.linka.cloud/grpc/registry"
 )
@@ -30,3 +34,54 @@ func TestRegistry(t *testing.T) {
 	}
 	assert.Len(svcs, 0)
 }
+
+func TestWatch(t *testing.T) {
+	reg := NewRegistry(Domain("watch"))
+	w, err := reg.Watch(registry.WatchService("watch"))
+	require.NoError(t, err)
+	defer w.Stop()
+
+	svc := &registry.Service{Name: "watch", Nodes: []*registry.Node{{Id: "watch-1", Address: "127.0.0.1:8889"}}}
+	require.NoError(t, reg.Register(svc))
+	defer reg.Deregister(svc)
+
+	ch := make(chan *registry.Result, 1)
+	go func() {
+		r, err := w.Next()
+		assert.NoError(t, err)
+		ch <- r
+	}()
+	select {
+	case r := <-ch:
+		assert.Equal(t, registry.Create.String(), r.Action)
+		require.Len(t, r.Service.Nodes, 1)
+		assert.Equal(t, "watch-1", r.Service.Nodes[0].Id)
+		assert.Equal(t, "127.0.0.1:8889", r.Service.Nodes[0].Address)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no watch result")
+	}
+}
+
+func TestPurge(t *testing.T) {
+	reg := NewRegistry(Domain("purge"))
+	require.Implements(t, (*registry.Purger)(nil), reg)
+	svc := &registry.Service{Name: "purge", Nodes: []*registry.Node{{Id: "purge-1", Address: "127.0.0.1:8890"}}}
+	require.NoError(t, reg.Register(svc))
+	defer reg.Deregister(svc)
+
+	// the local nodes are purged
+	j := registry.NewJanitor(reg, registry.WithJanitorServices("purge"), registry.WithJanitorFailures(1), registry.WithProbe(func(context.Context, *registry.Service, *registry.Node) bool {
+		return false
+	}))
+	purged, err := j.Purge(context.Background())
+	require.NoError(t, err)
+	require.Len(t, purged, 1)
+	assert.Equal(t, "purge-1", purged[0].Nodes[0].Id)
+	svcs, err := reg.GetService("purge")
+	require.NoError(t, err)
+	assert.Len(t, svcs, 0)
+
+	// the other instances advertisements cannot be removed
+	err = reg.(registry.Purger).Purge(&registry.Service{Name: "purge", Nodes: []*registry.Node{{Id: "purge-2"}}})
+	assert.True(t, errors.Is(err, registry.ErrPurgeNotSupported))
+}
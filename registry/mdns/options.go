@@ -0,0 +1,23 @@
+package mdns
+
+import (
+	"context"
+
+	"go.linka.cloud/grpc/registry"
+)
+
+type domainKey struct{}
+
+// Domain sets the mdns domain the services are advertised in, defaults to grpc, the services in other domains are ignored
+func Domain(domain string) registry.Option {
+	return setOption(domainKey{}, domain)
+}
+
+func setOption(k, v interface{}) registry.Option {
+	return func(o *registry.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, k, v)
+	}
+}
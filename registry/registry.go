@@ -12,6 +12,8 @@ var (
 	ErrNotFound = errors.New("service not found")
 	// Watcher stopped error when watcher is stopped
 	ErrWatcherStopped = errors.New("watcher stopped")
+	// ErrPurgeNotSupported is returned by the Purgers which cannot remove the records of the other instances
+	ErrPurgeNotSupported = errors.New("purge not supported")
 )
 
 // The registry provides an interface for service discovery
@@ -1,11 +1,15 @@
 package resolver
 
 import (
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"google.golang.org/grpc/resolver"
 
 	"go.linka.cloud/grpc/registry"
+	"go.linka.cloud/grpc/utils/backoff"
 )
 
 func New(reg registry.Registry) resolver.Builder {
@@ -17,7 +21,7 @@ type builder struct {
 }
 
 func (r builder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
-	rslvr := &resolvr{reg: r.reg, target: target, cc: cc}
+	rslvr := &resolvr{reg: r.reg, target: target, cc: cc, resolve: make(chan struct{}, 1), closed: make(chan struct{})}
 	go rslvr.run()
 	return rslvr, nil
 }
@@ -26,13 +30,24 @@ func (r builder) Scheme() string {
 	return r.reg.String()
 }
 
+// maxRetryBackoff is the maximum delay between the failed listings or watches retries
+const maxRetryBackoff = 30 * time.Second
+
 type resolvr struct {
 	reg    registry.Registry
 	target resolver.Target
 	cc     resolver.ClientConn
-	addrs  []resolver.Address
+
+	// resolve is signaled by ResolveNow to list the nodes again
+	resolve chan struct{}
+	// closed is closed by Close
+	closed chan struct{}
+	once   sync.Once
+	nodes  map[string]string
 }
 
+// run lists the nodes and watches their changes until the resolver is closed, the failed listings
+// and watches are retried with backoff, or as soon as the client connection asks to resolve again
 func (r *resolvr) run() {
 	if r.reg.String() == "noop" {
 		r.cc.UpdateState(resolver.State{Addresses: []resolver.Address{{Addr: r.target.Endpoint}}})
@@ -44,44 +59,142 @@ func (r *resolvr) run() {
 	if len(parts) > 1 {
 		version = parts[1]
 	}
+	for attempt := 1; ; attempt++ {
+		err := r.list(name, version)
+		if err == nil {
+			var w registry.Watcher
+			if w, err = r.reg.Watch(registry.WatchService(name)); err == nil {
+				start := time.Now()
+				err = r.watch(w, name, version)
+				// the watch was healthy, start the backoff over
+				if time.Since(start) > maxRetryBackoff {
+					attempt = 1
+				}
+			}
+		}
+		select {
+		case <-r.closed:
+			return
+		default:
+		}
+		r.cc.ReportError(err)
+		if !r.wait(attempt) {
+			return
+		}
+	}
+}
+
+// list replaces the nodes with the registered ones
+func (r *resolvr) list(name, version string) error {
 	svc, err := r.reg.GetService(name)
-	if err != nil {
-		return
+	if err != nil && err != registry.ErrNotFound {
+		return err
 	}
+	r.nodes = make(map[string]string)
 	for _, v := range svc {
 		if v.Name != name || v.Version != version {
 			continue
 		}
 		for _, vv := range v.Nodes {
-			r.addrs = append(r.addrs, resolver.Address{Addr: vv.Address})
+			r.nodes[vv.Id] = vv.Address
 		}
 	}
-	r.cc.UpdateState(resolver.State{Addresses: r.addrs})
-	w, err := r.reg.Watch(registry.WatchService(r.target.Endpoint))
-	if err != nil {
-		return
-	}
+	r.update()
+	return nil
+}
+
+// watch applies the changes of the nodes until the watcher fails or the resolver is closed,
+// listing the nodes again when the client connection asks to resolve
+func (r *resolvr) watch(w registry.Watcher, name, version string) error {
 	defer w.Stop()
+	results := make(chan *registry.Result)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			res, err := w.Next()
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case results <- res:
+			case <-r.closed:
+				return
+			}
+		}
+	}()
 	for {
-		res, err := w.Next()
-		if err != nil {
-			return
+		select {
+		case <-r.closed:
+			return nil
+		case err := <-errs:
+			return err
+		case <-r.resolve:
+			if err := r.list(name, version); err != nil {
+				r.cc.ReportError(err)
+			}
+		case res := <-results:
+			if res.Service == nil || res.Service.Name != name || res.Service.Version != version {
+				continue
+			}
+			switch res.Action {
+			case registry.Create.String(), registry.Update.String():
+				for _, v := range res.Service.Nodes {
+					r.nodes[v.Id] = v.Address
+				}
+			case registry.Delete.String():
+				for _, v := range res.Service.Nodes {
+					delete(r.nodes, v.Id)
+				}
+			}
+			r.update()
 		}
-		// TODO(adphi): implement
-		switch res.Action {
-		case registry.Create.String():
+	}
+}
 
-		case registry.Delete.String():
+// wait waits before the next attempt, it returns false if the resolver was closed meanwhile
+func (r *resolvr) wait(attempt int) bool {
+	d := backoff.Do(attempt)
+	if d > maxRetryBackoff {
+		d = maxRetryBackoff
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-r.closed:
+		return false
+	case <-r.resolve:
+		return true
+	case <-t.C:
+		return true
+	}
+}
 
-		}
-		r.cc.UpdateState(resolver.State{Addresses: r.addrs})
+// update sends the resolved nodes addresses to the client connection
+func (r *resolvr) update() {
+	addrs := make([]resolver.Address, 0, len(r.nodes))
+	for _, v := range r.nodes {
+		addrs = append(addrs, resolver.Address{Addr: v})
 	}
+	sort.Slice(addrs, func(i, j int) bool {
+		return addrs[i].Addr < addrs[j].Addr
+	})
+	r.cc.UpdateState(resolver.State{Addresses: addrs})
 }
 
 func (r *resolvr) ResolveNow(options resolver.ResolveNowOptions) {
 	if r.reg.String() == "noop" {
 		r.cc.UpdateState(resolver.State{Addresses: []resolver.Address{{Addr: r.target.Endpoint}}})
+		return
+	}
+	select {
+	case r.resolve <- struct{}{}:
+	default:
 	}
 }
 
-func (r *resolvr) Close() {}
+func (r *resolvr) Close() {
+	r.once.Do(func() {
+		close(r.closed)
+	})
+}
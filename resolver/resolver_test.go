@@ -0,0 +1,165 @@
+package resolver
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/resolver"
+
+	"go.linka.cloud/grpc/registry"
+)
+
+// fakeRegistry fails the listings and watches while its errors are set
+type fakeRegistry struct {
+	registry.Registry
+	mu       sync.Mutex
+	nodes    []*registry.Node
+	getErr   error
+	watchErr error
+	gets     int
+	watches  chan *fakeWatcher
+}
+
+func (r *fakeRegistry) String() string {
+	return "fake"
+}
+
+func (r *fakeRegistry) GetService(name string, _ ...registry.GetOption) ([]*registry.Service, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gets++
+	if r.getErr != nil {
+		return nil, r.getErr
+	}
+	return []*registry.Service{{Name: name, Nodes: r.nodes}}, nil
+}
+
+func (r *fakeRegistry) Watch(_ ...registry.WatchOption) (registry.Watcher, error) {
+	r.mu.Lock()
+	err := r.watchErr
+	r.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	w := &fakeWatcher{results: make(chan *registry.Result), stopped: make(chan struct{})}
+	r.watches <- w
+	return w, nil
+}
+
+func (r *fakeRegistry) set(f func()) {
+	r.mu.Lock()
+	f()
+	r.mu.Unlock()
+}
+
+type fakeWatcher struct {
+	results chan *registry.Result
+	once    sync.Once
+	stopped chan struct{}
+}
+
+func (w *fakeWatcher) Next() (*registry.Result, error) {
+	select {
+	case r := <-w.results:
+		return r, nil
+	case <-w.stopped:
+		return nil, registry.ErrWatcherStopped
+	}
+}
+
+func (w *fakeWatcher) Stop() {
+	w.once.Do(func() {
+		close(w.stopped)
+	})
+}
+
+type clientConn struct {
+	resolver.ClientConn
+	states chan []string
+	errs   chan error
+}
+
+func (c *clientConn) UpdateState(s resolver.State) error {
+	var addrs []string
+	for _, v := range s.Addresses {
+		addrs = append(addrs, v.Addr)
+	}
+	c.states <- addrs
+	return nil
+}
+
+func (c *clientConn) ReportError(err error) {
+	c.errs <- err
+}
+
+func TestResolverRetry(t *testing.T) {
+	unavailable := errors.New("unavailable")
+	reg := &fakeRegistry{
+		nodes:    []*registry.Node{{Id: "1", Address: "127.0.0.1:1"}},
+		getErr:   unavailable,
+		watchErr: unavailable,
+		watches:  make(chan *fakeWatcher, 1),
+	}
+	cc := &clientConn{states: make(chan []string, 16), errs: make(chan error, 16)}
+	r, err := New(reg).Build(resolver.Target{Endpoint: "test"}, cc, resolver.BuildOptions{})
+	require.NoError(t, err)
+	defer r.Close()
+
+	state := func() []string {
+		select {
+		case s := <-cc.states:
+			return s
+		case <-time.After(5 * time.Second):
+			t.Fatal("no state update")
+			return nil
+		}
+	}
+	reported := func() error {
+		select {
+		case err := <-cc.errs:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatal("no error reported")
+			return nil
+		}
+	}
+
+	// the failed listing is reported and retried
+	assert.Equal(t, unavailable, reported())
+	reg.set(func() { reg.getErr = nil })
+	r.ResolveNow(resolver.ResolveNowOptions{})
+	assert.Equal(t, []string{"127.0.0.1:1"}, state())
+
+	// the failed watch is reported and retried with a new listing
+	assert.Equal(t, unavailable, reported())
+	reg.set(func() { reg.watchErr = nil })
+	r.ResolveNow(resolver.ResolveNowOptions{})
+	assert.Equal(t, []string{"127.0.0.1:1"}, state())
+	var w *fakeWatcher
+	select {
+	case w = <-reg.watches:
+	case <-time.After(5 * time.Second):
+		t.Fatal("not watching")
+	}
+	w.results <- &registry.Result{Action: registry.Create.String(), Service: &registry.Service{Name: "test", Nodes: []*registry.Node{{Id: "2", Address: "127.0.0.1:2"}}}}
+	assert.Equal(t, []string{"127.0.0.1:1", "127.0.0.1:2"}, state())
+
+	// ResolveNow lists the nodes again while watching
+	reg.set(func() { reg.nodes = []*registry.Node{{Id: "3", Address: "127.0.0.1:3"}} })
+	r.ResolveNow(resolver.ResolveNowOptions{})
+	assert.Equal(t, []string{"127.0.0.1:3"}, state())
+
+	// the broken watch is started again
+	w.Stop()
+	assert.Equal(t, registry.ErrWatcherStopped, reported())
+	assert.Equal(t, []string{"127.0.0.1:3"}, state())
+	select {
+	case <-reg.watches:
+	case <-time.After(5 * time.Second):
+		t.Fatal("not watching again")
+	}
+}
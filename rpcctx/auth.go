@@ -0,0 +1,282 @@
+package rpcctx
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/fullstorydev/grpchan/inprocgrpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+const (
+	// ForwardedClientCertHeader is the header set by Envoy with the client certificate details
+	ForwardedClientCertHeader = "X-Forwarded-Client-Cert"
+	// ForwardedTLSClientCertHeader is the header set by Traefik with the client certificate chain
+	ForwardedTLSClientCertHeader = "X-Forwarded-Tls-Client-Cert"
+)
+
+// AuthSource is where the peer authentication information comes from
+type AuthSource string
+
+const (
+	// AuthSourceTLS is the TLS connection of the caller
+	AuthSourceTLS AuthSource = "tls"
+	// AuthSourceForwarded is the client certificate forwarded by a trusted proxy terminating TLS
+	AuthSourceForwarded AuthSource = "forwarded"
+)
+
+type authInfoKey struct{}
+
+// AuthInfo is the peer authentication information, normalized across the grpc, gateway and grpc-web paths
+type AuthInfo struct {
+	Source AuthSource
+	// Certificates is the client certificate chain, leaf first, it is empty when the caller did not present one
+	Certificates []*x509.Certificate
+	// Verified reports whether the chain was verified, either by the server or by the proxy
+	Verified bool
+	// State is the TLS connection state, it is only set for the AuthSourceTLS source
+	State *tls.ConnectionState
+}
+
+// Leaf returns the client certificate
+func (a *AuthInfo) Leaf() *x509.Certificate {
+	if a == nil || len(a.Certificates) == 0 {
+		return nil
+	}
+	return a.Certificates[0]
+}
+
+// Identity returns the mTLS identity of the caller: the first URI SAN, e.g. a SPIFFE ID,
+// or the subject common name of the client certificate
+func (a *AuthInfo) Identity() string {
+	c := a.Leaf()
+	if c == nil {
+		return ""
+	}
+	if len(c.URIs) != 0 {
+		return c.URIs[0].String()
+	}
+	return c.Subject.CommonName
+}
+
+// WithAuthInfo returns a context carrying the peer authentication information
+func WithAuthInfo(ctx context.Context, a *AuthInfo) context.Context {
+	return context.WithValue(ctx, authInfoKey{}, a)
+}
+
+// PeerAuth returns the peer authentication information: the one set by the http middleware
+// for the gateway and grpc-web requests, or the TLS state of the grpc connection
+func PeerAuth(ctx context.Context) (*AuthInfo, bool) {
+	if a, ok := ctx.Value(authInfoKey{}).(*AuthInfo); ok {
+		return a, true
+	}
+	// the gateway calls the service through the in-process channel
+	if c := inprocgrpc.ClientContext(ctx); c != nil {
+		if a, ok := c.Value(authInfoKey{}).(*AuthInfo); ok {
+			return a, true
+		}
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, false
+	}
+	i, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return nil, false
+	}
+	return FromTLS(&i.State), true
+}
+
+// FromTLS returns the authentication information of the TLS connection state
+func FromTLS(s *tls.ConnectionState) *AuthInfo {
+	if s == nil {
+		return nil
+	}
+	return &AuthInfo{
+		Source:       AuthSourceTLS,
+		Certificates: s.PeerCertificates,
+		Verified:     len(s.VerifiedChains) != 0,
+		State:        s,
+	}
+}
+
+// ParseForwardedClientCert returns the client certificate forwarded by a proxy in the Envoy
+// X-Forwarded-Client-Cert header or in the Traefik X-Forwarded-Tls-Client-Cert header.
+// It returns nil when the headers are not set.
+func ParseForwardedClientCert(h http.Header) (*AuthInfo, error) {
+	var (
+		certs []*x509.Certificate
+		err   error
+	)
+	switch {
+	case h.Get(ForwardedClientCertHeader) != "":
+		certs, err = parseXFCC(h.Get(ForwardedClientCertHeader))
+	case h.Get(ForwardedTLSClientCertHeader) != "":
+		certs, err = parseTraefik(h.Get(ForwardedTLSClientCertHeader))
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &AuthInfo{Source: AuthSourceForwarded, Certificates: certs, Verified: len(certs) != 0}, nil
+}
+
+// PeerAuthMiddleware adds the peer authentication information to the http requests context.
+// The forwarded client certificate headers are only honored when trusted returns true, e.g. for the
+// requests coming from the proxy terminating TLS, they are removed from the other requests.
+func PeerAuthMiddleware(trusted func(r *http.Request) bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if trusted == nil || !trusted(r) {
+				r.Header.Del(ForwardedClientCertHeader)
+				r.Header.Del(ForwardedTLSClientCertHeader)
+				if r.TLS != nil {
+					r = r.WithContext(WithAuthInfo(r.Context(), FromTLS(r.TLS)))
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+			a, err := ParseForwardedClientCert(r.Header)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if a == nil && r.TLS != nil {
+				a = FromTLS(r.TLS)
+			}
+			if a != nil {
+				r = r.WithContext(WithAuthInfo(r.Context(), a))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// parseXFCC parses the first element of the header, added by the proxy the client connected to, e.g.
+// By=spiffe://example.org/proxy;Hash=...;Cert="-----BEGIN%20CERTIFICATE-----...";Subject="CN=client"
+func parseXFCC(v string) ([]*x509.Certificate, error) {
+	elem := splitQuoted(v, ',')[0]
+	var (
+		cert, chain string
+		hash        []byte
+	)
+	for _, kv := range splitQuoted(elem, ';') {
+		i := strings.Index(kv, "=")
+		if i < 0 {
+			return nil, errors.New("rpcctx: invalid forwarded client cert element")
+		}
+		k, v := strings.TrimSpace(kv[:i]), strings.Trim(strings.TrimSpace(kv[i+1:]), `"`)
+		switch strings.ToLower(k) {
+		case "cert":
+			cert = v
+		case "chain":
+			chain = v
+		case "hash":
+			h, err := hex.DecodeString(v)
+			if err != nil {
+				return nil, fmt.Errorf("rpcctx: invalid forwarded client cert hash: %w", err)
+			}
+			hash = h
+		}
+	}
+	if chain == "" {
+		chain = cert
+	}
+	if chain == "" {
+		return nil, nil
+	}
+	s, err := url.PathUnescape(chain)
+	if err != nil {
+		return nil, err
+	}
+	certs, err := parsePEM(s)
+	if err != nil {
+		return nil, err
+	}
+	if hash != nil {
+		if sum := sha256.Sum256(certs[0].Raw); !bytes.Equal(sum[:], hash) {
+			return nil, errors.New("rpcctx: forwarded client cert hash mismatch")
+		}
+	}
+	return certs, nil
+}
+
+// parseTraefik parses the url escaped certificates chain, either PEM encoded
+// or as comma separated base64 DER certificates
+func parseTraefik(v string) ([]*x509.Certificate, error) {
+	s, err := url.PathUnescape(v)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(s, "-----BEGIN") {
+		return parsePEM(s)
+	}
+	var certs []*x509.Certificate
+	for _, v := range strings.Split(s, ",") {
+		b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("rpcctx: invalid forwarded client cert: %w", err)
+		}
+		c, err := x509.ParseCertificate(b)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, c)
+	}
+	return certs, nil
+}
+
+func parsePEM(s string) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	rest := []byte(s)
+	for {
+		var b *pem.Block
+		b, rest = pem.Decode(rest)
+		if b == nil {
+			break
+		}
+		c, err := x509.ParseCertificate(b.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, c)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("rpcctx: no certificate found in forwarded client cert")
+	}
+	return certs, nil
+}
+
+// splitQuoted splits s on sep outside of the double quoted values
+func splitQuoted(s string, sep rune) []string {
+	var (
+		out    []string
+		quoted bool
+		start  int
+	)
+	for i, r := range s {
+		switch r {
+		case '"':
+			quoted = !quoted
+		case sep:
+			if !quoted {
+				out = append(out, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(out, s[start:])
+}
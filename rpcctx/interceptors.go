@@ -0,0 +1,102 @@
+package rpcctx
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"go.linka.cloud/grpc/interceptors"
+	metadata2 "go.linka.cloud/grpc/interceptors/metadata"
+	"go.linka.cloud/grpc/logger"
+)
+
+type Option func(o *options)
+
+// WithServiceInfo sets the service name and version added to the requests context
+func WithServiceInfo(name, version string) Option {
+	return func(o *options) {
+		o.service = &ServiceInfo{Name: name, Version: version}
+	}
+}
+
+// WithGenerateRequestID generates a request id when the caller did not send one, and returns it in the response headers
+func WithGenerateRequestID() Option {
+	return func(o *options) {
+		o.generate = true
+	}
+}
+
+// WithLogger adds l to the requests context, with the request id field, see logger.C
+func WithLogger(l logger.Logger) Option {
+	return func(o *options) {
+		o.logger = l
+	}
+}
+
+type options struct {
+	service  *ServiceInfo
+	generate bool
+	logger   logger.Logger
+}
+
+// NewServerInterceptors returns the interceptors enriching the requests context
+func NewServerInterceptors(opts ...Option) interceptors.ServerInterceptors {
+	o := options{}
+	for _, v := range opts {
+		v(&o)
+	}
+	return &interceptor{o: o}
+}
+
+type interceptor struct {
+	o options
+}
+
+func (i *interceptor) enrich(ctx context.Context) (context.Context, error) {
+	if i.o.service != nil {
+		ctx = WithService(ctx, i.o.service.Name, i.o.service.Version)
+	}
+	id, ok := incoming(ctx, RequestIDKey)
+	if !ok && i.o.generate {
+		id = uuid.New().String()
+		if err := grpc.SetHeader(ctx, metadata.Pairs(RequestIDKey, id)); err != nil {
+			return nil, err
+		}
+	}
+	if id != "" {
+		ctx = WithRequestID(ctx, id)
+	}
+	if i.o.logger != nil {
+		log := i.o.logger
+		if id != "" {
+			log = log.WithField(logger.FieldRequestID, id)
+		}
+		ctx = logger.Set(ctx, log)
+	}
+	if t, ok := incoming(ctx, TenantKey); ok {
+		ctx = WithTenant(ctx, t)
+	}
+	return ctx, nil
+}
+
+func (i *interceptor) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := i.enrich(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (i *interceptor) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := i.enrich(ss.Context())
+		if err != nil {
+			return err
+		}
+		return handler(srv, metadata2.NewContextServerStream(ctx, ss))
+	}
+}
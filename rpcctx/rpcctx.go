@@ -0,0 +1,128 @@
+// Package rpcctx provides typed accessors for the request information known by the framework
+package rpcctx
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+const (
+	RequestIDKey = "x-request-id"
+	TenantKey    = "x-tenant-id"
+)
+
+type serviceKey struct{}
+
+type requestIDKey struct{}
+
+type identityKey struct{}
+
+type tenantKey struct{}
+
+type inProcessKey struct{}
+
+// ServiceInfo is the service handling the request
+type ServiceInfo struct {
+	Name    string
+	Version string
+}
+
+// WithService returns a context carrying the service name and version
+func WithService(ctx context.Context, name, version string) context.Context {
+	return context.WithValue(ctx, serviceKey{}, ServiceInfo{Name: name, Version: version})
+}
+
+// Service returns the service handling the request
+func Service(ctx context.Context) (ServiceInfo, bool) {
+	s, ok := ctx.Value(serviceKey{}).(ServiceInfo)
+	return s, ok
+}
+
+// Method returns the full method name of the request, e.g. /helloworld.Greeter/SayHello
+func Method(ctx context.Context) (string, bool) {
+	return grpc.Method(ctx)
+}
+
+// Peer returns the address of the caller
+func Peer(ctx context.Context) (net.Addr, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return nil, false
+	}
+	return p.Addr, true
+}
+
+// WithInProcess returns a context marking the request as received through the in-process channel
+func WithInProcess(ctx context.Context) context.Context {
+	return context.WithValue(ctx, inProcessKey{}, true)
+}
+
+// InProcess reports whether the request was received through the in-process channel, e.g. from the gateway,
+// rather than from the network
+func InProcess(ctx context.Context) bool {
+	v, _ := ctx.Value(inProcessKey{}).(bool)
+	return v
+}
+
+// WithRequestID returns a context carrying the request id
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request id, falling back to the x-request-id incoming metadata
+func RequestID(ctx context.Context) (string, bool) {
+	if v, ok := ctx.Value(requestIDKey{}).(string); ok {
+		return v, true
+	}
+	return incoming(ctx, RequestIDKey)
+}
+
+// WithIdentity returns a context carrying the authenticated identity, e.g. from an auth validator
+func WithIdentity(ctx context.Context, identity interface{}) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// Identity returns the authenticated identity
+func Identity(ctx context.Context) (interface{}, bool) {
+	v := ctx.Value(identityKey{})
+	return v, v != nil
+}
+
+// WithTenant returns a context carrying the tenant
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// Tenant returns the tenant, falling back to the x-tenant-id incoming metadata
+func Tenant(ctx context.Context) (string, bool) {
+	if v, ok := ctx.Value(tenantKey{}).(string); ok {
+		return v, true
+	}
+	return incoming(ctx, TenantKey)
+}
+
+// DeadlineRemaining returns the time left before the request deadline
+func DeadlineRemaining(ctx context.Context) (time.Duration, bool) {
+	d, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	return time.Until(d), true
+}
+
+func incoming(ctx context.Context, key string) (string, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", false
+	}
+	v := md.Get(key)
+	if len(v) == 0 || v[0] == "" {
+		return "", false
+	}
+	return v[0], true
+}
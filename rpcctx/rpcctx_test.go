@@ -0,0 +1,152 @@
+package rpcctx
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"go.linka.cloud/grpc/logger"
+)
+
+func TestAccessors(t *testing.T) {
+	ctx := context.Background()
+	_, ok := RequestID(ctx)
+	assert.False(t, ok)
+	_, ok = DeadlineRemaining(ctx)
+	assert.False(t, ok)
+
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(RequestIDKey, "md-id", TenantKey, "md-tenant"))
+	id, ok := RequestID(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "md-id", id)
+	tenant, _ := Tenant(ctx)
+	assert.Equal(t, "md-tenant", tenant)
+
+	ctx = WithRequestID(ctx, "id")
+	ctx = WithTenant(ctx, "tenant")
+	ctx = WithIdentity(ctx, "user")
+	ctx = WithService(ctx, "svc", "v1")
+	id, _ = RequestID(ctx)
+	assert.Equal(t, "id", id)
+	tenant, _ = Tenant(ctx)
+	assert.Equal(t, "tenant", tenant)
+	identity, ok := Identity(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "user", identity)
+	s, ok := Service(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, ServiceInfo{Name: "svc", Version: "v1"}, s)
+
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	d, ok := DeadlineRemaining(ctx)
+	assert.True(t, ok)
+	assert.True(t, d > 0 && d <= time.Minute)
+}
+
+func newCert(t *testing.T) *x509.Certificate {
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	u, _ := url.Parse("spiffe://example.org/client")
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		URIs:         []*url.URL{u},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	b, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &k.PublicKey, k)
+	require.NoError(t, err)
+	c, err := x509.ParseCertificate(b)
+	require.NoError(t, err)
+	return c
+}
+
+func TestPeerAuth(t *testing.T) {
+	c := newCert(t)
+	_, ok := PeerAuth(context.Background())
+	assert.False(t, ok)
+
+	ctx := peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{c},
+		VerifiedChains:   [][]*x509.Certificate{{c}},
+	}}})
+	a, ok := PeerAuth(ctx)
+	require.True(t, ok)
+	assert.Equal(t, AuthSourceTLS, a.Source)
+	assert.True(t, a.Verified)
+	assert.Equal(t, "spiffe://example.org/client", a.Identity())
+
+	p := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Raw}))
+	sum := sha256.Sum256(c.Raw)
+	xfcc := `By=spiffe://example.org/proxy;Hash=` + hex.EncodeToString(sum[:]) + `;Cert="` + url.PathEscape(p) + `";Subject="CN=client,O=example",By=spiffe://example.org/other`
+	traefik := url.PathEscape(base64.StdEncoding.EncodeToString(c.Raw))
+
+	var got *AuthInfo
+	trusted := false
+	h := PeerAuthMiddleware(func(r *http.Request) bool { return trusted })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = PeerAuth(r.Context())
+	}))
+	do := func(k, v string) int {
+		got = nil
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(k, v)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, do(ForwardedClientCertHeader, xfcc))
+	assert.Nil(t, got)
+
+	trusted = true
+	for _, v := range [][2]string{{ForwardedClientCertHeader, xfcc}, {ForwardedTLSClientCertHeader, traefik}} {
+		assert.Equal(t, http.StatusOK, do(v[0], v[1]))
+		require.NotNil(t, got)
+		assert.Equal(t, AuthSourceForwarded, got.Source)
+		assert.Equal(t, c.Raw, got.Leaf().Raw)
+		assert.Equal(t, "spiffe://example.org/client", got.Identity())
+	}
+	assert.Equal(t, http.StatusBadRequest, do(ForwardedClientCertHeader, `Hash=00;Cert="`+url.PathEscape(p)+`"`))
+}
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	log := logger.New().SetOutput(&buf).SetFormatter(logger.FormatJSON.Formatter()).WithFields(logger.FieldService, "svc", logger.FieldVersion, "v1")
+	i := NewServerInterceptors(WithLogger(log))
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(RequestIDKey, "id"))
+	_, err := i.UnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/svc.Service/Get"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		logger.C(ctx).Info("hello")
+		return nil, nil
+	})
+	require.NoError(t, err)
+	var m map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &m))
+	for k, v := range map[string]string{"level": "info", "msg": "hello", "svc": "svc", "ver": "v1", "request_id": "id"} {
+		assert.Equal(t, v, m[k], k)
+	}
+	assert.Contains(t, m, "ts")
+}
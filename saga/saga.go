@@ -0,0 +1,203 @@
+// Package saga provides a lightweight orchestration of the multi-services write flows: a saga is a sequence
+// of steps, each one with a compensation undoing it, which are executed in reverse order when a step fails.
+// The saga state is persisted after each step, so that the interrupted sagas are resumed on restart.
+//
+//	s := saga.New("order", saga.NewSQLStore(db),
+//		saga.Step{Name: "reserve", Do: reserve, Compensate: release},
+//		saga.Step{Name: "charge", Do: charge, Compensate: refund},
+//		saga.Step{Name: "ship", Do: ship},
+//	)
+//	// resume the sagas interrupted by the last shutdown
+//	svc.Go("saga-order", s.Resume)
+//	...
+//	err := s.Start(ctx, orderID, payload)
+//
+// The steps and the compensations may be executed more than once when a saga is resumed, they must be idempotent,
+// e.g. by using the saga id as idempotency key.
+package saga
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/multierr"
+
+	"go.linka.cloud/grpc/logger"
+)
+
+var (
+	ErrNotFound = errors.New("saga: not found")
+	ErrExists   = errors.New("saga: already exists")
+)
+
+// Status is the state of a saga instance
+type Status string
+
+const (
+	// StatusRunning is the state of the saga executing its steps
+	StatusRunning Status = "running"
+	// StatusCompensating is the state of the saga undoing its steps after a failure
+	StatusCompensating Status = "compensating"
+	// StatusCompleted is the state of the saga which steps all succeeded
+	StatusCompleted Status = "completed"
+	// StatusCompensated is the state of the saga which steps were all undone
+	StatusCompensated Status = "compensated"
+)
+
+// Done reports whether the saga is finished
+func (s Status) Done() bool {
+	return s == StatusCompleted || s == StatusCompensated
+}
+
+// Instance is the persisted state of a saga execution
+type Instance struct {
+	ID   string
+	Saga string
+	// Data is the saga payload, the steps may update it, e.g. to record the ids they created
+	Data   []byte
+	Status Status
+	// Step is the index of the next step to execute while running, or of the next step to compensate
+	// plus one while compensating
+	Step int
+	// Failed is the name of the failed step
+	Failed string
+	// Error is the error of the failed step
+	Error     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Step is a saga step
+type Step struct {
+	Name string
+	// Do executes the step
+	Do func(ctx context.Context, i *Instance) error
+	// Compensate undoes the step, it is optional, e.g. for the last step or for the read only steps
+	Compensate func(ctx context.Context, i *Instance) error
+}
+
+// StepError is returned when a saga step fails, the saga being compensated
+type StepError struct {
+	Saga string
+	ID   string
+	Step string
+	Err  error
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("saga %s: %s: step %s failed: %v", e.Saga, e.ID, e.Step, e.Err)
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// Saga is a saga definition
+type Saga struct {
+	name  string
+	store Store
+	steps []Step
+	now   func() time.Time
+}
+
+// New returns a Saga which instances are persisted in store
+func New(name string, store Store, steps ...Step) *Saga {
+	return &Saga{name: name, store: store, steps: steps, now: time.Now}
+}
+
+// Name returns the saga name
+func (s *Saga) Name() string {
+	return s.name
+}
+
+// Start executes a new saga instance. It returns a *StepError if a step failed and the saga was compensated,
+// or the persistence or compensation error, the saga being then resumed by the next Resume.
+func (s *Saga) Start(ctx context.Context, id string, data []byte) error {
+	now := s.now()
+	i := &Instance{ID: id, Saga: s.name, Data: data, Status: StatusRunning, CreatedAt: now, UpdatedAt: now}
+	if err := s.store.Create(ctx, i); err != nil {
+		return err
+	}
+	return s.run(ctx, i)
+}
+
+// Get returns the state of a saga instance
+func (s *Saga) Get(ctx context.Context, id string) (*Instance, error) {
+	return s.store.Get(ctx, s.name, id)
+}
+
+// Resume executes the pending saga instances, e.g. the ones interrupted by a restart, the failed ones
+// being left pending for the next Resume.
+// It should only run on one replica at a time, e.g. on the leader, see service.WithLeaderElection.
+func (s *Saga) Resume(ctx context.Context) error {
+	is, err := s.store.Pending(ctx, s.name)
+	if err != nil {
+		return err
+	}
+	var merr error
+	for _, i := range is {
+		// the compensated sagas are expected, only the ones still pending are reported
+		var serr *StepError
+		if err := s.run(ctx, i); err != nil && !errors.As(err, &serr) {
+			merr = multierr.Append(merr, err)
+		}
+	}
+	return merr
+}
+
+func (s *Saga) run(ctx context.Context, i *Instance) error {
+	log := logger.C(ctx).WithFields("saga", s.name, "id", i.ID)
+	// the step error, it is only known if the step failed during this run
+	var failed error
+	for i.Status == StatusRunning && i.Step < len(s.steps) {
+		st := s.steps[i.Step]
+		if err := st.Do(ctx, i); err != nil {
+			log.Warnf("step %s failed: compensating: %v", st.Name, err)
+			i.Status = StatusCompensating
+			i.Failed = st.Name
+			i.Error = err.Error()
+			failed = err
+		} else {
+			i.Step++
+		}
+		if err := s.save(ctx, i); err != nil {
+			return err
+		}
+	}
+	if i.Status == StatusRunning {
+		i.Status = StatusCompleted
+		return s.save(ctx, i)
+	}
+	for i.Status == StatusCompensating && i.Step > 0 {
+		st := s.steps[i.Step-1]
+		if st.Compensate != nil {
+			if err := st.Compensate(ctx, i); err != nil {
+				return fmt.Errorf("saga %s: %s: compensate %s: %w", s.name, i.ID, st.Name, err)
+			}
+		}
+		i.Step--
+		if err := s.save(ctx, i); err != nil {
+			return err
+		}
+	}
+	if i.Status == StatusCompensating {
+		i.Status = StatusCompensated
+		if err := s.save(ctx, i); err != nil {
+			return err
+		}
+	}
+	if i.Status != StatusCompensated {
+		return nil
+	}
+	if failed == nil {
+		failed = errors.New(i.Error)
+	}
+	return &StepError{Saga: s.name, ID: i.ID, Step: i.Failed, Err: failed}
+}
+
+func (s *Saga) save(ctx context.Context, i *Instance) error {
+	i.UpdatedAt = s.now()
+	return s.store.Update(ctx, i)
+}
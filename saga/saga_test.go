@@ -0,0 +1,100 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recorder struct {
+	calls []string
+	fail  map[string]error
+}
+
+func (r *recorder) step(name string) Step {
+	return Step{
+		Name: name,
+		Do: func(ctx context.Context, i *Instance) error {
+			r.calls = append(r.calls, name)
+			return r.fail[name]
+		},
+		Compensate: func(ctx context.Context, i *Instance) error {
+			r.calls = append(r.calls, "undo-"+name)
+			return r.fail["undo-"+name]
+		},
+	}
+}
+
+func TestSaga(t *testing.T) {
+	ctx := context.Background()
+	r := &recorder{fail: make(map[string]error)}
+	s := New("order", NewMemoryStore(), r.step("reserve"), r.step("charge"), r.step("ship"))
+
+	require.NoError(t, s.Start(ctx, "1", []byte("data")))
+	assert.Equal(t, []string{"reserve", "charge", "ship"}, r.calls)
+	i, err := s.Get(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, StatusCompleted, i.Status)
+	assert.Equal(t, []byte("data"), i.Data)
+	assert.Equal(t, ErrExists, s.Start(ctx, "1", nil))
+
+	// the completed steps are compensated in reverse order
+	r.calls = nil
+	r.fail["ship"] = errors.New("out of stock")
+	err = s.Start(ctx, "2", nil)
+	var serr *StepError
+	require.True(t, errors.As(err, &serr))
+	assert.Equal(t, "ship", serr.Step)
+	assert.Equal(t, r.fail["ship"], errors.Unwrap(err))
+	assert.Equal(t, []string{"reserve", "charge", "ship", "undo-charge", "undo-reserve"}, r.calls)
+	i, err = s.Get(ctx, "2")
+	require.NoError(t, err)
+	assert.Equal(t, StatusCompensated, i.Status)
+	assert.Equal(t, "out of stock", i.Error)
+}
+
+func TestResume(t *testing.T) {
+	ctx := context.Background()
+	r := &recorder{fail: make(map[string]error)}
+	store := NewMemoryStore()
+	s := New("order", store, r.step("reserve"), r.step("charge"), r.step("ship"))
+
+	// a failing compensation leaves the saga pending
+	r.fail["ship"] = errors.New("out of stock")
+	r.fail["undo-reserve"] = errors.New("unavailable")
+	err := s.Start(ctx, "1", nil)
+	require.Error(t, err)
+	var serr *StepError
+	assert.False(t, errors.As(err, &serr))
+	i, err := s.Get(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, StatusCompensating, i.Status)
+	assert.Equal(t, 1, i.Step)
+
+	// a saga interrupted while running
+	require.NoError(t, store.Create(ctx, &Instance{ID: "2", Saga: "order", Status: StatusRunning, Step: 2, CreatedAt: time.Now()}))
+	// other sagas are not resumed
+	require.NoError(t, store.Create(ctx, &Instance{ID: "3", Saga: "other", Status: StatusRunning}))
+
+	r.calls = nil
+	delete(r.fail, "ship")
+	require.Error(t, s.Resume(ctx))
+	assert.Equal(t, []string{"undo-reserve", "ship"}, r.calls)
+
+	r.calls = nil
+	delete(r.fail, "undo-reserve")
+	require.NoError(t, s.Resume(ctx))
+	assert.Equal(t, []string{"undo-reserve"}, r.calls)
+	for id, st := range map[string]Status{"1": StatusCompensated, "2": StatusCompleted} {
+		i, err := s.Get(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, st, i.Status, id)
+	}
+	is, err := store.Pending(ctx, "order")
+	require.NoError(t, err)
+	assert.Empty(t, is)
+}
@@ -0,0 +1,167 @@
+package saga
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const defaultTable = "sagas"
+
+// Schema returns the DDL of the sagas table for mysql and sqlite, the data column must be a BYTEA for postgres
+func Schema(table string) string {
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	saga VARCHAR(255) NOT NULL,
+	id VARCHAR(255) NOT NULL,
+	data BLOB NULL,
+	status VARCHAR(32) NOT NULL,
+	step INTEGER NOT NULL,
+	failed VARCHAR(255) NOT NULL,
+	error TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (saga, id)
+);
+CREATE INDEX IF NOT EXISTS %s_status ON %s (saga, status);`, table, table, table)
+}
+
+type SQLOption func(s *sqlStore)
+
+// WithTable sets the table name, defaults to sagas
+func WithTable(name string) SQLOption {
+	return func(s *sqlStore) {
+		s.table = name
+	}
+}
+
+// WithDollarPlaceholders uses the $1 placeholders, e.g. for postgres, instead of ?
+func WithDollarPlaceholders() SQLOption {
+	return func(s *sqlStore) {
+		s.dollar = true
+	}
+}
+
+type sqlStore struct {
+	db     *sql.DB
+	table  string
+	dollar bool
+}
+
+// NewSQLStore returns a Store using db, the table must exist, see Schema and Migrate
+func NewSQLStore(db *sql.DB, opts ...SQLOption) Store {
+	s := &sqlStore{db: db, table: defaultTable}
+	for _, v := range opts {
+		v(s)
+	}
+	return s
+}
+
+// Migrate creates the sagas table if it does not exist
+func Migrate(ctx context.Context, db *sql.DB, table string) error {
+	if table == "" {
+		table = defaultTable
+	}
+	for _, v := range strings.Split(Schema(table), ";") {
+		if strings.TrimSpace(v) == "" {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// query replaces the ? placeholders if needed
+func (s *sqlStore) query(q string) string {
+	q = strings.Replace(q, "{table}", s.table, -1)
+	if !s.dollar {
+		return q
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range q {
+		if r == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+const columns = `saga, id, data, status, step, failed, error, created_at, updated_at`
+
+func scan(row interface {
+	Scan(dest ...interface{}) error
+}) (*Instance, error) {
+	var (
+		i      Instance
+		status string
+	)
+	if err := row.Scan(&i.Saga, &i.ID, &i.Data, &status, &i.Step, &i.Failed, &i.Error, &i.CreatedAt, &i.UpdatedAt); err != nil {
+		return nil, err
+	}
+	i.Status = Status(status)
+	return &i, nil
+}
+
+func (s *sqlStore) Create(ctx context.Context, i *Instance) error {
+	if _, err := s.Get(ctx, i.Saga, i.ID); err == nil {
+		return ErrExists
+	} else if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, s.query(`INSERT INTO {table} (`+columns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		i.Saga, i.ID, i.Data, string(i.Status), i.Step, i.Failed, i.Error, i.CreatedAt, i.UpdatedAt)
+	return err
+}
+
+func (s *sqlStore) Update(ctx context.Context, i *Instance) error {
+	res, err := s.db.ExecContext(ctx, s.query(`UPDATE {table} SET data = ?, status = ?, step = ?, failed = ?, error = ?, updated_at = ? WHERE saga = ? AND id = ?`),
+		i.Data, string(i.Status), i.Step, i.Failed, i.Error, i.UpdatedAt, i.Saga, i.ID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n != 0 {
+		return nil
+	}
+	// mysql does not count the unchanged rows
+	if _, err := s.Get(ctx, i.Saga, i.ID); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *sqlStore) Get(ctx context.Context, saga, id string) (*Instance, error) {
+	i, err := scan(s.db.QueryRowContext(ctx, s.query(`SELECT `+columns+` FROM {table} WHERE saga = ? AND id = ?`), saga, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return i, err
+}
+
+func (s *sqlStore) Pending(ctx context.Context, saga string) ([]*Instance, error) {
+	rows, err := s.db.QueryContext(ctx, s.query(`SELECT `+columns+` FROM {table} WHERE saga = ? AND status IN (?, ?) ORDER BY created_at`),
+		saga, string(StatusRunning), string(StatusCompensating))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*Instance
+	for rows.Next() {
+		i, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, i)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,75 @@
+package saga
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// Store persists the saga instances
+type Store interface {
+	// Create stores a new instance, it returns ErrExists if the instance already exists
+	Create(ctx context.Context, i *Instance) error
+	Update(ctx context.Context, i *Instance) error
+	Get(ctx context.Context, saga, id string) (*Instance, error)
+	// Pending returns the saga instances which are not done, oldest first
+	Pending(ctx context.Context, saga string) ([]*Instance, error)
+}
+
+type memory struct {
+	mu        sync.RWMutex
+	instances map[[2]string]Instance
+}
+
+// NewMemoryStore returns an in-process Store, e.g. for tests
+func NewMemoryStore() Store {
+	return &memory{instances: make(map[[2]string]Instance)}
+}
+
+func (m *memory) Create(_ context.Context, i *Instance) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := [2]string{i.Saga, i.ID}
+	if _, ok := m.instances[k]; ok {
+		return ErrExists
+	}
+	m.instances[k] = *i
+	return nil
+}
+
+func (m *memory) Update(_ context.Context, i *Instance) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := [2]string{i.Saga, i.ID}
+	if _, ok := m.instances[k]; !ok {
+		return ErrNotFound
+	}
+	m.instances[k] = *i
+	return nil
+}
+
+func (m *memory) Get(_ context.Context, saga, id string) (*Instance, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	i, ok := m.instances[[2]string{saga, id}]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &i, nil
+}
+
+func (m *memory) Pending(_ context.Context, saga string) ([]*Instance, error) {
+	m.mu.RLock()
+	var out []*Instance
+	for _, v := range m.instances {
+		if v.Saga == saga && !v.Status.Done() {
+			i := v
+			out = append(out, &i)
+		}
+	}
+	m.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].CreatedAt.Before(out[j].CreatedAt)
+	})
+	return out, nil
+}
@@ -0,0 +1,151 @@
+// Package schemaregistry encodes the proto messages published on a broker with the Confluent Schema Registry
+// wire format: the messages are prefixed with the id of their registered schema, so that the consumers in any
+// language can look it up and decode them reliably. It works with the Confluent Schema Registry
+// and the registries implementing its api, e.g. the Buf Schema Registry.
+package schemaregistry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const contentType = "application/vnd.schemaregistry.v1+json"
+
+// ErrNotFound is returned when the subject or the schema is not registered
+var ErrNotFound = errors.New("schemaregistry: not found")
+
+// Reference is a schema imported by another one
+type Reference struct {
+	// Name is the import path, e.g. "google/protobuf/timestamp.proto"
+	Name    string `json:"name"`
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+}
+
+// Schema is a registered schema
+type Schema struct {
+	ID         int         `json:"id,omitempty"`
+	Subject    string      `json:"subject,omitempty"`
+	Version    int         `json:"version,omitempty"`
+	SchemaType string      `json:"schemaType,omitempty"`
+	Schema     string      `json:"schema"`
+	References []Reference `json:"references,omitempty"`
+}
+
+// Client is a Confluent Schema Registry api client
+type Client struct {
+	url  string
+	opts options
+}
+
+// NewClient returns a client of the registry at url, e.g. http://schema-registry:8081
+func NewClient(url string, opts ...Option) *Client {
+	c := &Client{url: strings.TrimSuffix(url, "/")}
+	for _, v := range opts {
+		v(&c.opts)
+	}
+	if c.opts.client == nil {
+		c.opts.client = http.DefaultClient
+	}
+	if c.opts.subject == nil {
+		c.opts.subject = TopicNameStrategy
+	}
+	return c
+}
+
+// Register registers the schema under subject, or returns the existing one, and returns its id
+func (c *Client) Register(ctx context.Context, subject string, s Schema) (int, error) {
+	var res Schema
+	if err := c.do(ctx, http.MethodPost, "/subjects/"+url.PathEscape(subject)+"/versions", s, &res); err != nil {
+		return 0, err
+	}
+	return res.ID, nil
+}
+
+// Lookup returns the registered version of the schema under subject, or ErrNotFound
+func (c *Client) Lookup(ctx context.Context, subject string, s Schema) (*Schema, error) {
+	var res Schema
+	if err := c.do(ctx, http.MethodPost, "/subjects/"+url.PathEscape(subject), s, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Compatible checks the schema against the latest version registered under subject,
+// according to the subject compatibility level
+func (c *Client) Compatible(ctx context.Context, subject string, s Schema) (bool, error) {
+	var res struct {
+		IsCompatible bool `json:"is_compatible"`
+	}
+	err := c.do(ctx, http.MethodPost, "/compatibility/subjects/"+url.PathEscape(subject)+"/versions/latest", s, &res)
+	if errors.Is(err, ErrNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return res.IsCompatible, nil
+}
+
+// SchemaByID returns the schema registered with id
+func (c *Client) SchemaByID(ctx context.Context, id int) (*Schema, error) {
+	var res Schema
+	if err := c.do(ctx, http.MethodGet, "/schemas/ids/"+strconv.Itoa(id), nil, &res); err != nil {
+		return nil, err
+	}
+	res.ID = id
+	return &res, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body []byte
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = b
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.url+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", contentType)
+	if in != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.opts.user != "" {
+		req.SetBasicAuth(c.opts.user, c.opts.password)
+	}
+	res, err := c.opts.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", ErrNotFound, strings.TrimSpace(string(b)))
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		var e struct {
+			Code    int    `json:"error_code"`
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(b, &e) == nil && e.Message != "" {
+			return fmt.Errorf("schemaregistry: %s %s: %s (%d)", method, path, e.Message, e.Code)
+		}
+		return fmt.Errorf("schemaregistry: %s %s: %s", method, path, res.Status)
+	}
+	return json.Unmarshal(b, out)
+}
@@ -0,0 +1,57 @@
+package schemaregistry
+
+import (
+	"net/http"
+)
+
+type Option func(o *options)
+
+// WithHTTPClient sets the http client used to call the registry
+func WithHTTPClient(c *http.Client) Option {
+	return func(o *options) {
+		o.client = c
+	}
+}
+
+// WithBasicAuth sets the registry credentials, e.g. the Confluent Cloud api key and secret or the BSR user and token
+func WithBasicAuth(user, password string) Option {
+	return func(o *options) {
+		o.user = user
+		o.password = password
+	}
+}
+
+// WithAutoRegister registers the schemas of the serialized messages and of their imports in the registry.
+// Without it, the schemas must have been registered beforehand, e.g. by the CI, and the serialization
+// of a message which schema is not registered fails.
+func WithAutoRegister() Option {
+	return func(o *options) {
+		o.autoRegister = true
+	}
+}
+
+// WithSubjectNameStrategy sets the function returning the subject of the messages published on topic,
+// it defaults to the TopicNameStrategy
+func WithSubjectNameStrategy(fn func(topic string, fullName string) string) Option {
+	return func(o *options) {
+		o.subject = fn
+	}
+}
+
+// TopicNameStrategy is the Confluent default subject name strategy: {topic}-value
+func TopicNameStrategy(topic, _ string) string {
+	return topic + "-value"
+}
+
+// RecordNameStrategy uses the message full name as subject, e.g. to publish several message types on a topic
+func RecordNameStrategy(_, fullName string) string {
+	return fullName
+}
+
+type options struct {
+	client       *http.Client
+	user         string
+	password     string
+	autoRegister bool
+	subject      func(topic, fullName string) string
+}
@@ -0,0 +1,96 @@
+package schemaregistry
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// registry is a minimal schema registry
+type registry struct {
+	mu      sync.Mutex
+	schemas map[string]Schema
+	calls   int
+}
+
+func (r *registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls++
+	var s Schema
+	if err := json.NewDecoder(req.Body).Decode(&s); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	subject := strings.TrimPrefix(req.URL.Path, "/subjects/")
+	register := strings.HasSuffix(subject, "/versions")
+	subject = strings.TrimSuffix(subject, "/versions")
+	v, ok := r.schemas[subject]
+	switch {
+	case !ok && !register:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error_code": 40401, "message": "Subject not found"}`))
+		return
+	case !ok:
+		v = Schema{ID: len(r.schemas) + 1, Subject: subject, Version: 1, Schema: s.Schema}
+		r.schemas[subject] = v
+	}
+	json.NewEncoder(w).Encode(v)
+}
+
+func TestSerializer(t *testing.T) {
+	reg := &registry{schemas: make(map[string]Schema)}
+	srv := httptest.NewServer(reg)
+	defer srv.Close()
+
+	ctx := context.Background()
+	m := structpb.NewStringValue("hello")
+
+	_, err := NewSerializer(NewClient(srv.URL)).Marshal(ctx, "events", m)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	s := NewSerializer(NewClient(srv.URL, WithAutoRegister()))
+	b, err := s.Marshal(ctx, "events", m)
+	require.NoError(t, err)
+	assert.Contains(t, reg.schemas, "events-value")
+	id, payload, err := Decode(b)
+	require.NoError(t, err)
+	assert.Equal(t, reg.schemas["events-value"].ID, id)
+	// Value is the second message of struct.proto
+	assert.Equal(t, []byte{0, 0, 0, 0, byte(id), 2, 2}, b[:7])
+
+	var got structpb.Value
+	require.NoError(t, Unmarshal(b, &got))
+	assert.True(t, proto.Equal(m, &got))
+	want, err := proto.Marshal(m)
+	require.NoError(t, err)
+	assert.Equal(t, want, payload)
+
+	// the schema ids are cached
+	calls := reg.calls
+	_, err = s.Marshal(ctx, "events", m)
+	require.NoError(t, err)
+	assert.Equal(t, calls, reg.calls)
+
+	b, err = s.Marshal(ctx, "ticks", timestamppb.Now())
+	require.NoError(t, err)
+	// Timestamp is the first message of its file
+	assert.Equal(t, byte(0), b[5])
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	_, _, err := Decode([]byte{1, 0, 0, 0, 1, 0})
+	assert.Equal(t, ErrInvalidPayload, err)
+	_, _, err = Decode([]byte{0, 0})
+	assert.Equal(t, ErrInvalidPayload, err)
+}
@@ -0,0 +1,172 @@
+package schemaregistry
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	magicByte = 0
+	// SchemaTypeProtobuf is the registry schema type of the proto schemas
+	SchemaTypeProtobuf = "PROTOBUF"
+)
+
+// ErrInvalidPayload is returned when decoding a payload which is not in the schema registry wire format
+var ErrInvalidPayload = errors.New("schemaregistry: invalid payload")
+
+// Serializer encodes the messages with the id of their schema
+type Serializer struct {
+	c   *Client
+	mu  sync.RWMutex
+	ids map[string]int
+}
+
+// NewSerializer returns a Serializer looking up, or registering, the schemas with c
+func NewSerializer(c *Client) *Serializer {
+	return &Serializer{c: c, ids: make(map[string]int)}
+}
+
+// Marshal encodes m, published on topic, in the schema registry wire format: the magic byte,
+// the schema id, the message indexes in its file and the message
+func (s *Serializer) Marshal(ctx context.Context, topic string, m proto.Message) ([]byte, error) {
+	md := m.ProtoReflect().Descriptor()
+	id, err := s.SchemaID(ctx, s.c.opts.subject(topic, string(md.FullName())), md.ParentFile())
+	if err != nil {
+		return nil, err
+	}
+	payload, err := proto.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, 5, 5+binary.MaxVarintLen64+len(payload))
+	b[0] = magicByte
+	binary.BigEndian.PutUint32(b[1:], uint32(id))
+	b = appendIndexes(b, indexes(md))
+	return append(b, payload...), nil
+}
+
+// SchemaID returns the id of the schema of fd under subject, it is registered when the client
+// auto registers the schemas
+func (s *Serializer) SchemaID(ctx context.Context, subject string, fd protoreflect.FileDescriptor) (int, error) {
+	key := subject + "\x00" + fd.Path()
+	s.mu.RLock()
+	id, ok := s.ids[key]
+	s.mu.RUnlock()
+	if ok {
+		return id, nil
+	}
+	sc, err := s.schema(ctx, fd)
+	if err != nil {
+		return 0, err
+	}
+	if s.c.opts.autoRegister {
+		id, err = s.c.Register(ctx, subject, sc)
+	} else {
+		var r *Schema
+		if r, err = s.c.Lookup(ctx, subject, sc); err == nil {
+			id = r.ID
+		}
+	}
+	if err != nil {
+		return 0, fmt.Errorf("schemaregistry: %s: %s: %w", subject, fd.Path(), err)
+	}
+	s.mu.Lock()
+	s.ids[key] = id
+	s.mu.Unlock()
+	return id, nil
+}
+
+// schema returns the registry schema of fd, its imports are referenced with their path as subject
+func (s *Serializer) schema(ctx context.Context, fd protoreflect.FileDescriptor) (Schema, error) {
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(protodesc.ToFileDescriptorProto(fd))
+	if err != nil {
+		return Schema{}, err
+	}
+	// the registry accepts the base64 encoded FileDescriptorProto in place of the .proto source
+	sc := Schema{SchemaType: SchemaTypeProtobuf, Schema: base64.StdEncoding.EncodeToString(b)}
+	imports := fd.Imports()
+	for i := 0; i < imports.Len(); i++ {
+		imp := imports.Get(i).FileDescriptor
+		is, err := s.schema(ctx, imp)
+		if err != nil {
+			return Schema{}, err
+		}
+		if s.c.opts.autoRegister {
+			if _, err := s.c.Register(ctx, imp.Path(), is); err != nil {
+				return Schema{}, fmt.Errorf("schemaregistry: %s: %w", imp.Path(), err)
+			}
+		}
+		r, err := s.c.Lookup(ctx, imp.Path(), is)
+		if err != nil {
+			return Schema{}, fmt.Errorf("schemaregistry: %s: %w", imp.Path(), err)
+		}
+		sc.References = append(sc.References, Reference{Name: imp.Path(), Subject: imp.Path(), Version: r.Version})
+	}
+	return sc, nil
+}
+
+// Unmarshal decodes the payload encoded in the schema registry wire format into m
+func Unmarshal(b []byte, m proto.Message) error {
+	_, payload, err := Decode(b)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(payload, m)
+}
+
+// Decode returns the schema id and the message of the payload encoded in the schema registry wire format,
+// e.g. to resolve the message type from the registry with SchemaByID
+func Decode(b []byte) (id int, payload []byte, err error) {
+	if len(b) < 6 || b[0] != magicByte {
+		return 0, nil, ErrInvalidPayload
+	}
+	id = int(binary.BigEndian.Uint32(b[1:5]))
+	b = b[5:]
+	n, l := binary.Varint(b)
+	if l <= 0 || n < 0 {
+		return 0, nil, ErrInvalidPayload
+	}
+	b = b[l:]
+	for i := int64(0); i < n; i++ {
+		if _, l = binary.Varint(b); l <= 0 {
+			return 0, nil, ErrInvalidPayload
+		}
+		b = b[l:]
+	}
+	return id, b, nil
+}
+
+// indexes returns the path of md in its file, e.g. [1, 0] for the first message nested in the second one
+func indexes(md protoreflect.MessageDescriptor) []int {
+	var out []int
+	var d protoreflect.Descriptor = md
+	for {
+		if _, ok := d.(protoreflect.FileDescriptor); ok {
+			break
+		}
+		out = append([]int{d.Index()}, out...)
+		d = d.Parent()
+	}
+	return out
+}
+
+// appendIndexes appends the zigzag varint encoded indexes, with the [0] shortcut for the first message
+func appendIndexes(b []byte, idx []int) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	if len(idx) == 1 && idx[0] == 0 {
+		return append(b, 0)
+	}
+	b = append(b, buf[:binary.PutVarint(buf, int64(len(idx)))]...)
+	for _, v := range idx {
+		b = append(b, buf[:binary.PutVarint(buf, int64(v))]...)
+	}
+	return b
+}
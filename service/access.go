@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/soheilhy/cmux"
+
+	"go.linka.cloud/grpc/logger"
+)
+
+// AccessRule restricts the access to the http routes: it returns false, after writing the response,
+// if the request is not allowed
+type AccessRule func(w http.ResponseWriter, r *http.Request) bool
+
+// BasicAuthAccess only allows the requests with the given basic auth credentials
+func BasicAuthAccess(user, password string) AccessRule {
+	return func(w http.ResponseWriter, r *http.Request) bool {
+		u, p, ok := r.BasicAuth()
+		if ok && subtle.ConstantTimeCompare([]byte(u), []byte(user))&subtle.ConstantTimeCompare([]byte(p), []byte(password)) == 1 {
+			return true
+		}
+		w.Header().Set("WWW-Authenticate", `Basic realm="restricted", charset="UTF-8"`)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return false
+	}
+}
+
+// ClientCertAccess only allows the requests authenticated with a verified client certificate
+func ClientCertAccess() AccessRule {
+	return func(w http.ResponseWriter, r *http.Request) bool {
+		if s := tlsState(r); s != nil && len(s.VerifiedChains) != 0 {
+			return true
+		}
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return false
+	}
+}
+
+// LocalhostAccess only allows the requests from the loopback interface
+func LocalhostAccess() AccessRule {
+	return func(w http.ResponseWriter, r *http.Request) bool {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if ip := net.ParseIP(host); err == nil && ip != nil && ip.IsLoopback() {
+			return true
+		}
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return false
+	}
+}
+
+type tlsConnKey struct{}
+
+// connContext exposes the tls connection to the handlers as the connections multiplexed by cmux
+// are not seen as tls connections by the http server, hence the requests TLS field is not set
+func connContext(ctx context.Context, c net.Conn) context.Context {
+	if m, ok := c.(*cmux.MuxConn); ok {
+		c = m.Conn
+	}
+	if t, ok := c.(*tls.Conn); ok {
+		return context.WithValue(ctx, tlsConnKey{}, t)
+	}
+	return ctx
+}
+
+func tlsState(r *http.Request) *tls.ConnectionState {
+	if r.TLS != nil {
+		return r.TLS
+	}
+	if c, ok := r.Context().Value(tlsConnKey{}).(*tls.Conn); ok {
+		s := c.ConnectionState()
+		return &s
+	}
+	return nil
+}
+
+type accessRules struct {
+	prefix string
+	rules  []AccessRule
+}
+
+// restrict applies the access rules of the longest prefix matching the request path
+func (o *options) restrict(next http.Handler) http.Handler {
+	if len(o.httpAccess) == 0 {
+		return next
+	}
+	rules := append([]accessRules(nil), o.httpAccess...)
+	sort.SliceStable(rules, func(i, j int) bool {
+		return len(rules[i].prefix) > len(rules[j].prefix)
+	})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, v := range rules {
+			if r.URL.Path != v.prefix && !strings.HasPrefix(r.URL.Path, v.prefix+"/") {
+				continue
+			}
+			for _, fn := range v.rules {
+				if !fn(w, r) {
+					return
+				}
+			}
+			break
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// serveAdmin serves the admin routes on their dedicated listener
+func (s *service) serveAdmin() error {
+	if s.adminHandler == nil {
+		return nil
+	}
+	lis, err := net.Listen(s.opts.network, s.opts.adminAddress)
+	if err != nil {
+		return listenError("admin", s.opts.adminAddress, err)
+	}
+	s.adminServer = &http.Server{Handler: s.opts.restrict(s.adminHandler)}
+	go func() {
+		if err := s.adminServer.Serve(lis); err != nil && err != http.ErrServerClosed {
+			logger.C(s.opts.ctx).Errorf("admin server: %v", err)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPAccess(t *testing.T) {
+	o := NewOptions()
+	WithHTTPAccess("/admin", LocalhostAccess())(o)
+	WithHTTPAccess("/admin/secret/", LocalhostAccess(), BasicAuthAccess("admin", "pass"))(o)
+	WithHTTPAccess("/mtls", ClientCertAccess())(o)
+	h := o.restrict(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	do := func(path, remote string, auth bool) int {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.RemoteAddr = remote
+		if auth {
+			r.SetBasicAuth("admin", "pass")
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusOK, do("/api", "10.0.0.1:1234", false))
+	assert.Equal(t, http.StatusOK, do("/administrator", "10.0.0.1:1234", false))
+	assert.Equal(t, http.StatusForbidden, do("/admin/config", "10.0.0.1:1234", false))
+	assert.Equal(t, http.StatusOK, do("/admin/config", "127.0.0.1:1234", false))
+	assert.Equal(t, http.StatusOK, do("/admin", "[::1]:1234", false))
+	assert.Equal(t, http.StatusUnauthorized, do("/admin/secret/key", "127.0.0.1:1234", false))
+	assert.Equal(t, http.StatusOK, do("/admin/secret/key", "127.0.0.1:1234", true))
+	assert.Equal(t, http.StatusForbidden, do("/admin/secret/key", "10.0.0.1:1234", true))
+	assert.Equal(t, http.StatusForbidden, do("/mtls/", "127.0.0.1:1234", true))
+}
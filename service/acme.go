@@ -0,0 +1,45 @@
+package service
+
+import (
+	"net"
+	"net/http"
+
+	"go.linka.cloud/grpc/acme"
+	"go.linka.cloud/grpc/logger"
+)
+
+const defaultACMEChallengeAddress = ":80"
+
+// acmeChallenge mounts the ACME challenge handler, on the service mux when the service is not using tls,
+// on a dedicated plaintext listener otherwise
+func (s *service) acmeChallenge() error {
+	if s.opts.acmeHandler == nil {
+		return nil
+	}
+	if s.opts.tlsConfig == nil {
+		s.opts.mux.Handle(acme.ChallengePath, s.opts.acmeHandler)
+		s.opts.httpRoutes = true
+		return nil
+	}
+	// served by the https redirect listener
+	if s.opts.acmeOnRedirect() {
+		return nil
+	}
+	address := s.opts.acmeAddress
+	if address == "" {
+		address = defaultACMEChallengeAddress
+	}
+	lis, err := net.Listen(s.opts.network, address)
+	if err != nil {
+		return listenError("acme challenge", address, err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle(acme.ChallengePath, s.opts.acmeHandler)
+	s.acmeServer = &http.Server{Handler: mux}
+	go func() {
+		if err := s.acmeServer.Serve(lis); err != nil && err != http.ErrServerClosed {
+			logger.C(s.opts.ctx).Errorf("acme challenge server: %v", err)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,331 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"go.linka.cloud/grpc/logger"
+	"go.linka.cloud/grpc/metrics/otlp"
+)
+
+const redacted = "[redacted]"
+
+// ConfigDump is the resolved configuration of a running service
+type ConfigDump struct {
+	ID              string              `json:"id"`
+	Name            string              `json:"name"`
+	Version         string              `json:"version"`
+	Profile         string              `json:"profile,omitempty"`
+	Address         string              `json:"address"`
+	Reflection      bool                `json:"reflection"`
+	Health          bool                `json:"health"`
+	TLS             TLSDump             `json:"tls"`
+	Registry        string              `json:"registry"`
+	Registration    RegistrationDump    `json:"registration"`
+	TrustedProxies  []string            `json:"trustedProxies,omitempty"`
+	Access          []AccessDump        `json:"access,omitempty"`
+	Log             LogDump             `json:"log"`
+	Metrics         MetricsDump         `json:"metrics"`
+	Gateway         RouteDump           `json:"gateway"`
+	GRPCWeb         RouteDump           `json:"grpcWeb"`
+	ReactUI         bool                `json:"reactUI"`
+	Admin           AdminDump           `json:"admin"`
+	Cors            CorsDump            `json:"cors"`
+	Interceptors    InterceptorsDump    `json:"interceptors"`
+	ShutdownTimeout string              `json:"shutdownTimeout"`
+	DeregisterDelay string              `json:"deregisterDelay"`
+	WaitFor         []string            `json:"waitFor,omitempty"`
+	WaitForTimeout  string              `json:"waitForTimeout"`
+	Modules         []string            `json:"modules,omitempty"`
+	Services        map[string][]string `json:"services"`
+}
+
+type TLSDump struct {
+	Enabled    bool   `json:"enabled"`
+	Secure     bool   `json:"secure"`
+	CACert     string `json:"caCert,omitempty"`
+	Cert       string `json:"cert,omitempty"`
+	Key        string `json:"key,omitempty"`
+	MinVersion string `json:"minVersion,omitempty"`
+	// HandshakeTimeout and MaxHandshakes are the handshakes limits, zero is unlimited
+	HandshakeTimeout string `json:"handshakeTimeout,omitempty"`
+	MaxHandshakes    int    `json:"maxHandshakes,omitempty"`
+}
+
+type RegistrationDump struct {
+	Policy           string `json:"policy"`
+	Timeout          string `json:"timeout"`
+	TTL              string `json:"ttl"`
+	Interval         string `json:"interval"`
+	AddressDetection string `json:"addressDetection,omitempty"`
+}
+
+// AccessDump is the number of access rules of a routes prefix, the rules themselves are not printable
+type AccessDump struct {
+	Prefix string `json:"prefix"`
+	Rules  int    `json:"rules"`
+}
+
+type LogDump struct {
+	Format         string `json:"format,omitempty"`
+	SamplingBurst  int    `json:"samplingBurst,omitempty"`
+	SamplingPeriod string `json:"samplingPeriod,omitempty"`
+}
+
+type MetricsDump struct {
+	Runtime    bool      `json:"runtime"`
+	Registerer bool      `json:"registerer"`
+	OTLP       *OTLPDump `json:"otlp,omitempty"`
+}
+
+type OTLPDump struct {
+	Endpoint string            `json:"endpoint"`
+	Interval string            `json:"interval"`
+	Timeout  string            `json:"timeout"`
+	Headers  map[string]string `json:"headers,omitempty"`
+}
+
+type RouteDump struct {
+	Enabled bool   `json:"enabled"`
+	Prefix  string `json:"prefix,omitempty"`
+}
+
+type AdminDump struct {
+	Enabled bool   `json:"enabled"`
+	Prefix  string `json:"prefix,omitempty"`
+	Address string `json:"address,omitempty"`
+}
+
+type CorsDump struct {
+	AllowedOrigins   []string `json:"allowedOrigins,omitempty"`
+	AllowedMethods   []string `json:"allowedMethods,omitempty"`
+	AllowedHeaders   []string `json:"allowedHeaders,omitempty"`
+	ExposedHeaders   []string `json:"exposedHeaders,omitempty"`
+	AllowCredentials bool     `json:"allowCredentials"`
+}
+
+type InterceptorsDump struct {
+	UnaryServer  int `json:"unaryServer"`
+	StreamServer int `json:"streamServer"`
+	UnaryClient  int `json:"unaryClient"`
+	StreamClient int `json:"streamClient"`
+}
+
+// ConfigDump returns the resolved configuration as json, secrets are redacted
+func (s *service) ConfigDump() ([]byte, error) {
+	s.mu.Lock()
+	d := s.configDump()
+	s.mu.Unlock()
+	return json.MarshalIndent(d, "", "  ")
+}
+
+func (s *service) configDump() ConfigDump {
+	o := s.opts
+	d := ConfigDump{
+		ID:         s.id,
+		Name:       o.name,
+		Version:    o.version,
+		Profile:    o.profile,
+		Address:    o.address,
+		Reflection: o.reflection,
+		Health:     o.health,
+		TLS: TLSDump{
+			Enabled: o.tlsConfig != nil,
+			Secure:  o.secure,
+			CACert:  o.caCert,
+			Cert:    o.cert,
+		},
+		Registration: RegistrationDump{
+			Policy:   o.registrationPolicy.String(),
+			Timeout:  o.registrationTimeout.String(),
+			TTL:      o.registerTTL().String(),
+			Interval: o.registerInterval().String(),
+		},
+		TrustedProxies: o.trustedProxies.Proxies(),
+		Log: LogDump{
+			Format:        logFormat(o.logFormatter),
+			SamplingBurst: o.logSamplingBurst,
+		},
+		Metrics: MetricsDump{
+			Runtime:    o.runtimeMetrics,
+			Registerer: o.metricsRegisterer != nil,
+		},
+		Gateway: RouteDump{Enabled: o.Gateway(), Prefix: o.gatewayPrefix},
+		GRPCWeb: RouteDump{Enabled: o.grpcWeb, Prefix: o.grpcWebPrefix},
+		ReactUI: o.hasReactUI,
+		Admin:   AdminDump{Enabled: o.adminPrefix != "", Prefix: o.adminPrefix, Address: o.adminAddress},
+		Cors: CorsDump{
+			AllowedOrigins:   o.cors.AllowedOrigins,
+			AllowedMethods:   o.cors.AllowedMethods,
+			AllowedHeaders:   o.cors.AllowedHeaders,
+			ExposedHeaders:   o.cors.ExposedHeaders,
+			AllowCredentials: o.cors.AllowCredentials,
+		},
+		Interceptors: InterceptorsDump{
+			UnaryServer:  len(o.unaryServerInterceptors),
+			StreamServer: len(o.streamServerInterceptors),
+			UnaryClient:  len(o.unaryClientInterceptors),
+			StreamClient: len(o.streamClientInterceptors),
+		},
+		ShutdownTimeout: o.shutdownTimeout.String(),
+		DeregisterDelay: o.deregisterDelay.String(),
+		WaitForTimeout:  o.waitForTimeout.String(),
+		Services:        make(map[string][]string),
+	}
+	if o.key != "" {
+		d.TLS.Key = redacted
+	}
+	if o.tlsConfig != nil {
+		d.TLS.MinVersion = tlsVersionName(o.tlsConfig.MinVersion)
+		timeout := o.tlsHandshakeTimeout
+		if timeout == 0 {
+			timeout = DefaultTLSHandshakeTimeout
+		}
+		d.TLS.HandshakeTimeout = timeout.String()
+		d.TLS.MaxHandshakes = o.maxTLSHandshakes
+	}
+	if o.addressDetection > 0 {
+		d.Registration.AddressDetection = o.addressDetection.String()
+	}
+	for _, v := range o.httpAccess {
+		d.Access = append(d.Access, AccessDump{Prefix: v.prefix, Rules: len(v.rules)})
+	}
+	if o.logSamplingBurst > 0 {
+		d.Log.SamplingPeriod = o.logSamplingPeriod.String()
+	}
+	if o.otlp {
+		c := otlp.Resolve(o.otlpOpts...)
+		d.Metrics.OTLP = &OTLPDump{
+			Endpoint: redactURL(c.Endpoint),
+			Interval: c.Interval.String(),
+			Timeout:  c.Timeout.String(),
+		}
+		// the headers usually carry the collector credentials
+		for k := range c.Headers {
+			if d.Metrics.OTLP.Headers == nil {
+				d.Metrics.OTLP.Headers = make(map[string]string)
+			}
+			d.Metrics.OTLP.Headers[k] = redacted
+		}
+	}
+	if o.registry != nil {
+		d.Registry = o.registry.String()
+	}
+	for _, v := range o.waitFor {
+		d.WaitFor = append(d.WaitFor, v.Name())
+	}
+	for _, v := range s.modules {
+		d.Modules = append(d.Modules, v.Name())
+	}
+	for k, v := range s.GetServiceInfo() {
+		var methods []string
+		for _, m := range v.Methods {
+			methods = append(methods, m.Name)
+		}
+		sort.Strings(methods)
+		d.Services[k] = methods
+	}
+	return d
+}
+
+// logFormat returns the name of the log format, or the formatter type if it is not one of the logger formats
+func logFormat(f logrus.Formatter) string {
+	switch f.(type) {
+	case nil:
+		return ""
+	case *logrus.JSONFormatter:
+		return string(logger.FormatJSON)
+	case *logrus.TextFormatter:
+		return string(logger.FormatText)
+	}
+	return fmt.Sprintf("%T", f)
+}
+
+// redactURL redacts the password and the query of u, which may carry credentials
+func redactURL(u string) string {
+	v, err := url.Parse(u)
+	if err != nil {
+		return redacted
+	}
+	if _, ok := v.User.Password(); ok {
+		v.User = url.UserPassword(v.User.Username(), "xxxxx")
+	}
+	if v.RawQuery != "" {
+		v.RawQuery = "xxxxx"
+	}
+	return v.String()
+}
+
+// admin registers the admin routes on the service mux, or on the admin listener mux if an admin address is set
+func (s *service) admin() {
+	if s.opts.adminPrefix == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/config", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		b, err := s.ConfigDump()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+	})
+	mux.HandleFunc("/descriptors", s.serveDescriptors)
+	for _, v := range s.opts.adminHandlers {
+		mux.Handle(v.pattern, v.handler)
+	}
+	h := http.StripPrefix(s.opts.adminPrefix, mux)
+	if s.opts.adminAddress == "" {
+		s.opts.mux.Handle(s.opts.adminPrefix+"/", h)
+		return
+	}
+	m := http.NewServeMux()
+	m.Handle(s.opts.adminPrefix+"/", h)
+	s.adminHandler = m
+}
+
+// serveDescriptors serves the FileDescriptorSet of the registered services, in binary,
+// or as json when requested with the format=json query parameter or the application/json Accept header
+func (s *service) serveDescriptors(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	set, err := s.Descriptors()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var b []byte
+	if r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json") {
+		b, err = protojson.MarshalOptions{Multiline: true}.Marshal(set)
+		w.Header().Set("Content-Type", "application/json")
+	} else {
+		b, err = proto.MarshalOptions{Deterministic: true}.Marshal(set)
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.Header().Set("Content-Disposition", `attachment; filename="descriptors.binpb"`)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(b)
+}
+
+type adminHandler struct {
+	pattern string
+	handler http.Handler
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"sort"
+
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"go.linka.cloud/grpc/compat"
+)
+
+// checkCompatibility checks the registered services against the baseline set with WithCompatibilityCheck
+func (s *service) checkCompatibility() error {
+	if s.opts.compatBaseline == "" {
+		return nil
+	}
+	baseline, err := compat.Load(s.opts.compatBaseline)
+	if err != nil {
+		return err
+	}
+	current, err := s.descriptors()
+	if err != nil {
+		return err
+	}
+	return compat.Check(baseline, current)
+}
+
+// Descriptors returns the FileDescriptorSet of the registered services and of their dependencies
+func (s *service) Descriptors() (*descriptorpb.FileDescriptorSet, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.descriptors()
+}
+
+func (s *service) descriptors() (*descriptorpb.FileDescriptorSet, error) {
+	names := make([]string, 0, len(s.services))
+	for k := range s.services {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return compat.Registered(names...)
+}
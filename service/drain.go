@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+
+	"go.linka.cloud/grpc/interceptors/metadata"
+)
+
+type drainingKey struct{}
+
+// Draining returns a channel closed when the service serving the request starts draining its connections
+// on shutdown, after the deregister delay, so that long-lived stream handlers can checkpoint and let the clients reconnect before the hard stop.
+// It returns a nil channel, which never closes, if the context is not a service handler context.
+func Draining(ctx context.Context) <-chan struct{} {
+	ch, _ := ctx.Value(drainingKey{}).(chan struct{})
+	return ch
+}
+
+// drainingChan returns the channel closed when the current run is stopping,
+// the requests received while stopping get the same, already closed, channel
+func (s *service) drainingChan() chan struct{} {
+	s.drainMu.Lock()
+	defer s.drainMu.Unlock()
+	if s.draining == nil {
+		s.draining = make(chan struct{})
+	}
+	return s.draining
+}
+
+// drain signals the handlers that the service is shutting down
+func (s *service) drain() {
+	ch := s.drainingChan()
+	s.drainMu.Lock()
+	defer s.drainMu.Unlock()
+	select {
+	case <-ch:
+	default:
+		close(ch)
+	}
+}
+
+// resetDrain gives the next run a new channel if the previous run drained
+func (s *service) resetDrain() {
+	s.drainMu.Lock()
+	defer s.drainMu.Unlock()
+	if s.draining == nil {
+		return
+	}
+	select {
+	case <-s.draining:
+		s.draining = nil
+	default:
+	}
+}
+
+// drainInterceptors exposes the draining channel to the handlers, see Draining
+func (s *service) drainInterceptors() (grpc.UnaryServerInterceptor, grpc.StreamServerInterceptor) {
+	unary := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(context.WithValue(ctx, drainingKey{}, s.drainingChan()), req)
+	}
+	stream := func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := context.WithValue(ss.Context(), drainingKey{}, s.drainingChan())
+		return handler(srv, metadata.NewContextServerStream(ctx, ss))
+	}
+	return unary, stream
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+const requestIDHeader = "X-Request-Id"
+
+// Envelope is the gateway response body when WithGatewayEnvelope is set
+type Envelope struct {
+	Data      json.RawMessage `json:"data,omitempty"`
+	Error     json.RawMessage `json:"error,omitempty"`
+	RequestID string          `json:"request_id,omitempty"`
+}
+
+// gatewayResponse wraps the gateway responses in the Envelope and / or pretty prints them
+func (s *service) gatewayResponse(next http.Handler) http.Handler {
+	if !s.opts.gatewayEnvelope && !s.opts.gatewayPretty {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pretty := s.opts.gatewayPretty && isPretty(r)
+		if pretty {
+			// the parameter is not part of the api
+			q := r.URL.Query()
+			q.Del("pretty")
+			r.URL.RawQuery = q.Encode()
+		}
+		// websockets and streams are not buffered
+		if r.Header.Get("Upgrade") != "" || (!s.opts.gatewayEnvelope && !pretty) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ew := &envelopeWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(ew, r)
+		if ew.passthrough {
+			return
+		}
+		body := ew.buf.Bytes()
+		if !isJSON(w.Header().Get("Content-Type")) || len(body) == 0 {
+			ew.flushBuffer()
+			return
+		}
+		if s.opts.gatewayEnvelope {
+			e := Envelope{RequestID: requestID(r, w)}
+			if ew.status >= http.StatusBadRequest {
+				e.Error = body
+			} else {
+				e.Data = body
+			}
+			b, err := json.Marshal(e)
+			if err != nil {
+				ew.flushBuffer()
+				return
+			}
+			body = b
+		}
+		if pretty {
+			var b bytes.Buffer
+			if err := json.Indent(&b, body, "", "  "); err == nil {
+				body = b.Bytes()
+			}
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(ew.status)
+		w.Write(body)
+	})
+}
+
+func isPretty(r *http.Request) bool {
+	v, ok := r.URL.Query()["pretty"]
+	if !ok {
+		return false
+	}
+	if len(v) == 0 || v[0] == "" {
+		return true
+	}
+	b, err := strconv.ParseBool(v[0])
+	return err == nil && b
+}
+
+func isJSON(contentType string) bool {
+	return strings.HasPrefix(contentType, "application/json")
+}
+
+func requestID(r *http.Request, w http.ResponseWriter) string {
+	if v := r.Header.Get(requestIDHeader); v != "" {
+		return v
+	}
+	if v := w.Header().Get(requestIDHeader); v != "" {
+		return v
+	}
+	return w.Header().Get(runtime.MetadataHeaderPrefix + requestIDHeader)
+}
+
+// envelopeWriter buffers the response, it switches to passthrough when the handler flushes, i.e. for server streams
+type envelopeWriter struct {
+	http.ResponseWriter
+	status      int
+	buf         bytes.Buffer
+	passthrough bool
+}
+
+func (w *envelopeWriter) WriteHeader(code int) {
+	if w.passthrough {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.status = code
+}
+
+func (w *envelopeWriter) Write(b []byte) (int, error) {
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.buf.Write(b)
+}
+
+func (w *envelopeWriter) Flush() {
+	if !w.passthrough {
+		w.flushBuffer()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *envelopeWriter) flushBuffer() {
+	w.passthrough = true
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.Write(w.buf.Bytes())
+	w.buf.Reset()
+}
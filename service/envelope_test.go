@@ -0,0 +1,76 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGatewayResponse(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.URL.Query().Get("pretty"))
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/error" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"code":5,"message":"not found"}`))
+			return
+		}
+		w.Write([]byte(`{"message":"hello"}`))
+	})
+	tests := []struct {
+		name     string
+		opts     []Option
+		path     string
+		reqID    string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "disabled",
+			path:     "/?pretty",
+			wantCode: http.StatusOK,
+			wantBody: `{"message":"hello"}`,
+		},
+		{
+			name:     "pretty",
+			opts:     []Option{WithGatewayPrettyPrint()},
+			path:     "/?pretty=1",
+			wantCode: http.StatusOK,
+			wantBody: "{\n  \"message\": \"hello\"\n}",
+		},
+		{
+			name:     "envelope",
+			opts:     []Option{WithGatewayEnvelope()},
+			path:     "/",
+			reqID:    "id",
+			wantCode: http.StatusOK,
+			wantBody: `{"data":{"message":"hello"},"request_id":"id"}`,
+		},
+		{
+			name:     "envelope error",
+			opts:     []Option{WithGatewayEnvelope()},
+			path:     "/error",
+			wantCode: http.StatusNotFound,
+			wantBody: `{"error":{"code":5,"message":"not found"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := NewOptions()
+			for _, v := range tt.opts {
+				v(o)
+			}
+			s := &service{opts: o}
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.reqID != "" {
+				req.Header.Set(requestIDHeader, tt.reqID)
+			}
+			rec := httptest.NewRecorder()
+			s.gatewayResponse(h).ServeHTTP(rec, req)
+			assert.Equal(t, tt.wantCode, rec.Code)
+			assert.Equal(t, tt.wantBody, rec.Body.String())
+		})
+	}
+}
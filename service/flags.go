@@ -6,6 +6,8 @@ import (
 
 	env "github.com/caitlinelfring/go-env-default"
 	"github.com/spf13/pflag"
+
+	"go.linka.cloud/grpc/logger"
 )
 
 const (
@@ -13,6 +15,7 @@ const (
 
 	insecure   = "insecure"
 	reflection = "reflection"
+	logFormat  = "log-format"
 
 	caCert     = "ca-cert"
 	serverCert = "server-cert"
@@ -29,6 +32,7 @@ func NewFlagSet() (*pflag.FlagSet, Option) {
 		optCACert     string
 		optCert       string
 		optKey        string
+		optLogFormat  string
 	)
 	flags := pflag.NewFlagSet("gRPC", pflag.ContinueOnError)
 	flags.StringVarP(&optAddress, serverAddress, "a", env.GetDefault(u(serverAddress), "0.0.0.0:0"), "Bind address for the server, e.g. 127.0.0.1:9090"+flagEnv(serverAddress))
@@ -37,10 +41,19 @@ func NewFlagSet() (*pflag.FlagSet, Option) {
 	flags.StringVar(&optCACert, caCert, "", "Path to Root CA certificate"+flagEnv(caCert))
 	flags.StringVar(&optCert, serverCert, "", "Path to Server certificate"+flagEnv(serverCert))
 	flags.StringVar(&optKey, serverKey, "", "Path to Server key"+flagEnv(serverKey))
+	flags.StringVar(&optLogFormat, logFormat, env.GetDefault(envName(logFormat), ""), "Logs format: text or json"+flagEnv(logFormat))
 	return flags, func(o *options) {
 		o.address = optAddress
 		o.secure = !optInsecure
 		o.reflection = optReflection
+		if optLogFormat != "" {
+			f, err := logger.ParseFormat(optLogFormat)
+			if err != nil {
+				o.fail(logFormat, err)
+			} else {
+				WithLogFormat(f)(o)
+			}
+		}
 		// o.caCert = optCACert
 		// o.cert = optCert
 		// o.key = optKey
@@ -48,5 +61,9 @@ func NewFlagSet() (*pflag.FlagSet, Option) {
 }
 
 func flagEnv(name string) string {
-	return fmt.Sprintf(" [$%s]", strings.Replace(u(name), "-", "_", -1))
+	return fmt.Sprintf(" [$%s]", envName(name))
+}
+
+func envName(name string) string {
+	return strings.Replace(u(name), "-", "_", -1)
 }
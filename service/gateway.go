@@ -1,30 +1,135 @@
 package service
 
 import (
+	"context"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/utilities"
+	"github.com/justinas/alice"
 	"github.com/tmc/grpc-websocket-proxy/wsproxy"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
-var defaultGatewayOptions = []runtime.ServeMuxOption{
-	runtime.WithIncomingHeaderMatcher(func(s string) (string, bool) {
-		return s, true
-	}),
+const pushbackMetadataKey = "grpc-retry-pushback-ms"
+
+// DefaultGatewayHeaderMappings are the http headers exchanged with the grpc metadata without prefix,
+// so that the W3C trace context and baggage flow across the gateway
+var DefaultGatewayHeaderMappings = map[string]string{
+	"traceparent": "traceparent",
+	"tracestate":  "tracestate",
+	"baggage":     "baggage",
+}
+
+func (s *service) gatewayOptions() []runtime.ServeMuxOption {
+	in := make(map[string]string)
+	out := make(map[string]string)
+	for _, m := range []map[string]string{DefaultGatewayHeaderMappings, s.opts.gatewayHeaders} {
+		for h, k := range m {
+			in[strings.ToLower(h)] = strings.ToLower(k)
+			out[strings.ToLower(k)] = h
+		}
+	}
+	opts := []runtime.ServeMuxOption{
+		runtime.WithIncomingHeaderMatcher(func(h string) (string, bool) {
+			if k, ok := in[strings.ToLower(h)]; ok {
+				return k, true
+			}
+			return h, true
+		}),
+		runtime.WithOutgoingHeaderMatcher(func(k string) (string, bool) {
+			if h, ok := out[strings.ToLower(k)]; ok {
+				return h, true
+			}
+			return runtime.MetadataHeaderPrefix + k, true
+		}),
+		runtime.WithErrorHandler(s.gatewayErrorHandler),
+	}
+	if len(s.opts.gatewayTrailers) != 0 {
+		opts = append(opts, runtime.WithForwardResponseOption(s.gatewayTrailers))
+	}
+	if s.opts.gatewayUnescapingMode != nil {
+		opts = append(opts, runtime.WithUnescapingMode(*s.opts.gatewayUnescapingMode))
+	}
+	if s.opts.gatewayQueryParser != nil {
+		opts = append(opts, runtime.SetQueryParameterParser(s.opts.gatewayQueryParser))
+	}
+	if s.opts.gatewayDisablePathLengthFallback {
+		opts = append(opts, runtime.WithDisablePathLengthFallback())
+	}
+	return opts
+}
+
+// noQueryParser ignores the query parameters
+type noQueryParser struct{}
+
+func (noQueryParser) Parse(proto.Message, url.Values, *utilities.DoubleArray) error {
+	return nil
 }
 
 func (s *service) gateway(opts ...runtime.ServeMuxOption) error {
 	if !s.opts.Gateway() {
 		return nil
 	}
-	mux := runtime.NewServeMux(append(defaultGatewayOptions, opts...)...)
-	if err := s.opts.gateway(s.opts.ctx, mux, s.inproc); err != nil {
-		return err
+	mux := runtime.NewServeMux(append(s.gatewayOptions(), opts...)...)
+	fns := s.opts.gatewayHandlers
+	if s.opts.gateway != nil {
+		fns = append([]RegisterGatewayFunc{s.opts.gateway}, fns...)
+	}
+	for _, fn := range fns {
+		if err := fn(s.opts.ctx, mux, s.inproc); err != nil {
+			return err
+		}
 	}
+	h := alice.New(s.opts.gatewayMiddlewares...).Then(s.gatewayResponse(wsproxy.WebsocketProxy(mux)))
 	if s.opts.gatewayPrefix != "" {
-		s.opts.mux.Handle(s.opts.gatewayPrefix+"/", http.StripPrefix(s.opts.gatewayPrefix, wsproxy.WebsocketProxy(mux)))
+		s.opts.mux.Handle(s.opts.gatewayPrefix+"/", http.StripPrefix(s.opts.gatewayPrefix, h))
 	} else {
-		s.opts.mux.Handle("/", wsproxy.WebsocketProxy(mux))
+		s.opts.mux.Handle("/", h)
 	}
 	return nil
 }
+
+// gatewayErrorHandler counts the transcoding errors and returns the mapped trailers and the Retry-After header
+// before delegating to the default handler, which answers ResourceExhausted with 429 and Unavailable with 503
+func (s *service) gatewayErrorHandler(ctx context.Context, mux *runtime.ServeMux, m runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+	s.metrics.gatewayErrors.WithLabelValues(status.Convert(err).Code().String()).Inc()
+	s.gatewayTrailers(ctx, w, nil)
+	if d, ok := s.retryAfter(ctx, err); ok {
+		w.Header().Set("Retry-After", strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10))
+	}
+	runtime.DefaultHTTPErrorHandler(ctx, mux, m, w, r, err)
+}
+
+// retryAfter returns the delay the http clients should wait before retrying: the RetryInfo error details,
+// the grpc-retry-pushback-ms trailer, or the default delay for the load shedding and rate limiting statuses
+func (s *service) retryAfter(ctx context.Context, err error) (time.Duration, bool) {
+	st := status.Convert(err)
+	for _, v := range st.Details() {
+		if ri, ok := v.(*errdetails.RetryInfo); ok && ri.GetRetryDelay() != nil {
+			return ri.GetRetryDelay().AsDuration(), true
+		}
+	}
+	if md, ok := runtime.ServerMetadataFromContext(ctx); ok {
+		if v := md.TrailerMD.Get(pushbackMetadataKey); len(v) != 0 {
+			// a negative pushback asks the clients not to retry
+			if ms, err := strconv.Atoi(v[0]); err == nil && ms >= 0 {
+				return time.Duration(ms) * time.Millisecond, true
+			}
+			return 0, false
+		}
+	}
+	switch st.Code() {
+	case codes.ResourceExhausted, codes.Unavailable:
+		return s.opts.gatewayRetryAfter, s.opts.gatewayRetryAfter > 0
+	}
+	return 0, false
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+
+	"go.linka.cloud/grpc/errors"
+	"go.linka.cloud/grpc/interceptors/retry"
+)
+
+func TestGatewayRetryAfter(t *testing.T) {
+	o := NewOptions()
+	WithGatewayRetryAfter(5 * time.Second)(o)
+	s := &service{opts: o}
+	ctx := context.Background()
+	withTrailer := func(v string) context.Context {
+		return runtime.NewServerMetadataContext(ctx, runtime.ServerMetadata{TrailerMD: metadata.Pairs(pushbackMetadataKey, v)})
+	}
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want time.Duration
+		ok   bool
+	}{
+		{name: "retry info", ctx: ctx, err: errors.Unavailabled(fmt.Errorf("down"), retry.Pushback(2*time.Second)), want: 2 * time.Second, ok: true},
+		{name: "pushback", ctx: withTrailer("1500"), err: errors.ResourceExhaustedf("quota"), want: 1500 * time.Millisecond, ok: true},
+		{name: "negative pushback", ctx: withTrailer("-1"), err: errors.ResourceExhaustedf("quota")},
+		{name: "default", ctx: ctx, err: errors.Unavailablef("overloaded"), want: 5 * time.Second, ok: true},
+		{name: "other code", ctx: ctx, err: errors.NotFoundf("missing")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, ok := s.retryAfter(tt.ctx, tt.err)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, d)
+		})
+	}
+}
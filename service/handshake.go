@@ -0,0 +1,158 @@
+package service
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.linka.cloud/grpc/logger"
+)
+
+// DefaultTLSHandshakeTimeout is the TLS handshake timeout used when none is configured
+const DefaultTLSHandshakeTimeout = 10 * time.Second
+
+// the causes of the failed handshakes, reported by the tls_handshake_failures_total metric
+const (
+	handshakeTimeout     = "timeout"
+	handshakeLimit       = "limit"
+	handshakeNotTLS      = "not_tls"
+	handshakeClosed      = "closed"
+	handshakeCertificate = "certificate"
+	handshakeOther       = "other"
+)
+
+// tlsListener returns a listener completing the TLS handshakes before returning the connections,
+// so that the slow or stalled handshakes are bounded by the handshake timeout and do not hold the listener,
+// and the handshakes above the concurrent handshakes limit are refused
+func (s *service) tlsListener(l net.Listener) net.Listener {
+	timeout := s.opts.tlsHandshakeTimeout
+	if timeout == 0 {
+		timeout = DefaultTLSHandshakeTimeout
+	}
+	h := &handshakeListener{
+		Listener: l,
+		s:        s,
+		config:   s.opts.tlsConfig,
+		timeout:  timeout,
+		conns:    make(chan net.Conn),
+		stopped:  make(chan struct{}),
+	}
+	if s.opts.maxTLSHandshakes > 0 {
+		h.sem = make(chan struct{}, s.opts.maxTLSHandshakes)
+	}
+	go h.serve()
+	return h
+}
+
+type handshakeListener struct {
+	net.Listener
+	s       *service
+	config  *tls.Config
+	timeout time.Duration
+	// sem bounds the concurrent handshakes, it is nil if unlimited
+	sem   chan struct{}
+	conns chan net.Conn
+
+	// stopped is closed with the accept error once the listener does not accept connections anymore
+	stopped chan struct{}
+	err     error
+}
+
+func (l *handshakeListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.stopped:
+		return nil, l.err
+	}
+}
+
+func (l *handshakeListener) serve() {
+	var delay time.Duration
+	for {
+		c, err := l.Listener.Accept()
+		if ne, ok := err.(net.Error); ok && ne.Temporary() {
+			// the same backoff as the http server
+			if delay == 0 {
+				delay = 5 * time.Millisecond
+			} else if delay *= 2; delay > time.Second {
+				delay = time.Second
+			}
+			time.Sleep(delay)
+			continue
+		}
+		if err != nil {
+			l.err = err
+			close(l.stopped)
+			return
+		}
+		delay = 0
+		if l.sem != nil {
+			select {
+			case l.sem <- struct{}{}:
+			default:
+				l.failed(c, handshakeLimit, errors.New("too many concurrent handshakes"))
+				continue
+			}
+		}
+		go l.handshake(c)
+	}
+}
+
+func (l *handshakeListener) handshake(c net.Conn) {
+	m := l.s.metrics
+	m.tlsHandshakes.Inc()
+	defer m.tlsHandshakes.Dec()
+	if l.sem != nil {
+		defer func() { <-l.sem }()
+	}
+	tc := tls.Server(c, l.config)
+	if l.timeout > 0 {
+		c.SetDeadline(time.Now().Add(l.timeout))
+	}
+	if err := tc.Handshake(); err != nil {
+		l.failed(c, handshakeCause(err), err)
+		return
+	}
+	c.SetDeadline(time.Time{})
+	select {
+	case l.conns <- tc:
+	case <-l.stopped:
+		tc.Close()
+	}
+}
+
+func (l *handshakeListener) failed(c net.Conn, cause string, err error) {
+	l.s.metrics.tlsHandshakeFailures.WithLabelValues(cause).Inc()
+	logger.C(l.s.opts.ctx).Debugf("tls handshake from %v failed: %s: %v", c.RemoteAddr(), cause, err)
+	c.Close()
+}
+
+// handshakeCause returns the cause of the failed handshake
+func handshakeCause(err error) string {
+	var (
+		ne net.Error
+		rh tls.RecordHeaderError
+		ua x509.UnknownAuthorityError
+		ci x509.CertificateInvalidError
+		he x509.HostnameError
+	)
+	switch {
+	case errors.As(err, &ne) && ne.Timeout():
+		return handshakeTimeout
+	case errors.As(err, &rh):
+		// e.g. a plaintext request
+		return handshakeNotTLS
+	case errors.Is(err, io.EOF) || errors.Is(err, syscall.ECONNRESET):
+		return handshakeClosed
+	case errors.As(err, &ua) || errors.As(err, &ci) || errors.As(err, &he),
+		strings.Contains(err.Error(), "certificate"):
+		return handshakeCertificate
+	}
+	return handshakeOther
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"crypto/tls"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.linka.cloud/grpc/certs"
+)
+
+func TestTLSListener(t *testing.T) {
+	cert, err := certs.New("localhost")
+	require.NoError(t, err)
+	o := NewOptions()
+	o.tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	WithTLSHandshakeTimeout(100 * time.Millisecond)(o)
+	WithMaxConcurrentTLSHandshakes(1)(o)
+	s := &service{opts: o, metrics: newMetrics(nil)}
+
+	raw, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	lis := s.tlsListener(raw)
+	defer lis.Close()
+	failures := func(cause string) float64 {
+		return testutil.ToFloat64(s.metrics.tlsHandshakeFailures.WithLabelValues(cause))
+	}
+
+	// the stalled handshake holds the only slot until it times out
+	stalled, err := net.Dial("tcp", lis.Addr().String())
+	require.NoError(t, err)
+	defer stalled.Close()
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(s.metrics.tlsHandshakes) == 1
+	}, time.Second, 10*time.Millisecond)
+	refused, err := net.Dial("tcp", lis.Addr().String())
+	require.NoError(t, err)
+	defer refused.Close()
+	assert.Eventually(t, func() bool { return failures(handshakeLimit) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Eventually(t, func() bool { return failures(handshakeTimeout) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(s.metrics.tlsHandshakes) == 0
+	}, time.Second, 10*time.Millisecond)
+
+	plain, err := net.Dial("tcp", lis.Addr().String())
+	require.NoError(t, err)
+	defer plain.Close()
+	_, err = plain.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool { return failures(handshakeNotTLS) == 1 }, time.Second, 10*time.Millisecond)
+
+	errs := make(chan error, 1)
+	go func() {
+		c, err := tls.Dial("tcp", lis.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+		if err == nil {
+			err = c.Handshake()
+			c.Close()
+		}
+		errs <- err
+	}()
+	c, err := lis.Accept()
+	require.NoError(t, err)
+	defer c.Close()
+	assert.IsType(t, &tls.Conn{}, c)
+	assert.NoError(t, <-errs)
+
+	lis.Close()
+	_, err = lis.Accept()
+	assert.Error(t, err)
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/stats"
+
+	metadata2 "go.linka.cloud/grpc/interceptors/metadata"
+	"go.linka.cloud/grpc/rpcctx"
+)
+
+// inprocInterceptors returns the interceptors running before the server chain for the in-process channel
+// used by the gateway, so that its calls look like the network ones:
+//   - the request is flagged with rpcctx.WithInProcess
+//   - the peer address is the http client one, i.e. the last X-Forwarded-For value added by the gateway
+//   - the rpc begin and end are reported to the stats handler, without connection nor payload events
+//
+// The metadata, including the tracing one, and the deadline are propagated by the channel itself.
+// The transport options, e.g. compression, message size limits or keepalive, do not apply, and the
+// TLS information is only available with rpcctx.PeerAuth.
+func (s *service) inprocInterceptors() (grpc.UnaryServerInterceptor, grpc.StreamServerInterceptor) {
+	unary := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (res interface{}, err error) {
+		ctx, end := s.inprocBegin(ctx, info.FullMethod)
+		defer func() {
+			end(err)
+		}()
+		return handler(ctx, req)
+	}
+	stream := func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		ctx, end := s.inprocBegin(ss.Context(), info.FullMethod)
+		defer func() {
+			end(err)
+		}()
+		return handler(srv, metadata2.NewContextServerStream(ctx, ss))
+	}
+	return unary, stream
+}
+
+func (s *service) inprocBegin(ctx context.Context, method string) (context.Context, func(err error)) {
+	ctx = rpcctx.WithInProcess(ctx)
+	if _, ok := peer.FromContext(ctx); !ok {
+		if a, ok := forwardedAddr(ctx); ok {
+			ctx = peer.NewContext(ctx, &peer.Peer{Addr: a})
+		}
+	}
+	ctx = s.stats.TagRPC(ctx, &stats.RPCTagInfo{FullMethodName: method})
+	begin := time.Now()
+	s.stats.HandleRPC(ctx, &stats.Begin{BeginTime: begin})
+	return ctx, func(err error) {
+		s.stats.HandleRPC(ctx, &stats.End{BeginTime: begin, EndTime: time.Now(), Error: err})
+	}
+}
+
+// forwardedAddr returns the client address appended by the gateway to the x-forwarded-for metadata
+func forwardedAddr(ctx context.Context) (net.Addr, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, false
+	}
+	v := md.Get("x-forwarded-for")
+	if len(v) == 0 {
+		return nil, false
+	}
+	parts := strings.Split(v[len(v)-1], ",")
+	ip := net.ParseIP(strings.TrimSpace(parts[len(parts)-1]))
+	if ip == nil {
+		return nil, false
+	}
+	return &net.TCPAddr{IP: ip}, true
+}
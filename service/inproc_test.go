@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"go.linka.cloud/grpc/rpcctx"
+	"go.linka.cloud/grpc/stats"
+)
+
+func TestInprocInterceptors(t *testing.T) {
+	s := &service{opts: NewOptions(), stats: stats.NewHandler()}
+	var events []stats.Event
+	s.stats.Subscribe(func(ctx context.Context, e stats.Event) {
+		events = append(events, e)
+	})
+	unary, _ := s.inprocInterceptors()
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-forwarded-for", "203.0.113.1, 198.51.100.7"))
+	_, err := unary(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		assert.True(t, rpcctx.InProcess(ctx))
+		a, ok := rpcctx.Peer(ctx)
+		require.True(t, ok)
+		assert.Equal(t, "198.51.100.7:0", a.String())
+		return nil, status.Error(codes.NotFound, "")
+	})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	require.Len(t, events, 2)
+	assert.Equal(t, stats.StreamBegin, events[0].Type)
+	assert.Equal(t, stats.StreamEnd, events[1].Type)
+	assert.Equal(t, "/test.Service/Get", events[1].Method)
+	assert.Equal(t, codes.NotFound, status.Code(events[1].Error))
+	assert.Nil(t, events[1].Conn)
+}
@@ -1,6 +1,10 @@
 package service
 
 import (
+	"context"
+
+	"google.golang.org/grpc"
+
 	"go.linka.cloud/grpc/interceptors"
 	"go.linka.cloud/grpc/interceptors/metadata"
 )
@@ -14,7 +18,25 @@ func md(opts *options) interceptors.Interceptors {
 		pairs = append(pairs, "grpc-service-version", opts.version)
 	}
 	if len(pairs) != 0 {
-		return metadata.NewInterceptors(pairs...)
+		// override so that forwarded metadata does not carry the caller identity
+		return metadata.New(metadata.WithPairs(pairs...), metadata.WithMode(metadata.Override))
 	}
 	return nil
 }
+
+// contextValuesInterceptors returns the interceptors applying the WithContextValues functions
+func (s *service) contextValuesInterceptors() (grpc.UnaryServerInterceptor, grpc.StreamServerInterceptor) {
+	with := func(ctx context.Context) context.Context {
+		for _, fn := range s.opts.contextValues {
+			ctx = fn(ctx)
+		}
+		return ctx
+	}
+	unary := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(with(ctx), req)
+	}
+	stream := func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, metadata.NewContextServerStream(with(ss.Context()), ss))
+	}
+	return unary, stream
+}
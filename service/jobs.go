@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"go.linka.cloud/grpc/logger"
+)
+
+const (
+	// ExitCodeFailure is the process exit code of a failed job
+	ExitCodeFailure = 1
+	// ExitCodeInterrupted is the process exit code of the jobs interrupted by a signal, as the shells report it for SIGINT
+	ExitCodeInterrupted = 130
+)
+
+// ErrInterrupted is returned by Start when the jobs are interrupted by a signal
+var ErrInterrupted = errors.New("interrupted")
+
+type job struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// JobError is the failure of a job
+type JobError struct {
+	Job string
+	Err error
+}
+
+func (e *JobError) Error() string {
+	return fmt.Sprintf("job %s: %v", e.Job, e.Err)
+}
+
+func (e *JobError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCoder may be implemented by the jobs errors to set the process exit code, see WithExitCode
+type ExitCoder interface {
+	ExitCode() int
+}
+
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+func (e *exitError) ExitCode() int {
+	return e.code
+}
+
+// WithExitCode returns an error reporting code as the process exit code, e.g. to tell apart
+// the retryable failures in the Kubernetes Job pod failure policy
+func WithExitCode(err error, code int) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{code: code, err: err}
+}
+
+// ExitCode returns the process exit code for the error returned by Start: 0 when err is nil,
+// ExitCodeInterrupted when the service was interrupted, the code of the ExitCoder found in the chain
+// or ExitCodeFailure
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var c ExitCoder
+	if errors.As(err, &c) {
+		return c.ExitCode()
+	}
+	if errors.Is(err, ErrInterrupted) {
+		return ExitCodeInterrupted
+	}
+	return ExitCodeFailure
+}
+
+// Exit logs err, if any, and exits the process with its exit code:
+//
+//	svc, err := service.NewJob(migrate)
+//	if err != nil {
+//		service.Exit(err)
+//	}
+//	service.Exit(svc.Start())
+func Exit(err error) {
+	if err != nil {
+		logger.StandardLogger().Error(err)
+	}
+	os.Exit(ExitCode(err))
+}
+
+// runJobs runs the jobs sequentially in the background, the returned channel receives the first failure
+// once they are done. It is nil, and so never ready, when there is no job.
+func (s *service) runJobs() <-chan error {
+	if len(s.opts.jobs) == 0 {
+		return nil
+	}
+	done := make(chan error, 1)
+	go func() {
+		ctx := s.opts.ctx
+		for _, j := range s.opts.jobs {
+			log := logger.C(ctx).WithField("job", j.name)
+			log.Info("job started")
+			start := time.Now()
+			err := j.fn(ctx)
+			result := "success"
+			if err != nil {
+				result = "failure"
+			}
+			s.metrics.jobDuration.WithLabelValues(j.name, result).Observe(time.Since(start).Seconds())
+			if err != nil {
+				log.WithError(err).Error("job failed")
+				done <- &JobError{Job: j.name, Err: err}
+				return
+			}
+			log.Infof("job completed in %v", time.Since(start))
+		}
+		done <- nil
+	}()
+	return done
+}
+
+// jobsDone stops the service once the jobs are done and returns their failure
+func (s *service) jobsDone(err error) error {
+	if cerr := s.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// interrupted stops the service on signal, reporting the jobs interruption
+func (s *service) interrupted() error {
+	err := s.Close()
+	if len(s.opts.jobs) != 0 && err == nil {
+		err = ErrInterrupted
+	}
+	return err
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+
+	"google.golang.org/grpc/keepalive"
+
+	"go.linka.cloud/grpc/logger"
+)
+
+const (
+	http2FrameHeaderLen = 9
+	http2FrameGoAway    = 0x7
+	// http2 error codes of the GOAWAY frames
+	http2ErrCodeNoError         = 0x0
+	http2ErrCodeEnhanceYourCalm = 0xb
+	maxGoAwayDebugLen           = 64
+)
+
+const (
+	// goAwayReasonTooManyPings is also the debug data sent by grpc with the ENHANCE_YOUR_CALM code
+	goAwayReasonTooManyPings = "too_many_pings"
+	goAwayReasonNoError      = "no_error"
+	goAwayReasonOther        = "other"
+)
+
+// keepaliveParams returns the keepalive server parameters, with the max connection age if set
+func (o *options) keepaliveParams() (keepalive.ServerParameters, bool) {
+	p := keepalive.ServerParameters{}
+	set := false
+	if o.keepalive != nil {
+		p, set = *o.keepalive, true
+	}
+	if o.maxConnectionAge > 0 {
+		p.MaxConnectionAge = o.maxConnectionAge
+		p.MaxConnectionAgeGrace = o.maxConnectionAgeGrace
+		set = true
+	}
+	return p, set
+}
+
+// goAwayListener reports the GOAWAY frames sent on the grpc connections: the clients disconnected
+// for pinging too aggressively, and the connections closed by the idle and age limits or the shutdown
+func (s *service) goAwayListener(l net.Listener) net.Listener {
+	return &goAwayListener{Listener: l, s: s}
+}
+
+type goAwayListener struct {
+	net.Listener
+	s *service
+}
+
+func (l *goAwayListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &goAwayConn{Conn: c, f: &frameSniffer{onGoAway: func(code uint32, debug []byte) {
+		l.s.goAway(c.RemoteAddr(), code, debug)
+	}}}, nil
+}
+
+func (s *service) goAway(addr net.Addr, code uint32, debug []byte) {
+	reason := goAwayReasonOther
+	switch {
+	case code == http2ErrCodeEnhanceYourCalm && string(debug) == goAwayReasonTooManyPings:
+		reason = goAwayReasonTooManyPings
+		logger.C(s.opts.ctx).Warnf("closing connection from %v: too many pings, see the keepalive enforcement policy", addr)
+	case code == http2ErrCodeNoError:
+		reason = goAwayReasonNoError
+	default:
+		logger.C(s.opts.ctx).Debugf("sent goaway to %v: code %d: %s", addr, code, debug)
+	}
+	s.metrics.goAways.WithLabelValues(reason).Inc()
+}
+
+type goAwayConn struct {
+	net.Conn
+	f *frameSniffer
+}
+
+func (c *goAwayConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.f.feed(b[:n])
+	return n, err
+}
+
+// frameSniffer parses the http2 frames written by the server to find the GOAWAY frames
+type frameSniffer struct {
+	mu        sync.Mutex
+	hdr       [http2FrameHeaderLen]byte
+	hdrLen    int
+	inPayload bool
+	left      int
+	typ       byte
+	payload   []byte
+	onGoAway  func(code uint32, debug []byte)
+}
+
+func (f *frameSniffer) feed(b []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for len(b) > 0 {
+		if !f.inPayload {
+			n := copy(f.hdr[f.hdrLen:], b)
+			f.hdrLen += n
+			b = b[n:]
+			if f.hdrLen < http2FrameHeaderLen {
+				return
+			}
+			f.left = int(f.hdr[0])<<16 | int(f.hdr[1])<<8 | int(f.hdr[2])
+			f.typ = f.hdr[3]
+			f.payload = f.payload[:0]
+			f.hdrLen = 0
+			f.inPayload = true
+			if f.left == 0 {
+				f.frame()
+			}
+			continue
+		}
+		n := len(b)
+		if n > f.left {
+			n = f.left
+		}
+		if f.typ == http2FrameGoAway && len(f.payload) < 8+maxGoAwayDebugLen {
+			keep := n
+			if room := 8 + maxGoAwayDebugLen - len(f.payload); keep > room {
+				keep = room
+			}
+			f.payload = append(f.payload, b[:keep]...)
+		}
+		f.left -= n
+		b = b[n:]
+		if f.left == 0 {
+			f.frame()
+		}
+	}
+}
+
+func (f *frameSniffer) frame() {
+	f.inPayload = false
+	if f.typ != http2FrameGoAway || len(f.payload) < 8 || f.onGoAway == nil {
+		return
+	}
+	f.onGoAway(binary.BigEndian.Uint32(f.payload[4:8]), f.payload[8:])
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func frame(typ byte, payload []byte) []byte {
+	b := make([]byte, http2FrameHeaderLen+len(payload))
+	b[0], b[1], b[2] = byte(len(payload)>>16), byte(len(payload)>>8), byte(len(payload))
+	b[3] = typ
+	copy(b[http2FrameHeaderLen:], payload)
+	return b
+}
+
+func TestFrameSniffer(t *testing.T) {
+	var codes []uint32
+	var debugs []string
+	f := &frameSniffer{onGoAway: func(code uint32, debug []byte) {
+		codes = append(codes, code)
+		debugs = append(debugs, string(debug))
+	}}
+	goAway := make([]byte, 8)
+	binary.BigEndian.PutUint32(goAway[4:], http2ErrCodeEnhanceYourCalm)
+	goAway = append(goAway, goAwayReasonTooManyPings...)
+
+	var b []byte
+	b = append(b, frame(0x4, nil)...)               // settings
+	b = append(b, frame(0x0, make([]byte, 100))...) // data
+	b = append(b, frame(http2FrameGoAway, goAway)...)
+	// written byte by byte
+	for i := range b {
+		f.feed(b[i : i+1])
+	}
+	assert.Equal(t, []uint32{http2ErrCodeEnhanceYourCalm}, codes)
+	assert.Equal(t, []string{goAwayReasonTooManyPings}, debugs)
+
+	f.feed(frame(http2FrameGoAway, make([]byte, 8)))
+	assert.Equal(t, []uint32{http2ErrCodeEnhanceYourCalm, http2ErrCodeNoError}, codes)
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"go.linka.cloud/grpc/errors"
+	"go.linka.cloud/grpc/events"
+	"go.linka.cloud/grpc/logger"
+	"go.linka.cloud/grpc/match"
+	"go.linka.cloud/grpc/registry"
+)
+
+const (
+	// LeaderKey is the response trailer carrying the leader identity, i.e. its address,
+	// when a standby replica rejects a rpc
+	LeaderKey = "x-leader"
+	// MetadataRole is the node metadata key of the replica role when the leader election is enabled
+	MetadataRole = "role"
+	// RoleLeader is the MetadataRole value of the leader replica
+	RoleLeader = "leader"
+	// RoleStandby is the MetadataRole value of the standby replicas
+	RoleStandby = "standby"
+)
+
+// leaderExempt are the services served by the standby replicas too
+var leaderExempt = []string{
+	"/grpc.health.v1.Health/",
+	"/grpc.reflection.*/*",
+}
+
+// Leader returns the identity of the current leader and whether the service is the leader.
+// The service is always the leader when the leader election is not enabled.
+func (s *service) Leader() (string, bool) {
+	if s.opts.elector == nil {
+		return s.Address(), true
+	}
+	s.leaderMu.RLock()
+	defer s.leaderMu.RUnlock()
+	return s.leader, s.leading
+}
+
+// elect campaigns for the leadership in the background, the service identity is its advertised address
+func (s *service) elect() {
+	if s.opts.elector == nil {
+		return
+	}
+	id := s.AdvertisedAddress()
+	if id == "" {
+		id = s.opts.address
+	}
+	s.Go("leader-election", func(ctx context.Context) error {
+		return s.opts.elector.Run(ctx, id, func(leader string) {
+			s.setLeader(leader, leader == id)
+		})
+	})
+}
+
+func (s *service) setLeader(leader string, leading bool) {
+	s.leaderMu.Lock()
+	was := s.leading
+	s.leader, s.leading = leader, leading
+	s.leaderMu.Unlock()
+	log := logger.C(s.opts.ctx)
+	switch {
+	case leading && !was:
+		log.Info("elected leader: serving")
+	case !leading && was:
+		log.Warnf("lost leadership: standing by")
+	}
+	s.events.Publish(events.Event{Type: events.LeaderChanged, Address: leader})
+	if leading == was {
+		return
+	}
+	// publish the new role
+	md := s.NodeMetadata()
+	s.regMu.Lock()
+	registered := s.registered && s.regSvc != nil
+	if registered {
+		// the record is replaced as it may be in use by a registration running without the lock
+		node := *s.regSvc.Nodes[0]
+		node.Metadata = md
+		s.regSvc = &registry.Service{
+			Name:    s.regSvc.Name,
+			Version: s.regSvc.Version,
+			Nodes:   []*registry.Node{&node},
+		}
+	}
+	s.regMu.Unlock()
+	if !registered {
+		return
+	}
+	if err := s.registerRecord(); err != nil {
+		log.Errorf("failed to register service role: %v", err)
+	}
+}
+
+// role returns the replica role to publish in the node metadata
+func (s *service) role() string {
+	if _, leading := s.Leader(); leading {
+		return RoleLeader
+	}
+	return RoleStandby
+}
+
+// leaderInterceptors reject the rpcs with UNAVAILABLE when the service is not the leader,
+// the current leader identity is returned in the LeaderKey trailer
+func (s *service) leaderInterceptors() (grpc.UnaryServerInterceptor, grpc.StreamServerInterceptor) {
+	check := func(ctx context.Context, method string) error {
+		if match.AnyMethod(leaderExempt, method) {
+			return nil
+		}
+		leader, leading := s.Leader()
+		if leading {
+			return nil
+		}
+		if leader == "" {
+			return errors.Unavailablef("no leader elected")
+		}
+		// the trailer cannot be set on the gateway in-process calls
+		_ = grpc.SetTrailer(ctx, metadata.Pairs(LeaderKey, leader))
+		return errors.Unavailablef("not the leader, the leader is %s", leader)
+	}
+	unary := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := check(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+	stream := func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := check(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+	return unary, stream
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go.linka.cloud/grpc/events"
+)
+
+type elector struct{}
+
+func (elector) Run(ctx context.Context, id string, onChange func(leader string)) error {
+	return nil
+}
+
+func TestLeaderInterceptors(t *testing.T) {
+	o := NewOptions()
+	WithLeaderElection(elector{})(o)
+	s := &service{opts: o, events: events.NewBus()}
+	unary, _ := s.leaderInterceptors()
+	call := func(method string) error {
+		_, err := unary(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+		return err
+	}
+
+	err := call("/greeter.Greeter/SayHello")
+	require.Error(t, err)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, RoleStandby, s.NodeMetadata()[MetadataRole])
+
+	s.setLeader("10.0.0.1:9991", false)
+	err = call("/greeter.Greeter/SayHello")
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Contains(t, err.Error(), "10.0.0.1:9991")
+	assert.NoError(t, call("/grpc.health.v1.Health/Check"))
+
+	s.setLeader("10.0.0.2:9991", true)
+	assert.NoError(t, call("/greeter.Greeter/SayHello"))
+	assert.Equal(t, RoleLeader, s.NodeMetadata()[MetadataRole])
+	leader, leading := s.Leader()
+	assert.Equal(t, "10.0.0.2:9991", leader)
+	assert.True(t, leading)
+}
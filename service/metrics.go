@@ -0,0 +1,200 @@
+package service
+
+import (
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/improbable-eng/grpc-web/go/grpcweb"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsNamespace = "grpc_service"
+
+var _ prometheus.Collector = (*metrics)(nil)
+
+// metrics holds the framework internals metrics
+type metrics struct {
+	tasksRunning *prometheus.GaugeVec
+	tasksTotal   *prometheus.CounterVec
+
+	muxErrors         prometheus.Counter
+	connectionsOpen   prometheus.Gauge
+	connectionsTotal  prometheus.Counter
+	registryTotal     *prometheus.CounterVec
+	gatewayErrors     *prometheus.CounterVec
+	websocketSessions prometheus.Gauge
+	shutdownDuration  prometheus.Histogram
+	goAways           *prometheus.CounterVec
+	jobDuration       *prometheus.HistogramVec
+
+	tlsHandshakes        prometheus.Gauge
+	tlsHandshakeFailures *prometheus.CounterVec
+}
+
+func newMetrics(labels prometheus.Labels) *metrics {
+	return &metrics{
+		tasksRunning: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   metricsNamespace,
+			ConstLabels: labels,
+			Name:        "tasks_running",
+			Help:        "Current number of running background tasks.",
+		}, []string{"task"}),
+		tasksTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   metricsNamespace,
+			ConstLabels: labels,
+			Name:        "tasks_total",
+			Help:        "Total number of completed background tasks by result.",
+		}, []string{"task", "result"}),
+		muxErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   metricsNamespace,
+			ConstLabels: labels,
+			Name:        "mux_errors_total",
+			Help:        "Total number of connection matching errors.",
+		}),
+		connectionsOpen: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   metricsNamespace,
+			ConstLabels: labels,
+			Name:        "connections_open",
+			Help:        "Current number of open connections on the service listener.",
+		}),
+		connectionsTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   metricsNamespace,
+			ConstLabels: labels,
+			Name:        "connections_total",
+			Help:        "Total number of connections accepted on the service listener.",
+		}),
+		registryTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   metricsNamespace,
+			ConstLabels: labels,
+			Name:        "registry_operations_total",
+			Help:        "Total number of registry operations by operation and result.",
+		}, []string{"operation", "result"}),
+		gatewayErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   metricsNamespace,
+			ConstLabels: labels,
+			Name:        "gateway_errors_total",
+			Help:        "Total number of errors returned by the gateway by grpc code.",
+		}, []string{"grpc_code"}),
+		websocketSessions: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   metricsNamespace,
+			ConstLabels: labels,
+			Name:        "grpc_web_websocket_sessions",
+			Help:        "Current number of open grpc-web websocket sessions.",
+		}),
+		shutdownDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace:   metricsNamespace,
+			ConstLabels: labels,
+			Name:        "shutdown_duration_seconds",
+			Help:        "Duration of the service shutdown.",
+			Buckets:     []float64{.1, .5, 1, 2.5, 5, 10, 30, 60},
+		}),
+		goAways: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   metricsNamespace,
+			ConstLabels: labels,
+			Name:        "goaways_total",
+			Help:        "Total number of GOAWAY frames sent on the grpc connections by reason: too_many_pings, no_error (idle, age or shutdown) or other.",
+		}, []string{"reason"}),
+		jobDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   metricsNamespace,
+			ConstLabels: labels,
+			Name:        "job_duration_seconds",
+			Help:        "Duration of the run-to-completion jobs by result.",
+			Buckets:     []float64{1, 5, 15, 30, 60, 300, 900, 1800, 3600},
+		}, []string{"job", "result"}),
+		tlsHandshakes: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   metricsNamespace,
+			ConstLabels: labels,
+			Name:        "tls_handshakes_in_flight",
+			Help:        "Current number of TLS handshakes in progress on the service listener.",
+		}),
+		tlsHandshakeFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   metricsNamespace,
+			ConstLabels: labels,
+			Name:        "tls_handshake_failures_total",
+			Help:        "Total number of failed TLS handshakes by cause: timeout, limit, not_tls, closed, certificate or other.",
+		}, []string{"cause"}),
+	}
+}
+
+func (m *metrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		m.tasksRunning,
+		m.tasksTotal,
+		m.muxErrors,
+		m.connectionsOpen,
+		m.connectionsTotal,
+		m.registryTotal,
+		m.gatewayErrors,
+		m.websocketSessions,
+		m.shutdownDuration,
+		m.goAways,
+		m.jobDuration,
+		m.tlsHandshakes,
+		m.tlsHandshakeFailures,
+	}
+}
+
+func (m *metrics) Describe(descs chan<- *prometheus.Desc) {
+	for _, v := range m.collectors() {
+		v.Describe(descs)
+	}
+}
+
+func (m *metrics) Collect(c chan<- prometheus.Metric) {
+	for _, v := range m.collectors() {
+		v.Collect(c)
+	}
+}
+
+func (m *metrics) registry(op string, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	m.registryTotal.WithLabelValues(op, result).Inc()
+}
+
+// websocket tracks the grpc-web websocket sessions handled by h
+func (m *metrics) websocket(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !grpcweb.IsGrpcWebSocketRequest(r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		m.websocketSessions.Inc()
+		defer m.websocketSessions.Dec()
+		h.ServeHTTP(w, r)
+	})
+}
+
+// listener counts the connections accepted by l
+func (m *metrics) listener(l net.Listener) net.Listener {
+	return &countingListener{Listener: l, m: m}
+}
+
+type countingListener struct {
+	net.Listener
+	m *metrics
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	l.m.connectionsTotal.Inc()
+	l.m.connectionsOpen.Inc()
+	return &countingConn{Conn: c, m: l.m}, nil
+}
+
+type countingConn struct {
+	net.Conn
+	m    *metrics
+	once sync.Once
+}
+
+func (c *countingConn) Close() error {
+	c.once.Do(c.m.connectionsOpen.Dec)
+	return c.Conn.Close()
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/multierr"
+)
+
+const defaultModuleTimeout = 30 * time.Second
+
+// Module is a component whose lifecycle is managed by the service, e.g. a database, a broker or a cron scheduler.
+// Modules are started before the service starts serving, in dependency order, and stopped in reverse order
+// once the server is stopped.
+type Module interface {
+	Name() string
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+}
+
+// Dependent may be implemented by a Module to declare the names of the modules it depends on
+type Dependent interface {
+	DependsOn() []string
+}
+
+// Timeouter may be implemented by a Module to override the default start and stop timeout
+type Timeouter interface {
+	Timeout() time.Duration
+}
+
+type ModuleOption func(m *module)
+
+// DependsOn declares the names of the modules the module depends on
+func DependsOn(names ...string) ModuleOption {
+	return func(m *module) {
+		m.deps = append(m.deps, names...)
+	}
+}
+
+// ModuleTimeout sets the module start and stop timeout
+func ModuleTimeout(d time.Duration) ModuleOption {
+	return func(m *module) {
+		m.timeout = d
+	}
+}
+
+// NewModule returns a Module from its start and stop functions, both may be nil
+func NewModule(name string, start, stop func(ctx context.Context) error, opts ...ModuleOption) Module {
+	m := &module{name: name, start: start, stop: stop}
+	for _, o := range opts {
+		o(m)
+	}
+	return m
+}
+
+type module struct {
+	name    string
+	start   func(ctx context.Context) error
+	stop    func(ctx context.Context) error
+	deps    []string
+	timeout time.Duration
+}
+
+func (m *module) Name() string {
+	return m.name
+}
+
+func (m *module) Start(ctx context.Context) error {
+	if m.start == nil {
+		return nil
+	}
+	return m.start(ctx)
+}
+
+func (m *module) Stop(ctx context.Context) error {
+	if m.stop == nil {
+		return nil
+	}
+	return m.stop(ctx)
+}
+
+func (m *module) DependsOn() []string {
+	return m.deps
+}
+
+func (m *module) Timeout() time.Duration {
+	return m.timeout
+}
+
+func moduleTimeout(m Module) time.Duration {
+	if t, ok := m.(Timeouter); ok && t.Timeout() > 0 {
+		return t.Timeout()
+	}
+	return defaultModuleTimeout
+}
+
+// sortModules returns the modules in topological order, preserving the registration order when possible
+func sortModules(mods []Module) ([]Module, error) {
+	index := make(map[string]int, len(mods))
+	for i, m := range mods {
+		if _, ok := index[m.Name()]; ok {
+			return nil, fmt.Errorf("module %q: duplicate module", m.Name())
+		}
+		index[m.Name()] = i
+	}
+	deps := make([][]int, len(mods))
+	for i, m := range mods {
+		d, ok := m.(Dependent)
+		if !ok {
+			continue
+		}
+		for _, n := range d.DependsOn() {
+			j, ok := index[n]
+			if !ok {
+				return nil, fmt.Errorf("module %q: unknown dependency %q", m.Name(), n)
+			}
+			deps[i] = append(deps[i], j)
+		}
+	}
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(mods))
+	out := make([]Module, 0, len(mods))
+	var visit func(i int, path []string) error
+	visit = func(i int, path []string) error {
+		switch state[i] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("modules dependency cycle: %s", strings.Join(append(path, mods[i].Name()), " -> "))
+		}
+		state[i] = visiting
+		for _, j := range deps[i] {
+			if err := visit(j, append(path, mods[i].Name())); err != nil {
+				return err
+			}
+		}
+		state[i] = visited
+		out = append(out, mods[i])
+		return nil
+	}
+	for i := range mods {
+		if err := visit(i, nil); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// startModules starts the modules in order, stopping the already started ones in reverse order on failure
+func startModules(ctx context.Context, mods []Module) error {
+	for i, m := range mods {
+		sctx, cancel := context.WithTimeout(ctx, moduleTimeout(m))
+		err := m.Start(sctx)
+		cancel()
+		if err != nil {
+			err = fmt.Errorf("module %q: start: %w", m.Name(), err)
+			return multierr.Append(err, stopModules(ctx, mods[:i]))
+		}
+	}
+	return nil
+}
+
+// stopModules stops the modules in reverse order
+func stopModules(ctx context.Context, mods []Module) error {
+	var err error
+	for i := len(mods) - 1; i >= 0; i-- {
+		m := mods[i]
+		sctx, cancel := context.WithTimeout(ctx, moduleTimeout(m))
+		if e := m.Stop(sctx); e != nil {
+			err = multierr.Append(err, fmt.Errorf("module %q: stop: %w", m.Name(), e))
+		}
+		cancel()
+	}
+	return err
+}
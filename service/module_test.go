@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSortModules(t *testing.T) {
+	mods := []Module{
+		NewModule("outbox", nil, nil, DependsOn("db", "broker")),
+		NewModule("db", nil, nil),
+		NewModule("broker", nil, nil),
+		NewModule("cron", nil, nil, DependsOn("db")),
+	}
+	sorted, err := sortModules(mods)
+	require.NoError(t, err)
+	var names []string
+	for _, v := range sorted {
+		names = append(names, v.Name())
+	}
+	assert.Equal(t, []string{"db", "broker", "outbox", "cron"}, names)
+
+	_, err = sortModules([]Module{NewModule("a", nil, nil, DependsOn("b")), NewModule("b", nil, nil, DependsOn("a"))})
+	assert.Error(t, err)
+
+	_, err = sortModules([]Module{NewModule("a", nil, nil, DependsOn("unknown"))})
+	assert.Error(t, err)
+}
+
+func TestStartModulesRollback(t *testing.T) {
+	var stopped []string
+	stop := func(name string) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			stopped = append(stopped, name)
+			return nil
+		}
+	}
+	mods := []Module{
+		NewModule("db", nil, stop("db")),
+		NewModule("broker", nil, stop("broker")),
+		NewModule("cron", func(ctx context.Context) error {
+			return errors.New("failed")
+		}, stop("cron")),
+	}
+	err := startModules(context.Background(), mods)
+	assert.Error(t, err)
+	assert.Equal(t, []string{"broker", "db"}, stopped)
+}
@@ -4,6 +4,8 @@ import (
 	"net/http"
 
 	"github.com/justinas/alice"
+
+	"go.linka.cloud/grpc/rpcctx"
 )
 
 type ServeMux interface {
@@ -13,3 +15,27 @@ type ServeMux interface {
 }
 
 type Middleware = alice.Constructor
+
+// httpMiddlewares returns the user middlewares, preceded by the ones normalizing the request origin:
+// the tls state, the peer authentication information available to the gateway and grpc-web handlers
+// through rpcctx.PeerAuth, and the client address resolved from the trusted proxies headers
+func (s *service) httpMiddlewares() []Middleware {
+	trusted := s.opts.trustForwardedClientCert
+	if trusted == nil && s.opts.trustedProxies != nil {
+		trusted = s.opts.trustedProxies.Trusted
+	}
+	return append([]Middleware{withTLSState, rpcctx.PeerAuthMiddleware(trusted), s.opts.trustedProxies.Middleware}, s.opts.middlewares...)
+}
+
+// withTLSState sets the request TLS state, which is not set by the http server for the connections multiplexed by cmux
+func withTLSState(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil {
+			if st := tlsState(r); st != nil {
+				r = r.WithContext(r.Context())
+				r.TLS = st
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.linka.cloud/grpc/events"
+	"go.linka.cloud/grpc/logger"
+	"go.linka.cloud/grpc/notify"
+)
+
+const notificationTimeout = 10 * time.Second
+
+// notifications subscribes the notifiers to the events bus
+func (s *service) notifications() {
+	for _, v := range s.opts.notifiers {
+		n := v
+		s.events.Subscribe(func(e events.Event) {
+			ne, ok := s.notification(e)
+			if !ok {
+				return
+			}
+			// the service context may already be cancelled, e.g. for the stopped event
+			ctx, cancel := context.WithTimeout(context.Background(), notificationTimeout)
+			defer cancel()
+			if err := n.Notify(ctx, ne); err != nil {
+				logger.C(s.opts.ctx).Warnf("failed to send %s notification: %v", ne.Type, err)
+			}
+		}, events.Started, events.Stopped, events.TaskPanicked, events.HealthChanged, events.RegistrationFailed)
+	}
+}
+
+// notification converts the bus event to a notification
+func (s *service) notification(e events.Event) (notify.Event, bool) {
+	ne := notify.Event{
+		Service:  s.opts.name,
+		Instance: s.id,
+		Time:     e.Time,
+	}
+	switch e.Type {
+	case events.Started:
+		ne.Type = notify.Started
+		ne.Message = "service started"
+		ne.Fields = map[string]string{"address": e.Address}
+	case events.Stopped:
+		ne.Type = notify.Stopped
+		ne.Message = "service stopped"
+	case events.TaskPanicked:
+		ne.Type = notify.PanicRecovered
+		ne.Message = "task panicked"
+		ne.Fields = map[string]string{"task": e.Task, "panic": fmt.Sprint(e.Panic)}
+	case events.HealthChanged:
+		ne.Type = notify.HealthChanged
+		ne.Message = "health status changed"
+		ne.Fields = map[string]string{"from": e.Previous, "to": e.Status}
+	case events.RegistrationFailed:
+		ne.Type = notify.RegistrationFailed
+		ne.Message = "failed to register service"
+		ne.Fields = map[string]string{"error": e.Error.Error()}
+	default:
+		return notify.Event{}, false
+	}
+	return ne, true
+}
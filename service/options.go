@@ -8,18 +8,32 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net"
+	"net/http"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"github.com/improbable-eng/grpc-web/go/grpcweb"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/cors"
+	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
 
 	"go.linka.cloud/grpc/certs"
+	"go.linka.cloud/grpc/certs/revocation"
 	"go.linka.cloud/grpc/interceptors"
+	"go.linka.cloud/grpc/leader"
+	"go.linka.cloud/grpc/logger"
+	"go.linka.cloud/grpc/metrics/otlp"
+	runtime2 "go.linka.cloud/grpc/metrics/runtime"
+	"go.linka.cloud/grpc/notify"
+	"go.linka.cloud/grpc/profiling"
 	"go.linka.cloud/grpc/registry"
 	"go.linka.cloud/grpc/transport"
 	"go.linka.cloud/grpc/utils/addr"
+	"go.linka.cloud/grpc/utils/forwarded"
 )
 
 var _ Options = (*options)(nil)
@@ -40,6 +54,8 @@ type Options interface {
 	Key() string
 	TLSConfig() *tls.Config
 	Secure() bool
+	// SelfSigned reports whether the service serves a generated self signed certificate
+	SelfSigned() bool
 
 	Registry() registry.Registry
 
@@ -65,6 +81,10 @@ type Options interface {
 	GatewayPrefix() string
 	GatewayOpts() []runtime.ServeMuxOption
 
+	ShutdownTimeout() time.Duration
+	DeregisterDelay() time.Duration
+	AdminPrefix() string
+
 	// TODO(adphi): metrics + tracing
 
 	Default()
@@ -72,9 +92,12 @@ type Options interface {
 
 func NewOptions() *options {
 	return &options{
-		ctx:     context.Background(),
-		address: ":0",
-		health:  true,
+		ctx:             context.Background(),
+		address:         ":0",
+		health:          true,
+		shutdownTimeout: defaultShutdownTimeout,
+		network:         "tcp",
+		waitForTimeout:  defaultWaitForTimeout,
 	}
 }
 
@@ -175,6 +198,77 @@ func WithTLSConfig(conf *tls.Config) Option {
 	}
 }
 
+// WithTLSMinVersion sets the minimum tls version, defaults to tls.VersionTLS12.
+// It overrides the version of the config passed with WithTLSConfig.
+func WithTLSMinVersion(v uint16) Option {
+	return func(o *options) {
+		o.tlsMinVersion = v
+	}
+}
+
+// WithTLS13Only only accepts TLS 1.3 connections
+func WithTLS13Only() Option {
+	return WithTLSMinVersion(tls.VersionTLS13)
+}
+
+// WithTLSCipherSuites sets the TLS 1.2 cipher suites, defaults to DefaultTLSCipherSuites
+func WithTLSCipherSuites(ids ...uint16) Option {
+	return func(o *options) {
+		o.tlsCipherSuites = ids
+	}
+}
+
+// WithTLSCurvePreferences sets the key exchange curves, defaults to DefaultTLSCurvePreferences
+func WithTLSCurvePreferences(curves ...tls.CurveID) Option {
+	return func(o *options) {
+		o.tlsCurves = curves
+	}
+}
+
+// WithTLSSessionTicketRotation sets the session ticket keys rotation interval, defaults to 12 hours.
+// A negative value disables the rotation.
+func WithTLSSessionTicketRotation(d time.Duration) Option {
+	return func(o *options) {
+		o.sessionTicketRotation = d
+	}
+}
+
+// WithTLSHandshakeTimeout sets the maximum duration of the TLS handshakes, defaults to DefaultTLSHandshakeTimeout.
+// The connections which did not complete their handshake in time are closed. A negative value disables the timeout.
+func WithTLSHandshakeTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.tlsHandshakeTimeout = d
+	}
+}
+
+// WithMaxConcurrentTLSHandshakes limits the number of TLS handshakes in progress, the connections accepted
+// above the limit are closed, e.g. to protect the public listeners against handshake exhaustion.
+// It is unlimited by default.
+func WithMaxConcurrentTLSHandshakes(n int) Option {
+	return func(o *options) {
+		o.maxTLSHandshakes = n
+	}
+}
+
+// WithOCSPStapling staples the OCSP response of the server certificate to the handshakes.
+// The certificate chain must contain the issuer certificate.
+func WithOCSPStapling(opts ...revocation.Option) Option {
+	return func(o *options) {
+		o.ocspStapling = true
+		o.ocspStaplingOpts = opts
+	}
+}
+
+// WithClientRevocationCheck rejects the client certificates revoked according to c,
+// e.g. revocation.First(revocation.NewOCSP(), revocation.NewCRL()).
+// If softFail is true, the certificates which status cannot be determined are accepted.
+func WithClientRevocationCheck(c revocation.Checker, softFail bool) Option {
+	return func(o *options) {
+		o.revocationChecker = c
+		o.revocationSoftFail = softFail
+	}
+}
+
 func WithBeforeStart(fn ...func() error) Option {
 	return func(o *options) {
 		o.beforeStart = append(o.beforeStart, fn...)
@@ -202,6 +296,10 @@ func WithAfterStop(fn ...func() error) Option {
 func WithInterceptors(i ...interceptors.Interceptors) Option {
 	return func(o *options) {
 		for _, v := range i {
+			if v == nil {
+				o.fail("WithInterceptors", errNilInterceptors)
+				continue
+			}
 			o.unaryServerInterceptors = append(o.unaryServerInterceptors, v.UnaryServerInterceptor())
 			o.streamServerInterceptors = append(o.streamServerInterceptors, v.StreamServerInterceptor())
 			o.unaryClientInterceptors = append(o.unaryClientInterceptors, v.UnaryClientInterceptor())
@@ -213,6 +311,10 @@ func WithInterceptors(i ...interceptors.Interceptors) Option {
 func WithServerInterceptors(i ...interceptors.ServerInterceptors) Option {
 	return func(o *options) {
 		for _, v := range i {
+			if v == nil {
+				o.fail("WithServerInterceptors", errNilInterceptors)
+				continue
+			}
 			o.unaryServerInterceptors = append(o.unaryServerInterceptors, v.UnaryServerInterceptor())
 			o.streamServerInterceptors = append(o.streamServerInterceptors, v.StreamServerInterceptor())
 		}
@@ -222,6 +324,10 @@ func WithServerInterceptors(i ...interceptors.ServerInterceptors) Option {
 func WithClientInterceptors(i ...interceptors.ClientInterceptors) Option {
 	return func(o *options) {
 		for _, v := range i {
+			if v == nil {
+				o.fail("WithClientInterceptors", errNilInterceptors)
+				continue
+			}
 			o.unaryClientInterceptors = append(o.unaryClientInterceptors, v.UnaryClientInterceptor())
 			o.streamClientInterceptors = append(o.streamClientInterceptors, v.StreamClientInterceptor())
 		}
@@ -272,6 +378,30 @@ func WithMux(mux ServeMux) Option {
 	}
 }
 
+// WithForwardedClientCert honors the client certificate forwarded by the proxies terminating TLS, in the
+// Envoy X-Forwarded-Client-Cert or Traefik X-Forwarded-Tls-Client-Cert headers, for the http requests
+// trusted returns true for, e.g. the ones coming from the proxy address. See rpcctx.PeerAuth.
+func WithForwardedClientCert(trusted func(r *http.Request) bool) Option {
+	return func(o *options) {
+		o.trustForwardedClientCert = trusted
+	}
+}
+
+// WithTrustedProxies honors the Forwarded, X-Forwarded-For, X-Real-Ip, X-Forwarded-Proto and X-Forwarded-Host
+// headers of the http requests coming from the proxies addresses or networks, e.g. 10.0.0.0/8, for the logging,
+// rate limiting, ip filtering and redirects, see the forwarded package. The headers are ignored by default.
+// The proxies are also trusted for the forwarded client certificates unless WithForwardedClientCert is set.
+func WithTrustedProxies(proxies ...string) Option {
+	return func(o *options) {
+		t, err := forwarded.New(proxies...)
+		if err != nil {
+			o.fail("WithTrustedProxies", err)
+			return
+		}
+		o.trustedProxies = t
+	}
+}
+
 func WithMiddlewares(m ...Middleware) Option {
 	return func(o *options) {
 		o.middlewares = m
@@ -302,6 +432,54 @@ func WithGateway(fn RegisterGatewayFunc) Option {
 	}
 }
 
+// WithGatewayHandlers adds gateway handlers registration functions,
+// e.g. the generated RegisterGreeterHandler. They are registered with a client using the in-process channel.
+func WithGatewayHandlers(fns ...RegisterGatewayFunc) Option {
+	return func(o *options) {
+		o.gatewayHandlers = append(o.gatewayHandlers, fns...)
+	}
+}
+
+// WithGatewayUnescapingMode sets how the gateway unescapes the path parameters
+func WithGatewayUnescapingMode(mode runtime.UnescapingMode) Option {
+	return func(o *options) {
+		o.gatewayUnescapingMode = &mode
+	}
+}
+
+// WithGatewayQueryParser sets the parser populating the request messages from the query parameters
+func WithGatewayQueryParser(p runtime.QueryParameterParser) Option {
+	return func(o *options) {
+		o.gatewayQueryParser = p
+	}
+}
+
+// WithGatewayDisableQueryParams disables the population of the request messages from the query parameters
+func WithGatewayDisableQueryParams() Option {
+	return WithGatewayQueryParser(noQueryParser{})
+}
+
+// WithGatewayDisablePathLengthFallback disables the fallback to the longest matching route when the method does not match
+func WithGatewayDisablePathLengthFallback() Option {
+	return func(o *options) {
+		o.gatewayDisablePathLengthFallback = true
+	}
+}
+
+// WithGatewayPrettyPrint indents the gateway json responses when the pretty query parameter is set, e.g. ?pretty=1
+func WithGatewayPrettyPrint() Option {
+	return func(o *options) {
+		o.gatewayPretty = true
+	}
+}
+
+// WithGatewayEnvelope wraps the gateway json responses in a {"data", "error", "request_id"} envelope
+func WithGatewayEnvelope() Option {
+	return func(o *options) {
+		o.gatewayEnvelope = true
+	}
+}
+
 func WithGatewayPrefix(prefix string) Option {
 	return func(o *options) {
 		o.gatewayPrefix = strings.TrimSuffix(prefix, "/")
@@ -314,6 +492,52 @@ func WithGatewayOpts(opts ...runtime.ServeMuxOption) Option {
 	}
 }
 
+// WithGatewayMiddlewares wraps the gateway handler only, e.g. with auth.NewHTTPMiddleware to reject the
+// unauthenticated requests before the transcoding
+func WithGatewayMiddlewares(m ...Middleware) Option {
+	return func(o *options) {
+		o.gatewayMiddlewares = append(o.gatewayMiddlewares, m...)
+	}
+}
+
+// WithGatewayHeaderMapping exchanges the http header with the grpc metadata key in both directions,
+// the response metadata is returned without the Grpc-Metadata- prefix
+func WithGatewayHeaderMapping(header, key string) Option {
+	return func(o *options) {
+		if o.gatewayHeaders == nil {
+			o.gatewayHeaders = make(map[string]string)
+		}
+		o.gatewayHeaders[header] = key
+	}
+}
+
+// WithGatewayTrailerMapping returns the grpc trailer metadata key as the http response header,
+// as most http clients and browsers ignore the http trailers. The header is exposed to the CORS requests.
+func WithGatewayTrailerMapping(key, header string) Option {
+	return func(o *options) {
+		if o.gatewayTrailers == nil {
+			o.gatewayTrailers = make(map[string]string)
+		}
+		o.gatewayTrailers[strings.ToLower(key)] = header
+	}
+}
+
+// WithGatewayRetryAfter sets the Retry-After header returned with the gateway 429 and 503 responses
+// when the error does not carry a RetryInfo detail, so that the http clients can back off
+func WithGatewayRetryAfter(d time.Duration) Option {
+	return func(o *options) {
+		o.gatewayRetryAfter = d
+	}
+}
+
+// WithExposedHeaders exposes the response headers to the CORS requests, e.g. the custom headers
+// and trailers set by the handlers. Grpc-Status and Grpc-Message are always exposed.
+func WithExposedHeaders(headers ...string) Option {
+	return func(o *options) {
+		o.exposedHeaders = append(o.exposedHeaders, headers...)
+	}
+}
+
 // WithReactUI add static single page app serving to the http server
 // subpath is the path in the read-only file embed.FS to use as root to serve
 // static content
@@ -325,6 +549,369 @@ func WithReactUI(fs embed.FS, subpath string) Option {
 	}
 }
 
+// WithShutdownTimeout sets the maximum time to wait for the background tasks to return when the service stops
+func WithShutdownTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.shutdownTimeout = d
+	}
+}
+
+// WithAdvertisedAddress sets the address registered in the registry when it differs from the bind address,
+// e.g. a pod ip or an external dns name. The bound port is used if the address has no port.
+func WithAdvertisedAddress(address string) Option {
+	return func(o *options) {
+		o.advertisedAddress = address
+	}
+}
+
+// WithAdvertisedAddressFromEnv reads the advertised address from the first non empty environment variable,
+// it defaults to addr.DefaultAdvertiseEnv, e.g. POD_IP
+func WithAdvertisedAddressFromEnv(keys ...string) Option {
+	return func(o *options) {
+		o.advertiseFromEnv = true
+		o.advertiseEnv = keys
+	}
+}
+
+// WithNetwork sets the listener network: tcp (default), tcp4 or tcp6
+func WithNetwork(network string) Option {
+	return func(o *options) {
+		o.network = network
+	}
+}
+
+// WithDualStack binds the service port on both 0.0.0.0 and [::],
+// the service is registered with the dual address family
+func WithDualStack() Option {
+	return func(o *options) {
+		o.dualStack = true
+	}
+}
+
+// WithNodeMetadata adds metadata to the registry node, the instance id and the version are always set
+func WithNodeMetadata(md map[string]string) Option {
+	return func(o *options) {
+		if o.nodeMetadata == nil {
+			o.nodeMetadata = make(map[string]string)
+		}
+		for k, v := range md {
+			o.nodeMetadata[k] = v
+		}
+	}
+}
+
+// WithServedBy returns the node id in the x-served-by response header
+func WithServedBy() Option {
+	return func(o *options) {
+		o.servedBy = true
+	}
+}
+
+// WithContextValues injects per-request values, e.g. loggers, feature flag clients or database handles,
+// in the handlers context. The functions are applied in order by an interceptor running before the
+// user interceptors, after the service information and request id are set in the context.
+func WithContextValues(fns ...func(ctx context.Context) context.Context) Option {
+	return func(o *options) {
+		o.contextValues = append(o.contextValues, fns...)
+	}
+}
+
+// WithDeregisterDelay sets the time to wait after the service is deregistered and marked as not serving
+// before draining the connections, so that load balancers and resolvers stop routing traffic to it
+func WithDeregisterDelay(d time.Duration) Option {
+	return func(o *options) {
+		o.deregisterDelay = d
+	}
+}
+
+// withSignals replaces the process signals the service stops or skips the shutdown delays on, for tests
+func withSignals(sigs <-chan os.Signal) Option {
+	return func(o *options) {
+		o.signals = sigs
+	}
+}
+
+// WithMetricsRegisterer registers the framework internals metrics, e.g. background tasks, on the given registerer
+func WithMetricsRegisterer(r prometheus.Registerer) Option {
+	return func(o *options) {
+		o.metricsRegisterer = r
+	}
+}
+
+// WithRuntimeMetrics exports the Go runtime metrics, and the cgroup ones with runtime.WithCgroup,
+// under the service metrics namespace and labels. It requires WithMetricsRegisterer.
+func WithRuntimeMetrics(opts ...runtime2.Option) Option {
+	return func(o *options) {
+		o.runtimeMetrics = true
+		o.runtimeMetricsOpts = append(o.runtimeMetricsOpts, opts...)
+	}
+}
+
+// WithWaitFor adds dependency checks that must pass before the service is registered and reported as serving
+func WithWaitFor(checks ...Check) Option {
+	return func(o *options) {
+		o.waitFor = append(o.waitFor, checks...)
+	}
+}
+
+// WithWaitForTimeout sets the maximum time to wait for the dependency checks to pass, defaults to one minute
+func WithWaitForTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.waitForTimeout = d
+	}
+}
+
+// WithModules adds modules managed by the service lifecycle, they are started in dependency order
+// before the service starts serving and stopped in reverse order after the server is stopped
+func WithModules(mods ...Module) Option {
+	return func(o *options) {
+		o.modules = append(o.modules, mods...)
+	}
+}
+
+// WithOTLPMetrics pushes the metrics to an OpenTelemetry collector using OTLP/HTTP.
+// The service name, version and instance id are added to the resource attributes.
+func WithOTLPMetrics(opts ...otlp.Option) Option {
+	return func(o *options) {
+		o.otlp = true
+		o.otlpOpts = append(o.otlpOpts, opts...)
+	}
+}
+
+// WithProfiling enables continuous profiling.
+// The profiles are tagged with the service name, version and instance id.
+func WithProfiling(opts ...profiling.Option) Option {
+	return func(o *options) {
+		o.profiling = true
+		o.profilingOpts = append(o.profilingOpts, opts...)
+	}
+}
+
+// WithACMEChallenge serves the ACME HTTP-01 challenges with h, e.g. an acme.ChallengeHandler.
+// When the service uses tls, the challenges are served on a plaintext listener bound to address (defaults to :80).
+func WithACMEChallenge(h http.Handler, address string) Option {
+	return func(o *options) {
+		o.acmeHandler = h
+		o.acmeAddress = address
+	}
+}
+
+// WithHTTPSRedirect serves a plaintext listener bound to address (defaults to :80) which permanently redirects
+// the http requests to the service https address. It requires tls.
+// The ACME challenges set with WithACMEChallenge are served by this listener when they use the same address.
+func WithHTTPSRedirect(address string) Option {
+	return func(o *options) {
+		o.httpsRedirect = true
+		o.httpsRedirectAddress = address
+	}
+}
+
+// WithoutListener runs the service lifecycle, i.e. the modules, the background tasks and the hooks, without
+// serving grpc nor http on the service address. The service is not registered. The admin routes are still
+// served when they use a dedicated listener, see WithAdminAddress.
+func WithoutListener() Option {
+	return func(o *options) {
+		o.noListener = true
+	}
+}
+
+// WithoutRegistration skips the service registration in the registry
+func WithoutRegistration() Option {
+	return func(o *options) {
+		o.noRegistration = true
+	}
+}
+
+// WithJob adds a job run once the service is started, the jobs run sequentially in the order they are added.
+// The service is stopped when they are done, and Start returns the first job failure as a *JobError,
+// see ExitCode. The service serves, e.g. the health and the metrics, while the jobs run, but it is not registered.
+func WithJob(name string, fn func(ctx context.Context) error) Option {
+	return func(o *options) {
+		o.jobs = append(o.jobs, job{name: name, fn: fn})
+	}
+}
+
+// WithLeaderElection runs the service as an active-passive component, e.g. a scheduler: only the replica elected
+// by e serves the rpcs, the standby replicas stay registered, with the MetadataRole node metadata, and reject
+// them with UNAVAILABLE and the leader address in the LeaderKey trailer. The health and reflection services
+// are served by all the replicas.
+func WithLeaderElection(e leader.Elector) Option {
+	return func(o *options) {
+		o.elector = e
+	}
+}
+
+// WithCompatibilityCheck fails the service startup when the registered services contracts are not backward
+// compatible with the baseline FileDescriptorSet file, e.g. the one of the deployed version, see compat.Load.
+func WithCompatibilityCheck(baseline string) Option {
+	return func(o *options) {
+		o.compatBaseline = baseline
+	}
+}
+
+// WithMaxConnectionAge closes the connections older than age with a GOAWAY, the in-flight rpcs,
+// e.g. long-lived streams, are forcibly closed after grace. It lets the clients rebalance across the instances.
+// The keepalive server parameters passed with WithGRPCServerOpts take precedence.
+func WithMaxConnectionAge(age, grace time.Duration) Option {
+	return func(o *options) {
+		o.maxConnectionAge = age
+		o.maxConnectionAgeGrace = grace
+	}
+}
+
+// WithKeepaliveParams sets the server keepalive parameters, the max connection age set with
+// WithMaxConnectionAge takes precedence
+func WithKeepaliveParams(p keepalive.ServerParameters) Option {
+	return func(o *options) {
+		o.keepalive = &p
+	}
+}
+
+// WithKeepaliveEnforcementPolicy sets the policy applied to the clients keepalive pings:
+// the connections of the clients pinging more often than allowed are closed.
+// These disconnections are logged and counted in the goaways metric.
+func WithKeepaliveEnforcementPolicy(p keepalive.EnforcementPolicy) Option {
+	return func(o *options) {
+		o.keepalivePolicy = &p
+	}
+}
+
+// WithNotifiers sends the lifecycle and error events (start, stop, recovered task panics,
+// health changes and registration failures) to the notifiers, see notify.Filter to select them
+func WithNotifiers(ns ...notify.Notifier) Option {
+	return func(o *options) {
+		o.notifiers = append(o.notifiers, ns...)
+	}
+}
+
+// WithAdmin mounts the admin routes, e.g. {prefix}/config and {prefix}/descriptors, on the http server
+func WithAdmin(prefix string) Option {
+	return func(o *options) {
+		o.adminPrefix = strings.TrimSuffix(prefix, "/")
+	}
+}
+
+// WithAdminHandler mounts h on the admin routes, e.g. WithAdminHandler("/metrics", promhttp.Handler())
+// is served on {prefix}/metrics. It requires WithAdmin.
+func WithAdminHandler(pattern string, h http.Handler) Option {
+	return func(o *options) {
+		o.adminHandlers = append(o.adminHandlers, adminHandler{pattern: pattern, handler: h})
+	}
+}
+
+// WithAdminAddress serves the admin routes on a dedicated plaintext listener instead of the service one,
+// e.g. 127.0.0.1:9090, so that they are not exposed with the public api. It requires WithAdmin.
+func WithAdminAddress(address string) Option {
+	return func(o *options) {
+		o.adminAddress = address
+	}
+}
+
+// WithHTTPAccess restricts the access to the http routes under prefix, e.g. /debug, with the given rules,
+// which must all allow the request. The rules of the longest matching prefix apply.
+// They also apply to the admin routes served on the admin address.
+func WithHTTPAccess(prefix string, rules ...AccessRule) Option {
+	return func(o *options) {
+		o.httpAccess = append(o.httpAccess, accessRules{prefix: strings.TrimSuffix(prefix, "/"), rules: rules})
+	}
+}
+
+// WithProfile applies the options of the named profile, e.g. ProfileDev, see RegisterProfile.
+// It should be the first option, so that the following ones override the profile defaults.
+func WithProfile(name string) Option {
+	return func(o *options) {
+		opts, ok := profile(name)
+		if !ok {
+			o.fail("WithProfile", fmt.Errorf("%w: %q", ErrUnknownProfile, name))
+			return
+		}
+		o.profile = name
+		for _, v := range opts {
+			v(o)
+		}
+	}
+}
+
+// WithProfileFromEnv applies the profile named by the first non empty environment variable,
+// it defaults to ProfileEnv, e.g. PROFILE=prod. No profile is applied if they are all empty.
+func WithProfileFromEnv(keys ...string) Option {
+	if len(keys) == 0 {
+		keys = []string{ProfileEnv}
+	}
+	return func(o *options) {
+		for _, k := range keys {
+			if v := os.Getenv(k); v != "" {
+				WithProfile(v)(o)
+				return
+			}
+		}
+	}
+}
+
+// WithAuthRequired rejects with UNAUTHENTICATED the rpcs carrying no credentials: no authorization or
+// x-api-key metadata and no client certificate, their validation is left to the auth interceptors.
+// The health and reflection services do not require credentials.
+func WithAuthRequired() Option {
+	return func(o *options) {
+		o.authRequired = true
+	}
+}
+
+// WithLogFormatter sets the formatter of the service logger, e.g. &logrus.JSONFormatter{}.
+// The service uses a copy of the context logger, the default logger of the process is not modified.
+func WithLogFormatter(f logrus.Formatter) Option {
+	return func(o *options) {
+		o.logFormatter = f
+	}
+}
+
+// WithLogSampling logs each identical entry of the service logs at most burst times per period,
+// the dropped entries are counted in the next one logged, see logger.Sampled
+func WithLogSampling(burst int, period time.Duration) Option {
+	return func(o *options) {
+		o.logSamplingBurst = burst
+		o.logSamplingPeriod = period
+	}
+}
+
+// WithLogFormat sets the format of the service logger, e.g. logger.FormatJSON for the production log pipelines.
+// The logs use the stable logger field names, e.g. ts, level, svc, ver and request_id.
+func WithLogFormat(f logger.Format) Option {
+	return func(o *options) {
+		o.logFormatter = f.Formatter()
+	}
+}
+
+// WithRegistrationPolicy sets the startup behavior when the registry is unreachable, see RegistrationPolicy.
+// The retries stop after timeout, the service then fails to start with the RegistrationRetry policy
+// or keeps serving without registration with the RegistrationOptional one. A zero timeout retries until
+// the service is stopped.
+func WithRegistrationPolicy(p RegistrationPolicy, timeout time.Duration) Option {
+	return func(o *options) {
+		o.registrationPolicy = p
+		o.registrationTimeout = timeout
+	}
+}
+
+// WithRegisterTTL sets the TTL of the registry record, defaults to 90 seconds, and the interval it is refreshed at,
+// defaults to a third of the TTL. The registries supporting TTLs remove the records of the crashed instances
+// once it expires, see registry.Janitor for the other ones.
+func WithRegisterTTL(ttl, interval time.Duration) Option {
+	return func(o *options) {
+		o.regTTL = ttl
+		o.regInterval = interval
+	}
+}
+
+// WithAddressDetection periodically detects the advertised address again, e.g. for the long-running services
+// on DHCP or NATed hosts, and updates the registry record when it changed. An events.AddressChanged event
+// is published on the service events bus. It is disabled by default.
+func WithAddressDetection(interval time.Duration) Option {
+	return func(o *options) {
+		o.addressDetection = interval
+	}
+}
+
 type options struct {
 	ctx     context.Context
 	name    string
@@ -339,6 +926,17 @@ type options struct {
 	cert      string
 	key       string
 	tlsConfig *tls.Config
+	// selfSigned is set when the certificate was generated as none was provided
+	selfSigned bool
+
+	tlsMinVersion         uint16
+	tlsCipherSuites       []uint16
+	tlsCurves             []tls.CurveID
+	sessionTicketRotation time.Duration
+	ocspStapling          bool
+	ocspStaplingOpts      []revocation.Option
+	revocationChecker     revocation.Checker
+	revocationSoftFail    bool
 
 	transport transport.Transport
 	registry  registry.Registry
@@ -356,21 +954,117 @@ type options struct {
 	unaryClientInterceptors  []grpc.UnaryClientInterceptor
 	streamClientInterceptors []grpc.StreamClientInterceptor
 
-	mux           ServeMux
-	middlewares   []Middleware
-	grpcWeb       bool
-	grpcWebOpts   []grpcweb.Option
-	grpcWebPrefix string
-	gateway       RegisterGatewayFunc
-	gatewayOpts   []runtime.ServeMuxOption
-	cors          cors.Options
+	mux             ServeMux
+	middlewares     []Middleware
+	grpcWeb         bool
+	grpcWebOpts     []grpcweb.Option
+	grpcWebPrefix   string
+	gateway         RegisterGatewayFunc
+	gatewayOpts     []runtime.ServeMuxOption
+	gatewayHeaders  map[string]string
+	gatewayHandlers []RegisterGatewayFunc
+	gatewayTrailers map[string]string
+	exposedHeaders  []string
+
+	// trustForwardedClientCert selects the requests which forwarded client certificate headers are honored
+	trustForwardedClientCert func(r *http.Request) bool
+	// trustedProxies are the proxies which forwarded headers are honored, nil trusts none
+	trustedProxies *forwarded.Trust
+	// gatewayMiddlewares wrap the gateway handler only
+	gatewayMiddlewares []Middleware
+
+	gatewayUnescapingMode            *runtime.UnescapingMode
+	gatewayQueryParser               runtime.QueryParameterParser
+	gatewayDisablePathLengthFallback bool
+	gatewayPretty                    bool
+	gatewayEnvelope                  bool
+	gatewayRetryAfter                time.Duration
+	cors                             cors.Options
 
 	reactUI        embed.FS
 	reactUISubPath string
 	hasReactUI     bool
 
-	error         error
+	errors        []error
 	gatewayPrefix string
+
+	shutdownTimeout   time.Duration
+	deregisterDelay   time.Duration
+	signals           <-chan os.Signal
+	nodeMetadata      map[string]string
+	advertisedAddress string
+	network           string
+	dualStack         bool
+	advertiseFromEnv  bool
+	advertiseEnv      []string
+	servedBy          bool
+	contextValues     []func(ctx context.Context) context.Context
+	metricsRegisterer prometheus.Registerer
+
+	runtimeMetrics     bool
+	runtimeMetricsOpts []runtime2.Option
+
+	waitFor        []Check
+	waitForTimeout time.Duration
+
+	modules []Module
+
+	adminPrefix   string
+	adminAddress  string
+	adminHandlers []adminHandler
+	httpAccess    []accessRules
+	// httpRoutes is set when routes are registered through the Router
+	httpRoutes bool
+
+	acmeHandler http.Handler
+	acmeAddress string
+
+	// noListener runs the service lifecycle without serving, see NewWorker
+	noListener     bool
+	noRegistration bool
+	jobs           []job
+
+	elector leader.Elector
+
+	// compatBaseline is the FileDescriptorSet the registered services are checked against
+	compatBaseline string
+
+	// httpsRedirect serves a plaintext listener redirecting to the https address
+	httpsRedirect        bool
+	httpsRedirectAddress string
+
+	otlp     bool
+	otlpOpts []otlp.Option
+
+	profiling     bool
+	profilingOpts []profiling.Option
+
+	notifiers []notify.Notifier
+
+	maxConnectionAge      time.Duration
+	maxConnectionAgeGrace time.Duration
+	keepalive             *keepalive.ServerParameters
+	keepalivePolicy       *keepalive.EnforcementPolicy
+
+	// profile is the name of the applied profile
+	profile      string
+	authRequired bool
+	logFormatter logrus.Formatter
+
+	logSamplingBurst  int
+	logSamplingPeriod time.Duration
+
+	registrationPolicy  RegistrationPolicy
+	registrationTimeout time.Duration
+
+	regTTL      time.Duration
+	regInterval time.Duration
+
+	// addressDetection is the advertised address detection interval
+	addressDetection time.Duration
+
+	tlsHandshakeTimeout time.Duration
+	maxTLSHandshakes    int
 }
 
 func (o *options) Name() string {
@@ -421,6 +1115,10 @@ func (o *options) Secure() bool {
 	return o.secure
 }
 
+func (o *options) SelfSigned() bool {
+	return o.selfSigned
+}
+
 func (o *options) BeforeStart() []func() error {
 	return o.beforeStart
 }
@@ -478,7 +1176,7 @@ func (o *options) GRPCWebOpts() []grpcweb.Option {
 }
 
 func (o *options) Gateway() bool {
-	return o.gateway != nil
+	return o.gateway != nil || len(o.gatewayHandlers) != 0
 }
 
 func (o *options) GatewayPrefix() string {
@@ -489,6 +1187,47 @@ func (o *options) GatewayOpts() []runtime.ServeMuxOption {
 	return o.gatewayOpts
 }
 
+func (o *options) ShutdownTimeout() time.Duration {
+	return o.shutdownTimeout
+}
+
+func (o *options) DeregisterDelay() time.Duration {
+	return o.deregisterDelay
+}
+
+func (o *options) AdminPrefix() string {
+	return o.adminPrefix
+}
+
+// registerTTL returns the registry record TTL
+func (o *options) registerTTL() time.Duration {
+	if o.regTTL > 0 {
+		return o.regTTL
+	}
+	return defaultRegisterTTL
+}
+
+// registerInterval returns the registry record refresh interval
+func (o *options) registerInterval() time.Duration {
+	if o.regInterval > 0 {
+		return o.regInterval
+	}
+	return o.registerTTL() / 3
+}
+
+// hasHTTP reports whether the http server needs to be started
+func (o *options) hasHTTP() bool {
+	return o.Gateway() || o.grpcWeb || o.hasReactUI || (o.adminPrefix != "" && o.adminAddress == "") || o.httpRoutes
+}
+
+// family returns the address family of the registered ip
+func (o *options) family(ip string) string {
+	if o.dualStack {
+		return addr.FamilyDual
+	}
+	return addr.Family(ip)
+}
+
 func (o *options) parseTLSConfig() error {
 	if o.tlsConfig != nil {
 		return nil
@@ -521,28 +1260,33 @@ func (o *options) parseTLSConfig() error {
 			Certificates: []tls.Certificate{cert},
 			ClientAuth:   tls.NoClientCert,
 		}
+		o.selfSigned = true
+		return nil
+	}
+	cert, err := tls.LoadX509KeyPair(o.cert, o.key)
+	if err != nil {
+		return certificateError(o.cert, o.key, o.caCert, err)
+	}
+	o.tlsConfig = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+	}
+	// the CA certificate is optional, the system pool is used without it
+	if o.caCert == "" {
 		return nil
 	}
 	caCert, err := ioutil.ReadFile(o.caCert)
 	if err != nil {
-		return err
+		return certificateError(o.cert, o.key, o.caCert, err)
 	}
 	caCertPool := x509.NewCertPool()
 	ok := caCertPool.AppendCertsFromPEM(caCert)
 	if !ok {
-		return fmt.Errorf("failed to load CA Cert from %s", o.caCert)
-	}
-	cert, err := tls.LoadX509KeyPair(o.cert, o.key)
-	if err != nil {
-		return err
-	}
-	o.tlsConfig = &tls.Config{
-		Certificates: []tls.Certificate{cert},
-		RootCAs:      caCertPool,
+		return certificateError(o.cert, o.key, o.caCert, fmt.Errorf("%w from %s", errInvalidCACert, o.caCert))
 	}
+	o.tlsConfig.RootCAs = caCertPool
 	return nil
 }
 
 func (o *options) hasTLSConfig() bool {
-	return o.caCert != "" && o.cert != "" && o.key != "" && o.tlsConfig == nil
+	return o.cert != "" && o.key != "" && o.tlsConfig == nil
 }
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"go.linka.cloud/grpc/errors"
+	"go.linka.cloud/grpc/logger"
+	"go.linka.cloud/grpc/match"
+	"go.linka.cloud/grpc/rpcctx"
+)
+
+const (
+	// ProfileDev is the local development profile: self-signed TLS, reflection and colored text logs
+	ProfileDev = "dev"
+	// ProfileStaging is the staging profile: JSON logs and authentication required, reflection is kept for debugging
+	ProfileStaging = "staging"
+	// ProfileProd is the production profile: JSON logs, authentication required and reflection off
+	ProfileProd = "prod"
+
+	// ProfileEnv is the environment variable selecting the profile, see WithProfileFromEnv
+	ProfileEnv = "PROFILE"
+)
+
+var (
+	profilesMu sync.RWMutex
+	profiles   = map[string][]Option{
+		ProfileDev: {
+			WithSecure(true),
+			WithReflection(true),
+			WithLogFormatter(&logrus.TextFormatter{ForceColors: true, FullTimestamp: true, FieldMap: logFieldMap}),
+		},
+		ProfileStaging: {
+			WithReflection(true),
+			WithAuthRequired(),
+			WithLogFormat(logger.FormatJSON),
+		},
+		ProfileProd: {
+			WithReflection(false),
+			WithAuthRequired(),
+			WithLogFormat(logger.FormatJSON),
+		},
+	}
+)
+
+// logFieldMap uses the stable logger field names in the colored text logs
+var logFieldMap = logrus.FieldMap{
+	logrus.FieldKeyTime:  logger.FieldTimestamp,
+	logrus.FieldKeyLevel: logger.FieldLevel,
+	logrus.FieldKeyMsg:   logger.FieldMessage,
+}
+
+// RegisterProfile registers the options bundle of a profile, replacing the existing one, e.g. to share
+// an organization wide setup or to override the built-in profiles
+func RegisterProfile(name string, opts ...Option) {
+	profilesMu.Lock()
+	defer profilesMu.Unlock()
+	profiles[name] = opts
+}
+
+func profile(name string) ([]Option, bool) {
+	profilesMu.RLock()
+	defer profilesMu.RUnlock()
+	opts, ok := profiles[name]
+	return opts, ok
+}
+
+// authExempt are the services which do not require credentials
+var authExempt = leaderExempt
+
+// hasCredentials reports whether the rpc carries credentials
+func hasCredentials(ctx context.Context) bool {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if len(md.Get("authorization")) != 0 || len(md.Get("x-api-key")) != 0 {
+			return true
+		}
+	}
+	a, ok := rpcctx.PeerAuth(ctx)
+	return ok && a.Leaf() != nil
+}
+
+// authRequiredInterceptors reject the rpcs without credentials, see WithAuthRequired
+func authRequiredInterceptors() (grpc.UnaryServerInterceptor, grpc.StreamServerInterceptor) {
+	check := func(ctx context.Context, method string) error {
+		if match.AnyMethod(authExempt, method) {
+			return nil
+		}
+		if !hasCredentials(ctx) {
+			return errors.Unauthenticatedf("missing credentials")
+		}
+		return nil
+	}
+	unary := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := check(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+	stream := func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := check(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+	return unary, stream
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestProfile(t *testing.T) {
+	o := NewOptions()
+	WithProfile(ProfileDev)(o)
+	assert.Equal(t, ProfileDev, o.profile)
+	assert.True(t, o.secure)
+	assert.True(t, o.reflection)
+	assert.False(t, o.authRequired)
+
+	// the following options override the profile
+	o = NewOptions()
+	WithProfile(ProfileProd)(o)
+	WithReflection(true)(o)
+	assert.True(t, o.reflection)
+	assert.True(t, o.authRequired)
+	assert.IsType(t, &logrus.JSONFormatter{}, o.logFormatter)
+
+	o = NewOptions()
+	WithProfile("unknown")(o)
+	require.Len(t, o.errors, 1)
+	assert.True(t, errors.Is(o.errors[0], ErrUnknownProfile))
+
+	RegisterProfile("test", WithHealth(false))
+	defer RegisterProfile("test")
+	os.Setenv("TEST_PROFILE", "test")
+	defer os.Unsetenv("TEST_PROFILE")
+	o = NewOptions()
+	WithProfileFromEnv("TEST_EMPTY", "TEST_PROFILE")(o)
+	assert.Equal(t, "test", o.profile)
+	assert.False(t, o.health)
+	o = NewOptions()
+	WithProfileFromEnv("TEST_EMPTY")(o)
+	assert.Empty(t, o.profile)
+}
+
+func TestAuthRequired(t *testing.T) {
+	unary, _ := authRequiredInterceptors()
+	call := func(ctx context.Context, method string) error {
+		_, err := unary(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+		return err
+	}
+	ctx := context.Background()
+	assert.Equal(t, codes.Unauthenticated, status.Code(call(ctx, "/greeter.Greeter/SayHello")))
+	assert.NoError(t, call(ctx, "/grpc.health.v1.Health/Check"))
+	assert.NoError(t, call(metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer token")), "/greeter.Greeter/SayHello"))
+	assert.NoError(t, call(metadata.NewIncomingContext(ctx, metadata.Pairs("x-api-key", "key")), "/greeter.Greeter/SayHello"))
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.linka.cloud/grpc/logger"
+	"go.linka.cloud/grpc/utils/backoff"
+)
+
+const (
+	defaultWaitForTimeout  = time.Minute
+	maxWaitForRetryBackoff = 5 * time.Second
+)
+
+// Check is a dependency check that must pass before the service is registered
+// and reported as serving, e.g. database reachable, broker connected or caches warmed.
+type Check interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+// NewCheck returns a named Check running fn
+func NewCheck(name string, fn func(ctx context.Context) error) Check {
+	return &check{name: name, fn: fn}
+}
+
+type check struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+func (c *check) Name() string {
+	return c.name
+}
+
+func (c *check) Check(ctx context.Context) error {
+	return c.fn(ctx)
+}
+
+// waitFor blocks until all the dependency checks pass or the timeout expires
+func (s *service) waitFor() error {
+	if len(s.opts.waitFor) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(s.opts.ctx, s.opts.waitForTimeout)
+	defer cancel()
+	log := logger.C(ctx)
+	pending := s.opts.waitFor
+	for attempt := 1; ; attempt++ {
+		var failed []Check
+		for _, c := range pending {
+			if err := c.Check(ctx); err != nil {
+				log.WithField("check", c.Name()).WithError(err).Infof("waiting for dependency (attempt %d)", attempt)
+				failed = append(failed, c)
+				continue
+			}
+			log.WithField("check", c.Name()).Info("dependency ready")
+		}
+		if len(failed) == 0 {
+			return nil
+		}
+		pending = failed
+		wait := backoff.Do(attempt)
+		if wait > maxWaitForRetryBackoff {
+			wait = maxWaitForRetryBackoff
+		}
+		select {
+		case <-ctx.Done():
+			var names []string
+			for _, c := range pending {
+				names = append(names, c.Name())
+			}
+			return fmt.Errorf("dependencies not ready after %v: %s", s.opts.waitForTimeout, strings.Join(names, ", "))
+		case <-time.After(wait):
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWaitFor(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls = make(map[string]int)
+	)
+	count := func(name string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls[name]
+	}
+	check := func(name string, fn func(n int) error) Check {
+		return NewCheck(name, func(ctx context.Context) error {
+			mu.Lock()
+			calls[name]++
+			n := calls[name]
+			mu.Unlock()
+			return fn(n)
+		})
+	}
+	o := NewOptions()
+	WithWaitFor(
+		check("db", func(n int) error { return nil }),
+		check("cache", func(n int) error {
+			if n < 2 {
+				return errors.New("warming")
+			}
+			return nil
+		}),
+	)(o)
+	s := &service{opts: o}
+	require.NoError(t, s.waitFor())
+	// the passed checks are not run again
+	assert.Equal(t, 1, count("db"))
+	assert.Equal(t, 2, count("cache"))
+
+	o = NewOptions()
+	WithWaitFor(
+		check("broker", func(n int) error { return errors.New("unreachable") }),
+		check("ready", func(n int) error { return nil }),
+		check("queue", func(n int) error { return errors.New("unreachable") }),
+	)(o)
+	WithWaitForTimeout(100 * time.Millisecond)(o)
+	s = &service{opts: o}
+	start := time.Now()
+	err := s.waitFor()
+	require.Error(t, err)
+	// only the pending dependencies are reported
+	assert.Equal(t, "dependencies not ready after 100ms: broker, queue", err.Error())
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+	assert.Equal(t, 1, count("ready"))
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"go.linka.cloud/grpc/acme"
+	"go.linka.cloud/grpc/logger"
+	"go.linka.cloud/grpc/utils/forwarded"
+)
+
+const defaultHTTPSRedirectAddress = ":80"
+
+// redirectAddress returns the plaintext redirect listener address
+func (o *options) redirectAddress() string {
+	if o.httpsRedirectAddress != "" {
+		return o.httpsRedirectAddress
+	}
+	return defaultHTTPSRedirectAddress
+}
+
+// acmeOnRedirect reports whether the ACME challenges are served by the redirect listener
+func (o *options) acmeOnRedirect() bool {
+	if !o.httpsRedirect || o.acmeHandler == nil {
+		return false
+	}
+	address := o.acmeAddress
+	if address == "" {
+		address = defaultACMEChallengeAddress
+	}
+	return address == o.redirectAddress()
+}
+
+// serveRedirect serves the plaintext listener redirecting the http requests to the service https address
+func (s *service) serveRedirect() error {
+	if !s.opts.httpsRedirect || s.opts.tlsConfig == nil {
+		return nil
+	}
+	lis, err := net.Listen(s.opts.network, s.opts.redirectAddress())
+	if err != nil {
+		return listenError("https redirect", s.opts.redirectAddress(), err)
+	}
+	var h http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, port, _ := net.SplitHostPort(s.Address())
+		http.Redirect(w, r, httpsURL(r, port), http.StatusPermanentRedirect)
+	})
+	if s.opts.acmeOnRedirect() {
+		mux := http.NewServeMux()
+		mux.Handle("/", h)
+		mux.Handle(acme.ChallengePath, s.opts.acmeHandler)
+		h = mux
+	}
+	if s.opts.trustedProxies != nil {
+		h = s.opts.trustedProxies.Middleware(h)
+	}
+	s.redirectServer = &http.Server{Handler: h}
+	go func() {
+		if err := s.redirectServer.Serve(lis); err != nil && err != http.ErrServerClosed {
+			logger.C(s.opts.ctx).Errorf("https redirect server: %v", err)
+		}
+	}()
+	return nil
+}
+
+// httpsURL returns the https url of the request on port, which is omitted when it is the default one
+func httpsURL(r *http.Request, port string) string {
+	host := forwarded.FromRequest(r).Host
+	if host == "" {
+		host = r.Host
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if port != "" && port != "443" {
+		host = net.JoinHostPort(host, port)
+	} else if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		host = "[" + host + "]"
+	}
+	return "https://" + host + r.URL.RequestURI()
+}
@@ -0,0 +1,28 @@
+package service
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPSURL(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		port string
+		want string
+	}{
+		{name: "default port", url: "http://example.org/a?b=c", port: "443", want: "https://example.org/a?b=c"},
+		{name: "service port", url: "http://example.org:80/a", port: "8443", want: "https://example.org:8443/a"},
+		{name: "ipv6", url: "http://[::1]:80/", port: "443", want: "https://[::1]/"},
+		{name: "ipv6 service port", url: "http://[::1]/", port: "8443", want: "https://[::1]:8443/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.url, nil)
+			assert.Equal(t, tt.want, httpsURL(r, tt.port))
+		})
+	}
+}
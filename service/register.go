@@ -1,10 +1,19 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net"
+	"os"
 	"strings"
 	"time"
 
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
+
+	"go.linka.cloud/grpc/events"
+	"go.linka.cloud/grpc/logger"
 	"go.linka.cloud/grpc/registry"
 	"go.linka.cloud/grpc/utils/addr"
 	"go.linka.cloud/grpc/utils/backoff"
@@ -12,75 +21,395 @@ import (
 )
 
 func (s *service) register() error {
-	const (
-		defaultRegisterInterval = time.Second * 30
-		defaultRegisterTTL      = time.Second * 90
-	)
-	regFunc := func(service *registry.Service) error {
-		var regErr error
-
-		for i := 0; i < 3; i++ {
-			// set the ttl
-			rOpts := []registry.RegisterOption{registry.RegisterTTL(defaultRegisterTTL)}
-			// attempt to register
-			if err := s.opts.Registry().Register(service, rOpts...); err != nil {
-				// set the error
-				regErr = err
-				// backoff then retry
-				time.Sleep(backoff.Do(i + 1))
-				continue
-			}
-			// success so nil error
-			regErr = nil
-			break
-		}
+	advt, family, err := s.detectAddress()
+	if err != nil {
+		return err
+	}
 
-		return regErr
+	s.regMu.Lock()
+	s.addrFamily = family
+	s.regMu.Unlock()
+
+	// register service
+	node := &registry.Node{
+		Id:       s.nodeID(),
+		Address:  advt,
+		Metadata: s.NodeMetadata(),
 	}
 
+	s.regMu.Lock()
+	s.regSvc = &registry.Service{
+		Name:    s.opts.name,
+		Version: s.opts.version,
+		Nodes:   []*registry.Node{node},
+	}
+	s.regMu.Unlock()
+
+	// register the service
+	if err := s.registerRecord(); err != nil {
+		return registryError(s.opts.Registry().String(), err)
+	}
+	return nil
+}
+
+// detectAddress returns the address to register and its family
+func (s *service) detectAddress() (string, string, error) {
 	var err error
 	var advt, host, port string
 
-	// // check the advertise address first
-	// // if it exists then use it, otherwise
-	// // use the address
-	// if len(config.Advertise) > 0 {
-	//	advt = config.Advertise
-	// } else {
+	// check the advertise address first
+	// if it exists then use it, otherwise
+	// use the address
 	advt = s.opts.address
-	// }
+	if a := s.advertise(); a != "" {
+		if advt, err = addr.Advertise(a, s.opts.address); err != nil {
+			return "", "", err
+		}
+	}
 
 	if cnt := strings.Count(advt, ":"); cnt >= 1 {
 		// ipv6 address in format [host]:port or ipv4 host:port
 		host, port, err = net.SplitHostPort(advt)
 		if err != nil {
-			return err
+			return "", "", err
 		}
 	} else {
-		host = s.opts.address
+		host = advt
 	}
 
-	addr, err := addr.Extract(host)
+	addr, err := addr.ExtractFamily(host, s.family())
 	if err != nil {
-		return err
+		return "", "", err
 	}
+	return net2.HostPort(addr, port), s.opts.family(addr), nil
+}
 
-	// register service
-	node := &registry.Node{
-		Id:      s.opts.name + "-" + s.id,
-		Address: net2.HostPort(addr, port),
+// watchAddress periodically detects the address again and updates the registry record when it changed,
+// e.g. for the services running on DHCP or NATed hosts
+func (s *service) watchAddress() {
+	if s.regSvc == nil || s.opts.addressDetection <= 0 {
+		return
 	}
+	s.Go("address-detection", func(ctx context.Context) error {
+		t := time.NewTicker(s.opts.addressDetection)
+		defer t.Stop()
+		log := logger.C(ctx)
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-t.C:
+				if err := s.readvertise(); err != nil {
+					log.Warnf("failed to update advertised address: %v", err)
+				}
+			}
+		}
+	})
+}
 
+// readvertise replaces the registry record with one using the detected address if it changed,
+// an AddressChanged event is published once the record is updated
+func (s *service) readvertise() error {
+	advt, family, err := s.detectAddress()
+	if err != nil {
+		return err
+	}
+	s.regMu.Lock()
+	if s.regSvc == nil || s.regSvc.Nodes[0].Address == advt {
+		s.regMu.Unlock()
+		return nil
+	}
+	s.addrFamily = family
+	s.regMu.Unlock()
+
+	md := s.NodeMetadata()
+
+	s.regMu.Lock()
+	defer s.regMu.Unlock()
+	prev := s.regSvc
+	node := *prev.Nodes[0]
+	node.Address = advt
+	node.Metadata = md
 	s.regSvc = &registry.Service{
-		Name:    s.opts.name,
-		Version: s.opts.version,
-		Nodes:   []*registry.Node{node},
+		Name:    prev.Name,
+		Version: prev.Version,
+		Nodes:   []*registry.Node{&node},
 	}
+	if s.registered {
+		// the old record expires anyway in the registries supporting TTLs
+		err := s.opts.Registry().Deregister(prev)
+		s.metrics.registry("deregister", err)
+		if err != nil {
+			logger.C(s.opts.ctx).Warnf("failed to deregister previous address %s: %v", prev.Nodes[0].Address, err)
+		}
+		// on failure, the record is registered again by the heartbeat
+		err = s.opts.Registry().Register(s.regSvc, registry.RegisterTTL(s.opts.registerTTL()))
+		s.metrics.registry("register", err)
+		if err != nil {
+			s.events.Publish(events.Event{Type: events.RegistrationFailed, Error: err})
+			return err
+		}
+	}
+	s.events.Publish(events.Event{Type: events.AddressChanged, Address: advt, Previous: prev.Nodes[0].Address})
+	return nil
+}
 
-	// register the service
-	if err := regFunc(s.regSvc); err != nil {
+const defaultRegisterTTL = 90 * time.Second
+
+// RegistrationPolicy is the startup behavior when the service registration fails
+type RegistrationPolicy int
+
+const (
+	// RegistrationFailFast stops the service, it is the default
+	RegistrationFailFast RegistrationPolicy = iota
+	// RegistrationRetry retries the registration with backoff, the service is started once registered
+	RegistrationRetry
+	// RegistrationOptional starts the service without registration, retrying it in the background
+	RegistrationOptional
+)
+
+func (p RegistrationPolicy) String() string {
+	switch p {
+	case RegistrationFailFast:
+		return "fail-fast"
+	case RegistrationRetry:
+		return "retry"
+	case RegistrationOptional:
+		return "optional"
+	}
+	return fmt.Sprintf("RegistrationPolicy(%d)", int(p))
+}
+
+// registerWithPolicy registers the service, applying the registration policy when the registry is unreachable
+func (s *service) registerWithPolicy() error {
+	err := s.register()
+	if err == nil || !errors.Is(err, ErrRegistryUnreachable) {
 		return err
 	}
+	switch s.opts.registrationPolicy {
+	case RegistrationRetry:
+		logger.C(s.opts.ctx).Warnf("%v: retrying", err)
+		return s.retryRegister(s.opts.ctx, s.notify(), err)
+	case RegistrationOptional:
+		logger.C(s.opts.ctx).Warnf("%v: serving without registration", err)
+		s.Go("registration", func(ctx context.Context) error {
+			return s.retryRegister(ctx, nil, err)
+		})
+		return nil
+	}
+	return err
+}
+
+// retryRegister retries the service registration with backoff until it succeeds, the registration timeout
+// expires, ctx is done or a signal is received, it returns the last error if it did not succeed
+func (s *service) retryRegister(ctx context.Context, sigs <-chan os.Signal, err error) error {
+	if d := s.opts.registrationTimeout; d > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+	log := logger.C(ctx)
+	for i := 1; ; i++ {
+		select {
+		case <-ctx.Done():
+			return err
+		case sig := <-sigs:
+			log.Warnf("received %v: aborting registration", sig)
+			return err
+		case <-time.After(backoff.Do(i)):
+		}
+		s.regMu.Lock()
+		registered := s.registered
+		s.regMu.Unlock()
+		// the health watcher may have registered the service
+		if registered {
+			return nil
+		}
+		if rerr := s.registerRecord(); rerr != nil {
+			err = registryError(s.opts.Registry().String(), rerr)
+			log.Warnf("registration attempt %d failed: %v", i, rerr)
+			continue
+		}
+		log.Info("service registered")
+		return nil
+	}
+}
 
+// family returns the address family to extract the registered address from
+func (s *service) family() string {
+	switch s.opts.network {
+	case "tcp4":
+		return addr.FamilyIPv4
+	case "tcp6":
+		return addr.FamilyIPv6
+	}
+	return ""
+}
+
+// advertise returns the configured advertised address, looking up the environment if requested
+func (s *service) advertise() string {
+	if s.opts.advertisedAddress != "" {
+		return s.opts.advertisedAddress
+	}
+	if !s.opts.advertiseFromEnv {
+		return ""
+	}
+	a, _ := addr.FromEnv(s.opts.advertiseEnv...)
+	return a
+}
+
+// registerRecord registers the service record, retrying on failure.
+// The registry is called without holding regMu, so that an unreachable registry does not block
+// the record readers, e.g. AdvertisedAddress, NodeMetadata or Stop.
+func (s *service) registerRecord() error {
+	s.regMu.Lock()
+	svc := s.regSvc
+	s.regMu.Unlock()
+	var regErr error
+	for i := 0; i < 3; i++ {
+		// set the ttl
+		rOpts := []registry.RegisterOption{registry.RegisterTTL(s.opts.registerTTL())}
+		// attempt to register
+		err := s.opts.Registry().Register(svc, rOpts...)
+		s.metrics.registry("register", err)
+		if err != nil {
+			// set the error
+			regErr = err
+			// backoff then retry
+			time.Sleep(backoff.Do(i + 1))
+			continue
+		}
+		// success so nil error
+		regErr = nil
+		break
+	}
+	if regErr != nil {
+		s.events.Publish(events.Event{Type: events.RegistrationFailed, Error: regErr})
+		return regErr
+	}
+	s.regMu.Lock()
+	s.registered = true
+	s.regMu.Unlock()
+	s.events.Publish(events.Event{Type: events.Registered, Address: svc.Nodes[0].Address})
 	return nil
 }
+
+// deregisterRecord removes the service record if it is registered
+func (s *service) deregisterRecord() error {
+	s.regMu.Lock()
+	defer s.regMu.Unlock()
+	if s.regSvc == nil || !s.registered {
+		return nil
+	}
+	err := s.opts.registry.Deregister(s.regSvc)
+	s.metrics.registry("deregister", err)
+	if err != nil {
+		return err
+	}
+	s.registered = false
+	s.events.Publish(events.Event{Type: events.Deregistered, Address: s.regSvc.Nodes[0].Address})
+	return nil
+}
+
+// heartbeat refreshes the registry record before its TTL expires, so that the records of the crashed
+// instances expire in the registries supporting TTLs
+func (s *service) heartbeat() {
+	if s.regSvc == nil {
+		return
+	}
+	s.Go("registry-heartbeat", func(ctx context.Context) error {
+		t := time.NewTicker(s.opts.registerInterval())
+		defer t.Stop()
+		log := logger.C(ctx)
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-t.C:
+				if err := s.refreshRecord(); err != nil {
+					log.Warnf("failed to refresh registry record: %v", err)
+				}
+			}
+		}
+	})
+}
+
+// refreshRecord registers again the service record if it is registered
+func (s *service) refreshRecord() error {
+	s.regMu.Lock()
+	svc, registered := s.regSvc, s.registered
+	s.regMu.Unlock()
+	if !registered {
+		return nil
+	}
+	err := s.opts.Registry().Register(svc, registry.RegisterTTL(s.opts.registerTTL()))
+	s.metrics.registry("refresh", err)
+	return err
+}
+
+// watchHealth keeps the registry record in sync with the overall health status:
+// the record is removed when the service is not serving and restored when it serves again
+func (s *service) watchHealth() {
+	if s.health == nil || s.regSvc == nil {
+		return
+	}
+	s.Go("health-registry", func(ctx context.Context) error {
+		w := &healthWatcher{ctx: ctx, ch: make(chan grpc_health_v1.HealthCheckResponse_ServingStatus)}
+		go s.health.Watch(&grpc_health_v1.HealthCheckRequest{}, w)
+		log := logger.C(ctx)
+		last := grpc_health_v1.HealthCheckResponse_ServingStatus(-1)
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case st := <-w.ch:
+				if last != st {
+					if last != -1 {
+						s.events.Publish(events.Event{Type: events.HealthChanged, Status: st.String(), Previous: last.String()})
+					}
+					last = st
+				}
+				s.regMu.Lock()
+				registered := s.registered
+				s.regMu.Unlock()
+				if st == grpc_health_v1.HealthCheckResponse_SERVING {
+					if registered {
+						continue
+					}
+					log.Infof("service is serving again: restoring it in the registry")
+					if err := s.registerRecord(); err != nil {
+						log.Errorf("failed to register service: %v", err)
+					}
+					continue
+				}
+				if !registered {
+					continue
+				}
+				log.Warnf("service is %s: removing it from the registry", st)
+				if err := s.deregisterRecord(); err != nil {
+					log.Errorf("failed to deregister service: %v", err)
+				}
+			}
+		}
+	})
+}
+
+// healthWatcher is an in-memory grpc_health_v1.Health_WatchServer
+type healthWatcher struct {
+	grpc.ServerStream
+	ctx context.Context
+	ch  chan grpc_health_v1.HealthCheckResponse_ServingStatus
+}
+
+func (w *healthWatcher) Context() context.Context {
+	return w.ctx
+}
+
+func (w *healthWatcher) Send(res *grpc_health_v1.HealthCheckResponse) error {
+	select {
+	case w.ch <- res.GetStatus():
+		return nil
+	case <-w.ctx.Done():
+		return w.ctx.Err()
+	}
+}
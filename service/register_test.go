@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+
+	"go.linka.cloud/grpc/events"
+	"go.linka.cloud/grpc/registry"
+	"go.linka.cloud/grpc/registry/noop"
+)
+
+// flakyRegistry fails the first registrations
+type flakyRegistry struct {
+	registry.Registry
+	mu       sync.Mutex
+	failures int
+	calls    int
+}
+
+func (r *flakyRegistry) Register(s *registry.Service, opts ...registry.RegisterOption) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls++
+	if r.calls <= r.failures {
+		return errors.New("registry unavailable")
+	}
+	return nil
+}
+
+func TestRegistrationPolicy(t *testing.T) {
+	reg := &flakyRegistry{Registry: noop.New(), failures: 3}
+	svc := startService(t,
+		WithAddress("127.0.0.1:0"),
+		WithHealth(false),
+		WithRegistry(reg),
+		WithRegistrationPolicy(RegistrationOptional, time.Minute),
+	)
+	// the service is registered in the background
+	s := svc.(*service)
+	assert.Eventually(t, func() bool {
+		s.regMu.Lock()
+		defer s.regMu.Unlock()
+		return s.registered
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// the registration timeout must not be negative
+	_, err := New(WithRegistrationPolicy(RegistrationRetry, -time.Second))
+	assert.True(t, errors.Is(err, ErrInvalidTimeout))
+}
+
+// addrRegistry records the registered addresses
+type addrRegistry struct {
+	registry.Registry
+	mu    sync.Mutex
+	addrs map[string]bool
+}
+
+func (r *addrRegistry) Register(s *registry.Service, opts ...registry.RegisterOption) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.addrs[s.Nodes[0].Address] = true
+	return nil
+}
+
+func (r *addrRegistry) Deregister(s *registry.Service, opts ...registry.DeregisterOption) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.addrs, s.Nodes[0].Address)
+	return nil
+}
+
+func TestReadvertise(t *testing.T) {
+	reg := &addrRegistry{Registry: noop.New(), addrs: make(map[string]bool)}
+	svc, err := New(
+		WithAddress("127.0.0.1:0"),
+		WithAdvertisedAddress("10.0.0.1:9000"),
+		WithHealth(false),
+		WithRegistry(reg),
+	)
+	require.NoError(t, err)
+	s := svc.(*service)
+	ch := make(chan events.Event, 1)
+	defer svc.Events().Subscribe(func(e events.Event) {
+		ch <- e
+	}, events.AddressChanged)()
+	require.NoError(t, s.register())
+	assert.Equal(t, "10.0.0.1:9000", svc.AdvertisedAddress())
+
+	// unchanged address
+	require.NoError(t, s.readvertise())
+	select {
+	case e := <-ch:
+		t.Fatalf("unexpected event: %v", e.Type)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	s.opts.advertisedAddress = "10.0.0.2:9000"
+	require.NoError(t, s.readvertise())
+	select {
+	case e := <-ch:
+		assert.Equal(t, "10.0.0.2:9000", e.Address)
+		assert.Equal(t, "10.0.0.1:9000", e.Previous)
+	case <-time.After(time.Second):
+		t.Fatal("no address changed event")
+	}
+	assert.Equal(t, "10.0.0.2:9000", svc.AdvertisedAddress())
+	reg.mu.Lock()
+	assert.Equal(t, map[string]bool{"10.0.0.2:9000": true}, reg.addrs)
+	reg.mu.Unlock()
+
+	_, err = New(WithAddressDetection(-time.Second))
+	assert.True(t, errors.Is(err, ErrInvalidTimeout))
+}
+
+// recordRegistry records the registrations and deregistrations
+type recordRegistry struct {
+	registry.Registry
+	ops chan string
+	mu  sync.Mutex
+	svc *registry.Service
+	// registering is called on registration if set
+	registering func()
+	// deregistered is called on deregistration if set
+	deregistered func()
+}
+
+func newRecordRegistry() *recordRegistry {
+	return &recordRegistry{Registry: noop.New(), ops: make(chan string, 16)}
+}
+
+func (r *recordRegistry) Register(s *registry.Service, opts ...registry.RegisterOption) error {
+	if r.registering != nil {
+		r.registering()
+	}
+	r.mu.Lock()
+	r.svc = s
+	r.mu.Unlock()
+	r.ops <- "register"
+	return nil
+}
+
+func (r *recordRegistry) Deregister(s *registry.Service, opts ...registry.DeregisterOption) error {
+	if r.deregistered != nil {
+		r.deregistered()
+	}
+	r.ops <- "deregister"
+	return nil
+}
+
+func (r *recordRegistry) service() *registry.Service {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.svc
+}
+
+// next returns the next registry operation
+func (r *recordRegistry) next(t *testing.T) string {
+	select {
+	case op := <-r.ops:
+		return op
+	case <-time.After(5 * time.Second):
+		t.Fatal("no registry operation")
+		return ""
+	}
+}
+
+// idle asserts that no registry operation happens
+func (r *recordRegistry) idle(t *testing.T) {
+	select {
+	case op := <-r.ops:
+		t.Fatalf("unexpected registry operation: %s", op)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestWatchHealth(t *testing.T) {
+	reg := newRecordRegistry()
+	o := NewOptions()
+	WithRegistry(reg)(o)
+	ctx, cancel := context.WithCancel(context.Background())
+	o.ctx = ctx
+	s := &service{opts: o, metrics: newMetrics(nil), events: events.NewBus(), health: health.NewServer()}
+	s.regSvc = &registry.Service{Name: "test", Nodes: []*registry.Node{{Id: "test-1", Address: "127.0.0.1:9000"}}}
+	s.registered = true
+	ch := make(chan events.Event, 4)
+	defer s.events.Subscribe(func(e events.Event) {
+		ch <- e
+	}, events.HealthChanged)()
+	s.watchHealth()
+
+	// the serving service stays registered
+	reg.idle(t)
+
+	s.health.SetServingStatus("", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	assert.Equal(t, "deregister", reg.next(t))
+	select {
+	case e := <-ch:
+		assert.Equal(t, "NOT_SERVING", e.Status)
+		assert.Equal(t, "SERVING", e.Previous)
+	case <-time.After(time.Second):
+		t.Fatal("no health changed event")
+	}
+	// the services statuses do not change the overall status
+	s.health.SetServingStatus("test.Service", grpc_health_v1.HealthCheckResponse_SERVING)
+	reg.idle(t)
+
+	s.health.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	assert.Equal(t, "register", reg.next(t))
+	assert.Equal(t, "127.0.0.1:9000", reg.service().Nodes[0].Address)
+	assert.Eventually(t, func() bool {
+		s.regMu.Lock()
+		defer s.regMu.Unlock()
+		return s.registered
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.NoError(t, s.waitTasks(time.Second))
+	s.health.SetServingStatus("", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	reg.idle(t)
+}
+
+func TestRegisterRecordUnlocked(t *testing.T) {
+	reg := newRecordRegistry()
+	entered, release := make(chan struct{}), make(chan struct{})
+	reg.registering = func() {
+		close(entered)
+		<-release
+	}
+	o := NewOptions()
+	WithRegistry(reg)(o)
+	s := &service{opts: o, metrics: newMetrics(nil), events: events.NewBus()}
+	s.regSvc = &registry.Service{Name: "test", Nodes: []*registry.Node{{Id: "test-1", Address: "127.0.0.1:9000"}}}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.registerRecord()
+	}()
+	select {
+	case <-entered:
+	case <-time.After(time.Second):
+		t.Fatal("registry not called")
+	}
+	// the record readers are not blocked by the registry call
+	addr := make(chan string, 1)
+	go func() {
+		addr <- s.AdvertisedAddress()
+	}()
+	select {
+	case a := <-addr:
+		assert.Equal(t, "127.0.0.1:9000", a)
+	case <-time.After(time.Second):
+		t.Fatal("AdvertisedAddress blocked by the registration")
+	}
+	s.regMu.Lock()
+	assert.False(t, s.registered)
+	s.regMu.Unlock()
+	close(release)
+	require.NoError(t, <-errs)
+	assert.Equal(t, "register", reg.next(t))
+	s.regMu.Lock()
+	assert.True(t, s.registered)
+	s.regMu.Unlock()
+}
@@ -0,0 +1,34 @@
+package service
+
+import (
+	"net/http"
+)
+
+// Router registers plain http routes on the service http server, e.g. redirects, robots.txt
+// or /.well-known endpoints. The routes must be registered before the service is started.
+type Router interface {
+	Handle(pattern string, h http.Handler)
+	HandleFunc(pattern string, fn func(http.ResponseWriter, *http.Request))
+	// Redirect redirects the requests matching the pattern to target with the given status code,
+	// e.g. http.StatusMovedPermanently
+	Redirect(pattern, target string, code int)
+}
+
+// HTTPRoutesRegisterer is implemented by the services registering their own http routes:
+// RegisterHTTPRoutes is called when the service is registered
+type HTTPRoutesRegisterer interface {
+	RegisterHTTPRoutes(r Router)
+}
+
+func (s *service) Handle(pattern string, h http.Handler) {
+	s.opts.mux.Handle(pattern, h)
+	s.opts.httpRoutes = true
+}
+
+func (s *service) HandleFunc(pattern string, fn func(http.ResponseWriter, *http.Request)) {
+	s.Handle(pattern, http.HandlerFunc(fn))
+}
+
+func (s *service) Redirect(pattern, target string, code int) {
+	s.Handle(pattern, http.RedirectHandler(target, code))
+}
@@ -2,7 +2,6 @@ package service
 
 import (
 	"context"
-	"crypto/tls"
 	"fmt"
 	"net"
 	"net/http"
@@ -18,6 +17,7 @@ import (
 	"github.com/google/uuid"
 	grpcmiddleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	"github.com/justinas/alice"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/cors"
 	"github.com/soheilhy/cmux"
 	"go.uber.org/multierr"
@@ -25,16 +25,63 @@ import (
 	"google.golang.org/grpc/health"
 	"google.golang.org/grpc/health/grpc_health_v1"
 	greflect "google.golang.org/grpc/reflection"
+	"google.golang.org/protobuf/types/descriptorpb"
 
+	"go.linka.cloud/grpc/certs/revocation"
+	"go.linka.cloud/grpc/events"
+	"go.linka.cloud/grpc/interceptors/metadata"
 	"go.linka.cloud/grpc/logger"
+	"go.linka.cloud/grpc/metrics/otlp"
+	runtime2 "go.linka.cloud/grpc/metrics/runtime"
+	"go.linka.cloud/grpc/profiling"
 	"go.linka.cloud/grpc/registry"
 	"go.linka.cloud/grpc/registry/noop"
+	"go.linka.cloud/grpc/rpcctx"
+	"go.linka.cloud/grpc/stats"
+	net2 "go.linka.cloud/grpc/utils/net"
+)
+
+const (
+	// ServedByKey is the response header carrying the node id when WithServedBy is set
+	ServedByKey = "x-served-by"
+	// MetadataInstanceID is the node metadata key of the instance id
+	MetadataInstanceID = "instance_id"
+	// MetadataVersion is the node metadata key of the service version
+	MetadataVersion = "version"
+	// MetadataAddressFamily is the node metadata key of the address family: ipv4, ipv6 or dual
+	MetadataAddressFamily = "address_family"
 )
 
 type Service interface {
 	greflect.GRPCServer
+	Router
 
 	Options() Options
+	// Go runs fn in a background goroutine tied to the service lifecycle
+	Go(name string, fn func(ctx context.Context) error)
+	// ID returns the instance id generated when the service is created
+	ID() string
+	// Address returns the address the service is bound to, with the actual port when it listens on port 0.
+	// It is empty until the listener is created, see Listening.
+	Address() string
+	// Listening returns a channel closed once the listener is created, before the service starts serving
+	Listening() <-chan struct{}
+	// AdvertisedAddress returns the address registered in the registry, it is empty until the service is registered
+	AdvertisedAddress() string
+	// NodeMetadata returns the metadata of the registry node
+	NodeMetadata() map[string]string
+	// Stats returns the server connections and rpcs events handler.
+	// It is installed as the grpc server stats.Handler, so it is replaced if one is passed with WithGRPCServerOpts.
+	Stats() stats.Handler
+	// Events returns the bus of the lifecycle, connection, registry and health events
+	Events() events.Bus
+	// Leader returns the identity of the current leader, i.e. its address, and whether the service is the leader,
+	// see WithLeaderElection
+	Leader() (leader string, leading bool)
+	// Descriptors returns the FileDescriptorSet of the registered services and of their dependencies
+	Descriptors() (*descriptorpb.FileDescriptorSet, error)
+	// ConfigDump returns the resolved configuration as json, secrets are redacted
+	ConfigDump() ([]byte, error)
 	Start() error
 	Stop() error
 	Close() error
@@ -58,7 +105,38 @@ type service struct {
 
 	id     string
 	regSvc *registry.Service
-	closed chan struct{}
+	// regMu guards the registry record registration
+	regMu      sync.Mutex
+	registered bool
+	addrFamily string
+	closed     chan struct{}
+
+	tasks   sync.WaitGroup
+	metrics *metrics
+	health  *health.Server
+	modules []Module
+	stats   stats.Handler
+
+	acmeServer     *http.Server
+	redirectServer *http.Server
+	// adminHandler is set when the admin routes are served on a dedicated listener
+	adminHandler http.Handler
+	adminServer  *http.Server
+	stapler      *revocation.Stapler
+
+	events events.Bus
+
+	// addrMu guards the bound address as s.mu is held while the service is starting
+	addrMu    sync.RWMutex
+	bound     string
+	listening chan struct{}
+
+	drainMu  sync.Mutex
+	draining chan struct{}
+
+	leaderMu sync.RWMutex
+	leader   string
+	leading  bool
 }
 
 func newService(opts ...Option) (*service, error) {
@@ -67,12 +145,25 @@ func newService(opts ...Option) (*service, error) {
 		id:       uuid.New().String(),
 		inproc:   &inprocgrpc.Channel{},
 		services: make(map[string]*serviceInfo),
+		stats:    stats.NewHandler(),
+		events:   events.NewBus(),
+
+		listening: make(chan struct{}),
 	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	for _, f := range opts {
 		f(s.opts)
 	}
+	s.metrics = newMetrics(s.instanceLabels())
+	if s.opts.logFormatter != nil {
+		s.opts.ctx = logger.Set(s.opts.ctx, logger.WithFormatter(logger.C(s.opts.ctx), s.opts.logFormatter))
+	}
+	// identify the instance in all the service logs
+	s.opts.ctx = logger.Set(s.opts.ctx, logger.C(s.opts.ctx).WithFields(logger.FieldService, s.opts.name, logger.FieldVersion, s.opts.version, "instance", s.id))
+	if s.opts.logSamplingBurst > 0 {
+		s.opts.ctx = logger.Set(s.opts.ctx, logger.Sampled(logger.C(s.opts.ctx), s.opts.logSamplingBurst, s.opts.logSamplingPeriod))
+	}
 
 	md := md(s.opts)
 	if md != nil {
@@ -82,45 +173,120 @@ func newService(opts ...Option) (*service, error) {
 		s.opts.streamClientInterceptors = append([]grpc.StreamClientInterceptor{md.StreamClientInterceptor()}, s.opts.streamClientInterceptors...)
 	}
 
+	if s.opts.servedBy {
+		sb := metadata.New(metadata.WithPairs(ServedByKey, s.nodeID()))
+		s.opts.unaryServerInterceptors = append([]grpc.UnaryServerInterceptor{sb.UnaryServerInterceptor()}, s.opts.unaryServerInterceptors...)
+		s.opts.streamServerInterceptors = append([]grpc.StreamServerInterceptor{sb.StreamServerInterceptor()}, s.opts.streamServerInterceptors...)
+	}
+	if len(s.opts.contextValues) != 0 {
+		cu, cs := s.contextValuesInterceptors()
+		s.opts.unaryServerInterceptors = append([]grpc.UnaryServerInterceptor{cu}, s.opts.unaryServerInterceptors...)
+		s.opts.streamServerInterceptors = append([]grpc.StreamServerInterceptor{cs}, s.opts.streamServerInterceptors...)
+	}
+	// expose the service information and logger to the handlers through the rpcctx accessors and logger.C
+	rc := rpcctx.NewServerInterceptors(rpcctx.WithServiceInfo(s.opts.name, s.opts.version), rpcctx.WithLogger(logger.C(s.opts.ctx)))
+	s.opts.unaryServerInterceptors = append([]grpc.UnaryServerInterceptor{rc.UnaryServerInterceptor()}, s.opts.unaryServerInterceptors...)
+	s.opts.streamServerInterceptors = append([]grpc.StreamServerInterceptor{rc.StreamServerInterceptor()}, s.opts.streamServerInterceptors...)
+	du, ds := s.drainInterceptors()
+	s.opts.unaryServerInterceptors = append([]grpc.UnaryServerInterceptor{du}, s.opts.unaryServerInterceptors...)
+	s.opts.streamServerInterceptors = append([]grpc.StreamServerInterceptor{ds}, s.opts.streamServerInterceptors...)
+	if s.opts.authRequired {
+		au, as := authRequiredInterceptors()
+		s.opts.unaryServerInterceptors = append([]grpc.UnaryServerInterceptor{au}, s.opts.unaryServerInterceptors...)
+		s.opts.streamServerInterceptors = append([]grpc.StreamServerInterceptor{as}, s.opts.streamServerInterceptors...)
+	}
+	if s.opts.elector != nil {
+		lu, ls := s.leaderInterceptors()
+		s.opts.unaryServerInterceptors = append([]grpc.UnaryServerInterceptor{lu}, s.opts.unaryServerInterceptors...)
+		s.opts.streamServerInterceptors = append([]grpc.StreamServerInterceptor{ls}, s.opts.streamServerInterceptors...)
+	}
+
 	if s.opts.mux == nil {
 		s.opts.mux = http.NewServeMux()
 	}
-	if s.opts.error != nil {
-		return nil, s.opts.error
+	// report all the options failures at once
+	if err := multierr.Append(multierr.Combine(s.opts.errors...), s.opts.validate()); err != nil {
+		return nil, err
 	}
-	s.opts.ctx, s.cancel = context.WithCancel(s.opts.ctx)
-	go func() {
-		for {
-			select {
-			case <-s.opts.ctx.Done():
-				s.Stop()
+	if s.opts.metricsRegisterer != nil {
+		if err := s.opts.metricsRegisterer.Register(s.metrics); err != nil {
+			return nil, err
+		}
+		if s.opts.runtimeMetrics {
+			opts := append([]runtime2.Option{runtime2.WithNamespace(metricsNamespace), runtime2.WithConstLabels(s.instanceLabels())}, s.opts.runtimeMetricsOpts...)
+			if err := s.opts.metricsRegisterer.Register(runtime2.New(opts...)); err != nil {
+				return nil, err
 			}
 		}
+	}
+	s.connEvents()
+	s.notifications()
+	s.opts.ctx, s.cancel = context.WithCancel(s.opts.ctx)
+	go func() {
+		<-s.opts.ctx.Done()
+		s.Stop()
 	}()
 	if s.opts.registry == nil {
 		s.opts.registry = noop.New()
 	}
+	if s.opts.otlp {
+		opts := []otlp.Option{otlp.WithService(s.opts.name, s.opts.version, s.id)}
+		if g, ok := s.opts.metricsRegisterer.(prometheus.Gatherer); ok {
+			opts = append(opts, otlp.WithGatherer(g))
+		}
+		opts = append(opts, s.opts.otlpOpts...)
+		s.opts.modules = append(s.opts.modules, otlp.NewExporter(opts...))
+	}
+	if s.opts.profiling {
+		opts := append([]profiling.Option{profiling.WithService(s.opts.name, s.opts.version, s.id)}, s.opts.profilingOpts...)
+		p, err := profiling.NewProfiler(opts...)
+		if err != nil {
+			return nil, err
+		}
+		s.opts.modules = append(s.opts.modules, p)
+	}
+	mods, err := sortModules(s.opts.modules)
+	if err != nil {
+		return nil, err
+	}
+	s.modules = mods
 
 	if err := s.opts.parseTLSConfig(); err != nil {
 		return nil, err
 	}
+	s.opts.hardenTLS()
+	if err := s.setupOCSPStapling(); err != nil {
+		return nil, err
+	}
 
 	ui := grpcmiddleware.ChainUnaryServer(s.opts.unaryServerInterceptors...)
-	s.inproc = s.inproc.WithServerUnaryInterceptor(ui)
-
 	si := grpcmiddleware.ChainStreamServer(s.opts.streamServerInterceptors...)
-	s.inproc = s.inproc.WithServerStreamInterceptor(si)
+
+	// the gateway calls go through the in-process channel, which runs the same chain
+	iu, is := s.inprocInterceptors()
+	s.inproc = s.inproc.WithServerUnaryInterceptor(grpcmiddleware.ChainUnaryServer(iu, ui))
+	s.inproc = s.inproc.WithServerStreamInterceptor(grpcmiddleware.ChainStreamServer(is, si))
 
 	gopts := []grpc.ServerOption{
 		grpc.StreamInterceptor(si),
 		grpc.UnaryInterceptor(ui),
+		grpc.StatsHandler(s.stats),
+	}
+	if p, ok := s.opts.keepaliveParams(); ok {
+		gopts = append(gopts, grpc.KeepaliveParams(p))
+	}
+	if s.opts.keepalivePolicy != nil {
+		gopts = append(gopts, grpc.KeepaliveEnforcementPolicy(*s.opts.keepalivePolicy))
 	}
 	s.server = grpc.NewServer(append(gopts, s.opts.serverOpts...)...)
 	if s.opts.reflection {
 		greflect.Register(s.server)
 	}
 	if s.opts.health {
-		s.registerService(&grpc_health_v1.Health_ServiceDesc, health.NewServer())
+		s.health = health.NewServer()
+		// the service is not ready to serve until it is started and its dependencies are available
+		s.health.SetServingStatus("", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+		s.registerService(&grpc_health_v1.Health_ServiceDesc, s.health)
 	}
 	if err := s.gateway(s.opts.gatewayOpts...); err != nil {
 		return nil, err
@@ -128,6 +294,7 @@ func newService(opts ...Option) (*service, error) {
 	if err := s.reactApp(); err != nil {
 		return nil, err
 	}
+	s.admin()
 	// we do not configure grpc web here as the grpc handlers are not yet registered
 	return s, nil
 }
@@ -136,41 +303,176 @@ func (s *service) Options() Options {
 	return s.opts
 }
 
+func (s *service) ID() string {
+	return s.id
+}
+
+func (s *service) Address() string {
+	s.addrMu.RLock()
+	defer s.addrMu.RUnlock()
+	return s.bound
+}
+
+func (s *service) Listening() <-chan struct{} {
+	s.addrMu.RLock()
+	defer s.addrMu.RUnlock()
+	return s.listening
+}
+
+// setAddress records the bound address and signals that the service is listening
+func (s *service) setAddress(addr string) {
+	s.addrMu.Lock()
+	defer s.addrMu.Unlock()
+	s.bound = addr
+	select {
+	case <-s.listening:
+	default:
+		close(s.listening)
+	}
+}
+
+func (s *service) AdvertisedAddress() string {
+	s.regMu.Lock()
+	defer s.regMu.Unlock()
+	if s.regSvc == nil || len(s.regSvc.Nodes) == 0 {
+		return ""
+	}
+	return s.regSvc.Nodes[0].Address
+}
+
+func (s *service) NodeMetadata() map[string]string {
+	md := make(map[string]string, len(s.opts.nodeMetadata)+2)
+	for k, v := range s.opts.nodeMetadata {
+		md[k] = v
+	}
+	md[MetadataInstanceID] = s.id
+	s.regMu.Lock()
+	if s.addrFamily != "" {
+		md[MetadataAddressFamily] = s.addrFamily
+	}
+	s.regMu.Unlock()
+	if s.opts.version != "" {
+		md[MetadataVersion] = s.opts.version
+	}
+	if s.opts.elector != nil {
+		md[MetadataRole] = s.role()
+	}
+	return md
+}
+
+// nodeID is the registry node id, also used as the x-served-by value
+func (s *service) nodeID() string {
+	return s.opts.name + "-" + s.id
+}
+
+// instanceLabels are the constant labels of the framework metrics
+func (s *service) instanceLabels() prometheus.Labels {
+	l := prometheus.Labels{"service_instance": s.id}
+	if s.opts.name != "" {
+		l["service"] = s.opts.name
+	}
+	return l
+}
+
+func (s *service) Stats() stats.Handler {
+	return s.stats
+}
+
+func (s *service) Events() events.Bus {
+	return s.events
+}
+
+// connEvents publishes the server connections events on the bus
+func (s *service) connEvents() {
+	s.stats.Subscribe(func(_ context.Context, e stats.Event) {
+		if e.Client || (e.Type != stats.ConnOpen && e.Type != stats.ConnClose) {
+			return
+		}
+		ev := events.Event{Type: events.ConnOpened, Time: e.Time}
+		if e.Type == stats.ConnClose {
+			ev.Type = events.ConnClosed
+		}
+		if e.Conn != nil && e.Conn.RemoteAddr != nil {
+			ev.Address = e.Conn.RemoteAddr.String()
+		}
+		s.events.Publish(ev)
+	})
+}
+
 func (s *service) run() error {
 	s.mu.Lock()
 	s.closed = make(chan struct{})
+	s.resetDrain()
+	s.events.Publish(events.Event{Type: events.Starting})
+
+	if s.opts.noListener {
+		return s.runWithoutListener()
+	}
 
 	// configure grpc web now that we are ready to go
 	if err := s.grpcWeb(s.opts.grpcWebOpts...); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	if err := s.checkCompatibility(); err != nil {
+		s.mu.Unlock()
 		return err
 	}
 
-	lis, err := net.Listen("tcp", s.opts.address)
+	lis, err := s.listen()
 	if err != nil {
-		return err
+		s.mu.Unlock()
+		return listenError("service", s.opts.address, err)
+	}
+	if err := s.acmeChallenge(); err != nil {
+		return s.abort(lis, err)
+	}
+	if err := s.serveAdmin(); err != nil {
+		return s.abort(lis, err)
 	}
+	if err := s.serveRedirect(); err != nil {
+		return s.abort(lis, err)
+	}
+	lis = s.metrics.listener(lis)
 	if s.opts.tlsConfig != nil {
-		lis = tls.NewListener(lis, s.opts.tlsConfig)
+		if err := s.rotateSessionTicketKeys(); err != nil {
+			return s.abort(lis, err)
+		}
+		s.staple()
+		lis = s.tlsListener(lis)
 	}
 
+	// use the actual address from now on, e.g. when the port was chosen by the system,
+	// so that it is the one registered
 	s.opts.address = lis.Addr().String()
+	s.setAddress(s.opts.address)
 
 	mux := cmux.New(lis)
 	mux.SetReadTimeout(5 * time.Second)
+	mux.HandleError(func(err error) bool {
+		if !ignoreMuxError(err) {
+			s.metrics.muxErrors.Inc()
+		}
+		return true
+	})
 
-	gLis := mux.MatchWithWriters(cmux.HTTP2MatchHeaderFieldSendSettings("content-type", "application/grpc"))
+	// match the content subtypes too, e.g. application/grpc+json, but not application/grpc-web
+	gLis := mux.MatchWithWriters(
+		cmux.HTTP2MatchHeaderFieldSendSettings("content-type", "application/grpc"),
+		cmux.HTTP2MatchHeaderFieldPrefixSendSettings("content-type", "application/grpc+"),
+	)
 	hList := mux.Match(cmux.Any())
 
 	for i := range s.opts.beforeStart {
 		if err := s.opts.beforeStart[i](); err != nil {
-			s.mu.Unlock()
-			return err
+			return s.abort(lis, err)
 		}
 	}
 
-	if err := s.register(); err != nil {
-		return err
+	if err := startModules(s.opts.ctx, s.modules); err != nil {
+		return s.abort(lis, err)
 	}
+
 	s.running = true
 
 	errs := make(chan error, 3)
@@ -191,17 +493,19 @@ func (s *service) run() error {
 			AllowCredentials: true,
 		}
 	}
+	s.opts.cors.ExposedHeaders = append(s.opts.cors.ExposedHeaders, s.opts.corsExposedHeaders()...)
 	hServer := &http.Server{
-		Handler: alice.New(s.opts.middlewares...).Then(cors.New(s.opts.cors).Handler(s.opts.mux)),
+		Handler:     alice.New(s.httpMiddlewares()...).Then(cors.New(s.opts.cors).Handler(s.opts.restrict(s.opts.mux))),
+		ConnContext: connContext,
 	}
-	if s.opts.Gateway() || s.opts.grpcWeb || s.opts.hasReactUI {
+	if s.opts.hasHTTP() {
 		go func() {
 			errs <- hServer.Serve(hList)
 			hServer.Shutdown(s.opts.ctx)
 		}()
 	}
 	go func() {
-		errs <- s.server.Serve(gLis)
+		errs <- s.server.Serve(s.goAwayListener(gLis))
 	}()
 
 	go func() {
@@ -216,6 +520,22 @@ func (s *service) run() error {
 		}
 		errs <- nil
 	}()
+	if stopped, err := s.unlocked(s.waitFor); stopped || err != nil {
+		return s.startFailed(stopped, err)
+	}
+	// the job instances must not receive traffic from the other services
+	if !s.opts.noRegistration && len(s.opts.jobs) == 0 {
+		if stopped, err := s.unlocked(s.registerWithPolicy); stopped || err != nil {
+			return s.startFailed(stopped, err)
+		}
+	}
+	if s.health != nil {
+		s.health.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	}
+	s.watchHealth()
+	s.heartbeat()
+	s.watchAddress()
+	s.elect()
 	for i := range s.opts.afterStart {
 		if err := s.opts.afterStart[i](); err != nil {
 			s.mu.Unlock()
@@ -224,12 +544,16 @@ func (s *service) run() error {
 		}
 	}
 	s.mu.Unlock()
+	s.events.Publish(events.Event{Type: events.Started, Address: s.opts.address})
+	jobs := s.runJobs()
 	sigs := s.notify()
 	select {
 	case sig := <-sigs:
 		fmt.Println()
 		logger.C(s.opts.ctx).Warnf("received %v", sig)
-		return s.Close()
+		return s.interrupted()
+	case err := <-jobs:
+		return s.jobsDone(err)
 	case err := <-errs:
 		if err != nil && !ignoreMuxError(err) {
 			logger.C(s.opts.ctx).Error(err)
@@ -239,6 +563,76 @@ func (s *service) run() error {
 	}
 }
 
+// abort releases s.mu, the listener if any and the servers started before the startup failure
+func (s *service) abort(lis net.Listener, err error) error {
+	if lis != nil {
+		lis.Close()
+	}
+	s.closeServers()
+	s.mu.Unlock()
+	return err
+}
+
+// closeServers closes the acme challenge, admin and https redirect servers
+func (s *service) closeServers() {
+	if s.acmeServer != nil {
+		s.acmeServer.Close()
+	}
+	if s.adminServer != nil {
+		s.adminServer.Close()
+	}
+	if s.redirectServer != nil {
+		s.redirectServer.Close()
+	}
+}
+
+// unlocked runs the blocking startup step fn, e.g. the dependency checks or the registration retries,
+// without holding s.mu so that Stop and the admin handlers are not blocked meanwhile.
+// s.mu must be held, it is held again on return, and stopped reports whether the service was stopped meanwhile.
+func (s *service) unlocked(fn func() error) (stopped bool, err error) {
+	s.mu.Unlock()
+	err = fn()
+	s.mu.Lock()
+	return !s.running, err
+}
+
+// startFailed releases s.mu and stops the service after a failed startup step,
+// the service stopped during the step is not an error but its record may have been registered meanwhile
+func (s *service) startFailed(stopped bool, err error) error {
+	s.mu.Unlock()
+	if !stopped {
+		s.Stop()
+		return err
+	}
+	if err := s.deregisterRecord(); err != nil {
+		logger.C(s.opts.ctx).Errorf("failed to deregister service: %v", err)
+	}
+	return nil
+}
+
+// listen binds the service address, on both ipv4 and ipv6 when dual-stack is enabled
+func (s *service) listen() (net.Listener, error) {
+	if !s.opts.dualStack {
+		return net.Listen(s.opts.network, s.opts.address)
+	}
+	_, port, err := net.SplitHostPort(s.opts.address)
+	if err != nil {
+		return nil, err
+	}
+	l4, err := net.Listen("tcp4", net.JoinHostPort("0.0.0.0", port))
+	if err != nil {
+		return nil, err
+	}
+	// use the same port when it was chosen by the system
+	_, port, _ = net.SplitHostPort(l4.Addr().String())
+	l6, err := net.Listen("tcp6", net.JoinHostPort("::", port))
+	if err != nil {
+		l4.Close()
+		return nil, err
+	}
+	return net2.MultiListener(l4, l6), nil
+}
+
 func (s *service) Start() error {
 	return s.run()
 }
@@ -250,16 +644,33 @@ func (s *service) Stop() error {
 	if !s.running {
 		return nil
 	}
+	start := time.Now()
+	s.events.Publish(events.Event{Type: events.Stopping})
 	for i := range s.opts.beforeStop {
 		if err := s.opts.beforeStop[i](); err != nil {
 			return err
 		}
 	}
-	if err := s.opts.registry.Deregister(s.regSvc); err != nil {
+	// stop advertising the service before draining so that no new traffic is routed to it
+	if s.health != nil {
+		s.health.Shutdown()
+	}
+	if err := s.deregisterRecord(); err != nil {
 		log.Errorf("failed to deregister service: %v", err)
 	}
 	defer close(s.closed)
 	sigs := s.notify()
+	if d := s.opts.deregisterDelay; d > 0 {
+		log.Infof("waiting %v for load balancers to converge", d)
+		select {
+		case sig := <-sigs:
+			fmt.Println()
+			log.Warnf("received %v: skipping deregister delay", sig)
+		case <-time.After(d):
+		}
+	}
+	// the handlers are signaled once no new traffic is routed to the service
+	s.drain()
 	done := make(chan struct{})
 	go func() {
 		defer close(done)
@@ -280,13 +691,29 @@ func (s *service) Stop() error {
 	case <-done:
 	}
 	s.running = false
+	s.closeServers()
 	s.cancel()
+	if err := s.waitTasks(s.opts.shutdownTimeout); err != nil {
+		log.Warn(err)
+	}
+	// the service context is already cancelled
+	if err := stopModules(context.Background(), s.modules); err != nil {
+		log.Errorf("failed to stop modules: %v", err)
+	}
+	s.metrics.shutdownDuration.Observe(time.Since(start).Seconds())
 	for i := range s.opts.afterStop {
 		if err := s.opts.afterStop[i](); err != nil {
 			return err
 		}
 	}
 	log.Info("server stopped")
+	s.events.Publish(events.Event{Type: events.Stopped})
+	// give the listeners, e.g. the notifiers, a chance to handle the last events
+	ctx, cancel := context.WithTimeout(context.Background(), s.opts.shutdownTimeout)
+	defer cancel()
+	if err := s.events.Flush(ctx); err != nil {
+		log.Warnf("events were not delivered within %v", s.opts.shutdownTimeout)
+	}
 	return nil
 }
 
@@ -309,6 +736,9 @@ type serviceInfo struct {
 func (s *service) registerService(sd *grpc.ServiceDesc, ss interface{}) {
 	s.server.RegisterService(sd, ss)
 	s.inproc.RegisterService(sd, ss)
+	if r, ok := ss.(HTTPRoutesRegisterer); ok {
+		r.RegisterHTTPRoutes(s)
+	}
 
 	if _, ok := s.services[sd.ServiceName]; ok {
 		logger.C(s.opts.ctx).Fatalf("grpc: Service.RegisterService found duplicate service registration for %q", sd.ServiceName)
@@ -364,6 +794,9 @@ func (s *service) Close() error {
 }
 
 func (s *service) notify() <-chan os.Signal {
+	if s.opts.signals != nil {
+		return s.opts.signals
+	}
 	sigs := make(chan os.Signal, 2)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGQUIT)
 	return sigs
@@ -0,0 +1,392 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+// startService starts a service with opts and waits for its startup, it is stopped at the end of the test
+func startService(t *testing.T, opts ...Option) Service {
+	t.Helper()
+	started := make(chan struct{})
+	svc, err := New(append(opts, WithAfterStart(func() error {
+		close(started)
+		return nil
+	}))...)
+	require.NoError(t, err)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- svc.Start()
+	}()
+	select {
+	case <-started:
+	case err := <-errs:
+		t.Fatalf("service stopped: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("service did not start")
+	}
+	t.Cleanup(func() {
+		assert.NoError(t, svc.Stop())
+		select {
+		case err := <-errs:
+			assert.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Error("service not stopped")
+		}
+	})
+	return svc
+}
+
+func TestAddressPortZero(t *testing.T) {
+	svc, err := New(WithAddress("127.0.0.1:0"), WithHealth(false))
+	require.NoError(t, err)
+	assert.Empty(t, svc.Address())
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- svc.Start()
+	}()
+	select {
+	case <-svc.Listening():
+	case err := <-errs:
+		t.Fatalf("service stopped: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("service did not listen")
+	}
+	host, port, err := net.SplitHostPort(svc.Address())
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", host)
+	assert.NotEqual(t, "0", port)
+
+	conn, err := net.Dial("tcp", svc.Address())
+	require.NoError(t, err)
+	conn.Close()
+
+	require.NoError(t, svc.Stop())
+}
+
+func TestDraining(t *testing.T) {
+	assert.Nil(t, Draining(context.Background()))
+
+	s := &service{}
+	unary, _ := s.drainInterceptors()
+	var ch <-chan struct{}
+	_, err := unary(context.Background(), nil, nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		ch = Draining(ctx)
+		return nil, nil
+	})
+	require.NoError(t, err)
+	require.NotNil(t, ch)
+	select {
+	case <-ch:
+		t.Fatal("draining before stop")
+	default:
+	}
+	s.drain()
+	select {
+	case <-ch:
+	default:
+		t.Fatal("not draining after stop")
+	}
+	// the requests received while stopping are draining too
+	_, err = unary(context.Background(), nil, nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		assert.Equal(t, ch, Draining(ctx))
+		return nil, nil
+	})
+	require.NoError(t, err)
+	s.drain()
+
+	// the next run gets a new channel
+	s.resetDrain()
+	_, err = unary(context.Background(), nil, nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		select {
+		case <-Draining(ctx):
+			t.Fatal("draining after restart")
+		default:
+		}
+		return nil, nil
+	})
+	require.NoError(t, err)
+}
+
+func TestContextValues(t *testing.T) {
+	type key struct{}
+	o := NewOptions()
+	WithContextValues(func(ctx context.Context) context.Context {
+		return context.WithValue(ctx, key{}, "a")
+	}, func(ctx context.Context) context.Context {
+		return context.WithValue(ctx, key{}, ctx.Value(key{}).(string)+"b")
+	})(o)
+	s := &service{opts: o}
+	unary, _ := s.contextValuesInterceptors()
+	var v interface{}
+	_, err := unary(context.Background(), nil, nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+		v = ctx.Value(key{})
+		return nil, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "ab", v)
+}
+
+func TestJob(t *testing.T) {
+	errJob := errors.New("job failed")
+	svc, err := NewJob(func(ctx context.Context) error {
+		return errJob
+	}, WithHealth(false))
+	require.NoError(t, err)
+	err = svc.Start()
+	assert.True(t, errors.Is(err, errJob))
+	var jerr *JobError
+	require.True(t, errors.As(err, &jerr))
+	assert.Equal(t, "job", jerr.Job)
+	assert.Equal(t, ExitCodeFailure, ExitCode(err))
+	assert.Empty(t, svc.Address())
+
+	svc, err = NewJob(func(ctx context.Context) error {
+		return nil
+	}, WithHealth(false))
+	require.NoError(t, err)
+	assert.NoError(t, svc.Start())
+}
+
+func TestJobs(t *testing.T) {
+	var ran []string
+	svc, err := New(
+		WithAddress("127.0.0.1:0"),
+		WithHealth(false),
+		WithJob("a", func(ctx context.Context) error {
+			ran = append(ran, "a")
+			return nil
+		}),
+		WithJob("b", func(ctx context.Context) error {
+			ran = append(ran, "b")
+			return WithExitCode(errors.New("retry"), 3)
+		}),
+		WithJob("c", func(ctx context.Context) error {
+			ran = append(ran, "c")
+			return nil
+		}),
+	)
+	require.NoError(t, err)
+	err = svc.Start()
+	assert.Equal(t, []string{"a", "b"}, ran)
+	assert.Equal(t, 3, ExitCode(err))
+}
+
+func TestExitCode(t *testing.T) {
+	assert.Equal(t, 0, ExitCode(nil))
+	assert.Equal(t, ExitCodeFailure, ExitCode(errors.New("failed")))
+	assert.Equal(t, ExitCodeInterrupted, ExitCode(ErrInterrupted))
+	assert.Equal(t, 42, ExitCode(&JobError{Job: "job", Err: WithExitCode(errors.New("failed"), 42)}))
+	assert.NoError(t, WithExitCode(nil, 42))
+}
+
+func TestWorker(t *testing.T) {
+	started := make(chan struct{})
+	svc, err := NewWorker(WithHealth(false), WithAfterStart(func() error {
+		close(started)
+		return nil
+	}))
+	require.NoError(t, err)
+	svc.Go("worker", func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	})
+	errs := make(chan error, 1)
+	go func() {
+		errs <- svc.Start()
+	}()
+	<-started
+	require.NoError(t, svc.Stop())
+	select {
+	case err := <-errs:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("worker did not stop")
+	}
+}
+
+func TestWaitForUnlocked(t *testing.T) {
+	checking := make(chan struct{})
+	svc, err := New(WithAddress("127.0.0.1:0"), WithHealth(false), WithWaitFor(NewCheck("blocked", func(ctx context.Context) error {
+		close(checking)
+		<-ctx.Done()
+		return ctx.Err()
+	})))
+	require.NoError(t, err)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- svc.Start()
+	}()
+	select {
+	case <-checking:
+	case err := <-errs:
+		t.Fatalf("service stopped: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("dependency not checked")
+	}
+	// the pending dependencies do not hold the service lock
+	_, err = svc.ConfigDump()
+	require.NoError(t, err)
+	require.NoError(t, svc.Stop())
+	select {
+	case err := <-errs:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("service not stopped")
+	}
+}
+
+func TestStartFailureCloses(t *testing.T) {
+	free := func() string {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer l.Close()
+		return l.Addr().String()
+	}
+	address, admin := free(), free()
+	boom := errors.New("boom")
+	svc, err := New(
+		WithAddress(address),
+		WithHealth(false),
+		WithAdmin("/admin"),
+		WithAdminAddress(admin),
+		WithBeforeStart(func() error { return boom }),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, boom, svc.Start())
+	// both the service and the admin addresses are released
+	for _, v := range []string{address, admin} {
+		assert.Eventually(t, func() bool {
+			l, err := net.Listen("tcp", v)
+			if err != nil {
+				return false
+			}
+			l.Close()
+			return true
+		}, time.Second, 10*time.Millisecond)
+	}
+}
+
+func TestDeregisterDelay(t *testing.T) {
+	start := func(reg *recordRegistry, delay time.Duration, opts ...Option) Service {
+		svc := startService(t, append([]Option{
+			WithAddress("127.0.0.1:0"),
+			WithRegistry(reg),
+			WithDeregisterDelay(delay),
+		}, opts...)...)
+		require.Equal(t, "register", reg.next(t))
+		return svc
+	}
+	stop := func(svc Service) <-chan error {
+		stopped := make(chan error, 1)
+		go func() {
+			stopped <- svc.Stop()
+		}()
+		return stopped
+	}
+
+	reg := newRecordRegistry()
+	var (
+		status       grpc_health_v1.HealthCheckResponse_ServingStatus
+		deregistered time.Time
+	)
+	svc := start(reg, 200*time.Millisecond)
+	reg.deregistered = func() {
+		res, err := svc.(*service).health.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		if err == nil {
+			status = res.GetStatus()
+		}
+		deregistered = time.Now()
+	}
+	stopped := stop(svc)
+	require.Equal(t, "deregister", reg.next(t))
+	// the service is not serving anymore when deregistered
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, status)
+	// but still accepts connections until the load balancers converge
+	conn, err := net.Dial("tcp", svc.Address())
+	require.NoError(t, err)
+	conn.Close()
+	select {
+	case err := <-stopped:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("service not stopped")
+	}
+	assert.True(t, time.Since(deregistered) >= 200*time.Millisecond)
+
+	// a signal skips the delay
+	sigs := make(chan os.Signal, 1)
+	reg = newRecordRegistry()
+	svc = start(reg, time.Minute, withSignals(sigs))
+	stopped = stop(svc)
+	require.Equal(t, "deregister", reg.next(t))
+	// the delay starts once the service is deregistered
+	tick := time.NewTicker(20 * time.Millisecond)
+	defer tick.Stop()
+	timeout := time.After(5 * time.Second)
+	for done := false; !done; {
+		select {
+		case err := <-stopped:
+			require.NoError(t, err)
+			done = true
+		case <-tick.C:
+			// the serving loop may receive it too, it stops the service as well
+			select {
+			case sigs <- syscall.SIGINT:
+			default:
+			}
+		case <-timeout:
+			t.Fatal("deregister delay not skipped")
+		}
+	}
+}
+
+func TestServedBy(t *testing.T) {
+	reg := newRecordRegistry()
+	svc := startService(t,
+		WithName("test"),
+		WithVersion("v1.0.0"),
+		WithAddress("127.0.0.1:0"),
+		WithRegistry(reg),
+		WithNodeMetadata(map[string]string{"zone": "eu-west-1a"}),
+		WithServedBy(),
+	)
+	nodeID := "test-" + svc.ID()
+
+	md := svc.NodeMetadata()
+	assert.Equal(t, "eu-west-1a", md["zone"])
+	assert.Equal(t, svc.ID(), md[MetadataInstanceID])
+	assert.Equal(t, "v1.0.0", md[MetadataVersion])
+	assert.Equal(t, "ipv4", md[MetadataAddressFamily])
+	// the returned metadata is a copy
+	md["zone"] = "us-east-1a"
+	assert.Equal(t, "eu-west-1a", svc.NodeMetadata()["zone"])
+
+	require.Equal(t, "register", reg.next(t))
+	node := reg.service().Nodes[0]
+	assert.Equal(t, nodeID, node.Id)
+	assert.Equal(t, svc.AdvertisedAddress(), node.Address)
+	assert.Equal(t, svc.NodeMetadata(), node.Metadata)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, svc.Address(), grpc.WithInsecure(), grpc.WithBlock())
+	require.NoError(t, err)
+	defer conn.Close()
+	var header metadata.MD
+	_, err = grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{}, grpc.Header(&header))
+	require.NoError(t, err)
+	assert.Equal(t, []string{nodeID}, header.Get(ServedByKey))
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+var (
+	// ErrAddressInUse is the kind of the StartupError returned when a listener address is already in use
+	ErrAddressInUse = errors.New("address already in use")
+	// ErrInvalidCertificate is the kind of the StartupError returned when the tls certificate files cannot be loaded
+	ErrInvalidCertificate = errors.New("invalid certificate")
+	// ErrRegistryUnreachable is the kind of the StartupError returned when the service cannot be registered
+	ErrRegistryUnreachable = errors.New("registry unreachable")
+	// ErrDatabaseUnreachable is the kind of the StartupError returned by DatabaseError
+	ErrDatabaseUnreachable = errors.New("database unreachable")
+)
+
+// StartupError is a failure of the service startup with a hint about how to fix it.
+// It matches its Kind with errors.Is and unwraps to its cause:
+//
+//	if errors.Is(err, service.ErrAddressInUse) {
+//		...
+//	}
+type StartupError struct {
+	// Kind is the failure kind, e.g. ErrAddressInUse
+	Kind error
+	// Op is the failed operation, e.g. "admin listen 127.0.0.1:9090"
+	Op   string
+	Hint string
+	Err  error
+}
+
+func (e *StartupError) Error() string {
+	msg := fmt.Sprintf("%s: %v", e.Op, e.Err)
+	if e.Hint != "" {
+		msg += " (hint: " + e.Hint + ")"
+	}
+	return msg
+}
+
+func (e *StartupError) Unwrap() error {
+	return e.Err
+}
+
+func (e *StartupError) Is(target error) bool {
+	return e.Kind == target
+}
+
+// DatabaseError returns a StartupError for a database connection failure, e.g. from a module
+// or a WithBeforeStart hook, dsn is only used in the hint and must not contain credentials
+func DatabaseError(dsn string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &StartupError{
+		Kind: ErrDatabaseUnreachable,
+		Op:   "connect database",
+		Hint: fmt.Sprintf("check that the database %s is running and reachable and that the credentials are valid", dsn),
+		Err:  err,
+	}
+}
+
+// listenError classifies the failure to listen on address
+func listenError(name, address string, err error) error {
+	if err == nil {
+		return nil
+	}
+	op := fmt.Sprintf("%s listen %s", name, address)
+	if errors.Is(err, syscall.EADDRINUSE) {
+		return &StartupError{
+			Kind: ErrAddressInUse,
+			Op:   op,
+			Hint: "another process, or another instance of the service, is listening on this address: stop it or choose another address",
+			Err:  err,
+		}
+	}
+	return fmt.Errorf("%s: %w", op, err)
+}
+
+var errInvalidCACert = errors.New("failed to load CA Cert")
+
+// certificateError classifies the failure to load the tls certificate files
+func certificateError(cert, key, ca string, err error) error {
+	if err == nil {
+		return nil
+	}
+	e := &StartupError{Kind: ErrInvalidCertificate, Op: "load tls certificate", Err: err}
+	var pathErr *os.PathError
+	switch {
+	case errors.As(err, &pathErr):
+		e.Hint = fmt.Sprintf("check that %s exists and is readable by the service user", pathErr.Path)
+	case errors.Is(err, errInvalidCACert):
+		e.Hint = fmt.Sprintf("check that %s contains PEM encoded CA certificates", ca)
+	default:
+		e.Hint = fmt.Sprintf("check that %s and %s are a matching PEM encoded certificate and private key pair", cert, key)
+	}
+	return e
+}
+
+// registryError classifies the failure to register the service
+func registryError(registry string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &StartupError{
+		Kind: ErrRegistryUnreachable,
+		Op:   fmt.Sprintf("register service in %s registry", registry),
+		Hint: "check the registry address and that it is reachable from the service",
+		Err:  err,
+	}
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"crypto/tls"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenError(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer lis.Close()
+	_, err = net.Listen("tcp", lis.Addr().String())
+	require.Error(t, err)
+	err = listenError("service", lis.Addr().String(), err)
+	assert.True(t, errors.Is(err, ErrAddressInUse))
+	assert.True(t, errors.Is(err, syscall.EADDRINUSE))
+	var e *StartupError
+	require.True(t, errors.As(err, &e))
+	assert.NotEmpty(t, e.Hint)
+	assert.Contains(t, err.Error(), "service listen "+lis.Addr().String())
+
+	err = listenError("service", ":0", errors.New("noop"))
+	assert.False(t, errors.Is(err, ErrAddressInUse))
+	assert.NoError(t, listenError("service", ":0", nil))
+}
+
+func TestCertificateError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	cert, key := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	_, err = tls.LoadX509KeyPair(cert, key)
+	err = certificateError(cert, key, "", err)
+	assert.True(t, errors.Is(err, ErrInvalidCertificate))
+	assert.Contains(t, err.Error(), "check that "+cert+" exists")
+
+	require.NoError(t, ioutil.WriteFile(cert, []byte("cert"), 0600))
+	require.NoError(t, ioutil.WriteFile(key, []byte("key"), 0600))
+	_, err = tls.LoadX509KeyPair(cert, key)
+	err = certificateError(cert, key, "", err)
+	assert.True(t, errors.Is(err, ErrInvalidCertificate))
+	assert.Contains(t, err.Error(), "matching PEM encoded certificate and private key pair")
+}
+
+func TestDatabaseError(t *testing.T) {
+	assert.NoError(t, DatabaseError("postgres://db:5432", nil))
+	err := DatabaseError("postgres://db:5432", errors.New("connection refused"))
+	assert.True(t, errors.Is(err, ErrDatabaseUnreachable))
+	assert.False(t, errors.Is(err, ErrAddressInUse))
+	assert.Contains(t, err.Error(), "postgres://db:5432")
+}
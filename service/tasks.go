@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"go.linka.cloud/grpc/events"
+	"go.linka.cloud/grpc/logger"
+)
+
+const defaultShutdownTimeout = 10 * time.Second
+
+// Go runs fn in a background goroutine tied to the service context.
+// Panics are recovered and logged, and the service waits for the task to return
+// (up to the shutdown timeout) when it stops.
+func (s *service) Go(name string, fn func(ctx context.Context) error) {
+	ctx := s.opts.ctx
+	log := logger.C(ctx).WithField("task", name)
+	s.tasks.Add(1)
+	s.metrics.tasksRunning.WithLabelValues(name).Inc()
+	go func() {
+		result := "success"
+		defer func() {
+			if r := recover(); r != nil {
+				result = "panic"
+				log.Errorf("task panicked: %v\n%s", r, debug.Stack())
+				s.events.Publish(events.Event{Type: events.TaskPanicked, Task: name, Panic: r})
+			}
+			s.metrics.tasksRunning.WithLabelValues(name).Dec()
+			s.metrics.tasksTotal.WithLabelValues(name, result).Inc()
+			s.tasks.Done()
+		}()
+		log.Debug("task started")
+		if err := fn(ctx); err != nil && ctx.Err() == nil {
+			result = "error"
+			log.WithError(err).Error("task failed")
+			return
+		}
+		log.Debug("task stopped")
+	}()
+}
+
+// waitTasks waits for the background tasks to return
+func (s *service) waitTasks(timeout time.Duration) error {
+	done := make(chan struct{})
+	go func() {
+		s.tasks.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("background tasks did not stop within %v", timeout)
+	}
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.linka.cloud/grpc/events"
+)
+
+func TestGo(t *testing.T) {
+	s := &service{opts: NewOptions(), metrics: newMetrics(nil), events: events.NewBus()}
+	ch := make(chan events.Event, 1)
+	defer s.events.Subscribe(func(e events.Event) {
+		ch <- e
+	}, events.TaskPanicked)()
+
+	s.Go("panic", func(ctx context.Context) error {
+		panic("boom")
+	})
+	s.Go("error", func(ctx context.Context) error {
+		return errors.New("failed")
+	})
+	s.Go("success", func(ctx context.Context) error {
+		return nil
+	})
+	// the panic does not crash the process and is reported
+	select {
+	case e := <-ch:
+		assert.Equal(t, "panic", e.Task)
+		assert.Equal(t, "boom", e.Panic)
+	case <-time.After(time.Second):
+		t.Fatal("no task panicked event")
+	}
+	require.NoError(t, s.waitTasks(time.Second))
+	for _, v := range []string{"panic", "error", "success"} {
+		assert.Equal(t, float64(1), testutil.ToFloat64(s.metrics.tasksTotal.WithLabelValues(v, v)))
+		assert.Equal(t, float64(0), testutil.ToFloat64(s.metrics.tasksRunning.WithLabelValues(v)))
+	}
+
+	// the tasks ignoring the context are not waited for past the timeout
+	release := make(chan struct{})
+	defer close(release)
+	s.Go("stuck", func(ctx context.Context) error {
+		<-release
+		return nil
+	})
+	assert.Equal(t, float64(1), testutil.ToFloat64(s.metrics.tasksRunning.WithLabelValues("stuck")))
+	start := time.Now()
+	err := s.waitTasks(50 * time.Millisecond)
+	require.Error(t, err)
+	assert.Equal(t, "background tasks did not stop within 50ms", err.Error())
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+}
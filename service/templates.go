@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+
+	"google.golang.org/grpc/health/grpc_health_v1"
+
+	"go.linka.cloud/grpc/events"
+	"go.linka.cloud/grpc/logger"
+)
+
+// NewAPIGateway returns a service exposing its grpc services over http, through the grpc-gateway
+// registered with gateway, and grpc-web, with the health service enabled.
+// The opts are applied after the template ones so they can override them.
+func NewAPIGateway(gateway RegisterGatewayFunc, opts ...Option) (Service, error) {
+	return New(append([]Option{WithGateway(gateway), WithGRPCWeb(true), WithHealth(true)}, opts...)...)
+}
+
+// NewWorker returns a service without listener nor registration, running the modules, e.g. a broker
+// consumer or a cron scheduler, and the background tasks started with Go until it is stopped.
+func NewWorker(opts ...Option) (Service, error) {
+	return New(append([]Option{WithoutListener()}, opts...)...)
+}
+
+// NewJob returns a service without listener nor registration which runs job to completion:
+// Start returns once the job has returned, with its error, and the service is stopped.
+// The job context is cancelled when the service is stopped, e.g. on SIGINT.
+// See WithJob to run the jobs in a serving service.
+func NewJob(job func(ctx context.Context) error, opts ...Option) (Service, error) {
+	return New(append([]Option{WithoutListener(), WithJob("job", job)}, opts...)...)
+}
+
+// runWithoutListener runs the service lifecycle without serving, s.mu must be held
+func (s *service) runWithoutListener() error {
+	if err := s.serveAdmin(); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	for i := range s.opts.beforeStart {
+		if err := s.opts.beforeStart[i](); err != nil {
+			return s.abort(nil, err)
+		}
+	}
+	if err := startModules(s.opts.ctx, s.modules); err != nil {
+		return s.abort(nil, err)
+	}
+	s.running = true
+	if stopped, err := s.unlocked(s.waitFor); stopped || err != nil {
+		return s.startFailed(stopped, err)
+	}
+	if s.health != nil {
+		s.health.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	}
+	for i := range s.opts.afterStart {
+		if err := s.opts.afterStart[i](); err != nil {
+			s.mu.Unlock()
+			s.Stop()
+			return err
+		}
+	}
+	closed := s.closed
+	s.mu.Unlock()
+	s.events.Publish(events.Event{Type: events.Started})
+	jobs := s.runJobs()
+	sigs := s.notify()
+	select {
+	case sig := <-sigs:
+		logger.C(s.opts.ctx).Warnf("received %v", sig)
+		return s.interrupted()
+	case err := <-jobs:
+		return s.jobsDone(err)
+	case <-closed:
+		return nil
+	}
+}
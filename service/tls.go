@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"time"
+
+	"go.linka.cloud/grpc/certs/revocation"
+	"go.linka.cloud/grpc/logger"
+)
+
+const (
+	defaultSessionTicketRotation = 12 * time.Hour
+	// sessionTicketKeys is the number of keys kept so that the tickets issued with the previous keys are still accepted
+	sessionTicketKeys = 3
+)
+
+// DefaultTLSCipherSuites are the TLS 1.2 cipher suites used when none are configured: forward secret AEAD only.
+// The TLS 1.3 cipher suites are not configurable.
+var DefaultTLSCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+}
+
+// DefaultTLSCurvePreferences are the key exchange curves used when none are configured
+var DefaultTLSCurvePreferences = []tls.CurveID{tls.X25519, tls.CurveP256}
+
+// hardenTLS applies the tls options and the secure defaults to the fields left empty.
+// The config is cloned as it may be shared with the caller.
+func (o *options) hardenTLS() {
+	if o.tlsConfig == nil {
+		return
+	}
+	c := o.tlsConfig.Clone()
+	if o.tlsMinVersion != 0 {
+		c.MinVersion = o.tlsMinVersion
+	}
+	if c.MinVersion == 0 {
+		c.MinVersion = tls.VersionTLS12
+	}
+	if len(o.tlsCipherSuites) != 0 {
+		c.CipherSuites = o.tlsCipherSuites
+	}
+	if len(c.CipherSuites) == 0 {
+		c.CipherSuites = DefaultTLSCipherSuites
+	}
+	if len(o.tlsCurves) != 0 {
+		c.CurvePreferences = o.tlsCurves
+	}
+	if len(c.CurvePreferences) == 0 {
+		c.CurvePreferences = DefaultTLSCurvePreferences
+	}
+	if o.revocationChecker != nil {
+		verify := revocation.VerifyPeerCertificate(o.revocationChecker, o.revocationSoftFail)
+		if prev := c.VerifyPeerCertificate; prev != nil {
+			c.VerifyPeerCertificate = func(raw [][]byte, chains [][]*x509.Certificate) error {
+				if err := prev(raw, chains); err != nil {
+					return err
+				}
+				return verify(raw, chains)
+			}
+		} else {
+			c.VerifyPeerCertificate = verify
+		}
+	}
+	o.tlsConfig = c
+}
+
+// setupOCSPStapling serves the server certificate with its stapled OCSP response
+func (s *service) setupOCSPStapling() error {
+	c := s.opts.tlsConfig
+	if !s.opts.ocspStapling || c == nil {
+		return nil
+	}
+	if len(c.Certificates) == 0 || c.GetCertificate != nil {
+		return errors.New("ocsp stapling requires a static tls certificate")
+	}
+	st, err := revocation.NewStapler(c.Certificates[0], s.opts.ocspStaplingOpts...)
+	if err != nil {
+		return err
+	}
+	c.GetCertificate = st.GetCertificate
+	s.stapler = st
+	return nil
+}
+
+// staple keeps the stapled OCSP response up to date
+func (s *service) staple() {
+	if s.stapler == nil {
+		return
+	}
+	s.Go("ocsp-stapling", func(ctx context.Context) error {
+		s.stapler.Run(ctx, func(err error) {
+			logger.C(ctx).Warnf("failed to refresh ocsp staple: %v", err)
+		})
+		return nil
+	})
+}
+
+// rotateSessionTicketKeys regularly replaces the session ticket encryption key so that
+// a leaked key only compromises the sessions of a limited period
+func (s *service) rotateSessionTicketKeys() error {
+	c := s.opts.tlsConfig
+	if c == nil || c.SessionTicketsDisabled || s.opts.sessionTicketRotation < 0 {
+		return nil
+	}
+	interval := s.opts.sessionTicketRotation
+	if interval == 0 {
+		interval = defaultSessionTicketRotation
+	}
+	var keys [][32]byte
+	rotate := func() error {
+		var k [32]byte
+		if _, err := rand.Read(k[:]); err != nil {
+			return err
+		}
+		keys = append([][32]byte{k}, keys...)
+		if len(keys) > sessionTicketKeys {
+			keys = keys[:sessionTicketKeys]
+		}
+		c.SetSessionTicketKeys(keys)
+		return nil
+	}
+	if err := rotate(); err != nil {
+		return err
+	}
+	s.Go("tls-session-tickets", func(ctx context.Context) error {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-t.C:
+				if err := rotate(); err != nil {
+					return err
+				}
+			}
+		}
+	})
+	return nil
+}
+
+func tlsVersionName(v uint16) string {
+	switch v {
+	case tls.VersionTLS10:
+		return "1.0"
+	case tls.VersionTLS11:
+		return "1.1"
+	case tls.VersionTLS12:
+		return "1.2"
+	case tls.VersionTLS13:
+		return "1.3"
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.linka.cloud/grpc/certs/revocation"
+)
+
+func TestHardenTLS(t *testing.T) {
+	o := NewOptions()
+	o.hardenTLS()
+	assert.Nil(t, o.tlsConfig)
+
+	user := &tls.Config{CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384}}
+	o.tlsConfig = user
+	o.hardenTLS()
+	assert.Equal(t, uint16(tls.VersionTLS12), o.tlsConfig.MinVersion)
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384}, o.tlsConfig.CipherSuites)
+	assert.Equal(t, DefaultTLSCurvePreferences, o.tlsConfig.CurvePreferences)
+	// the caller config is not modified
+	assert.Equal(t, uint16(0), user.MinVersion)
+
+	o = NewOptions()
+	WithTLSConfig(&tls.Config{})(o)
+	WithTLS13Only()(o)
+	o.hardenTLS()
+	assert.Equal(t, uint16(tls.VersionTLS13), o.tlsConfig.MinVersion)
+	assert.Equal(t, DefaultTLSCipherSuites, o.tlsConfig.CipherSuites)
+}
+
+func TestHasTLSConfig(t *testing.T) {
+	o := NewOptions()
+	assert.False(t, o.hasTLSConfig())
+	WithCert("cert.pem")(o)
+	WithKey("key.pem")(o)
+	// the CA certificate is not required to serve tls
+	assert.True(t, o.hasTLSConfig())
+	WithCACert("ca.pem")(o)
+	assert.True(t, o.hasTLSConfig())
+}
+
+func TestClientRevocationCheck(t *testing.T) {
+	var called []string
+	o := NewOptions()
+	WithTLSConfig(&tls.Config{VerifyPeerCertificate: func([][]byte, [][]*x509.Certificate) error {
+		called = append(called, "user")
+		return nil
+	}})(o)
+	WithClientRevocationCheck(revocation.CheckerFunc(func(_ context.Context, cert, _ *x509.Certificate) error {
+		called = append(called, "revocation")
+		if cert.SerialNumber.Int64() == 2 {
+			return revocation.ErrRevoked
+		}
+		return nil
+	}), false)(o)
+	o.hardenTLS()
+	issuer := &x509.Certificate{SerialNumber: big.NewInt(1)}
+	assert.NoError(t, o.tlsConfig.VerifyPeerCertificate(nil, [][]*x509.Certificate{{{SerialNumber: big.NewInt(3)}, issuer}}))
+	assert.Equal(t, []string{"user", "revocation"}, called)
+	err := o.tlsConfig.VerifyPeerCertificate(nil, [][]*x509.Certificate{{{SerialNumber: big.NewInt(2)}, issuer}})
+	assert.True(t, errors.Is(err, revocation.ErrRevoked))
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/protobuf/proto"
+)
+
+// gatewayTrailers copies the mapped trailers to the response headers, before the response is written
+func (s *service) gatewayTrailers(ctx context.Context, w http.ResponseWriter, _ proto.Message) error {
+	md, ok := runtime.ServerMetadataFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	for k, h := range s.opts.gatewayTrailers {
+		for _, v := range md.TrailerMD.Get(k) {
+			w.Header().Add(h, v)
+		}
+	}
+	return nil
+}
+
+// corsExposedHeaders returns the response headers readable by the browsers: the grpc status used by grpc-web,
+// the mapped gateway trailers and the declared headers
+func (o *options) corsExposedHeaders() []string {
+	h := []string{"Grpc-Status", "Grpc-Message", "Grpc-Status-Details-Bin"}
+	for _, v := range o.gatewayTrailers {
+		h = append(h, v)
+	}
+	return append(h, o.exposedHeaders...)
+}
@@ -0,0 +1,28 @@
+package service
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestGatewayTrailers(t *testing.T) {
+	o := NewOptions()
+	WithGatewayTrailerMapping("X-Total-Count", "X-Total-Count")(o)
+	WithExposedHeaders("X-Custom")(o)
+	s := &service{opts: o}
+
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{
+		TrailerMD: metadata.Pairs("x-total-count", "42", "x-other", "value"),
+	})
+	rec := httptest.NewRecorder()
+	assert.NoError(t, s.gatewayTrailers(ctx, rec, nil))
+	assert.Equal(t, "42", rec.Header().Get("X-Total-Count"))
+	assert.Empty(t, rec.Header().Get("X-Other"))
+
+	assert.Equal(t, []string{"Grpc-Status", "Grpc-Message", "Grpc-Status-Details-Bin", "X-Total-Count", "X-Custom"}, o.corsExposedHeaders())
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"go.uber.org/multierr"
+)
+
+var (
+	// ErrInvalidAddress is returned when the server address is not a valid host:port
+	ErrInvalidAddress = errors.New("invalid address")
+	// ErrGatewayPrefixWithoutGateway is returned when a gateway prefix is set without gateway
+	ErrGatewayPrefixWithoutGateway = errors.New("gateway prefix set without gateway")
+	// ErrGRPCWebPrefixWithoutGRPCWeb is returned when a grpc-web prefix is set without enabling grpc-web
+	ErrGRPCWebPrefixWithoutGRPCWeb = errors.New("grpc-web prefix set without grpc-web")
+	// ErrRouteConflict is returned when multiple http handlers are mounted on the same route
+	ErrRouteConflict = errors.New("http route conflict")
+	// ErrTLSCertWithoutKey is returned when a certificate is set without key
+	ErrTLSCertWithoutKey = errors.New("tls certificate set without key")
+	// ErrTLSKeyWithoutCert is returned when a key is set without certificate
+	ErrTLSKeyWithoutCert = errors.New("tls key set without certificate")
+	// ErrTLSCACertWithoutCert is returned when a CA certificate is set without certificate and key
+	ErrTLSCACertWithoutCert = errors.New("tls CA certificate set without certificate and key")
+	// ErrTLSConflict is returned when both a tls config and certificate files are set
+	ErrTLSConflict = errors.New("tls config set with certificate files")
+	// ErrInvalidNetwork is returned when the network is not one of tcp, tcp4 or tcp6
+	ErrInvalidNetwork = errors.New("invalid network")
+	// ErrInvalidTimeout is returned when a negative timeout is set
+	ErrInvalidTimeout = errors.New("invalid timeout")
+	// ErrAdminWithoutPrefix is returned when the admin address or handlers are set without admin prefix
+	ErrAdminWithoutPrefix = errors.New("admin address or handlers set without admin prefix")
+
+	// ErrRuntimeMetricsWithoutRegisterer is returned when the runtime metrics are enabled without metrics registerer
+	ErrRuntimeMetricsWithoutRegisterer = errors.New("runtime metrics enabled without metrics registerer")
+
+	// ErrHTTPSRedirectWithoutTLS is returned when the https redirect is enabled without tls
+	ErrHTTPSRedirectWithoutTLS = errors.New("https redirect enabled without tls")
+
+	// ErrUnknownProfile is returned when the selected profile is not registered
+	ErrUnknownProfile = errors.New("unknown profile")
+
+	errNilInterceptors = errors.New("nil interceptors, their constructor probably failed")
+)
+
+// OptionError is a failure of an option, reported by New along with the other options failures
+type OptionError struct {
+	// Option identifies the failed option, e.g. "WithServerInterceptors"
+	Option string
+	Err    error
+}
+
+func (e *OptionError) Error() string {
+	return fmt.Sprintf("option %s: %v", e.Option, e.Err)
+}
+
+func (e *OptionError) Unwrap() error {
+	return e.Err
+}
+
+// OptionFunc turns an option constructor which can fail, e.g. one building interceptors from a JWKS url
+// or a policy file, into an Option. The error is reported by New as an OptionError identified by name:
+//
+//	service.OptionFunc("jwt", func() (service.Option, error) {
+//		i, err := jwt.NewServerInterceptors(jwksURL)
+//		if err != nil {
+//			return nil, err
+//		}
+//		return service.WithServerInterceptors(i), nil
+//	})
+func OptionFunc(name string, fn func() (Option, error)) Option {
+	return func(o *options) {
+		opt, err := fn()
+		if err != nil {
+			o.fail(name, err)
+			return
+		}
+		if opt != nil {
+			opt(o)
+		}
+	}
+}
+
+// fail records the failure of an option
+func (o *options) fail(option string, err error) {
+	o.errors = append(o.errors, &OptionError{Option: option, Err: err})
+}
+
+// validate checks the options consistency and returns all the problems found
+func (o *options) validate() error {
+	var err error
+	add := func(e error, format string, args ...interface{}) {
+		err = multierr.Append(err, fmt.Errorf("%w: "+format, append([]interface{}{e}, args...)...))
+	}
+	if _, _, e := net.SplitHostPort(o.address); e != nil {
+		add(ErrInvalidAddress, "%q: %v", o.address, e)
+	}
+	switch o.network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		add(ErrInvalidNetwork, "%q", o.network)
+	}
+	if o.dualStack && o.network != "tcp" {
+		add(ErrInvalidNetwork, "dual-stack requires the tcp network, got %q", o.network)
+	}
+	if o.gatewayPrefix != "" && !o.Gateway() {
+		add(ErrGatewayPrefixWithoutGateway, "%q", o.gatewayPrefix)
+	}
+	if o.grpcWebPrefix != "" && !o.grpcWeb {
+		add(ErrGRPCWebPrefixWithoutGRPCWeb, "%q", o.grpcWebPrefix)
+	}
+	if o.Gateway() && o.gatewayPrefix == "" && o.hasReactUI {
+		add(ErrRouteConflict, "both gateway and react ui are mounted on /")
+	}
+	if o.grpcWeb && o.Gateway() && o.grpcWebPrefix != "" && o.grpcWebPrefix == o.gatewayPrefix {
+		add(ErrRouteConflict, "both gateway and grpc-web are mounted on %q", o.gatewayPrefix)
+	}
+	if o.cert != "" && o.key == "" {
+		add(ErrTLSCertWithoutKey, "%q", o.cert)
+	}
+	if o.key != "" && o.cert == "" {
+		add(ErrTLSKeyWithoutCert, "%q", o.key)
+	}
+	if o.caCert != "" && (o.cert == "" || o.key == "") {
+		add(ErrTLSCACertWithoutCert, "%q", o.caCert)
+	}
+	if o.tlsConfig != nil && (o.cert != "" || o.key != "" || o.caCert != "") {
+		add(ErrTLSConflict, "use either WithTLSConfig or WithCACert, WithCert and WithKey")
+	}
+	if o.adminPrefix == "" && (o.adminAddress != "" || len(o.adminHandlers) != 0) {
+		add(ErrAdminWithoutPrefix, "use WithAdmin")
+	}
+	if o.runtimeMetrics && o.metricsRegisterer == nil {
+		add(ErrRuntimeMetricsWithoutRegisterer, "use WithMetricsRegisterer")
+	}
+	if o.httpsRedirect && !o.secure && o.tlsConfig == nil && o.cert == "" {
+		add(ErrHTTPSRedirectWithoutTLS, "use WithSecure, WithTLSConfig or WithCert and WithKey")
+	}
+	if o.shutdownTimeout < 0 {
+		add(ErrInvalidTimeout, "shutdown timeout: %v", o.shutdownTimeout)
+	}
+	if o.deregisterDelay < 0 {
+		add(ErrInvalidTimeout, "deregister delay: %v", o.deregisterDelay)
+	}
+	if o.waitForTimeout < 0 {
+		add(ErrInvalidTimeout, "wait for timeout: %v", o.waitForTimeout)
+	}
+	if o.logSamplingBurst > 0 && o.logSamplingPeriod <= 0 {
+		add(ErrInvalidTimeout, "log sampling period: %v", o.logSamplingPeriod)
+	}
+	if o.registrationTimeout < 0 {
+		add(ErrInvalidTimeout, "registration timeout: %v", o.registrationTimeout)
+	}
+	if o.regTTL < 0 || o.regInterval < 0 || (o.regInterval > 0 && o.regInterval >= o.registerTTL()) {
+		add(ErrInvalidTimeout, "register ttl %v with refresh interval %v", o.regTTL, o.regInterval)
+	}
+	if o.addressDetection < 0 {
+		add(ErrInvalidTimeout, "address detection interval: %v", o.addressDetection)
+	}
+	return err
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/multierr"
+	"google.golang.org/grpc"
+)
+
+func TestValidate(t *testing.T) {
+	gw := func(ctx context.Context, mux *runtime.ServeMux, cc grpc.ClientConnInterface) error {
+		return nil
+	}
+	tests := []struct {
+		name string
+		opts []Option
+		errs []error
+	}{
+		{
+			name: "defaults",
+		},
+		{
+			name: "invalid address",
+			opts: []Option{WithAddress("localhost")},
+			errs: []error{ErrInvalidAddress},
+		},
+		{
+			name: "gateway prefix without gateway",
+			opts: []Option{WithGatewayPrefix("/api")},
+			errs: []error{ErrGatewayPrefixWithoutGateway},
+		},
+		{
+			name: "gateway with prefix",
+			opts: []Option{WithGateway(gw), WithGatewayPrefix("/api")},
+		},
+		{
+			name: "grpc-web prefix without grpc-web",
+			opts: []Option{WithGRPCWebPrefix("/grpc")},
+			errs: []error{ErrGRPCWebPrefixWithoutGRPCWeb},
+		},
+		{
+			name: "gateway and grpc-web on the same prefix",
+			opts: []Option{WithGateway(gw), WithGatewayPrefix("/api"), WithGRPCWeb(true), WithGRPCWebPrefix("/api")},
+			errs: []error{ErrRouteConflict},
+		},
+		{
+			name: "tls files",
+			opts: []Option{WithCACert("ca.pem"), WithKey("key.pem")},
+			errs: []error{ErrTLSKeyWithoutCert, ErrTLSCACertWithoutCert},
+		},
+		{
+			name: "tls files without CA certificate",
+			opts: []Option{WithCert("cert.pem"), WithKey("key.pem"), WithHTTPSRedirect("")},
+		},
+		{
+			name: "log sampling without period",
+			opts: []Option{WithLogSampling(10, 0)},
+			errs: []error{ErrInvalidTimeout},
+		},
+		{
+			name: "invalid network",
+			opts: []Option{WithNetwork("udp")},
+			errs: []error{ErrInvalidNetwork},
+		},
+		{
+			name: "dual-stack with tcp6",
+			opts: []Option{WithNetwork("tcp6"), WithDualStack()},
+			errs: []error{ErrInvalidNetwork},
+		},
+		{
+			name: "admin address without admin",
+			opts: []Option{WithAdminAddress("127.0.0.1:9090")},
+			errs: []error{ErrAdminWithoutPrefix},
+		},
+		{
+			name: "runtime metrics without registerer",
+			opts: []Option{WithRuntimeMetrics()},
+			errs: []error{ErrRuntimeMetricsWithoutRegisterer},
+		},
+		{
+			name: "https redirect without tls",
+			opts: []Option{WithHTTPSRedirect("")},
+			errs: []error{ErrHTTPSRedirectWithoutTLS},
+		},
+		{
+			name: "negative timeouts",
+			opts: []Option{WithShutdownTimeout(-1), WithWaitForTimeout(-1)},
+			errs: []error{ErrInvalidTimeout, ErrInvalidTimeout},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := NewOptions()
+			for _, v := range tt.opts {
+				v(o)
+			}
+			errs := multierr.Errors(o.validate())
+			if !assert.Len(t, errs, len(tt.errs)) {
+				return
+			}
+			for i, v := range tt.errs {
+				assert.True(t, errors.Is(errs[i], v), "expected %v, got %v", v, errs[i])
+			}
+		})
+	}
+}
+
+func TestOptionErrors(t *testing.T) {
+	boom := errors.New("invalid policy file")
+	_, err := newService(
+		OptionFunc("policy", func() (Option, error) {
+			return nil, boom
+		}),
+		OptionFunc("served-by", func() (Option, error) {
+			return WithServedBy(), nil
+		}),
+		WithServerInterceptors(nil),
+		WithTrustedProxies("10.0.0.0/33"),
+		WithAddress("localhost"),
+	)
+	errs := multierr.Errors(err)
+	if !assert.Len(t, errs, 4) {
+		return
+	}
+	var oerr *OptionError
+	if assert.True(t, errors.As(errs[0], &oerr)) {
+		assert.Equal(t, "policy", oerr.Option)
+		assert.True(t, errors.Is(oerr, boom))
+	}
+	if assert.True(t, errors.As(errs[1], &oerr)) {
+		assert.Equal(t, "WithServerInterceptors", oerr.Option)
+	}
+	if assert.True(t, errors.As(errs[2], &oerr)) {
+		assert.Equal(t, "WithTrustedProxies", oerr.Option)
+	}
+	assert.True(t, errors.Is(errs[3], ErrInvalidAddress))
+}
@@ -25,7 +25,7 @@ func (s *service) grpcWeb(opts ...grpcweb.Option) error {
 	if !s.opts.grpcWeb {
 		return nil
 	}
-	h := grpcweb.WrapServer(s.server, append(defaultWebOptions, opts...)...)
+	h := s.metrics.websocket(grpcweb.WrapServer(s.server, append(defaultWebOptions, opts...)...))
 	for _, v := range grpcweb.ListGRPCResources(s.server) {
 		if s.opts.grpcWebPrefix != "" {
 			s.opts.mux.Handle(s.opts.grpcWebPrefix+v, http.StripPrefix(s.opts.grpcWebPrefix, h))
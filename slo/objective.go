@@ -0,0 +1,79 @@
+package slo
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Objective is the service level objective of a method
+type Objective struct {
+	// Name identifies the objective in the metrics, defaults to the method
+	Name string
+	// Method is the full method name, e.g. /pkg.Service/Method, or a service prefix, e.g. /pkg.Service/,
+	// to apply the objective to all the service methods
+	Method string
+	// Availability is the target ratio of successful requests, e.g. 0.999, zero disables the availability objective
+	Availability float64
+	// Latency is the threshold under which a request is good, zero disables the latency objective
+	Latency time.Duration
+	// LatencyTarget is the target ratio of requests faster than Latency, e.g. 0.99
+	LatencyTarget float64
+}
+
+func (o Objective) name() string {
+	if o.Name != "" {
+		return o.Name
+	}
+	return o.Method
+}
+
+func (o Objective) matches(method string) bool {
+	if strings.HasSuffix(o.Method, "/") {
+		return strings.HasPrefix(method, o.Method)
+	}
+	return o.Method == method
+}
+
+func (o Objective) validate() error {
+	if o.Method == "" {
+		return fmt.Errorf("slo: %s: method is required", o.name())
+	}
+	if o.Availability < 0 || o.Availability >= 1 {
+		return fmt.Errorf("slo: %s: availability must be in [0, 1)", o.name())
+	}
+	if o.Latency < 0 || o.LatencyTarget < 0 || o.LatencyTarget >= 1 || (o.Latency > 0) != (o.LatencyTarget > 0) {
+		return fmt.Errorf("slo: %s: latency and latency target must be both set, the target in (0, 1)", o.name())
+	}
+	return nil
+}
+
+// BurnRateWindow is a multi-window burn-rate alert: it fires when the error budget is consumed
+// Factor times faster than allowed over both the Long and the Short windows
+type BurnRateWindow struct {
+	Long     time.Duration
+	Short    time.Duration
+	Factor   float64
+	Severity string
+}
+
+// DefaultWindows are the multi-window burn-rate alerts recommended for a 30 days objective:
+// 2% of the budget consumed in 1 hour or 5% in 6 hours pages, 10% in 3 days opens a ticket
+var DefaultWindows = []BurnRateWindow{
+	{Long: time.Hour, Short: 5 * time.Minute, Factor: 14.4, Severity: "page"},
+	{Long: 6 * time.Hour, Short: 30 * time.Minute, Factor: 6, Severity: "page"},
+	{Long: 24 * time.Hour, Short: 2 * time.Hour, Factor: 3, Severity: "ticket"},
+	{Long: 72 * time.Hour, Short: 6 * time.Hour, Factor: 1, Severity: "ticket"},
+}
+
+// windowName formats the window label, e.g. 5m, 1h, 3d
+func windowName(d time.Duration) string {
+	switch {
+	case d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	default:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+}
@@ -0,0 +1,82 @@
+package slo
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Rule is a prometheus alerting rule
+type Rule struct {
+	Alert       string
+	Expr        string
+	For         string
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+// Rules returns the multi-window burn-rate alerting rules of the objectives, based on the grpc_slo_burn_rate metric
+func (t *Tracker) Rules() []Rule {
+	var out []Rule
+	for _, v := range t.objectives {
+		var slis []string
+		for k := range v.windows {
+			slis = append(slis, k)
+		}
+		sort.Strings(slis)
+		for _, sli := range slis {
+			for _, w := range t.windows {
+				sel := func(d string) string {
+					return fmt.Sprintf(`grpc_slo_burn_rate{slo=%q, sli=%q, window=%q}`, v.o.name(), sli, d)
+				}
+				long, short := windowName(w.Long), windowName(w.Short)
+				out = append(out, Rule{
+					Alert: "GRPCSLOBurnRate",
+					Expr:  fmt.Sprintf("%s > %g and %s > %g", sel(long), w.Factor, sel(short), w.Factor),
+					Labels: map[string]string{
+						"slo":      v.o.name(),
+						"sli":      sli,
+						"severity": w.Severity,
+						"window":   long,
+					},
+					Annotations: map[string]string{
+						"summary": fmt.Sprintf("%s %s error budget burning %gx too fast over %s", v.o.name(), sli, w.Factor, long),
+					},
+				})
+			}
+		}
+	}
+	return out
+}
+
+// WriteRules writes the rules as a prometheus rule group file
+func (t *Tracker) WriteRules(w io.Writer, group string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "groups:\n- name: %q\n  rules:\n", group)
+	for _, r := range t.Rules() {
+		fmt.Fprintf(&b, "  - alert: %q\n    expr: %q\n", r.Alert, r.Expr)
+		if r.For != "" {
+			fmt.Fprintf(&b, "    for: %q\n", r.For)
+		}
+		writeMap(&b, "labels", r.Labels)
+		writeMap(&b, "annotations", r.Annotations)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeMap(b *strings.Builder, name string, m map[string]string) {
+	if len(m) == 0 {
+		return
+	}
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fmt.Fprintf(b, "    %s:\n", name)
+	for _, k := range keys {
+		fmt.Fprintf(b, "      %s: %q\n", k, m[k])
+	}
+}
@@ -0,0 +1,182 @@
+// Package slo tracks per-method service level objectives: it exports the service level indicators
+// and the multi-window burn rates as prometheus metrics, and generates the matching alerting rules.
+// The Tracker is fed by the metrics interceptors, see metrics.ServerInterceptors.EnableSLO.
+package slo
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	sliAvailability = "availability"
+	sliLatency      = "latency"
+)
+
+// ServerError returns true if the error counts against the availability objective:
+// the client errors, e.g. InvalidArgument or NotFound, do not
+func ServerError(err error) bool {
+	switch status.Code(err) {
+	case codes.Unknown, codes.DeadlineExceeded, codes.Internal, codes.Unavailable, codes.DataLoss:
+		return true
+	}
+	return false
+}
+
+type Option func(t *Tracker)
+
+// WithWindows sets the burn-rate alert windows, defaults to DefaultWindows
+func WithWindows(w ...BurnRateWindow) Option {
+	return func(t *Tracker) {
+		t.windows = w
+	}
+}
+
+// WithServerError sets the function classifying the errors counting against the availability, defaults to ServerError
+func WithServerError(fn func(err error) bool) Option {
+	return func(t *Tracker) {
+		t.serverError = fn
+	}
+}
+
+type tracked struct {
+	o       Objective
+	windows map[string]*window
+}
+
+// Tracker tracks the objectives, it is a prometheus.Collector
+type Tracker struct {
+	objectives  []*tracked
+	windows     []BurnRateWindow
+	serverError func(err error) bool
+	now         func() time.Time
+
+	objective *prometheus.Desc
+	events    *prometheus.CounterVec
+	ratio     *prometheus.Desc
+	burnRate  *prometheus.Desc
+}
+
+// New returns a Tracker for the objectives, the first matching objective applies to a method
+func New(objectives []Objective, opts ...Option) (*Tracker, error) {
+	t := &Tracker{
+		windows:     DefaultWindows,
+		serverError: ServerError,
+		now:         time.Now,
+		objective: prometheus.NewDesc("grpc_slo_objective", "Target ratio of good events of the objective.",
+			[]string{"slo", "sli"}, nil),
+		events: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_slo_events_total",
+			Help: "Total number of events of the objective by result (good or bad).",
+		}, []string{"slo", "sli", "result"}),
+		ratio: prometheus.NewDesc("grpc_slo_sli_ratio", "Ratio of good events of the objective over the window.",
+			[]string{"slo", "sli", "window"}, nil),
+		burnRate: prometheus.NewDesc("grpc_slo_burn_rate", "Error budget burn rate of the objective over the window, 1 consumes the budget exactly over the objective period.",
+			[]string{"slo", "sli", "window"}, nil),
+	}
+	for _, v := range opts {
+		v(t)
+	}
+	for _, o := range objectives {
+		if err := o.validate(); err != nil {
+			return nil, err
+		}
+		tr := &tracked{o: o, windows: make(map[string]*window)}
+		if o.Availability > 0 {
+			tr.windows[sliAvailability] = newWindow(t.maxWindow())
+		}
+		if o.Latency > 0 {
+			tr.windows[sliLatency] = newWindow(t.maxWindow())
+		}
+		t.objectives = append(t.objectives, tr)
+	}
+	return t, nil
+}
+
+func (t *Tracker) maxWindow() time.Duration {
+	var max time.Duration
+	for _, v := range t.windows {
+		if v.Long > max {
+			max = v.Long
+		}
+	}
+	return max
+}
+
+// Objectives returns the tracked objectives
+func (t *Tracker) Objectives() []Objective {
+	var out []Objective
+	for _, v := range t.objectives {
+		out = append(out, v.o)
+	}
+	return out
+}
+
+// Observe records a completed rpc
+func (t *Tracker) Observe(method string, d time.Duration, err error) {
+	now := t.now()
+	for _, v := range t.objectives {
+		if !v.o.matches(method) {
+			continue
+		}
+		if w, ok := v.windows[sliAvailability]; ok {
+			t.record(v.o, w, sliAvailability, now, !t.serverError(err))
+		}
+		// the failed requests are only accounted for in the availability
+		if w, ok := v.windows[sliLatency]; ok && !t.serverError(err) {
+			t.record(v.o, w, sliLatency, now, d <= v.o.Latency)
+		}
+		return
+	}
+}
+
+func (t *Tracker) record(o Objective, w *window, sli string, now time.Time, good bool) {
+	w.add(now, good)
+	result := "good"
+	if !good {
+		result = "bad"
+	}
+	t.events.WithLabelValues(o.name(), sli, result).Inc()
+}
+
+func target(o Objective, sli string) float64 {
+	if sli == sliLatency {
+		return o.LatencyTarget
+	}
+	return o.Availability
+}
+
+func (t *Tracker) Describe(c chan<- *prometheus.Desc) {
+	c <- t.objective
+	c <- t.ratio
+	c <- t.burnRate
+	t.events.Describe(c)
+}
+
+func (t *Tracker) Collect(c chan<- prometheus.Metric) {
+	now := t.now()
+	durations := make(map[time.Duration]struct{})
+	for _, v := range t.windows {
+		durations[v.Long] = struct{}{}
+		durations[v.Short] = struct{}{}
+	}
+	for _, v := range t.objectives {
+		for sli, w := range v.windows {
+			name := v.o.name()
+			tg := target(v.o, sli)
+			c <- prometheus.MustNewConstMetric(t.objective, prometheus.GaugeValue, tg, name, sli)
+			for d := range durations {
+				r, ok := w.ratio(now, d)
+				if !ok {
+					continue
+				}
+				c <- prometheus.MustNewConstMetric(t.ratio, prometheus.GaugeValue, r, name, sli, windowName(d))
+				c <- prometheus.MustNewConstMetric(t.burnRate, prometheus.GaugeValue, (1-r)/(1-tg), name, sli, windowName(d))
+			}
+		}
+	}
+	t.events.Collect(c)
+}
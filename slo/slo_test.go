@@ -0,0 +1,68 @@
+package slo
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestWindow(t *testing.T) {
+	now := time.Unix(0, 0).Add(24 * time.Hour)
+	w := newWindow(time.Hour)
+	_, ok := w.ratio(now, 5*time.Minute)
+	assert.False(t, ok)
+	w.add(now.Add(-30*time.Minute), false)
+	w.add(now, true)
+	r, ok := w.ratio(now, 5*time.Minute)
+	require.True(t, ok)
+	assert.Equal(t, 1.0, r)
+	r, _ = w.ratio(now, time.Hour)
+	assert.Equal(t, 0.5, r)
+	// the bucket of an hour ago is reused
+	w.add(now.Add(time.Hour), true)
+	r, _ = w.ratio(now.Add(time.Hour), time.Hour)
+	assert.Equal(t, 1.0, r)
+}
+
+func TestTracker(t *testing.T) {
+	_, err := New([]Objective{{Method: "/svc.Service/Get", Availability: 1}})
+	assert.Error(t, err)
+	_, err = New([]Objective{{Method: "/svc.Service/Get", Latency: time.Second}})
+	assert.Error(t, err)
+
+	tr, err := New([]Objective{
+		{Name: "get", Method: "/svc.Service/Get", Availability: 0.99, Latency: 100 * time.Millisecond, LatencyTarget: 0.9},
+		{Method: "/svc.Service/", Availability: 0.9},
+	}, WithWindows(BurnRateWindow{Long: time.Hour, Short: 5 * time.Minute, Factor: 14.4, Severity: "page"}))
+	require.NoError(t, err)
+
+	tr.Observe("/svc.Service/Get", 10*time.Millisecond, nil)
+	tr.Observe("/svc.Service/Get", time.Second, nil)
+	tr.Observe("/svc.Service/Get", 10*time.Millisecond, status.Error(codes.NotFound, ""))
+	tr.Observe("/svc.Service/Get", 10*time.Millisecond, status.Error(codes.Internal, ""))
+	tr.Observe("/svc.Service/List", 10*time.Millisecond, nil)
+	tr.Observe("/other.Service/List", 10*time.Millisecond, status.Error(codes.Internal, ""))
+
+	assert.Equal(t, 3.0, testutil.ToFloat64(tr.events.WithLabelValues("get", "availability", "good")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(tr.events.WithLabelValues("get", "availability", "bad")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(tr.events.WithLabelValues("get", "latency", "good")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(tr.events.WithLabelValues("get", "latency", "bad")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(tr.events.WithLabelValues("/svc.Service/", "availability", "good")))
+
+	r, ok := tr.objectives[0].windows[sliAvailability].ratio(time.Now(), time.Hour)
+	require.True(t, ok)
+	assert.Equal(t, 0.75, r)
+
+	rules := tr.Rules()
+	require.Len(t, rules, 3)
+	assert.Equal(t, `grpc_slo_burn_rate{slo="get", sli="availability", window="1h"} > 14.4 and grpc_slo_burn_rate{slo="get", sli="availability", window="5m"} > 14.4`, rules[0].Expr)
+	var b strings.Builder
+	require.NoError(t, tr.WriteRules(&b, "slo"))
+	assert.Contains(t, b.String(), `severity: "page"`)
+}
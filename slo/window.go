@@ -0,0 +1,63 @@
+package slo
+
+import (
+	"sync"
+	"time"
+)
+
+// counts are the good and total events of a minute
+type counts struct {
+	minute int64
+	good   uint64
+	total  uint64
+}
+
+// window counts the events per minute over a sliding period
+type window struct {
+	mu      sync.Mutex
+	buckets []counts
+}
+
+func newWindow(period time.Duration) *window {
+	n := int(period / time.Minute)
+	if n < 1 {
+		n = 1
+	}
+	return &window{buckets: make([]counts, n)}
+}
+
+func (w *window) add(now time.Time, good bool) {
+	m := now.Unix() / 60
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	b := &w.buckets[m%int64(len(w.buckets))]
+	if b.minute != m {
+		*b = counts{minute: m}
+	}
+	b.total++
+	if good {
+		b.good++
+	}
+}
+
+// ratio returns the good events ratio over the last d, false if there was no event
+func (w *window) ratio(now time.Time, d time.Duration) (float64, bool) {
+	m := now.Unix() / 60
+	n := int64(d / time.Minute)
+	if n < 1 {
+		n = 1
+	}
+	var good, total uint64
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, b := range w.buckets {
+		if b.minute > m-n && b.minute <= m {
+			good += b.good
+			total += b.total
+		}
+	}
+	if total == 0 {
+		return 0, false
+	}
+	return float64(good) / float64(total), true
+}
@@ -0,0 +1,203 @@
+// Package stats exposes the grpc server connections and rpcs lifecycle as events
+package stats
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/stats"
+)
+
+// EventType is the kind of Event
+type EventType int
+
+const (
+	ConnOpen EventType = iota
+	ConnClose
+	StreamBegin
+	StreamEnd
+	MessageIn
+	MessageOut
+)
+
+func (t EventType) String() string {
+	switch t {
+	case ConnOpen:
+		return "ConnOpen"
+	case ConnClose:
+		return "ConnClose"
+	case StreamBegin:
+		return "StreamBegin"
+	case StreamEnd:
+		return "StreamEnd"
+	case MessageIn:
+		return "MessageIn"
+	case MessageOut:
+		return "MessageOut"
+	default:
+		return "Unknown"
+	}
+}
+
+// Conn describes a transport connection
+type Conn struct {
+	// ID is unique for the Handler lifetime
+	ID         uint64
+	RemoteAddr net.Addr
+	LocalAddr  net.Addr
+	Opened     time.Time
+}
+
+// Event is a connection or rpc lifecycle event
+type Event struct {
+	Type EventType
+	Time time.Time
+	// Conn is the connection the event happened on, it may be nil for in-process calls
+	Conn *Conn
+	// Method is the full rpc method name, empty for connection events
+	Method string
+	// Client is true for client side rpcs
+	Client bool
+	// Size is the message wire length for MessageIn and MessageOut events
+	Size int
+	// Duration is the rpc duration for StreamEnd events and the connection duration for ConnClose events
+	Duration time.Duration
+	// Error is the rpc error for StreamEnd events
+	Error error
+}
+
+// Listener is called synchronously for every event, it must not block
+type Listener func(ctx context.Context, e Event)
+
+// Handler is a grpc stats.Handler dispatching the events to its listeners
+type Handler interface {
+	stats.Handler
+	// Subscribe registers l and returns a function removing it
+	Subscribe(l Listener) (unsubscribe func())
+}
+
+func NewHandler() Handler {
+	h := &handler{}
+	h.listeners.Store([]*listener(nil))
+	return h
+}
+
+type connKey struct{}
+
+type rpcKey struct{}
+
+type rpc struct {
+	method string
+	begin  time.Time
+}
+
+type listener struct {
+	fn Listener
+}
+
+type handler struct {
+	mu        sync.Mutex
+	listeners atomic.Value
+	ids       uint64
+}
+
+func (h *handler) Subscribe(l Listener) func() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ll := &listener{fn: l}
+	ls := h.load()
+	h.listeners.Store(append(ls[:len(ls):len(ls)], ll))
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			ls := h.load()
+			out := make([]*listener, 0, len(ls))
+			for _, v := range ls {
+				if v != ll {
+					out = append(out, v)
+				}
+			}
+			h.listeners.Store(out)
+		})
+	}
+}
+
+func (h *handler) load() []*listener {
+	return h.listeners.Load().([]*listener)
+}
+
+func (h *handler) emit(ctx context.Context, e Event) {
+	for _, v := range h.load() {
+		v.fn(ctx, e)
+	}
+}
+
+func (h *handler) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	return context.WithValue(ctx, connKey{}, &Conn{
+		ID:         atomic.AddUint64(&h.ids, 1),
+		RemoteAddr: info.RemoteAddr,
+		LocalAddr:  info.LocalAddr,
+	})
+}
+
+func (h *handler) HandleConn(ctx context.Context, s stats.ConnStats) {
+	if len(h.load()) == 0 {
+		return
+	}
+	c, _ := ctx.Value(connKey{}).(*Conn)
+	now := time.Now()
+	switch s.(type) {
+	case *stats.ConnBegin:
+		if c != nil {
+			c.Opened = now
+		}
+		h.emit(ctx, Event{Type: ConnOpen, Time: now, Conn: c})
+	case *stats.ConnEnd:
+		e := Event{Type: ConnClose, Time: now, Conn: c}
+		if c != nil && !c.Opened.IsZero() {
+			e.Duration = now.Sub(c.Opened)
+		}
+		h.emit(ctx, e)
+	}
+}
+
+func (h *handler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	return context.WithValue(ctx, rpcKey{}, &rpc{method: info.FullMethodName})
+}
+
+func (h *handler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	if len(h.load()) == 0 {
+		return
+	}
+	c, _ := ctx.Value(connKey{}).(*Conn)
+	r, _ := ctx.Value(rpcKey{}).(*rpc)
+	e := Event{Time: time.Now(), Conn: c, Client: s.IsClient()}
+	if r != nil {
+		e.Method = r.method
+	}
+	switch v := s.(type) {
+	case *stats.Begin:
+		if r != nil {
+			r.begin = v.BeginTime
+		}
+		e.Type = StreamBegin
+	case *stats.InPayload:
+		e.Type = MessageIn
+		e.Size = v.WireLength
+	case *stats.OutPayload:
+		e.Type = MessageOut
+		e.Size = v.WireLength
+	case *stats.End:
+		e.Type = StreamEnd
+		e.Error = v.Error
+		e.Duration = v.EndTime.Sub(v.BeginTime)
+	default:
+		return
+	}
+	h.emit(ctx, e)
+}
@@ -0,0 +1,50 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/stats"
+)
+
+func TestHandler(t *testing.T) {
+	h := NewHandler()
+	var events []Event
+	unsubscribe := h.Subscribe(func(ctx context.Context, e Event) {
+		events = append(events, e)
+	})
+
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 4242}
+	ctx := h.TagConn(context.Background(), &stats.ConnTagInfo{RemoteAddr: addr})
+	h.HandleConn(ctx, &stats.ConnBegin{})
+	ctx = h.TagRPC(ctx, &stats.RPCTagInfo{FullMethodName: "/svc/Method"})
+	begin := time.Now()
+	h.HandleRPC(ctx, &stats.Begin{BeginTime: begin})
+	h.HandleRPC(ctx, &stats.InPayload{WireLength: 10})
+	h.HandleRPC(ctx, &stats.OutPayload{WireLength: 20})
+	h.HandleRPC(ctx, &stats.End{BeginTime: begin, EndTime: begin.Add(time.Second), Error: errors.New("boom")})
+	h.HandleConn(ctx, &stats.ConnEnd{})
+
+	require.Len(t, events, 6)
+	types := make([]EventType, 0, len(events))
+	for _, v := range events {
+		types = append(types, v.Type)
+		require.NotNil(t, v.Conn)
+		assert.Equal(t, addr, v.Conn.RemoteAddr)
+	}
+	assert.Equal(t, []EventType{ConnOpen, StreamBegin, MessageIn, MessageOut, StreamEnd, ConnClose}, types)
+	assert.Equal(t, "/svc/Method", events[1].Method)
+	assert.Equal(t, 10, events[2].Size)
+	assert.Equal(t, 20, events[3].Size)
+	assert.Equal(t, time.Second, events[4].Duration)
+	assert.EqualError(t, events[4].Error, "boom")
+
+	unsubscribe()
+	h.HandleConn(ctx, &stats.ConnBegin{})
+	assert.Len(t, events, 6)
+}
@@ -0,0 +1,56 @@
+package stream
+
+import (
+	"context"
+	"time"
+)
+
+// Batch reads in and calls fn with batches of at most size messages, a partial batch is flushed
+// when interval elapses since its first message. It returns when in is closed, after flushing
+// the last batch, when the context is done or when fn fails.
+func Batch(ctx context.Context, in <-chan interface{}, size int, interval time.Duration, fn func(batch []interface{}) error) error {
+	if size <= 0 {
+		size = 1
+	}
+	batch := make([]interface{}, 0, size)
+	var timer *time.Timer
+	var tick <-chan time.Time
+	flush := func() error {
+		if timer != nil {
+			timer.Stop()
+			timer, tick = nil, nil
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		err := fn(batch)
+		batch = make([]interface{}, 0, size)
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case v, ok := <-in:
+			if !ok {
+				return flush()
+			}
+			batch = append(batch, v)
+			if len(batch) >= size {
+				if err := flush(); err != nil {
+					return err
+				}
+				continue
+			}
+			if timer == nil && interval > 0 {
+				timer = time.NewTimer(interval)
+				tick = timer.C
+			}
+		case <-tick:
+			timer, tick = nil, nil
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package stream
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrSlowConsumer is the error of the subscriptions closed because their buffer was full
+	ErrSlowConsumer = errors.New("stream: slow consumer")
+	// ErrClosed is the error of the subscriptions closed by the Fanout
+	ErrClosed = errors.New("stream: closed")
+)
+
+// Fanout publishes messages to multiple subscribers, each with a bounded buffer
+type Fanout struct {
+	opts options
+
+	mu     sync.RWMutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+func NewFanout(opts ...Option) *Fanout {
+	return &Fanout{opts: newOptions(opts...), subs: make(map[*Subscription]struct{})}
+}
+
+// Subscription receives the published messages on C
+type Subscription struct {
+	f    *Fanout
+	c    chan interface{}
+	mu   sync.Mutex
+	err  error
+	done chan struct{}
+	// sending tracks the blocked publishers so that the channel is not closed while they send
+	sending sync.WaitGroup
+}
+
+// C returns the messages channel, it is closed when the subscription ends
+func (s *Subscription) C() <-chan interface{} {
+	return s.c
+}
+
+// Err returns why the subscription ended: nil if closed by the subscriber, ErrSlowConsumer or ErrClosed
+func (s *Subscription) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close ends the subscription
+func (s *Subscription) Close() {
+	s.f.remove(s, nil)
+}
+
+// Subscribe returns a new subscription, or a closed one if the Fanout is closed
+func (f *Fanout) Subscribe() *Subscription {
+	s := &Subscription{f: f, c: make(chan interface{}, f.opts.buffer), done: make(chan struct{})}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		s.err = ErrClosed
+		close(s.c)
+		close(s.done)
+		return s
+	}
+	f.subs[s] = struct{}{}
+	f.metrics(func(m *Metrics) { m.subscribers.WithLabelValues(f.opts.name).Inc() })
+	return s
+}
+
+// Publish sends v to all the subscribers according to the overflow policy
+func (f *Fanout) Publish(v interface{}) {
+	f.mu.RLock()
+	subs := make([]*Subscription, 0, len(f.subs))
+	for s := range f.subs {
+		subs = append(subs, s)
+	}
+	f.mu.RUnlock()
+	for _, s := range subs {
+		f.publish(s, v)
+	}
+}
+
+func (f *Fanout) publish(s *Subscription, v interface{}) {
+	// the subscription channel is only closed under the subscription lock
+	s.mu.Lock()
+	if s.err != nil || isDone(s.done) {
+		s.mu.Unlock()
+		return
+	}
+	switch f.opts.overflow {
+	case Block:
+		s.sending.Add(1)
+		s.mu.Unlock()
+		defer s.sending.Done()
+		select {
+		case s.c <- v:
+		case <-s.done:
+		}
+		return
+	case DropNewest:
+		select {
+		case s.c <- v:
+		default:
+			f.metrics(func(m *Metrics) { m.dropped.WithLabelValues(f.opts.name).Inc() })
+		}
+	case Disconnect:
+		select {
+		case s.c <- v:
+		default:
+			s.mu.Unlock()
+			f.remove(s, ErrSlowConsumer)
+			return
+		}
+	default:
+		for {
+			select {
+			case s.c <- v:
+				s.mu.Unlock()
+				return
+			default:
+			}
+			select {
+			case <-s.c:
+				f.metrics(func(m *Metrics) { m.dropped.WithLabelValues(f.opts.name).Inc() })
+			default:
+			}
+		}
+	}
+	s.mu.Unlock()
+}
+
+func (f *Fanout) remove(s *Subscription, err error) {
+	f.mu.Lock()
+	_, ok := f.subs[s]
+	delete(f.subs, s)
+	f.mu.Unlock()
+	if !ok {
+		return
+	}
+	f.metrics(func(m *Metrics) { m.subscribers.WithLabelValues(f.opts.name).Dec() })
+	s.mu.Lock()
+	close(s.done)
+	s.mu.Unlock()
+	// wait for the blocked publishers to give up before closing the channel
+	s.sending.Wait()
+	s.mu.Lock()
+	s.err = err
+	close(s.c)
+	s.mu.Unlock()
+}
+
+// Close ends all the subscriptions with ErrClosed
+func (f *Fanout) Close() {
+	f.mu.Lock()
+	f.closed = true
+	subs := make([]*Subscription, 0, len(f.subs))
+	for s := range f.subs {
+		subs = append(subs, s)
+	}
+	f.mu.Unlock()
+	for _, s := range subs {
+		f.remove(s, ErrClosed)
+	}
+}
+
+// Len returns the number of subscribers
+func (f *Fanout) Len() int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return len(f.subs)
+}
+
+func (f *Fanout) metrics(fn func(m *Metrics)) {
+	if f.opts.metrics != nil {
+		fn(f.opts.metrics)
+	}
+}
+
+func isDone(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
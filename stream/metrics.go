@@ -0,0 +1,59 @@
+package stream
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Metrics are the streams flow control metrics, labelled by stream name
+type Metrics struct {
+	sendDuration *prometheus.HistogramVec
+	sendTimeouts *prometheus.CounterVec
+	dropped      *prometheus.CounterVec
+	subscribers  *prometheus.GaugeVec
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		sendDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "grpc_stream_send_duration_seconds",
+			Help: "Time spent waiting for the stream messages to be sent, it grows when the peers apply backpressure.",
+		}, []string{"stream"}),
+		sendTimeouts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_stream_send_timeouts_total",
+			Help: "Total number of stream messages not sent in time.",
+		}, []string{"stream"}),
+		dropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_stream_dropped_messages_total",
+			Help: "Total number of messages dropped because a subscriber buffer was full.",
+		}, []string{"stream"}),
+		subscribers: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "grpc_stream_subscribers",
+			Help: "Number of fan-out subscribers.",
+		}, []string{"stream"}),
+	}
+}
+
+func (m *Metrics) send(name string, d time.Duration, err error) {
+	m.sendDuration.WithLabelValues(name).Observe(d.Seconds())
+	if status.Code(err) == codes.DeadlineExceeded {
+		m.sendTimeouts.WithLabelValues(name).Inc()
+	}
+}
+
+func (m *Metrics) Describe(descs chan<- *prometheus.Desc) {
+	m.sendDuration.Describe(descs)
+	m.sendTimeouts.Describe(descs)
+	m.dropped.Describe(descs)
+	m.subscribers.Describe(descs)
+}
+
+func (m *Metrics) Collect(c chan<- prometheus.Metric) {
+	m.sendDuration.Collect(c)
+	m.sendTimeouts.Collect(c)
+	m.dropped.Collect(c)
+	m.subscribers.Collect(c)
+}
@@ -0,0 +1,72 @@
+package stream
+
+import (
+	"time"
+)
+
+// Overflow is the behaviour of a fan-out subscription when its buffer is full
+type Overflow int
+
+const (
+	// DropOldest discards the oldest buffered message
+	DropOldest Overflow = iota
+	// DropNewest discards the published message
+	DropNewest
+	// Disconnect closes the subscription with ErrSlowConsumer
+	Disconnect
+	// Block waits for the subscriber, slowing down the publisher
+	Block
+)
+
+type Option func(o *options)
+
+// WithName sets the stream name used as metrics label
+func WithName(name string) Option {
+	return func(o *options) {
+		o.name = name
+	}
+}
+
+// WithMetrics records the flow control metrics
+func WithMetrics(m *Metrics) Option {
+	return func(o *options) {
+		o.metrics = m
+	}
+}
+
+// WithTimeout sets the maximum time a Sender waits for a message to be sent
+func WithTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.timeout = d
+	}
+}
+
+// WithBuffer sets the size of the fan-out subscriptions buffer, defaults to 64
+func WithBuffer(n int) Option {
+	return func(o *options) {
+		o.buffer = n
+	}
+}
+
+// WithOverflow sets the behaviour of the fan-out subscriptions when their buffer is full, defaults to DropOldest
+func WithOverflow(p Overflow) Option {
+	return func(o *options) {
+		o.overflow = p
+	}
+}
+
+type options struct {
+	name     string
+	metrics  *Metrics
+	timeout  time.Duration
+	buffer   int
+	overflow Overflow
+}
+
+func newOptions(opts ...Option) options {
+	o := options{name: "default", buffer: 64}
+	for _, v := range opts {
+		v(&o)
+	}
+	return o
+}
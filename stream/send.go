@@ -0,0 +1,58 @@
+// Package stream provides helpers for streaming handlers: sends bounded by a context or a timeout,
+// bounded fan-out to multiple subscribers, batching and flow control metrics.
+package stream
+
+import (
+	"context"
+	"time"
+
+	"go.linka.cloud/grpc/errors"
+)
+
+// MsgSender is implemented by grpc.ServerStream and grpc.ClientStream
+type MsgSender interface {
+	SendMsg(m interface{}) error
+}
+
+// Send sends m on s, giving up when the context is done or the timeout expires (no timeout if zero).
+// grpc sends block while the peer flow control window is full: on timeout the stream must be aborted,
+// e.g. by returning the error from the handler, as the message may still be sent later.
+func Send(ctx context.Context, s MsgSender, m interface{}, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.SendMsg(m)
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return errors.DeadlineExceededf("stream: send timeout")
+		}
+		return errors.Canceled(ctx.Err())
+	}
+}
+
+// Sender sends messages with the configured timeout and records the flow control metrics
+type Sender struct {
+	s    MsgSender
+	opts options
+}
+
+func NewSender(s MsgSender, opts ...Option) *Sender {
+	return &Sender{s: s, opts: newOptions(opts...)}
+}
+
+func (s *Sender) Send(ctx context.Context, m interface{}) error {
+	start := time.Now()
+	err := Send(ctx, s.s, m, s.opts.timeout)
+	if s.opts.metrics != nil {
+		s.opts.metrics.send(s.opts.name, time.Since(start), err)
+	}
+	return err
+}
@@ -0,0 +1,102 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type blockingSender struct {
+	release chan struct{}
+}
+
+func (s *blockingSender) SendMsg(m interface{}) error {
+	<-s.release
+	return nil
+}
+
+func TestSendTimeout(t *testing.T) {
+	s := &blockingSender{release: make(chan struct{})}
+	defer close(s.release)
+	err := Send(context.Background(), s, "msg", 10*time.Millisecond)
+	assert.Error(t, err)
+}
+
+func TestFanout(t *testing.T) {
+	f := NewFanout(WithBuffer(2))
+	a := f.Subscribe()
+	b := f.Subscribe()
+	assert.Equal(t, 2, f.Len())
+	for i := 0; i < 3; i++ {
+		f.Publish(i)
+	}
+	// drop oldest
+	assert.Equal(t, 1, <-a.C())
+	assert.Equal(t, 2, <-a.C())
+	b.Close()
+	// the buffered messages are still delivered
+	var got []interface{}
+	for v := range b.C() {
+		got = append(got, v)
+	}
+	assert.Equal(t, []interface{}{1, 2}, got)
+	assert.NoError(t, b.Err())
+	f.Close()
+	_, ok := <-a.C()
+	assert.False(t, ok)
+	assert.True(t, errors.Is(a.Err(), ErrClosed))
+}
+
+func TestFanoutDisconnect(t *testing.T) {
+	f := NewFanout(WithBuffer(1), WithOverflow(Disconnect))
+	s := f.Subscribe()
+	f.Publish(1)
+	f.Publish(2)
+	assert.Equal(t, 1, <-s.C())
+	_, ok := <-s.C()
+	assert.False(t, ok)
+	assert.True(t, errors.Is(s.Err(), ErrSlowConsumer))
+	assert.Equal(t, 0, f.Len())
+}
+
+func TestFanoutBlock(t *testing.T) {
+	f := NewFanout(WithBuffer(1), WithOverflow(Block))
+	s := f.Subscribe()
+	f.Publish(1)
+	done := make(chan struct{})
+	go func() {
+		f.Publish(2)
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("publish did not block")
+	case <-time.After(10 * time.Millisecond):
+	}
+	s.Close()
+	<-done
+}
+
+func TestBatch(t *testing.T) {
+	in := make(chan interface{})
+	var batches [][]interface{}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- Batch(context.Background(), in, 2, 10*time.Millisecond, func(b []interface{}) error {
+			batches = append(batches, b)
+			return nil
+		})
+	}()
+	in <- 1
+	in <- 2
+	in <- 3
+	time.Sleep(30 * time.Millisecond)
+	in <- 4
+	close(in)
+	require.NoError(t, <-errs)
+	assert.Equal(t, [][]interface{}{{1, 2}, {3}, {4}}, batches)
+}
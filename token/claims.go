@@ -0,0 +1,126 @@
+package token
+
+import (
+	"encoding/json"
+	"time"
+)
+
+var reserved = map[string]bool{"iss": true, "sub": true, "aud": true, "exp": true, "nbf": true, "iat": true, "jti": true}
+
+// Claims are the token registered claims, see RFC 7519, and the custom ones
+type Claims struct {
+	Issuer    string
+	Subject   string
+	Audience  []string
+	ExpiresAt time.Time
+	NotBefore time.Time
+	IssuedAt  time.Time
+	ID        string
+	// Custom are the private claims, they must not use the registered claims names
+	Custom map[string]interface{}
+}
+
+type registered struct {
+	Issuer    string      `json:"iss,omitempty"`
+	Subject   string      `json:"sub,omitempty"`
+	Audience  interface{} `json:"aud,omitempty"`
+	ExpiresAt int64       `json:"exp,omitempty"`
+	NotBefore int64       `json:"nbf,omitempty"`
+	IssuedAt  int64       `json:"iat,omitempty"`
+	ID        string      `json:"jti,omitempty"`
+}
+
+func unix(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func fromUnix(v int64) time.Time {
+	if v == 0 {
+		return time.Time{}
+	}
+	return time.Unix(v, 0)
+}
+
+func (c Claims) MarshalJSON() ([]byte, error) {
+	r := registered{
+		Issuer:    c.Issuer,
+		Subject:   c.Subject,
+		ExpiresAt: unix(c.ExpiresAt),
+		NotBefore: unix(c.NotBefore),
+		IssuedAt:  unix(c.IssuedAt),
+		ID:        c.ID,
+	}
+	switch len(c.Audience) {
+	case 0:
+	case 1:
+		r.Audience = c.Audience[0]
+	default:
+		r.Audience = c.Audience
+	}
+	b, err := json.Marshal(r)
+	if err != nil || len(c.Custom) == 0 {
+		return b, err
+	}
+	m := make(map[string]interface{}, len(c.Custom)+7)
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	for k, v := range c.Custom {
+		if !reserved[k] {
+			m[k] = v
+		}
+	}
+	return json.Marshal(m)
+}
+
+func (c *Claims) UnmarshalJSON(b []byte) error {
+	var r registered
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	*c = Claims{
+		Issuer:    r.Issuer,
+		Subject:   r.Subject,
+		ExpiresAt: fromUnix(r.ExpiresAt),
+		NotBefore: fromUnix(r.NotBefore),
+		IssuedAt:  fromUnix(r.IssuedAt),
+		ID:        r.ID,
+	}
+	switch a := r.Audience.(type) {
+	case string:
+		c.Audience = []string{a}
+	case []interface{}:
+		for _, v := range a {
+			if s, ok := v.(string); ok {
+				c.Audience = append(c.Audience, s)
+			}
+		}
+	}
+	for k, v := range m {
+		if reserved[k] {
+			continue
+		}
+		if c.Custom == nil {
+			c.Custom = make(map[string]interface{})
+		}
+		c.Custom[k] = v
+	}
+	return nil
+}
+
+// HasAudience returns true if aud is one of the token audiences
+func (c *Claims) HasAudience(aud string) bool {
+	for _, v := range c.Audience {
+		if v == aud {
+			return true
+		}
+	}
+	return false
+}
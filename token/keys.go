@@ -0,0 +1,92 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+// Algorithm is a JWS signature algorithm
+type Algorithm string
+
+const (
+	HS256 Algorithm = "HS256"
+	EdDSA Algorithm = "EdDSA"
+)
+
+const minHMACKeySize = 32
+
+// Key is a signing or verification key identified by its kid
+type Key struct {
+	ID   string
+	alg  Algorithm
+	hmac []byte
+	priv ed25519.PrivateKey
+	pub  ed25519.PublicKey
+}
+
+// NewHMACKey returns a HS256 key, the secret must be at least 32 bytes long
+func NewHMACKey(id string, secret []byte) (*Key, error) {
+	if len(secret) < minHMACKeySize {
+		return nil, fmt.Errorf("token: hmac key must be at least %d bytes", minHMACKeySize)
+	}
+	return &Key{ID: id, alg: HS256, hmac: append([]byte(nil), secret...)}, nil
+}
+
+// NewEd25519Key returns an EdDSA signing key
+func NewEd25519Key(id string, priv ed25519.PrivateKey) (*Key, error) {
+	if len(priv) != ed25519.PrivateKeySize {
+		return nil, errors.New("token: invalid ed25519 private key")
+	}
+	return &Key{ID: id, alg: EdDSA, priv: priv, pub: priv.Public().(ed25519.PublicKey)}, nil
+}
+
+// NewEd25519PublicKey returns an EdDSA key which can only verify tokens, e.g. in the services consuming the tokens
+func NewEd25519PublicKey(id string, pub ed25519.PublicKey) (*Key, error) {
+	if len(pub) != ed25519.PublicKeySize {
+		return nil, errors.New("token: invalid ed25519 public key")
+	}
+	return &Key{ID: id, alg: EdDSA, pub: pub}, nil
+}
+
+// Algorithm returns the key signature algorithm
+func (k *Key) Algorithm() Algorithm {
+	return k.alg
+}
+
+// Public returns the verification only key, it is the key itself for HMAC keys
+func (k *Key) Public() *Key {
+	if k.alg != EdDSA {
+		return k
+	}
+	return &Key{ID: k.ID, alg: k.alg, pub: k.pub}
+}
+
+func (k *Key) canSign() bool {
+	return k.hmac != nil || k.priv != nil
+}
+
+func (k *Key) sign(b []byte) ([]byte, error) {
+	switch {
+	case k.hmac != nil:
+		h := hmac.New(sha256.New, k.hmac)
+		h.Write(b)
+		return h.Sum(nil), nil
+	case k.priv != nil:
+		return ed25519.Sign(k.priv, b), nil
+	}
+	return nil, fmt.Errorf("token: key %q cannot sign", k.ID)
+}
+
+func (k *Key) verify(b, sig []byte) bool {
+	switch k.alg {
+	case HS256:
+		s, _ := k.sign(b)
+		return hmac.Equal(s, sig)
+	case EdDSA:
+		return ed25519.Verify(k.pub, b, sig)
+	}
+	return false
+}
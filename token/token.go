@@ -0,0 +1,231 @@
+// Package token issues and verifies signed JSON Web Tokens (HS256 or EdDSA) with key rotation:
+// the tokens are signed with the current key and verified with any of the known keys, selected by their kid header.
+package token
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalid is returned when the token is malformed or its signature does not match
+	ErrInvalid = errors.New("token: invalid token")
+	// ErrUnknownKey is returned when the token kid is not one of the known keys
+	ErrUnknownKey = errors.New("token: unknown key")
+	// ErrExpired is returned when the token is expired
+	ErrExpired = errors.New("token: expired")
+	// ErrNotYetValid is returned when the token is used before its not before date
+	ErrNotYetValid = errors.New("token: not yet valid")
+	// ErrClaims is returned when the issuer or audience do not match
+	ErrClaims = errors.New("token: invalid claims")
+)
+
+type Option func(o *options)
+
+// WithIssuer sets the issued tokens iss claim and the issuer the verified tokens must have
+func WithIssuer(iss string) Option {
+	return func(o *options) {
+		o.issuer = iss
+	}
+}
+
+// WithAudience sets the issued tokens default audience, the verified tokens must have one of them
+func WithAudience(aud ...string) Option {
+	return func(o *options) {
+		o.audience = aud
+	}
+}
+
+// WithTTL sets the issued tokens lifetime when their claims have no expiration, defaults to 1 hour
+func WithTTL(d time.Duration) Option {
+	return func(o *options) {
+		o.ttl = d
+	}
+}
+
+// WithLeeway sets the tolerated clock skew when checking the exp and nbf claims, defaults to 1 minute
+func WithLeeway(d time.Duration) Option {
+	return func(o *options) {
+		o.leeway = d
+	}
+}
+
+type options struct {
+	issuer   string
+	audience []string
+	ttl      time.Duration
+	leeway   time.Duration
+}
+
+// Manager issues and verifies the tokens
+type Manager struct {
+	o       options
+	mu      sync.RWMutex
+	current *Key
+	keys    map[string]*Key
+	now     func() time.Time
+}
+
+// New returns a Manager signing with current, the others keys are only used to verify the tokens.
+// current may be a verification only key, e.g. an ed25519 public key, then Issue fails.
+func New(current *Key, others []*Key, opts ...Option) *Manager {
+	m := &Manager{
+		o:       options{ttl: time.Hour, leeway: time.Minute},
+		current: current,
+		keys:    map[string]*Key{current.ID: current},
+		now:     time.Now,
+	}
+	for _, v := range others {
+		m.keys[v.ID] = v
+	}
+	for _, v := range opts {
+		v(&m.o)
+	}
+	return m
+}
+
+// Rotate signs the new tokens with k, the previous keys are still used to verify the tokens
+// until they are removed, which should not happen before the tokens they signed have expired
+func (m *Manager) Rotate(k *Key) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.current = k
+	m.keys[k.ID] = k
+}
+
+// Remove stops accepting the tokens signed by the key id, the current key cannot be removed
+func (m *Manager) Remove(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.current.ID != id {
+		delete(m.keys, id)
+	}
+}
+
+// Keys returns the verification keys, e.g. to publish them to the tokens consumers
+func (m *Manager) Keys() []*Key {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var out []*Key
+	for _, v := range m.keys {
+		out = append(out, v.Public())
+	}
+	return out
+}
+
+type header struct {
+	Alg Algorithm `json:"alg"`
+	Typ string    `json:"typ,omitempty"`
+	Kid string    `json:"kid,omitempty"`
+}
+
+var enc = base64.RawURLEncoding
+
+// Issue signs the claims with the current key. The issuer, audience, expiration, issued at and id
+// claims are set if they are empty.
+func (m *Manager) Issue(c Claims) (string, error) {
+	m.mu.RLock()
+	k := m.current
+	m.mu.RUnlock()
+	if !k.canSign() {
+		return "", fmt.Errorf("token: key %q cannot sign", k.ID)
+	}
+	now := m.now()
+	if c.Issuer == "" {
+		c.Issuer = m.o.issuer
+	}
+	if len(c.Audience) == 0 {
+		c.Audience = m.o.audience
+	}
+	if c.IssuedAt.IsZero() {
+		c.IssuedAt = now
+	}
+	if c.ExpiresAt.IsZero() && m.o.ttl > 0 {
+		c.ExpiresAt = now.Add(m.o.ttl)
+	}
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
+	h, err := json.Marshal(header{Alg: k.alg, Typ: "JWT", Kid: k.ID})
+	if err != nil {
+		return "", err
+	}
+	p, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	s := enc.EncodeToString(h) + "." + enc.EncodeToString(p)
+	sig, err := k.sign([]byte(s))
+	if err != nil {
+		return "", err
+	}
+	return s + "." + enc.EncodeToString(sig), nil
+}
+
+// Verify checks the token signature and claims and returns its claims
+func (m *Manager) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalid
+	}
+	hb, err := enc.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	var h header
+	if err := json.Unmarshal(hb, &h); err != nil {
+		return nil, ErrInvalid
+	}
+	m.mu.RLock()
+	k, ok := m.keys[h.Kid]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, h.Kid)
+	}
+	// the algorithm comes from the key, never from the token, so that a token cannot choose how it is verified
+	if h.Alg != k.alg {
+		return nil, ErrInvalid
+	}
+	sig, err := enc.DecodeString(parts[2])
+	if err != nil || !k.verify([]byte(parts[0]+"."+parts[1]), sig) {
+		return nil, ErrInvalid
+	}
+	pb, err := enc.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	var c Claims
+	if err := json.Unmarshal(pb, &c); err != nil {
+		return nil, ErrInvalid
+	}
+	now := m.now()
+	if !c.ExpiresAt.IsZero() && !now.Before(c.ExpiresAt.Add(m.o.leeway)) {
+		return nil, ErrExpired
+	}
+	if !c.NotBefore.IsZero() && now.Add(m.o.leeway).Before(c.NotBefore) {
+		return nil, ErrNotYetValid
+	}
+	if m.o.issuer != "" && c.Issuer != m.o.issuer {
+		return nil, fmt.Errorf("%w: issuer %q", ErrClaims, c.Issuer)
+	}
+	if len(m.o.audience) != 0 {
+		ok := false
+		for _, v := range m.o.audience {
+			if c.HasAudience(v) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w: audience %v", ErrClaims, c.Audience)
+		}
+	}
+	return &c, nil
+}
@@ -0,0 +1,101 @@
+package token
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHMAC(t *testing.T) {
+	k1, err := NewHMACKey("1", bytes.Repeat([]byte{1}, 32))
+	require.NoError(t, err)
+	_, err = NewHMACKey("short", []byte("secret"))
+	assert.Error(t, err)
+
+	m := New(k1, nil, WithIssuer("test"), WithAudience("api"))
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	tk, err := m.Issue(Claims{Subject: "alice", Custom: map[string]interface{}{"role": "admin", "exp": "ignored"}})
+	require.NoError(t, err)
+	c, err := m.Verify(tk)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", c.Subject)
+	assert.Equal(t, "test", c.Issuer)
+	assert.Equal(t, []string{"api"}, c.Audience)
+	assert.Equal(t, "admin", c.Custom["role"])
+	assert.Equal(t, now.Add(time.Hour).Unix(), c.ExpiresAt.Unix())
+	assert.NotEmpty(t, c.ID)
+
+	// tampered payload
+	parts := strings.Split(tk, ".")
+	forged, err := New(k1, nil).Issue(Claims{Subject: "bob"})
+	require.NoError(t, err)
+	_, err = m.Verify(parts[0] + "." + strings.Split(forged, ".")[1] + "." + parts[2])
+	assert.True(t, errors.Is(err, ErrInvalid))
+	_, err = m.Verify("a.b")
+	assert.True(t, errors.Is(err, ErrInvalid))
+
+	// claims
+	_, err = m.Verify(forged)
+	assert.True(t, errors.Is(err, ErrClaims))
+	future, err := m.Issue(Claims{NotBefore: now.Add(time.Hour)})
+	require.NoError(t, err)
+	_, err = m.Verify(future)
+	assert.True(t, errors.Is(err, ErrNotYetValid))
+	now = now.Add(2 * time.Hour)
+	_, err = m.Verify(tk)
+	assert.True(t, errors.Is(err, ErrExpired))
+}
+
+func TestRotation(t *testing.T) {
+	_, priv1, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, priv2, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	k1, err := NewEd25519Key("1", priv1)
+	require.NoError(t, err)
+	k2, err := NewEd25519Key("2", priv2)
+	require.NoError(t, err)
+
+	m := New(k1, nil)
+	t1, err := m.Issue(Claims{Subject: "alice"})
+	require.NoError(t, err)
+	m.Rotate(k2)
+	t2, err := m.Issue(Claims{Subject: "alice"})
+	require.NoError(t, err)
+	assert.Len(t, m.Keys(), 2)
+
+	// a consumer only knowing the public keys
+	v := New(m.Keys()[0], m.Keys()[1:])
+	_, err = v.Issue(Claims{})
+	assert.Error(t, err)
+	for _, tk := range []string{t1, t2} {
+		_, err = m.Verify(tk)
+		assert.NoError(t, err)
+		_, err = v.Verify(tk)
+		assert.NoError(t, err)
+	}
+
+	m.Remove("1")
+	_, err = m.Verify(t1)
+	assert.True(t, errors.Is(err, ErrUnknownKey))
+	m.Remove("2")
+	_, err = m.Verify(t2)
+	assert.NoError(t, err)
+
+	// algorithm confusion: an HMAC token using the same kid is rejected
+	h, err := NewHMACKey("2", bytes.Repeat([]byte{1}, 32))
+	require.NoError(t, err)
+	forged, err := New(h, nil).Issue(Claims{Subject: "alice"})
+	require.NoError(t, err)
+	_, err = m.Verify(forged)
+	assert.True(t, errors.Is(err, ErrInvalid))
+}
@@ -0,0 +1,29 @@
+package token
+
+import (
+	"context"
+
+	"go.linka.cloud/grpc/errors"
+	"go.linka.cloud/grpc/rpcctx"
+)
+
+type claimsCtx struct{}
+
+// FromContext returns the claims of the token which authenticated the request
+func FromContext(ctx context.Context) (*Claims, bool) {
+	c, ok := ctx.Value(claimsCtx{}).(*Claims)
+	return c, ok
+}
+
+// Validator returns an auth.TokenValidator, for auth.WithTokenValidators, verifying the bearer tokens.
+// The token subject is set as the rpcctx identity.
+func (m *Manager) Validator() func(ctx context.Context, token string) (context.Context, error) {
+	return func(ctx context.Context, token string) (context.Context, error) {
+		c, err := m.Verify(token)
+		if err != nil {
+			return ctx, errors.Unauthenticatedf("%v", err)
+		}
+		ctx = context.WithValue(ctx, claimsCtx{}, c)
+		return rpcctx.WithIdentity(ctx, c.Subject), nil
+	}
+}
@@ -50,8 +50,32 @@ func IsLocal(addr string) bool {
 	return false
 }
 
+const (
+	FamilyIPv4 = "ipv4"
+	FamilyIPv6 = "ipv6"
+	FamilyDual = "dual"
+)
+
+// Family returns the address family of the ip, ipv4 or ipv6, or an empty string if it is not an ip
+func Family(ip string) string {
+	v := net.ParseIP(ip)
+	switch {
+	case v == nil:
+		return ""
+	case v.To4() != nil:
+		return FamilyIPv4
+	default:
+		return FamilyIPv6
+	}
+}
+
 // Extract returns a real ip
 func Extract(addr string) (string, error) {
+	return ExtractFamily(addr, "")
+}
+
+// ExtractFamily returns a real ip of the given family, ipv4 or ipv6, any family is accepted if it is empty or dual
+func ExtractFamily(addr, family string) (string, error) {
 	// if addr specified then its returned
 	if len(addr) > 0 && (addr != "0.0.0.0" && addr != "[::]" && addr != "::") {
 		return addr, nil
@@ -93,6 +117,10 @@ func Extract(addr string) (string, error) {
 			continue
 		}
 
+		if (family == FamilyIPv4 || family == FamilyIPv6) && Family(ip.String()) != family {
+			continue
+		}
+
 		if !isPrivateIP(ip.String()) {
 			publicIP = ip.String()
 			continue
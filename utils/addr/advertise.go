@@ -0,0 +1,54 @@
+package addr
+
+import (
+	"net"
+	"os"
+)
+
+// DefaultAdvertiseEnv are the environment variables looked up by FromEnv when no keys are given.
+// POD_IP and HOST_IP are usually set from the kubernetes downward api, e.g. status.podIP
+var DefaultAdvertiseEnv = []string{"ADVERTISE_ADDRESS", "POD_IP", "HOST_IP"}
+
+// FromEnv returns the first non empty value of the given environment variables, or of DefaultAdvertiseEnv
+func FromEnv(keys ...string) (string, bool) {
+	if len(keys) == 0 {
+		keys = DefaultAdvertiseEnv
+	}
+	for _, k := range keys {
+		if v := os.Getenv(k); v != "" {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// Hostname returns the host name when it resolves to a local address, e.g. the container name in a docker network
+func Hostname() (string, bool) {
+	h, err := os.Hostname()
+	if err != nil || h == "" {
+		return "", false
+	}
+	ips, err := net.LookupHost(h)
+	if err != nil {
+		return "", false
+	}
+	for _, v := range ips {
+		if IsLocal(v) {
+			return h, true
+		}
+	}
+	return "", false
+}
+
+// Advertise returns the address to advertise for the bound address.
+// The advertise value may be a host or a host:port, the bound port is used when it has no port.
+func Advertise(advertise, bound string) (string, error) {
+	_, port, err := net.SplitHostPort(bound)
+	if err != nil {
+		return "", err
+	}
+	if h, p, err := net.SplitHostPort(advertise); err == nil {
+		return net.JoinHostPort(h, p), nil
+	}
+	return net.JoinHostPort(advertise, port), nil
+}
@@ -0,0 +1,41 @@
+package addr
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdvertise(t *testing.T) {
+	tests := []struct {
+		advertise string
+		bound     string
+		want      string
+	}{
+		{advertise: "10.0.0.1", bound: "0.0.0.0:9090", want: "10.0.0.1:9090"},
+		{advertise: "svc.example.com:443", bound: "0.0.0.0:9090", want: "svc.example.com:443"},
+		{advertise: "fd00::1", bound: "[::]:9090", want: "[fd00::1]:9090"},
+		{advertise: "[fd00::1]:8080", bound: "[::]:9090", want: "[fd00::1]:8080"},
+	}
+	for _, tt := range tests {
+		got, err := Advertise(tt.advertise, tt.bound)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got)
+	}
+	_, err := Advertise("10.0.0.1", "invalid")
+	assert.Error(t, err)
+}
+
+func TestFromEnv(t *testing.T) {
+	os.Setenv("TEST_ADVERTISE_EMPTY", "")
+	os.Setenv("TEST_ADVERTISE", "10.0.0.2")
+	defer os.Unsetenv("TEST_ADVERTISE_EMPTY")
+	defer os.Unsetenv("TEST_ADVERTISE")
+	v, ok := FromEnv("TEST_ADVERTISE_EMPTY", "TEST_ADVERTISE")
+	assert.True(t, ok)
+	assert.Equal(t, "10.0.0.2", v)
+	_, ok = FromEnv("TEST_ADVERTISE_EMPTY")
+	assert.False(t, ok)
+}
@@ -0,0 +1,209 @@
+// Package forwarded resolves the client address, scheme and host of the http requests, honoring the
+// Forwarded, X-Forwarded-For, X-Real-Ip, X-Forwarded-Proto and X-Forwarded-Host headers only when
+// the request comes from a trusted proxy, so that the logging, rate limiting, ip filtering and
+// redirects share the same decision.
+package forwarded
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Info is the resolved origin of a request
+type Info struct {
+	// ClientIP is the address of the client, without port
+	ClientIP string
+	// Scheme is the scheme used by the client, http or https
+	Scheme string
+	// Host is the host requested by the client
+	Host string
+	// RemoteAddr is the address of the peer, i.e. the last proxy when the headers are honored
+	RemoteAddr string
+	// Forwarded reports whether the forwarded headers were honored
+	Forwarded bool
+}
+
+// Trust is the set of proxies which forwarded headers are honored, the zero value trusts none
+type Trust struct {
+	nets []*net.IPNet
+}
+
+// New returns the trust of the proxies addresses or networks, e.g. 10.0.0.0/8 or 127.0.0.1
+func New(proxies ...string) (*Trust, error) {
+	t := &Trust{}
+	for _, v := range proxies {
+		if !strings.Contains(v, "/") {
+			ip := net.ParseIP(v)
+			if ip == nil {
+				return nil, fmt.Errorf("forwarded: invalid proxy address %q", v)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			t.nets = append(t.nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, fmt.Errorf("forwarded: invalid proxy network %q: %w", v, err)
+		}
+		t.nets = append(t.nets, n)
+	}
+	return t, nil
+}
+
+// Proxies returns the trusted proxies networks
+func (t *Trust) Proxies() []string {
+	if t == nil {
+		return nil
+	}
+	var proxies []string
+	for _, n := range t.nets {
+		proxies = append(proxies, n.String())
+	}
+	return proxies
+}
+
+// Trusted reports whether the request comes from a trusted proxy
+func (t *Trust) Trusted(r *http.Request) bool {
+	return t.trustedIP(host(r.RemoteAddr))
+}
+
+func (t *Trust) trustedIP(s string) bool {
+	if t == nil {
+		return false
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return false
+	}
+	for _, n := range t.nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve returns the origin of the request
+func (t *Trust) Resolve(r *http.Request) Info {
+	i := Info{ClientIP: host(r.RemoteAddr), Scheme: "http", Host: r.Host, RemoteAddr: r.RemoteAddr}
+	if r.TLS != nil {
+		i.Scheme = "https"
+	}
+	if !t.Trusted(r) {
+		return i
+	}
+	fwd := parseForwarded(r.Header.Get("Forwarded"))
+	if ip, ok := t.clientIP(fwd["for"], r.Header["X-Forwarded-For"]); ok {
+		i.ClientIP, i.Forwarded = ip, true
+	} else if v := r.Header.Get("X-Real-Ip"); v != "" && net.ParseIP(host(v)) != nil {
+		i.ClientIP, i.Forwarded = host(v), true
+	}
+	if v := first(fwd["proto"], r.Header.Get("X-Forwarded-Proto")); v == "http" || v == "https" {
+		i.Scheme, i.Forwarded = v, true
+	}
+	if v := first(fwd["host"], r.Header.Get("X-Forwarded-Host")); v != "" {
+		i.Host, i.Forwarded = v, true
+	}
+	return i
+}
+
+// clientIP walks the forwarded addresses from the nearest proxy and returns the first untrusted one,
+// as the leftmost values can be set by the client
+func (t *Trust) clientIP(fwd string, xff []string) (string, bool) {
+	var ips []string
+	if fwd != "" {
+		ips = strings.Split(fwd, ",")
+	} else {
+		for _, v := range xff {
+			ips = append(ips, strings.Split(v, ",")...)
+		}
+	}
+	var last string
+	for i := len(ips) - 1; i >= 0; i-- {
+		ip := host(strings.Trim(strings.TrimSpace(ips[i]), `"`))
+		if net.ParseIP(ip) == nil {
+			break
+		}
+		last = ip
+		if !t.trustedIP(ip) {
+			return ip, true
+		}
+	}
+	return last, last != ""
+}
+
+// Middleware stores the resolved origin in the request context, see FromRequest, and sets the request
+// remote address to the client one, so that the grpc-web and gateway peers are the clients
+func (t *Trust) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := t.Resolve(r)
+		r = r.WithContext(context.WithValue(r.Context(), infoKey{}, i))
+		if i.Forwarded {
+			r.RemoteAddr = net.JoinHostPort(i.ClientIP, "0")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type infoKey struct{}
+
+// FromRequest returns the origin resolved by the middleware, or the one of the direct peer if it did not run
+func FromRequest(r *http.Request) Info {
+	if i, ok := r.Context().Value(infoKey{}).(Info); ok {
+		return i
+	}
+	return (*Trust)(nil).Resolve(r)
+}
+
+// ClientIP returns the client address of the request, see FromRequest
+func ClientIP(r *http.Request) string {
+	return FromRequest(r).ClientIP
+}
+
+// parseForwarded returns the parameters of the last element of the RFC 7239 Forwarded header,
+// the for parameter contains the addresses of all the elements
+func parseForwarded(v string) map[string]string {
+	m := make(map[string]string)
+	if v == "" {
+		return m
+	}
+	var fors []string
+	for _, e := range strings.Split(v, ",") {
+		for _, p := range strings.Split(e, ";") {
+			kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			k, v := strings.ToLower(kv[0]), strings.Trim(kv[1], `"`)
+			if k == "for" {
+				fors = append(fors, v)
+				continue
+			}
+			m[k] = v
+		}
+	}
+	m["for"] = strings.Join(fors, ",")
+	return m
+}
+
+func host(addr string) string {
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		return h
+	}
+	return strings.Trim(addr, "[]")
+}
+
+func first(v ...string) string {
+	for _, s := range v {
+		if s != "" {
+			return strings.TrimSpace(strings.Split(s, ",")[0])
+		}
+	}
+	return ""
+}
@@ -0,0 +1,73 @@
+package forwarded
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolve(t *testing.T) {
+	trust, err := New("10.0.0.0/8", "192.0.2.1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.0/8", "192.0.2.1/32"}, trust.Proxies())
+	_, err = New("invalid")
+	assert.Error(t, err)
+
+	tests := []struct {
+		name    string
+		remote  string
+		headers map[string]string
+		want    Info
+	}{
+		{
+			name:    "untrusted",
+			remote:  "203.0.113.9:1234",
+			headers: map[string]string{"X-Forwarded-For": "198.51.100.1", "X-Forwarded-Proto": "https"},
+			want:    Info{ClientIP: "203.0.113.9", Scheme: "http", Host: "example.com", RemoteAddr: "203.0.113.9:1234"},
+		},
+		{
+			name:    "trusted proxy chain",
+			remote:  "10.0.0.2:1234",
+			headers: map[string]string{"X-Forwarded-For": "1.1.1.1, 198.51.100.1, 10.0.0.3", "X-Forwarded-Proto": "https", "X-Forwarded-Host": "api.example.com"},
+			want:    Info{ClientIP: "198.51.100.1", Scheme: "https", Host: "api.example.com", RemoteAddr: "10.0.0.2:1234", Forwarded: true},
+		},
+		{
+			name:    "forwarded header",
+			remote:  "192.0.2.1:1234",
+			headers: map[string]string{"Forwarded": `for="[2001:db8::1]:4711";proto=https, for=10.0.0.3`},
+			want:    Info{ClientIP: "2001:db8::1", Scheme: "https", Host: "example.com", RemoteAddr: "192.0.2.1:1234", Forwarded: true},
+		},
+		{
+			name:    "real ip",
+			remote:  "10.0.0.2:1234",
+			headers: map[string]string{"X-Real-Ip": "198.51.100.1"},
+			want:    Info{ClientIP: "198.51.100.1", Scheme: "http", Host: "example.com", RemoteAddr: "10.0.0.2:1234", Forwarded: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+			r.RemoteAddr = tt.remote
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			assert.Equal(t, tt.want, trust.Resolve(r))
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	trust, err := New("10.0.0.0/8")
+	require.NoError(t, err)
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.0.0.2:1234"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+	trust.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "198.51.100.1", ClientIP(r))
+		assert.Equal(t, "198.51.100.1:0", r.RemoteAddr)
+	})).ServeHTTP(httptest.NewRecorder(), r)
+	assert.Equal(t, "10.0.0.2", ClientIP(r))
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 // HostPort format addr and port suitable for dial
@@ -118,3 +119,73 @@ func Proxy(service string, address []string) (string, []string, bool) {
 
 	return service, address, hasProxy
 }
+
+// MultiListener returns a listener accepting the connections of all the given listeners,
+// e.g. an ipv4 and an ipv6 listener for dual-stack binding. Addr returns the first listener address.
+func MultiListener(ls ...net.Listener) net.Listener {
+	m := &multiListener{ls: ls, conns: make(chan acceptResult), closed: make(chan struct{})}
+	for _, l := range ls {
+		go m.accept(l)
+	}
+	return m
+}
+
+var errListenerClosed = errors.New("use of closed network connection")
+
+type acceptResult struct {
+	conn net.Conn
+	err  error
+}
+
+type multiListener struct {
+	ls     []net.Listener
+	conns  chan acceptResult
+	closed chan struct{}
+	once   sync.Once
+}
+
+func (m *multiListener) accept(l net.Listener) {
+	for {
+		c, err := l.Accept()
+		select {
+		case m.conns <- acceptResult{conn: c, err: err}:
+		case <-m.closed:
+			if c != nil {
+				c.Close()
+			}
+			return
+		}
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			return
+		}
+	}
+}
+
+func (m *multiListener) Accept() (net.Conn, error) {
+	select {
+	case r := <-m.conns:
+		return r.conn, r.err
+	case <-m.closed:
+		return nil, errListenerClosed
+	}
+}
+
+func (m *multiListener) Close() error {
+	var err error
+	m.once.Do(func() {
+		close(m.closed)
+		for _, l := range m.ls {
+			if e := l.Close(); e != nil && err == nil {
+				err = e
+			}
+		}
+	})
+	return err
+}
+
+func (m *multiListener) Addr() net.Addr {
+	return m.ls[0].Addr()
+}
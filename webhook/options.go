@@ -0,0 +1,112 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"go.linka.cloud/grpc/utils/backoff"
+)
+
+const (
+	defaultMaxAttempts = 5
+	defaultWorkers     = 4
+	defaultQueueSize   = 1024
+	defaultTimeout     = 10 * time.Second
+)
+
+// DeadLetter receives the deliveries that could not be delivered, e.g. to publish them on a broker
+// dead-letter topic. They can be replayed with Dispatcher.Redeliver.
+type DeadLetter interface {
+	DeadLetter(ctx context.Context, d Delivery, err error) error
+}
+
+// DeadLetterFunc is a func implementing DeadLetter
+type DeadLetterFunc func(ctx context.Context, d Delivery, err error) error
+
+func (fn DeadLetterFunc) DeadLetter(ctx context.Context, d Delivery, err error) error {
+	return fn(ctx, d, err)
+}
+
+type Option func(o *options)
+
+// WithHTTPClient sets the client used to post the deliveries, e.g. one built with httpclient.New
+func WithHTTPClient(c *http.Client) Option {
+	return func(o *options) {
+		o.client = c
+	}
+}
+
+// WithMaxAttempts sets the number of attempts before a delivery is dead-lettered, defaults to 5
+func WithMaxAttempts(n int) Option {
+	return func(o *options) {
+		o.maxAttempts = n
+	}
+}
+
+// WithBackoff sets the delay before the given retry attempt, starting at 1, defaults to backoff.Do
+func WithBackoff(fn func(attempt int) time.Duration) Option {
+	return func(o *options) {
+		o.backoff = fn
+	}
+}
+
+// WithWorkers sets the number of concurrent deliveries, defaults to 4
+func WithWorkers(n int) Option {
+	return func(o *options) {
+		o.workers = n
+	}
+}
+
+// WithQueueSize sets the number of deliveries waiting to be sent before Dispatch fails with ErrQueueFull,
+// defaults to 1024
+func WithQueueSize(n int) Option {
+	return func(o *options) {
+		o.queueSize = n
+	}
+}
+
+// WithTimeout sets the timeout of a delivery attempt, defaults to 10 seconds
+func WithTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.timeout = d
+	}
+}
+
+// WithDeadLetter sets the dead-letter handler, the failed deliveries are only logged if none is configured
+func WithDeadLetter(dl DeadLetter) Option {
+	return func(o *options) {
+		o.deadLetter = dl
+	}
+}
+
+type options struct {
+	client      *http.Client
+	maxAttempts int
+	backoff     func(attempt int) time.Duration
+	workers     int
+	queueSize   int
+	timeout     time.Duration
+	deadLetter  DeadLetter
+}
+
+func newOptions(opts ...Option) options {
+	o := options{
+		client:      http.DefaultClient,
+		maxAttempts: defaultMaxAttempts,
+		backoff:     backoff.Do,
+		workers:     defaultWorkers,
+		queueSize:   defaultQueueSize,
+		timeout:     defaultTimeout,
+	}
+	for _, v := range opts {
+		v(&o)
+	}
+	if o.workers < 1 {
+		o.workers = 1
+	}
+	if o.maxAttempts < 1 {
+		o.maxAttempts = 1
+	}
+	return o
+}
@@ -0,0 +1,79 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// SignatureHeader carries the timestamp and the signature of the delivery, e.g. t=1700000000,v1=5257a8...
+	SignatureHeader = "X-Webhook-Signature"
+	// IDHeader carries the delivery id, which stays the same across the attempts so that consumers can deduplicate
+	IDHeader = "X-Webhook-Id"
+	// EventHeader carries the event name
+	EventHeader = "X-Webhook-Event"
+)
+
+var (
+	ErrInvalidSignature = errors.New("webhook: invalid signature")
+	ErrExpiredSignature = errors.New("webhook: signature timestamp out of tolerance")
+)
+
+// Sign returns the signature header value of body signed with secret at t.
+// The signature is the hex encoded HMAC-SHA256 of "<unix timestamp>.<body>".
+func Sign(secret []byte, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac(secret, ts, body))
+}
+
+// Verify checks the signature header value of body, it is meant to be used by the consumers.
+// The signature timestamp must be within tolerance of the current time, a zero tolerance disables the check.
+// Several v1 signatures may be present while the secret is rotated.
+func Verify(secret []byte, header string, body []byte, tolerance time.Duration) error {
+	var ts string
+	var sigs [][]byte
+	for _, v := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(v), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "t":
+			ts = kv[1]
+		case "v1":
+			if b, err := hex.DecodeString(kv[1]); err == nil {
+				sigs = append(sigs, b)
+			}
+		}
+	}
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || len(sigs) == 0 {
+		return ErrInvalidSignature
+	}
+	if tolerance > 0 {
+		d := time.Since(time.Unix(sec, 0))
+		if d > tolerance || d < -tolerance {
+			return ErrExpiredSignature
+		}
+	}
+	want := mac(secret, ts, body)
+	for _, v := range sigs {
+		if hmac.Equal(v, want) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+func mac(secret []byte, ts string, body []byte) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(ts))
+	h.Write([]byte("."))
+	h.Write(body)
+	return h.Sum(nil)
+}
@@ -0,0 +1,327 @@
+// Package webhook delivers signed webhooks to the registered consumer endpoints,
+// retrying with backoff and dead-lettering the deliveries that keep failing.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"go.linka.cloud/grpc/logger"
+)
+
+var (
+	ErrQueueFull       = errors.New("webhook: delivery queue is full")
+	ErrClosed          = errors.New("webhook: dispatcher stopped")
+	ErrInvalidEndpoint = errors.New("webhook: invalid endpoint")
+)
+
+// Endpoint is a consumer url
+type Endpoint struct {
+	ID  string
+	URL string
+	// Secret signs the deliveries, they are not signed if it is empty
+	Secret []byte
+	// Events are the event names the endpoint is subscribed to, all the events are delivered if it is empty
+	Events []string
+}
+
+func (e Endpoint) subscribed(event string) bool {
+	if len(e.Events) == 0 {
+		return true
+	}
+	for _, v := range e.Events {
+		if v == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Delivery is an event to be delivered to an endpoint
+type Delivery struct {
+	ID       string    `json:"id"`
+	Event    string    `json:"event"`
+	Endpoint string    `json:"endpoint"`
+	Payload  []byte    `json:"payload"`
+	Attempts int       `json:"attempts"`
+	Created  time.Time `json:"created"`
+}
+
+// permanentError is a failure which is not worth retrying, e.g. a 400 Bad Request
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+// Dispatcher delivers the events to the endpoints subscribed to them.
+// The deliveries are queued and sent by Run, which must be started, e.g. as a service background task.
+// It is a prometheus.Collector exposing the deliveries metrics.
+type Dispatcher struct {
+	opts      options
+	mu        sync.RWMutex
+	endpoints map[string]Endpoint
+	queue     chan Delivery
+
+	deliveries *prometheus.CounterVec
+	duration   *prometheus.HistogramVec
+	queued     prometheus.GaugeFunc
+}
+
+// New returns a Dispatcher
+func New(opts ...Option) *Dispatcher {
+	d := &Dispatcher{opts: newOptions(opts...), endpoints: make(map[string]Endpoint)}
+	d.queue = make(chan Delivery, d.opts.queueSize)
+	d.deliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhook_deliveries_total",
+		Help: "Total number of webhook delivery attempts by result: success, retry, dead_letter or dropped.",
+	}, []string{"event", "result"})
+	d.duration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "webhook_delivery_duration_seconds",
+		Help:    "Duration of the webhook delivery attempts.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"event"})
+	d.queued = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "webhook_queue_length",
+		Help: "Number of webhook deliveries waiting to be sent.",
+	}, func() float64 {
+		return float64(len(d.queue))
+	})
+	return d
+}
+
+// Register adds or replaces an endpoint
+func (d *Dispatcher) Register(e Endpoint) error {
+	if e.ID == "" {
+		return fmt.Errorf("%w: missing id", ErrInvalidEndpoint)
+	}
+	if u, err := url.Parse(e.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: invalid url %q", ErrInvalidEndpoint, e.URL)
+	}
+	d.mu.Lock()
+	d.endpoints[e.ID] = e
+	d.mu.Unlock()
+	return nil
+}
+
+// Unregister removes an endpoint, its pending deliveries are dropped
+func (d *Dispatcher) Unregister(id string) {
+	d.mu.Lock()
+	delete(d.endpoints, id)
+	d.mu.Unlock()
+}
+
+// Endpoints returns the registered endpoints sorted by id
+func (d *Dispatcher) Endpoints() []Endpoint {
+	d.mu.RLock()
+	out := make([]Endpoint, 0, len(d.endpoints))
+	for _, v := range d.endpoints {
+		out = append(out, v)
+	}
+	d.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+func (d *Dispatcher) endpoint(id string) (Endpoint, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	e, ok := d.endpoints[id]
+	return e, ok
+}
+
+// Dispatch queues a delivery of the event to every subscribed endpoint.
+// The payload is encoded as json, unless it is a []byte or a json.RawMessage which are sent as is.
+// It does not block: ErrQueueFull is returned if the queue can not hold all the deliveries.
+func (d *Dispatcher) Dispatch(ctx context.Context, event string, payload interface{}) error {
+	var b []byte
+	switch v := payload.(type) {
+	case []byte:
+		b = v
+	case json.RawMessage:
+		b = v
+	default:
+		var err error
+		if b, err = json.Marshal(payload); err != nil {
+			return err
+		}
+	}
+	now := time.Now()
+	for _, e := range d.Endpoints() {
+		if !e.subscribed(event) {
+			continue
+		}
+		if err := d.enqueue(Delivery{ID: uuid.New().String(), Event: event, Endpoint: e.ID, Payload: b, Created: now}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Redeliver queues a dead-lettered delivery again, resetting its attempts
+func (d *Dispatcher) Redeliver(del Delivery) error {
+	if _, ok := d.endpoint(del.Endpoint); !ok {
+		return fmt.Errorf("%w: %s is not registered", ErrInvalidEndpoint, del.Endpoint)
+	}
+	del.Attempts = 0
+	return d.enqueue(del)
+}
+
+func (d *Dispatcher) enqueue(del Delivery) error {
+	select {
+	case d.queue <- del:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Run sends the queued deliveries until ctx is done.
+// The deliveries still queued or waiting for a retry when it returns are dead-lettered with ErrClosed.
+func (d *Dispatcher) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for i := 0; i < d.opts.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case del := <-d.queue:
+					d.deliver(ctx, &wg, del)
+				}
+			}
+		}()
+	}
+	<-ctx.Done()
+	wg.Wait()
+	for {
+		select {
+		case del := <-d.queue:
+			d.deadLetter(ctx, del, ErrClosed)
+		default:
+			return nil
+		}
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, wg *sync.WaitGroup, del Delivery) {
+	e, ok := d.endpoint(del.Endpoint)
+	if !ok {
+		d.deliveries.WithLabelValues(del.Event, "dropped").Inc()
+		return
+	}
+	del.Attempts++
+	start := time.Now()
+	err := d.send(ctx, e, del)
+	d.duration.WithLabelValues(del.Event).Observe(time.Since(start).Seconds())
+	if err == nil {
+		d.deliveries.WithLabelValues(del.Event, "success").Inc()
+		return
+	}
+	var perm *permanentError
+	if ctx.Err() != nil {
+		err = ErrClosed
+	}
+	if errors.As(err, &perm) || del.Attempts >= d.opts.maxAttempts || ctx.Err() != nil {
+		d.deadLetter(ctx, del, err)
+		return
+	}
+	d.deliveries.WithLabelValues(del.Event, "retry").Inc()
+	logger.C(ctx).Debugf("webhook: delivery %s to %s failed, retrying: %v", del.ID, e.ID, err)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		t := time.NewTimer(d.opts.backoff(del.Attempts))
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			d.deadLetter(ctx, del, ErrClosed)
+			return
+		}
+		select {
+		case d.queue <- del:
+		case <-ctx.Done():
+			d.deadLetter(ctx, del, ErrClosed)
+		}
+	}()
+}
+
+func (d *Dispatcher) send(ctx context.Context, e Endpoint, del Delivery) error {
+	ctx, cancel := context.WithTimeout(ctx, d.opts.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.URL, bytes.NewReader(del.Payload))
+	if err != nil {
+		return &permanentError{err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(IDHeader, del.ID)
+	req.Header.Set(EventHeader, del.Event)
+	if len(e.Secret) != 0 {
+		req.Header.Set(SignatureHeader, Sign(e.Secret, time.Now(), del.Payload))
+	}
+	res, err := d.opts.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(res.Body, 64<<10))
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+	err = fmt.Errorf("webhook: %s returned %s", e.URL, res.Status)
+	switch {
+	case res.StatusCode == http.StatusRequestTimeout, res.StatusCode == http.StatusTooManyRequests:
+		return err
+	case res.StatusCode >= 400 && res.StatusCode < 500:
+		return &permanentError{err: err}
+	}
+	return err
+}
+
+func (d *Dispatcher) deadLetter(ctx context.Context, del Delivery, err error) {
+	d.deliveries.WithLabelValues(del.Event, "dead_letter").Inc()
+	log := logger.C(ctx)
+	if d.opts.deadLetter == nil {
+		log.Warnf("webhook: delivery %s of %s to %s failed after %d attempts: %v", del.ID, del.Event, del.Endpoint, del.Attempts, err)
+		return
+	}
+	// the dead-letter handler must run even if the dispatcher is stopping
+	if ctx.Err() != nil {
+		ctx = context.Background()
+	}
+	if err := d.opts.deadLetter.DeadLetter(ctx, del, err); err != nil {
+		log.Errorf("webhook: failed to dead-letter delivery %s: %v", del.ID, err)
+	}
+}
+
+func (d *Dispatcher) Describe(c chan<- *prometheus.Desc) {
+	d.deliveries.Describe(c)
+	d.duration.Describe(c)
+	d.queued.Describe(c)
+}
+
+func (d *Dispatcher) Collect(c chan<- prometheus.Metric) {
+	d.deliveries.Collect(c)
+	d.duration.Collect(c)
+	d.queued.Collect(c)
+}
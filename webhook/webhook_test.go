@@ -0,0 +1,121 @@
+package webhook
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignVerify(t *testing.T) {
+	secret, body := []byte("secret"), []byte(`{"ok":true}`)
+	h := Sign(secret, time.Now(), body)
+	assert.NoError(t, Verify(secret, h, body, time.Minute))
+	assert.Equal(t, ErrInvalidSignature, Verify([]byte("other"), h, body, time.Minute))
+	assert.Equal(t, ErrInvalidSignature, Verify(secret, h, []byte(`{}`), time.Minute))
+	assert.Equal(t, ErrInvalidSignature, Verify(secret, "garbage", body, 0))
+	old := Sign(secret, time.Now().Add(-time.Hour), body)
+	assert.Equal(t, ErrExpiredSignature, Verify(secret, old, body, time.Minute))
+	assert.NoError(t, Verify(secret, old, body, 0))
+	// rotation: any of the signatures may match
+	assert.NoError(t, Verify(secret, Sign([]byte("other"), time.Now(), body)+","+h[len("t=1234567890,"):], body, time.Minute))
+}
+
+type deadLetters struct {
+	mu  sync.Mutex
+	ds  []Delivery
+	err []error
+}
+
+func (d *deadLetters) DeadLetter(_ context.Context, del Delivery, err error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.ds = append(d.ds, del)
+	d.err = append(d.err, err)
+	return nil
+}
+
+func (d *deadLetters) len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.ds)
+}
+
+func TestDispatcher(t *testing.T) {
+	secret := []byte("secret")
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	received := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		calls[r.URL.Path]++
+		n := calls[r.URL.Path]
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/flaky":
+			if n < 3 {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		case "/bad":
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if err := Verify(secret, r.Header.Get(SignatureHeader), b, time.Minute); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		received <- r.URL.Path + " " + r.Header.Get(EventHeader) + " " + string(b)
+	}))
+	defer srv.Close()
+
+	dl := &deadLetters{}
+	d := New(WithBackoff(func(int) time.Duration { return time.Millisecond }), WithDeadLetter(dl))
+	assert.Error(t, d.Register(Endpoint{ID: "invalid", URL: "ftp://example.org"}))
+	require.NoError(t, d.Register(Endpoint{ID: "flaky", URL: srv.URL + "/flaky", Secret: secret}))
+	require.NoError(t, d.Register(Endpoint{ID: "bad", URL: srv.URL + "/bad", Secret: secret, Events: []string{"user.created"}}))
+	require.NoError(t, d.Register(Endpoint{ID: "other", URL: srv.URL + "/other", Secret: secret, Events: []string{"user.deleted"}}))
+	assert.Len(t, d.Endpoints(), 3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- d.Run(ctx)
+	}()
+	require.NoError(t, d.Dispatch(ctx, "user.created", map[string]string{"id": "1"}))
+
+	select {
+	case v := <-received:
+		assert.Equal(t, `/flaky user.created {"id":"1"}`, v)
+	case <-time.After(5 * time.Second):
+		t.Fatal("delivery not received")
+	}
+	require.Eventually(t, func() bool {
+		return dl.len() == 1 && testutil.ToFloat64(d.deliveries.WithLabelValues("user.created", "success")) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "bad", dl.ds[0].Endpoint)
+	assert.Equal(t, 1, dl.ds[0].Attempts)
+	assert.Equal(t, 2.0, testutil.ToFloat64(d.deliveries.WithLabelValues("user.created", "retry")))
+	mu.Lock()
+	assert.Equal(t, 0, calls["/other"])
+	mu.Unlock()
+
+	cancel()
+	assert.NoError(t, <-done)
+
+	// the deliveries queued while stopped are dead-lettered when the dispatcher stops
+	require.NoError(t, d.Redeliver(dl.ds[0]))
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	assert.NoError(t, d.Run(ctx))
+	assert.Equal(t, 2, dl.len())
+	assert.Equal(t, ErrClosed, dl.err[1])
+}